@@ -0,0 +1,148 @@
+package pivot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/negroni"
+)
+
+var RequestIdHeader = `X-Request-ID`
+
+type requestContextKey int
+
+const (
+	requestDetailsKey requestContextKey = iota
+)
+
+// Details about a request being processed by the server that will be reported in the access log.
+type RequestDetails struct {
+	RequestID   string
+	Collection  string
+	RecordCount int
+	StartedAt   time.Time
+}
+
+type AccessLogEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	RequestID   string    `json:"request_id"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Query       string    `json:"query,omitempty"`
+	RemoteAddr  string    `json:"remote_addr"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	Status      int       `json:"status"`
+	Bytes       int       `json:"bytes"`
+	LatencyMs   float64   `json:"latency_ms"`
+	Collection  string    `json:"collection,omitempty"`
+	RecordCount int       `json:"record_count"`
+}
+
+// A negroni middleware that assigns every request an ID (or propagates the one given in the
+// X-Request-ID header) and writes a JSON-encoded AccessLogEntry for every completed request.
+type AccessLogger struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+func NewAccessLogger(writer io.Writer) *AccessLogger {
+	return &AccessLogger{
+		writer: writer,
+	}
+}
+
+func (self *AccessLogger) ServeHTTP(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	details := &RequestDetails{
+		RequestID:  req.Header.Get(RequestIdHeader),
+		Collection: collectionFromPath(req.URL.Path),
+		StartedAt:  time.Now(),
+	}
+
+	if details.RequestID == `` {
+		details.RequestID = generateRequestId()
+	}
+
+	w.Header().Set(RequestIdHeader, details.RequestID)
+	req = req.WithContext(context.WithValue(req.Context(), requestDetailsKey, details))
+
+	next(w, req)
+
+	if self.writer == nil {
+		return
+	}
+
+	entry := AccessLogEntry{
+		Timestamp:   details.StartedAt,
+		RequestID:   details.RequestID,
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Query:       req.URL.RawQuery,
+		RemoteAddr:  req.RemoteAddr,
+		UserAgent:   req.UserAgent(),
+		LatencyMs:   float64(time.Since(details.StartedAt)) / float64(time.Millisecond),
+		Collection:  details.Collection,
+		RecordCount: details.RecordCount,
+	}
+
+	if rw, ok := w.(negroni.ResponseWriter); ok {
+		entry.Status = rw.Status()
+		entry.Bytes = rw.Size()
+	}
+
+	if data, err := json.Marshal(&entry); err == nil {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
+		self.writer.Write(append(data, '\n'))
+	} else {
+		log.Warningf("access log error: %v", err)
+	}
+}
+
+// Retrieve the details of the given request as populated by the AccessLogger middleware.
+func GetRequestDetails(req *http.Request) *RequestDetails {
+	if v := req.Context().Value(requestDetailsKey); v != nil {
+		if details, ok := v.(*RequestDetails); ok {
+			return details
+		}
+	}
+
+	return &RequestDetails{}
+}
+
+// Retrieve the ID of the given request.
+func GetRequestId(req *http.Request) string {
+	return GetRequestDetails(req).RequestID
+}
+
+func setRequestRecordCount(req *http.Request, count int) {
+	GetRequestDetails(req).RecordCount = count
+}
+
+func collectionFromPath(path string) string {
+	for _, prefix := range []string{`/api/collections/`, `/api/schema/`} {
+		if strings.HasPrefix(path, prefix) {
+			parts := strings.SplitN(strings.TrimPrefix(path, prefix), `/`, 2)
+			return parts[0]
+		}
+	}
+
+	return ``
+}
+
+func generateRequestId() string {
+	id := make([]byte, 16)
+
+	if _, err := rand.Read(id); err == nil {
+		return hex.EncodeToString(id)
+	}
+
+	return ``
+}
@@ -0,0 +1,73 @@
+package pivot
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+func TestAccessLogger(t *testing.T) {
+	assert := require.New(t)
+	var buf bytes.Buffer
+	var seenId string
+
+	server := negroni.New(NewAccessLogger(&buf))
+	server.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seenId = GetRequestId(req)
+		setRequestRecordCount(req, 3)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`hello`))
+	}))
+
+	// request IDs given by the client are propagated
+	req := httptest.NewRequest(`POST`, `/api/collections/users/records?x=1`, nil)
+	req.Header.Set(RequestIdHeader, `abc123`)
+	req.Header.Set(`User-Agent`, `tester`)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	assert.Equal(`abc123`, seenId)
+	assert.Equal(`abc123`, w.Header().Get(RequestIdHeader))
+
+	var entry AccessLogEntry
+	assert.NoError(json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(`abc123`, entry.RequestID)
+	assert.Equal(`POST`, entry.Method)
+	assert.Equal(`/api/collections/users/records`, entry.Path)
+	assert.Equal(`x=1`, entry.Query)
+	assert.Equal(`tester`, entry.UserAgent)
+	assert.Equal(http.StatusCreated, entry.Status)
+	assert.Equal(5, entry.Bytes)
+	assert.Equal(`users`, entry.Collection)
+	assert.Equal(3, entry.RecordCount)
+
+	// otherwise, one is generated
+	buf.Reset()
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(`GET`, `/api/status`, nil))
+
+	assert.Len(seenId, 32)
+	assert.Equal(seenId, w.Header().Get(RequestIdHeader))
+
+	entry = AccessLogEntry{}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(seenId, entry.RequestID)
+	assert.Empty(entry.Collection)
+}
+
+func TestCollectionFromPath(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(`users`, collectionFromPath(`/api/collections/users`))
+	assert.Equal(`users`, collectionFromPath(`/api/collections/users/records/1`))
+	assert.Equal(`users`, collectionFromPath(`/api/schema/users`))
+	assert.Equal(``, collectionFromPath(`/api/status`))
+	assert.Equal(``, collectionFromPath(`/`))
+}
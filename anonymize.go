@@ -0,0 +1,201 @@
+package pivot
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghodss/yaml"
+)
+
+type AnonymizeRuleType string
+
+const (
+	AnonymizeFullName  AnonymizeRuleType = `name`
+	AnonymizeFirstName                   = `first_name`
+	AnonymizeLastName                    = `last_name`
+	AnonymizeEmail                       = `email`
+	AnonymizeHash                        = `hash`
+	AnonymizeShiftDate                   = `shift_date`
+	AnonymizeConstant                    = `constant`
+	AnonymizeRedact                      = `redact`
+	AnonymizeClear                       = `clear`
+)
+
+var anonymizeFirstNames = []string{
+	`Alex`, `Blake`, `Casey`, `Dana`, `Eli`, `Frankie`, `Gray`, `Harper`, `Indy`, `Jesse`, `Kai`,
+	`Logan`, `Morgan`, `Noel`, `Oakley`, `Parker`, `Quinn`, `Riley`, `Sage`, `Taylor`, `Val`,
+	`Winter`, `Yael`, `Zion`,
+}
+
+var anonymizeLastNames = []string{
+	`Abbott`, `Barker`, `Chandler`, `Dalton`, `Ellison`, `Fletcher`, `Garner`, `Hollis`, `Irwin`,
+	`Jensen`, `Keller`, `Lowell`, `Mercer`, `Nolan`, `Osborne`, `Porter`, `Quincy`, `Rowe`,
+	`Sutton`, `Thorne`, `Underwood`, `Vaughn`, `Whitaker`, `Young`,
+}
+
+// Describes how the value of a field should be replaced.  In rules files, a rule may be given as just
+// its type (e.g.: "email") or as an object with additional parameters.
+type AnonymizeRule struct {
+	Type AnonymizeRuleType `json:"type"`
+
+	// For shift_date: the maximum number of days (in either direction) to shift dates by.
+	Days int `json:"days,omitempty"`
+
+	// For constant: the value to replace the field with.
+	Value interface{} `json:"value,omitempty"`
+}
+
+func (self *AnonymizeRule) UnmarshalJSON(data []byte) error {
+	var ruleType string
+
+	if err := json.Unmarshal(data, &ruleType); err == nil {
+		self.Type = AnonymizeRuleType(ruleType)
+		return nil
+	}
+
+	type rule AnonymizeRule
+	return json.Unmarshal(data, (*rule)(self))
+}
+
+// A set of rules for rewriting personally-identifiable information, keyed on collection name and then
+// field name.  Replacement values are derived from a hash of the original value and the salt, so the
+// same input always produces the same output within a run (preserving relationships between
+// records), but the originals cannot be recovered without the salt.
+type AnonymizeRules struct {
+	Collections map[string]map[string]AnonymizeRule
+	Salt        string
+}
+
+// Loads anonymization rules from the given JSON or YAML file.
+func LoadAnonymizeRules(filename string) (*AnonymizeRules, error) {
+	data, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &AnonymizeRules{}
+
+	switch ext := path.Ext(filename); ext {
+	case `.json`:
+		err = json.Unmarshal(data, &rules.Collections)
+	case `.yml`, `.yaml`:
+		err = yaml.Unmarshal(data, &rules.Collections)
+	default:
+		return nil, fmt.Errorf("Unrecognized file extension %s", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("decode error: %v", err)
+	}
+
+	for collection, fields := range rules.Collections {
+		for field, rule := range fields {
+			switch rule.Type {
+			case AnonymizeFullName, AnonymizeFirstName, AnonymizeLastName, AnonymizeEmail,
+				AnonymizeHash, AnonymizeConstant, AnonymizeRedact, AnonymizeClear:
+			case AnonymizeShiftDate:
+				if rule.Days <= 0 {
+					return nil, fmt.Errorf("%s.%s: shift_date requires a positive number of days", collection, field)
+				}
+			default:
+				return nil, fmt.Errorf("%s.%s: unknown rule type %q", collection, field, rule.Type)
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// Returns whether there are any rules for the given collection.
+func (self *AnonymizeRules) Has(collection string) bool {
+	if self == nil {
+		return false
+	}
+
+	return len(self.Collections[collection]) > 0
+}
+
+// Rewrites the fields of the given record according to the rules for the given collection.  The
+// record is modified in place.
+func (self *AnonymizeRules) Apply(collection string, record *dal.Record) error {
+	if !self.Has(collection) {
+		return nil
+	}
+
+	for field, rule := range self.Collections[collection] {
+		value := record.Get(field)
+
+		if value == nil && rule.Type != AnonymizeConstant {
+			continue
+		}
+
+		if replacement, err := self.replace(rule, record, value); err == nil {
+			record.Set(field, replacement)
+		} else {
+			return fmt.Errorf("field %q: %v", field, err)
+		}
+	}
+
+	return nil
+}
+
+func (self *AnonymizeRules) replace(rule AnonymizeRule, record *dal.Record, value interface{}) (interface{}, error) {
+	sum := self.hash(value)
+	n := binary.BigEndian.Uint64(sum[:8])
+
+	switch rule.Type {
+	case AnonymizeFullName:
+		first := anonymizeFirstNames[n%uint64(len(anonymizeFirstNames))]
+		last := anonymizeLastNames[(n>>32)%uint64(len(anonymizeLastNames))]
+		return first + ` ` + last, nil
+
+	case AnonymizeFirstName:
+		return anonymizeFirstNames[n%uint64(len(anonymizeFirstNames))], nil
+
+	case AnonymizeLastName:
+		return anonymizeLastNames[n%uint64(len(anonymizeLastNames))], nil
+
+	case AnonymizeEmail:
+		return fmt.Sprintf("user-%s@example.com", hex.EncodeToString(sum[:6])), nil
+
+	case AnonymizeHash:
+		return hex.EncodeToString(sum[:16]), nil
+
+	case AnonymizeShiftDate:
+		if t, err := stringutil.ConvertToTime(value); err == nil {
+			// shift every date in a record by the same amount so that intervals between them are kept
+			shift := self.hash(record.ID)
+			days := int64(binary.BigEndian.Uint64(shift[:8])%uint64(2*rule.Days+1)) - int64(rule.Days)
+
+			return t.Add(time.Duration(days) * 24 * time.Hour), nil
+		} else {
+			return nil, err
+		}
+
+	case AnonymizeConstant:
+		return rule.Value, nil
+
+	case AnonymizeRedact:
+		return dal.RedactedValue, nil
+
+	case AnonymizeClear:
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown rule type %q", rule.Type)
+	}
+}
+
+func (self *AnonymizeRules) hash(value interface{}) []byte {
+	sum := sha256.Sum256([]byte(self.Salt + "\x00" + fmt.Sprintf("%v", value)))
+	return sum[:]
+}
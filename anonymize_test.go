@@ -0,0 +1,162 @@
+package pivot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAnonymizeRules(salt string) *AnonymizeRules {
+	return &AnonymizeRules{
+		Salt: salt,
+		Collections: map[string]map[string]AnonymizeRule{
+			`users`: {
+				`name`:       {Type: AnonymizeFullName},
+				`first`:      {Type: AnonymizeFirstName},
+				`last`:       {Type: AnonymizeLastName},
+				`email`:      {Type: AnonymizeEmail},
+				`ssn`:        {Type: AnonymizeHash},
+				`born_at`:    {Type: AnonymizeShiftDate, Days: 30},
+				`joined_at`:  {Type: AnonymizeShiftDate, Days: 30},
+				`country`:    {Type: AnonymizeConstant, Value: `XX`},
+				`password`:   {Type: AnonymizeRedact},
+				`last_login`: {Type: AnonymizeClear},
+			},
+		},
+	}
+}
+
+func newTestAnonymizeRecord() *dal.Record {
+	return dal.NewRecord(1).
+		Set(`name`, `Alice Smith`).
+		Set(`first`, `Alice`).
+		Set(`last`, `Smith`).
+		Set(`email`, `alice@example.org`).
+		Set(`ssn`, `123-45-6789`).
+		Set(`born_at`, time.Date(1990, 4, 1, 0, 0, 0, 0, time.UTC)).
+		Set(`joined_at`, time.Date(2015, 6, 15, 0, 0, 0, 0, time.UTC)).
+		Set(`password`, `hunter2`).
+		Set(`last_login`, `yesterday`).
+		Set(`age`, 31)
+}
+
+func TestAnonymizeRulesApply(t *testing.T) {
+	assert := require.New(t)
+	rules := newTestAnonymizeRules(`salt`)
+	record := newTestAnonymizeRecord()
+
+	assert.NoError(rules.Apply(`users`, record))
+
+	// names are replaced with fake ones
+	parts := strings.Split(record.Get(`name`).(string), ` `)
+	assert.Len(parts, 2)
+	assert.Contains(anonymizeFirstNames, parts[0])
+	assert.Contains(anonymizeLastNames, parts[1])
+	assert.Contains(anonymizeFirstNames, record.Get(`first`))
+	assert.Contains(anonymizeLastNames, record.Get(`last`))
+
+	// emails and other identifiers are replaced with hashes
+	assert.Regexp(regexp.MustCompile(`^user-[0-9a-f]{12}@example\.com$`), record.Get(`email`))
+	assert.Regexp(regexp.MustCompile(`^[0-9a-f]{32}$`), record.Get(`ssn`))
+
+	// dates are shifted by no more than the given number of days, all by the same amount
+	bornAt := record.Get(`born_at`).(time.Time)
+	joinedAt := record.Get(`joined_at`).(time.Time)
+	shift := bornAt.Sub(time.Date(1990, 4, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.True(shift <= 30*24*time.Hour && shift >= -30*24*time.Hour, shift)
+	assert.Equal(shift, joinedAt.Sub(time.Date(2015, 6, 15, 0, 0, 0, 0, time.UTC)))
+
+	assert.Equal(`XX`, record.Get(`country`))
+	assert.Equal(dal.RedactedValue, record.Get(`password`))
+	assert.Nil(record.Get(`last_login`))
+
+	// fields without rules are left alone
+	assert.Equal(31, record.Get(`age`))
+	assert.EqualValues(1, record.ID)
+}
+
+func TestAnonymizeRulesDeterministic(t *testing.T) {
+	assert := require.New(t)
+
+	first := newTestAnonymizeRecord()
+	second := newTestAnonymizeRecord()
+	assert.NoError(newTestAnonymizeRules(`salt`).Apply(`users`, first))
+	assert.NoError(newTestAnonymizeRules(`salt`).Apply(`users`, second))
+
+	// the same input and salt always produce the same output, so relationships between records survive
+	for _, field := range []string{`name`, `first`, `last`, `email`, `ssn`, `born_at`} {
+		assert.Equal(first.Get(field), second.Get(field), field)
+	}
+
+	// ...but a different salt produces different output
+	other := newTestAnonymizeRecord()
+	assert.NoError(newTestAnonymizeRules(`pepper`).Apply(`users`, other))
+	assert.NotEqual(first.Get(`email`), other.Get(`email`))
+	assert.NotEqual(first.Get(`ssn`), other.Get(`ssn`))
+}
+
+func TestAnonymizeRulesUnmatched(t *testing.T) {
+	assert := require.New(t)
+	rules := newTestAnonymizeRules(`salt`)
+
+	// collections without rules aren't touched
+	record := newTestAnonymizeRecord()
+	assert.False(rules.Has(`groups`))
+	assert.NoError(rules.Apply(`groups`, record))
+	assert.Equal(newTestAnonymizeRecord(), record)
+
+	// missing values aren't filled in, except by constants
+	record = dal.NewRecord(2).Set(`age`, 25)
+	assert.NoError(rules.Apply(`users`, record))
+	assert.Equal(map[string]interface{}{
+		`age`:     25,
+		`country`: `XX`,
+	}, record.Fields)
+
+	var none *AnonymizeRules
+	assert.False(none.Has(`users`))
+	assert.NoError(none.Apply(`users`, record))
+
+	// values that aren't dates can't be shifted
+	assert.Error(rules.Apply(`users`, dal.NewRecord(3).Set(`born_at`, `not a date`)))
+}
+
+func TestLoadAnonymizeRules(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-anonymize-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, `rules.yml`)
+	assert.NoError(ioutil.WriteFile(filename, []byte(`
+users:
+    email: email
+    born_at:
+        type: shift_date
+        days: 7
+`), 0644))
+
+	rules, err := LoadAnonymizeRules(filename)
+	assert.NoError(err)
+	assert.Equal(AnonymizeRule{Type: AnonymizeEmail}, rules.Collections[`users`][`email`])
+	assert.Equal(AnonymizeRule{Type: AnonymizeShiftDate, Days: 7}, rules.Collections[`users`][`born_at`])
+
+	for _, invalid := range []string{
+		"users:\n    email: scramble\n",
+		"users:\n    born_at: shift_date\n",
+	} {
+		assert.NoError(ioutil.WriteFile(filename, []byte(invalid), 0644))
+
+		_, err := LoadAnonymizeRules(filename)
+		assert.Error(err, invalid)
+	}
+}
@@ -35,20 +35,39 @@ type Backend interface {
 	Ping(time.Duration) error
 }
 
-var NotImplementedError = fmt.Errorf("Not Implemented")
+var NotImplementedError = dal.ErrUnsupported
 
 type BackendFunc func(dal.ConnectionString) Backend
 
 var backendMap = map[string]BackendFunc{
-	`dynamodb`:   NewDynamoBackend,
-	`file`:       NewFilesystemBackend,
-	`fs`:         NewFilesystemBackend,
-	`mongodb`:    NewMongoBackend,
-	`mysql`:      NewSqlBackend,
-	`postgres`:   NewSqlBackend,
-	`postgresql`: NewSqlBackend,
-	`psql`:       NewSqlBackend,
-	`sqlite`:     NewSqlBackend,
+	`ansi`:          NewSqlBackend,
+	`badger`:        NewBadgerBackend,
+	`bigquery`:      NewBigqueryBackend,
+	`bolt`:          NewBoltBackend,
+	`clickhouse`:    NewSqlBackend,
+	`cockroach`:     NewSqlBackend,
+	`cockroachdb`:   NewSqlBackend,
+	`consul`:        NewConsulBackend,
+	`couchdb`:       NewCouchDBBackend,
+	`duckdb`:        NewSqlBackend,
+	`dynamodb`:      NewDynamoBackend,
+	`elasticsearch`: NewElasticsearchBackend,
+	`etcd`:          NewEtcdBackend,
+	`file`:          NewFilesystemBackend,
+	`firestore`:     NewFirestoreBackend,
+	`fs`:            NewFilesystemBackend,
+	`memory`:        NewMemoryBackend,
+	`mock`:          NewMockBackendFromConnectionString,
+	`mongodb`:       NewMongoBackend,
+	`mssql`:         NewSqlBackend,
+	`mysql`:         NewSqlBackend,
+	`odbc`:          NewSqlBackend,
+	`postgres`:      NewSqlBackend,
+	`postgresql`:    NewSqlBackend,
+	`psql`:          NewSqlBackend,
+	`redis`:         NewRedisBackend,
+	`sqlite`:        NewSqlBackend,
+	`sqlserver`:     NewSqlBackend,
 }
 
 func RegisterBackend(name string, fn BackendFunc) {
@@ -0,0 +1,74 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/pivot/dal"
+)
+
+type BatchOperationType string
+
+const (
+	BatchInsert BatchOperationType = `insert`
+	BatchUpdate BatchOperationType = `update`
+	BatchDelete BatchOperationType = `delete`
+)
+
+// A single write operation to be performed as part of an atomic batch.
+type BatchOperation struct {
+	Type       BatchOperationType `json:"type"`
+	Collection string             `json:"collection"`
+	Records    []*dal.Record      `json:"records,omitempty"`
+	IDs        []interface{}      `json:"ids,omitempty"`
+}
+
+// Returns the number of records affected by this operation.
+func (self *BatchOperation) Count() int {
+	if self.Type == BatchDelete {
+		return len(self.IDs)
+	}
+
+	return len(self.Records)
+}
+
+func (self *BatchOperation) Validate() error {
+	if self.Collection == `` {
+		return fmt.Errorf("must specify a collection")
+	}
+
+	switch self.Type {
+	case BatchInsert, BatchUpdate:
+		if len(self.Records) == 0 {
+			return fmt.Errorf("%s operation must specify at least one record", self.Type)
+		}
+	case BatchDelete:
+		if len(self.IDs) == 0 {
+			return fmt.Errorf("delete operation must specify at least one ID")
+		}
+	default:
+		return fmt.Errorf("unknown operation type %q", self.Type)
+	}
+
+	return nil
+}
+
+// Returned when an operation in a batch fails, identifying the operation that caused the entire
+// batch to be rolled back.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (self *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", self.Index, self.Err)
+}
+
+func (self *BatchError) Unwrap() error {
+	return self.Err
+}
+
+// Implemented by backends that can execute a sequence of write operations atomically: either all
+// operations succeed, or none of them are applied.
+type Batcher interface {
+	Batch(operations []BatchOperation) error
+}
@@ -0,0 +1,55 @@
+package backends
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchOperationValidate(t *testing.T) {
+	assert := require.New(t)
+	records := []*dal.Record{dal.NewRecord(1)}
+
+	for _, op := range []BatchOperation{
+		{Type: BatchInsert, Collection: `users`, Records: records},
+		{Type: BatchUpdate, Collection: `users`, Records: records},
+		{Type: BatchDelete, Collection: `users`, IDs: []interface{}{1}},
+	} {
+		assert.NoError(op.Validate(), "%+v", op)
+	}
+
+	for _, op := range []BatchOperation{
+		{Type: BatchInsert, Records: records},
+		{Type: BatchInsert, Collection: `users`},
+		{Type: BatchUpdate, Collection: `users`, IDs: []interface{}{1}},
+		{Type: BatchDelete, Collection: `users`, Records: records},
+		{Type: `upsert`, Collection: `users`, Records: records},
+	} {
+		assert.Error(op.Validate(), "%+v", op)
+	}
+}
+
+func TestBatchOperationCount(t *testing.T) {
+	assert := require.New(t)
+
+	op := BatchOperation{Type: BatchInsert, Records: []*dal.Record{dal.NewRecord(1), dal.NewRecord(2)}, IDs: []interface{}{1}}
+	assert.Equal(2, op.Count())
+
+	op.Type = BatchDelete
+	assert.Equal(1, op.Count())
+}
+
+func TestBatchError(t *testing.T) {
+	assert := require.New(t)
+	cause := fmt.Errorf("duplicate key")
+
+	err := &BatchError{
+		Index: 2,
+		Err:   cause,
+	}
+
+	assert.Equal(`operation 2: duplicate key`, err.Error())
+	assert.Equal(cause, err.Unwrap())
+}
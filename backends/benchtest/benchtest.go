@@ -0,0 +1,224 @@
+// Package benchtest provides benchmarks that can be run against any backend, so that backend
+// implementations can be compared with one another and performance regressions caught.
+//
+// To benchmark a backend, call RunAll (or any of the individual benchmarks) from a Benchmark function:
+//
+//	func BenchmarkMyBackend(b *testing.B) {
+//		benchtest.RunAll(b, backend)
+//	}
+package benchtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// The number of records the retrieve, update, and query benchmarks are run against.
+var DatasetSize = 1000
+
+// The number of records inserted per call by BenchmarkBulkInsert.
+var BulkInsertSize = 100
+
+// The maximum number of records returned by each query in BenchmarkQuery.
+var QueryLimit = 25
+
+// Runs every benchmark in this package against the given backend as sub-benchmarks.
+func RunAll(b *testing.B, backend backends.Backend) {
+	b.Run(`Insert`, func(b *testing.B) {
+		BenchmarkInsert(b, backend)
+	})
+
+	b.Run(`BulkInsert`, func(b *testing.B) {
+		BenchmarkBulkInsert(b, backend)
+	})
+
+	b.Run(`Retrieve`, func(b *testing.B) {
+		BenchmarkRetrieve(b, backend)
+	})
+
+	b.Run(`Update`, func(b *testing.B) {
+		BenchmarkUpdate(b, backend)
+	})
+
+	b.Run(`Query`, func(b *testing.B) {
+		BenchmarkQuery(b, backend)
+	})
+
+	b.Run(`Delete`, func(b *testing.B) {
+		BenchmarkDelete(b, backend)
+	})
+}
+
+// Measures inserting one record at a time.
+func BenchmarkInsert(b *testing.B, backend backends.Backend) {
+	withCollection(b, backend, `BenchmarkInsert`, func(collection *dal.Collection) {
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if err := backend.Insert(collection.Name, dal.NewRecordSet(makeRecord(i+1))); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Measures inserting BulkInsertSize records at a time.
+func BenchmarkBulkInsert(b *testing.B, backend backends.Backend) {
+	withCollection(b, backend, `BenchmarkBulkInsert`, func(collection *dal.Collection) {
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			recordset := dal.NewRecordSet()
+
+			for j := 0; j < BulkInsertSize; j++ {
+				recordset.Push(makeRecord(i*BulkInsertSize + j + 1))
+			}
+
+			if err := backend.Insert(collection.Name, recordset); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Measures retrieving records by ID.
+func BenchmarkRetrieve(b *testing.B, backend backends.Backend) {
+	withDataset(b, backend, `BenchmarkRetrieve`, func(collection *dal.Collection) {
+		for i := 0; i < b.N; i++ {
+			if _, err := backend.Retrieve(collection.Name, (i%DatasetSize)+1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Measures updating a field of one record at a time.
+func BenchmarkUpdate(b *testing.B, backend backends.Backend) {
+	withDataset(b, backend, `BenchmarkUpdate`, func(collection *dal.Collection) {
+		for i := 0; i < b.N; i++ {
+			if err := backend.Update(collection.Name, dal.NewRecordSet(
+				dal.NewRecord((i%DatasetSize)+1).Set(`value`, i),
+			)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Measures range queries returning up to QueryLimit records.  This is skipped for backends that
+// don't support querying.
+func BenchmarkQuery(b *testing.B, backend backends.Backend) {
+	withDataset(b, backend, `BenchmarkQuery`, func(collection *dal.Collection) {
+		search := backend.WithSearch(collection)
+
+		if search == nil {
+			b.Skipf("backend %T does not support queries", backend)
+		}
+
+		for i := 0; i < b.N; i++ {
+			if f, err := filter.Parse(fmt.Sprintf("value/gte:%d", i%DatasetSize)); err == nil {
+				f.Limit = QueryLimit
+
+				if _, err := search.Query(collection, f); err != nil {
+					b.Fatal(err)
+				}
+			} else {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Measures deleting one record at a time.
+func BenchmarkDelete(b *testing.B, backend backends.Backend) {
+	withCollection(b, backend, `BenchmarkDelete`, func(collection *dal.Collection) {
+		recordset := dal.NewRecordSet()
+
+		for i := 0; i < b.N; i++ {
+			recordset.Push(makeRecord(i + 1))
+		}
+
+		if err := backend.Insert(collection.Name, recordset); err != nil {
+			b.Fatal(err)
+		}
+
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if err := backend.Delete(collection.Name, i+1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Returns the definition of the collection the benchmarks operate on.
+func NewCollection(name string) *dal.Collection {
+	collection := dal.NewCollection(name).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `value`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name: `created_at`,
+		Type: dal.TimeType,
+	})
+
+	collection.IdentityFieldType = dal.IntType
+
+	return collection
+}
+
+func makeRecord(id int) *dal.Record {
+	return dal.NewRecord(id).SetFields(map[string]interface{}{
+		`name`:       fmt.Sprintf("record-%d", id),
+		`value`:      id,
+		`created_at`: time.Now(),
+	})
+}
+
+// creates a collection for the duration of the given function, removing it afterwards
+func withCollection(b *testing.B, backend backends.Backend, name string, fn func(collection *dal.Collection)) {
+	b.Helper()
+	b.StopTimer()
+
+	collection := NewCollection(name)
+
+	if err := backend.CreateCollection(collection); err != nil {
+		b.Fatal(err)
+	}
+
+	defer backend.DeleteCollection(name)
+
+	b.StartTimer()
+	fn(collection)
+	b.StopTimer()
+}
+
+// creates a collection containing DatasetSize records for the duration of the given function, which
+// is timed from when the records have been inserted
+func withDataset(b *testing.B, backend backends.Backend, name string, fn func(collection *dal.Collection)) {
+	withCollection(b, backend, name, func(collection *dal.Collection) {
+		b.StopTimer()
+
+		recordset := dal.NewRecordSet()
+
+		for i := 0; i < DatasetSize; i++ {
+			recordset.Push(makeRecord(i + 1))
+		}
+
+		if err := backend.Insert(collection.Name, recordset); err != nil {
+			b.Fatal(err)
+		}
+
+		b.ResetTimer()
+		b.StartTimer()
+		fn(collection)
+	})
+}
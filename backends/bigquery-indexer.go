@@ -0,0 +1,201 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/ghetzel/pivot/filter/generators"
+	"google.golang.org/api/iterator"
+)
+
+func (self *BigqueryBackend) IndexConnectionString() *dal.ConnectionString {
+	return &dal.ConnectionString{}
+}
+
+func (self *BigqueryBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *BigqueryBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *BigqueryBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.Name, id)
+}
+
+func (self *BigqueryBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.Name, id)
+}
+
+func (self *BigqueryBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *BigqueryBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Translates the filter into a Standard SQL query and calls resultFn with each resulting row.
+func (self *BigqueryBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	defer timeOperation(`pivot.indexers.bigquery.query_time`)()
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	return self.query(collection, f, resultFn)
+}
+
+func (self *BigqueryBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *BigqueryBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	values := make(map[string][]interface{})
+
+	for _, field := range fields {
+		queryGen := self.makeQueryGen(collection)
+		queryGen.Distinct = true
+
+		fieldFilter := filter.Copy(f)
+		fieldFilter.Fields = []string{field}
+		fieldFilter.Sort = nil
+
+		if stmt, err := filter.Render(queryGen, collection.Name, &fieldFilter); err == nil {
+			ctx, cancel := self.context()
+			rows, err := self.makeQuery(string(stmt[:]), queryGen.GetValues()).Read(ctx)
+
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+
+			for {
+				var row []bigquery.Value
+
+				if err := rows.Next(&row); err == iterator.Done {
+					break
+				} else if err != nil {
+					cancel()
+					return nil, err
+				} else if len(row) > 0 {
+					values[field] = append(values[field], row[0])
+				}
+			}
+
+			cancel()
+		} else {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func (self *BigqueryBackend) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	return DefaultFacetsImplementation(self, collection, fields, f)
+}
+
+// Deletes the records matching the filter with a single DELETE statement.
+func (self *BigqueryBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	if f == nil || f.IsMatchAll() {
+		// BigQuery requires DELETE statements to have a WHERE clause
+		f = filter.MustParse(fmt.Sprintf("%s/not:null", collection.IdentityField))
+	}
+
+	queryGen := self.makeQueryGen(collection)
+	queryGen.Type = generators.SqlDeleteStatement
+
+	if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
+		return self.exec(string(stmt[:]), queryGen.GetValues())
+	} else {
+		return err
+	}
+}
+
+func (self *BigqueryBackend) FlushIndex() error {
+	return nil
+}
+
+// runs the SELECT statement for the filter and calls resultFn with a record made from each row
+func (self *BigqueryBackend) query(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	queryGen := self.makeQueryGen(collection)
+
+	if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
+		ctx, cancel := self.context()
+		defer cancel()
+
+		rows, err := self.makeQuery(string(stmt[:]), queryGen.GetValues()).Read(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for {
+			row := make(map[string]bigquery.Value)
+
+			if err := rows.Next(&row); err == iterator.Done {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			record, err := self.recordFromRow(collection, row)
+
+			if err := resultFn(record, err, IndexPage{
+				Page:         1,
+				TotalPages:   1,
+				Limit:        f.Limit,
+				Offset:       f.Offset,
+				TotalResults: int64(rows.TotalRows),
+			}); err != nil {
+				return queryStopped(err)
+			}
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *BigqueryBackend) recordFromRow(collection *dal.Collection, row map[string]bigquery.Value) (*dal.Record, error) {
+	record := dal.NewRecord(nil)
+
+	for k, v := range row {
+		if k == collection.IdentityField {
+			record.ID = v
+			continue
+		}
+
+		// objects are stored as JSON strings
+		if field, ok := collection.GetField(k); ok && field.Type == dal.ObjectType {
+			if data, ok := v.(string); ok {
+				var value interface{}
+
+				if err := json.Unmarshal([]byte(data), &value); err == nil {
+					v = value
+				} else {
+					return nil, fmt.Errorf("Invalid value of field %v: %v", k, err)
+				}
+			}
+		}
+
+		record.Set(k, v)
+	}
+
+	// do this AFTER populating the record's fields from the row
+	if err := record.Populate(record, collection); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
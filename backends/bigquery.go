@@ -0,0 +1,622 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/ghetzel/pivot/filter/generators"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// The dataset tables are created in when the connection string doesn't specify one.
+var BigqueryDefaultDataset = `pivot`
+
+// How long each request (including each query) made to BigQuery may take before it is abandoned.
+var BigqueryRequestTimeout = 5 * time.Minute
+
+// BigQuery has no notion of primary keys or object types, so columns are given these descriptions to
+// tell which one holds the ID, which ones hold objects (as JSON strings), and which kind of geometry
+// each geography holds.
+var BigqueryIdentityDescription = `pivot:identity`
+var BigqueryObjectDescription = `pivot:object`
+var BigqueryPointDescription = `pivot:point`
+var BigqueryPolygonDescription = `pivot:polygon`
+
+// A Backend that stores each collection as a table in a BigQuery dataset.  It is meant for data that
+// is mostly appended to and queried:
+//
+//   - Records are inserted with streaming inserts, and must be given an ID (which is also used to
+//     deduplicate retried inserts on a best-effort basis.)  Unlike other backends, inserting a record
+//     with an existing ID adds another row rather than failing.
+//   - Records are updated and deleted with DML statements, which count against BigQuery's quotas and
+//     cannot modify rows that are still in the streaming buffer (for up to 90 minutes after they were
+//     inserted.)
+//   - Filters are translated into Standard SQL queries.
+//
+// Connection strings name the Google Cloud project and dataset to use (e.g.:
+// "bigquery://my-project/my_dataset".)  Credentials are found the same way as other Google Cloud
+// clients do, or are read from the file given in the "credentials" option.  Datasets created by the
+// backend are created in the location given by the "location" option.
+type BigqueryBackend struct {
+	Backend
+	conn                  dal.ConnectionString
+	client                *bigquery.Client
+	dataset               string
+	indexer               Indexer
+	registeredCollections sync.Map
+	dmlWarning            sync.Once
+}
+
+func NewBigqueryBackend(connection dal.ConnectionString) Backend {
+	return &BigqueryBackend{
+		conn:    connection,
+		dataset: sliceutil.OrString(connection.Dataset(), BigqueryDefaultDataset),
+	}
+}
+
+func (self *BigqueryBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *BigqueryBackend) Initialize() error {
+	options := make([]option.ClientOption, 0)
+
+	if filename := self.conn.OptString(`credentials`, ``); filename != `` {
+		options = append(options, option.WithCredentialsFile(filename))
+	}
+
+	project := sliceutil.OrString(self.conn.Host(), bigquery.DetectProjectID)
+
+	if client, err := bigquery.NewClient(context.Background(), project, options...); err == nil {
+		client.Location = self.conn.OptString(`location`, ``)
+		self.client = client
+	} else {
+		return fmt.Errorf("Cannot connect to BigQuery: %v", err)
+	}
+
+	if err := self.Ping(DefaultConnectTimeout); err != nil {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	return self.indexer.IndexInitialize(self)
+}
+
+func (self *BigqueryBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *BigqueryBackend) RegisterCollection(collection *dal.Collection) {
+	if collection != nil {
+		self.registeredCollections.Store(collection.Name, collection)
+	}
+}
+
+func (self *BigqueryBackend) Ping(timeout time.Duration) error {
+	if self.client == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if _, err := self.client.Datasets(ctx).Next(); err != nil && err != iterator.Done {
+		return fmt.Errorf("Backend unavailable: %v", err)
+	}
+
+	return nil
+}
+
+func (self *BigqueryBackend) Exists(name string, id interface{}) bool {
+	if _, err := self.Retrieve(name, id); err == nil {
+		return true
+	}
+
+	return false
+}
+
+func (self *BigqueryBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		var found *dal.Record
+
+		f := filter.New()
+		f.Limit = 1
+		f.Fields = fields
+		f.AddCriteria(filter.Criterion{
+			Field:  collection.IdentityField,
+			Values: []interface{}{id},
+		})
+
+		if err := self.query(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+			found = record
+			return err
+		}); err != nil {
+			return nil, err
+		} else if found == nil {
+			return nil, dal.RecordNotFound(id)
+		}
+
+		return found, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Adds the records to the collection's table with a streaming insert.
+func (self *BigqueryBackend) Insert(name string, recordset *dal.RecordSet) error {
+	defer timeOperation(`pivot.backends.bigquery.insert_time`)()
+
+	if collection, err := self.GetCollection(name); err == nil {
+		rows := make([]*bigqueryRow, len(recordset.Records))
+
+		for i, record := range recordset.Records {
+			if r, err := collection.MakeRecord(record); err == nil {
+				if r.ID == nil {
+					return fmt.Errorf("Records inserted into %v must have an ID", collection.Name)
+				}
+
+				rows[i] = &bigqueryRow{
+					collection: collection,
+					record:     r,
+				}
+			} else {
+				return err
+			}
+		}
+
+		ctx, cancel := self.context()
+		defer cancel()
+
+		if err := self.client.Dataset(self.dataset).Table(collection.Name).Inserter().Put(ctx, rows); err != nil {
+			return err
+		}
+
+		return self.index(collection, recordset)
+	} else {
+		return err
+	}
+}
+
+// Updates the records with UPDATE statements.  Records without an ID are applied to all records
+// matching the target filter (if given.)
+func (self *BigqueryBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	defer timeOperation(`pivot.backends.bigquery.update_time`)()
+
+	var targetFilter *filter.Filter
+
+	if len(target) > 0 {
+		if f, err := filter.Parse(target[0]); err == nil {
+			targetFilter = f
+		} else {
+			return err
+		}
+	}
+
+	if collection, err := self.GetCollection(name); err == nil {
+		for _, record := range recordset.Records {
+			if r, err := collection.MakeRecord(record); err == nil {
+				record = r
+			} else {
+				return err
+			}
+
+			var recordUpdateFilter *filter.Filter
+
+			if record.ID == nil || record.ID == `` {
+				if targetFilter != nil {
+					recordUpdateFilter = targetFilter
+				} else {
+					return fmt.Errorf("Update must target at least one record")
+				}
+			} else {
+				recordUpdateFilter = filter.New().AddCriteria(filter.Criterion{
+					Field:  collection.IdentityField,
+					Values: []interface{}{record.ID},
+				})
+			}
+
+			queryGen := self.makeQueryGen(collection)
+			queryGen.Type = generators.SqlUpdateStatement
+
+			for k, v := range record.Fields {
+				if k != collection.IdentityField {
+					queryGen.InputData[k] = v
+				}
+			}
+
+			if stmt, err := filter.Render(queryGen, collection.Name, recordUpdateFilter); err == nil {
+				if err := self.exec(string(stmt[:]), queryGen.GetValues()); err != nil {
+					return err
+				}
+			} else {
+				return err
+			}
+		}
+
+		return self.index(collection, recordset)
+	} else {
+		return err
+	}
+}
+
+// Deletes the records with a DELETE statement.
+func (self *BigqueryBackend) Delete(name string, ids ...interface{}) error {
+	defer timeOperation(`pivot.backends.bigquery.delete_time`)()
+
+	if collection, err := self.GetCollection(name); err == nil {
+		f := filter.New().AddCriteria(filter.Criterion{
+			Field:  collection.IdentityField,
+			Values: ids,
+		})
+
+		queryGen := self.makeQueryGen(collection)
+		queryGen.Type = generators.SqlDeleteStatement
+
+		if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
+			if err := self.exec(string(stmt[:]), queryGen.GetValues()); err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+
+		if search := self.WithSearch(collection); search != nil {
+			return search.IndexRemove(collection, ids)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Creates a table for the collection, creating the dataset first if it doesn't exist.
+func (self *BigqueryBackend) CreateCollection(definition *dal.Collection) error {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	dataset := self.client.Dataset(self.dataset)
+
+	if _, err := dataset.Metadata(ctx); bigqueryNotFound(err) {
+		if err := dataset.Create(ctx, &bigquery.DatasetMetadata{
+			Location: self.client.Location,
+		}); err != nil && !bigqueryAlreadyExists(err) {
+			return fmt.Errorf("Failed to create dataset %v: %v", self.dataset, err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if schema, err := self.bigquerySchema(definition); err == nil {
+		if err := dataset.Table(definition.Name).Create(ctx, &bigquery.TableMetadata{
+			Schema: schema,
+		}); bigqueryAlreadyExists(err) {
+			return fmt.Errorf("Collection %v already exists", definition.Name)
+		} else if err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	self.RegisterCollection(definition)
+	return nil
+}
+
+func (self *BigqueryBackend) DeleteCollection(name string) error {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	if err := self.client.Dataset(self.dataset).Table(name).Delete(ctx); bigqueryNotFound(err) {
+		return dal.CollectionNotFound
+	} else if err != nil {
+		return err
+	}
+
+	self.registeredCollections.Delete(name)
+	return nil
+}
+
+func (self *BigqueryBackend) ListCollections() ([]string, error) {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	names := make([]string, 0)
+	tables := self.client.Dataset(self.dataset).Tables(ctx)
+
+	for {
+		if table, err := tables.Next(); err == nil {
+			names = append(names, table.TableID)
+		} else if err == iterator.Done {
+			break
+		} else if bigqueryNotFound(err) {
+			// the dataset is only created along with the first collection
+			return names, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Reads the collection definition from the schema of its table.  Tables not created by this backend
+// use the column named "id" as their identity, if there is one.
+func (self *BigqueryBackend) GetCollection(name string) (*dal.Collection, error) {
+	if c, ok := self.registeredCollections.Load(name); ok {
+		return c.(*dal.Collection), nil
+	}
+
+	ctx, cancel := self.context()
+	defer cancel()
+
+	if metadata, err := self.client.Dataset(self.dataset).Table(name).Metadata(ctx); err == nil {
+		collection := dal.NewCollection(name)
+		identity := dal.DefaultIdentityField
+
+		for _, column := range metadata.Schema {
+			if column.Description == BigqueryIdentityDescription {
+				identity = column.Name
+				break
+			}
+		}
+
+		for _, column := range metadata.Schema {
+			field := dal.Field{
+				Name:       column.Name,
+				NativeType: string(column.Type),
+				Required:   column.Required,
+				Type:       bigqueryFieldType(column),
+			}
+
+			if column.Name == identity {
+				field.Identity = true
+				collection.IdentityField = column.Name
+				collection.IdentityFieldType = field.Type
+			}
+
+			collection.Fields = append(collection.Fields, field)
+		}
+
+		self.RegisterCollection(collection)
+		return collection, nil
+	} else if bigqueryNotFound(err) {
+		return nil, dal.CollectionNotFound
+	} else {
+		return nil, err
+	}
+}
+
+func (self *BigqueryBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *BigqueryBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return nil
+}
+
+func (self *BigqueryBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+func (self *BigqueryBackend) index(collection *dal.Collection, recordset *dal.RecordSet) error {
+	if !collection.SkipIndexPersistence {
+		if search := self.WithSearch(collection); search != nil {
+			return search.Index(collection, recordset)
+		}
+	}
+
+	return nil
+}
+
+func (self *BigqueryBackend) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), BigqueryRequestTimeout)
+}
+
+func (self *BigqueryBackend) makeQueryGen(collection *dal.Collection) *generators.Sql {
+	queryGen := generators.NewSqlGenerator()
+	queryGen.TypeMapping = generators.BigqueryTypeMapping
+	queryGen.TableNameFormat = "`" + self.dataset + ".%s`"
+	queryGen.FieldNameFormat = "`%s`"
+	queryGen.NestedFieldNameFormat = "JSON_EXTRACT_SCALAR(`%s`, '$.%s')"
+	queryGen.NestedFieldIndexFormat = `[%s]`
+
+	if collection != nil {
+		queryGen.NamingConvention = collection.GetNamingConvention()
+	}
+
+	return queryGen
+}
+
+// returns a query for the given statement, with the values as its positional parameters
+func (self *BigqueryBackend) makeQuery(stmt string, values []interface{}) *bigquery.Query {
+	querylog.Debugf("[%T] %s %v", self, stmt, values)
+
+	query := self.client.Query(stmt)
+
+	for _, value := range values {
+		query.Parameters = append(query.Parameters, bigquery.QueryParameter{
+			Value: value,
+		})
+	}
+
+	return query
+}
+
+// runs a DML statement, waiting for it to complete
+func (self *BigqueryBackend) exec(stmt string, values []interface{}) error {
+	self.dmlWarning.Do(func() {
+		log.Warningf("[%T] updates and deletes use DML statements, which count against BigQuery's quotas", self)
+	})
+
+	ctx, cancel := self.context()
+	defer cancel()
+
+	if job, err := self.makeQuery(stmt, values).Run(ctx); err == nil {
+		if status, err := job.Wait(ctx); err == nil {
+			return status.Err()
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// builds the table schema for the collection, with the identity column first
+func (self *BigqueryBackend) bigquerySchema(definition *dal.Collection) (bigquery.Schema, error) {
+	schema := bigquery.Schema{
+		{
+			Name:        definition.IdentityField,
+			Type:        bigquery.IntegerFieldType,
+			Required:    true,
+			Description: BigqueryIdentityDescription,
+		},
+	}
+
+	if definition.IdentityFieldType == dal.StringType {
+		schema[0].Type = bigquery.StringFieldType
+	}
+
+	for _, field := range definition.Fields {
+		if field.Name == definition.IdentityField {
+			continue
+		}
+
+		column := &bigquery.FieldSchema{
+			Name:     field.Name,
+			Required: field.Required,
+		}
+
+		switch field.Type {
+		case dal.StringType:
+			column.Type = bigquery.StringFieldType
+		case dal.IntType:
+			column.Type = bigquery.IntegerFieldType
+		case dal.FloatType:
+			column.Type = bigquery.FloatFieldType
+		case dal.BooleanType:
+			column.Type = bigquery.BooleanFieldType
+		case dal.TimeType:
+			column.Type = bigquery.TimestampFieldType
+		case dal.ObjectType:
+			column.Type = bigquery.StringFieldType
+			column.Description = BigqueryObjectDescription
+		case dal.RawType:
+			column.Type = bigquery.BytesFieldType
+		case dal.PointType:
+			column.Type = bigquery.GeographyFieldType
+			column.Description = BigqueryPointDescription
+		case dal.PolygonType:
+			column.Type = bigquery.GeographyFieldType
+			column.Description = BigqueryPolygonDescription
+		default:
+			return nil, dal.Unsupported("%T cannot store %v fields in BigQuery", self, field.Type)
+		}
+
+		schema = append(schema, column)
+	}
+
+	return schema, nil
+}
+
+// maps column types to DAL types
+func bigqueryFieldType(column *bigquery.FieldSchema) dal.Type {
+	if column.Repeated {
+		return dal.ObjectType
+	}
+
+	switch column.Type {
+	case bigquery.StringFieldType:
+		if column.Description == BigqueryObjectDescription {
+			return dal.ObjectType
+		}
+
+		return dal.StringType
+	case bigquery.IntegerFieldType:
+		return dal.IntType
+	case bigquery.FloatFieldType, bigquery.NumericFieldType:
+		return dal.FloatType
+	case bigquery.BooleanFieldType:
+		return dal.BooleanType
+	case bigquery.TimestampFieldType, bigquery.DateTimeFieldType, bigquery.DateFieldType, bigquery.TimeFieldType:
+		return dal.TimeType
+	case bigquery.RecordFieldType:
+		return dal.ObjectType
+	case bigquery.GeographyFieldType:
+		if column.Description == BigqueryPolygonDescription {
+			return dal.PolygonType
+		}
+
+		return dal.PointType
+	default:
+		return dal.RawType
+	}
+}
+
+func bigqueryNotFound(err error) bool {
+	if e, ok := err.(*googleapi.Error); ok {
+		return e.Code == http.StatusNotFound
+	}
+
+	return false
+}
+
+func bigqueryAlreadyExists(err error) bool {
+	if e, ok := err.(*googleapi.Error); ok {
+		return e.Code == http.StatusConflict
+	}
+
+	return false
+}
+
+// A record being streamed into a table.  Objects are stored as JSON strings and geometries as
+// Well-Known Text, and the record's ID is used as the insert ID.
+type bigqueryRow struct {
+	collection *dal.Collection
+	record     *dal.Record
+}
+
+func (self *bigqueryRow) Save() (map[string]bigquery.Value, string, error) {
+	row := make(map[string]bigquery.Value)
+
+	for k, v := range convertGeometryFields(self.collection, self.record.Fields, toWKT) {
+		if field, ok := self.collection.GetField(k); ok && field.Type == dal.ObjectType && v != nil {
+			if data, err := json.Marshal(v); err == nil {
+				v = string(data)
+			} else {
+				return nil, ``, err
+			}
+		}
+
+		row[k] = v
+	}
+
+	row[self.collection.IdentityField] = self.record.ID
+
+	return row, fmt.Sprintf("%v", self.record.ID), nil
+}
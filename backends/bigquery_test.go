@@ -0,0 +1,137 @@
+package backends
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/ghetzel/pivot/filter/generators"
+	"github.com/stretchr/testify/require"
+)
+
+func newBigqueryUsers() *dal.Collection {
+	return dal.NewCollection(`users`).AddFields(dal.Field{
+		Name:     `name`,
+		Type:     dal.StringType,
+		Required: true,
+	}, dal.Field{
+		Name: `tags`,
+		Type: dal.ObjectType,
+	}, dal.Field{
+		Name: `location`,
+		Type: dal.PointType,
+	})
+}
+
+func TestBigquerySchema(t *testing.T) {
+	assert := require.New(t)
+
+	backend := &BigqueryBackend{}
+
+	schema, err := backend.bigquerySchema(newBigqueryUsers())
+	assert.NoError(err)
+	assert.Equal(bigquery.Schema{
+		{Name: `id`, Type: bigquery.IntegerFieldType, Required: true, Description: BigqueryIdentityDescription},
+		{Name: `name`, Type: bigquery.StringFieldType, Required: true},
+		{Name: `tags`, Type: bigquery.StringFieldType, Description: BigqueryObjectDescription},
+		{Name: `location`, Type: bigquery.GeographyFieldType, Description: BigqueryPointDescription},
+	}, schema)
+
+	// each column maps back to the type it was created for
+	for _, column := range schema {
+		if column.Name != `id` {
+			field, _ := newBigqueryUsers().GetField(column.Name)
+			assert.Equal(field.Type, bigqueryFieldType(column), column.Name)
+		}
+	}
+
+	assert.Equal(dal.ObjectType, bigqueryFieldType(&bigquery.FieldSchema{Type: bigquery.IntegerFieldType, Repeated: true}))
+	assert.Equal(dal.ObjectType, bigqueryFieldType(&bigquery.FieldSchema{Type: bigquery.RecordFieldType}))
+	assert.Equal(dal.FloatType, bigqueryFieldType(&bigquery.FieldSchema{Type: bigquery.NumericFieldType}))
+	assert.Equal(dal.TimeType, bigqueryFieldType(&bigquery.FieldSchema{Type: bigquery.DateFieldType}))
+	assert.Equal(dal.PolygonType, bigqueryFieldType(&bigquery.FieldSchema{Type: bigquery.GeographyFieldType, Description: BigqueryPolygonDescription}))
+	assert.Equal(dal.RawType, bigqueryFieldType(&bigquery.FieldSchema{Type: bigquery.BytesFieldType}))
+
+	// string identities are stored in string columns
+	collection := dal.NewCollection(`sessions`)
+	collection.IdentityFieldType = dal.StringType
+
+	schema, err = backend.bigquerySchema(collection)
+	assert.NoError(err)
+	assert.Equal(bigquery.StringFieldType, schema[0].Type)
+}
+
+func TestBigqueryStatements(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`bigquery://test/analytics`)
+	assert.NoError(err)
+
+	backend := NewBigqueryBackend(cs).(*BigqueryBackend)
+	collection := newBigqueryUsers()
+
+	// tables are qualified with the dataset, and fields within objects are read from their JSON
+	gen := backend.makeQueryGen(collection)
+	stmt, err := filter.Render(gen, `users`, filter.MustParse(`tags.colors.0/red/name/alice`))
+	assert.NoError(err)
+	assert.Equal(
+		"SELECT * FROM `analytics.users` "+
+			"WHERE (JSON_EXTRACT_SCALAR(`tags`, '$.colors[0]') = ?) "+
+			"AND (`name` = ?)",
+		string(stmt[:]),
+	)
+
+	// geometries are bound as Well-Known Text
+	gen = backend.makeQueryGen(collection)
+	gen.Type = generators.SqlUpdateStatement
+	gen.InputData[`location`] = dal.Point{Latitude: 1, Longitude: 2}
+	gen.InputData[`name`] = `alice`
+
+	stmt, err = filter.Render(gen, `users`, filter.MustParse(`id/1`))
+	assert.NoError(err)
+	assert.Equal(
+		"UPDATE `analytics.users` SET `location` = ST_GEOGFROMTEXT(?), `name` = ? WHERE (`id` = ?)",
+		string(stmt[:]),
+	)
+	assert.Equal([]interface{}{`POINT(2 1)`, `alice`}, gen.GetValues()[:2])
+}
+
+func TestBigqueryRows(t *testing.T) {
+	assert := require.New(t)
+
+	backend := &BigqueryBackend{}
+	collection := newBigqueryUsers()
+
+	// objects are stored as JSON strings, and read back from them
+	row, insertId, err := (&bigqueryRow{
+		collection: collection,
+		record:     dal.NewRecord(`u1`).Set(`name`, `alice`).Set(`tags`, map[string]interface{}{`a`: 1}),
+	}).Save()
+
+	assert.NoError(err)
+	assert.Equal(`u1`, insertId)
+	assert.Equal(map[string]bigquery.Value{
+		`id`:   `u1`,
+		`name`: `alice`,
+		`tags`: `{"a":1}`,
+	}, row)
+
+	record, err := backend.recordFromRow(collection, map[string]bigquery.Value{
+		`id`:   int64(1),
+		`name`: `alice`,
+		`tags`: `{"a":1}`,
+	})
+
+	assert.NoError(err)
+	assert.EqualValues(1, record.ID)
+	assert.Equal(`alice`, record.Get(`name`))
+	assert.Equal(map[string]interface{}{`a`: float64(1)}, record.Get(`tags`))
+
+	_, err = backend.recordFromRow(collection, map[string]bigquery.Value{
+		`id`:   int64(1),
+		`tags`: `{not json`,
+	})
+
+	assert.Error(err)
+}
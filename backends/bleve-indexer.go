@@ -3,14 +3,24 @@ package backends
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
 	"github.com/blevesearch/bleve/analysis/char/regexp"
+	_ "github.com/blevesearch/bleve/analysis/lang/cjk"
+	_ "github.com/blevesearch/bleve/analysis/lang/de"
+	_ "github.com/blevesearch/bleve/analysis/lang/en"
+	_ "github.com/blevesearch/bleve/analysis/lang/es"
+	_ "github.com/blevesearch/bleve/analysis/lang/fr"
+	_ "github.com/blevesearch/bleve/analysis/lang/it"
+	_ "github.com/blevesearch/bleve/analysis/lang/pt"
 	"github.com/blevesearch/bleve/analysis/token/lowercase"
 	"github.com/blevesearch/bleve/analysis/tokenizer/single"
 	"github.com/blevesearch/bleve/mapping"
@@ -22,27 +32,99 @@ import (
 	"github.com/orcaman/concurrent-map"
 )
 
+// The number of pending documents that will cause a collection's batch to be written to the index.
+// This can be overridden per connection with the "batchSize" option.
 var BleveBatchFlushCount = 1
+
+// How long documents may remain pending before their batch is written to the index, regardless of
+// its size.  This can be overridden per connection with the "flushInterval" option.
 var BleveBatchFlushInterval = 10 * time.Second
+
+// The largest number of documents from a single call to Index that will be written to the index at
+// once (unless the batch size is larger.)
+var BleveMaxBatchSize = 1000
 var BleveIdentityField = `_id`
 
+// The number of shards each collection's index is split into, unless the collection specifies its
+// own.  This can be overridden per connection with the "shards" option.
+var BleveShardCount = 1
+
 type bleveDeferredBatch struct {
+	index     bleve.Index
 	batch     *bleve.Batch
 	lastFlush time.Time
+	batchLock sync.Mutex
+}
+
+// Writes the pending documents to the index if there are enough of them, if they have been pending
+// for too long, or if force is true.  The caller must hold batchLock.
+func (self *bleveDeferredBatch) flush(batchSize int, interval time.Duration, force bool) error {
+	if size := self.batch.Size(); size > 0 {
+		if force || size >= batchSize || time.Since(self.lastFlush) >= interval {
+			defer timeOperation(`pivot.indexers.bleve.deferred_batch_flush`)()
+
+			if err := self.index.Batch(self.batch); err != nil {
+				return err
+			}
+
+			self.batch.Reset()
+			self.lastFlush = time.Now()
+		}
+	} else {
+		// nothing is pending, so the interval starts over from the next document added
+		self.lastFlush = time.Now()
+	}
+
+	return nil
+}
+
+// The index for a single collection, which may be split across several shards.  Searches are
+// performed against all shards at once, while each document is written to the shard selected by
+// hashing its ID.
+type bleveIndex struct {
+	bleve.Index
+	name   string
+	shards []bleve.Index
+}
+
+// All shards share the same mapping, but an alias spanning several indexes won't return it.
+func (self *bleveIndex) Mapping() mapping.IndexMapping {
+	return self.shards[0].Mapping()
+}
+
+func (self *bleveIndex) shardFor(id string) int {
+	if len(self.shards) == 1 {
+		return 0
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+
+	return int(hash.Sum32() % uint32(len(self.shards)))
+}
+
+// Returns the name used to track pending documents for the given shard.
+func (self *bleveIndex) batchName(shard int) string {
+	if len(self.shards) == 1 {
+		return self.name
+	}
+
+	return fmt.Sprintf("%s.%d", self.name, shard)
 }
 
 type BleveIndexer struct {
 	Indexer
 	conn               *dal.ConnectionString
 	parent             Backend
-	indexCache         map[string]bleve.Index
+	indexCache         map[string]*bleveIndex
+	indexLock          sync.Mutex
 	indexDeferredBatch cmap.ConcurrentMap
 }
 
 func NewBleveIndexer(connection dal.ConnectionString) *BleveIndexer {
 	return &BleveIndexer{
 		conn:               &connection,
-		indexCache:         make(map[string]bleve.Index),
+		indexCache:         make(map[string]*bleveIndex),
 		indexDeferredBatch: cmap.New(),
 	}
 }
@@ -54,6 +136,16 @@ func (self *BleveIndexer) IndexConnectionString() *dal.ConnectionString {
 func (self *BleveIndexer) IndexInitialize(parent Backend) error {
 	self.parent = parent
 
+	// when documents are allowed to accumulate, make sure they are written out periodically even if
+	// no further documents arrive
+	if interval := self.flushInterval(); self.batchSize() > 1 && interval > 0 {
+		go func() {
+			for range time.Tick(interval) {
+				self.checkAndFlushBatches(false)
+			}
+		}()
+	}
+
 	return nil
 }
 
@@ -62,7 +154,7 @@ func (self *BleveIndexer) GetBackend() Backend {
 }
 
 func (self *BleveIndexer) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
-	defer stats.NewTiming().Send(`pivot.indexers.bleve.retrieve_time`)
+	defer timeOperation(`pivot.indexers.bleve.retrieve_time`)()
 
 	if index, err := self.getIndexForCollection(collection); err == nil {
 
@@ -91,87 +183,165 @@ func (self *BleveIndexer) IndexExists(collection *dal.Collection, id interface{}
 }
 
 func (self *BleveIndexer) Index(collection *dal.Collection, records *dal.RecordSet) error {
-	defer stats.NewTiming().Send(`pivot.indexers.bleve.index_time`)
+	defer timeOperation(`pivot.indexers.bleve.index_time`)()
 
 	if index, err := self.getIndexForCollection(collection); err == nil {
-		name := collection.GetIndexName()
-
-		var batch *bleve.Batch
-
-		d, ok := self.indexDeferredBatch.Get(name)
+		byShard := make([][]*dal.Record, len(index.shards))
 
-		if ok {
-			batch = d.(*bleveDeferredBatch).batch
-		} else {
-			batch = index.NewBatch()
-			self.indexDeferredBatch.Set(name, &bleveDeferredBatch{
-				batch:     batch,
-				lastFlush: time.Now(),
-			})
+		for _, record := range records.Records {
+			shard := index.shardFor(fmt.Sprintf("%v", record.ID))
+			byShard[shard] = append(byShard[shard], record)
 		}
 
-		for _, record := range records.Records {
-			querylog.Debugf("[%T] Adding %v to batch", self, record)
+		for shard, shardRecords := range byShard {
+			if len(shardRecords) > 0 {
+				deferred := self.getDeferredBatch(index.batchName(shard), index.shards[shard])
 
-			if err := batch.Index(fmt.Sprintf("%v", record.ID), record.Fields); err != nil {
-				return err
+				if err := self.indexBatch(deferred, collection, index.batchName(shard), shardRecords); err != nil {
+					return err
+				}
 			}
 		}
 
-		self.checkAndFlushBatches(false)
 		return nil
 	} else {
 		return err
 	}
 }
 
-func (self *BleveIndexer) checkAndFlushBatches(forceFlush bool) {
-	for item := range self.indexDeferredBatch.Iter() {
-		name := item.Key
-		deferred := item.Val.(*bleveDeferredBatch)
+func (self *BleveIndexer) indexBatch(deferred *bleveDeferredBatch, collection *dal.Collection, name string, records []*dal.Record) error {
+	batchSize := self.batchSize()
+	interval := self.flushInterval()
+	chunkSize := BleveMaxBatchSize
 
-		if deferred.batch != nil {
-			shouldFlush := false
+	if batchSize > chunkSize {
+		chunkSize = batchSize
+	}
 
-			if deferred.batch.Size() >= BleveBatchFlushCount {
-				shouldFlush = true
-			}
+	deferred.batchLock.Lock()
+	defer deferred.batchLock.Unlock()
 
-			if time.Since(deferred.lastFlush) >= BleveBatchFlushInterval {
-				shouldFlush = true
-			}
+	if deferred.batch.Size() == 0 {
+		deferred.lastFlush = time.Now()
+	}
 
-			if forceFlush {
-				shouldFlush = true
-			}
+	for _, record := range records {
+		querylog.Debugf("[%T] Adding %v to batch", self, record)
 
-			if shouldFlush {
-				defer stats.NewTiming().Send(`pivot.indexers.bleve.deferred_batch_flush`)
+		if err := deferred.batch.Index(fmt.Sprintf("%v", record.ID), self.documentFor(collection, record)); err != nil {
+			return err
+		}
 
-				if index, err := self.getIndexForCollection(dal.NewCollection(name)); err == nil {
+		// write out full batches as we go so that large recordsets aren't held in memory
+		if deferred.batch.Size() >= chunkSize {
+			querylog.Debugf("[%T] Indexing %d records to %s", self, deferred.batch.Size(), name)
 
-					querylog.Debugf("[%T] Indexing %d records to %s", self, deferred.batch.Size(), name)
+			if err := deferred.flush(batchSize, interval, true); err != nil {
+				return err
+			}
+		}
+	}
 
-					if err := index.Batch(deferred.batch); err == nil {
-						deferred.batch = index.NewBatch()
-						deferred.lastFlush = time.Now()
+	return deferred.flush(batchSize, interval, false)
+}
 
-						defer func() {
-							for _, key := range self.indexDeferredBatch.Keys() {
-								self.indexDeferredBatch.Remove(key)
-							}
-						}()
-					} else {
-						log.Errorf("[%T] error indexing %d records to %s: %v", self, deferred.batch.Size(), name, err)
+// Returns the fields of the given record as they should be indexed.  Values of fields the collection
+// declares as numbers, times, booleans or points are converted to those types so that they are indexed
+// according to the field's mapping (e.g.: so that times stored as integers are range-queryable.)
+func (self *BleveIndexer) documentFor(collection *dal.Collection, record *dal.Record) map[string]interface{} {
+	doc := make(map[string]interface{}, len(record.Fields))
+
+	for name, value := range record.Fields {
+		if field, ok := collection.GetField(name); ok && value != nil {
+			switch field.Type {
+			case dal.IntType, dal.FloatType, dal.TimeType, dal.BooleanType:
+				if v, err := field.ConvertValue(value); err == nil {
+					value = v
+				}
+			case dal.PointType:
+				// geo points are read from maps with "lat" and "lon" keys
+				if v, err := field.ConvertValue(value); err == nil {
+					if point, ok := v.(dal.Point); ok {
+						value = map[string]interface{}{
+							`lat`: point.Latitude,
+							`lon`: point.Longitude,
+						}
 					}
 				}
 			}
 		}
+
+		doc[name] = value
+	}
+
+	return doc
+}
+
+func (self *BleveIndexer) getDeferredBatch(name string, index bleve.Index) *bleveDeferredBatch {
+	self.indexDeferredBatch.SetIfAbsent(name, &bleveDeferredBatch{
+		index:     index,
+		batch:     index.NewBatch(),
+		lastFlush: time.Now(),
+	})
+
+	d, _ := self.indexDeferredBatch.Get(name)
+	return d.(*bleveDeferredBatch)
+}
+
+func (self *BleveIndexer) batchSize() int {
+	if v := int(self.conn.OptInt(`batchSize`, int64(BleveBatchFlushCount))); v > 0 {
+		return v
+	}
+
+	return 1
+}
+
+func (self *BleveIndexer) flushInterval() time.Duration {
+	if v, err := time.ParseDuration(self.conn.OptString(`flushInterval`, ``)); err == nil {
+		return v
+	}
+
+	return BleveBatchFlushInterval
+}
+
+func (self *BleveIndexer) checkAndFlushBatches(forceFlush bool) {
+	batchSize := self.batchSize()
+	interval := self.flushInterval()
+
+	for item := range self.indexDeferredBatch.IterBuffered() {
+		deferred := item.Val.(*bleveDeferredBatch)
+
+		deferred.batchLock.Lock()
+		size := deferred.batch.Size()
+
+		if err := deferred.flush(batchSize, interval, forceFlush); err == nil {
+			if size > 0 && deferred.batch.Size() == 0 {
+				querylog.Debugf("[%T] Indexed %d records to %s", self, size, item.Key)
+			}
+		} else {
+			log.Errorf("[%T] error indexing %d records to %s: %v", self, size, item.Key, err)
+		}
+
+		deferred.batchLock.Unlock()
 	}
 }
 
+// Writes any pending documents for the named index so that subsequent changes apply after them.
+func (self *BleveIndexer) flushBatch(name string) error {
+	if d, ok := self.indexDeferredBatch.Get(name); ok {
+		deferred := d.(*bleveDeferredBatch)
+
+		deferred.batchLock.Lock()
+		defer deferred.batchLock.Unlock()
+
+		return deferred.flush(self.batchSize(), self.flushInterval(), true)
+	}
+
+	return nil
+}
+
 func (self *BleveIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
-	defer stats.NewTiming().Send(`pivot.indexers.bleve.query_time`)
+	defer timeOperation(`pivot.indexers.bleve.query_time`)()
 
 	if f.IdentityField == `` {
 		f.IdentityField = BleveIdentityField
@@ -220,14 +390,17 @@ func (self *BleveIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter
 
 					// call the resultFn for each hit on this page
 					for _, hit := range results.Hits {
-						if err := resultFn(dal.NewRecord(hit.ID).SetFields(hit.Fields), nil, IndexPage{
+						record := dal.NewRecord(hit.ID).SetFields(hit.Fields)
+						record.Score = hit.Score
+
+						if err := resultFn(record, nil, IndexPage{
 							Page:         page,
 							TotalPages:   totalPages,
 							Limit:        f.Limit,
 							Offset:       offset,
 							TotalResults: int64(results.Total),
 						}); err != nil {
-							return err
+							return queryStopped(err)
 						}
 
 						processed += 1
@@ -270,14 +443,42 @@ func (self *BleveIndexer) Query(collection *dal.Collection, f *filter.Filter, re
 
 func (self *BleveIndexer) IndexRemove(collection *dal.Collection, ids []interface{}) error {
 	if index, err := self.getIndexForCollection(collection); err == nil {
+		batches := make([]*bleve.Batch, len(index.shards))
 
-		batch := index.NewBatch()
+		for shard, shardIndex := range index.shards {
+			// pending documents must be written first, otherwise they would reappear once their batch
+			// is flushed
+			if err := self.flushBatch(index.batchName(shard)); err != nil {
+				return err
+			}
+
+			batches[shard] = shardIndex.NewBatch()
+		}
 
 		for _, id := range ids {
-			batch.Delete(fmt.Sprintf("%v", id))
+			docID := fmt.Sprintf("%v", id)
+			shard := index.shardFor(docID)
+			batches[shard].Delete(docID)
+
+			// write out full batches as we go so that mass deletions aren't held in memory
+			if batches[shard].Size() >= IndexRemoveChunkSize {
+				if err := index.shards[shard].Batch(batches[shard]); err != nil {
+					return err
+				}
+
+				batches[shard].Reset()
+			}
+		}
+
+		for shard, batch := range batches {
+			if batch.Size() > 0 {
+				if err := index.shards[shard].Batch(batch); err != nil {
+					return err
+				}
+			}
 		}
 
-		return index.Batch(batch)
+		return nil
 	} else {
 		return err
 	}
@@ -344,15 +545,86 @@ func (self *BleveIndexer) ListValues(collection *dal.Collection, fields []string
 	}
 }
 
+// Counts the distinct values of the given fields using bleve's term facets.  Facets are computed from
+// indexed terms, so identity, numeric and time fields (whose terms aren't their values) are counted
+// from the matching records instead.
+func (self *BleveIndexer) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	if index, err := self.getIndexForCollection(collection); err == nil {
+		if bq, err := self.filterToBleveQuery(index, f); err == nil {
+			request := bleve.NewSearchRequestOptions(bq, 0, 0, false)
+			output := make(map[string][]FacetBucket)
+			counted := make([]string, 0)
+
+			for _, field := range fields {
+				if field == `id` || field == BleveIdentityField || collection.IsIdentityField(field) {
+					counted = append(counted, field)
+					continue
+				}
+
+				if def, ok := collection.GetField(field); ok {
+					switch def.Type {
+					case dal.IntType, dal.FloatType, dal.TimeType:
+						counted = append(counted, field)
+						continue
+					}
+				}
+
+				request.AddFacet(field, bleve.NewFacetRequest(field, MaxFacetCardinality))
+			}
+
+			if len(request.Facets) > 0 {
+				if results, err := index.Search(request); err == nil {
+					for name, facet := range results.Facets {
+						buckets := make([]FacetBucket, 0, len(facet.Terms))
+
+						for _, term := range facet.Terms {
+							buckets = append(buckets, FacetBucket{
+								Value: term.Term,
+								Count: int64(term.Count),
+							})
+						}
+
+						output[name] = sortFacetBuckets(buckets)
+					}
+				} else {
+					return nil, err
+				}
+			}
+
+			if len(counted) > 0 {
+				if facets, err := DefaultFacetsImplementation(self, collection, counted, f); err == nil {
+					for name, buckets := range facets {
+						output[name] = buckets
+					}
+				} else {
+					return nil, err
+				}
+			}
+
+			return output, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
 func (self *BleveIndexer) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
 	f.Fields = []string{BleveIdentityField}
 	var ids []interface{}
 
+	// all matching IDs are gathered before anything is deleted, since deleting while paging through
+	// the results would shift the pages out from under us
 	if err := self.QueryFunc(collection, f, func(indexRecord *dal.Record, err error, page IndexPage) error {
 		ids = append(ids, indexRecord.ID)
 		return nil
 	}); err == nil {
-		return self.parent.Delete(collection.Name, ids)
+		return deleteInChunks(self.parent, collection.Name, ids)
 	} else {
 		return err
 	}
@@ -363,46 +635,84 @@ func (self *BleveIndexer) FlushIndex() error {
 	return nil
 }
 
-func (self *BleveIndexer) getIndexForCollection(collection *dal.Collection) (bleve.Index, error) {
-	defer stats.NewTiming().Send(`pivot.indexers.bleve.retrieve_index`)
+func (self *BleveIndexer) getIndexForCollection(collection *dal.Collection) (*bleveIndex, error) {
+	defer timeOperation(`pivot.indexers.bleve.retrieve_index`)()
 	name := collection.GetIndexName()
 
+	self.indexLock.Lock()
+	defer self.indexLock.Unlock()
+
 	if v, ok := self.indexCache[name]; ok {
 		return v, nil
 	} else {
-		var index bleve.Index
-		mapping := bleve.NewIndexMapping()
+		index := &bleveIndex{
+			name:   name,
+			shards: make([]bleve.Index, self.shardCount(collection)),
+		}
 
-		// setup the mapping and text analysis settings for this index
-		self.useFilterMapping(mapping)
+		for i := range index.shards {
+			shardName := name
 
-		switch self.conn.Dataset() {
-		case `memory`:
-			if ix, err := bleve.NewMemOnly(mapping); err == nil {
-				index = ix
-			} else {
-				return nil, err
+			if len(index.shards) > 1 {
+				shardName = index.batchName(i)
 			}
-		default:
-			indexBaseDir := self.conn.Dataset()
-			indexPath := path.Join(indexBaseDir, name)
 
-			if ix, err := bleve.Open(indexPath); err == nil {
-				index = ix
-			} else if ix, err := bleve.New(indexPath, mapping); err == nil {
-				index = ix
+			if shard, err := self.openIndex(shardName, collection); err == nil {
+				index.shards[i] = shard
 			} else {
 				return nil, err
 			}
 		}
 
+		if len(index.shards) == 1 {
+			index.Index = index.shards[0]
+		} else {
+			index.Index = bleve.NewIndexAlias(index.shards...)
+		}
+
 		self.indexCache[name] = index
 		return index, nil
 	}
 }
 
+func (self *BleveIndexer) shardCount(collection *dal.Collection) int {
+	if collection.IndexShards > 0 {
+		return collection.IndexShards
+	} else if v := int(self.conn.OptInt(`shards`, int64(BleveShardCount))); v > 0 {
+		return v
+	}
+
+	return 1
+}
+
+// Opens (or creates) the named index in the dataset directory.  New indexes are mapped according to
+// the given collection's fields.
+func (self *BleveIndexer) openIndex(name string, collection *dal.Collection) (bleve.Index, error) {
+	mapping := bleve.NewIndexMapping()
+
+	// setup the mapping and text analysis settings for this index
+	self.useFilterMapping(mapping)
+
+	if err := self.useFieldMappings(mapping, collection); err != nil {
+		return nil, err
+	}
+
+	switch self.conn.Dataset() {
+	case `memory`:
+		return bleve.NewMemOnly(mapping)
+	default:
+		indexPath := path.Join(self.conn.Dataset(), name)
+
+		if ix, err := bleve.Open(indexPath); err == nil {
+			return ix, nil
+		} else {
+			return bleve.New(indexPath, mapping)
+		}
+	}
+}
+
 func (self *BleveIndexer) filterToBleveQuery(index bleve.Index, f *filter.Filter) (query.Query, error) {
-	defer stats.NewTiming().Send(`pivot.indexers.bleve.filter_to_native`)
+	defer timeOperation(`pivot.indexers.bleve.filter_to_native`)()
 
 	if f.MatchAll {
 		return bleve.NewMatchAllQuery(), nil
@@ -474,7 +784,18 @@ func (self *BleveIndexer) filterToBleveQuery(index bleve.Index, f *filter.Filter
 						case `false`:
 							currentQuery = bleve.NewBoolFieldQuery(false)
 						default:
-							currentQuery = bleve.NewTermQuery(analyzedValue)
+							switch criterion.Type {
+							case dal.IntType, dal.FloatType:
+								// numbers are indexed numerically, so they can't be matched as terms
+								if v, err := stringutil.ConvertToFloat(analyzedValue); err == nil {
+									inclusive := true
+									currentQuery = bleve.NewNumericRangeInclusiveQuery(&v, &v, &inclusive, &inclusive)
+								} else {
+									return nil, err
+								}
+							default:
+								currentQuery = bleve.NewTermQuery(analyzedValue)
+							}
 						}
 					}
 
@@ -484,6 +805,10 @@ func (self *BleveIndexer) filterToBleveQuery(index bleve.Index, f *filter.Filter
 					currentQuery = bleve.NewWildcardQuery(`*` + analyzedValue)
 				case `contains`:
 					currentQuery = bleve.NewWildcardQuery(`*` + analyzedValue + `*`)
+				case `fuzzy`:
+					fq := bleve.NewFuzzyQuery(analyzedValue)
+					fq.SetFuzziness(criterion.GetFuzziness())
+					currentQuery = fq
 
 				case `gt`, `lt`, `gte`, `lte`:
 					var minInc, maxInc bool
@@ -612,3 +937,59 @@ func (self *BleveIndexer) useFilterMapping(mappingImpl *mapping.IndexMappingImpl
 
 	mappingImpl.DefaultAnalyzer = `pivot_filter`
 }
+
+// Maps each of the collection's fields according to its type: numbers, times and booleans are indexed
+// as such, identity and key strings are indexed verbatim as a single term, and other strings use the
+// default analyzer.  A field's IndexAnalyzer (e.g.: "keyword", "standard", or a language such as "en")
+// overrides the analyzer for string fields, and IndexNoStore omits the field's value from search results.
+// Fields not described by the collection are mapped dynamically.
+func (self *BleveIndexer) useFieldMappings(mappingImpl *mapping.IndexMappingImpl, collection *dal.Collection) error {
+	if collection == nil || len(collection.Fields) == 0 {
+		return nil
+	}
+
+	document := bleve.NewDocumentMapping()
+
+	for _, field := range collection.Fields {
+		var fieldMapping *mapping.FieldMapping
+
+		switch field.Type {
+		case dal.IntType, dal.FloatType:
+			fieldMapping = bleve.NewNumericFieldMapping()
+		case dal.TimeType:
+			fieldMapping = bleve.NewDateTimeFieldMapping()
+		case dal.BooleanType:
+			fieldMapping = bleve.NewBooleanFieldMapping()
+		case dal.PointType:
+			fieldMapping = bleve.NewGeoPointFieldMapping()
+		case dal.StringType:
+			fieldMapping = bleve.NewTextFieldMapping()
+
+			if field.Identity || field.Key {
+				fieldMapping.Analyzer = keyword.Name
+			} else if lang, err := indexLanguageFor(collection, &field); err == nil {
+				// the language analyzers are registered under their language code
+				if lang != `` {
+					fieldMapping.Analyzer = lang
+				}
+			} else {
+				return err
+			}
+
+			if field.IndexAnalyzer != `` {
+				fieldMapping.Analyzer = field.IndexAnalyzer
+			}
+		default:
+			continue
+		}
+
+		if field.IndexNoStore {
+			fieldMapping.Store = false
+		}
+
+		document.AddFieldMappingsAt(field.Name, fieldMapping)
+	}
+
+	mappingImpl.DefaultMapping = document
+	return nil
+}
@@ -0,0 +1,248 @@
+package backends
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blevesearch/bleve"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a bleve indexer that keeps its indexes in memory, using the given connection string options
+func newMemoryBleveIndexer(options string) (*BleveIndexer, error) {
+	if cs, err := dal.ParseConnectionString(`bleve:///memory?` + options); err == nil {
+		indexer := NewBleveIndexer(cs)
+		return indexer, indexer.IndexInitialize(nil)
+	} else {
+		return nil, err
+	}
+}
+
+func TestBleveIndexerRemovePending(t *testing.T) {
+	assert := require.New(t)
+
+	// documents accumulate until ten are pending (or an hour has passed)
+	indexer, err := newMemoryBleveIndexer(`batchSize=10&flushInterval=1h`)
+	assert.NoError(err)
+
+	collection := dal.NewCollection(`widgets`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(
+		dal.NewRecord(`1`).Set(`name`, `first`),
+		dal.NewRecord(`2`).Set(`name`, `second`),
+	)))
+
+	// nothing has been written yet
+	assert.False(indexer.IndexExists(collection, `1`))
+	assert.False(indexer.IndexExists(collection, `2`))
+
+	// removing a pending document writes out its batch first, so it isn't added back later
+	assert.NoError(indexer.IndexRemove(collection, []interface{}{`1`}))
+	assert.False(indexer.IndexExists(collection, `1`))
+	assert.True(indexer.IndexExists(collection, `2`))
+
+	assert.NoError(indexer.FlushIndex())
+	assert.False(indexer.IndexExists(collection, `1`))
+	assert.True(indexer.IndexExists(collection, `2`))
+
+	// the same holds for documents added after the flush
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(
+		dal.NewRecord(`3`).Set(`name`, `third`),
+	)))
+
+	assert.NoError(indexer.IndexRemove(collection, []interface{}{`3`}))
+	assert.NoError(indexer.FlushIndex())
+	assert.False(indexer.IndexExists(collection, `3`))
+	assert.True(indexer.IndexExists(collection, `2`))
+}
+
+func TestBleveIndexerShards(t *testing.T) {
+	assert := require.New(t)
+
+	indexer, err := newMemoryBleveIndexer(`shards=4`)
+	assert.NoError(err)
+
+	collection := dal.NewCollection(`widgets`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	records := dal.NewRecordSet()
+
+	for i := 0; i < 20; i++ {
+		records.Push(dal.NewRecord(fmt.Sprintf("%d", i)).Set(`name`, fmt.Sprintf("widget %d", i)))
+	}
+
+	assert.NoError(indexer.Index(collection, records))
+
+	index, err := indexer.getIndexForCollection(collection)
+	assert.NoError(err)
+	assert.Len(index.shards, 4)
+
+	// each document is written to exactly one shard, and retrieved through the alias spanning all of them
+	for _, record := range records.Records {
+		id := fmt.Sprintf("%v", record.ID)
+
+		for shard, shardIndex := range index.shards {
+			results, err := shardIndex.Search(bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{id})))
+			assert.NoError(err)
+
+			if shard == index.shardFor(id) {
+				assert.EqualValues(1, results.Total, "document %s missing from shard %d", id, shard)
+			} else {
+				assert.EqualValues(0, results.Total, "document %s unexpectedly in shard %d", id, shard)
+			}
+		}
+
+		assert.True(indexer.IndexExists(collection, id))
+	}
+
+	// removals are routed to the same shard the document was written to
+	remove := make([]interface{}, 0)
+
+	for i := 0; i < 20; i += 2 {
+		remove = append(remove, fmt.Sprintf("%d", i))
+	}
+
+	assert.NoError(indexer.IndexRemove(collection, remove))
+
+	for i := 0; i < 20; i++ {
+		assert.Equal(i%2 == 1, indexer.IndexExists(collection, fmt.Sprintf("%d", i)), "document %d", i)
+	}
+}
+
+func TestBleveIndexerFieldMappings(t *testing.T) {
+	assert := require.New(t)
+
+	indexer, err := newMemoryBleveIndexer(``)
+	assert.NoError(err)
+
+	collection := dal.NewCollection(`widgets`).AddFields(dal.Field{
+		Name: `code`,
+		Type: dal.StringType,
+		Key:  true,
+	}, dal.Field{
+		Name: `title`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name:          `sku`,
+		Type:          dal.StringType,
+		IndexAnalyzer: `keyword`,
+	}, dal.Field{
+		Name: `count`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name:         `secret`,
+		Type:         dal.StringType,
+		IndexNoStore: true,
+	})
+
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(
+		dal.NewRecord(`1`).SetFields(map[string]interface{}{
+			`code`:   `ABC-123`,
+			`title`:  `Quick Brown Fox`,
+			`sku`:    `Widget A`,
+			`count`:  `42`,
+			`secret`: `hidden`,
+		}),
+		dal.NewRecord(`2`).SetFields(map[string]interface{}{
+			`code`:   `XYZ-789`,
+			`title`:  `Lazy Dog`,
+			`sku`:    `Widget B`,
+			`count`:  7,
+			`secret`: `hidden`,
+		}),
+	)))
+
+	for spec, ids := range map[string][]string{
+		// key fields are indexed verbatim as a single term
+		`code/ABC-123`: {`1`},
+		`code/abc`:     {},
+
+		// other strings are analyzed, so individual words match regardless of case
+		`title/quick`: {`1`},
+		`title/DOG`:   {`2`},
+
+		// an explicit analyzer takes precedence
+		`sku/Widget A`: {`1`},
+		`sku/widget`:   {},
+
+		// numbers are indexed numerically, even when given as strings
+		`int:count/gte:40`: {`1`},
+		`int:count/lt:40`:  {`2`},
+		`int:count/42`:     {`1`},
+	} {
+		results, err := indexer.Query(collection, filter.MustParse(spec))
+		assert.NoError(err, spec)
+
+		actual := make([]string, 0)
+
+		for _, record := range results.Records {
+			actual = append(actual, fmt.Sprintf("%v", record.ID))
+		}
+
+		assert.ElementsMatch(ids, actual, spec)
+	}
+
+	// fields that aren't stored are searchable, but omitted from results
+	results, err := indexer.Query(collection, filter.MustParse(`secret/hidden`).WithFields(`title`, `secret`))
+	assert.NoError(err)
+	assert.Len(results.Records, 2)
+
+	for _, record := range results.Records {
+		assert.NotEmpty(record.Get(`title`))
+		assert.Nil(record.Get(`secret`))
+	}
+}
+
+func TestBleveIndexerRemoveInChunks(t *testing.T) {
+	assert := require.New(t)
+
+	defer func(size int) {
+		IndexRemoveChunkSize = size
+	}(IndexRemoveChunkSize)
+
+	IndexRemoveChunkSize = 3
+
+	indexer, err := newMemoryBleveIndexer(`shards=2`)
+	assert.NoError(err)
+
+	mock := NewMockBackend()
+	assert.NoError(indexer.IndexInitialize(mock))
+
+	collection := dal.NewCollection(`widgets`)
+	assert.NoError(mock.CreateCollection(collection))
+
+	records := dal.NewRecordSet()
+	ids := make([]interface{}, 0)
+
+	for i := 0; i < 10; i++ {
+		records.Push(dal.NewRecord(fmt.Sprintf("%d", i)).Set(`group`, i%2))
+		ids = append(ids, fmt.Sprintf("%d", i))
+	}
+
+	assert.NoError(mock.Insert(`widgets`, records))
+	assert.NoError(indexer.Index(collection, records))
+
+	// documents are removed from every shard, however many chunks it takes
+	assert.NoError(indexer.IndexRemove(collection, ids[:7]))
+
+	for i, id := range ids {
+		assert.Equal(i >= 7, indexer.IndexExists(collection, id), "document %v", id)
+	}
+
+	// deleting by query deletes the matching records from the backend in chunks
+	assert.NoError(indexer.Index(collection, records))
+	assert.NoError(indexer.DeleteQuery(collection, filter.All()))
+
+	assert.Len(mock.CallsTo(`Delete`), 4)
+
+	for _, id := range ids {
+		assert.False(mock.Exists(`widgets`, id))
+	}
+}
@@ -0,0 +1,16 @@
+package backends
+
+import (
+	"io"
+)
+
+// Implemented by backends that can read and write the contents of a RawType field as a stream,
+// without loading the entire value into memory.
+type BlobStreamer interface {
+	// Writes the contents of the given reader to the named field of the given record.
+	WriteBlob(collection string, id interface{}, field string, reader io.Reader) error
+
+	// Returns a reader positioned at the start of the named field's contents, along with the total
+	// size of the contents in bytes.
+	ReadBlob(collection string, id interface{}, field string) (io.ReadSeeker, int64, error)
+}
@@ -0,0 +1,390 @@
+// Package conformancetest provides behavior tests that any backend is expected to pass, so that
+// backend implementations (including ones maintained outside of this repository) can verify that
+// they behave the way pivot and its users expect.
+//
+// To test a backend, call Run (or RunBackend, or any of the individual tests) from a Test function:
+//
+//	func TestMyBackendConformance(t *testing.T) {
+//		conformancetest.Run(t, `mybackend://localhost/test`)
+//	}
+//
+// Each test creates (and removes) its own collections, whose names begin with "Conformance".
+package conformancetest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// Connects to the backend described by the given connection string and runs every test in this
+// package against it as subtests.
+func Run(t *testing.T, connectionString string) {
+	if cs, err := dal.ParseConnectionString(connectionString); err == nil {
+		if backend, err := backends.MakeBackend(cs); err == nil {
+			if err := backend.Initialize(); err != nil {
+				t.Fatalf("failed to initialize backend: %v", err)
+			}
+
+			RunBackend(t, backend)
+		} else {
+			t.Fatalf("failed to create backend: %v", err)
+		}
+	} else {
+		t.Fatalf("invalid connection string: %v", err)
+	}
+}
+
+// Runs every test in this package against the given (initialized) backend as subtests.
+func RunBackend(t *testing.T, backend backends.Backend) {
+	t.Run(`CollectionManagement`, func(t *testing.T) {
+		TestCollectionManagement(t, backend)
+	})
+
+	t.Run(`CRUD`, func(t *testing.T) {
+		TestCRUD(t, backend)
+	})
+
+	t.Run(`Errors`, func(t *testing.T) {
+		TestErrors(t, backend)
+	})
+
+	t.Run(`TypeRoundTrip`, func(t *testing.T) {
+		TestTypeRoundTrip(t, backend)
+	})
+
+	t.Run(`FilterOperators`, func(t *testing.T) {
+		TestFilterOperators(t, backend)
+	})
+
+	t.Run(`Pagination`, func(t *testing.T) {
+		TestPagination(t, backend)
+	})
+}
+
+// Verifies that collections can be created, listed, retrieved, and deleted.
+func TestCollectionManagement(t *testing.T, backend backends.Backend) {
+	assert := require.New(t)
+	name := `ConformanceCollectionManagement`
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(name).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	collection, err := backend.GetCollection(name)
+	assert.NoError(err)
+	assert.Equal(name, collection.Name)
+
+	_, ok := collection.GetField(`name`)
+	assert.True(ok, "collection is missing field 'name'")
+
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Contains(names, name)
+
+	assert.NoError(backend.DeleteCollection(name))
+
+	_, err = backend.GetCollection(name)
+	assert.True(errors.Is(err, dal.ErrCollectionNotFound), "%v", err)
+}
+
+// Verifies inserting, checking for, retrieving, updating, and deleting records.
+func TestCRUD(t *testing.T, backend backends.Backend) {
+	assert := require.New(t)
+
+	withCollection(t, backend, dal.NewCollection(`ConformanceCRUD`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `count`,
+		Type: dal.IntType,
+	}), func(collection *dal.Collection) {
+		assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+			dal.NewRecord(1).Set(`name`, `First`).Set(`count`, 1),
+			dal.NewRecord(2).Set(`name`, `Second`).Set(`count`, 2),
+			dal.NewRecord(3).Set(`name`, `Third`).Set(`count`, 3),
+		)))
+
+		assert.True(backend.Exists(collection.Name, 1))
+		assert.True(backend.Exists(collection.Name, `1`), "IDs given as strings should be converted")
+		assert.False(backend.Exists(collection.Name, 99))
+
+		record, err := backend.Retrieve(collection.Name, 2)
+		assert.NoError(err)
+		assert.EqualValues(2, record.ID)
+		assert.Equal(`Second`, record.Get(`name`))
+		assert.EqualValues(2, record.Get(`count`))
+
+		// retrieving specific fields
+		record, err = backend.Retrieve(collection.Name, 2, `name`)
+		assert.NoError(err)
+		assert.Equal(`Second`, record.Get(`name`))
+
+		// updates only change the given fields
+		assert.NoError(backend.Update(collection.Name, dal.NewRecordSet(
+			dal.NewRecord(3).Set(`name`, `Threeve`),
+		)))
+
+		record, err = backend.Retrieve(collection.Name, 3)
+		assert.NoError(err)
+		assert.Equal(`Threeve`, record.Get(`name`))
+
+		assert.NoError(backend.Delete(collection.Name, 1, 2))
+		assert.False(backend.Exists(collection.Name, 1))
+		assert.False(backend.Exists(collection.Name, 2))
+		assert.True(backend.Exists(collection.Name, 3))
+	})
+}
+
+// Verifies that errors are reported using the sentinel errors in the dal package.
+func TestErrors(t *testing.T, backend backends.Backend) {
+	assert := require.New(t)
+
+	withCollection(t, backend, dal.NewCollection(`ConformanceErrors`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}), func(collection *dal.Collection) {
+		_, err := backend.Retrieve(collection.Name, 99)
+		assert.True(errors.Is(err, dal.ErrRecordNotFound), "%v", err)
+
+		_, err = backend.GetCollection(`ConformanceErrorsMissing`)
+		assert.True(errors.Is(err, dal.ErrCollectionNotFound), "%v", err)
+
+		assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+			dal.NewRecord(1).Set(`name`, `First`),
+		)))
+
+		err = backend.Insert(collection.Name, dal.NewRecordSet(
+			dal.NewRecord(1).Set(`name`, `Again`),
+		))
+
+		assert.True(errors.Is(err, dal.ErrUniqueViolation), "%v", err)
+	})
+}
+
+// Verifies that values of every field type come back as the type they were written as, and that
+// zero values are distinguished from nulls.
+func TestTypeRoundTrip(t *testing.T, backend backends.Backend) {
+	assert := require.New(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	withCollection(t, backend, dal.NewCollection(`ConformanceTypeRoundTrip`).AddFields(dal.Field{
+		Name: `str`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `int`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name: `float`,
+		Type: dal.FloatType,
+	}, dal.Field{
+		Name: `bool`,
+		Type: dal.BooleanType,
+	}, dal.Field{
+		Name: `time`,
+		Type: dal.TimeType,
+	}, dal.Field{
+		Name: `object`,
+		Type: dal.ObjectType,
+	}), func(collection *dal.Collection) {
+		assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+			dal.NewRecord(1).SetFields(map[string]interface{}{
+				`str`:   `hello ☃`,
+				`int`:   int64(-42),
+				`float`: 3.25,
+				`bool`:  true,
+				`time`:  now,
+				`object`: map[string]interface{}{
+					`name`: `nested`,
+				},
+			}),
+			dal.NewRecord(2).SetFields(map[string]interface{}{
+				`str`:   ``,
+				`int`:   0,
+				`float`: 0.0,
+				`bool`:  false,
+			}),
+			dal.NewRecord(3),
+		)))
+
+		record, err := backend.Retrieve(collection.Name, 1)
+		assert.NoError(err)
+		assert.Equal(`hello ☃`, record.Get(`str`))
+		assert.EqualValues(-42, record.Get(`int`))
+		assert.Equal(3.25, record.Get(`float`))
+		assert.Equal(true, record.Get(`bool`))
+
+		if v, ok := record.Get(`time`).(time.Time); ok {
+			assert.True(now.Equal(v), "expected %v, got %v", now, v)
+		} else {
+			assert.Fail(fmt.Sprintf("expected time.Time, got %T", record.Get(`time`)))
+		}
+
+		assert.Equal(`nested`, record.GetNested(`object.name`))
+
+		record, err = backend.Retrieve(collection.Name, 2)
+		assert.NoError(err)
+		assert.False(record.IsNull(`int`), "zero values should not be null")
+		assert.EqualValues(0, record.Get(`int`))
+		assert.EqualValues(0, record.Get(`float`))
+		assert.Equal(false, record.Get(`bool`))
+
+		record, err = backend.Retrieve(collection.Name, 3)
+		assert.NoError(err)
+		assert.True(record.IsNull(`int`))
+		assert.True(record.IsNull(`time`))
+	})
+}
+
+// Verifies that each filter operator matches the expected records.  This is skipped for backends
+// that don't support querying.
+func TestFilterOperators(t *testing.T, backend backends.Backend) {
+	assert := require.New(t)
+	collection := dal.NewCollection(`ConformanceFilterOperators`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `count`,
+		Type: dal.IntType,
+	})
+
+	withCollection(t, backend, collection, func(collection *dal.Collection) {
+		search := backend.WithSearch(collection)
+
+		if search == nil {
+			t.Skipf("backend %T does not support queries", backend)
+		}
+
+		assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+			dal.NewRecord(1).Set(`name`, `First`).Set(`count`, 10),
+			dal.NewRecord(2).Set(`name`, `Second`).Set(`count`, 20),
+			dal.NewRecord(3).Set(`name`, `Third`).Set(`count`, 30),
+			dal.NewRecord(4).Set(`name`, `Fourth`),
+		)))
+
+		for query, ids := range map[string][]int64{
+			`all`:                          {1, 2, 3, 4},
+			`id/2`:                         {2},
+			`name/First`:                   {1},
+			`name/not:First`:               {2, 3, 4},
+			`name/like:first`:              {1},
+			`name/prefix:fi`:               {1},
+			`name/suffix:d`:                {2, 3},
+			`name/contains:ir`:             {1, 3},
+			`count/gt:10`:                  {2, 3},
+			`count/gte:20`:                 {2, 3},
+			`count/lt:20`:                  {1},
+			`count/lte:20`:                 {1, 2},
+			`count/null`:                   {4},
+			`count/not:null`:               {1, 2, 3},
+			`count/gte:10/count/lte:20`:    {1, 2},
+			`name/contains:ir/count/gt:10`: {3},
+			`name/Nobody`:                  {},
+		} {
+			f, err := filter.Parse(query)
+			assert.NoError(err, query)
+			f.Sort = []string{`id`}
+
+			recordset, err := search.Query(collection, f)
+			assert.NoError(err, query)
+
+			actual := make([]int64, 0)
+
+			assert.NoError(recordset.Each(func(record *dal.Record) error {
+				actual = append(actual, record.ID.(int64))
+				return nil
+			}), query)
+
+			assert.Equal(ids, actual, query)
+		}
+	})
+}
+
+// Verifies that limits, offsets, result counts, and page counts are consistent with one another.
+// This is skipped for backends that don't support querying.
+func TestPagination(t *testing.T, backend backends.Backend) {
+	assert := require.New(t)
+	collection := dal.NewCollection(`ConformancePagination`)
+
+	withCollection(t, backend, collection, func(collection *dal.Collection) {
+		search := backend.WithSearch(collection)
+
+		if search == nil {
+			t.Skipf("backend %T does not support queries", backend)
+		}
+
+		recordset := dal.NewRecordSet()
+
+		for i := 1; i <= 21; i++ {
+			recordset.Push(dal.NewRecord(i))
+		}
+
+		assert.NoError(backend.Insert(collection.Name, recordset))
+
+		for _, tc := range []struct {
+			offset int
+			limit  int
+			count  int
+			first  int64
+			pages  int
+		}{
+			{0, 25, 21, 1, 1},
+			{0, 9, 9, 1, 3},
+			{3, 9, 9, 4, 3},
+			{18, 9, 3, 19, 3},
+			{20, 0, 1, 21, 1},
+			{30, 9, 0, 0, 3},
+		} {
+			name := fmt.Sprintf("offset=%d limit=%d", tc.offset, tc.limit)
+			f := filter.All()
+			f.Offset = tc.offset
+			f.Limit = tc.limit
+			f.Sort = []string{`id`}
+
+			results, err := search.Query(collection, f)
+			assert.NoError(err, name)
+			assert.Equal(tc.count, results.Len(), name)
+
+			if tc.count > 0 {
+				record, ok := results.GetRecord(0)
+				assert.True(ok, name)
+				assert.EqualValues(tc.first, record.ID, name)
+			}
+
+			if results.KnownSize {
+				assert.EqualValues(21, results.ResultCount, name)
+
+				if tc.limit > 0 {
+					assert.Equal(tc.pages, results.TotalPages, name)
+				}
+			}
+		}
+	})
+}
+
+// creates a collection for the duration of the given function, removing it afterwards
+func withCollection(t *testing.T, backend backends.Backend, collection *dal.Collection, fn func(collection *dal.Collection)) {
+	t.Helper()
+
+	// remove anything left behind by a previous run
+	backend.DeleteCollection(collection.Name)
+
+	if err := backend.CreateCollection(collection); err != nil {
+		t.Fatalf("failed to create collection %s: %v", collection.Name, err)
+	}
+
+	defer func() {
+		if err := backend.DeleteCollection(collection.Name); err != nil {
+			t.Errorf("failed to delete collection %s: %v", collection.Name, err)
+		}
+	}()
+
+	fn(collection)
+}
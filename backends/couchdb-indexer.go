@@ -0,0 +1,184 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/ghetzel/pivot/filter/generators"
+)
+
+type couchdbFindResponse struct {
+	Docs    []map[string]interface{} `json:"docs"`
+	Warning string                   `json:"warning"`
+}
+
+func (self *CouchDBBackend) IndexConnectionString() *dal.ConnectionString {
+	return &dal.ConnectionString{}
+}
+
+func (self *CouchDBBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *CouchDBBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *CouchDBBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.Name, id)
+}
+
+func (self *CouchDBBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.Name, id)
+}
+
+func (self *CouchDBBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *CouchDBBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Translates the filter into a Mango query and calls resultFn with each matching document, requesting
+// them from the database's _find endpoint in pages of IndexerPageSize.
+func (self *CouchDBBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	defer timeOperation(`pivot.indexers.couchdb.query_time`)()
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	var query map[string]interface{}
+
+	if data, err := filter.Render(generators.NewCouchDBGenerator(), collection.Name, f); err == nil {
+		if err := json.Unmarshal(data, &query); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	path := fmt.Sprintf("/%s/_find", url.PathEscape(collection.Name))
+	processed := 0
+	page := 1
+
+	for {
+		limit := IndexerPageSize
+
+		if f.Limit > 0 {
+			if remaining := f.Limit - processed; remaining <= 0 {
+				return nil
+			} else if remaining < limit {
+				limit = remaining
+			}
+		}
+
+		query[`limit`] = limit
+		query[`skip`] = f.Offset + processed
+
+		var response couchdbFindResponse
+
+		if err := self.decode(`POST`, path, query, &response); err != nil {
+			return err
+		}
+
+		if response.Warning != `` {
+			querylog.Debugf("[%T] %s: %s", self, collection.Name, response.Warning)
+		}
+
+		for _, doc := range response.Docs {
+			processed++
+
+			// design documents hold views and indexes rather than records
+			if id, _ := doc[`_id`].(string); strings.HasPrefix(id, `_design/`) {
+				continue
+			}
+
+			record, err := self.recordFromDocument(collection, doc)
+
+			// the total number of matching documents isn't reported by _find
+			if err := resultFn(record, err, IndexPage{
+				Page:         page,
+				Limit:        f.Limit,
+				Offset:       f.Offset,
+				TotalResults: -1,
+			}); err != nil {
+				return queryStopped(err)
+			}
+		}
+
+		if len(response.Docs) < limit {
+			return nil
+		}
+
+		page++
+	}
+}
+
+func (self *CouchDBBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *CouchDBBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+	seen := make(map[string]map[string]bool)
+
+	err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			value := record.Get(field)
+
+			if field == collection.IdentityField {
+				value = record.ID
+			}
+
+			if seen[field] == nil {
+				seen[field] = make(map[string]bool)
+			}
+
+			if key := fmt.Sprintf("%v", value); !seen[field][key] {
+				seen[field][key] = true
+				values[field] = append(values[field], value)
+			}
+		}
+
+		return nil
+	})
+
+	return values, err
+}
+
+func (self *CouchDBBackend) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	return DefaultFacetsImplementation(self, collection, fields, f)
+}
+
+func (self *CouchDBBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		ids = append(ids, record.ID)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return deleteInChunks(self, collection.Name, ids)
+}
+
+func (self *CouchDBBackend) FlushIndex() error {
+	return nil
+}
@@ -0,0 +1,612 @@
+package backends
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// The number of times an update that conflicts with a concurrent change is retried (against the
+// latest revision of the document) before giving up.
+var CouchDBConflictRetries = 5
+
+// The local (unreplicated) document in each database that the collection definition is stored in.
+var CouchDBSchemaDocument = `_local/pivot`
+
+// How long each request to CouchDB may take before it is abandoned.
+var CouchDBRequestTimeout = 30 * time.Second
+
+type couchdbWriteResult struct {
+	OK     bool   `json:"ok"`
+	ID     string `json:"id"`
+	Rev    string `json:"rev"`
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// A Backend that stores each collection as a CouchDB database, with one document per record.  The
+// revision of each document is kept in the Revision of the records read from it.
+//
+// Updates only change the fields given in each record.  Records with a Revision are only updated if
+// the document hasn't changed since that revision was read (otherwise a stale record error is
+// returned); records without one are merged into the latest revision of the document, retrying if
+// it is changed concurrently.
+type CouchDBBackend struct {
+	Backend
+	conn                  dal.ConnectionString
+	client                *http.Client
+	indexer               Indexer
+	registeredCollections sync.Map
+}
+
+func NewCouchDBBackend(connection dal.ConnectionString) Backend {
+	return &CouchDBBackend{
+		conn: connection,
+		client: &http.Client{
+			Timeout: CouchDBRequestTimeout,
+		},
+	}
+}
+
+func (self *CouchDBBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *CouchDBBackend) Initialize() error {
+	if err := self.Ping(DefaultConnectTimeout); err != nil {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	return self.indexer.IndexInitialize(self)
+}
+
+func (self *CouchDBBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *CouchDBBackend) RegisterCollection(collection *dal.Collection) {
+	if collection != nil {
+		self.registeredCollections.Store(collection.Name, collection)
+	}
+}
+
+func (self *CouchDBBackend) Ping(timeout time.Duration) error {
+	errchan := make(chan error, 1)
+
+	go func() {
+		if response, err := self.do(`GET`, `/`, nil, nil); err == nil {
+			response.Body.Close()
+
+			if response.StatusCode >= 400 {
+				errchan <- fmt.Errorf("%v", response.Status)
+			} else {
+				errchan <- nil
+			}
+		} else {
+			errchan <- err
+		}
+	}()
+
+	select {
+	case err := <-errchan:
+		if err != nil {
+			return fmt.Errorf("Backend unavailable: %v", err)
+		}
+
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("Backend unavailable: timed out after waiting %v", timeout)
+	}
+}
+
+func (self *CouchDBBackend) Exists(name string, id interface{}) bool {
+	if collection, err := self.GetCollection(name); err == nil {
+		if rev, err := self.currentRevision(collection, id); err == nil && rev != `` {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *CouchDBBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		if doc, err := self.getDocument(collection, id); err == nil {
+			if doc == nil {
+				return nil, dal.RecordNotFound(id)
+			}
+
+			if record, err := self.recordFromDocument(collection, doc); err == nil {
+				if len(fields) > 0 {
+					for key := range record.Fields {
+						if !sliceutil.ContainsString(fields, key) {
+							delete(record.Fields, key)
+						}
+					}
+				}
+
+				return record, nil
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *CouchDBBackend) Insert(name string, recordset *dal.RecordSet) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		for _, record := range recordset.Records {
+			if r, err := collection.MakeRecord(record); err == nil {
+				record = r
+			} else {
+				return err
+			}
+
+			if record.ID == nil {
+				return fmt.Errorf("Cannot write a record without an ID to %v", collection.Name)
+			}
+
+			if result, status, err := self.putDocument(collection, record.ID, ``, record.Fields); err == nil {
+				if status == http.StatusConflict {
+					return dal.UniqueViolation(fmt.Errorf("Record %v already exists", record.ID))
+				} else if !result.OK {
+					return fmt.Errorf("Failed to insert record %v: %v", record.ID, result.Reason)
+				}
+
+				record.Revision = result.Rev
+			} else {
+				return err
+			}
+		}
+
+		return self.index(collection, recordset)
+	} else {
+		return err
+	}
+}
+
+func (self *CouchDBBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		for _, record := range recordset.Records {
+			if r, err := collection.MakeRecord(record); err == nil {
+				record = r
+			} else {
+				return err
+			}
+
+			if record.ID == nil {
+				return fmt.Errorf("Cannot write a record without an ID to %v", collection.Name)
+			}
+
+			if err := self.update(collection, record); err != nil {
+				return err
+			}
+		}
+
+		return self.index(collection, recordset)
+	} else {
+		return err
+	}
+}
+
+// merges the record's fields into its document, retrying against the latest revision when the
+// document is changed concurrently (unless the record specifies the revision it expects)
+func (self *CouchDBBackend) update(collection *dal.Collection, record *dal.Record) error {
+	for attempt := 0; attempt <= CouchDBConflictRetries; attempt++ {
+		doc, err := self.getDocument(collection, record.ID)
+
+		if err != nil {
+			return err
+		} else if doc == nil {
+			doc = make(map[string]interface{})
+		}
+
+		rev, _ := doc[`_rev`].(string)
+
+		if record.Revision != `` && record.Revision != rev {
+			return dal.StaleRecord(record.ID)
+		}
+
+		for k, v := range record.Fields {
+			doc[k] = v
+		}
+
+		if result, status, err := self.putDocument(collection, record.ID, rev, doc); err == nil {
+			if status == http.StatusConflict {
+				if record.Revision != `` {
+					return dal.StaleRecord(record.ID)
+				}
+
+				querylog.Debugf("[%T] Record %v changed while updating, retrying", self, record.ID)
+				continue
+			} else if !result.OK {
+				return fmt.Errorf("Failed to update record %v: %v", record.ID, result.Reason)
+			}
+
+			record.Revision = result.Rev
+			return nil
+		} else {
+			return err
+		}
+	}
+
+	return fmt.Errorf("Failed to update record %v: it was changed by others %d times", record.ID, CouchDBConflictRetries+1)
+}
+
+func (self *CouchDBBackend) Delete(name string, ids ...interface{}) error {
+	if collection, err := self.GetCollection(name); err == nil {
+	DeleteLoop:
+		for _, id := range ids {
+			for attempt := 0; attempt <= CouchDBConflictRetries; attempt++ {
+				rev, err := self.currentRevision(collection, id)
+
+				if err != nil {
+					return err
+				} else if rev == `` {
+					continue DeleteLoop
+				}
+
+				if response, err := self.do(`DELETE`, self.documentPath(collection, id), url.Values{
+					`rev`: []string{rev},
+				}, nil); err == nil {
+					response.Body.Close()
+
+					switch {
+					case response.StatusCode == http.StatusConflict:
+						continue
+					case response.StatusCode == http.StatusNotFound:
+						continue DeleteLoop
+					case response.StatusCode >= 400:
+						return fmt.Errorf("Failed to delete record %v: %v", id, response.Status)
+					default:
+						continue DeleteLoop
+					}
+				} else {
+					return err
+				}
+			}
+
+			return fmt.Errorf("Failed to delete record %v: it was changed by others %d times", id, CouchDBConflictRetries+1)
+		}
+
+		if search := self.WithSearch(collection); search != nil {
+			return search.IndexRemove(collection, ids)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Creates a database for the collection, storing the collection definition in it and creating Mango
+// indexes on each field so that queries can be sorted by them.
+func (self *CouchDBBackend) CreateCollection(definition *dal.Collection) error {
+	if response, err := self.do(`PUT`, `/`+url.PathEscape(definition.Name), nil, nil); err == nil {
+		response.Body.Close()
+
+		if response.StatusCode == http.StatusPreconditionFailed {
+			return fmt.Errorf("Collection %v already exists", definition.Name)
+		} else if response.StatusCode >= 400 {
+			return fmt.Errorf("Failed to create collection %v: %v", definition.Name, response.Status)
+		}
+	} else {
+		return err
+	}
+
+	if response, err := self.do(`PUT`, fmt.Sprintf("/%s/%s", url.PathEscape(definition.Name), CouchDBSchemaDocument), nil, map[string]interface{}{
+		`collection`: definition,
+	}); err == nil {
+		response.Body.Close()
+
+		if response.StatusCode >= 400 {
+			return fmt.Errorf("Failed to store definition of collection %v: %v", definition.Name, response.Status)
+		}
+	} else {
+		return err
+	}
+
+	for _, field := range definition.Fields {
+		if field.Name == definition.IdentityField {
+			continue
+		}
+
+		if response, err := self.do(`POST`, fmt.Sprintf("/%s/_index", url.PathEscape(definition.Name)), nil, map[string]interface{}{
+			`name`: `pivot-` + field.Name,
+			`type`: `json`,
+			`index`: map[string]interface{}{
+				`fields`: []string{field.Name},
+			},
+		}); err == nil {
+			response.Body.Close()
+
+			if response.StatusCode >= 400 {
+				return fmt.Errorf("Failed to index field %v of collection %v: %v", field.Name, definition.Name, response.Status)
+			}
+		} else {
+			return err
+		}
+	}
+
+	self.RegisterCollection(definition)
+	return nil
+}
+
+func (self *CouchDBBackend) DeleteCollection(name string) error {
+	if response, err := self.do(`DELETE`, `/`+url.PathEscape(name), nil, nil); err == nil {
+		response.Body.Close()
+
+		if response.StatusCode == http.StatusNotFound {
+			return dal.CollectionNotFound
+		} else if response.StatusCode >= 400 {
+			return fmt.Errorf("Failed to delete collection %v: %v", name, response.Status)
+		}
+
+		self.registeredCollections.Delete(name)
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Lists every database except CouchDB's own (whose names start with an underscore).
+func (self *CouchDBBackend) ListCollections() ([]string, error) {
+	var names []string
+
+	if err := self.decode(`GET`, `/_all_dbs`, nil, &names); err != nil {
+		return nil, err
+	}
+
+	collections := make([]string, 0, len(names))
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, `_`) {
+			collections = append(collections, name)
+		}
+	}
+
+	sort.Strings(collections)
+	return collections, nil
+}
+
+// Returns the collection definition stored in the named database, or a collection with a string
+// identity (and no other fields) for databases that were not created by this backend.
+func (self *CouchDBBackend) GetCollection(name string) (*dal.Collection, error) {
+	if c, ok := self.registeredCollections.Load(name); ok {
+		return c.(*dal.Collection), nil
+	}
+
+	collection := dal.NewCollection(name)
+
+	if response, err := self.do(`GET`, fmt.Sprintf("/%s/%s", url.PathEscape(name), CouchDBSchemaDocument), nil, nil); err == nil {
+		defer response.Body.Close()
+
+		switch {
+		case response.StatusCode == http.StatusNotFound:
+			// tell a missing database apart from a database without a definition
+			if response, err := self.do(`HEAD`, `/`+url.PathEscape(name), nil, nil); err == nil {
+				response.Body.Close()
+
+				if response.StatusCode == http.StatusNotFound {
+					return nil, dal.CollectionNotFound
+				}
+			} else {
+				return nil, err
+			}
+
+			collection.IdentityFieldType = dal.StringType
+
+		case response.StatusCode >= 400:
+			return nil, fmt.Errorf("Failed to retrieve collection %v: %v", name, response.Status)
+
+		default:
+			schema := struct {
+				Collection *dal.Collection `json:"collection"`
+			}{
+				Collection: collection,
+			}
+
+			if err := json.NewDecoder(response.Body).Decode(&schema); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, err
+	}
+
+	self.RegisterCollection(collection)
+	return collection, nil
+}
+
+func (self *CouchDBBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *CouchDBBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return nil
+}
+
+func (self *CouchDBBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+func (self *CouchDBBackend) index(collection *dal.Collection, recordset *dal.RecordSet) error {
+	if !collection.SkipIndexPersistence {
+		if search := self.WithSearch(collection); search != nil {
+			return search.Index(collection, recordset)
+		}
+	}
+
+	return nil
+}
+
+func (self *CouchDBBackend) documentPath(collection *dal.Collection, id interface{}) string {
+	return fmt.Sprintf("/%s/%s", url.PathEscape(collection.Name), url.PathEscape(fmt.Sprintf("%v", id)))
+}
+
+// returns the document with the given ID, or nil if it doesn't exist
+func (self *CouchDBBackend) getDocument(collection *dal.Collection, id interface{}) (map[string]interface{}, error) {
+	if response, err := self.do(`GET`, self.documentPath(collection, id), nil, nil); err == nil {
+		defer response.Body.Close()
+
+		var doc map[string]interface{}
+
+		if response.StatusCode == http.StatusNotFound {
+			return nil, nil
+		} else if response.StatusCode >= 400 {
+			return nil, fmt.Errorf("Failed to retrieve record %v: %v", id, response.Status)
+		} else if err := json.NewDecoder(response.Body).Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode error: %v", err)
+		}
+
+		return doc, nil
+	} else {
+		return nil, err
+	}
+}
+
+// returns the current revision of the given document (from its ETag), or an empty string if it
+// doesn't exist
+func (self *CouchDBBackend) currentRevision(collection *dal.Collection, id interface{}) (string, error) {
+	if response, err := self.do(`HEAD`, self.documentPath(collection, id), nil, nil); err == nil {
+		response.Body.Close()
+
+		if response.StatusCode == http.StatusNotFound {
+			return ``, nil
+		} else if response.StatusCode >= 400 {
+			return ``, fmt.Errorf("Failed to retrieve record %v: %v", id, response.Status)
+		}
+
+		return strings.Trim(response.Header.Get(`ETag`), `"`), nil
+	} else {
+		return ``, err
+	}
+}
+
+// writes the given fields as the document's content, returning the result and the response status
+func (self *CouchDBBackend) putDocument(collection *dal.Collection, id interface{}, rev string, fields map[string]interface{}) (*couchdbWriteResult, int, error) {
+	doc := make(map[string]interface{})
+
+	for k, v := range convertGeometryFields(collection, fields, toGeoJSON) {
+		doc[k] = v
+	}
+
+	delete(doc, `_id`)
+	delete(doc, `_rev`)
+
+	if rev != `` {
+		doc[`_rev`] = rev
+	}
+
+	if response, err := self.do(`PUT`, self.documentPath(collection, id), nil, doc); err == nil {
+		defer response.Body.Close()
+
+		var result couchdbWriteResult
+
+		if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+			return nil, response.StatusCode, fmt.Errorf("decode error: %v", err)
+		}
+
+		return &result, response.StatusCode, nil
+	} else {
+		return nil, 0, err
+	}
+}
+
+func (self *CouchDBBackend) recordFromDocument(collection *dal.Collection, doc map[string]interface{}) (*dal.Record, error) {
+	record := dal.NewRecord(doc[`_id`])
+	record.Revision, _ = doc[`_rev`].(string)
+
+	for k, v := range doc {
+		if !strings.HasPrefix(k, `_`) {
+			record.Set(k, v)
+		}
+	}
+
+	if collection.IdentityFieldType != dal.StringType {
+		record.ID = stringutil.Autotype(record.ID)
+	}
+
+	// do this AFTER populating the record's fields from the document
+	if err := record.Populate(record, collection); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (self *CouchDBBackend) do(method string, urlpath string, query url.Values, body interface{}) (*http.Response, error) {
+	var payload io.Reader
+
+	if body != nil {
+		if data, err := json.Marshal(body); err == nil {
+			payload = bytes.NewReader(data)
+		} else {
+			return nil, err
+		}
+	}
+
+	u := fmt.Sprintf("%s://%s%s", sliceutil.OrString(self.conn.Protocol(), `http`), self.conn.Host(), urlpath)
+
+	if len(query) > 0 {
+		u += `?` + query.Encode()
+	}
+
+	querylog.Debugf("[%T] %v %v", self, method, u)
+
+	if req, err := http.NewRequest(method, u, payload); err == nil {
+		req.Header.Set(`Accept`, `application/json`)
+		req.Header.Set(`Content-Type`, `application/json`)
+
+		if username, password, ok := self.conn.Credentials(); ok {
+			req.SetBasicAuth(username, password)
+		}
+
+		return self.client.Do(req)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *CouchDBBackend) decode(method string, urlpath string, body interface{}, into interface{}) error {
+	if response, err := self.do(method, urlpath, nil, body); err == nil {
+		defer response.Body.Close()
+
+		if response.StatusCode >= 400 {
+			return fmt.Errorf("%v %v: %v", method, urlpath, response.Status)
+		}
+
+		return json.NewDecoder(response.Body).Decode(into)
+	} else {
+		return err
+	}
+}
@@ -103,8 +103,24 @@ func (self *DynamoBackend) QueryFunc(collection *dal.Collection, flt *filter.Fil
 			return fmt.Errorf("Could not generate DynamoDB query from filter %v", flt)
 		}
 
+		rangeKey, hasRangeKey := dynamoRangeKey(collection)
+		var rangeApplied bool
+
 		for _, criterion := range flt.Criteria {
-			if !collection.IsIdentityField(criterion.Field) {
+			// DynamoDB does not allow key attributes in filter expressions, so criteria on the range
+			// key become the query's key condition
+			if hasRangeKey && criterion.Field == rangeKey.Name {
+				op, ok := self.toRangeOp(&criterion)
+
+				if !ok || rangeApplied || len(criterion.Values) != 1 {
+					return dal.Unsupported("Only a single comparison with one value is supported on range key '%v' when querying DynamoDB", criterion.Field)
+				}
+
+				querylog.Debugf("[%T] Table: %v; RANGE: %v %v %v", self, collection.Name, criterion.Field, op, criterion.Values[0])
+				query = query.Range(criterion.Field, op, criterion.Values[0])
+				rangeApplied = true
+
+			} else if !collection.IsIdentityField(criterion.Field) {
 				values := make([]interface{}, 0)
 				orFilters := make([]string, 0)
 
@@ -112,7 +128,7 @@ func (self *DynamoBackend) QueryFunc(collection *dal.Collection, flt *filter.Fil
 					nativeOp := self.toNativeOp(&criterion)
 
 					if nativeOp == `` {
-						return fmt.Errorf("Unsupported operator '%v' when querying DynamoDB", criterion.Operator)
+						return dal.Unsupported("Unsupported operator '%v' when querying DynamoDB", criterion.Operator)
 					}
 
 					orFilters = append(
@@ -185,11 +201,15 @@ func (self *DynamoBackend) Query(collection *dal.Collection, f *filter.Filter, r
 }
 
 func (self *DynamoBackend) ListValues(collection *dal.Collection, fields []string, flt *filter.Filter) (map[string][]interface{}, error) {
-	return nil, fmt.Errorf("%T.ListValues: Not Implemented", self)
+	return nil, dal.Unsupported("%T.ListValues: Not Implemented", self)
+}
+
+func (self *DynamoBackend) Facets(collection *dal.Collection, fields []string, flt *filter.Filter) (map[string][]FacetBucket, error) {
+	return DefaultFacetsImplementation(self, collection, fields, flt)
 }
 
 func (self *DynamoBackend) DeleteQuery(collection *dal.Collection, flt *filter.Filter) error {
-	return fmt.Errorf("%T.DeleteQuery: Not Implemented", self)
+	return dal.Unsupported("%T.DeleteQuery: Not Implemented", self)
 }
 
 func (self *DynamoBackend) FlushIndex() error {
@@ -207,6 +227,10 @@ func (self *DynamoBackend) validateFilter(collection *dal.Collection, flt *filte
 				continue
 			}
 
+			if rangeKey, ok := dynamoRangeKey(collection); ok && rangeKey.Name == field {
+				continue
+			}
+
 			return fmt.Errorf("Filter field '%v' cannot be used: not a key field", field)
 		}
 	}
@@ -233,6 +257,26 @@ func (self *DynamoBackend) toNativeOp(criterion *filter.Criterion) string {
 	}
 }
 
+// returns the key condition operator equivalent to the criterion's operator
+func (self *DynamoBackend) toRangeOp(criterion *filter.Criterion) (dynamo.Operator, bool) {
+	switch criterion.Operator {
+	case `lt`:
+		return dynamo.Less, true
+	case `lte`:
+		return dynamo.LessOrEqual, true
+	case `gt`:
+		return dynamo.Greater, true
+	case `gte`:
+		return dynamo.GreaterOrEqual, true
+	case `prefix`:
+		return dynamo.BeginsWith, true
+	case `is`, ``:
+		return dynamo.Equal, true
+	default:
+		return ``, false
+	}
+}
+
 func (self *DynamoBackend) iterResult(result map[string]interface{}, collection *dal.Collection, flt *filter.Filter, resultFn IndexResultFunc) (bool, error) {
 	record := dal.NewRecord(nil)
 
@@ -246,7 +290,9 @@ func (self *DynamoBackend) iterResult(result map[string]interface{}, collection
 
 	if err := resultFn(record, nil, IndexPage{
 		Limit: flt.Limit,
-	}); err != nil {
+	}); err == IndexResultsStop {
+		return false, nil
+	} else if err != nil {
 		return false, err
 	}
 
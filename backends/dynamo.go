@@ -21,6 +21,13 @@ import (
 
 var DefaultAmazonRegion = `us-east-1`
 
+// The largest item DynamoDB will store, used as the MaxRecordBytes of collections read from tables.
+var DynamoMaxItemBytes = 400 * 1024
+
+// The read and write capacity units that tables are created with, unless the "readCapacity" and
+// "writeCapacity" options say otherwise.
+var DynamoDefaultCapacity int64 = 5
+
 type DynamoBackend struct {
 	Backend
 	Indexer
@@ -154,7 +161,7 @@ func (self *DynamoBackend) Retrieve(name string, id interface{}, fields ...strin
 
 			return record, nil
 		} else if err == dynamo.ErrNotFound {
-			return nil, fmt.Errorf("Record %v does not exist", id)
+			return nil, dal.RecordNotFound(id)
 		} else if err == dynamo.ErrTooMany {
 			return nil, fmt.Errorf("Too many records found for ID %v", id)
 		} else {
@@ -195,8 +202,64 @@ func (self *DynamoBackend) Delete(name string, ids ...interface{}) error {
 	return nil
 }
 
+// Creates a table whose partition (hash) key is the collection's identity field, and whose sort
+// (range) key is its sort key field, if it has one.  Other fields are not part of the table's
+// definition, as DynamoDB items can have any attributes.
 func (self *DynamoBackend) CreateCollection(definition *dal.Collection) error {
-	return fmt.Errorf("Not Implemented")
+	if definition.IdentityField == `` {
+		definition.IdentityField = dal.DefaultIdentityField
+	}
+
+	input := &dynamodb.CreateTableInput{
+		TableName: aws.String(definition.Name),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String(definition.IdentityField),
+				AttributeType: aws.String(self.toKeyType(definition.IdentityFieldType)),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String(definition.IdentityField),
+				KeyType:       aws.String(dynamodb.KeyTypeHash),
+			},
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(self.cs.OptInt(`readCapacity`, DynamoDefaultCapacity)),
+			WriteCapacityUnits: aws.Int64(self.cs.OptInt(`writeCapacity`, DynamoDefaultCapacity)),
+		},
+	}
+
+	if rangeKey, ok := dynamoRangeKey(definition); ok {
+		input.AttributeDefinitions = append(input.AttributeDefinitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(rangeKey.Name),
+			AttributeType: aws.String(self.toKeyType(rangeKey.Type)),
+		})
+
+		input.KeySchema = append(input.KeySchema, &dynamodb.KeySchemaElement{
+			AttributeName: aws.String(rangeKey.Name),
+			KeyType:       aws.String(dynamodb.KeyTypeRange),
+		})
+	}
+
+	querylog.Debugf("[%T] create table: %v", self, input)
+
+	if _, err := self.db.Client().CreateTable(input); err == nil {
+		if err := self.db.Client().WaitUntilTableExists(&dynamodb.DescribeTableInput{
+			TableName: aws.String(definition.Name),
+		}); err != nil {
+			return err
+		}
+
+		if definition.MaxRecordBytes == 0 {
+			definition.MaxRecordBytes = DynamoMaxItemBytes
+		}
+
+		self.RegisterCollection(definition)
+		return nil
+	} else {
+		return err
+	}
 }
 
 func (self *DynamoBackend) DeleteCollection(name string) error {
@@ -260,6 +323,17 @@ func (self *DynamoBackend) toDalType(t dynamo.KeyType) dal.Type {
 	}
 }
 
+func (self *DynamoBackend) toKeyType(t dal.Type) string {
+	switch t {
+	case dal.RawType:
+		return dynamodb.ScalarAttributeTypeB
+	case dal.IntType, dal.FloatType:
+		return dynamodb.ScalarAttributeTypeN
+	default:
+		return dynamodb.ScalarAttributeTypeS
+	}
+}
+
 func (self *DynamoBackend) cacheTable(name string) (*dal.Collection, error) {
 	if table, err := self.db.Table(name).Describe().Run(); err == nil {
 		if collectionI, ok := self.tableCache.Load(name); ok {
@@ -269,6 +343,7 @@ func (self *DynamoBackend) cacheTable(name string) (*dal.Collection, error) {
 				Name:              table.Name,
 				IdentityField:     table.HashKey,
 				IdentityFieldType: self.toDalType(table.HashKeyType),
+				MaxRecordBytes:    DynamoMaxItemBytes,
 			}
 
 			collection.AddFields(dal.Field{
@@ -283,6 +358,7 @@ func (self *DynamoBackend) cacheTable(name string) (*dal.Collection, error) {
 				collection.AddFields(dal.Field{
 					Name:     rangeKey,
 					Key:      true,
+					SortKey:  true,
 					Required: true,
 					Type:     self.toDalType(table.RangeKeyType),
 				})
@@ -301,10 +377,8 @@ func (self *DynamoBackend) toRecordKeyFilter(collection *dal.Collection, id inte
 	var hashValue interface{}
 	var rangeValue interface{}
 
-	for _, field := range collection.Fields {
-		if f := field; f.Key {
-			rangeKey = &f
-		}
+	if field, ok := dynamoRangeKey(collection); ok {
+		rangeKey = &field
 	}
 
 	// at least the identity field must have been found
@@ -414,6 +488,11 @@ func (self *DynamoBackend) getSingleRecordDelete(name string, id interface{}) (*
 
 func (self *DynamoBackend) upsertRecords(collection *dal.Collection, records *dal.RecordSet, isCreate bool) error {
 	for _, record := range records.Records {
+		// records are written as-is (rather than via MakeRecord), so enforce size limits here
+		if err := collection.CheckLimits(record); err != nil {
+			return err
+		}
+
 		item := make(map[string]interface{})
 
 		for k, v := range record.Fields {
@@ -440,7 +519,7 @@ func (self *DynamoBackend) upsertRecords(collection *dal.Collection, records *da
 
 			exprValues := []interface{}{record.ID}
 
-			if rangeKey, ok := collection.GetFirstNonIdentityKeyField(); ok {
+			if rangeKey, ok := dynamoRangeKey(collection); ok {
 				expr = append(expr, `attribute_not_exists($)`)
 
 				if v := record.Get(rangeKey.Name); v != nil {
@@ -468,3 +547,13 @@ func (self *DynamoBackend) upsertRecords(collection *dal.Collection, records *da
 
 	return nil
 }
+
+// returns the field used as a table's range key: the collection's sort key, or (for collections that
+// don't declare one) its first non-identity key field
+func dynamoRangeKey(collection *dal.Collection) (dal.Field, bool) {
+	if field, ok := collection.GetSortKeyField(); ok {
+		return field, true
+	}
+
+	return collection.GetFirstNonIdentityKeyField()
+}
@@ -2,6 +2,7 @@ package backends
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -98,7 +99,7 @@ func (self *bulkOperation) GetBody() ([]map[string]interface{}, error) {
 	rv = append(rv, map[string]interface{}{
 		string(self.Type): map[string]interface{}{
 			`_index`: self.Index,
-			`_type`:  self.Type,
+			`_type`:  self.DocType,
 			`_id`:    self.ID,
 		},
 	})
@@ -207,7 +208,7 @@ func (self *ElasticsearchIndexer) GetBackend() Backend {
 }
 
 func (self *ElasticsearchIndexer) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
-	defer stats.NewTiming().Send(`pivot.indexers.elasticsearch.retrieve_time`)
+	defer timeOperation(`pivot.indexers.elasticsearch.retrieve_time`)()
 
 	if index, err := self.getIndexForCollection(collection); err == nil {
 		if req, err := self.newRequest(`GET`, fmt.Sprintf(
@@ -248,7 +249,7 @@ func (self *ElasticsearchIndexer) IndexExists(collection *dal.Collection, id int
 }
 
 func (self *ElasticsearchIndexer) Index(collection *dal.Collection, records *dal.RecordSet) error {
-	defer stats.NewTiming().Send(`pivot.indexers.elasticsearch.index_time`)
+	defer timeOperation(`pivot.indexers.elasticsearch.index_time`)()
 
 	if index, err := self.getIndexForCollection(collection); err == nil {
 		for _, record := range records.Records {
@@ -259,7 +260,7 @@ func (self *ElasticsearchIndexer) Index(collection *dal.Collection, records *dal
 				Index:   index.Name,
 				DocType: ElasticsearchDocumentType,
 				ID:      record.ID,
-				Payload: record.Fields,
+				Payload: convertGeometryFields(collection, record.Fields, elasticsearchGeometry),
 			})
 		}
 
@@ -287,7 +288,7 @@ func (self *ElasticsearchIndexer) checkAndFlushBatches(forceFlush bool) {
 		}
 
 		if shouldFlush {
-			defer stats.NewTiming().Send(`pivot.indexers.elasticsearch.deferred_batch_flush`)
+			defer timeOperation(`pivot.indexers.elasticsearch.deferred_batch_flush`)()
 
 			if bulkBody, err := self.indexDeferredBatch.Flush(); err == nil {
 				querylog.Debugf("[%T] Indexing %d records to %s", self, l)
@@ -311,7 +312,7 @@ func (self *ElasticsearchIndexer) checkAndFlushBatches(forceFlush bool) {
 }
 
 func (self *ElasticsearchIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
-	defer stats.NewTiming().Send(`pivot.indexers.elasticsearch.query_time`)
+	defer timeOperation(`pivot.indexers.elasticsearch.query_time`)()
 
 	if f.IdentityField == `` {
 		f.IdentityField = ElasticsearchIdentityField
@@ -400,14 +401,17 @@ func (self *ElasticsearchIndexer) QueryFunc(collection *dal.Collection, f *filte
 
 							// call the resultFn for each hit on this page
 							for _, hit := range results.Hits {
-								if err := resultFn(dal.NewRecord(hit.ID).SetFields(hit.Source), nil, IndexPage{
+								record := dal.NewRecord(hit.ID).SetFields(hit.Source)
+								record.Score = hit.Score
+
+								if err := resultFn(record, nil, IndexPage{
 									Page:         page,
 									TotalPages:   totalPages,
 									Limit:        originalLimit,
 									Offset:       f.Offset,
 									TotalResults: int64(results.Total),
 								}); err != nil {
-									return err
+									return queryStopped(err)
 								}
 
 								processed += 1
@@ -468,14 +472,48 @@ func (self *ElasticsearchIndexer) Query(collection *dal.Collection, f *filter.Fi
 
 func (self *ElasticsearchIndexer) IndexRemove(collection *dal.Collection, ids []interface{}) error {
 	if index, err := self.getIndexForCollection(collection); err == nil {
-		for _, id := range ids {
-			if req, err := self.newRequest(
-				`DELETE`,
-				fmt.Sprintf("/%v/%v/%v", index.Name, ElasticsearchDocumentType, id),
-				nil,
-			); err == nil {
-				self.client.Do(req)
+		// pending documents must be written first, otherwise they would reappear once their batch
+		// is flushed
+		self.checkAndFlushBatches(true)
+
+		for len(ids) > 0 {
+			var body []map[string]interface{}
+			n := len(ids)
+
+			if IndexRemoveChunkSize > 0 && n > IndexRemoveChunkSize {
+				n = IndexRemoveChunkSize
+			}
+
+			for _, id := range ids[:n] {
+				op := bulkOperation{
+					Type:    bulkDelete,
+					Index:   index.Name,
+					DocType: ElasticsearchDocumentType,
+					ID:      id,
+				}
+
+				if opBody, err := op.GetBody(); err == nil {
+					body = append(body, opBody...)
+				} else {
+					return err
+				}
+			}
+
+			if req, err := self.newRequest(`POST`, `/_bulk`, body); err == nil {
+				if response, err := self.client.Do(req); err == nil {
+					response.Body.Close()
+
+					if response.StatusCode >= 400 {
+						return fmt.Errorf("error removing %d documents: %v", n, response.Status)
+					}
+				} else {
+					return err
+				}
+			} else {
+				return err
 			}
+
+			ids = ids[n:]
 		}
 
 		return nil
@@ -486,7 +524,222 @@ func (self *ElasticsearchIndexer) IndexRemove(collection *dal.Collection, ids []
 
 func (self *ElasticsearchIndexer) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
 	if _, err := self.getIndexForCollection(collection); err == nil {
-		return nil, fmt.Errorf("Not Implemented")
+		return nil, NotImplementedError
+	} else {
+		return nil, err
+	}
+}
+
+// Counts the distinct values of the given fields using a terms aggregation for each.
+func (self *ElasticsearchIndexer) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	if f.IdentityField == `` {
+		f.IdentityField = ElasticsearchIdentityField
+	}
+
+	if index, err := self.getIndexForCollection(collection); err == nil {
+		var body map[string]interface{}
+
+		if query, err := filter.Render(generators.NewElasticsearchGenerator(), index.Name, f); err == nil {
+			if err := json.Unmarshal(query, &body); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+
+		aggs := make(map[string]interface{})
+
+		for _, field := range fields {
+			if field == `id` {
+				field = ElasticsearchIdentityField
+			}
+
+			aggs[field] = map[string]interface{}{
+				`terms`: map[string]interface{}{
+					`field`: field,
+					`size`:  MaxFacetCardinality,
+				},
+			}
+		}
+
+		delete(body, `from`)
+		delete(body, `sort`)
+		body[`size`] = 0
+		body[`aggs`] = aggs
+
+		if req, err := self.newRequest(`GET`, fmt.Sprintf("/%s/_search", index.Name), body); err == nil {
+			if response, err := self.client.Do(req); err == nil {
+				defer response.Body.Close()
+
+				if response.StatusCode < 400 {
+					var result struct {
+						Aggregations map[string]struct {
+							Buckets []struct {
+								Key      interface{} `json:"key"`
+								DocCount int64       `json:"doc_count"`
+							} `json:"buckets"`
+						} `json:"aggregations"`
+					}
+
+					if err := json.NewDecoder(response.Body).Decode(&result); err == nil {
+						output := make(map[string][]FacetBucket)
+
+						for name, agg := range result.Aggregations {
+							buckets := make([]FacetBucket, 0, len(agg.Buckets))
+
+							for _, bucket := range agg.Buckets {
+								buckets = append(buckets, FacetBucket{
+									Value: bucket.Key,
+									Count: bucket.DocCount,
+								})
+							}
+
+							if name == ElasticsearchIdentityField {
+								name = `id`
+							}
+
+							output[name] = sortFacetBuckets(buckets)
+						}
+
+						return output, nil
+					} else {
+						return nil, fmt.Errorf("decode error: %v", err)
+					}
+				} else {
+					return nil, fmt.Errorf("%v", response.Status)
+				}
+			} else {
+				return nil, fmt.Errorf("response error: %v", err)
+			}
+		} else {
+			return nil, fmt.Errorf("request error: %v", err)
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Groups records into time buckets using a date_histogram aggregation, with a metric aggregation
+// of the value field in each bucket.  First and Last aggregations are not supported.
+func (self *ElasticsearchIndexer) AggregateByTime(collection *dal.Collection, field string, interval TimeInterval, fn filter.Aggregate, f ...*filter.Filter) ([]TimeBucket, error) {
+	var metric string
+
+	switch fn.Aggregation {
+	case filter.Count:
+	case filter.Sum:
+		metric = `sum`
+	case filter.Average:
+		metric = `avg`
+	case filter.Minimum:
+		metric = `min`
+	case filter.Maximum:
+		metric = `max`
+	default:
+		return nil, dal.Unsupported("%T cannot perform first or last aggregations over time", self)
+	}
+
+	var flt *filter.Filter
+
+	if len(f) > 0 && f[0] != nil {
+		flt = f[0]
+	} else {
+		flt = filter.All()
+	}
+
+	if flt.IdentityField == `` {
+		flt.IdentityField = ElasticsearchIdentityField
+	}
+
+	if index, err := self.getIndexForCollection(collection); err == nil {
+		var body map[string]interface{}
+
+		if query, err := filter.Render(generators.NewElasticsearchGenerator(), index.Name, flt); err == nil {
+			if err := json.Unmarshal(query, &body); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+
+		histogram := map[string]interface{}{
+			`date_histogram`: map[string]interface{}{
+				`field`:         field,
+				`interval`:      string(interval),
+				`min_doc_count`: 1,
+			},
+		}
+
+		if metric != `` && fn.Field != `` {
+			histogram[`aggs`] = map[string]interface{}{
+				`value`: map[string]interface{}{
+					metric: map[string]interface{}{
+						`field`: fn.Field,
+					},
+				},
+			}
+		}
+
+		delete(body, `from`)
+		delete(body, `sort`)
+		body[`size`] = 0
+		body[`aggs`] = map[string]interface{}{
+			`timeseries`: histogram,
+		}
+
+		if req, err := self.newRequest(`GET`, fmt.Sprintf("/%s/_search", index.Name), body); err == nil {
+			if response, err := self.client.Do(req); err == nil {
+				defer response.Body.Close()
+
+				if response.StatusCode < 400 {
+					var result struct {
+						Aggregations struct {
+							Timeseries struct {
+								Buckets []struct {
+									Key      int64  `json:"key"`
+									DocCount uint64 `json:"doc_count"`
+									Value    struct {
+										Value *float64 `json:"value"`
+									} `json:"value"`
+								} `json:"buckets"`
+							} `json:"timeseries"`
+						} `json:"aggregations"`
+					}
+
+					if err := json.NewDecoder(response.Body).Decode(&result); err == nil {
+						buckets := make([]TimeBucket, 0, len(result.Aggregations.Timeseries.Buckets))
+
+						for _, bucket := range result.Aggregations.Timeseries.Buckets {
+							tb := TimeBucket{
+								Time:  time.Unix(0, bucket.Key*int64(time.Millisecond)).UTC(),
+								Count: bucket.DocCount,
+							}
+
+							if metric == `` {
+								tb.Value = float64(bucket.DocCount)
+							} else if bucket.Value.Value != nil {
+								tb.Value = *bucket.Value.Value
+							}
+
+							buckets = append(buckets, tb)
+						}
+
+						return buckets, nil
+					} else {
+						return nil, fmt.Errorf("decode error: %v", err)
+					}
+				} else {
+					return nil, fmt.Errorf("%v", response.Status)
+				}
+			} else {
+				return nil, fmt.Errorf("response error: %v", err)
+			}
+		} else {
+			return nil, fmt.Errorf("request error: %v", err)
+		}
 	} else {
 		return nil, err
 	}
@@ -496,11 +749,13 @@ func (self *ElasticsearchIndexer) DeleteQuery(collection *dal.Collection, f *fil
 	f.Fields = []string{ElasticsearchIdentityField}
 	var ids []interface{}
 
+	// all matching IDs are gathered before anything is deleted, since deleting while paging through
+	// the results would shift the pages out from under us
 	if err := self.QueryFunc(collection, f, func(indexRecord *dal.Record, err error, page IndexPage) error {
 		ids = append(ids, indexRecord.ID)
 		return nil
 	}); err == nil {
-		return self.parent.Delete(collection.Name, ids)
+		return deleteInChunks(self.parent, collection.Name, ids)
 	} else {
 		return err
 	}
@@ -511,6 +766,100 @@ func (self *ElasticsearchIndexer) FlushIndex() error {
 	return nil
 }
 
+// Verifies that the Elasticsearch cluster is reachable.
+func (self *ElasticsearchIndexer) IndexPing(timeout time.Duration) error {
+	if req, err := self.newRequest(`GET`, `/`, nil); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		if response, err := self.client.Do(req.WithContext(ctx)); err == nil {
+			defer response.Body.Close()
+
+			if response.StatusCode < 400 {
+				return nil
+			} else {
+				return fmt.Errorf("Indexer unavailable: %v", response.Status)
+			}
+		} else {
+			return fmt.Errorf("Indexer unavailable: %v", err)
+		}
+	} else {
+		return err
+	}
+}
+
+// Returns the number of index operations waiting to be sent in the next bulk request.
+func (self *ElasticsearchIndexer) IndexQueueDepth() int {
+	self.indexDeferredBatch.batchLock.Lock()
+	defer self.indexDeferredBatch.batchLock.Unlock()
+
+	return len(self.indexDeferredBatch.batch)
+}
+
+// Renders the given filter into an Elasticsearch query, and uses the Validate API to retrieve the
+// explanation of how each shard will rewrite it.
+func (self *ElasticsearchIndexer) Explain(collection *dal.Collection, f *filter.Filter) (*QueryPlan, error) {
+	if f.IdentityField == `` {
+		f.IdentityField = ElasticsearchIdentityField
+	}
+
+	index, err := self.getIndexForCollection(collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := filter.Render(generators.NewElasticsearchGenerator(), index.Name, f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &QueryPlan{
+		Collection: collection.Name,
+		Filter:     f.String(),
+		Backend:    `elasticsearch`,
+		Query:      string(query),
+	}
+
+	// the validate API only accepts the "query" portion of a search request
+	var search map[string]interface{}
+
+	if err := json.Unmarshal(query, &search); err != nil {
+		return nil, err
+	}
+
+	if req, err := self.newRequest(
+		`GET`,
+		fmt.Sprintf("/%s/_validate/query?explain=true&all_shards=true", index.Name),
+		map[string]interface{}{
+			`query`: search[`query`],
+		},
+	); err == nil {
+		if response, err := self.client.Do(req); err == nil {
+			defer response.Body.Close()
+
+			var validation struct {
+				Valid        bool                     `json:"valid"`
+				Explanations []map[string]interface{} `json:"explanations"`
+			}
+
+			if response.StatusCode >= 400 {
+				return nil, fmt.Errorf("Failed to explain query: %v", response.Status)
+			} else if err := json.NewDecoder(response.Body).Decode(&validation); err != nil {
+				return nil, err
+			}
+
+			plan.Plan = validation.Explanations
+			return plan, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
 func (self *ElasticsearchIndexer) newRequest(method string, urlpath string, body interface{}) (*http.Request, error) {
 	var buf bytes.Buffer
 	var lines []string
@@ -549,7 +898,7 @@ func (self *ElasticsearchIndexer) newRequest(method string, urlpath string, body
 }
 
 func (self *ElasticsearchIndexer) getIndexForCollection(collection *dal.Collection) (*elasticsearchIndex, error) {
-	defer stats.NewTiming().Send(`pivot.indexers.elasticsearch.retrieve_index`)
+	defer timeOperation(`pivot.indexers.elasticsearch.retrieve_index`)()
 	name := collection.GetIndexName()
 
 	if v, ok := self.indexCache[name]; ok {
@@ -571,6 +920,22 @@ func (self *ElasticsearchIndexer) getIndexForCollection(collection *dal.Collecti
 					}
 
 				case response.StatusCode == 404:
+					// collections with language-specific fields need their analyzers configured
+					// before any documents are indexed, so create those indexes up front
+					if properties, err := self.languageMappings(collection); err != nil {
+						return nil, err
+					} else {
+						// geometry fields must also be mapped before they're indexed, since
+						// they would otherwise be indexed as plain objects
+						for field, mapping := range elasticsearchGeoMappings(collection) {
+							properties[field] = mapping
+						}
+
+						if len(properties) > 0 {
+							return self.createIndex(name, properties)
+						}
+					}
+
 					return nil, fmt.Errorf("Index %v not found", name)
 
 				default:
@@ -585,6 +950,91 @@ func (self *ElasticsearchIndexer) getIndexForCollection(collection *dal.Collecti
 	}
 }
 
+// Creates the named index, mapping the given field properties for the default document type.
+func (self *ElasticsearchIndexer) createIndex(name string, properties map[string]interface{}) (*elasticsearchIndex, error) {
+	index := &elasticsearchIndex{
+		Name: name,
+		Mappings: map[string]interface{}{
+			ElasticsearchDocumentType: map[string]interface{}{
+				`properties`: properties,
+			},
+		},
+	}
+
+	if req, err := self.newRequest(`PUT`, fmt.Sprintf("/%s", name), map[string]interface{}{
+		`mappings`: index.Mappings,
+	}); err == nil {
+		if response, err := self.client.Do(req); err == nil {
+			if response.StatusCode < 400 {
+				self.indexCache[name] = index
+				return index, nil
+			} else {
+				return nil, fmt.Errorf("Failed to create index %v: %v", name, response.Status)
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Returns field mappings that apply Elasticsearch's built-in language analyzers to the collection's
+// string fields, according to the collection's (or each field's) configured language.
+func (self *ElasticsearchIndexer) languageMappings(collection *dal.Collection) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+
+	for _, field := range collection.Fields {
+		if field.Type != dal.StringType || field.Identity || field.Key {
+			continue
+		}
+
+		if lang, err := indexLanguageFor(collection, &field); err == nil {
+			if lang != `` {
+				properties[field.Name] = map[string]interface{}{
+					`type`:     `string`,
+					`analyzer`: IndexLanguages[lang],
+				}
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return properties, nil
+}
+
+// Returns field mappings for the collection's geometry fields: points are indexed as geo_points and
+// polygons as geo_shapes.
+func elasticsearchGeoMappings(collection *dal.Collection) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	for _, field := range collection.Fields {
+		switch field.Type {
+		case dal.PointType:
+			properties[field.Name] = map[string]interface{}{
+				`type`: `geo_point`,
+			}
+		case dal.PolygonType:
+			properties[field.Name] = map[string]interface{}{
+				`type`: `geo_shape`,
+			}
+		}
+	}
+
+	return properties
+}
+
+// geo_point fields take points as objects with "lat" and "lon" keys (which is how dal.Point is
+// encoded), and geo_shape fields take GeoJSON
+func elasticsearchGeometry(geometry interface{}) interface{} {
+	if polygon, ok := geometry.(dal.Polygon); ok {
+		return polygon.GeoJSON()
+	}
+
+	return geometry
+}
+
 func (self *ElasticsearchIndexer) useFilterMapping(index *elasticsearchIndex) {
 	// mappingImpl.AddCustomCharFilter(`remove_expression_tokens`, map[string]interface{}{
 	// 	`type`:   regexp.Name,
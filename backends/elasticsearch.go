@@ -0,0 +1,464 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// The key in each index's mapping metadata that the definition of the collection it holds is
+// stored under.
+var ElasticsearchCollectionMetaKey = `pivot_collection`
+
+// The mapping types that fields of each type are given when their index is created.  String fields
+// are left to Elasticsearch's dynamic mapping (or the language mappings) so that they are analyzed
+// the way the running version of Elasticsearch does by default.
+var ElasticsearchFieldTypes = map[dal.Type]string{
+	dal.IntType:     `long`,
+	dal.FloatType:   `double`,
+	dal.BooleanType: `boolean`,
+	dal.TimeType:    `date`,
+	dal.ObjectType:  `object`,
+	dal.RawType:     `binary`,
+	dal.PointType:   `geo_point`,
+	dal.PolygonType: `geo_shape`,
+}
+
+// A Backend that uses Elasticsearch as the source of truth, with one index per collection.  Records
+// are written and read as documents, and queries are performed by the ElasticsearchIndexer.
+//
+// Writes are visible to searches once the affected shards are refreshed.  The "refresh" connection
+// string option is passed along with each write, and defaults to "true" (refresh immediately); set it
+// to "false" to leave refreshing to Elasticsearch, or "wait_for" on clusters that support it.
+type ElasticsearchBackend struct {
+	Backend
+	conn                  dal.ConnectionString
+	es                    *ElasticsearchIndexer
+	indexer               Indexer
+	registeredCollections sync.Map
+}
+
+func NewElasticsearchBackend(connection dal.ConnectionString) Backend {
+	return &ElasticsearchBackend{
+		conn: connection,
+		es:   NewElasticsearchIndexer(connection),
+	}
+}
+
+func (self *ElasticsearchBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *ElasticsearchBackend) Initialize() error {
+	if err := self.es.IndexInitialize(self); err != nil {
+		return err
+	}
+
+	if err := self.es.IndexPing(ElasticsearchConnectTimeout); err != nil {
+		return err
+	}
+
+	if self.indexer != nil {
+		return self.indexer.IndexInitialize(self)
+	}
+
+	return nil
+}
+
+func (self *ElasticsearchBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ElasticsearchBackend) RegisterCollection(collection *dal.Collection) {
+	if collection != nil {
+		self.registeredCollections.Store(collection.Name, collection)
+	}
+}
+
+func (self *ElasticsearchBackend) Ping(timeout time.Duration) error {
+	if err := self.es.IndexPing(timeout); err != nil {
+		return fmt.Errorf("Backend unavailable: %v", err)
+	}
+
+	return nil
+}
+
+func (self *ElasticsearchBackend) Exists(name string, id interface{}) bool {
+	if collection, err := self.GetCollection(name); err == nil {
+		if response, err := self.do(`HEAD`, self.documentPath(collection, id), nil); err == nil {
+			response.Body.Close()
+			return response.StatusCode < 400
+		}
+	}
+
+	return false
+}
+
+func (self *ElasticsearchBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		if response, err := self.do(`GET`, self.documentPath(collection, id), nil); err == nil {
+			defer response.Body.Close()
+
+			var doc elasticsearchDocument
+
+			if response.StatusCode == http.StatusNotFound {
+				return nil, dal.RecordNotFound(id)
+			} else if response.StatusCode >= 400 {
+				return nil, fmt.Errorf("Failed to retrieve record %v: %v", id, response.Status)
+			} else if err := json.NewDecoder(response.Body).Decode(&doc); err != nil {
+				return nil, fmt.Errorf("decode error: %v", err)
+			} else if !doc.Found {
+				return nil, dal.RecordNotFound(id)
+			}
+
+			record := dal.NewRecord(doc.ID).SetFields(doc.Source)
+
+			if collection.IdentityFieldType != dal.StringType {
+				record.ID = stringutil.Autotype(record.ID)
+			}
+
+			// do this AFTER populating the record's fields from the document
+			if err := record.Populate(record, collection); err != nil {
+				return nil, err
+			}
+
+			if len(fields) > 0 {
+				for key := range record.Fields {
+					if !sliceutil.ContainsString(fields, key) {
+						delete(record.Fields, key)
+					}
+				}
+			}
+
+			return record, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Indexes each record as a new document, failing if a document with the same ID already exists.
+func (self *ElasticsearchBackend) Insert(name string, recordset *dal.RecordSet) error {
+	return self.write(name, recordset, true)
+}
+
+// Updates the given fields of each record's document, creating documents that don't exist yet.
+func (self *ElasticsearchBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.write(name, recordset, false)
+}
+
+func (self *ElasticsearchBackend) write(name string, recordset *dal.RecordSet, insert bool) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		for _, record := range recordset.Records {
+			if r, err := collection.MakeRecord(record); err == nil {
+				record = r
+			} else {
+				return err
+			}
+
+			if record.ID == nil {
+				return fmt.Errorf("Cannot write a record without an ID to %v", collection.Name)
+			}
+
+			var urlpath string
+			var body interface{}
+			source := convertGeometryFields(collection, record.Fields, elasticsearchGeometry)
+
+			if insert {
+				urlpath = self.documentPath(collection, record.ID) + `/_create`
+				body = source
+			} else {
+				urlpath = self.documentPath(collection, record.ID) + `/_update`
+				body = map[string]interface{}{
+					`doc`:           source,
+					`doc_as_upsert`: true,
+				}
+			}
+
+			urlpath += `?refresh=` + self.conn.OptString(`refresh`, `true`)
+
+			if response, err := self.do(`POST`, urlpath, body); err == nil {
+				response.Body.Close()
+
+				if response.StatusCode == http.StatusConflict && insert {
+					return dal.UniqueViolation(fmt.Errorf("Record %v already exists", record.ID))
+				} else if response.StatusCode >= 400 {
+					return fmt.Errorf("Failed to write record %v: %v", record.ID, response.Status)
+				}
+			} else {
+				return err
+			}
+		}
+
+		if self.indexer != nil && !collection.SkipIndexPersistence {
+			return self.indexer.Index(collection, recordset)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ElasticsearchBackend) Delete(name string, ids ...interface{}) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		if err := self.es.IndexRemove(collection, ids); err != nil {
+			return err
+		}
+
+		if self.indexer != nil {
+			return self.indexer.IndexRemove(collection, ids)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Creates the collection's index, mapping each field according to its type.  The collection
+// definition is stored in the index's mapping metadata so that it can be read back later.
+func (self *ElasticsearchBackend) CreateCollection(definition *dal.Collection) error {
+	name := definition.GetIndexName()
+	properties, err := self.es.languageMappings(definition)
+
+	if err != nil {
+		return err
+	}
+
+	for _, field := range definition.Fields {
+		if _, ok := properties[field.Name]; ok || field.Name == definition.IdentityField {
+			continue
+		}
+
+		if mappingType, ok := ElasticsearchFieldTypes[field.Type]; ok {
+			properties[field.Name] = map[string]interface{}{
+				`type`: mappingType,
+			}
+		}
+	}
+
+	var meta map[string]interface{}
+
+	if data, err := json.Marshal(definition); err == nil {
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	index := &elasticsearchIndex{
+		Name: name,
+		Mappings: map[string]interface{}{
+			ElasticsearchDocumentType: map[string]interface{}{
+				`_meta`: map[string]interface{}{
+					ElasticsearchCollectionMetaKey: meta,
+				},
+				`properties`: properties,
+			},
+		},
+	}
+
+	if response, err := self.do(`PUT`, `/`+name, map[string]interface{}{
+		`mappings`: index.Mappings,
+	}); err == nil {
+		response.Body.Close()
+
+		if response.StatusCode >= 400 {
+			return fmt.Errorf("Failed to create collection %v: %v", definition.Name, response.Status)
+		}
+	} else {
+		return err
+	}
+
+	self.es.indexCache[name] = index
+	self.RegisterCollection(definition)
+	return nil
+}
+
+func (self *ElasticsearchBackend) DeleteCollection(name string) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		index := collection.GetIndexName()
+
+		if response, err := self.do(`DELETE`, `/`+index, nil); err == nil {
+			response.Body.Close()
+
+			if response.StatusCode >= 400 && response.StatusCode != http.StatusNotFound {
+				return fmt.Errorf("Failed to delete collection %v: %v", name, response.Status)
+			}
+		} else {
+			return err
+		}
+
+		delete(self.es.indexCache, index)
+		self.registeredCollections.Delete(name)
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Lists every index in the cluster except hidden (system) indexes, whose names start with a period.
+func (self *ElasticsearchBackend) ListCollections() ([]string, error) {
+	if response, err := self.do(`GET`, `/_cat/indices?format=json&h=index`, nil); err == nil {
+		defer response.Body.Close()
+
+		var indices []struct {
+			Index string `json:"index"`
+		}
+
+		if response.StatusCode >= 400 {
+			return nil, fmt.Errorf("Failed to list collections: %v", response.Status)
+		} else if err := json.NewDecoder(response.Body).Decode(&indices); err != nil {
+			return nil, err
+		}
+
+		names := make([]string, 0, len(indices))
+
+		for _, index := range indices {
+			if !strings.HasPrefix(index.Index, `.`) {
+				names = append(names, index.Index)
+			}
+		}
+
+		sort.Strings(names)
+		return names, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Returns the collection stored in the named index's mapping metadata, or one built from the index
+// mapping itself for indexes that were not created by this backend.
+func (self *ElasticsearchBackend) GetCollection(name string) (*dal.Collection, error) {
+	if c, ok := self.registeredCollections.Load(name); ok {
+		return c.(*dal.Collection), nil
+	}
+
+	if response, err := self.do(`GET`, fmt.Sprintf("/%s/_mapping", name), nil); err == nil {
+		defer response.Body.Close()
+
+		var mappings map[string]struct {
+			Mappings map[string]struct {
+				Meta       map[string]json.RawMessage `json:"_meta"`
+				Properties map[string]struct {
+					Type string `json:"type"`
+				} `json:"properties"`
+			} `json:"mappings"`
+		}
+
+		if response.StatusCode == http.StatusNotFound {
+			return nil, dal.CollectionNotFound
+		} else if response.StatusCode >= 400 {
+			return nil, fmt.Errorf("Failed to retrieve collection %v: %v", name, response.Status)
+		} else if err := json.NewDecoder(response.Body).Decode(&mappings); err != nil {
+			return nil, err
+		}
+
+		for _, index := range mappings {
+			if mapping, ok := index.Mappings[ElasticsearchDocumentType]; ok {
+				collection := dal.NewCollection(name)
+
+				if data, ok := mapping.Meta[ElasticsearchCollectionMetaKey]; ok {
+					if err := json.Unmarshal(data, collection); err != nil {
+						return nil, err
+					}
+				} else {
+					collection.IdentityFieldType = dal.StringType
+
+					for field, property := range mapping.Properties {
+						collection.AddFields(dal.Field{
+							Name:       field,
+							Type:       elasticsearchFieldType(property.Type),
+							NativeType: property.Type,
+						})
+					}
+				}
+
+				self.RegisterCollection(collection)
+				return collection, nil
+			}
+		}
+
+		return nil, dal.CollectionNotFound
+	} else {
+		return nil, err
+	}
+}
+
+func (self *ElasticsearchBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	if self.indexer != nil {
+		return self.indexer
+	}
+
+	return self.es
+}
+
+func (self *ElasticsearchBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return nil
+}
+
+func (self *ElasticsearchBackend) Flush() error {
+	if err := self.es.FlushIndex(); err != nil {
+		return err
+	}
+
+	if self.indexer != nil {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+func (self *ElasticsearchBackend) documentPath(collection *dal.Collection, id interface{}) string {
+	return fmt.Sprintf("/%s/%s/%v", collection.GetIndexName(), ElasticsearchDocumentType, id)
+}
+
+func (self *ElasticsearchBackend) do(method string, urlpath string, body interface{}) (*http.Response, error) {
+	if req, err := self.es.newRequest(method, urlpath, body); err == nil {
+		return self.es.client.Do(req)
+	} else {
+		return nil, err
+	}
+}
+
+// maps Elasticsearch field mapping types to the closest DAL type
+func elasticsearchFieldType(mappingType string) dal.Type {
+	switch mappingType {
+	case `long`, `integer`, `short`, `byte`:
+		return dal.IntType
+	case `double`, `float`, `half_float`, `scaled_float`:
+		return dal.FloatType
+	case `boolean`:
+		return dal.BooleanType
+	case `date`:
+		return dal.TimeType
+	case `object`, `nested`:
+		return dal.ObjectType
+	case `binary`:
+		return dal.RawType
+	case `geo_point`:
+		return dal.PointType
+	case `geo_shape`:
+		return dal.PolygonType
+	default:
+		return dal.StringType
+	}
+}
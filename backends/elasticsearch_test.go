@@ -0,0 +1,51 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElasticsearchFieldType(t *testing.T) {
+	assert := require.New(t)
+
+	for mappingType, fieldType := range map[string]dal.Type{
+		`long`:       dal.IntType,
+		`integer`:    dal.IntType,
+		`short`:      dal.IntType,
+		`byte`:       dal.IntType,
+		`double`:     dal.FloatType,
+		`half_float`: dal.FloatType,
+		`boolean`:    dal.BooleanType,
+		`date`:       dal.TimeType,
+		`nested`:     dal.ObjectType,
+		`binary`:     dal.RawType,
+		`geo_point`:  dal.PointType,
+		`geo_shape`:  dal.PolygonType,
+		`keyword`:    dal.StringType,
+		`text`:       dal.StringType,
+	} {
+		assert.Equal(fieldType, elasticsearchFieldType(mappingType), mappingType)
+	}
+
+	// every type a collection's fields are mapped to is read back as the same type
+	for fieldType, mappingType := range ElasticsearchFieldTypes {
+		assert.Equal(fieldType, elasticsearchFieldType(mappingType), mappingType)
+	}
+}
+
+func TestElasticsearchDocumentPath(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`elasticsearch://localhost:9200/`)
+	assert.NoError(err)
+
+	backend := NewElasticsearchBackend(cs).(*ElasticsearchBackend)
+	collection := dal.NewCollection(`users`)
+
+	assert.Equal(`/users/`+ElasticsearchDocumentType+`/42`, backend.documentPath(collection, 42))
+
+	collection.IndexName = `people`
+	assert.Equal(`/people/`+ElasticsearchDocumentType+`/42`, backend.documentPath(collection, 42))
+}
@@ -0,0 +1,28 @@
+package backends
+
+import (
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// Describes how a filter is executed: the native query it is rendered into, the values bound to that
+// query, and (if the database can provide one) the execution plan.
+type QueryPlan struct {
+	Collection string                   `json:"collection"`
+	Filter     string                   `json:"filter"`
+	Backend    string                   `json:"backend"`
+	Query      string                   `json:"query"`
+	Values     []interface{}            `json:"values,omitempty"`
+	Plan       []map[string]interface{} `json:"plan,omitempty"`
+
+	// For queries planned by a QueryPlanner: which strategy was chosen and why, and the plans of the
+	// search index and backend queries that are part of it.
+	Strategy string       `json:"strategy,omitempty"`
+	Reason   string       `json:"reason,omitempty"`
+	Steps    []*QueryPlan `json:"steps,omitempty"`
+}
+
+// Implemented by backends and indexers that can describe how a filter will be executed.
+type Explainer interface {
+	Explain(collection *dal.Collection, f *filter.Filter) (*QueryPlan, error)
+}
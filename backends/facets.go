@@ -0,0 +1,111 @@
+package backends
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// A distinct value of a field, along with the number of records matching a filter that have it.
+type FacetBucket struct {
+	Value interface{} `json:"value"`
+	Count int64       `json:"count"`
+}
+
+// Counts the distinct values of the given fields by querying every record that matches the filter.
+// This is suitable for indexers that cannot count values natively.
+func DefaultFacetsImplementation(indexer Indexer, collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	var query filter.Filter
+	options := make(map[string]interface{})
+
+	if f == nil {
+		query = filter.Copy(filter.All())
+	} else {
+		query = filter.Copy(f)
+	}
+
+	for k, v := range query.Options {
+		options[k] = v
+	}
+
+	query.Fields = fields
+	query.Options = options
+	query.Limit = 0
+	query.Offset = 0
+	query.Sort = nil
+
+	counts := make(map[string]map[string]*FacetBucket)
+
+	for _, field := range fields {
+		counts[field] = make(map[string]*FacetBucket)
+	}
+
+	if _, err := indexer.Query(collection, &query, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			var value interface{}
+
+			if field == `id` || field == collection.IdentityField {
+				value = record.ID
+			} else {
+				value = record.Get(field)
+			}
+
+			if value == nil {
+				continue
+			}
+
+			key := fmt.Sprintf("%v", value)
+
+			if bucket, ok := counts[field][key]; ok {
+				bucket.Count += 1
+			} else {
+				counts[field][key] = &FacetBucket{
+					Value: value,
+					Count: 1,
+				}
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	output := make(map[string][]FacetBucket)
+
+	for field, buckets := range counts {
+		values := make([]FacetBucket, 0, len(buckets))
+
+		for _, bucket := range buckets {
+			values = append(values, *bucket)
+		}
+
+		output[field] = sortFacetBuckets(values)
+	}
+
+	return output, nil
+}
+
+// Orders buckets from most to least common (and by value among equally common ones), keeping at most
+// MaxFacetCardinality of them.
+func sortFacetBuckets(buckets []FacetBucket) []FacetBucket {
+	sort.SliceStable(buckets, func(i int, j int) bool {
+		if buckets[i].Count == buckets[j].Count {
+			return fmt.Sprintf("%v", buckets[i].Value) < fmt.Sprintf("%v", buckets[j].Value)
+		}
+
+		return buckets[i].Count > buckets[j].Count
+	})
+
+	if MaxFacetCardinality > 0 && len(buckets) > MaxFacetCardinality {
+		buckets = buckets[:MaxFacetCardinality]
+	}
+
+	return buckets
+}
@@ -0,0 +1,341 @@
+package backends
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"google.golang.org/api/iterator"
+)
+
+// The most values Firestore will compare a field against in a single "in" or "not-in" filter.
+var FirestoreMaxDisjunctionValues = 10
+
+// The upper bound used when matching strings by prefix, which sorts after any other character.
+var firestorePrefixUpperBound = "\uf8ff"
+
+func (self *FirestoreBackend) IndexConnectionString() *dal.ConnectionString {
+	return &dal.ConnectionString{}
+}
+
+func (self *FirestoreBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *FirestoreBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *FirestoreBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.Name, id)
+}
+
+func (self *FirestoreBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.Name, id)
+}
+
+func (self *FirestoreBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *FirestoreBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Translates the filter into a Firestore query and calls resultFn with each matching document, reading
+// them in pages of IndexerPageSize.  Filters that Firestore cannot run (e.g.: those with range
+// criteria on more than one field) return an error saying why.
+func (self *FirestoreBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	defer timeOperation(`pivot.indexers.firestore.query_time`)()
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	query, err := self.firestoreQuery(collection, f)
+
+	if err != nil {
+		return err
+	}
+
+	var last *firestore.DocumentSnapshot
+	processed := 0
+	page := 1
+
+	for {
+		limit := IndexerPageSize
+
+		if f.Limit > 0 {
+			if remaining := f.Limit - processed; remaining <= 0 {
+				return nil
+			} else if remaining < limit {
+				limit = remaining
+			}
+		}
+
+		pageQuery := query.Limit(limit)
+
+		if last != nil {
+			pageQuery = pageQuery.StartAfter(last)
+		} else if f.Offset > 0 {
+			pageQuery = pageQuery.Offset(f.Offset)
+		}
+
+		ctx, cancel := self.context()
+		iter := pageQuery.Documents(ctx)
+		n := 0
+
+		for {
+			snapshot, err := iter.Next()
+
+			if err == iterator.Done {
+				break
+			} else if err != nil {
+				iter.Stop()
+				cancel()
+				return err
+			}
+
+			last = snapshot
+			processed++
+			n++
+
+			record, err := self.recordFromSnapshot(collection, snapshot)
+
+			// Firestore doesn't count the documents matching a query
+			if err := resultFn(record, err, IndexPage{
+				Page:         page,
+				Limit:        f.Limit,
+				Offset:       f.Offset,
+				TotalResults: -1,
+			}); err != nil {
+				iter.Stop()
+				cancel()
+				return queryStopped(err)
+			}
+		}
+
+		iter.Stop()
+		cancel()
+
+		if n < limit {
+			return nil
+		}
+
+		page++
+	}
+}
+
+func (self *FirestoreBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *FirestoreBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+	seen := make(map[string]map[string]bool)
+
+	err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			value := record.Get(field)
+
+			if field == collection.IdentityField {
+				value = record.ID
+			}
+
+			if seen[field] == nil {
+				seen[field] = make(map[string]bool)
+			}
+
+			if key := fmt.Sprintf("%v", value); !seen[field][key] {
+				seen[field][key] = true
+				values[field] = append(values[field], value)
+			}
+		}
+
+		return nil
+	})
+
+	return values, err
+}
+
+func (self *FirestoreBackend) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	return DefaultFacetsImplementation(self, collection, fields, f)
+}
+
+func (self *FirestoreBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		ids = append(ids, record.ID)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return deleteInChunks(self, collection.Name, ids)
+}
+
+func (self *FirestoreBackend) FlushIndex() error {
+	return nil
+}
+
+// Builds the Firestore query equivalent to the filter.  Firestore only runs queries it can answer from
+// a single index, so this returns an error for filters that:
+//
+//   - use an operator that Firestore has no equivalent for (e.g.: "contains" or "suffix"),
+//   - have range or inequality criteria on more than one field,
+//   - are sorted by a field other than the one with range or inequality criteria on it (first),
+//   - have more than one criterion with several values, or a "not" criterion alongside one,
+//   - or compare a field against more than FirestoreMaxDisjunctionValues values.
+func (self *FirestoreBackend) firestoreQuery(collection *dal.Collection, f *filter.Filter) (firestore.Query, error) {
+	query := self.client.Collection(collection.Name).Query
+	var rangeField string
+	var disjunctionField string
+	var hasNotEqual bool
+
+	for _, criterion := range f.Criteria {
+		path := self.firestoreFieldPath(collection, criterion.Field)
+		values := make([]interface{}, len(criterion.Values))
+
+		for i, value := range criterion.Values {
+			values[i] = self.firestoreValue(collection, criterion, value)
+		}
+
+		if len(values) == 0 {
+			return query, fmt.Errorf("No values given for criterion %v", criterion.Field)
+		}
+
+		var isRange bool
+
+		switch criterion.Operator {
+		case `is`, ``:
+			if len(values) == 1 {
+				query = query.Where(path, `==`, values[0])
+			} else {
+				query = query.Where(path, `in`, values)
+			}
+
+		case `not`:
+			isRange = true
+
+			if len(values) == 1 {
+				query = query.Where(path, `!=`, values[0])
+				hasNotEqual = true
+			} else {
+				query = query.Where(path, `not-in`, values)
+			}
+
+		case `gt`, `gte`, `lt`, `lte`:
+			if len(values) != 1 {
+				return query, dal.Unsupported("Firestore cannot compare field %v against several values with '%v'", criterion.Field, criterion.Operator)
+			}
+
+			isRange = true
+			query = query.Where(path, firestoreOperators[criterion.Operator], values[0])
+
+		case `range`:
+			if len(values) != 2 {
+				return query, dal.Unsupported("Firestore can only match field %v against a single range, %d values given", criterion.Field, len(values))
+			}
+
+			isRange = true
+			query = query.Where(path, `>=`, values[0]).Where(path, `<`, values[1])
+
+		case `prefix`:
+			if len(values) != 1 {
+				return query, dal.Unsupported("Firestore can only match field %v against a single prefix", criterion.Field)
+			}
+
+			prefix := fmt.Sprintf("%v", criterion.Values[0])
+			isRange = true
+			query = query.Where(path, `>=`, prefix).Where(path, `<`, prefix+firestorePrefixUpperBound)
+
+		default:
+			return query, dal.Unsupported("Firestore has no equivalent of the '%v' operator used on field %v", criterion.Operator, criterion.Field)
+		}
+
+		if len(values) > 1 && criterion.Operator != `range` {
+			if len(values) > FirestoreMaxDisjunctionValues {
+				return query, dal.Unsupported("Firestore can only compare field %v against up to %d values, %d given", criterion.Field, FirestoreMaxDisjunctionValues, len(values))
+			} else if disjunctionField != `` {
+				return query, dal.Unsupported("Firestore can only compare one field against several values per query, not both %v and %v", disjunctionField, criterion.Field)
+			}
+
+			disjunctionField = criterion.Field
+		}
+
+		if isRange {
+			if rangeField != `` && rangeField != criterion.Field {
+				return query, dal.Unsupported("Firestore can only have range and inequality criteria on one field per query, not both %v and %v", rangeField, criterion.Field)
+			}
+
+			rangeField = criterion.Field
+		}
+	}
+
+	if hasNotEqual && disjunctionField != `` {
+		return query, dal.Unsupported("Firestore cannot combine a 'not' criterion with one comparing field %v against several values", disjunctionField)
+	}
+
+	for i, sort := range f.GetSort() {
+		if i == 0 && rangeField != `` && sort.Field != rangeField {
+			return query, dal.Unsupported("Firestore queries with range or inequality criteria on field %v must be sorted by it first, not by %v", rangeField, sort.Field)
+		}
+
+		direction := firestore.Asc
+
+		if sort.Descending {
+			direction = firestore.Desc
+		}
+
+		query = query.OrderBy(self.firestoreFieldPath(collection, sort.Field), direction)
+	}
+
+	if len(f.Fields) > 0 {
+		query = query.Select(f.Fields...)
+	}
+
+	return query, nil
+}
+
+// the identity field is the document's name rather than one of its fields
+func (self *FirestoreBackend) firestoreFieldPath(collection *dal.Collection, field string) string {
+	if collection.IsIdentityField(field) {
+		return firestore.DocumentID
+	}
+
+	return field
+}
+
+// converts a criterion's value to the type of the field it's compared against, or to a reference to
+// the document it names when comparing IDs
+func (self *FirestoreBackend) firestoreValue(collection *dal.Collection, criterion filter.Criterion, value interface{}) interface{} {
+	if collection.IsIdentityField(criterion.Field) {
+		return self.document(collection, value)
+	} else if _, ok := collection.GetField(criterion.Field); ok {
+		return collection.ConvertValue(criterion.Field, value)
+	} else if _, ok := value.(string); ok && criterion.Operator != `prefix` {
+		return stringutil.Autotype(value)
+	}
+
+	return value
+}
+
+var firestoreOperators = map[string]string{
+	`gt`:  `>`,
+	`gte`: `>=`,
+	`lt`:  `<`,
+	`lte`: `<=`,
+}
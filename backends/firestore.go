@@ -0,0 +1,507 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The collection that the definitions of the collections created by this backend are stored in, with
+// one document per collection.
+var FirestoreSchemaCollection = `_pivot`
+
+// How long each request to Firestore may take before it is abandoned.
+var FirestoreRequestTimeout = 30 * time.Second
+
+// A Backend that stores each collection as a Firestore collection, with one document per record (whose
+// document ID is the record's ID.)  The update time of each document is kept in the Revision of the
+// records read from it, and records with a Revision are only updated if the document hasn't changed
+// since.
+//
+// Connection strings name the Google Cloud project the database belongs to (e.g.:
+// "firestore://my-project").  Credentials are found the same way as other Google Cloud clients do,
+// or are read from the file given in the "credentials" option.  The FIRESTORE_EMULATOR_HOST
+// environment variable is honored for testing against the Firestore emulator.
+type FirestoreBackend struct {
+	Backend
+	conn                  dal.ConnectionString
+	client                *firestore.Client
+	indexer               Indexer
+	registeredCollections sync.Map
+}
+
+func NewFirestoreBackend(connection dal.ConnectionString) Backend {
+	return &FirestoreBackend{
+		conn: connection,
+	}
+}
+
+func (self *FirestoreBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *FirestoreBackend) Initialize() error {
+	options := make([]option.ClientOption, 0)
+
+	if filename := self.conn.OptString(`credentials`, ``); filename != `` {
+		options = append(options, option.WithCredentialsFile(filename))
+	}
+
+	project := sliceutil.OrString(self.conn.Host(), firestore.DetectProjectID)
+
+	if client, err := firestore.NewClient(context.Background(), project, options...); err == nil {
+		self.client = client
+	} else {
+		return fmt.Errorf("Cannot connect to Firestore: %v", err)
+	}
+
+	if err := self.Ping(DefaultConnectTimeout); err != nil {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	return self.indexer.IndexInitialize(self)
+}
+
+func (self *FirestoreBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *FirestoreBackend) RegisterCollection(collection *dal.Collection) {
+	if collection != nil {
+		self.registeredCollections.Store(collection.Name, collection)
+	}
+}
+
+func (self *FirestoreBackend) Ping(timeout time.Duration) error {
+	if self.client == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if _, err := self.client.Collections(ctx).Next(); err != nil && err != iterator.Done {
+		return fmt.Errorf("Backend unavailable: %v", err)
+	}
+
+	return nil
+}
+
+func (self *FirestoreBackend) Exists(name string, id interface{}) bool {
+	if collection, err := self.GetCollection(name); err == nil {
+		if snapshot, err := self.getDocument(collection, id); err == nil && snapshot != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *FirestoreBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		if snapshot, err := self.getDocument(collection, id); err == nil {
+			if snapshot == nil {
+				return nil, dal.RecordNotFound(id)
+			}
+
+			if record, err := self.recordFromSnapshot(collection, snapshot); err == nil {
+				if len(fields) > 0 {
+					for key := range record.Fields {
+						if !sliceutil.ContainsString(fields, key) {
+							delete(record.Fields, key)
+						}
+					}
+				}
+
+				return record, nil
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Creates a document for each record, failing if one already exists.  Records without an ID are given
+// one generated by Firestore if the collection's identity is a string.
+func (self *FirestoreBackend) Insert(name string, recordset *dal.RecordSet) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		for _, record := range recordset.Records {
+			if r, err := collection.MakeRecord(record); err == nil {
+				record = r
+			} else {
+				return err
+			}
+
+			var doc *firestore.DocumentRef
+
+			if record.ID != nil {
+				doc = self.document(collection, record.ID)
+			} else if collection.IdentityFieldType == dal.StringType {
+				doc = self.client.Collection(collection.Name).NewDoc()
+				record.ID = doc.ID
+			} else {
+				return fmt.Errorf("Cannot write a record without an ID to %v", collection.Name)
+			}
+
+			ctx, cancel := self.context()
+			result, err := doc.Create(ctx, self.documentData(collection, record))
+			cancel()
+
+			if err == nil {
+				record.Revision = firestoreRevision(result.UpdateTime)
+			} else if status.Code(err) == codes.AlreadyExists {
+				return dal.UniqueViolation(fmt.Errorf("Record %v already exists", record.ID))
+			} else {
+				return err
+			}
+		}
+
+		return self.index(collection, recordset)
+	} else {
+		return err
+	}
+}
+
+// Merges each record's fields into its document, creating it if it doesn't exist.  Records with a
+// Revision are only written if the document hasn't been changed since that revision was read.
+func (self *FirestoreBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		for _, record := range recordset.Records {
+			if r, err := collection.MakeRecord(record); err == nil {
+				record = r
+			} else {
+				return err
+			}
+
+			if record.ID == nil {
+				return fmt.Errorf("Cannot write a record without an ID to %v", collection.Name)
+			}
+
+			if err := self.update(collection, record); err != nil {
+				return err
+			}
+		}
+
+		return self.index(collection, recordset)
+	} else {
+		return err
+	}
+}
+
+func (self *FirestoreBackend) update(collection *dal.Collection, record *dal.Record) error {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	doc := self.document(collection, record.ID)
+	data := self.documentData(collection, record)
+
+	var result *firestore.WriteResult
+	var err error
+
+	if record.Revision != `` {
+		if updateTime, perr := time.Parse(time.RFC3339Nano, record.Revision); perr == nil {
+			updates := make([]firestore.Update, 0, len(data))
+
+			for key, value := range data {
+				updates = append(updates, firestore.Update{
+					FieldPath: firestore.FieldPath{key},
+					Value:     value,
+				})
+			}
+
+			result, err = doc.Update(ctx, updates, firestore.LastUpdateTime(updateTime))
+
+			switch status.Code(err) {
+			case codes.FailedPrecondition, codes.NotFound:
+				return dal.StaleRecord(record.ID)
+			}
+		} else {
+			return fmt.Errorf("Invalid revision %q: %v", record.Revision, perr)
+		}
+	} else {
+		result, err = doc.Set(ctx, data, firestore.MergeAll)
+	}
+
+	if err == nil {
+		record.Revision = firestoreRevision(result.UpdateTime)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *FirestoreBackend) Delete(name string, ids ...interface{}) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		for _, id := range ids {
+			ctx, cancel := self.context()
+			_, err := self.document(collection, id).Delete(ctx)
+			cancel()
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if search := self.WithSearch(collection); search != nil {
+			return search.IndexRemove(collection, ids)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Firestore creates collections when documents are first written to them, so this only stores the
+// collection definition.
+func (self *FirestoreBackend) CreateCollection(definition *dal.Collection) error {
+	if _, err := self.GetCollection(definition.Name); err == nil {
+		return fmt.Errorf("Collection %v already exists", definition.Name)
+	} else if !dal.IsCollectionNotFoundErr(err) {
+		return err
+	}
+
+	if data, err := json.Marshal(definition); err == nil {
+		ctx, cancel := self.context()
+		defer cancel()
+
+		if _, err := self.client.Collection(FirestoreSchemaCollection).Doc(definition.Name).Set(ctx, map[string]interface{}{
+			`collection`: string(data),
+		}); err != nil {
+			return fmt.Errorf("Failed to store definition of collection %v: %v", definition.Name, err)
+		}
+	} else {
+		return err
+	}
+
+	self.RegisterCollection(definition)
+	return nil
+}
+
+// Deletes every document in the collection (Firestore has no way of deleting a collection outright),
+// reading them in pages of IndexerPageSize, and then the collection definition.
+func (self *FirestoreBackend) DeleteCollection(name string) error {
+	if _, err := self.GetCollection(name); err != nil {
+		return err
+	}
+
+	for {
+		ctx, cancel := self.context()
+		snapshots, err := self.client.Collection(name).Limit(IndexerPageSize).Documents(ctx).GetAll()
+
+		if err == nil {
+			for _, snapshot := range snapshots {
+				if _, err := snapshot.Ref.Delete(ctx); err != nil {
+					cancel()
+					return err
+				}
+			}
+		}
+
+		cancel()
+
+		if err != nil {
+			return err
+		} else if len(snapshots) < IndexerPageSize {
+			break
+		}
+	}
+
+	ctx, cancel := self.context()
+	defer cancel()
+
+	if _, err := self.client.Collection(FirestoreSchemaCollection).Doc(name).Delete(ctx); err != nil {
+		return err
+	}
+
+	self.registeredCollections.Delete(name)
+	return nil
+}
+
+// Lists every collection at the root of the database, except for the one collection definitions are
+// stored in.
+func (self *FirestoreBackend) ListCollections() ([]string, error) {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	names := make([]string, 0)
+
+	if collections, err := self.client.Collections(ctx).GetAll(); err == nil {
+		for _, collection := range collections {
+			if collection.ID != FirestoreSchemaCollection {
+				names = append(names, collection.ID)
+			}
+		}
+	} else {
+		return nil, err
+	}
+
+	// collections created by this backend exist before any records are written to them
+	if snapshots, err := self.client.Collection(FirestoreSchemaCollection).Documents(ctx).GetAll(); err == nil {
+		for _, snapshot := range snapshots {
+			if !sliceutil.ContainsString(names, snapshot.Ref.ID) {
+				names = append(names, snapshot.Ref.ID)
+			}
+		}
+	} else {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Returns the stored definition of the named collection, or a collection with a string identity (and
+// no other fields) for collections that were not created by this backend but contain documents.
+func (self *FirestoreBackend) GetCollection(name string) (*dal.Collection, error) {
+	if c, ok := self.registeredCollections.Load(name); ok {
+		return c.(*dal.Collection), nil
+	}
+
+	ctx, cancel := self.context()
+	defer cancel()
+
+	collection := dal.NewCollection(name)
+
+	if snapshot, err := self.client.Collection(FirestoreSchemaCollection).Doc(name).Get(ctx); err == nil {
+		definition, _ := snapshot.Data()[`collection`].(string)
+
+		if err := json.Unmarshal([]byte(definition), collection); err != nil {
+			return nil, fmt.Errorf("Invalid definition of collection %v: %v", name, err)
+		}
+	} else if status.Code(err) == codes.NotFound {
+		// collections only exist in Firestore while they contain documents
+		if snapshots, err := self.client.Collection(name).Limit(1).Documents(ctx).GetAll(); err == nil {
+			if len(snapshots) == 0 {
+				return nil, dal.CollectionNotFound
+			}
+		} else {
+			return nil, err
+		}
+
+		collection.IdentityFieldType = dal.StringType
+	} else {
+		return nil, err
+	}
+
+	self.RegisterCollection(collection)
+	return collection, nil
+}
+
+func (self *FirestoreBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *FirestoreBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return nil
+}
+
+func (self *FirestoreBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+func (self *FirestoreBackend) index(collection *dal.Collection, recordset *dal.RecordSet) error {
+	if !collection.SkipIndexPersistence {
+		if search := self.WithSearch(collection); search != nil {
+			return search.Index(collection, recordset)
+		}
+	}
+
+	return nil
+}
+
+func (self *FirestoreBackend) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), FirestoreRequestTimeout)
+}
+
+func (self *FirestoreBackend) document(collection *dal.Collection, id interface{}) *firestore.DocumentRef {
+	return self.client.Collection(collection.Name).Doc(fmt.Sprintf("%v", id))
+}
+
+// returns a snapshot of the document with the given ID, or nil if it doesn't exist
+func (self *FirestoreBackend) getDocument(collection *dal.Collection, id interface{}) (*firestore.DocumentSnapshot, error) {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	if snapshot, err := self.document(collection, id).Get(ctx); err == nil {
+		return snapshot, nil
+	} else if status.Code(err) == codes.NotFound {
+		return nil, nil
+	} else {
+		return nil, err
+	}
+}
+
+// returns the fields of the record as they are stored in its document (the ID is the document's name,
+// and so isn't stored with the rest of the fields.)  Geometries are stored as Well-Known Text, since
+// Firestore cannot store the nested arrays of their GeoJSON coordinates.
+func (self *FirestoreBackend) documentData(collection *dal.Collection, record *dal.Record) map[string]interface{} {
+	data := make(map[string]interface{})
+
+	for k, v := range convertGeometryFields(collection, record.Fields, toWKT) {
+		if !collection.IsIdentityField(k) {
+			data[k] = v
+		}
+	}
+
+	return data
+}
+
+func (self *FirestoreBackend) recordFromSnapshot(collection *dal.Collection, snapshot *firestore.DocumentSnapshot) (*dal.Record, error) {
+	record := dal.NewRecord(snapshot.Ref.ID)
+	record.Revision = firestoreRevision(snapshot.UpdateTime)
+
+	for k, v := range snapshot.Data() {
+		record.Set(k, v)
+	}
+
+	if collection.IdentityFieldType != dal.StringType {
+		record.ID = stringutil.Autotype(record.ID)
+	}
+
+	// do this AFTER populating the record's fields from the document
+	if err := record.Populate(record, collection); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// revisions are the time the document was last updated, which Firestore reports to the microsecond
+func firestoreRevision(updateTime time.Time) string {
+	if updateTime.IsZero() {
+		return ``
+	}
+
+	return updateTime.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,153 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a backend whose client is never connected, which is enough to build queries and documents
+func newOfflineFirestore() (*FirestoreBackend, error) {
+	os.Setenv(`FIRESTORE_EMULATOR_HOST`, `127.0.0.1:1`)
+	defer os.Unsetenv(`FIRESTORE_EMULATOR_HOST`)
+
+	if client, err := firestore.NewClient(context.Background(), `test`); err == nil {
+		return &FirestoreBackend{
+			client: client,
+		}, nil
+	} else {
+		return nil, err
+	}
+}
+
+func newFirestoreUsers() *dal.Collection {
+	collection := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name: `location`,
+		Type: dal.PointType,
+	})
+
+	collection.IdentityFieldType = dal.IntType
+	return collection
+}
+
+func TestFirestoreQuery(t *testing.T) {
+	assert := require.New(t)
+
+	backend, err := newOfflineFirestore()
+	assert.NoError(err)
+	defer backend.client.Close()
+
+	collection := newFirestoreUsers()
+	users := backend.client.Collection(`users`)
+
+	// IDs are compared as references to the documents they name
+	query, err := backend.firestoreQuery(collection, filter.MustParse(`id/1`))
+	assert.NoError(err)
+	assert.Equal(users.Where(firestore.DocumentID, `==`, users.Doc(`1`)), query)
+
+	query, err = backend.firestoreQuery(collection, filter.MustParse(`name/alice|bob/age/gte:21`))
+	assert.NoError(err)
+	assert.Equal(
+		users.Where(`name`, `in`, []interface{}{`alice`, `bob`}).Where(`age`, `>=`, collection.ConvertValue(`age`, 21)),
+		query,
+	)
+
+	query, err = backend.firestoreQuery(collection, filter.MustParse(`name/prefix:al`))
+	assert.NoError(err)
+	assert.Equal(users.Where(`name`, `>=`, `al`).Where(`name`, `<`, "al"), query)
+
+	query, err = backend.firestoreQuery(collection, filter.MustParse(`age/range:18|65`))
+	assert.NoError(err)
+	assert.Equal(
+		users.Where(`age`, `>=`, collection.ConvertValue(`age`, 18)).Where(`age`, `<`, collection.ConvertValue(`age`, 65)),
+		query,
+	)
+
+	// queries with range criteria must be sorted by that field first
+	f := filter.MustParse(`age/gt:21`)
+	f.Sort = []string{`-age`, `name`}
+	f.Fields = []string{`name`}
+
+	query, err = backend.firestoreQuery(collection, f)
+	assert.NoError(err)
+	assert.Equal(
+		users.Where(`age`, `>`, collection.ConvertValue(`age`, 21)).OrderBy(`age`, firestore.Desc).OrderBy(`name`, firestore.Asc).Select(`name`),
+		query,
+	)
+
+	f.Sort = []string{`name`}
+	_, err = backend.firestoreQuery(collection, f)
+	assert.True(errors.Is(err, dal.ErrUnsupported))
+
+	// filters Firestore can't run from a single index are refused
+	for _, spec := range []string{
+		`name/contains:li`,
+		`name/suffix:ce`,
+		`age/gt:21/name/lt:m`,
+		`name/alice|bob/age/21|31`,
+		`name/not:alice/age/21|31`,
+		`age/range:1|2|3`,
+		`age/` + strings.Repeat(`1|`, FirestoreMaxDisjunctionValues) + `1`,
+	} {
+		_, err := backend.firestoreQuery(collection, filter.MustParse(spec))
+		assert.True(errors.Is(err, dal.ErrUnsupported), spec)
+	}
+}
+
+func TestFirestoreDocumentData(t *testing.T) {
+	assert := require.New(t)
+
+	backend, err := newOfflineFirestore()
+	assert.NoError(err)
+	defer backend.client.Close()
+
+	collection := newFirestoreUsers()
+
+	// the ID names the document rather than being stored in it, and geometries are stored as text
+	assert.Equal(map[string]interface{}{
+		`name`:     `alice`,
+		`location`: `POINT(-74.0445 40.6892)`,
+	}, backend.documentData(collection, dal.NewRecord(1).Set(`name`, `alice`).Set(`location`, `40.6892,-74.0445`)))
+
+	doc := backend.document(collection, 1)
+	assert.Equal(`users`, doc.Parent.ID)
+	assert.Equal(`1`, doc.ID)
+}
+
+func TestFirestoreRevision(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(``, firestoreRevision(time.Time{}))
+
+	at := time.Date(2020, 11, 3, 12, 30, 15, 123456000, time.FixedZone(`EST`, -5*60*60))
+	revision := firestoreRevision(at)
+	assert.Equal(`2020-11-03T17:30:15.123456Z`, revision)
+
+	// revisions are parsed back into the precondition of later updates
+	parsed, err := time.Parse(time.RFC3339Nano, revision)
+	assert.NoError(err)
+	assert.True(at.Equal(parsed))
+}
+
+func TestFirestoreNotInitialized(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`firestore://test`)
+	assert.NoError(err)
+
+	assert.Error(NewFirestoreBackend(cs).Ping(time.Second))
+}
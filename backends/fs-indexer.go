@@ -24,7 +24,7 @@ func (self *FilesystemBackend) IndexExists(collection *dal.Collection, id interf
 }
 
 func (self *FilesystemBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
-	defer stats.NewTiming().Send(`pivot.indexers.filesystem.retrieve_time`)
+	defer timeOperation(`pivot.indexers.filesystem.retrieve_time`)()
 	return self.Retrieve(collection.GetIndexName(), id)
 }
 
@@ -37,7 +37,7 @@ func (self *FilesystemBackend) Index(collection *dal.Collection, records *dal.Re
 }
 
 func (self *FilesystemBackend) QueryFunc(collection *dal.Collection, filter *filter.Filter, resultFn IndexResultFunc) error {
-	defer stats.NewTiming().Send(`pivot.indexers.filesystem.query_time`)
+	defer timeOperation(`pivot.indexers.filesystem.query_time`)()
 	querylog.Debugf("[%T] Query using filter %q", self, filter.String())
 
 	if filter.IdOnly() {
@@ -52,7 +52,7 @@ func (self *FilesystemBackend) QueryFunc(collection *dal.Collection, filter *fil
 					Offset:       0,
 					TotalResults: 1,
 				}); err != nil {
-					return err
+					return queryStopped(err)
 				}
 			} else {
 				return err
@@ -81,7 +81,7 @@ func (self *FilesystemBackend) QueryFunc(collection *dal.Collection, filter *fil
 								Offset:       offset,
 								TotalResults: -1,
 							}); err != nil {
-								return err
+								return queryStopped(err)
 							}
 						}
 					}
@@ -93,7 +93,7 @@ func (self *FilesystemBackend) QueryFunc(collection *dal.Collection, filter *fil
 						Offset:       offset,
 						TotalResults: -1,
 					}); err != nil {
-						return err
+						return queryStopped(err)
 					}
 				}
 
@@ -158,6 +158,10 @@ func (self *FilesystemBackend) ListValues(collection *dal.Collection, fields []s
 	}
 }
 
+func (self *FilesystemBackend) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	return DefaultFacetsImplementation(self, collection, fields, f)
+}
+
 func (self *FilesystemBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
 	idsToRemove := make([]interface{}, 0)
 
@@ -168,7 +172,7 @@ func (self *FilesystemBackend) DeleteQuery(collection *dal.Collection, f *filter
 
 		return nil
 	}); err == nil {
-		return self.Delete(collection.Name, idsToRemove...)
+		return deleteInChunks(self, collection.Name, idsToRemove)
 	} else {
 		return err
 	}
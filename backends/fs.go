@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ghetzel/go-stockutil/pathutil"
@@ -39,18 +40,17 @@ type FilesystemBackend struct {
 	format                SerializationFormat
 	indexer               Indexer
 	aggregator            map[string]Aggregator
-	registeredCollections map[string]*dal.Collection
+	registeredCollections sync.Map
 	recordSubdir          string
 	recordCache           *lru.ARCCache
 }
 
 func NewFilesystemBackend(connection dal.ConnectionString) Backend {
 	return &FilesystemBackend{
-		conn:                  connection,
-		format:                FormatYAML,
-		aggregator:            make(map[string]Aggregator),
-		registeredCollections: make(map[string]*dal.Collection),
-		recordSubdir:          DefaultFilesystemRecordSubdirectory,
+		conn:         connection,
+		format:       FormatYAML,
+		aggregator:   make(map[string]Aggregator),
+		recordSubdir: DefaultFilesystemRecordSubdirectory,
 	}
 }
 
@@ -79,7 +79,7 @@ func (self *FilesystemBackend) Ping(timeout time.Duration) error {
 }
 
 func (self *FilesystemBackend) RegisterCollection(collection *dal.Collection) {
-	self.registeredCollections[collection.Name] = collection
+	self.registeredCollections.Store(collection.Name, collection)
 }
 
 func (self *FilesystemBackend) SetIndexer(indexConnString dal.ConnectionString) error {
@@ -158,7 +158,10 @@ func (self *FilesystemBackend) Initialize() error {
 func (self *FilesystemBackend) Insert(collectionName string, recordset *dal.RecordSet) error {
 	for _, record := range recordset.Records {
 		if self.Exists(collectionName, record.ID) {
-			return fmt.Errorf("Record %q already exists", record.ID)
+			return &dal.Error{
+				Kind:    dal.ErrUniqueViolation,
+				Message: fmt.Sprintf("Record %q already exists", record.ID),
+			}
 		}
 	}
 
@@ -314,11 +317,11 @@ func (self *FilesystemBackend) GetCollection(name string) (*dal.Collection, erro
 	var v map[string]interface{}
 	var collection *dal.Collection
 
-	if c, ok := self.registeredCollections[name]; ok {
-		collection = c
+	if c, ok := self.registeredCollections.Load(name); ok {
+		collection = c.(*dal.Collection)
 	} else if c, err := self.readSchemaFromDisk(name); err == nil {
 		collection = c
-		self.registeredCollections[name] = collection
+		self.registeredCollections.Store(name, collection)
 	} else if dal.IsCollectionNotFoundErr(err) {
 		return nil, err
 	}
@@ -344,25 +347,36 @@ func (self *FilesystemBackend) Flush() error {
 	return nil
 }
 
+// Reads the collection's schema definition, which is written in the backend's serialization format.
+// Definitions written as JSON are also read regardless of format, since that's how they were
+// always stored (and how they may be written by hand for CSV-formatted collections.)
 func (self *FilesystemBackend) readSchemaFromDisk(name string) (*dal.Collection, error) {
-	schemaDesc := filepath.Join(self.root, name, `schema.json`)
+	filenames := []string{self.makeFilename(nil, `schema`, false)}
 
-	querylog.Debugf("[%T] Read schema definition at %v", self, schemaDesc)
+	if filenames[0] != `schema.json` {
+		filenames = append(filenames, `schema.json`)
+	}
 
-	if file, err := os.Open(schemaDesc); err == nil {
-		defer file.Close()
-		var schema dal.Collection
+	for _, filename := range filenames {
+		schemaDesc := filepath.Join(self.root, name, filename)
 
-		if err := json.NewDecoder(file).Decode(&schema); err == nil {
-			return &schema, nil
-		} else {
+		querylog.Debugf("[%T] Read schema definition at %v", self, schemaDesc)
+
+		if data, err := ioutil.ReadFile(schemaDesc); err == nil {
+			var schema dal.Collection
+
+			// YAML is converted to JSON before being decoded, so it decodes JSON as well
+			if err := yaml.Unmarshal(data, &schema); err == nil {
+				return &schema, nil
+			} else {
+				return nil, err
+			}
+		} else if !os.IsNotExist(err) {
 			return nil, err
 		}
-	} else if os.IsNotExist(err) {
-		return nil, dal.CollectionNotFound
-	} else {
-		return nil, err
 	}
+
+	return nil, dal.CollectionNotFound
 }
 
 func (self *FilesystemBackend) getDataRoot(collectionName string, isData bool) (string, error) {
@@ -435,7 +449,7 @@ func (self *FilesystemBackend) writeObject(collection *dal.Collection, id string
 				}
 
 			case FormatCSV:
-				return fmt.Errorf("Not Implemented")
+				return NotImplementedError
 			}
 
 			lockfilename := filepath.Join(dataRoot, fmt.Sprintf(WriteLockFormat, id))
@@ -535,7 +549,7 @@ func (self *FilesystemBackend) readObject(collection *dal.Collection, id string,
 						}
 
 					case FormatCSV:
-						return fmt.Errorf("Not Implemented")
+						return NotImplementedError
 					}
 				} else {
 					return err
@@ -547,7 +561,7 @@ func (self *FilesystemBackend) readObject(collection *dal.Collection, id string,
 				}
 
 				if isData {
-					return fmt.Errorf("Record %q does not exist", id)
+					return dal.RecordNotFound(fmt.Sprintf("%q", id))
 				} else {
 					return fmt.Errorf("File %q does not exist", objPath)
 				}
@@ -565,7 +579,9 @@ func (self *FilesystemBackend) readObject(collection *dal.Collection, id string,
 }
 
 func (self *FilesystemBackend) prepareIncomingRecord(collectionName string, record *dal.Record) error {
-	if collection, ok := self.registeredCollections[collectionName]; ok {
+	if c, ok := self.registeredCollections.Load(collectionName); ok {
+		collection := c.(*dal.Collection)
+
 		if collection.IdentityFieldType != dal.StringType {
 			record.ID = stringutil.Autotype(record.ID)
 		}
@@ -0,0 +1,49 @@
+package backends
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// collections may be registered (e.g.: by a config reload) while requests are being served
+func TestFilesystemRegisterCollectionConcurrently(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`fs:///tmp/pivot-fs-test`)
+	assert.NoError(err)
+
+	backend := NewFilesystemBackend(cs).(*FilesystemBackend)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				name := fmt.Sprintf("collection%d", j%4)
+
+				backend.RegisterCollection(dal.NewCollection(name))
+
+				if _, err := backend.GetCollection(name); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(err)
+	}
+}
@@ -0,0 +1,63 @@
+package backends
+
+import (
+	"github.com/ghetzel/pivot/dal"
+)
+
+// Returns the given record fields with the values of the collection's geometry fields passed
+// through the given function (e.g.: to give them to a document store in the form its spatial
+// indexes expect).  Values that aren't valid geometries are left as they are, for the backend to
+// reject.  The fields are copied rather than modified if any of them are converted.
+func convertGeometryFields(collection *dal.Collection, fields map[string]interface{}, convert func(interface{}) interface{}) map[string]interface{} {
+	var out map[string]interface{}
+
+	for _, field := range collection.Fields {
+		if !field.Type.IsGeometry() {
+			continue
+		}
+
+		if value, ok := fields[field.Name]; ok && value != nil {
+			if geometry, err := field.ConvertValue(value); err == nil && geometry != nil {
+				if out == nil {
+					out = make(map[string]interface{}, len(fields))
+
+					for k, v := range fields {
+						out[k] = v
+					}
+				}
+
+				out[field.Name] = convert(geometry)
+			}
+		}
+	}
+
+	if out == nil {
+		return fields
+	}
+
+	return out
+}
+
+// converts points and polygons into GeoJSON geometries
+func toGeoJSON(geometry interface{}) interface{} {
+	switch g := geometry.(type) {
+	case dal.Point:
+		return g.GeoJSON()
+	case dal.Polygon:
+		return g.GeoJSON()
+	default:
+		return geometry
+	}
+}
+
+// converts points and polygons into Well-Known Text
+func toWKT(geometry interface{}) interface{} {
+	switch g := geometry.(type) {
+	case dal.Point:
+		return g.WKT()
+	case dal.Polygon:
+		return g.WKT()
+	default:
+		return geometry
+	}
+}
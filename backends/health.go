@@ -0,0 +1,20 @@
+package backends
+
+import (
+	"time"
+)
+
+// Implemented by indexers that can verify that their underlying service is reachable.
+type IndexPinger interface {
+	IndexPing(time.Duration) error
+}
+
+// Implemented by backends that maintain a pool of connections and can report on its utilization.
+type PoolStatter interface {
+	PoolStats() map[string]interface{}
+}
+
+// Implemented by indexers that queue index operations to be performed asynchronously.
+type IndexQueuer interface {
+	IndexQueueDepth() int
+}
@@ -0,0 +1,48 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/pivot/dal"
+)
+
+// The languages that indexers can apply stemming and stopword removal for, keyed by their short
+// code.  Languages may be specified (via the "index_language" collection or field property) using
+// either their code or their English name.
+var IndexLanguages = map[string]string{
+	`cjk`: `cjk`,
+	`de`:  `german`,
+	`en`:  `english`,
+	`es`:  `spanish`,
+	`fr`:  `french`,
+	`it`:  `italian`,
+	`pt`:  `portuguese`,
+}
+
+// Returns the short code for the language that should be used to analyze the given field, which is
+// taken from the field itself or (failing that) the collection.  An empty string is returned if no
+// language is configured.
+func indexLanguageFor(collection *dal.Collection, field *dal.Field) (string, error) {
+	var lang string
+
+	if field != nil && field.IndexLanguage != `` {
+		lang = field.IndexLanguage
+	} else if collection != nil {
+		lang = collection.IndexLanguage
+	}
+
+	if lang == `` {
+		return ``, nil
+	}
+
+	lang = strings.ToLower(lang)
+
+	for code, name := range IndexLanguages {
+		if lang == code || lang == name {
+			return code, nil
+		}
+	}
+
+	return ``, fmt.Errorf("Unsupported index language %q", lang)
+}
@@ -162,7 +162,10 @@ func (self *MultiIndex) IndexRetrieve(collection *dal.Collection, id interface{}
 	}
 
 	if record == nil {
-		return nil, fmt.Errorf("Index document %v/%v does not exist", collection, id)
+		return nil, &dal.Error{
+			Kind:    dal.ErrRecordNotFound,
+			Message: fmt.Sprintf("Index document %v/%v does not exist", collection, id),
+		}
 	}
 
 	return record, nil
@@ -285,6 +288,29 @@ func (self *MultiIndex) ListValues(collection *dal.Collection, fields []string,
 	return values, indexErr
 }
 
+func (self *MultiIndex) Facets(collection *dal.Collection, fields []string, filter *filter.Filter) (map[string][]FacetBucket, error) {
+	var facets map[string][]FacetBucket
+	var indexErr error
+
+	// counts from different indexers can't be meaningfully combined, so use the first that succeeds
+	if err := self.EachSelectedIndex(collection, RetrieveOperation, func(indexer Indexer, _ int, _ int) error {
+		if kv, err := indexer.Facets(collection, fields, filter); err == nil {
+			facets = kv
+			indexErr = nil
+			return IndexerResultsStop
+		} else {
+			indexErr = err
+			querylog.Debugf("MultiIndex: Indexer facets %v/%v failed: %v", indexer, collection, err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return facets, indexErr
+}
+
 func (self *MultiIndex) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
 	var indexErr error
 
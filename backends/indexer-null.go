@@ -48,6 +48,10 @@ func (self *NullIndexer) ListValues(collection *dal.Collection, fields []string,
 	return nil, NotImplementedError
 }
 
+func (self *NullIndexer) Facets(collection *dal.Collection, fields []string, filter filter.Filter) (map[string][]FacetBucket, error) {
+	return nil, NotImplementedError
+}
+
 func (self *NullIndexer) DeleteQuery(collection *dal.Collection, f filter.Filter) error {
 	return NotImplementedError
 }
@@ -1,6 +1,7 @@
 package backends
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strings"
@@ -13,6 +14,10 @@ var IndexerPageSize int = 100
 var MaxFacetCardinality int = 10000
 var DefaultCompoundJoiner = `:`
 
+// The largest number of documents removed from an index (or records deleted by DeleteQuery) in a
+// single request, so that mass deletions are neither sent one-by-one nor all at once.
+var IndexRemoveChunkSize = 1000
+
 type IndexPage struct {
 	Page         int
 	TotalPages   int
@@ -23,6 +28,10 @@ type IndexPage struct {
 
 type IndexResultFunc func(record *dal.Record, err error, page IndexPage) error // {}
 
+// An IndexResultFunc may return this to stop a query early (for example, once it has found what it was
+// looking for).  QueryFunc stops reading results, releases any open cursor, and returns nil.
+var IndexResultsStop = fmt.Errorf(`stop index results`)
+
 type Indexer interface {
 	IndexConnectionString() *dal.ConnectionString
 	IndexInitialize(Backend) error
@@ -33,6 +42,7 @@ type Indexer interface {
 	QueryFunc(collection *dal.Collection, filter *filter.Filter, resultFn IndexResultFunc) error
 	Query(collection *dal.Collection, filter *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error)
 	ListValues(collection *dal.Collection, fields []string, filter *filter.Filter) (map[string][]interface{}, error)
+	Facets(collection *dal.Collection, fields []string, filter *filter.Filter) (map[string][]FacetBucket, error)
 	DeleteQuery(collection *dal.Collection, f *filter.Filter) error
 	FlushIndex() error
 	GetBackend() Backend
@@ -51,13 +61,38 @@ func MakeIndexer(connection dal.ConnectionString) (Indexer, error) {
 	}
 }
 
+// Performs QueryFunc on the given indexer, stopping as soon as the context is cancelled rather than
+// reading every remaining result.  The context's error is returned if the query was cut short.
+func QueryContext(ctx context.Context, indexer Indexer, collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if err := indexer.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		return resultFn(record, err, page)
+	}); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// returns nil if the given error came from an IndexResultFunc asking to stop the query
+func queryStopped(err error) error {
+	if err == IndexResultsStop {
+		return nil
+	}
+
+	return err
+}
+
 func PopulateRecordSetPageDetails(recordset *dal.RecordSet, f *filter.Filter, page IndexPage) {
 	// result count is whatever we were told it was for this query
 	if page.TotalResults >= 0 {
 		recordset.KnownSize = true
 		recordset.ResultCount = page.TotalResults
 	} else {
-		recordset.ResultCount = int64(len(recordset.Records))
+		recordset.ResultCount = int64(recordset.Len())
 	}
 
 	if page.TotalPages > 0 {
@@ -82,6 +117,9 @@ func PopulateRecordSetPageDetails(recordset *dal.RecordSet, f *filter.Filter, pa
 func DefaultQueryImplementation(indexer Indexer, collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
 	recordset := dal.NewRecordSet()
 
+	// if enabled, keep unbounded queries from holding every result in memory
+	recordset.SetMemoryLimit(dal.DefaultMaxInMemoryRecords, collection)
+
 	if err := indexer.QueryFunc(collection, f, func(indexRecord *dal.Record, err error, page IndexPage) error {
 		defer PopulateRecordSetPageDetails(recordset, f, page)
 
@@ -138,26 +176,28 @@ func DefaultQueryImplementation(indexer Indexer, collection *dal.Collection, f *
 				return resultFn(emptyRecord, err, page)
 			} else if parent != nil && !forceIndexRecord {
 				if record, err := parent.Retrieve(collection.Name, indexRecord.ID, f.Fields...); err == nil {
+					emptyRecord.Release()
 					return resultFn(record, err, page)
 				} else {
 					return resultFn(emptyRecord, err, page)
 				}
 			} else {
+				emptyRecord.Release()
 				return resultFn(indexRecord, err, page)
 			}
 		} else {
 			if f.IdOnly() {
-				recordset.Records = append(recordset.Records, dal.NewRecord(indexRecord.ID))
+				recordset.Push(dal.NewRecord(indexRecord.ID))
 
 			} else if parent != nil && !forceIndexRecord {
 				if record, err := parent.Retrieve(collection.Name, indexRecord.ID, f.Fields...); err == nil {
-					recordset.Records = append(recordset.Records, record)
+					recordset.Push(record)
 
 				} else {
-					recordset.Records = append(recordset.Records, dal.NewRecordErr(indexRecord.ID, err))
+					recordset.Push(dal.NewRecordErr(indexRecord.ID, err))
 				}
 			} else {
-				recordset.Records = append(recordset.Records, indexRecord)
+				recordset.Push(indexRecord)
 			}
 
 			return nil
@@ -168,3 +208,23 @@ func DefaultQueryImplementation(indexer Indexer, collection *dal.Collection, f *
 
 	return recordset, nil
 }
+
+// Deletes the records with the given IDs from the named collection, at most IndexRemoveChunkSize
+// at a time.
+func deleteInChunks(backend Backend, name string, ids []interface{}) error {
+	for len(ids) > 0 {
+		n := len(ids)
+
+		if IndexRemoveChunkSize > 0 && n > IndexRemoveChunkSize {
+			n = IndexRemoveChunkSize
+		}
+
+		if err := backend.Delete(name, ids[:n]...); err != nil {
+			return err
+		}
+
+		ids = ids[n:]
+	}
+
+	return nil
+}
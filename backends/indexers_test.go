@@ -0,0 +1,69 @@
+package backends
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteInChunks(t *testing.T) {
+	assert := require.New(t)
+
+	defer func(size int) {
+		IndexRemoveChunkSize = size
+	}(IndexRemoveChunkSize)
+
+	IndexRemoveChunkSize = 3
+
+	mock := NewMockBackend()
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`widgets`)))
+
+	ids := make([]interface{}, 0)
+
+	for i := 0; i < 10; i++ {
+		ids = append(ids, fmt.Sprintf("%d", i))
+		assert.NoError(mock.Insert(`widgets`, dal.NewRecordSet(dal.NewRecord(ids[i]))))
+	}
+
+	assert.NoError(deleteInChunks(mock, `widgets`, ids))
+
+	// the IDs are deleted in order, at most three at a time
+	deletes := mock.CallsTo(`Delete`)
+	assert.Len(deletes, 4)
+
+	for i, call := range deletes {
+		assert.Equal(`widgets`, call.Args[0])
+
+		end := (i + 1) * 3
+
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		assert.Equal(ids[i*3:end], call.Args[1])
+	}
+
+	for _, id := range ids {
+		assert.False(mock.Exists(`widgets`, id))
+	}
+
+	// a chunk size of zero deletes everything at once
+	IndexRemoveChunkSize = 0
+	mock.Reset()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`widgets`)))
+	assert.NoError(deleteInChunks(mock, `widgets`, ids))
+	assert.Len(mock.CallsTo(`Delete`), 1)
+
+	// failures stop the deletion
+	IndexRemoveChunkSize = 3
+	mock.Reset()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`widgets`)))
+	mock.FailWith(`Delete`, fmt.Errorf("deletion failed"))
+
+	assert.Error(deleteInChunks(mock, `widgets`, ids))
+	assert.Len(mock.CallsTo(`Delete`), 1)
+}
@@ -0,0 +1,188 @@
+package backends
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/ghetzel/pivot/dal"
+)
+
+// Separates the bucket a key belongs to from the key itself.  Every key in a bucket shares the
+// bucket's name and this separator as a prefix.
+var BadgerKeySeparator = "\x00"
+
+// How often space held by deleted and expired values is reclaimed from the value log.  This can be
+// overridden per connection with the "gcInterval" option.
+var BadgerGCInterval = time.Duration(5) * time.Minute
+
+// A KeyValueStore that keeps its data in an embedded Badger database directory.  Each collection's
+// records are stored under a key prefix made from the collection name, and values may expire.
+type BadgerStore struct {
+	db   *badger.DB
+	stop chan bool
+}
+
+// Returns a backend that stores its data in the Badger database directory named by the connection
+// string (e.g.: "badger:///./data").  The directory is created if it does not exist.
+//
+// Unless another indexer is given, queries are performed by a Bleve index stored in the "indexes"
+// subdirectory.  Records that expire are not removed from the index, but are no longer returned
+// by Retrieve.
+func NewBadgerBackend(connection dal.ConnectionString) Backend {
+	backend := NewKeyValueBackend(connection, &BadgerStore{})
+
+	if path, err := kvFilePath(connection); err == nil {
+		if indexConnString, err := dal.MakeConnectionString(`bleve`, ``, filepath.Join(path, `indexes`), nil); err == nil {
+			backend.SetIndexer(indexConnString)
+		}
+	}
+
+	return backend
+}
+
+func (self *BadgerStore) Open(conn dal.ConnectionString) error {
+	if path, err := kvFilePath(conn); err == nil {
+		if err := os.MkdirAll(path, 0700); err != nil {
+			return err
+		}
+
+		options := badger.DefaultOptions(path)
+		options.Logger = nil
+
+		if db, err := badger.Open(options); err == nil {
+			self.db = db
+		} else {
+			return fmt.Errorf("Cannot open %v: %v", path, err)
+		}
+	} else {
+		return err
+	}
+
+	interval := BadgerGCInterval
+
+	if v, err := time.ParseDuration(conn.OptString(`gcInterval`, ``)); err == nil {
+		interval = v
+	}
+
+	if interval > 0 {
+		self.stop = make(chan bool)
+		go self.collectGarbage(interval, self.stop)
+	}
+
+	return nil
+}
+
+func (self *BadgerStore) Get(bucket string, key string) ([]byte, error) {
+	var value []byte
+
+	err := self.db.View(func(txn *badger.Txn) error {
+		if item, err := txn.Get(self.key(bucket, key)); err == nil {
+			if v, err := item.ValueCopy(nil); err == nil {
+				value = v
+			} else {
+				return err
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		return nil
+	})
+
+	return value, err
+}
+
+func (self *BadgerStore) Put(bucket string, key string, value []byte) error {
+	return self.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(self.key(bucket, key), value)
+	})
+}
+
+func (self *BadgerStore) PutWithTTL(bucket string, key string, value []byte, ttl time.Duration) error {
+	return self.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(self.key(bucket, key), value).WithTTL(ttl))
+	})
+}
+
+func (self *BadgerStore) Delete(bucket string, keys ...string) error {
+	return self.db.Update(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			if err := txn.Delete(self.key(bucket, key)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (self *BadgerStore) Keys(bucket string, fn func(key string) error) error {
+	prefix := self.key(bucket, ``)
+	keys := make([]string, 0)
+
+	// keys are collected first so that fn is free to read from the store
+	if err := self.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, string(it.Item().Key()[len(prefix):]))
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *BadgerStore) DeleteBucket(bucket string) error {
+	return self.db.DropPrefix(self.key(bucket, ``))
+}
+
+func (self *BadgerStore) Close() error {
+	if self.stop != nil {
+		close(self.stop)
+		self.stop = nil
+	}
+
+	if self.db != nil {
+		return self.db.Close()
+	}
+
+	return nil
+}
+
+func (self *BadgerStore) key(bucket string, key string) []byte {
+	return []byte(bucket + BadgerKeySeparator + key)
+}
+
+// periodically rewrites value log files that are mostly made up of deleted or expired values
+func (self *BadgerStore) collectGarbage(interval time.Duration, stop chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// each call rewrites at most one file, so keep going until there's nothing left to do
+			for self.db.RunValueLogGC(0.5) == nil {
+			}
+		case <-stop:
+			return
+		}
+	}
+}
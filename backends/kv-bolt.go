@@ -0,0 +1,136 @@
+package backends
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	bolt "go.etcd.io/bbolt"
+)
+
+// How long to wait for another process to release its lock on a database file before giving up.
+var BoltOpenTimeout = time.Duration(10) * time.Second
+
+// A KeyValueStore that keeps its data in an embedded BoltDB file, with one bucket per collection.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// Returns a backend that stores its data in the BoltDB file named by the connection string (e.g.:
+// "bolt:///./data.db").  The file is created if it does not exist.
+func NewBoltBackend(connection dal.ConnectionString) Backend {
+	return NewKeyValueBackend(connection, &BoltStore{})
+}
+
+func (self *BoltStore) Open(conn dal.ConnectionString) error {
+	if path, err := kvFilePath(conn); err == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+
+		options := &bolt.Options{
+			Timeout: BoltOpenTimeout,
+		}
+
+		if v, err := time.ParseDuration(conn.OptString(`timeout`, ``)); err == nil {
+			options.Timeout = v
+		}
+
+		if db, err := bolt.Open(path, 0600, options); err == nil {
+			self.db = db
+			return nil
+		} else {
+			return fmt.Errorf("Cannot open %v: %v", path, err)
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *BoltStore) Get(bucket string, key string) ([]byte, error) {
+	var value []byte
+
+	err := self.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			// values are only valid for the life of the transaction
+			if v := b.Get([]byte(key)); v != nil {
+				value = make([]byte, len(v))
+				copy(value, v)
+			}
+		}
+
+		return nil
+	})
+
+	return value, err
+}
+
+func (self *BoltStore) Put(bucket string, key string, value []byte) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		if b, err := tx.CreateBucketIfNotExists([]byte(bucket)); err == nil {
+			return b.Put([]byte(key), value)
+		} else {
+			return err
+		}
+	})
+}
+
+func (self *BoltStore) Delete(bucket string, keys ...string) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			for _, key := range keys {
+				if err := b.Delete([]byte(key)); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (self *BoltStore) Keys(bucket string, fn func(key string) error) error {
+	keys := make([]string, 0)
+
+	// keys are collected first so that fn is free to read from the store
+	if err := self.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			return b.ForEach(func(k []byte, _ []byte) error {
+				keys = append(keys, string(k))
+				return nil
+			})
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *BoltStore) DeleteBucket(bucket string) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(bucket)); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (self *BoltStore) Close() error {
+	if self.db != nil {
+		return self.db.Close()
+	}
+
+	return nil
+}
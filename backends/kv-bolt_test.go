@@ -0,0 +1,133 @@
+package backends
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func newBoltTestBackend(path string, options string) (*KeyValueBackend, error) {
+	if cs, err := dal.ParseConnectionString(`bolt://` + path + options); err == nil {
+		backend := NewBoltBackend(cs).(*KeyValueBackend)
+		return backend, backend.Initialize()
+	} else {
+		return nil, err
+	}
+}
+
+func TestBoltBackend(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-bolt-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// the file (and its parent directories) are created as needed
+	path := filepath.Join(dir, `data`, `test.db`)
+	backend, err := newBoltTestBackend(path, ``)
+	assert.NoError(err)
+
+	_, err = os.Stat(path)
+	assert.NoError(err)
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `email`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(`1`).Set(`name`, `first`).Set(`email`, `first@example.com`),
+		dal.NewRecord(`2`).Set(`name`, `second`),
+	)))
+
+	// updates only change the fields they are given
+	assert.NoError(backend.Update(`users`, dal.NewRecordSet(dal.NewRecord(`1`).Set(`name`, `uno`))))
+	assert.NoError(backend.Delete(`users`, `2`))
+
+	// the existing file is locked while it is open
+	_, err = newBoltTestBackend(path, `?timeout=100ms`)
+	assert.Error(err)
+
+	assert.NoError(backend.Close())
+
+	// collections and records are still there once the file is reopened
+	backend, err = newBoltTestBackend(path, ``)
+	assert.NoError(err)
+	defer backend.Close()
+
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`users`}, names)
+
+	users, err := backend.GetCollection(`users`)
+	assert.NoError(err)
+	assert.Len(users.Fields, 2)
+
+	record, err := backend.Retrieve(`users`, `1`)
+	assert.NoError(err)
+	assert.Equal(`uno`, record.Get(`name`))
+	assert.Equal(`first@example.com`, record.Get(`email`))
+
+	assert.False(backend.Exists(`users`, `2`))
+
+	// deleting a collection removes its records along with it
+	assert.NoError(backend.DeleteCollection(`users`))
+
+	_, err = backend.GetCollection(`users`)
+	assert.Error(err)
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`users`)))
+	assert.False(backend.Exists(`users`, `1`))
+}
+
+func TestBoltStoreValues(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-bolt-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cs, err := dal.ParseConnectionString(`bolt://` + filepath.Join(dir, `test.db`))
+	assert.NoError(err)
+
+	store := &BoltStore{}
+	assert.NoError(store.Open(cs))
+	defer store.Close()
+
+	// reading from buckets or keys that don't exist isn't an error
+	value, err := store.Get(`missing`, `key`)
+	assert.NoError(err)
+	assert.Nil(value)
+	assert.NoError(store.DeleteBucket(`missing`))
+	assert.NoError(store.Delete(`missing`, `key`))
+
+	assert.NoError(store.Put(`things`, `b`, []byte(`two`)))
+	assert.NoError(store.Put(`things`, `a`, []byte(`one`)))
+
+	// values remain valid after the transaction they were read in
+	value, err = store.Get(`things`, `a`)
+	assert.NoError(err)
+	assert.NoError(store.Put(`things`, `a`, []byte(`uno`)))
+	assert.Equal([]byte(`one`), value)
+
+	// keys are visited in order, and may be read while visiting them
+	visited := make([]string, 0)
+
+	assert.NoError(store.Keys(`things`, func(key string) error {
+		if v, err := store.Get(`things`, key); err == nil {
+			visited = append(visited, key+`=`+string(v))
+			return nil
+		} else {
+			return err
+		}
+	}))
+
+	assert.Equal([]string{`a=uno`, `b=two`}, visited)
+}
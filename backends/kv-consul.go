@@ -0,0 +1,253 @@
+package backends
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/hashicorp/consul/api"
+)
+
+// The key prefix collections are stored under when the connection string doesn't specify one.
+var ConsulDefaultPrefix = `pivot`
+
+// How long each blocking query made while watching a collection waits for changes before it is
+// reissued.
+var ConsulWatchWaitTime = 5 * time.Minute
+
+// How long to wait before retrying a blocking query that failed while watching a collection.
+var ConsulWatchRetryInterval = 5 * time.Second
+
+// A KeyValueStore that keeps its data in Consul's KV store.  Each bucket is a key prefix under the
+// store's own prefix (e.g.: "pivot/users/"), and values are versioned by their modify index.
+type ConsulStore struct {
+	kv     *api.KV
+	prefix string
+}
+
+// Returns a backend that stores its data in the Consul agent named by the connection string (e.g.:
+// "consul://localhost:8500/myapp", or "consul+https://consul.example.com/myapp?datacenter=dc2"),
+// with records stored under "<prefix>/<collection>/<id>" where the prefix is given as the path
+// ("pivot" by default.)  The "datacenter" and "token" options select the datacenter to use and the
+// ACL token to use it with.
+//
+// Updates use check-and-set on the modify index of each record (see KeyValueRevisionStore), and
+// collections are watched with blocking queries.
+func NewConsulBackend(connection dal.ConnectionString) Backend {
+	return NewKeyValueBackend(connection, &ConsulStore{})
+}
+
+func (self *ConsulStore) Open(conn dal.ConnectionString) error {
+	config := api.DefaultConfig()
+
+	if host := conn.Host(); host != `` {
+		config.Address = host
+	}
+
+	if protocol := conn.Protocol(); protocol != `` {
+		config.Scheme = protocol
+	}
+
+	config.Datacenter = conn.OptString(`datacenter`, config.Datacenter)
+	config.Token = conn.OptString(`token`, config.Token)
+
+	if username, password, ok := conn.Credentials(); ok {
+		config.HttpAuth = &api.HttpBasicAuth{
+			Username: username,
+			Password: password,
+		}
+	}
+
+	if client, err := api.NewClient(config); err == nil {
+		self.kv = client.KV()
+		self.prefix = sliceutil.OrString(conn.Dataset(), ConsulDefaultPrefix)
+		return nil
+	} else {
+		return fmt.Errorf("Cannot connect to Consul: %v", err)
+	}
+}
+
+func (self *ConsulStore) Get(bucket string, key string) ([]byte, error) {
+	value, _, err := self.GetRevision(bucket, key)
+	return value, err
+}
+
+func (self *ConsulStore) GetRevision(bucket string, key string) ([]byte, string, error) {
+	if pair, _, err := self.kv.Get(self.key(bucket, key), nil); err == nil {
+		if pair == nil {
+			return nil, ``, nil
+		}
+
+		return pair.Value, strconv.FormatUint(pair.ModifyIndex, 10), nil
+	} else {
+		return nil, ``, err
+	}
+}
+
+func (self *ConsulStore) Put(bucket string, key string, value []byte) error {
+	_, err := self.kv.Put(&api.KVPair{
+		Key:   self.key(bucket, key),
+		Value: value,
+	}, nil)
+
+	return err
+}
+
+func (self *ConsulStore) PutIfRevision(bucket string, key string, value []byte, revision string, ttl time.Duration) (string, bool, error) {
+	if ttl > 0 {
+		return ``, false, dal.Unsupported("%T cannot expire records", self)
+	}
+
+	var index uint64
+
+	// an index of zero only stores the value if the key doesn't exist
+	if revision != `` {
+		if v, err := strconv.ParseUint(revision, 10, 64); err == nil {
+			index = v
+		} else {
+			return ``, false, fmt.Errorf("Invalid revision %q: %v", revision, err)
+		}
+	}
+
+	if ok, response, _, err := self.kv.Txn(api.KVTxnOps{
+		&api.KVTxnOp{
+			Verb:  api.KVCAS,
+			Key:   self.key(bucket, key),
+			Value: value,
+			Index: index,
+		},
+	}, nil); err == nil {
+		if ok && len(response.Results) > 0 {
+			return strconv.FormatUint(response.Results[0].ModifyIndex, 10), true, nil
+		}
+
+		return ``, false, nil
+	} else {
+		return ``, false, err
+	}
+}
+
+func (self *ConsulStore) Delete(bucket string, keys ...string) error {
+	for _, key := range keys {
+		if _, err := self.kv.Delete(self.key(bucket, key), nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *ConsulStore) Keys(bucket string, fn func(key string) error) error {
+	prefix := self.key(bucket, ``)
+
+	if keys, _, err := self.kv.Keys(prefix, ``, nil); err == nil {
+		for _, key := range keys {
+			if err := fn(strings.TrimPrefix(key, prefix)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ConsulStore) DeleteBucket(bucket string) error {
+	_, err := self.kv.DeleteTree(self.key(bucket, ``), nil)
+	return err
+}
+
+func (self *ConsulStore) Close() error {
+	return nil
+}
+
+// Watches the bucket with blocking queries, comparing the modify index of each key in successive
+// results to tell which keys were created, updated or removed.
+func (self *ConsulStore) WatchBucket(ctx context.Context, bucket string, fn func(key string, change ChangeType) error) error {
+	prefix := self.key(bucket, ``)
+	previous, index, err := self.watchList(ctx, prefix, 0)
+
+	if err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		current, lastIndex, err := self.watchList(ctx, prefix, index)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+
+			log.Warningf("[%T] watch %s: %v", self, bucket, err)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(ConsulWatchRetryInterval):
+			}
+
+			continue
+		}
+
+		// the index can go backwards (e.g.: when the cluster's state is restored), in which case the
+		// next query starts over without blocking
+		if lastIndex < index {
+			index = 0
+		} else {
+			index = lastIndex
+		}
+
+		for key, modified := range current {
+			if was, ok := previous[key]; !ok {
+				if err := fn(key, ChangeCreate); err != nil {
+					return err
+				}
+			} else if was != modified {
+				if err := fn(key, ChangeUpdate); err != nil {
+					return err
+				}
+			}
+		}
+
+		for key := range previous {
+			if _, ok := current[key]; !ok {
+				if err := fn(key, ChangeDelete); err != nil {
+					return err
+				}
+			}
+		}
+
+		previous = current
+	}
+
+	return nil
+}
+
+// returns the modify index of each key under the prefix once the prefix's index passes the given one
+func (self *ConsulStore) watchList(ctx context.Context, prefix string, index uint64) (map[string]uint64, uint64, error) {
+	options := &api.QueryOptions{
+		WaitIndex: index,
+		WaitTime:  ConsulWatchWaitTime,
+	}
+
+	if pairs, meta, err := self.kv.List(prefix, options.WithContext(ctx)); err == nil {
+		modified := make(map[string]uint64)
+
+		for _, pair := range pairs {
+			modified[strings.TrimPrefix(pair.Key, prefix)] = pair.ModifyIndex
+		}
+
+		return modified, meta.LastIndex, nil
+	} else {
+		return nil, 0, err
+	}
+}
+
+func (self *ConsulStore) key(bucket string, key string) string {
+	return self.prefix + `/` + bucket + `/` + key
+}
@@ -0,0 +1,26 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsulStoreKeys(t *testing.T) {
+	assert := require.New(t)
+
+	for connString, key := range map[string]string{
+		`consul://localhost:8500`:                         ConsulDefaultPrefix + `/users/1`,
+		`consul://localhost:8500/myapp`:                   `myapp/users/1`,
+		`consul+https://consul.example.com/myapp?token=x`: `myapp/users/1`,
+	} {
+		cs, err := dal.ParseConnectionString(connString)
+		assert.NoError(err)
+
+		// opening the store doesn't contact the agent
+		store := new(ConsulStore)
+		assert.NoError(store.Open(cs), connString)
+		assert.Equal(key, store.key(`users`, `1`), connString)
+	}
+}
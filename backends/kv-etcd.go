@@ -0,0 +1,233 @@
+package backends
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/dal"
+	"go.etcd.io/etcd/clientv3"
+)
+
+// The key prefix collections are stored under when the connection string doesn't specify one.
+var EtcdDefaultPrefix = `pivot`
+
+// How long to wait for a connection to the cluster (overridden by the "timeout" option.)
+var EtcdDialTimeout = 5 * time.Second
+
+// How long each request to the cluster may take before it is abandoned.
+var EtcdRequestTimeout = 10 * time.Second
+
+// A KeyValueStore that keeps its data in an etcd v3 cluster.  Each bucket is a key prefix under the
+// store's own prefix (e.g.: "pivot/users/"), and values are versioned by the revision of the cluster
+// they were last modified at.
+type EtcdStore struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// Returns a backend that stores its data in the etcd cluster named by the connection string (e.g.:
+// "etcd://localhost:2379/myapp", or "etcd+https://node1:2379,node2:2379/myapp"), with collections
+// stored under the key prefix given as the path ("pivot" by default.)  Updates use compare-and-swap
+// on the revision of each record; see KeyValueRevisionStore.
+func NewEtcdBackend(connection dal.ConnectionString) Backend {
+	return NewKeyValueBackend(connection, &EtcdStore{})
+}
+
+func (self *EtcdStore) Open(conn dal.ConnectionString) error {
+	protocol := sliceutil.OrString(conn.Protocol(), `http`)
+	endpoints := make([]string, 0)
+
+	for _, host := range strings.Split(sliceutil.OrString(conn.Host(), `localhost:2379`), `,`) {
+		endpoints = append(endpoints, fmt.Sprintf("%s://%s", protocol, host))
+	}
+
+	config := clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: EtcdDialTimeout,
+	}
+
+	if v, err := time.ParseDuration(conn.OptString(`timeout`, ``)); err == nil {
+		config.DialTimeout = v
+	}
+
+	if username, password, ok := conn.Credentials(); ok {
+		config.Username = username
+		config.Password = password
+	}
+
+	if client, err := clientv3.New(config); err == nil {
+		self.client = client
+		self.prefix = sliceutil.OrString(conn.Dataset(), EtcdDefaultPrefix)
+		return nil
+	} else {
+		return fmt.Errorf("Cannot connect to etcd: %v", err)
+	}
+}
+
+func (self *EtcdStore) Get(bucket string, key string) ([]byte, error) {
+	value, _, err := self.GetRevision(bucket, key)
+	return value, err
+}
+
+func (self *EtcdStore) GetRevision(bucket string, key string) ([]byte, string, error) {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	if response, err := self.client.Get(ctx, self.key(bucket, key)); err == nil {
+		if len(response.Kvs) == 0 {
+			return nil, ``, nil
+		}
+
+		kv := response.Kvs[0]
+		return kv.Value, strconv.FormatInt(kv.ModRevision, 10), nil
+	} else {
+		return nil, ``, err
+	}
+}
+
+func (self *EtcdStore) Put(bucket string, key string, value []byte) error {
+	return self.PutWithTTL(bucket, key, value, 0)
+}
+
+func (self *EtcdStore) PutWithTTL(bucket string, key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	if options, err := self.putOptions(ctx, ttl); err == nil {
+		_, err := self.client.Put(ctx, self.key(bucket, key), string(value), options...)
+		return err
+	} else {
+		return err
+	}
+}
+
+func (self *EtcdStore) PutIfRevision(bucket string, key string, value []byte, revision string, ttl time.Duration) (string, bool, error) {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	key = self.key(bucket, key)
+	var condition clientv3.Cmp
+
+	if revision == `` {
+		// the key must not exist
+		condition = clientv3.Compare(clientv3.CreateRevision(key), `=`, 0)
+	} else if rev, err := strconv.ParseInt(revision, 10, 64); err == nil {
+		condition = clientv3.Compare(clientv3.ModRevision(key), `=`, rev)
+	} else {
+		return ``, false, fmt.Errorf("Invalid revision %q: %v", revision, err)
+	}
+
+	if options, err := self.putOptions(ctx, ttl); err == nil {
+		if response, err := self.client.Txn(ctx).If(condition).Then(
+			clientv3.OpPut(key, string(value), options...),
+		).Commit(); err == nil {
+			if response.Succeeded {
+				return strconv.FormatInt(response.Header.Revision, 10), true, nil
+			}
+
+			return ``, false, nil
+		} else {
+			return ``, false, err
+		}
+	} else {
+		return ``, false, err
+	}
+}
+
+func (self *EtcdStore) Delete(bucket string, keys ...string) error {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	for _, key := range keys {
+		if _, err := self.client.Delete(ctx, self.key(bucket, key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Calls fn with each key in the bucket in sorted order, reading them from the cluster in pages of
+// IndexerPageSize.
+func (self *EtcdStore) Keys(bucket string, fn func(key string) error) error {
+	prefix := self.key(bucket, ``)
+	end := clientv3.GetPrefixRangeEnd(prefix)
+	start := prefix
+
+	for {
+		ctx, cancel := self.context()
+
+		response, err := self.client.Get(
+			ctx,
+			start,
+			clientv3.WithRange(end),
+			clientv3.WithKeysOnly(),
+			clientv3.WithLimit(int64(IndexerPageSize)),
+			clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+		)
+
+		cancel()
+
+		if err != nil {
+			return err
+		}
+
+		for _, kv := range response.Kvs {
+			if err := fn(strings.TrimPrefix(string(kv.Key), prefix)); err != nil {
+				return err
+			}
+		}
+
+		if !response.More || len(response.Kvs) == 0 {
+			return nil
+		}
+
+		// continue from just after the last key read
+		start = string(response.Kvs[len(response.Kvs)-1].Key) + "\x00"
+	}
+}
+
+func (self *EtcdStore) DeleteBucket(bucket string) error {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	_, err := self.client.Delete(ctx, self.key(bucket, ``), clientv3.WithPrefix())
+	return err
+}
+
+func (self *EtcdStore) Close() error {
+	return self.client.Close()
+}
+
+func (self *EtcdStore) key(bucket string, key string) string {
+	return self.prefix + `/` + bucket + `/` + key
+}
+
+func (self *EtcdStore) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), EtcdRequestTimeout)
+}
+
+// values with a TTL are attached to a lease that expires once the TTL has passed
+func (self *EtcdStore) putOptions(ctx context.Context, ttl time.Duration) ([]clientv3.OpOption, error) {
+	if ttl <= 0 {
+		return nil, nil
+	}
+
+	seconds := int64(ttl / time.Second)
+
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	if lease, err := self.client.Grant(ctx, seconds); err == nil {
+		return []clientv3.OpOption{
+			clientv3.WithLease(lease.ID),
+		}, nil
+	} else {
+		return nil, err
+	}
+}
@@ -0,0 +1,124 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// a MemoryStore that versions its values the way etcd does, and where others change each record the
+// given number of times while it is being updated
+type conflictingRevisionStore struct {
+	MemoryStore
+	revisions    map[string]int
+	conflicts    int
+	revisionLock sync.Mutex
+}
+
+func (self *conflictingRevisionStore) GetRevision(bucket string, key string) ([]byte, string, error) {
+	self.revisionLock.Lock()
+	defer self.revisionLock.Unlock()
+
+	if value, err := self.Get(bucket, key); err == nil && value != nil {
+		return value, fmt.Sprintf("%d", self.revisions[bucket+`/`+key]), nil
+	} else {
+		return nil, ``, err
+	}
+}
+
+func (self *conflictingRevisionStore) PutIfRevision(bucket string, key string, value []byte, revision string, ttl time.Duration) (string, bool, error) {
+	self.revisionLock.Lock()
+	defer self.revisionLock.Unlock()
+
+	if self.revisions == nil {
+		self.revisions = make(map[string]int)
+	}
+
+	name := bucket + `/` + key
+
+	if self.conflicts > 0 && revision != `` {
+		self.conflicts -= 1
+		self.revisions[name] += 1
+	}
+
+	if current, err := self.Get(bucket, key); err != nil {
+		return ``, false, err
+	} else if current == nil && revision != `` {
+		return ``, false, nil
+	} else if current != nil && revision != fmt.Sprintf("%d", self.revisions[name]) {
+		return ``, false, nil
+	}
+
+	if err := self.PutWithTTL(bucket, key, value, ttl); err != nil {
+		return ``, false, err
+	}
+
+	self.revisions[name] += 1
+	return fmt.Sprintf("%d", self.revisions[name]), true, nil
+}
+
+func TestKeyValueBackendConflicts(t *testing.T) {
+	assert := require.New(t)
+
+	store := new(conflictingRevisionStore)
+	backend := NewKeyValueBackend(dal.ConnectionString{}, store)
+	assert.NoError(backend.Initialize())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	})))
+
+	record := dal.NewRecord(1).Set(`name`, `alice`).Set(`age`, 31)
+	assert.NoError(backend.Insert(`users`, dal.NewRecordSet(record)))
+	assert.Equal(`1`, record.Revision)
+
+	err := backend.Insert(`users`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `again`)))
+	assert.True(errors.Is(err, dal.ErrUniqueViolation))
+
+	// updates that collide with others are retried against the latest revision...
+	store.conflicts = KeyValueConflictRetries
+	assert.NoError(backend.Update(`users`, dal.NewRecordSet(dal.NewRecord(1).Set(`age`, 32))))
+	assert.Zero(store.conflicts)
+
+	record, err = backend.Retrieve(`users`, 1)
+	assert.NoError(err)
+	assert.Equal(`alice`, record.Get(`name`))
+	assert.EqualValues(32, record.Get(`age`))
+
+	// ...a limited number of times
+	store.conflicts = KeyValueConflictRetries + 1
+	assert.Error(backend.Update(`users`, dal.NewRecordSet(dal.NewRecord(1).Set(`age`, 33))))
+
+	// updates of a specific revision aren't retried, since the caller's copy is out of date
+	record, err = backend.Retrieve(`users`, 1)
+	assert.NoError(err)
+
+	record.Set(`age`, 34)
+	store.conflicts = 1
+
+	err = backend.Update(`users`, dal.NewRecordSet(record))
+	assert.True(errors.Is(err, dal.ErrStaleRecord))
+
+	record, err = backend.Retrieve(`users`, 1)
+	assert.NoError(err)
+	assert.EqualValues(32, record.Get(`age`))
+}
+
+func TestEtcdStoreKey(t *testing.T) {
+	assert := require.New(t)
+
+	store := &EtcdStore{
+		prefix: EtcdDefaultPrefix,
+	}
+
+	assert.Equal(EtcdDefaultPrefix+`/users/1`, store.key(`users`, `1`))
+}
@@ -0,0 +1,160 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+func (self *KeyValueBackend) IndexConnectionString() *dal.ConnectionString {
+	return &dal.ConnectionString{}
+}
+
+func (self *KeyValueBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *KeyValueBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *KeyValueBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.Name, id)
+}
+
+func (self *KeyValueBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.Name, id)
+}
+
+func (self *KeyValueBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *KeyValueBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Calls resultFn for each record matching the filter, sorted by the filter's sort fields (or by ID).
+func (self *KeyValueBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	defer timeOperation(`pivot.indexers.kv.query_time`)()
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	matches, err := self.matching(collection, f)
+
+	if err != nil {
+		return err
+	}
+
+	total := int64(len(matches))
+
+	if f.Offset >= len(matches) {
+		matches = nil
+	} else {
+		matches = matches[f.Offset:]
+	}
+
+	if f.Limit > 0 && len(matches) > f.Limit {
+		matches = matches[:f.Limit]
+	}
+
+	for _, record := range matches {
+		if err := resultFn(record, nil, IndexPage{
+			Page:         1,
+			Limit:        f.Limit,
+			Offset:       f.Offset,
+			TotalResults: total,
+		}); err != nil {
+			return queryStopped(err)
+		}
+	}
+
+	return nil
+}
+
+func (self *KeyValueBackend) matching(collection *dal.Collection, f *filter.Filter) ([]*dal.Record, error) {
+	matches := make([]*dal.Record, 0)
+
+	// records matched only by their IDs are read directly rather than scanning the whole collection
+	if len(f.Criteria) == 1 && f.Criteria[0].Field == collection.IdentityField && (f.Criteria[0].Operator == `` || f.Criteria[0].Operator == `is`) {
+		for _, id := range f.Criteria[0].Values {
+			if record, err := self.read(collection, self.keyFor(id)); err != nil {
+				return nil, err
+			} else if record != nil {
+				matches = append(matches, record)
+			}
+		}
+	} else if err := self.store.Keys(collection.Name, func(key string) error {
+		if record, err := self.read(collection, key); err != nil {
+			return err
+		} else if record != nil && f.MatchesRecord(record) {
+			matches = append(matches, record)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sortRecords(collection, matches, f)
+
+	return matches, nil
+}
+
+func (self *KeyValueBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *KeyValueBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+	seen := make(map[string]map[string]bool)
+
+	err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		for _, field := range fields {
+			value := record.Get(field)
+
+			if field == collection.IdentityField {
+				value = record.ID
+			}
+
+			if seen[field] == nil {
+				seen[field] = make(map[string]bool)
+			}
+
+			if key := fmt.Sprintf("%v", value); !seen[field][key] {
+				seen[field][key] = true
+				values[field] = append(values[field], value)
+			}
+		}
+
+		return nil
+	})
+
+	return values, err
+}
+
+func (self *KeyValueBackend) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	return DefaultFacetsImplementation(self, collection, fields, f)
+}
+
+func (self *KeyValueBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		ids = append(ids, record.ID)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return deleteInChunks(self, collection.Name, ids)
+}
+
+func (self *KeyValueBackend) FlushIndex() error {
+	return nil
+}
@@ -0,0 +1,126 @@
+package backends
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+)
+
+type memoryValue struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+func (self memoryValue) expired() bool {
+	return !self.expiresAt.IsZero() && !time.Now().Before(self.expiresAt)
+}
+
+// A KeyValueStore that keeps its data in memory, which is lost once the process exits.
+type MemoryStore struct {
+	buckets map[string]map[string]memoryValue
+	lock    sync.RWMutex
+}
+
+// Returns a backend that keeps its collections and records in memory (e.g.: "memory://").  This is
+// meant for unit testing applications built on pivot without needing database files or servers;
+// each backend has its own data, which starts out empty.
+func NewMemoryBackend(connection dal.ConnectionString) Backend {
+	return NewKeyValueBackend(connection, &MemoryStore{})
+}
+
+func (self *MemoryStore) Open(conn dal.ConnectionString) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.buckets == nil {
+		self.buckets = make(map[string]map[string]memoryValue)
+	}
+
+	return nil
+}
+
+func (self *MemoryStore) Get(bucket string, key string) ([]byte, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if value, ok := self.buckets[bucket][key]; ok && !value.expired() {
+		return append([]byte(nil), value.data...), nil
+	}
+
+	return nil, nil
+}
+
+func (self *MemoryStore) Put(bucket string, key string, value []byte) error {
+	return self.PutWithTTL(bucket, key, value, 0)
+}
+
+func (self *MemoryStore) PutWithTTL(bucket string, key string, value []byte, ttl time.Duration) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	stored := memoryValue{
+		data: append([]byte(nil), value...),
+	}
+
+	if ttl > 0 {
+		stored.expiresAt = time.Now().Add(ttl)
+	}
+
+	if _, ok := self.buckets[bucket]; !ok {
+		self.buckets[bucket] = make(map[string]memoryValue)
+	}
+
+	self.buckets[bucket][key] = stored
+	return nil
+}
+
+func (self *MemoryStore) Delete(bucket string, keys ...string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for _, key := range keys {
+		delete(self.buckets[bucket], key)
+	}
+
+	return nil
+}
+
+// Calls fn with each unexpired key in the bucket, in sorted order.  Expired values are removed as
+// they are encountered.
+func (self *MemoryStore) Keys(bucket string, fn func(key string) error) error {
+	self.lock.Lock()
+	keys := make([]string, 0, len(self.buckets[bucket]))
+
+	for key, value := range self.buckets[bucket] {
+		if value.expired() {
+			delete(self.buckets[bucket], key)
+		} else {
+			keys = append(keys, key)
+		}
+	}
+
+	self.lock.Unlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *MemoryStore) DeleteBucket(bucket string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	delete(self.buckets, bucket)
+	return nil
+}
+
+func (self *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,100 @@
+package backends
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryTestBackend() (*KeyValueBackend, error) {
+	if cs, err := dal.ParseConnectionString(`memory://`); err == nil {
+		backend := NewMemoryBackend(cs).(*KeyValueBackend)
+		return backend, backend.Initialize()
+	} else {
+		return nil, err
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	assert := require.New(t)
+
+	first, err := newMemoryTestBackend()
+	assert.NoError(err)
+
+	second, err := newMemoryTestBackend()
+	assert.NoError(err)
+
+	assert.NoError(first.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(first.Insert(`users`, dal.NewRecordSet(dal.NewRecord(`1`).Set(`name`, `first`))))
+
+	// every backend has its own data
+	_, err = second.GetCollection(`users`)
+	assert.True(errors.Is(err, dal.ErrCollectionNotFound))
+
+	names, err := second.ListCollections()
+	assert.NoError(err)
+	assert.Empty(names)
+
+	// records read from the backend can be changed without changing what is stored
+	record, err := first.Retrieve(`users`, `1`)
+	assert.NoError(err)
+	record.Set(`name`, `changed`)
+
+	record, err = first.Retrieve(`users`, `1`)
+	assert.NoError(err)
+	assert.Equal(`first`, record.Get(`name`))
+
+	// inserting a record that already exists fails
+	err = first.Insert(`users`, dal.NewRecordSet(dal.NewRecord(`1`).Set(`name`, `again`)))
+	assert.True(dal.IsExistError(err))
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	assert := require.New(t)
+
+	store := &MemoryStore{}
+	assert.NoError(store.Open(dal.ConnectionString{}))
+
+	assert.NoError(store.Put(`things`, `forever`, []byte(`1`)))
+	assert.NoError(store.PutWithTTL(`things`, `brief`, []byte(`2`), 10*time.Millisecond))
+
+	value, err := store.Get(`things`, `brief`)
+	assert.NoError(err)
+	assert.Equal([]byte(`2`), value)
+
+	// stored values are copies, so changing the caller's (or a reader's) slice doesn't change them
+	value[0] = '9'
+
+	value, err = store.Get(`things`, `brief`)
+	assert.NoError(err)
+	assert.Equal([]byte(`2`), value)
+
+	time.Sleep(20 * time.Millisecond)
+
+	// expired values are gone, whether read directly or listed
+	value, err = store.Get(`things`, `brief`)
+	assert.NoError(err)
+	assert.Nil(value)
+
+	keys := make([]string, 0)
+
+	assert.NoError(store.Keys(`things`, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}))
+
+	assert.Equal([]string{`forever`}, keys)
+
+	assert.NoError(store.DeleteBucket(`things`))
+
+	value, err = store.Get(`things`, `forever`)
+	assert.NoError(err)
+	assert.Nil(value)
+}
@@ -0,0 +1,82 @@
+package backends
+
+import (
+	"context"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// Watches a collection for changes using the store's change notifications.  Stores that aren't
+// KeyValueWatchingStores are not supported, and are polled instead.
+func (self *KeyValueBackend) Watch(ctx context.Context, collection *dal.Collection, f *filter.Filter) (<-chan *ChangeEvent, error) {
+	store, ok := self.store.(KeyValueWatchingStore)
+
+	if !ok {
+		return nil, NotImplementedError
+	}
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	events := make(chan *ChangeEvent)
+
+	go func() {
+		defer close(events)
+
+		if err := store.WatchBucket(ctx, collection.Name, func(key string, change ChangeType) error {
+			if event := self.changeEvent(collection, f, key, change); event != nil {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			return nil
+		}); err != nil && ctx.Err() == nil {
+			log.Warningf("[%T] watch %s: %v", self, collection.Name, err)
+		}
+	}()
+
+	return events, nil
+}
+
+// converts a change to a key into a change event, or returns nil if the changed record does not match
+// the filter
+func (self *KeyValueBackend) changeEvent(collection *dal.Collection, f *filter.Filter, key string, change ChangeType) *ChangeEvent {
+	event := &ChangeEvent{
+		Type:       change,
+		Collection: collection.Name,
+		ID:         key,
+		Timestamp:  time.Now(),
+	}
+
+	if collection.IdentityFieldType != dal.StringType {
+		event.ID = stringutil.Autotype(key)
+	}
+
+	// the contents of deleted records are gone, so their deletion is always reported
+	if change == ChangeDelete {
+		return event
+	}
+
+	if record, err := self.read(collection, key); err == nil {
+		// the record may have been deleted since, in which case we'll hear about that next
+		if record == nil {
+			return nil
+		} else if !f.IsMatchAll() && !f.MatchesRecord(record) {
+			return nil
+		}
+
+		event.ID = record.ID
+		event.Record = record
+		return event
+	} else {
+		log.Warningf("[%T] watch %s: %v", self, collection.Name, err)
+		return nil
+	}
+}
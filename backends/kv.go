@@ -0,0 +1,496 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/pathutil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// The bucket collection definitions are stored in, which is not itself a collection.
+var KeyValueSchemaBucket = `_schema`
+
+// A KeyValueStore holds the data of a KeyValueBackend in buckets of values, each identified by a key.
+// Each collection is a bucket of encoded records keyed by their IDs.
+type KeyValueStore interface {
+	// Opens the store described by the given connection string.
+	Open(conn dal.ConnectionString) error
+
+	// Returns the value stored at the given key, or a nil value if there isn't one.
+	Get(bucket string, key string) ([]byte, error)
+
+	// Stores a value at the given key, replacing any value already there.
+	Put(bucket string, key string, value []byte) error
+
+	// Removes the given keys.  Keys that don't exist are ignored.
+	Delete(bucket string, keys ...string) error
+
+	// Calls fn with each key in the bucket (in order, for stores that keep their keys sorted),
+	// stopping at the first error fn returns.
+	Keys(bucket string, fn func(key string) error) error
+
+	// Removes the bucket and every value in it.
+	DeleteBucket(bucket string) error
+
+	Close() error
+}
+
+// Implemented by KeyValueStores that can expire values, which is required to use collections with a
+// TTL.
+type KeyValueExpiringStore interface {
+	// Stores a value at the given key that is removed once the given duration has passed.
+	PutWithTTL(bucket string, key string, value []byte, ttl time.Duration) error
+}
+
+// Implemented by KeyValueStores that version their values.  Records read from these stores carry the
+// revision they were read at, and writes only succeed if the record hasn't been changed by others in
+// the meantime.
+type KeyValueRevisionStore interface {
+	// Returns the value stored at the given key and its revision, or a nil value if there isn't one.
+	GetRevision(bucket string, key string) ([]byte, string, error)
+
+	// Stores a value at the given key if its current revision is the given one (or, if the revision is
+	// empty, if the key doesn't exist), returning the new revision and whether the value was stored.
+	// A nonzero TTL expires the value once it has passed.
+	PutIfRevision(bucket string, key string, value []byte, revision string, ttl time.Duration) (string, bool, error)
+}
+
+// Implemented by KeyValueStores that can notify subscribers of changes to the keys in a bucket, which
+// lets collections be watched without polling.
+type KeyValueWatchingStore interface {
+	// Calls fn with each key in the bucket that is created, updated or removed (along with the type
+	// of change) until the context is cancelled or fn returns an error.
+	WatchBucket(ctx context.Context, bucket string, fn func(key string, change ChangeType) error) error
+}
+
+// The number of times an update that conflicts with a concurrent change is retried (against the
+// latest revision of the record) before giving up.  Only applies to KeyValueRevisionStores.
+var KeyValueConflictRetries = 5
+
+// A Backend that stores records in a KeyValueStore (e.g.: an embedded database file).  Records are
+// stored as JSON, and queries are performed by reading every record in the collection, so the
+// backend is best suited to small collections unless an external indexer is configured.
+//
+// If the store is a KeyValueRevisionStore, updates of records that have a Revision only succeed if
+// the stored record is still at that revision (otherwise a stale record error is returned); records
+// without one are merged into the latest stored record, retrying if it is changed concurrently.
+type KeyValueBackend struct {
+	Backend
+	Indexer
+	conn                  dal.ConnectionString
+	store                 KeyValueStore
+	indexer               Indexer
+	registeredCollections sync.Map
+	writeLock             sync.Mutex
+}
+
+type kvRecord struct {
+	ID     interface{}            `json:"id"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Returns a backend that stores its data in the given store.
+func NewKeyValueBackend(connection dal.ConnectionString, store KeyValueStore) *KeyValueBackend {
+	return &KeyValueBackend{
+		conn:  connection,
+		store: store,
+	}
+}
+
+func (self *KeyValueBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *KeyValueBackend) Initialize() error {
+	if err := self.store.Open(self.conn); err != nil {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	return self.indexer.IndexInitialize(self)
+}
+
+func (self *KeyValueBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *KeyValueBackend) RegisterCollection(collection *dal.Collection) {
+	if collection != nil {
+		self.registeredCollections.Store(collection.Name, collection)
+	}
+}
+
+func (self *KeyValueBackend) Ping(timeout time.Duration) error {
+	errchan := make(chan error, 1)
+
+	go func() {
+		_, err := self.store.Get(KeyValueSchemaBucket, ``)
+		errchan <- err
+	}()
+
+	select {
+	case err := <-errchan:
+		if err != nil {
+			return fmt.Errorf("Backend unavailable: %v", err)
+		}
+
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("Backend unavailable: timed out after waiting %v", timeout)
+	}
+}
+
+func (self *KeyValueBackend) Exists(name string, id interface{}) bool {
+	if collection, err := self.GetCollection(name); err == nil {
+		if data, err := self.store.Get(collection.Name, self.keyFor(id)); err == nil && data != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *KeyValueBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		if record, err := self.read(collection, self.keyFor(id)); err == nil {
+			if record == nil {
+				return nil, dal.RecordNotFound(id)
+			}
+
+			if len(fields) > 0 {
+				for key := range record.Fields {
+					if !sliceutil.ContainsString(fields, key) {
+						delete(record.Fields, key)
+					}
+				}
+			}
+
+			return record, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *KeyValueBackend) Insert(name string, recordset *dal.RecordSet) error {
+	return self.write(name, recordset, true)
+}
+
+// Updates only change the fields given in each record; other fields keep their stored values.
+func (self *KeyValueBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.write(name, recordset, false)
+}
+
+func (self *KeyValueBackend) write(name string, recordset *dal.RecordSet, insert bool) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		self.writeLock.Lock()
+		defer self.writeLock.Unlock()
+
+		for _, record := range recordset.Records {
+			if r, err := collection.MakeRecord(record); err == nil {
+				record = r
+			} else {
+				return err
+			}
+
+			if record.ID == nil {
+				return fmt.Errorf("Cannot write a record without an ID to %v", collection.Name)
+			}
+
+			if err := self.writeRecord(collection, record, insert); err != nil {
+				return err
+			}
+		}
+
+		if !collection.SkipIndexPersistence {
+			if search := self.WithSearch(collection); search != nil {
+				if err := search.Index(collection, recordset); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// merges the record's fields into those already stored for it (unless inserting) and writes the result
+func (self *KeyValueBackend) writeRecord(collection *dal.Collection, record *dal.Record, insert bool) error {
+	key := self.keyFor(record.ID)
+
+	for attempt := 0; attempt <= KeyValueConflictRetries; attempt++ {
+		var revision string
+
+		stored := kvRecord{
+			ID:     record.ID,
+			Fields: make(map[string]interface{}),
+		}
+
+		if existing, err := self.read(collection, key); err != nil {
+			return err
+		} else if existing != nil {
+			if insert {
+				return dal.UniqueViolation(fmt.Errorf("Record %v already exists", record.ID))
+			}
+
+			revision = existing.Revision
+
+			for k, v := range existing.Fields {
+				stored.Fields[k] = v
+			}
+		}
+
+		if !insert && record.Revision != `` && record.Revision != revision {
+			return dal.StaleRecord(record.ID)
+		}
+
+		for k, v := range record.Fields {
+			stored.Fields[k] = v
+		}
+
+		data, err := json.Marshal(&stored)
+
+		if err != nil {
+			return err
+		}
+
+		if store, ok := self.store.(KeyValueRevisionStore); ok {
+			if newRevision, ok, err := store.PutIfRevision(collection.Name, key, data, revision, collection.GetTTL()); err != nil {
+				return err
+			} else if ok {
+				record.Revision = newRevision
+				return nil
+			} else if insert {
+				return dal.UniqueViolation(fmt.Errorf("Record %v already exists", record.ID))
+			} else if record.Revision != `` {
+				return dal.StaleRecord(record.ID)
+			}
+
+			querylog.Debugf("[%T] Record %v changed while updating, retrying", self, record.ID)
+			continue
+		}
+
+		return self.put(collection, key, data)
+	}
+
+	return fmt.Errorf("Failed to update record %v: it was changed by others %d times", record.ID, KeyValueConflictRetries+1)
+}
+
+// stores the given record data, expiring it after the collection's TTL (if it has one)
+func (self *KeyValueBackend) put(collection *dal.Collection, key string, data []byte) error {
+	if ttl := collection.GetTTL(); ttl > 0 {
+		if store, ok := self.store.(KeyValueExpiringStore); ok {
+			return store.PutWithTTL(collection.Name, key, data, ttl)
+		} else {
+			return dal.Unsupported("%T cannot expire records", self.store)
+		}
+	}
+
+	return self.store.Put(collection.Name, key, data)
+}
+
+func (self *KeyValueBackend) Delete(name string, ids ...interface{}) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		keys := make([]string, len(ids))
+
+		for i, id := range ids {
+			keys[i] = self.keyFor(id)
+		}
+
+		if err := self.store.Delete(collection.Name, keys...); err != nil {
+			return err
+		}
+
+		if search := self.WithSearch(collection); search != nil {
+			return search.IndexRemove(collection, ids)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *KeyValueBackend) CreateCollection(definition *dal.Collection) error {
+	if definition.Name == KeyValueSchemaBucket {
+		return fmt.Errorf("Collection name %q is reserved", definition.Name)
+	}
+
+	if data, err := self.store.Get(KeyValueSchemaBucket, definition.Name); err != nil {
+		return err
+	} else if data != nil {
+		return fmt.Errorf("Collection %v already exists", definition.Name)
+	}
+
+	if data, err := json.Marshal(definition); err == nil {
+		if err := self.store.Put(KeyValueSchemaBucket, definition.Name, data); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	self.RegisterCollection(definition)
+	return nil
+}
+
+func (self *KeyValueBackend) DeleteCollection(name string) error {
+	if _, err := self.GetCollection(name); err == nil {
+		if err := self.store.DeleteBucket(name); err != nil {
+			return err
+		}
+
+		self.registeredCollections.Delete(name)
+		return self.store.Delete(KeyValueSchemaBucket, name)
+	} else {
+		return err
+	}
+}
+
+func (self *KeyValueBackend) ListCollections() ([]string, error) {
+	names := make([]string, 0)
+
+	if err := self.store.Keys(KeyValueSchemaBucket, func(key string) error {
+		names = append(names, key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+func (self *KeyValueBackend) GetCollection(name string) (*dal.Collection, error) {
+	if c, ok := self.registeredCollections.Load(name); ok {
+		return c.(*dal.Collection), nil
+	}
+
+	if data, err := self.store.Get(KeyValueSchemaBucket, name); err == nil {
+		if data == nil {
+			return nil, dal.CollectionNotFound
+		}
+
+		var collection dal.Collection
+
+		if err := json.Unmarshal(data, &collection); err == nil {
+			self.RegisterCollection(&collection)
+			return &collection, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *KeyValueBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *KeyValueBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return nil
+}
+
+func (self *KeyValueBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+// Closes the underlying store.
+func (self *KeyValueBackend) Close() error {
+	return self.store.Close()
+}
+
+func (self *KeyValueBackend) keyFor(id interface{}) string {
+	return fmt.Sprintf("%v", id)
+}
+
+// reads and decodes the record stored at the given key, returning nil if there isn't one
+func (self *KeyValueBackend) read(collection *dal.Collection, key string) (*dal.Record, error) {
+	if data, revision, err := self.get(collection.Name, key); err == nil {
+		if data == nil {
+			return nil, nil
+		}
+
+		var stored kvRecord
+
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return nil, err
+		}
+
+		record := dal.NewRecord(stored.ID)
+		record.Fields = stored.Fields
+		record.Revision = revision
+
+		if record.Fields == nil {
+			record.Fields = make(map[string]interface{})
+		}
+
+		if collection.IdentityFieldType != dal.StringType {
+			record.ID = stringutil.Autotype(record.ID)
+		}
+
+		// do this AFTER populating the record's fields from the store
+		if err := record.Populate(record, collection); err != nil {
+			return nil, err
+		}
+
+		return record, nil
+	} else {
+		return nil, err
+	}
+}
+
+// returns the value stored at the given key, along with its revision if the store tracks them
+func (self *KeyValueBackend) get(bucket string, key string) ([]byte, string, error) {
+	if store, ok := self.store.(KeyValueRevisionStore); ok {
+		return store.GetRevision(bucket, key)
+	}
+
+	data, err := self.store.Get(bucket, key)
+	return data, ``, err
+}
+
+// Resolves the path of a database file given in a connection string (e.g.: "bolt:///./data.db",
+// "bolt:///~/data.db", or "bolt://data/pivot.db").
+func kvFilePath(conn dal.ConnectionString) (string, error) {
+	path := conn.Dataset()
+
+	if host := conn.Host(); host != `` {
+		path = host + `/` + path
+	} else if strings.HasPrefix(path, `~`) {
+		if v, err := pathutil.ExpandUser(path); err == nil {
+			path = v
+		} else {
+			return ``, err
+		}
+	} else if !strings.HasPrefix(path, `.`) {
+		path = `/` + path
+	}
+
+	return filepath.Abs(path)
+}
@@ -1,9 +1,37 @@
 package backends
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/ghetzel/pivot/dal"
 )
 
 type Migratable interface {
 	Migrate(diff []dal.SchemaDelta) error
 }
+
+// Returned when a migration fails partway through, identifying the fields that had already been
+// added to the collection before the failure.
+type MigrationError struct {
+	Collection string
+	Added      []string
+	Err        error
+}
+
+func (self *MigrationError) Error() string {
+	if len(self.Added) > 0 {
+		return fmt.Sprintf(
+			"Cannot migrate collection %q (fields already added: %s): %v",
+			self.Collection,
+			strings.Join(self.Added, `, `),
+			self.Err,
+		)
+	} else {
+		return fmt.Sprintf("Cannot migrate collection %q: %v", self.Collection, self.Err)
+	}
+}
+
+func (self *MigrationError) Unwrap() error {
+	return self.Err
+}
@@ -0,0 +1,570 @@
+package backends
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// A call made to a MockBackend, with the arguments it was given.
+type MockCall struct {
+	Method string
+	Args   []interface{}
+}
+
+// A Backend for unit testing code built on pivot.  By default it stores collections and records in
+// memory and supports querying them, so it can stand in for a real database.  Every call is recorded,
+// and the result of any method can be replaced by setting the corresponding *Func field, or made to
+// fail by calling FailWith.
+type MockBackend struct {
+	InsertFunc           func(collection string, records *dal.RecordSet) error
+	UpdateFunc           func(collection string, records *dal.RecordSet, target ...string) error
+	RetrieveFunc         func(collection string, id interface{}, fields ...string) (*dal.Record, error)
+	DeleteFunc           func(collection string, ids ...interface{}) error
+	ExistsFunc           func(collection string, id interface{}) bool
+	CreateCollectionFunc func(definition *dal.Collection) error
+	DeleteCollectionFunc func(collection string) error
+	GetCollectionFunc    func(collection string) (*dal.Collection, error)
+	QueryResultsFunc     func(collection *dal.Collection, f *filter.Filter) (*dal.RecordSet, error)
+	connection           dal.ConnectionString
+	calls                []MockCall
+	errors               map[string]error
+	collections          map[string]*dal.Collection
+	records              map[string]map[string]*dal.Record
+	lock                 sync.RWMutex
+}
+
+func NewMockBackend() *MockBackend {
+	return &MockBackend{
+		errors:      make(map[string]error),
+		collections: make(map[string]*dal.Collection),
+		records:     make(map[string]map[string]*dal.Record),
+	}
+}
+
+// Returns a MockBackend for use with MakeBackend (as the "mock" backend).
+func NewMockBackendFromConnectionString(connection dal.ConnectionString) Backend {
+	backend := NewMockBackend()
+	backend.connection = connection
+	return backend
+}
+
+// Causes every subsequent call to the named method (e.g.: "Insert") to return the given error.  A nil
+// error restores the default behavior.
+func (self *MockBackend) FailWith(method string, err error) *MockBackend {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if err == nil {
+		delete(self.errors, method)
+	} else {
+		self.errors[method] = err
+	}
+
+	return self
+}
+
+// Returns every call made so far, in the order they were made.
+func (self *MockBackend) Calls() []MockCall {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return append([]MockCall(nil), self.calls...)
+}
+
+// Returns the calls made to the named method.
+func (self *MockBackend) CallsTo(method string) []MockCall {
+	calls := make([]MockCall, 0)
+
+	for _, call := range self.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Forgets all recorded calls and scripted errors, and removes all collections and records.
+func (self *MockBackend) Reset() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.calls = nil
+	self.errors = make(map[string]error)
+	self.collections = make(map[string]*dal.Collection)
+	self.records = make(map[string]map[string]*dal.Record)
+}
+
+// records the call, returning the error it should fail with (if any)
+func (self *MockBackend) call(method string, args ...interface{}) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.calls = append(self.calls, MockCall{
+		Method: method,
+		Args:   args,
+	})
+
+	return self.errors[method]
+}
+
+func (self *MockBackend) Initialize() error {
+	return self.call(`Initialize`)
+}
+
+func (self *MockBackend) SetIndexer(connection dal.ConnectionString) error {
+	return self.call(`SetIndexer`, connection)
+}
+
+func (self *MockBackend) RegisterCollection(collection *dal.Collection) {
+	self.call(`RegisterCollection`, collection)
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.collections[collection.Name] = collection
+}
+
+func (self *MockBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.connection
+}
+
+func (self *MockBackend) Ping(timeout time.Duration) error {
+	return self.call(`Ping`, timeout)
+}
+
+func (self *MockBackend) Exists(name string, id interface{}) bool {
+	if err := self.call(`Exists`, name, id); err != nil {
+		return false
+	} else if self.ExistsFunc != nil {
+		return self.ExistsFunc(name, id)
+	}
+
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	_, ok := self.records[name][fmt.Sprintf("%v", id)]
+	return ok
+}
+
+func (self *MockBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if err := self.call(`Retrieve`, name, id, fields); err != nil {
+		return nil, err
+	} else if self.RetrieveFunc != nil {
+		return self.RetrieveFunc(name, id, fields...)
+	}
+
+	if _, err := self.getCollection(name); err != nil {
+		return nil, err
+	}
+
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if record, ok := self.records[name][fmt.Sprintf("%v", id)]; ok {
+		out := copyMockRecord(record)
+
+		if len(fields) > 0 {
+			out.Fields = make(map[string]interface{})
+
+			for _, field := range fields {
+				if value, ok := record.Fields[field]; ok {
+					out.Fields[field] = value
+				}
+			}
+		}
+
+		return out, nil
+	}
+
+	return nil, dal.RecordNotFound(id)
+}
+
+func (self *MockBackend) Insert(name string, recordset *dal.RecordSet) error {
+	if err := self.call(`Insert`, name, recordset); err != nil {
+		return err
+	} else if self.InsertFunc != nil {
+		return self.InsertFunc(name, recordset)
+	}
+
+	return self.write(name, recordset, true)
+}
+
+func (self *MockBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	if err := self.call(`Update`, name, recordset, target); err != nil {
+		return err
+	} else if self.UpdateFunc != nil {
+		return self.UpdateFunc(name, recordset, target...)
+	}
+
+	return self.write(name, recordset, false)
+}
+
+func (self *MockBackend) write(name string, recordset *dal.RecordSet, insert bool) error {
+	collection, err := self.getCollection(name)
+
+	if err != nil {
+		return err
+	}
+
+	records := make([]*dal.Record, 0, len(recordset.Records))
+
+	for _, record := range recordset.Records {
+		if r, err := collection.MakeRecord(copyMockRecord(record)); err == nil {
+			r.ID = mockIdentity(collection, r.ID)
+			records = append(records, r)
+		} else {
+			return err
+		}
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.records[name] == nil {
+		self.records[name] = make(map[string]*dal.Record)
+	}
+
+	if insert {
+		for _, record := range records {
+			if _, ok := self.records[name][fmt.Sprintf("%v", record.ID)]; ok {
+				return &dal.Error{
+					Kind:    dal.ErrUniqueViolation,
+					Message: fmt.Sprintf("Record %v already exists", record.ID),
+				}
+			}
+		}
+	}
+
+	for _, record := range records {
+		key := fmt.Sprintf("%v", record.ID)
+
+		if existing, ok := self.records[name][key]; ok {
+			// updates only change the fields they are given
+			merged := copyMockRecord(existing)
+
+			for k, v := range record.Fields {
+				merged.Fields[k] = v
+			}
+
+			record = merged
+		}
+
+		self.records[name][key] = record
+	}
+
+	return nil
+}
+
+func (self *MockBackend) Delete(name string, ids ...interface{}) error {
+	if err := self.call(`Delete`, name, ids); err != nil {
+		return err
+	} else if self.DeleteFunc != nil {
+		return self.DeleteFunc(name, ids...)
+	}
+
+	if _, err := self.getCollection(name); err != nil {
+		return err
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for _, id := range ids {
+		delete(self.records[name], fmt.Sprintf("%v", id))
+	}
+
+	return nil
+}
+
+func (self *MockBackend) CreateCollection(definition *dal.Collection) error {
+	if err := self.call(`CreateCollection`, definition); err != nil {
+		return err
+	} else if self.CreateCollectionFunc != nil {
+		return self.CreateCollectionFunc(definition)
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.collections[definition.Name] = definition
+	self.records[definition.Name] = make(map[string]*dal.Record)
+
+	return nil
+}
+
+func (self *MockBackend) DeleteCollection(name string) error {
+	if err := self.call(`DeleteCollection`, name); err != nil {
+		return err
+	} else if self.DeleteCollectionFunc != nil {
+		return self.DeleteCollectionFunc(name)
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if _, ok := self.collections[name]; !ok {
+		return dal.CollectionNotFound
+	}
+
+	delete(self.collections, name)
+	delete(self.records, name)
+
+	return nil
+}
+
+func (self *MockBackend) ListCollections() ([]string, error) {
+	if err := self.call(`ListCollections`); err != nil {
+		return nil, err
+	}
+
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	names := make([]string, 0, len(self.collections))
+
+	for name := range self.collections {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (self *MockBackend) GetCollection(name string) (*dal.Collection, error) {
+	if err := self.call(`GetCollection`, name); err != nil {
+		return nil, err
+	}
+
+	return self.getCollection(name)
+}
+
+// looks up a collection without recording the call
+func (self *MockBackend) getCollection(name string) (*dal.Collection, error) {
+	if self.GetCollectionFunc != nil {
+		return self.GetCollectionFunc(name)
+	}
+
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if collection, ok := self.collections[name]; ok {
+		return collection, nil
+	}
+
+	return nil, dal.CollectionNotFound
+}
+
+func (self *MockBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self
+}
+
+func (self *MockBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return nil
+}
+
+func (self *MockBackend) Flush() error {
+	return self.call(`Flush`)
+}
+
+func (self *MockBackend) IndexConnectionString() *dal.ConnectionString {
+	return &self.connection
+}
+
+func (self *MockBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *MockBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *MockBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.Name, id)
+}
+
+func (self *MockBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.Name, id)
+}
+
+func (self *MockBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *MockBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Calls resultFn for each record matching the filter, sorted by the filter's sort fields (or by ID).
+func (self *MockBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if err := self.call(`QueryFunc`, collection, f); err != nil {
+		return err
+	}
+
+	matches := self.matching(collection, f)
+	total := int64(len(matches))
+
+	if f.Offset >= len(matches) {
+		matches = nil
+	} else {
+		matches = matches[f.Offset:]
+	}
+
+	if f.Limit > 0 && len(matches) > f.Limit {
+		matches = matches[:f.Limit]
+	}
+
+	for _, record := range matches {
+		if err := resultFn(record, nil, IndexPage{
+			Page:         1,
+			Limit:        f.Limit,
+			Offset:       f.Offset,
+			TotalResults: total,
+		}); err != nil {
+			return queryStopped(err)
+		}
+	}
+
+	return nil
+}
+
+func (self *MockBackend) matching(collection *dal.Collection, f *filter.Filter) []*dal.Record {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	matches := make([]*dal.Record, 0)
+
+	for _, record := range self.records[collection.Name] {
+		if f.MatchesRecord(record) {
+			matches = append(matches, copyMockRecord(record))
+		}
+	}
+
+	sortRecords(collection, matches, f)
+
+	return matches
+}
+
+func (self *MockBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if err := self.call(`Query`, collection, f); err != nil {
+		return nil, err
+	} else if self.QueryResultsFunc != nil {
+		return self.QueryResultsFunc(collection, f)
+	}
+
+	recordset := dal.NewRecordSet()
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		defer PopulateRecordSetPageDetails(recordset, f, page)
+
+		if len(resultFns) > 0 {
+			return resultFns[0](record, err, page)
+		}
+
+		recordset.Push(record)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return recordset, nil
+}
+
+func (self *MockBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+	seen := make(map[string]map[string]bool)
+
+	err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		for _, field := range fields {
+			value := record.Get(field)
+
+			if field == collection.IdentityField {
+				value = record.ID
+			}
+
+			if seen[field] == nil {
+				seen[field] = make(map[string]bool)
+			}
+
+			if key := fmt.Sprintf("%v", value); !seen[field][key] {
+				seen[field][key] = true
+				values[field] = append(values[field], value)
+			}
+		}
+
+		return nil
+	})
+
+	return values, err
+}
+
+func (self *MockBackend) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	return DefaultFacetsImplementation(self, collection, fields, f)
+}
+
+func (self *MockBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		ids = append(ids, record.ID)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return self.Delete(collection.Name, ids...)
+}
+
+func (self *MockBackend) FlushIndex() error {
+	return nil
+}
+
+func copyMockRecord(record *dal.Record) *dal.Record {
+	out := dal.NewRecord(record.ID)
+
+	for k, v := range record.Fields {
+		out.Fields[k] = v
+	}
+
+	return out
+}
+
+// IDs are stored as the collection's identity type, so that "1" and 1 refer to the same record
+func mockIdentity(collection *dal.Collection, id interface{}) interface{} {
+	if id == nil {
+		return nil
+	}
+
+	switch collection.IdentityFieldType {
+	case dal.IntType:
+		if v, err := stringutil.ConvertToInteger(id); err == nil {
+			return v
+		}
+	case dal.StringType:
+		return fmt.Sprintf("%v", id)
+	}
+
+	return id
+}
+
+// sorts records by the filter's sort fields (or by ID), for backends that can't sort natively
+func sortRecords(collection *dal.Collection, records []*dal.Record, f *filter.Filter) {
+	sortBy := f.GetSort()
+
+	if len(sortBy) == 0 {
+		sortBy = []filter.SortBy{{
+			Field: collection.IdentityField,
+		}}
+	}
+
+	sort.SliceStable(records, func(i int, j int) bool {
+		return compareRecords(records[i], records[j], sortBy, collection.IdentityField) < 0
+	})
+}
@@ -0,0 +1,248 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockUsers() (*MockBackend, *dal.Collection, error) {
+	mock := NewMockBackend()
+	collection := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	})
+
+	if err := mock.CreateCollection(collection); err != nil {
+		return nil, nil, err
+	}
+
+	return mock, collection, mock.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `alice`).Set(`age`, 31),
+		dal.NewRecord(2).Set(`name`, `bob`).Set(`age`, 25),
+		dal.NewRecord(3).Set(`name`, `carol`).Set(`age`, 40),
+	))
+}
+
+func TestMockBackendCRUD(t *testing.T) {
+	assert := require.New(t)
+
+	mock, _, err := newMockUsers()
+	assert.NoError(err)
+
+	// IDs are normalized to the identity type
+	assert.True(mock.Exists(`users`, `1`))
+	assert.False(mock.Exists(`users`, 4))
+
+	record, err := mock.Retrieve(`users`, `2`)
+	assert.NoError(err)
+	assert.EqualValues(2, record.ID)
+	assert.Equal(`bob`, record.Get(`name`))
+
+	record, err = mock.Retrieve(`users`, 1, `age`)
+	assert.NoError(err)
+	assert.Nil(record.Get(`name`))
+	assert.EqualValues(31, record.Get(`age`))
+
+	_, err = mock.Retrieve(`users`, 4)
+	assert.True(dal.IsNotExistError(err))
+
+	_, err = mock.Retrieve(`groups`, 1)
+	assert.True(dal.IsCollectionNotFoundErr(err))
+
+	// retrieved records are copies
+	record.Set(`age`, 99)
+	record, err = mock.Retrieve(`users`, 1)
+	assert.NoError(err)
+	assert.EqualValues(31, record.Get(`age`))
+
+	// inserts refuse existing records; updates only change the given fields
+	err = mock.Insert(`users`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `again`)))
+	assert.True(errors.Is(err, dal.ErrUniqueViolation))
+
+	assert.NoError(mock.Update(`users`, dal.NewRecordSet(dal.NewRecord(1).Set(`age`, 32))))
+
+	record, err = mock.Retrieve(`users`, 1)
+	assert.NoError(err)
+	assert.Equal(`alice`, record.Get(`name`))
+	assert.EqualValues(32, record.Get(`age`))
+
+	assert.NoError(mock.Delete(`users`, 1, `2`))
+	assert.False(mock.Exists(`users`, 1))
+	assert.False(mock.Exists(`users`, 2))
+	assert.True(mock.Exists(`users`, 3))
+
+	// collections
+	names, err := mock.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`users`}, names)
+
+	assert.NoError(mock.DeleteCollection(`users`))
+	assert.True(dal.IsCollectionNotFoundErr(mock.DeleteCollection(`users`)))
+
+	_, err = mock.GetCollection(`users`)
+	assert.True(dal.IsCollectionNotFoundErr(err))
+}
+
+func TestMockBackendQuery(t *testing.T) {
+	assert := require.New(t)
+
+	mock, collection, err := newMockUsers()
+	assert.NoError(err)
+
+	search := mock.WithSearch(collection)
+	assert.NotNil(search)
+	assert.Nil(mock.WithAggregator(collection))
+
+	recordset, err := search.Query(collection, filter.MustParse(`age/gt:30`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+	assert.EqualValues(1, recordset.Records[0].ID)
+	assert.EqualValues(3, recordset.Records[1].ID)
+
+	// sorting, limits and offsets
+	f := filter.MustParse(`all`)
+	f.Sort = []string{`-age`}
+	f.Limit = 2
+	f.Offset = 1
+
+	recordset, err = search.Query(collection, f)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+	assert.EqualValues(1, recordset.Records[0].ID)
+	assert.EqualValues(2, recordset.Records[1].ID)
+	assert.Equal(int64(3), recordset.ResultCount)
+
+	// results can be stopped early
+	var seen int
+
+	assert.NoError(search.QueryFunc(collection, filter.All(), func(record *dal.Record, err error, page IndexPage) error {
+		seen += 1
+		return IndexResultsStop
+	}))
+
+	assert.Equal(1, seen)
+
+	values, err := search.ListValues(collection, []string{`name`}, filter.MustParse(`age/lt:35`))
+	assert.NoError(err)
+	assert.ElementsMatch([]interface{}{`alice`, `bob`}, values[`name`])
+
+	assert.NoError(search.DeleteQuery(collection, filter.MustParse(`name/bob`)))
+	assert.False(mock.Exists(`users`, 2))
+	assert.True(mock.Exists(`users`, 1))
+
+	// canned results can be returned instead
+	mock.QueryResultsFunc = func(collection *dal.Collection, f *filter.Filter) (*dal.RecordSet, error) {
+		return dal.NewRecordSet(dal.NewRecord(`canned`)), nil
+	}
+
+	recordset, err = search.Query(collection, filter.All())
+	assert.NoError(err)
+	assert.Equal(`canned`, recordset.Records[0].ID)
+}
+
+func TestMockBackendCalls(t *testing.T) {
+	assert := require.New(t)
+
+	mock, _, err := newMockUsers()
+	assert.NoError(err)
+
+	mock.Exists(`users`, 1)
+	mock.Retrieve(`users`, 2, `name`)
+
+	calls := mock.Calls()
+	assert.Len(calls, 4)
+	assert.Equal(`CreateCollection`, calls[0].Method)
+	assert.Equal(`Insert`, calls[1].Method)
+	assert.Equal(MockCall{
+		Method: `Exists`,
+		Args:   []interface{}{`users`, 1},
+	}, calls[2])
+	assert.Equal(MockCall{
+		Method: `Retrieve`,
+		Args:   []interface{}{`users`, 2, []string{`name`}},
+	}, calls[3])
+
+	assert.Len(mock.CallsTo(`Retrieve`), 1)
+	assert.Empty(mock.CallsTo(`Delete`))
+
+	// the returned calls are a copy
+	calls[0].Method = `changed`
+	assert.Equal(`CreateCollection`, mock.Calls()[0].Method)
+
+	mock.Reset()
+	assert.Empty(mock.Calls())
+
+	names, err := mock.ListCollections()
+	assert.NoError(err)
+	assert.Empty(names)
+}
+
+func TestMockBackendScriptedErrors(t *testing.T) {
+	assert := require.New(t)
+
+	mock, _, err := newMockUsers()
+	assert.NoError(err)
+
+	failure := fmt.Errorf("disk full")
+	assert.Equal(mock, mock.FailWith(`Insert`, failure))
+
+	// scripted errors persist until cleared, and the call is still recorded
+	for i := 0; i < 2; i++ {
+		assert.Equal(failure, mock.Insert(`users`, dal.NewRecordSet(dal.NewRecord(10+i))))
+	}
+
+	assert.Len(mock.CallsTo(`Insert`), 3)
+	assert.False(mock.Exists(`users`, 10))
+
+	mock.FailWith(`Exists`, failure)
+	assert.False(mock.Exists(`users`, 1))
+
+	mock.FailWith(`Insert`, nil)
+	assert.NoError(mock.Insert(`users`, dal.NewRecordSet(dal.NewRecord(10))))
+
+	// the behavior of individual methods can be replaced
+	mock.RetrieveFunc = func(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+		return dal.NewRecord(id).Set(`name`, `stubbed`), nil
+	}
+
+	record, err := mock.Retrieve(`anything`, 99)
+	assert.NoError(err)
+	assert.Equal(`stubbed`, record.Get(`name`))
+
+	// but scripted errors take precedence
+	mock.FailWith(`Retrieve`, failure)
+	_, err = mock.Retrieve(`anything`, 99)
+	assert.Equal(failure, err)
+
+	// Reset clears scripted errors
+	mock.Reset()
+
+	_, err = mock.Retrieve(`anything`, 99)
+	assert.NoError(err)
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+	assert.NoError(mock.Insert(`users`, dal.NewRecordSet(dal.NewRecord(1))))
+}
+
+func TestMockBackendFromConnectionString(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`mock://test`)
+	assert.NoError(err)
+
+	backend, err := MakeBackend(cs)
+	assert.NoError(err)
+
+	mock, ok := backend.(*MockBackend)
+	assert.True(ok)
+	assert.Equal(`mock`, mock.GetConnectionString().Backend())
+	assert.Equal(mock.GetConnectionString(), mock.IndexConnectionString())
+	assert.Equal(mock, mock.GetBackend())
+}
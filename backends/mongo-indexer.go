@@ -73,7 +73,8 @@ func (self *MongoBackend) QueryFunc(collection *dal.Collection, flt *filter.Filt
 							Offset:       flt.Offset,
 							TotalResults: int64(totalResults),
 						}); err != nil {
-							return err
+							iter.Close()
+							return queryStopped(err)
 						}
 
 						result = nil
@@ -130,6 +131,10 @@ func (self *MongoBackend) ListValues(collection *dal.Collection, fields []string
 	}
 }
 
+func (self *MongoBackend) Facets(collection *dal.Collection, fields []string, flt *filter.Filter) (map[string][]FacetBucket, error) {
+	return DefaultFacetsImplementation(self, collection, fields, flt)
+}
+
 func (self *MongoBackend) DeleteQuery(collection *dal.Collection, flt *filter.Filter) error {
 	if query, err := self.filterToNative(collection, flt); err == nil {
 		if _, err := self.db.C(collection.Name).RemoveAll(&query); err == nil {
@@ -146,6 +151,35 @@ func (self *MongoBackend) FlushIndex() error {
 	return nil
 }
 
+// Renders the given filter into a MongoDB query document and retrieves the server's explanation of
+// how it will be executed.
+func (self *MongoBackend) Explain(collection *dal.Collection, flt *filter.Filter) (*QueryPlan, error) {
+	if query, err := self.filterToNative(collection, flt); err == nil {
+		plan := &QueryPlan{
+			Collection: collection.Name,
+			Filter:     flt.String(),
+			Backend:    `mongodb`,
+		}
+
+		if data, err := json.Marshal(query); err == nil {
+			plan.Query = string(data)
+		} else {
+			return nil, err
+		}
+
+		var explanation map[string]interface{}
+
+		if err := self.db.C(collection.Name).Find(query).Explain(&explanation); err == nil {
+			plan.Plan = []map[string]interface{}{explanation}
+			return plan, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
 func (self *MongoBackend) filterToNative(collection *dal.Collection, flt *filter.Filter) (bson.M, error) {
 	if data, err := filter.Render(
 		generators.NewMongoDBGenerator(),
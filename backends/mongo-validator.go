@@ -0,0 +1,83 @@
+package backends
+
+import (
+	"github.com/ghetzel/pivot/dal"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The BSON types accepted for values of each field type.  Values are written as given rather than
+// converted to their field's type, so these include the types that such values are commonly
+// stored as (e.g.: times given as strings).
+var MongoValidatorTypes = map[dal.Type][]string{
+	dal.StringType:  {`string`, `objectId`},
+	dal.BooleanType: {`bool`},
+	dal.IntType:     {`int`, `long`},
+	dal.FloatType:   {`double`, `decimal`, `int`, `long`},
+	dal.TimeType:    {`date`, `timestamp`, `string`},
+	dal.ObjectType:  {`object`, `array`},
+	dal.RawType:     {`binData`, `string`},
+	dal.PointType:   {`object`},
+	dal.PolygonType: {`object`},
+}
+
+// Returns the options used to create a Mongo collection for the given definition, including a
+// $jsonSchema validator that enforces the types of its fields and the presence of required ones.
+//
+// The "validationLevel" and "validationAction" connection string options are given to MongoDB
+// as-is; a validationLevel of "off" creates collections without a validator.
+func (self *MongoBackend) collectionInfo(definition *dal.Collection) *mgo.CollectionInfo {
+	info := &mgo.CollectionInfo{
+		ValidationLevel:  self.conn.OptString(`validationLevel`, `moderate`),
+		ValidationAction: self.conn.OptString(`validationAction`, `error`),
+	}
+
+	if info.ValidationLevel == `off` {
+		return &mgo.CollectionInfo{}
+	}
+
+	properties := bson.M{}
+	required := make([]string, 0)
+
+	for _, field := range definition.Fields {
+		// the identity is stored as _id, whose type is up to the caller
+		if field.Name == definition.IdentityField || field.Name == MongoIdentityField {
+			continue
+		}
+
+		types, ok := MongoValidatorTypes[field.Type]
+
+		if !ok {
+			continue
+		}
+
+		if field.Required {
+			required = append(required, field.Name)
+		} else {
+			types = append(append([]string{}, types...), `null`)
+		}
+
+		properties[field.Name] = bson.M{
+			`bsonType`: types,
+		}
+	}
+
+	if len(properties) == 0 {
+		return &mgo.CollectionInfo{}
+	}
+
+	schema := bson.M{
+		`bsonType`:   `object`,
+		`properties`: properties,
+	}
+
+	if len(required) > 0 {
+		schema[`required`] = required
+	}
+
+	info.Validator = bson.M{
+		`$jsonSchema`: schema,
+	}
+
+	return info
+}
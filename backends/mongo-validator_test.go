@@ -0,0 +1,96 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func newMongoValidatorTestBackend(connString string) (*MongoBackend, error) {
+	if cs, err := dal.ParseConnectionString(connString); err == nil {
+		return NewMongoBackend(cs).(*MongoBackend), nil
+	} else {
+		return nil, err
+	}
+}
+
+func TestMongoCollectionInfo(t *testing.T) {
+	assert := require.New(t)
+
+	backend, err := newMongoValidatorTestBackend(`mongodb://localhost/test`)
+	assert.NoError(err)
+
+	collection := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `id`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name:     `name`,
+		Type:     dal.StringType,
+		Required: true,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name: `created_at`,
+		Type: dal.TimeType,
+	}, dal.Field{
+		Name: `mystery`,
+		Type: dal.Type(`unknown`),
+	})
+
+	info := backend.collectionInfo(collection)
+	assert.Equal(`moderate`, info.ValidationLevel)
+	assert.Equal(`error`, info.ValidationAction)
+
+	// required fields must be present and of the right type; others may also be null, and the
+	// identity and fields of unknown types are left alone
+	assert.Equal(bson.M{
+		`$jsonSchema`: bson.M{
+			`bsonType`: `object`,
+			`required`: []string{`name`},
+			`properties`: bson.M{
+				`name`: bson.M{
+					`bsonType`: []string{`string`, `objectId`},
+				},
+				`age`: bson.M{
+					`bsonType`: []string{`int`, `long`, `null`},
+				},
+				`created_at`: bson.M{
+					`bsonType`: []string{`date`, `timestamp`, `string`, `null`},
+				},
+			},
+		},
+	}, info.Validator)
+
+	// the default types aren't modified when null is added
+	assert.Equal([]string{`int`, `long`}, MongoValidatorTypes[dal.IntType])
+
+	// collections without typed fields aren't validated
+	assert.Equal(&mgo.CollectionInfo{}, backend.collectionInfo(dal.NewCollection(`empty`)))
+}
+
+func TestMongoCollectionInfoOptions(t *testing.T) {
+	assert := require.New(t)
+
+	collection := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name:     `name`,
+		Type:     dal.StringType,
+		Required: true,
+	})
+
+	backend, err := newMongoValidatorTestBackend(`mongodb://localhost/test?validationLevel=strict&validationAction=warn`)
+	assert.NoError(err)
+
+	info := backend.collectionInfo(collection)
+	assert.Equal(`strict`, info.ValidationLevel)
+	assert.Equal(`warn`, info.ValidationAction)
+	assert.NotNil(info.Validator)
+
+	// validation can be turned off entirely
+	backend, err = newMongoValidatorTestBackend(`mongodb://localhost/test?validationLevel=off`)
+	assert.NoError(err)
+	assert.Equal(&mgo.CollectionInfo{}, backend.collectionInfo(collection))
+}
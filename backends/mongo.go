@@ -143,7 +143,7 @@ func (self *MongoBackend) Retrieve(name string, id interface{}, fields ...string
 		if err := self.db.C(collection.Name).FindId(self.getId(id)).One(&data); err == nil {
 			return self.recordFromResult(collection, data, fields...)
 		} else if err == mgo.ErrNotFound {
-			return nil, fmt.Errorf("Record %v does not exist", id)
+			return nil, dal.RecordNotFound(id)
 		} else {
 			return nil, err
 		}
@@ -157,7 +157,7 @@ func (self *MongoBackend) Insert(name string, records *dal.RecordSet) error {
 		for _, record := range records.Records {
 			if _, err := collection.MakeRecord(record); err == nil {
 				self.normalizeRecordValues(record)
-				data := self.prepareValuesForWrite(record.Fields)
+				data := self.prepareValuesForWrite(convertGeometryFields(collection, record.Fields, toGeoJSON))
 
 				if record.ID != nil {
 					data[MongoIdentityField] = self.getId(record.ID)
@@ -165,7 +165,9 @@ func (self *MongoBackend) Insert(name string, records *dal.RecordSet) error {
 					delete(data, MongoIdentityField)
 				}
 
-				if err := self.db.C(collection.Name).Insert(&data); err != nil {
+				if err := self.db.C(collection.Name).Insert(&data); mgo.IsDup(err) {
+					return dal.UniqueViolation(err)
+				} else if err != nil {
 					return err
 				}
 			} else {
@@ -184,12 +186,16 @@ func (self *MongoBackend) Update(name string, records *dal.RecordSet, target ...
 		for _, record := range records.Records {
 			if _, err := collection.MakeRecord(record); err == nil {
 				self.normalizeRecordValues(record)
-				data := self.prepareValuesForWrite(record.Fields)
+				data := self.prepareValuesForWrite(convertGeometryFields(collection, record.Fields, toGeoJSON))
 
 				if record.ID == nil {
 					return fmt.Errorf("Cannot update record without an ID")
 				} else {
-					if err := self.db.C(collection.Name).UpdateId(self.getId(record.ID), data); err != nil {
+					if err := self.db.C(collection.Name).UpdateId(self.getId(record.ID), data); err == mgo.ErrNotFound {
+						return dal.RecordNotFound(record.ID)
+					} else if mgo.IsDup(err) {
+						return dal.UniqueViolation(err)
+					} else if err != nil {
 						return err
 					}
 				}
@@ -222,7 +228,18 @@ func (self *MongoBackend) CreateCollection(definition *dal.Collection) error {
 	if _, err := self.GetCollection(definition.Name); err == nil {
 		return fmt.Errorf("Collection %v already exists", definition.Name)
 	} else if dal.IsCollectionNotFoundErr(err) {
-		if err := self.db.C(definition.Name).Create(&mgo.CollectionInfo{}); err == nil {
+		if err := self.db.C(definition.Name).Create(self.collectionInfo(definition)); err == nil {
+			// geometry fields are stored as GeoJSON, which 2dsphere indexes make queryable
+			for _, field := range definition.Fields {
+				if field.Type.IsGeometry() {
+					if err := self.db.C(definition.Name).EnsureIndex(mgo.Index{
+						Key: []string{`$2dsphere:` + field.Name},
+					}); err != nil {
+						return err
+					}
+				}
+			}
+
 			self.registeredCollections.Store(definition.Name, definition)
 			return nil
 		} else {
@@ -313,8 +330,15 @@ func (self *MongoBackend) recordFromResult(collection *dal.Collection, data map[
 		for k, v := range data {
 			v = self.fromId(v)
 
-			if _, ok := collection.GetField(k); ok || len(collection.Fields) == 0 {
+			if field, ok := collection.GetField(k); ok || len(collection.Fields) == 0 {
 				if len(fields) == 0 || sliceutil.ContainsString(fields, k) {
+					// geometries are read back from GeoJSON
+					if field.Type.IsGeometry() {
+						if geometry, err := field.ConvertValue(v); err == nil {
+							v = geometry
+						}
+					}
+
 					record.Set(k, v)
 				}
 			}
@@ -4,4 +4,15 @@ type ConnectOptions struct {
 	Indexer            string   `json:"indexer"`
 	AdditionalIndexers []string `json:"additional_indexers"`
 	SkipInitialize     bool     `json:"skip_initialize"`
+
+	// The datacenter to connect to on backends that span several (e.g.: Consul), overriding the
+	// "datacenter" option of the connection string.
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// The queries used to list tables and describe their columns on SQL databases connected to in
+	// ANSI mode (e.g.: "odbc://MyDataSource"), overriding the "listTablesQuery" and
+	// "describeTableQuery" options of the connection string.  See AnsiListTablesQuery and
+	// AnsiDescribeTableQuery for what they should return.
+	ListTablesQuery    string `json:"list_tables_query,omitempty"`
+	DescribeTableQuery string `json:"describe_table_query,omitempty"`
 }
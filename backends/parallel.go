@@ -0,0 +1,264 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// Implemented by indexers that can split a filter into sub-filters which together match the same
+// records, each of which can be queried independently of the others.
+type Partitioner interface {
+	Partitions(collection *dal.Collection, f *filter.Filter) ([]*filter.Filter, error)
+}
+
+// Queries each of the given partitions concurrently using at most f.Parallelism workers, then
+// merges the results in the order specified by the filter's sort fields before applying its offset
+// and limit.  Each partition is expected to return its results already sorted.
+func ParallelQuery(indexer Indexer, collection *dal.Collection, f *filter.Filter, partitions []*filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	workers := f.Parallelism
+
+	if workers > len(partitions) {
+		workers = len(partitions)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]*dal.RecordSet, len(partitions))
+	errs := make([]error, len(partitions))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				sub := filter.Copy(partitions[i])
+				sub.Parallelism = 0
+				sub.Sort = f.Sort
+				sub.Fields = f.Fields
+				sub.IdentityField = f.IdentityField
+				sub.Offset = 0
+				sub.Limit = 0
+
+				// no partition can contribute more than offset+limit records to the merged result
+				if f.Limit > 0 {
+					sub.Limit = f.Offset + f.Limit
+				}
+
+				results[i], errs[i] = indexer.Query(collection, &sub)
+			}
+		}()
+	}
+
+	started := time.Now()
+
+	for i := range partitions {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	querylog.Debugf("[%T] queried %d partitions of %s in %v", indexer, len(partitions), collection.Name, time.Since(started))
+
+	var total int64
+	knownSize := true
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("partition %d: %v", i, err)
+		}
+
+		if results[i].KnownSize {
+			total += results[i].ResultCount
+		} else {
+			knownSize = false
+		}
+	}
+
+	merged, err := mergeSortedRecords(results, f.GetSort(), collection.IdentityField)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Offset > 0 {
+		if f.Offset < len(merged) {
+			merged = merged[f.Offset:]
+		} else {
+			merged = nil
+		}
+	}
+
+	if f.Limit > 0 && len(merged) > f.Limit {
+		merged = merged[:f.Limit]
+	}
+
+	page := IndexPage{
+		Limit:        f.Limit,
+		Offset:       f.Offset,
+		TotalResults: -1,
+	}
+
+	if knownSize {
+		page.TotalResults = total
+	}
+
+	recordset := dal.NewRecordSet()
+
+	for _, record := range merged {
+		if len(resultFns) > 0 {
+			if err := resultFns[0](record, nil, page); err == IndexResultsStop {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+		} else {
+			recordset.Records = append(recordset.Records, record)
+		}
+	}
+
+	PopulateRecordSetPageDetails(recordset, f, page)
+
+	return recordset, nil
+}
+
+// Performs a k-way merge of the records in the given recordsets, each of which must already be sorted
+// by the given fields.  If no sort is given, the recordsets are concatenated in order.
+func mergeSortedRecords(recordsets []*dal.RecordSet, sortBy []filter.SortBy, identityField string) ([]*dal.Record, error) {
+	merged := make([]*dal.Record, 0)
+	partitions := make([][]*dal.Record, len(recordsets))
+
+	// read back any records a partition spilled to disk
+	for i, rs := range recordsets {
+		if err := rs.Each(func(record *dal.Record) error {
+			partitions[i] = append(partitions[i], record)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(sortBy) == 0 {
+		for _, records := range partitions {
+			merged = append(merged, records...)
+		}
+
+		return merged, nil
+	}
+
+	heads := make([]int, len(partitions))
+
+	for {
+		next := -1
+
+		for i, records := range partitions {
+			if heads[i] >= len(records) {
+				continue
+			}
+
+			if next < 0 || compareRecords(
+				records[heads[i]],
+				partitions[next][heads[next]],
+				sortBy,
+				identityField,
+			) < 0 {
+				next = i
+			}
+		}
+
+		if next < 0 {
+			return merged, nil
+		}
+
+		merged = append(merged, partitions[next][heads[next]])
+		heads[next] += 1
+	}
+}
+
+func compareRecords(a *dal.Record, b *dal.Record, sortBy []filter.SortBy, identityField string) int {
+	for _, sort := range sortBy {
+		var va, vb interface{}
+
+		if sort.Field == identityField || sort.Field == dal.DefaultIdentityField {
+			va, vb = a.ID, b.ID
+		} else if sort.Field == filter.RelevanceField {
+			va, vb = a.Score, b.Score
+		} else {
+			va, vb = a.Get(sort.Field), b.Get(sort.Field)
+		}
+
+		if c := compareValues(va, vb); c != 0 {
+			if sort.Descending {
+				return -c
+			}
+
+			return c
+		}
+	}
+
+	return 0
+}
+
+// Compares two values in the way a database would order them: nulls first, then numbers and times
+// by value, and everything else as strings.
+func compareValues(a interface{}, b interface{}) int {
+	if a == nil && b == nil {
+		return 0
+	} else if a == nil {
+		return -1
+	} else if b == nil {
+		return 1
+	}
+
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	if isNumeric(a) && isNumeric(b) {
+		fa, errA := stringutil.ConvertToFloat(a)
+		fb, errB := stringutil.ConvertToFloat(b)
+
+		if errA == nil && errB == nil {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+func isNumeric(value interface{}) bool {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	default:
+		return false
+	}
+}
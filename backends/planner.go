@@ -0,0 +1,323 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+type QueryStrategy string
+
+const (
+	// the search index satisfies the filter on its own
+	IndexStrategy QueryStrategy = `index`
+
+	// the backend satisfies the filter on its own
+	BackendStrategy QueryStrategy = `backend`
+
+	// the search index finds candidate records, which the backend retrieves and filters further
+	HybridStrategy QueryStrategy = `hybrid`
+)
+
+// Describes how a QueryPlanner will satisfy a filter.
+type PlannedQuery struct {
+	Strategy QueryStrategy
+
+	// Why this strategy was chosen.
+	Reason string
+
+	// The filter given to the search index to find records (or candidate records).
+	IndexFilter *filter.Filter
+
+	// The filter the backend applies to the records (or candidate records) it returns.
+	BackendFilter *filter.Filter
+}
+
+// A QueryPlanner decides, for each filter it is given, whether to satisfy it from a search index,
+// from the backend's own query implementation, or from both: the search index finding the IDs of
+// candidate records, and the backend retrieving those records and filtering them further.
+//
+// Filters with full-text criteria go to the search index, and any other criteria they have are
+// applied by the backend.  Filters without full-text criteria go to the backend, since its data is
+// authoritative.  If the backend cannot perform queries itself, the search index is used, with the
+// backend retrieving any fields the index does not store.
+//
+// Methods other than QueryFunc, Query, and Explain are those of the search index.
+type QueryPlanner struct {
+	Indexer
+	backend Backend
+	native  Indexer
+}
+
+// Returns a planner that uses the given search index and the backend's own query implementation
+// (which may be nil if the backend has none).
+func NewQueryPlanner(backend Backend, index Indexer, native Indexer) *QueryPlanner {
+	return &QueryPlanner{
+		Indexer: index,
+		backend: backend,
+		native:  native,
+	}
+}
+
+func (self *QueryPlanner) GetBackend() Backend {
+	return self.backend
+}
+
+// Decides how the given filter will be satisfied.
+func (self *QueryPlanner) Plan(collection *dal.Collection, f *filter.Filter) *PlannedQuery {
+	if f == nil {
+		f = filter.All()
+	}
+
+	switch {
+	case self.Indexer == nil:
+		return &PlannedQuery{
+			Strategy:      BackendStrategy,
+			Reason:        `no search index`,
+			BackendFilter: f,
+		}
+
+	case self.native == nil:
+		if fields := unstoredFields(collection, f); len(fields) > 0 {
+			return &PlannedQuery{
+				Strategy:    HybridStrategy,
+				Reason:      fmt.Sprintf("backend cannot query; index does not store %v", fields),
+				IndexFilter: f,
+			}
+		}
+
+		return &PlannedQuery{
+			Strategy:    IndexStrategy,
+			Reason:      `backend cannot query`,
+			IndexFilter: f,
+		}
+
+	case f.HasFullText():
+		text, rest := f.SplitFullText()
+
+		if rest == nil && len(unstoredFields(collection, f)) == 0 {
+			return &PlannedQuery{
+				Strategy:    IndexStrategy,
+				Reason:      `filter only contains full-text criteria`,
+				IndexFilter: f,
+			}
+		}
+
+		if rest == nil {
+			rest = filter.All()
+			rest.Sort = f.Sort
+			rest.Fields = f.Fields
+			rest.Limit = f.Limit
+			rest.Offset = f.Offset
+		}
+
+		// the backend sorts and paginates the final results
+		text.Sort = nil
+		text.Fields = []string{collection.IdentityField}
+		text.Limit = 0
+		text.Offset = 0
+
+		return &PlannedQuery{
+			Strategy:      HybridStrategy,
+			Reason:        `full-text criteria are searched in the index, others are applied by the backend`,
+			IndexFilter:   text,
+			BackendFilter: rest,
+		}
+
+	default:
+		return &PlannedQuery{
+			Strategy:      BackendStrategy,
+			Reason:        `filter has no full-text criteria`,
+			BackendFilter: f,
+		}
+	}
+}
+
+func (self *QueryPlanner) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	plan := self.Plan(collection, f)
+	querylog.Debugf("[%T] %s plan for %v: %v", self, plan.Strategy, f, plan.Reason)
+
+	switch plan.Strategy {
+	case IndexStrategy:
+		return self.Indexer.QueryFunc(collection, plan.IndexFilter, resultFn)
+
+	case BackendStrategy:
+		if self.native == nil {
+			return dal.Unsupported("Backend %T does not support complex queries", self.backend)
+		}
+
+		return self.native.QueryFunc(collection, plan.BackendFilter, resultFn)
+
+	default:
+		ids := make([]interface{}, 0)
+
+		if err := self.Indexer.QueryFunc(collection, plan.IndexFilter, func(record *dal.Record, err error, _ IndexPage) error {
+			if err != nil {
+				return err
+			} else if record != nil {
+				ids = append(ids, record.ID)
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		} else if len(ids) == 0 {
+			return nil
+		}
+
+		if self.native != nil {
+			backendFilter := filter.Copy(plan.BackendFilter)
+			backendFilter.MatchAll = false
+			backendFilter.Criteria = append([]filter.Criterion{
+				{
+					Type:   dal.AutoType,
+					Field:  collection.IdentityField,
+					Values: ids,
+				},
+			}, plan.BackendFilter.Criteria...)
+
+			backendFilter.Spec = backendFilter.String()
+
+			return self.native.QueryFunc(collection, &backendFilter, resultFn)
+		}
+
+		return self.retrieveCandidates(collection, plan, ids, resultFn)
+	}
+}
+
+// retrieves each candidate record from the backend, in the order the search index returned them
+func (self *QueryPlanner) retrieveCandidates(collection *dal.Collection, plan *PlannedQuery, ids []interface{}, resultFn IndexResultFunc) error {
+	page := IndexPage{
+		Page:         1,
+		TotalPages:   1,
+		Limit:        plan.IndexFilter.Limit,
+		Offset:       plan.IndexFilter.Offset,
+		TotalResults: int64(len(ids)),
+	}
+
+	for _, id := range ids {
+		record, err := self.backend.Retrieve(collection.Name, id, plan.IndexFilter.Fields...)
+
+		if dal.IsNotExistError(err) {
+			// the index is behind the backend
+			continue
+		} else if err == nil && plan.BackendFilter != nil && !plan.BackendFilter.MatchesRecord(record) {
+			continue
+		}
+
+		if err := resultFn(record, err, page); err != nil {
+			return queryStopped(err)
+		}
+	}
+
+	return nil
+}
+
+func (self *QueryPlanner) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f != nil && self.Plan(collection, f).Strategy != IndexStrategy {
+		// records returned by the backend don't need to be retrieved again
+		forced := filter.Copy(f)
+		forced.Options = make(map[string]interface{})
+
+		for k, v := range f.Options {
+			forced.Options[k] = v
+		}
+
+		forced.Options[`ForceIndexRecord`] = true
+		f = &forced
+	}
+
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+// Describes the plan chosen for the given filter, including the plans of the search index and
+// backend (for those that can explain their queries).
+func (self *QueryPlanner) Explain(collection *dal.Collection, f *filter.Filter) (*QueryPlan, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	plan := self.Plan(collection, f)
+
+	explained := &QueryPlan{
+		Collection: collection.Name,
+		Filter:     f.String(),
+		Backend:    fmt.Sprintf("%T", self.backend),
+		Strategy:   string(plan.Strategy),
+		Reason:     plan.Reason,
+	}
+
+	if plan.IndexFilter != nil {
+		if step, err := explainStep(self.Indexer, `index`, collection, plan.IndexFilter); err == nil {
+			explained.Steps = append(explained.Steps, step)
+		} else {
+			return nil, err
+		}
+	}
+
+	if plan.BackendFilter != nil {
+		if self.native != nil {
+			if step, err := explainStep(self.native, `backend`, collection, plan.BackendFilter); err == nil {
+				explained.Steps = append(explained.Steps, step)
+			} else {
+				return nil, err
+			}
+		} else {
+			explained.Steps = append(explained.Steps, &QueryPlan{
+				Collection: collection.Name,
+				Filter:     plan.BackendFilter.String(),
+				Backend:    fmt.Sprintf("%T", self.backend),
+				Strategy:   `retrieve`,
+			})
+		}
+	}
+
+	return explained, nil
+}
+
+func explainStep(indexer Indexer, strategy string, collection *dal.Collection, f *filter.Filter) (*QueryPlan, error) {
+	// the SQL backend explains its search index if it has one, so ask for its own plan instead
+	if sqlBackend, ok := indexer.(*SqlBackend); ok {
+		if step, err := sqlBackend.explainSql(collection, f); err == nil {
+			step.Strategy = strategy
+			return step, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	if explainer, ok := indexer.(Explainer); ok {
+		if step, err := explainer.Explain(collection, f); err == nil {
+			step.Strategy = strategy
+			return step, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return &QueryPlan{
+		Collection: collection.Name,
+		Filter:     f.String(),
+		Backend:    fmt.Sprintf("%T", indexer),
+		Strategy:   strategy,
+	}, nil
+}
+
+// returns the fields a filter wants that the collection does not store in its search index
+func unstoredFields(collection *dal.Collection, f *filter.Filter) []string {
+	unstored := make([]string, 0)
+
+	for _, field := range collection.Fields {
+		if !field.IndexNoStore {
+			continue
+		}
+
+		if len(f.Fields) == 0 || sliceutil.ContainsString(f.Fields, field.Name) {
+			unstored = append(unstored, field.Name)
+		}
+	}
+
+	return unstored
+}
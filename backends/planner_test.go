@@ -0,0 +1,179 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func newPlannerTestCollection() *dal.Collection {
+	return dal.NewCollection(`articles`).AddFields(dal.Field{
+		Name: `title`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `body`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name:         `notes`,
+		Type:         dal.StringType,
+		IndexNoStore: true,
+	})
+}
+
+func TestQueryPlannerPlan(t *testing.T) {
+	assert := require.New(t)
+
+	collection := newPlannerTestCollection()
+	backend := NewMockBackend()
+	index := NewMockBackend()
+
+	for _, tc := range []struct {
+		name     string
+		index    Indexer
+		native   Indexer
+		filter   string
+		fields   []string
+		strategy QueryStrategy
+		indexed  string
+		filtered string
+	}{
+		{`no index`, nil, backend, `body/match:fox`, nil, BackendStrategy, ``, `body/match:fox`},
+		{`no full-text`, index, backend, `title/Hello`, nil, BackendStrategy, ``, `title/Hello`},
+		{`only full-text`, index, backend, `body/match:fox`, []string{`title`}, IndexStrategy, `body/match:fox`, ``},
+		{`only full-text, unstored`, index, backend, `body/match:fox`, nil, HybridStrategy, `body/match:fox`, `all`},
+		{`mixed`, index, backend, `body/match:fox/title/Hello`, []string{`title`}, HybridStrategy, `body/match:fox`, `title/Hello`},
+		{`no native`, index, nil, `title/Hello`, []string{`title`}, IndexStrategy, `title/Hello`, ``},
+		{`no native, unstored`, index, nil, `title/Hello`, nil, HybridStrategy, `title/Hello`, ``},
+	} {
+		f, err := filter.Parse(tc.filter)
+		assert.NoError(err, tc.name)
+		f.Fields = tc.fields
+
+		plan := NewQueryPlanner(backend, tc.index, tc.native).Plan(collection, f)
+		assert.Equal(tc.strategy, plan.Strategy, tc.name)
+		assert.NotEmpty(plan.Reason, tc.name)
+
+		if tc.indexed == `` {
+			assert.Nil(plan.IndexFilter, tc.name)
+		} else {
+			assert.NotNil(plan.IndexFilter, tc.name)
+			assert.Equal(filter.MustParse(tc.indexed).String(), plan.IndexFilter.String(), tc.name)
+		}
+
+		if tc.filtered == `` {
+			assert.Nil(plan.BackendFilter, tc.name)
+		} else {
+			assert.NotNil(plan.BackendFilter, tc.name)
+			assert.Equal(filter.MustParse(tc.filtered).String(), plan.BackendFilter.String(), tc.name)
+		}
+	}
+}
+
+func TestQueryPlannerHybridPagination(t *testing.T) {
+	assert := require.New(t)
+
+	collection := newPlannerTestCollection()
+	f := filter.MustParse(`body/match:fox/title/Hello`)
+	f.Sort = []string{`-title`}
+	f.Fields = []string{`title`}
+	f.Limit = 10
+	f.Offset = 20
+
+	plan := NewQueryPlanner(NewMockBackend(), NewMockBackend(), NewMockBackend()).Plan(collection, f)
+	assert.Equal(HybridStrategy, plan.Strategy)
+
+	// the index only finds the IDs of candidates...
+	assert.Empty(plan.IndexFilter.Sort)
+	assert.Equal([]string{collection.IdentityField}, plan.IndexFilter.Fields)
+	assert.Zero(plan.IndexFilter.Limit)
+	assert.Zero(plan.IndexFilter.Offset)
+
+	// ...which the backend sorts and paginates
+	assert.Equal([]string{`-title`}, plan.BackendFilter.Sort)
+	assert.Equal([]string{`title`}, plan.BackendFilter.Fields)
+	assert.Equal(10, plan.BackendFilter.Limit)
+	assert.Equal(20, plan.BackendFilter.Offset)
+}
+
+func TestQueryPlannerRetrieveCandidates(t *testing.T) {
+	assert := require.New(t)
+
+	collection := newPlannerTestCollection()
+	backend := NewMockBackend()
+	index := NewMockBackend()
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(index.CreateCollection(collection))
+
+	assert.NoError(backend.Insert(`articles`, dal.NewRecordSet(
+		dal.NewRecord(`1`).Set(`title`, `Hello`).Set(`notes`, `first`),
+		dal.NewRecord(`2`).Set(`title`, `Goodbye`).Set(`notes`, `second`),
+	)))
+
+	// the index doesn't store notes, and is behind the backend
+	assert.NoError(index.Insert(`articles`, dal.NewRecordSet(
+		dal.NewRecord(`1`).Set(`title`, `Hello`),
+		dal.NewRecord(`3`).Set(`title`, `Hello`),
+	)))
+
+	planner := NewQueryPlanner(backend, index, nil)
+	records := make([]*dal.Record, 0)
+
+	assert.NoError(planner.QueryFunc(collection, filter.MustParse(`title/Hello`), func(record *dal.Record, err error, _ IndexPage) error {
+		assert.NoError(err)
+		records = append(records, record)
+		return nil
+	}))
+
+	// candidates are retrieved from the backend, skipping those it doesn't have
+	assert.Len(records, 1)
+	assert.EqualValues(`1`, records[0].ID)
+	assert.Equal(`first`, records[0].Get(`notes`))
+	assert.Len(backend.CallsTo(`Retrieve`), 2)
+	assert.Empty(backend.CallsTo(`QueryFunc`))
+}
+
+func TestQueryPlannerExplain(t *testing.T) {
+	assert := require.New(t)
+
+	collection := newPlannerTestCollection()
+	backend := NewMockBackend()
+	index := NewMockBackend()
+
+	// hybrid plans explain both the index and the backend query
+	f := filter.MustParse(`body/match:fox/title/Hello`)
+	explained, err := NewQueryPlanner(backend, index, backend).Explain(collection, f)
+	assert.NoError(err)
+	assert.Equal(`articles`, explained.Collection)
+	assert.Equal(f.String(), explained.Filter)
+	assert.Equal(`*backends.MockBackend`, explained.Backend)
+	assert.Equal(string(HybridStrategy), explained.Strategy)
+	assert.NotEmpty(explained.Reason)
+	assert.Len(explained.Steps, 2)
+	assert.Equal(`index`, explained.Steps[0].Strategy)
+	assert.Equal(`backend`, explained.Steps[1].Strategy)
+	assert.Equal(filter.MustParse(`title/Hello`).String(), explained.Steps[1].Filter)
+
+	// without a native query implementation, the backend retrieves the candidates
+	explained, err = NewQueryPlanner(backend, index, nil).Explain(collection, filter.MustParse(`title/Hello`))
+	assert.NoError(err)
+	assert.Equal(string(HybridStrategy), explained.Strategy)
+	assert.Len(explained.Steps, 1)
+	assert.Equal(`index`, explained.Steps[0].Strategy)
+
+	// plans satisfied by one side have a single step
+	explained, err = NewQueryPlanner(backend, index, backend).Explain(collection, filter.MustParse(`title/Hello`))
+	assert.NoError(err)
+	assert.Equal(string(BackendStrategy), explained.Strategy)
+	assert.Len(explained.Steps, 1)
+	assert.Equal(`backend`, explained.Steps[0].Strategy)
+	assert.Equal(filter.MustParse(`title/Hello`).String(), explained.Steps[0].Filter)
+
+	// a missing filter matches everything
+	explained, err = NewQueryPlanner(backend, nil, backend).Explain(collection, nil)
+	assert.NoError(err)
+	assert.Equal(string(BackendStrategy), explained.Strategy)
+	assert.Len(explained.Steps, 1)
+}
@@ -0,0 +1,65 @@
+package backends
+
+import (
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+var DefaultIndexRebuildBatchSize = 500
+
+// Options that control how a search index is repopulated.
+type IndexRebuildOptions struct {
+	// The number of records to submit to the indexer at a time.
+	BatchSize int
+
+	// Whether to remove all existing documents from the index before rebuilding it.
+	Clear bool
+
+	// If set, this function will be called with the running total of indexed records.
+	Progress func(indexed int)
+}
+
+// Implemented by backends whose search index is stored separately from their records, allowing the
+// index to be rebuilt from the records themselves (e.g.: after the index is lost or corrupted.)
+type IndexRebuilder interface {
+	IndexRebuild(collection *dal.Collection, options IndexRebuildOptions) (int, error)
+}
+
+// Removes every document for the given collection from the given index.  Indexers' DeleteQuery
+// implementations delete matching records from the parent backend, so instead the IDs of all indexed
+// documents are gathered and removed from the index directly.
+func clearIndex(indexer Indexer, collection *dal.Collection, batchSize int) error {
+	f := filter.All()
+	f.IdentityField = collection.IdentityField
+	f.Fields = []string{collection.IdentityField}
+
+	ids := make([]interface{}, 0)
+
+	if err := indexer.QueryFunc(collection, f, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		} else if record != nil {
+			ids = append(ids, record.ID)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for len(ids) > 0 {
+		n := batchSize
+
+		if n <= 0 || n > len(ids) {
+			n = len(ids)
+		}
+
+		if err := indexer.IndexRemove(collection, ids[:n]); err != nil {
+			return err
+		}
+
+		ids = ids[n:]
+	}
+
+	return nil
+}
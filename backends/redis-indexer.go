@@ -0,0 +1,169 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+func (self *RedisBackend) IndexConnectionString() *dal.ConnectionString {
+	return &dal.ConnectionString{}
+}
+
+func (self *RedisBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *RedisBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *RedisBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.Name, id)
+}
+
+func (self *RedisBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.Name, id)
+}
+
+func (self *RedisBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *RedisBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Calls resultFn for each record matching the filter, sorted by the filter's sort fields (or by ID).
+func (self *RedisBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	defer timeOperation(`pivot.indexers.redis.query_time`)()
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	matches, err := self.matching(collection, f)
+
+	if err != nil {
+		return err
+	}
+
+	total := int64(len(matches))
+
+	if f.Offset >= len(matches) {
+		matches = nil
+	} else {
+		matches = matches[f.Offset:]
+	}
+
+	if f.Limit > 0 && len(matches) > f.Limit {
+		matches = matches[:f.Limit]
+	}
+
+	for _, record := range matches {
+		if err := resultFn(record, nil, IndexPage{
+			Page:         1,
+			Limit:        f.Limit,
+			Offset:       f.Offset,
+			TotalResults: total,
+		}); err != nil {
+			return queryStopped(err)
+		}
+	}
+
+	return nil
+}
+
+func (self *RedisBackend) matching(collection *dal.Collection, f *filter.Filter) ([]*dal.Record, error) {
+	var ids []interface{}
+
+	// records matched only by their IDs are read directly rather than reading the whole collection
+	if len(f.Criteria) == 1 && f.Criteria[0].Field == collection.IdentityField && (f.Criteria[0].Operator == `` || f.Criteria[0].Operator == `is`) {
+		ids = f.Criteria[0].Values
+	} else if members, err := self.client.SMembers(collection.Name).Result(); err == nil {
+		ids = sliceutil.Sliceify(members)
+	} else {
+		return nil, err
+	}
+
+	matches := make([]*dal.Record, 0)
+
+	for i := 0; i < len(ids); i += IndexerPageSize {
+		end := i + IndexerPageSize
+
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		if records, err := self.read(collection, ids[i:end]); err == nil {
+			for _, record := range records {
+				if f.MatchesRecord(record) {
+					matches = append(matches, record)
+				}
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	sortRecords(collection, matches, f)
+
+	return matches, nil
+}
+
+func (self *RedisBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *RedisBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+	seen := make(map[string]map[string]bool)
+
+	err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		for _, field := range fields {
+			value := record.Get(field)
+
+			if field == collection.IdentityField {
+				value = record.ID
+			}
+
+			if seen[field] == nil {
+				seen[field] = make(map[string]bool)
+			}
+
+			if key := fmt.Sprintf("%v", value); !seen[field][key] {
+				seen[field][key] = true
+				values[field] = append(values[field], value)
+			}
+		}
+
+		return nil
+	})
+
+	return values, err
+}
+
+func (self *RedisBackend) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	return DefaultFacetsImplementation(self, collection, fields, f)
+}
+
+func (self *RedisBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		ids = append(ids, record.ID)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return deleteInChunks(self, collection.Name, ids)
+}
+
+func (self *RedisBackend) FlushIndex() error {
+	return nil
+}
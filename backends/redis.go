@@ -0,0 +1,424 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/go-redis/redis"
+)
+
+// The hash collection definitions are stored in, keyed by collection name.
+var RedisSchemaKey = `_schema`
+
+// Separates the collection name from the record ID in the keys records are stored at.
+var RedisKeySeparator = `:`
+
+// A Backend that stores each record as a Redis hash at "<collection>:<id>", with the IDs of the
+// records in each collection kept in a set stored at "<collection>".  Field values are stored as JSON
+// so that their types survive being written as hash values.
+//
+// Collections with a TTL have each record expire that long after it was last written.  The ID
+// sets are cleaned up as expired records are encountered.
+type RedisBackend struct {
+	Backend
+	Indexer
+	conn                  dal.ConnectionString
+	client                *redis.Client
+	indexer               Indexer
+	registeredCollections sync.Map
+}
+
+func NewRedisBackend(connection dal.ConnectionString) Backend {
+	return &RedisBackend{
+		conn: connection,
+	}
+}
+
+func (self *RedisBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+// Connects to the server given by the connection string (e.g.: "redis://localhost:6379/0"), where
+// the dataset is the number of the database to use.
+func (self *RedisBackend) Initialize() error {
+	options := &redis.Options{
+		Addr:        self.conn.Host(),
+		DialTimeout: DefaultConnectTimeout,
+	}
+
+	if !strings.Contains(options.Addr, `:`) {
+		options.Addr = fmt.Sprintf("%s:6379", options.Addr)
+	}
+
+	if _, p, ok := self.conn.Credentials(); ok {
+		options.Password = p
+	}
+
+	if dataset := self.conn.Dataset(); dataset != `` {
+		if v, err := stringutil.ConvertToInteger(dataset); err == nil {
+			options.DB = int(v)
+		} else {
+			return fmt.Errorf("Invalid database number %q", dataset)
+		}
+	}
+
+	self.client = redis.NewClient(options)
+
+	if err := self.client.Ping().Err(); err != nil {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	return self.indexer.IndexInitialize(self)
+}
+
+func (self *RedisBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *RedisBackend) RegisterCollection(collection *dal.Collection) {
+	if collection != nil {
+		self.registeredCollections.Store(collection.Name, collection)
+	}
+}
+
+func (self *RedisBackend) Ping(timeout time.Duration) error {
+	errchan := make(chan error, 1)
+
+	go func() {
+		errchan <- self.client.Ping().Err()
+	}()
+
+	select {
+	case err := <-errchan:
+		if err != nil {
+			return fmt.Errorf("Backend unavailable: %v", err)
+		}
+
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("Backend unavailable: timed out after waiting %v", timeout)
+	}
+}
+
+func (self *RedisBackend) Exists(name string, id interface{}) bool {
+	if collection, err := self.GetCollection(name); err == nil {
+		if n, err := self.client.Exists(self.keyFor(collection, id)).Result(); err == nil && n > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *RedisBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		if records, err := self.read(collection, []interface{}{id}); err == nil {
+			if len(records) == 0 {
+				return nil, dal.RecordNotFound(id)
+			}
+
+			record := records[0]
+
+			if len(fields) > 0 {
+				for key := range record.Fields {
+					if !sliceutil.ContainsString(fields, key) {
+						delete(record.Fields, key)
+					}
+				}
+			}
+
+			return record, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *RedisBackend) Insert(name string, recordset *dal.RecordSet) error {
+	return self.write(name, recordset, true)
+}
+
+// Updates only change the fields given in each record; other fields keep their stored values.
+func (self *RedisBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.write(name, recordset, false)
+}
+
+func (self *RedisBackend) write(name string, recordset *dal.RecordSet, insert bool) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		ttl := collection.GetTTL()
+
+		for _, record := range recordset.Records {
+			if r, err := collection.MakeRecord(record); err == nil {
+				record = r
+			} else {
+				return err
+			}
+
+			if record.ID == nil {
+				return fmt.Errorf("Cannot write a record without an ID to %v", collection.Name)
+			}
+
+			key := self.keyFor(collection, record.ID)
+
+			// the ID is stored alongside the other fields so that it is read back with its type intact
+			values := map[string]interface{}{
+				collection.IdentityField: record.ID,
+			}
+
+			for k, v := range record.Fields {
+				values[k] = v
+			}
+
+			for k, v := range values {
+				if data, err := json.Marshal(v); err == nil {
+					values[k] = string(data)
+				} else {
+					return fmt.Errorf("Cannot encode field %q: %v", k, err)
+				}
+			}
+
+			if insert {
+				if n, err := self.client.Exists(key).Result(); err != nil {
+					return err
+				} else if n > 0 {
+					return dal.UniqueViolation(fmt.Errorf("Record %v already exists", record.ID))
+				}
+			}
+
+			if _, err := self.client.TxPipelined(func(pipe redis.Pipeliner) error {
+				pipe.HMSet(key, values)
+				pipe.SAdd(collection.Name, self.idFor(record.ID))
+
+				if ttl > 0 {
+					pipe.Expire(key, ttl)
+				}
+
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+
+		if !collection.SkipIndexPersistence {
+			if search := self.WithSearch(collection); search != nil {
+				if err := search.Index(collection, recordset); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *RedisBackend) Delete(name string, ids ...interface{}) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		if len(ids) == 0 {
+			return nil
+		}
+
+		keys := make([]string, len(ids))
+		members := make([]interface{}, len(ids))
+
+		for i, id := range ids {
+			keys[i] = self.keyFor(collection, id)
+			members[i] = self.idFor(id)
+		}
+
+		if _, err := self.client.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.Del(keys...)
+			pipe.SRem(collection.Name, members...)
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if search := self.WithSearch(collection); search != nil {
+			return search.IndexRemove(collection, ids)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *RedisBackend) CreateCollection(definition *dal.Collection) error {
+	if definition.Name == RedisSchemaKey {
+		return fmt.Errorf("Collection name %q is reserved", definition.Name)
+	}
+
+	if data, err := json.Marshal(definition); err == nil {
+		if created, err := self.client.HSetNX(RedisSchemaKey, definition.Name, string(data)).Result(); err != nil {
+			return err
+		} else if !created {
+			return fmt.Errorf("Collection %v already exists", definition.Name)
+		}
+	} else {
+		return err
+	}
+
+	self.RegisterCollection(definition)
+	return nil
+}
+
+func (self *RedisBackend) DeleteCollection(name string) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		if ids, err := self.client.SMembers(collection.Name).Result(); err == nil {
+			keys := []string{collection.Name}
+
+			for _, id := range ids {
+				keys = append(keys, self.keyFor(collection, id))
+			}
+
+			if _, err := self.client.TxPipelined(func(pipe redis.Pipeliner) error {
+				pipe.Del(keys...)
+				pipe.HDel(RedisSchemaKey, collection.Name)
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			self.registeredCollections.Delete(name)
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *RedisBackend) ListCollections() ([]string, error) {
+	return self.client.HKeys(RedisSchemaKey).Result()
+}
+
+func (self *RedisBackend) GetCollection(name string) (*dal.Collection, error) {
+	if c, ok := self.registeredCollections.Load(name); ok {
+		return c.(*dal.Collection), nil
+	}
+
+	if data, err := self.client.HGet(RedisSchemaKey, name).Result(); err == nil {
+		var collection dal.Collection
+
+		if err := json.Unmarshal([]byte(data), &collection); err == nil {
+			self.RegisterCollection(&collection)
+			return &collection, nil
+		} else {
+			return nil, err
+		}
+	} else if err == redis.Nil {
+		return nil, dal.CollectionNotFound
+	} else {
+		return nil, err
+	}
+}
+
+func (self *RedisBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *RedisBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return nil
+}
+
+func (self *RedisBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+func (self *RedisBackend) keyFor(collection *dal.Collection, id interface{}) string {
+	return collection.Name + RedisKeySeparator + self.idFor(id)
+}
+
+func (self *RedisBackend) idFor(id interface{}) string {
+	return fmt.Sprintf("%v", id)
+}
+
+// reads the records with the given IDs, skipping those that don't exist and removing them from
+// the collection's ID set (which is how records that have expired are cleaned up)
+func (self *RedisBackend) read(collection *dal.Collection, ids []interface{}) ([]*dal.Record, error) {
+	cmds := make([]*redis.StringStringMapCmd, len(ids))
+
+	if _, err := self.client.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(self.keyFor(collection, id))
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	records := make([]*dal.Record, 0, len(ids))
+	missing := make([]interface{}, 0)
+
+	for i, cmd := range cmds {
+		values, err := cmd.Result()
+
+		if err != nil {
+			return nil, err
+		} else if len(values) == 0 {
+			missing = append(missing, self.idFor(ids[i]))
+			continue
+		}
+
+		record := dal.NewRecord(ids[i])
+
+		for k, v := range values {
+			var value interface{}
+
+			if err := json.Unmarshal([]byte(v), &value); err != nil {
+				return nil, fmt.Errorf("Cannot decode field %q of record %v: %v", k, ids[i], err)
+			}
+
+			if k == collection.IdentityField {
+				record.ID = value
+			} else {
+				record.Set(k, value)
+			}
+		}
+
+		if collection.IdentityFieldType != dal.StringType {
+			record.ID = stringutil.Autotype(record.ID)
+		}
+
+		// do this AFTER populating the record's fields from the hash
+		if err := record.Populate(record, collection); err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	if len(missing) > 0 {
+		if err := self.client.SRem(collection.Name, missing...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
@@ -0,0 +1,16 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisBackendInvalidDatabase(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`redis://localhost/users`)
+	assert.NoError(err)
+	assert.Error(NewRedisBackend(cs).Initialize())
+}
@@ -0,0 +1,331 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// The width of the buckets that records are grouped into by AggregateByTime.
+type TimeInterval string
+
+const (
+	Minutely TimeInterval = `minute`
+	Hourly   TimeInterval = `hour`
+	Daily    TimeInterval = `day`
+	Weekly   TimeInterval = `week`
+	Monthly  TimeInterval = `month`
+	Yearly   TimeInterval = `year`
+)
+
+// The most buckets AggregateByTime will return, which keeps gaps between buckets far apart (e.g.:
+// minutely buckets over several years) from being filled with millions of empty ones.
+var MaxTimeBuckets = 10000
+
+func ParseTimeInterval(in string) (TimeInterval, error) {
+	switch interval := TimeInterval(strings.ToLower(strings.TrimSpace(in))); interval {
+	case Minutely, Hourly, Daily, Weekly, Monthly, Yearly:
+		return interval, nil
+	default:
+		return ``, fmt.Errorf("Unsupported time interval %q", in)
+	}
+}
+
+// Returns the start of the interval containing the given time (in UTC).  Weeks start on Monday.
+func (self TimeInterval) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+
+	switch self {
+	case Minutely:
+		return t.Truncate(time.Minute)
+	case Hourly:
+		return t.Truncate(time.Hour)
+	case Daily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case Weekly:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case Yearly:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return t
+	}
+}
+
+// Returns the start of the interval following the one starting at the given time.
+func (self TimeInterval) Next(t time.Time) time.Time {
+	switch self {
+	case Minutely:
+		return t.Add(time.Minute)
+	case Hourly:
+		return t.Add(time.Hour)
+	case Daily:
+		return t.AddDate(0, 0, 1)
+	case Weekly:
+		return t.AddDate(0, 0, 7)
+	case Monthly:
+		return t.AddDate(0, 1, 0)
+	case Yearly:
+		return t.AddDate(1, 0, 0)
+	default:
+		return t
+	}
+}
+
+// The records whose time falls within one interval, and the result of aggregating them.
+type TimeBucket struct {
+	Time  time.Time `json:"time"`
+	Count uint64    `json:"count"`
+	Value float64   `json:"value"`
+}
+
+// Implemented by aggregators and indexers that can group records into time buckets natively.  Those
+// that can't perform a particular aggregation return an error that is dal.ErrUnsupported.
+type TimeAggregator interface {
+	AggregateByTime(collection *dal.Collection, field string, interval TimeInterval, fn filter.Aggregate, f ...*filter.Filter) ([]TimeBucket, error)
+}
+
+// Groups the records matching the (optional) filter into buckets by the interval their time field
+// falls within, and aggregates the field named by fn in each bucket (the Value of Count aggregations
+// is the number of records in the bucket).  Buckets are returned in order, with empty buckets
+// included between the first and last ones so that the results can be charted as-is.
+//
+// The backend's aggregator or search index is used if either can do this natively (e.g.:
+// date_trunc in PostgreSQL or a date histogram in Elasticsearch), otherwise every matching record is
+// queried and bucketed here.
+func AggregateByTime(backend Backend, collection *dal.Collection, field string, interval TimeInterval, fn filter.Aggregate, f ...*filter.Filter) ([]TimeBucket, error) {
+	if _, err := ParseTimeInterval(string(interval)); err != nil {
+		return nil, err
+	}
+
+	if aggregator, ok := backend.WithAggregator(collection).(TimeAggregator); ok {
+		if buckets, err := aggregator.AggregateByTime(collection, field, interval, fn, f...); err == nil {
+			return fillTimeBuckets(buckets, interval), nil
+		} else if !errors.Is(err, dal.ErrUnsupported) {
+			return nil, err
+		}
+	}
+
+	if search := backend.WithSearch(collection, f...); search != nil {
+		if aggregator, ok := search.(TimeAggregator); ok {
+			if buckets, err := aggregator.AggregateByTime(collection, field, interval, fn, f...); err == nil {
+				return fillTimeBuckets(buckets, interval), nil
+			} else if !errors.Is(err, dal.ErrUnsupported) {
+				return nil, err
+			}
+		}
+
+		var flt *filter.Filter
+
+		if len(f) > 0 {
+			flt = f[0]
+		}
+
+		if buckets, err := DefaultAggregateByTimeImplementation(search, collection, field, interval, fn, flt); err == nil {
+			return fillTimeBuckets(buckets, interval), nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return nil, dal.Unsupported("Backend %T does not support complex queries", backend)
+}
+
+// Buckets and aggregates every record that matches the filter.  This is suitable for indexers that
+// cannot group records by time natively.
+func DefaultAggregateByTimeImplementation(indexer Indexer, collection *dal.Collection, field string, interval TimeInterval, fn filter.Aggregate, f *filter.Filter) ([]TimeBucket, error) {
+	var query filter.Filter
+	options := make(map[string]interface{})
+
+	if f == nil {
+		query = filter.Copy(filter.All())
+	} else {
+		query = filter.Copy(f)
+	}
+
+	for k, v := range query.Options {
+		options[k] = v
+	}
+
+	query.Fields = []string{field}
+	query.Options = options
+	query.Limit = 0
+	query.Offset = 0
+	query.Sort = nil
+
+	if fn.Aggregation != filter.Count && fn.Field != `` {
+		query.Fields = append(query.Fields, fn.Field)
+	}
+
+	buckets := make(map[time.Time]*timeBucketAccumulator)
+
+	if _, err := indexer.Query(collection, &query, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		if t, ok := timeOf(record.Get(field)); ok {
+			start := interval.Truncate(t)
+			bucket, ok := buckets[start]
+
+			if !ok {
+				bucket = &timeBucketAccumulator{
+					aggregation: fn.Aggregation,
+				}
+
+				buckets[start] = bucket
+			}
+
+			bucket.add(t, record.Get(fn.Field))
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	output := make([]TimeBucket, 0, len(buckets))
+
+	for start, bucket := range buckets {
+		output = append(output, bucket.bucket(start))
+	}
+
+	sort.Slice(output, func(i int, j int) bool {
+		return output[i].Time.Before(output[j].Time)
+	})
+
+	return output, nil
+}
+
+// aggregates the values of the records in one bucket as they are queried
+type timeBucketAccumulator struct {
+	aggregation filter.Aggregation
+	count       uint64
+	values      uint64
+	value       float64
+	firstAt     time.Time
+	lastAt      time.Time
+}
+
+func (self *timeBucketAccumulator) add(t time.Time, value interface{}) {
+	self.count += 1
+
+	if self.aggregation == filter.Count || value == nil {
+		return
+	}
+
+	v, err := stringutil.ConvertToFloat(value)
+
+	if err != nil {
+		return
+	}
+
+	switch self.aggregation {
+	case filter.Sum, filter.Average:
+		self.value += v
+	case filter.Minimum:
+		if self.values == 0 || v < self.value {
+			self.value = v
+		}
+	case filter.Maximum:
+		if self.values == 0 || v > self.value {
+			self.value = v
+		}
+	case filter.First:
+		if self.values == 0 || t.Before(self.firstAt) {
+			self.value = v
+			self.firstAt = t
+		}
+	case filter.Last:
+		if self.values == 0 || !t.Before(self.lastAt) {
+			self.value = v
+			self.lastAt = t
+		}
+	}
+
+	self.values += 1
+}
+
+func (self *timeBucketAccumulator) bucket(start time.Time) TimeBucket {
+	bucket := TimeBucket{
+		Time:  start,
+		Count: self.count,
+		Value: self.value,
+	}
+
+	switch self.aggregation {
+	case filter.Count:
+		bucket.Value = float64(self.count)
+	case filter.Average:
+		if self.values > 0 {
+			bucket.Value = self.value / float64(self.values)
+		}
+	}
+
+	return bucket
+}
+
+// sorts buckets and adds empty ones for the intervals between them that have no records
+func fillTimeBuckets(buckets []TimeBucket, interval TimeInterval) []TimeBucket {
+	if len(buckets) == 0 {
+		return buckets
+	}
+
+	sort.Slice(buckets, func(i int, j int) bool {
+		return buckets[i].Time.Before(buckets[j].Time)
+	})
+
+	filled := make([]TimeBucket, 0, len(buckets))
+
+	for i, bucket := range buckets {
+		if i > 0 {
+			for t := interval.Next(buckets[i-1].Time); t.Before(bucket.Time); t = interval.Next(t) {
+				if MaxTimeBuckets > 0 && len(filled) >= MaxTimeBuckets {
+					break
+				}
+
+				filled = append(filled, TimeBucket{
+					Time: t,
+				})
+			}
+		}
+
+		filled = append(filled, bucket)
+	}
+
+	if MaxTimeBuckets > 0 && len(filled) > MaxTimeBuckets {
+		filled = filled[:MaxTimeBuckets]
+	}
+
+	return filled
+}
+
+// reads a time from a record value, which may not have been converted to one by the backend
+func timeOf(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, false
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v != nil {
+			return *v, !v.IsZero()
+		}
+
+		return time.Time{}, false
+	}
+
+	if v, err := stringutil.ConvertToTime(value); err == nil && !v.IsZero() {
+		return v, true
+	}
+
+	return time.Time{}, false
+}
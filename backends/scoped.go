@@ -0,0 +1,434 @@
+package backends
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// Returns the criteria that every record in the given collection must match to be visible to
+// whoever is identified by the given context (e.g.: "owner_id/42").  A nil or match-all filter
+// makes every record visible.
+type ScopeFunc func(ctx context.Context, collection *dal.Collection) (*filter.Filter, error)
+
+// A ScopedBackend wraps another backend and restricts access to the records in a collection to
+// those matching the collection's scope.  The scope's criteria are added to every query, and
+// records are checked against the scope before they are retrieved, inserted, updated, or deleted;
+// records outside of the scope are treated as though they do not exist.
+//
+// Scope functions are registered once in a set shared by all scoped backends, and are given the
+// context of the backend they are called from.  Optional capabilities of the parent backend (such as
+// batches and blob streaming) are not exposed, since they would bypass the scope.
+type ScopedBackend struct {
+	Backend
+	ctx    context.Context
+	scopes *sync.Map
+}
+
+func NewScopedBackend(ctx context.Context, parent Backend, scopes *sync.Map) *ScopedBackend {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if scopes == nil {
+		scopes = new(sync.Map)
+	}
+
+	return &ScopedBackend{
+		Backend: parent,
+		ctx:     ctx,
+		scopes:  scopes,
+	}
+}
+
+// Returns a backend that shares this backend's scopes but evaluates them with the given context.
+func (self *ScopedBackend) WithContext(ctx context.Context) *ScopedBackend {
+	return NewScopedBackend(ctx, self.Backend, self.scopes)
+}
+
+// Returns the context scopes are evaluated with.
+func (self *ScopedBackend) Context() context.Context {
+	return self.ctx
+}
+
+// Sets the function that determines which records in the named collection are accessible.
+func (self *ScopedBackend) SetScope(collection string, fn ScopeFunc) {
+	if fn == nil {
+		self.scopes.Delete(collection)
+	} else {
+		self.scopes.Store(collection, fn)
+	}
+}
+
+// Returns the scope for the given collection, or nil if all records are accessible.
+func (self *ScopedBackend) Scope(collection *dal.Collection) (*filter.Filter, error) {
+	if fnI, ok := self.scopes.Load(collection.Name); ok {
+		if scope, err := fnI.(ScopeFunc)(self.ctx, collection); err == nil {
+			if scope == nil || scope.IsMatchAll() || len(scope.Criteria) == 0 {
+				return nil, nil
+			}
+
+			return scope, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (self *ScopedBackend) scopeByName(name string) (*filter.Filter, error) {
+	if _, ok := self.scopes.Load(name); !ok {
+		return nil, nil
+	}
+
+	if collection, err := self.Backend.GetCollection(name); err == nil {
+		return self.Scope(collection)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *ScopedBackend) Exists(collection string, id interface{}) bool {
+	if scope, err := self.scopeByName(collection); err != nil {
+		return false
+	} else if scope == nil {
+		return self.Backend.Exists(collection, id)
+	}
+
+	_, err := self.Retrieve(collection, id)
+	return err == nil
+}
+
+func (self *ScopedBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	scope, err := self.scopeByName(collection)
+
+	if err != nil {
+		return nil, err
+	} else if scope == nil {
+		return self.Backend.Retrieve(collection, id, fields...)
+	}
+
+	// the fields the scope tests must be retrieved to check the record against it, but are removed
+	// again afterwards if they weren't asked for
+	var added []string
+
+	if len(fields) > 0 {
+		for _, field := range scope.CriteriaFields() {
+			if !sliceutil.ContainsString(fields, field) {
+				fields = append(fields, field)
+				added = append(added, field)
+			}
+		}
+	}
+
+	if record, err := self.Backend.Retrieve(collection, id, fields...); err == nil {
+		if scope.MatchesRecord(record) {
+			for _, field := range added {
+				delete(record.Fields, field)
+			}
+
+			return record, nil
+		}
+
+		return nil, dal.RecordNotFound(id)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *ScopedBackend) Insert(collection string, records *dal.RecordSet) error {
+	if scope, err := self.scopeByName(collection); err != nil {
+		return err
+	} else if scope != nil {
+		for _, record := range records.Records {
+			if !scope.MatchesRecord(record) {
+				return dal.PermissionDenied("Record %v is not within the scope of collection %q", record.ID, collection)
+			}
+		}
+	}
+
+	return self.Backend.Insert(collection, records)
+}
+
+// Updates records that are within the collection's scope.  Records given with an ID must already be
+// within the scope, and records updated by a target filter are limited to those matching the scope.
+// In either case, updates that would move records out of the scope are not permitted.
+func (self *ScopedBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	scope, err := self.scopeByName(collection)
+
+	if err != nil {
+		return err
+	} else if scope == nil {
+		return self.Backend.Update(collection, records, target...)
+	}
+
+	for _, record := range records.Records {
+		if record.ID == nil || record.ID == `` {
+			// only the fields being updated can be checked, since the records aren't known
+			updated := filter.New()
+
+			for _, criterion := range scope.Criteria {
+				if _, ok := record.Fields[criterion.Field]; ok {
+					updated.AddCriteria(criterion)
+				}
+			}
+
+			if len(updated.Criteria) > 0 && !updated.MatchesRecord(record) {
+				return dal.PermissionDenied("Update would move records out of the scope of collection %q", collection)
+			}
+		} else if existing, err := self.Retrieve(collection, record.ID); err == nil {
+			merged := dal.NewRecord(existing.ID).SetFields(existing.Fields).SetFields(record.Fields)
+
+			if !scope.MatchesRecord(merged) {
+				return dal.PermissionDenied("Update would move record %v out of the scope of collection %q", record.ID, collection)
+			}
+		} else {
+			return err
+		}
+	}
+
+	if len(target) > 0 && target[0] != `` {
+		if f, err := filter.Parse(target[0]); err == nil {
+			target = []string{ApplyScope(f, scope).String()}
+		} else {
+			return err
+		}
+	}
+
+	return self.Backend.Update(collection, records, target...)
+}
+
+// Deletes records that are within the collection's scope.  If any of the given records are not, no
+// records are deleted.
+func (self *ScopedBackend) Delete(collection string, ids ...interface{}) error {
+	if scope, err := self.scopeByName(collection); err != nil {
+		return err
+	} else if scope != nil {
+		for _, id := range ids {
+			if _, err := self.Retrieve(collection, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return self.Backend.Delete(collection, ids...)
+}
+
+// Deletes a collection, unless it has a scope (since that would delete records outside of it).
+func (self *ScopedBackend) DeleteCollection(collection string) error {
+	if scope, err := self.scopeByName(collection); err != nil {
+		return err
+	} else if scope != nil {
+		return dal.PermissionDenied("Cannot delete collection %q, which has a scope", collection)
+	}
+
+	return self.Backend.DeleteCollection(collection)
+}
+
+func (self *ScopedBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	if indexer := self.Backend.WithSearch(collection, filters...); indexer != nil {
+		return &scopedIndexer{
+			Indexer: indexer,
+			backend: self,
+		}
+	}
+
+	return nil
+}
+
+func (self *ScopedBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	if aggregator := self.Backend.WithAggregator(collection); aggregator != nil {
+		return &scopedAggregator{
+			Aggregator: aggregator,
+			backend:    self,
+		}
+	}
+
+	return nil
+}
+
+// Returns a copy of the given filter that only matches records that also match the given scope.
+func ApplyScope(f *filter.Filter, scope *filter.Filter) *filter.Filter {
+	if scope == nil || scope.IsMatchAll() {
+		return f
+	}
+
+	var scoped filter.Filter
+
+	if f == nil {
+		scoped = filter.MakeFilter()
+	} else {
+		scoped = filter.Copy(f)
+	}
+
+	criteria := make([]filter.Criterion, 0, len(scoped.Criteria)+len(scope.Criteria))
+	criteria = append(criteria, scoped.Criteria...)
+	criteria = append(criteria, scope.Criteria...)
+
+	scoped.Criteria = criteria
+	scoped.MatchAll = false
+	scoped.Spec = scoped.String()
+
+	return &scoped
+}
+
+// adds a collection's scope to the queries made through an indexer
+type scopedIndexer struct {
+	Indexer
+	backend *ScopedBackend
+}
+
+func (self *scopedIndexer) scoped(collection *dal.Collection, f *filter.Filter) (*filter.Filter, error) {
+	if scope, err := self.backend.Scope(collection); err == nil {
+		return ApplyScope(f, scope), nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *scopedIndexer) IndexExists(collection *dal.Collection, id interface{}) bool {
+	_, err := self.IndexRetrieve(collection, id)
+	return err == nil
+}
+
+func (self *scopedIndexer) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	scope, err := self.backend.Scope(collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if record, err := self.Indexer.IndexRetrieve(collection, id); err == nil {
+		if scope == nil || scope.MatchesRecord(record) {
+			return record, nil
+		}
+
+		return nil, dal.RecordNotFound(id)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *scopedIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Indexer.QueryFunc(collection, scoped, resultFn)
+	} else {
+		return err
+	}
+}
+
+func (self *scopedIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Indexer.Query(collection, scoped, resultFns...)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *scopedIndexer) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Indexer.ListValues(collection, fields, scoped)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *scopedIndexer) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Indexer.Facets(collection, fields, scoped)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *scopedIndexer) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Indexer.DeleteQuery(collection, scoped)
+	} else {
+		return err
+	}
+}
+
+func (self *scopedIndexer) GetBackend() Backend {
+	return self.backend
+}
+
+// adds a collection's scope to the filters given to an aggregator
+type scopedAggregator struct {
+	Aggregator
+	backend *ScopedBackend
+}
+
+func (self *scopedAggregator) scoped(collection *dal.Collection, f []*filter.Filter) ([]*filter.Filter, error) {
+	scope, err := self.backend.Scope(collection)
+
+	if err != nil {
+		return nil, err
+	} else if scope == nil {
+		return f, nil
+	}
+
+	if len(f) == 0 {
+		return []*filter.Filter{ApplyScope(filter.All(), scope)}, nil
+	}
+
+	scoped := make([]*filter.Filter, len(f))
+
+	for i, ff := range f {
+		scoped[i] = ApplyScope(ff, scope)
+	}
+
+	return scoped, nil
+}
+
+func (self *scopedAggregator) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Aggregator.Sum(collection, field, scoped...)
+	} else {
+		return 0, err
+	}
+}
+
+func (self *scopedAggregator) Count(collection *dal.Collection, f ...*filter.Filter) (uint64, error) {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Aggregator.Count(collection, scoped...)
+	} else {
+		return 0, err
+	}
+}
+
+func (self *scopedAggregator) Minimum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Aggregator.Minimum(collection, field, scoped...)
+	} else {
+		return 0, err
+	}
+}
+
+func (self *scopedAggregator) Maximum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Aggregator.Maximum(collection, field, scoped...)
+	} else {
+		return 0, err
+	}
+}
+
+func (self *scopedAggregator) Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Aggregator.Average(collection, field, scoped...)
+	} else {
+		return 0, err
+	}
+}
+
+func (self *scopedAggregator) GroupBy(collection *dal.Collection, fields []string, aggregates []filter.Aggregate, f ...*filter.Filter) (*dal.RecordSet, error) {
+	if scoped, err := self.scoped(collection, f); err == nil {
+		return self.Aggregator.GroupBy(collection, fields, aggregates, scoped...)
+	} else {
+		return nil, err
+	}
+}
@@ -0,0 +1,120 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a mock backend with records owned by alice and bob, and a scoped backend limited to
+// alice's records
+func newScopedTestBackend() (*ScopedBackend, *MockBackend, *dal.Collection, error) {
+	mock := NewMockBackend()
+	collection := dal.NewCollection(`widgets`).AddFields(dal.Field{
+		Name: `owner`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	if err := mock.CreateCollection(collection); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := mock.Insert(`widgets`, dal.NewRecordSet(
+		dal.NewRecord(`1`).Set(`owner`, `alice`).Set(`name`, `first`),
+		dal.NewRecord(`2`).Set(`owner`, `bob`).Set(`name`, `second`),
+		dal.NewRecord(`3`).Set(`owner`, `alice`).Set(`name`, `third`),
+	)); err != nil {
+		return nil, nil, nil, err
+	}
+
+	scoped := NewScopedBackend(context.Background(), mock, nil)
+	scoped.SetScope(`widgets`, func(ctx context.Context, _ *dal.Collection) (*filter.Filter, error) {
+		return filter.Parse(`owner/alice`)
+	})
+
+	return scoped, mock, collection, nil
+}
+
+func TestScopedBackendRetrieveFields(t *testing.T) {
+	assert := require.New(t)
+
+	scoped, mock, _, err := newScopedTestBackend()
+	assert.NoError(err)
+
+	// the scope's fields are read to check the record, but only the requested fields are returned
+	record, err := scoped.Retrieve(`widgets`, `1`, `name`)
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		`name`: `first`,
+	}, record.Fields)
+
+	retrieves := mock.CallsTo(`Retrieve`)
+	assert.Len(retrieves, 1)
+	assert.ElementsMatch([]string{`name`, `owner`}, retrieves[0].Args[2])
+
+	// ...unless they were requested too
+	record, err = scoped.Retrieve(`widgets`, `1`, `name`, `owner`)
+	assert.NoError(err)
+	assert.Equal(`alice`, record.Get(`owner`))
+
+	// records out of scope still aren't returned
+	_, err = scoped.Retrieve(`widgets`, `2`, `name`)
+	assert.True(dal.IsNotExistError(err))
+
+	// all fields are returned when none are given
+	record, err = scoped.Retrieve(`widgets`, `3`)
+	assert.NoError(err)
+	assert.Equal(`alice`, record.Get(`owner`))
+	assert.Equal(`third`, record.Get(`name`))
+}
+
+func TestScopedBackendUpdateTarget(t *testing.T) {
+	assert := require.New(t)
+
+	scoped, mock, _, err := newScopedTestBackend()
+	assert.NoError(err)
+
+	// updates by filter are limited to the records in scope
+	assert.NoError(scoped.Update(`widgets`, dal.NewRecordSet(dal.NewRecord(nil).Set(`name`, `renamed`)), `name/first`))
+
+	updates := mock.CallsTo(`Update`)
+	assert.Len(updates, 1)
+
+	target := updates[0].Args[2].([]string)
+	assert.Len(target, 1)
+
+	f, err := filter.Parse(target[0])
+	assert.NoError(err)
+	assert.ElementsMatch([]string{`name`, `owner`}, f.CriteriaFields())
+	assert.True(f.MatchesRecord(dal.NewRecord(`1`).Set(`owner`, `alice`).Set(`name`, `first`)))
+	assert.False(f.MatchesRecord(dal.NewRecord(`2`).Set(`owner`, `bob`).Set(`name`, `first`)))
+
+	// ...and can't move them out of it
+	err = scoped.Update(`widgets`, dal.NewRecordSet(dal.NewRecord(nil).Set(`owner`, `bob`)), `name/first`)
+	assert.True(errors.Is(err, dal.ErrPermissionDenied))
+	assert.Len(mock.CallsTo(`Update`), 1)
+}
+
+func TestScopedIndexerDeleteQuery(t *testing.T) {
+	assert := require.New(t)
+
+	scoped, mock, collection, err := newScopedTestBackend()
+	assert.NoError(err)
+
+	// only records in scope are deleted, even when the query matches others
+	assert.NoError(scoped.WithSearch(collection).DeleteQuery(collection, filter.All()))
+
+	assert.False(mock.Exists(`widgets`, `1`))
+	assert.True(mock.Exists(`widgets`, `2`))
+	assert.False(mock.Exists(`widgets`, `3`))
+
+	// the indexer reports the scoped backend as its own, so that writes made through it stay scoped
+	assert.Equal(scoped, scoped.WithSearch(collection).GetBackend())
+}
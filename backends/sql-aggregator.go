@@ -4,7 +4,8 @@ package backends
 
 import (
 	"database/sql"
-	"reflect"
+	"fmt"
+	"time"
 
 	"github.com/ghetzel/pivot/dal"
 	"github.com/ghetzel/pivot/filter"
@@ -35,6 +36,14 @@ func (self *SqlBackend) Average(collection *dal.Collection, field string, f ...*
 }
 
 func (self *SqlBackend) GroupBy(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, f ...*filter.Filter) (*dal.RecordSet, error) {
+	if resolved, found, err := self.resolveFullTextAggregate(collection, f); err != nil {
+		return nil, err
+	} else if !found {
+		return dal.NewRecordSet(), nil
+	} else {
+		f = resolved
+	}
+
 	if result, err := self.aggregate(collection, groupBy, aggregates, f, self.extractRecordSet); err == nil {
 		return result.(*dal.RecordSet), nil
 	} else {
@@ -42,7 +51,116 @@ func (self *SqlBackend) GroupBy(collection *dal.Collection, groupBy []string, ag
 	}
 }
 
+// Groups records into time buckets using the database's date functions (e.g.: date_trunc in
+// PostgreSQL).  First and Last aggregations are not supported.
+func (self *SqlBackend) AggregateByTime(collection *dal.Collection, field string, interval TimeInterval, fn filter.Aggregate, f ...*filter.Filter) ([]TimeBucket, error) {
+	bucketFormat, ok := self.timeBucketFormats[interval]
+
+	if !ok {
+		return nil, dal.Unsupported("%T cannot group by %v", self, interval)
+	}
+
+	switch fn.Aggregation {
+	case filter.First, filter.Last:
+		return nil, dal.Unsupported("%T cannot perform first or last aggregations over time", self)
+	}
+
+	if resolved, found, err := self.resolveFullTextAggregate(collection, f); err != nil {
+		return nil, err
+	} else if !found {
+		return make([]TimeBucket, 0), nil
+	} else {
+		f = resolved
+	}
+
+	var flt filter.Filter
+
+	if len(f) == 0 || f[0] == nil {
+		flt = filter.Copy(filter.All())
+	} else {
+		flt = filter.Copy(f[0])
+	}
+
+	// the matching records are selected in a subquery, which the buckets are grouped from
+	flt.Fields = nil
+	flt.Sort = nil
+	flt.Limit = 0
+	flt.Offset = 0
+
+	queryGen := self.makeQueryGen(collection)
+
+	if err := queryGen.Initialize(collection.Name); err != nil {
+		return nil, err
+	}
+
+	subquery, err := filter.Render(queryGen, collection.Name, &flt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	value := `COUNT(*)`
+
+	if fn.Aggregation != filter.Count && fn.Field != `` {
+		value = queryGen.ToAggregatedFieldName(fn.Aggregation, fn.Field)
+	}
+
+	stmt := fmt.Sprintf(
+		"SELECT %s, COUNT(*), %s FROM (%s) timeseries GROUP BY 1 ORDER BY 1",
+		fmt.Sprintf(bucketFormat, queryGen.ToFieldName(field)),
+		value,
+		string(subquery[:]),
+	)
+
+	querylog.Debugf("[%T] %s %v", self, stmt, queryGen.GetValues())
+
+	rows, err := self.db.Query(stmt, queryGen.GetValues()...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	buckets := make([]TimeBucket, 0)
+
+	for rows.Next() {
+		var start interface{}
+		var count uint64
+		var aggregated sql.NullFloat64
+
+		if err := rows.Scan(&start, &count, &aggregated); err != nil {
+			return nil, err
+		}
+
+		switch v := start.(type) {
+		case []byte:
+			start = sqlScanTime(v)
+		case string:
+			start = sqlScanTime([]byte(v))
+		}
+
+		if t, ok := start.(time.Time); ok {
+			buckets = append(buckets, TimeBucket{
+				Time:  interval.Truncate(t),
+				Count: count,
+				Value: aggregated.Float64,
+			})
+		}
+	}
+
+	return buckets, rows.Err()
+}
+
 func (self *SqlBackend) aggregateFloat(collection *dal.Collection, aggregation filter.Aggregation, field string, f []*filter.Filter) (float64, error) {
+	if resolved, found, err := self.resolveFullTextAggregate(collection, f); err != nil {
+		return 0, err
+	} else if !found {
+		return 0, nil
+	} else {
+		f = resolved
+	}
+
 	if result, err := self.aggregate(collection, nil, []filter.Aggregate{
 		{
 			Aggregation: aggregation,
@@ -118,8 +236,11 @@ func (self *SqlBackend) extractRecordSet(rows *sql.Rows, queryGen *generators.Sq
 	recordset := dal.NewRecordSet()
 
 	if columns, err := rows.Columns(); err == nil {
+		plan := newSqlScanPlan(queryGen, collection, columns, flt.Fields)
+		defer plan.Release()
+
 		for rows.Next() {
-			if record, err := self.scanFnValueToRecord(queryGen, collection, columns, reflect.ValueOf(rows.Scan), flt.Fields); err == nil {
+			if record, err := plan.Scan(rows); err == nil {
 				recordset.Push(record)
 			} else {
 				return nil, err
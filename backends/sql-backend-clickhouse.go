@@ -0,0 +1,302 @@
+package backends
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/ClickHouse/clickhouse-go"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/ghetzel/pivot/filter/generators"
+)
+
+// The table engine collections are created with, unless the "engine" option says otherwise (e.g.:
+// "?engine=ReplacingMergeTree()" to have rows with the same ID merged together.)  Rows are ordered by
+// their IDs.
+var ClickhouseDefaultEngine = `MergeTree()`
+
+// Objects and raw data are both stored as strings, so their columns are given these comments to tell
+// them apart when the table is read back.
+var ClickhouseObjectComment = `pivot:object`
+var ClickhouseRawComment = `pivot:raw`
+
+// ClickHouse is meant for analytic workloads, and so differs from the other SQL databases in a few
+// ways that matter here:
+//
+//   - IDs are not generated, so records must be inserted with one.  IDs are not unique either; rows
+//     inserted with the same ID are all kept unless the table engine merges them.
+//   - Rows are updated and deleted with ALTER TABLE mutations, which are applied asynchronously.
+//   - Inserts are batched into one block per set of fields being inserted.
+func (self *SqlBackend) initializeClickhouse() (string, string, error) {
+	// tell the backend cool details about generating compatible SQL
+	self.queryGenTypeMapping = generators.ClickhouseTypeMapping
+	self.queryGenPlaceholderFormat = `?`
+	self.queryGenPlaceholderArgument = ``
+	self.queryGenTableFormat = "`%s`"
+	self.queryGenFieldFormat = "`%s`"
+	self.queryGenNestedFieldFormat = "JSONExtractString(`%s`, '%s')"
+	self.queryGenNestedFieldJoiner = `', '`
+	self.queryGenNormalizerFormat = `replaceRegexpAll(lower(%v), '[:*\\[\\]]+', ' ')`
+	self.queryGenMutations = true
+	self.listAllTablesQuery = `SELECT name FROM system.tables WHERE database = currentDatabase()`
+	self.createPrimaryKeyIntFormat = `%s Int64`
+	self.createPrimaryKeyStrFormat = `%s String`
+	self.createTableSuffixFormat = fmt.Sprintf(" ENGINE = %s ORDER BY %%s", self.conn.OptString(`engine`, ClickhouseDefaultEngine))
+	self.columnDefinitionFunc = self.clickhouseColumnDefinition
+	self.timeBucketFormats = map[TimeInterval]string{
+		Minutely: `toStartOfMinute(%s)`,
+		Hourly:   `toStartOfHour(%s)`,
+		Daily:    `toStartOfDay(%s)`,
+		Weekly:   `toMonday(%s)`,
+		Monthly:  `toStartOfMonth(%s)`,
+		Yearly:   `toStartOfYear(%s)`,
+	}
+
+	// the bespoke method for determining table information for ClickHouse
+	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
+		stmt := `SELECT name, type, default_kind, default_expression, is_in_primary_key, comment ` +
+			`FROM system.columns ` +
+			`WHERE database = currentDatabase() AND table = ? ` +
+			`ORDER BY position`
+
+		querylog.Debugf("[%T] %s %v", self, stmt, collectionName)
+
+		if rows, err := self.db.Query(stmt, collectionName); err == nil {
+			defer rows.Close()
+
+			collection := dal.NewCollection(collectionName)
+			var hasIdentity bool
+
+			// for each field in the schema description for this table...
+			for rows.Next() {
+				var column, columnType, defaultKind, defaultExpression, comment string
+				var primaryKey uint8
+
+				if err := rows.Scan(&column, &columnType, &defaultKind, &defaultExpression, &primaryKey, &comment); err == nil {
+					field := dal.Field{
+						Name:       column,
+						NativeType: columnType,
+						Required:   true,
+					}
+
+					// only Nullable columns can hold NULLs
+					if inner, ok := clickhouseUnwrapType(columnType, `Nullable`); ok {
+						columnType = inner
+						field.Required = false
+					}
+
+					if inner, ok := clickhouseUnwrapType(columnType, `LowCardinality`); ok {
+						columnType = inner
+					}
+
+					switch defaultKind {
+					case `DEFAULT`:
+						field.DefaultValue = stringutil.Autotype(strings.Trim(defaultExpression, `'`))
+					case `MATERIALIZED`:
+						field.Generated = defaultExpression
+						field.GeneratedStored = true
+					case `ALIAS`:
+						field.Generated = defaultExpression
+					}
+
+					field.Type = clickhouseFieldType(columnType, comment)
+
+					// the first column of the sorting key identifies each row
+					if primaryKey == 1 && !hasIdentity {
+						hasIdentity = true
+						field.Identity = true
+						collection.IdentityField = column
+						collection.IdentityFieldType = field.Type
+					}
+
+					collection.Fields = append(collection.Fields, field)
+				} else {
+					return nil, err
+				}
+			}
+
+			return collection, rows.Err()
+		} else {
+			return nil, err
+		}
+	}
+
+	host := self.conn.Host()
+
+	// append port to host if not present
+	if !strings.Contains(host, `:`) {
+		host = fmt.Sprintf("%s:9000", host)
+	}
+
+	opts := self.driverOptions(map[string]string{
+		`database`: self.conn.Dataset(),
+	})
+
+	if u, p, ok := self.conn.Credentials(); ok {
+		opts.Set(`username`, u)
+		opts.Set(`password`, p)
+	}
+
+	dsn := `tcp://` + host
+
+	if v := opts.Encode(); v != `` {
+		dsn += `?` + v
+	}
+
+	return `clickhouse`, dsn, nil
+}
+
+// declares a column for the given field.  Columns are only nullable if the field isn't required, and
+// UNIQUE constraints are not supported (so they are ignored.)
+func (self *SqlBackend) clickhouseColumnDefinition(gen *generators.Sql, field dal.Field) (string, error) {
+	if field.Type.IsGeometry() {
+		return ``, dal.Unsupported("%T cannot store %v fields in ClickHouse", self, field.Type)
+	}
+
+	nativeType, err := gen.ToNativeType(field.Type, []dal.Type{field.Subtype}, 0)
+
+	if err != nil {
+		return ``, err
+	}
+
+	if !field.Required {
+		nativeType = fmt.Sprintf("Nullable(%s)", nativeType)
+	}
+
+	def := fmt.Sprintf("%s %s", gen.ToFieldName(field.Name), nativeType)
+
+	if field.Generated != `` {
+		if field.GeneratedStored {
+			def += fmt.Sprintf(" MATERIALIZED %s", field.Generated)
+		} else {
+			def += fmt.Sprintf(" ALIAS %s", field.Generated)
+		}
+	} else if v := field.DefaultValue; v != nil && !typeutil.IsFunction(v) {
+		if field.Type == dal.BooleanType {
+			if typeutil.V(v).Bool() {
+				def += ` DEFAULT 1`
+			} else {
+				def += ` DEFAULT 0`
+			}
+		} else {
+			def += fmt.Sprintf(" DEFAULT %v", gen.ToNativeValue(field.Type, []dal.Type{field.Subtype}, v))
+		}
+	}
+
+	switch field.Type {
+	case dal.ObjectType:
+		def += fmt.Sprintf(" COMMENT '%s'", ClickhouseObjectComment)
+	case dal.RawType:
+		def += fmt.Sprintf(" COMMENT '%s'", ClickhouseRawComment)
+	}
+
+	return def, nil
+}
+
+// Inserts the given records within an existing transaction.  The ClickHouse driver sends all the rows
+// inserted with a prepared statement as a single block when the transaction is committed, so records
+// are inserted using one statement for each distinct set of fields they have.
+func (self *SqlBackend) insertClickhouseTx(tx *sql.Tx, collection *dal.Collection, recordset *dal.RecordSet) error {
+	records := make([]*dal.Record, len(recordset.Records))
+
+	for i, record := range recordset.Records {
+		if r, err := collection.MakeRecord(record); err == nil {
+			if typeutil.IsZero(r.ID) {
+				return fmt.Errorf("Records inserted into %v must have an ID", collection.Name)
+			}
+
+			records[i] = r
+		} else {
+			return err
+		}
+	}
+
+	// convert incoming values to their destination field types
+	rows := collection.ConvertRecordValues(records)
+	convertId := collection.ValueConverter(collection.IdentityField)
+	statements := make(map[string]*sql.Stmt)
+
+	defer func() {
+		for _, stmt := range statements {
+			stmt.Close()
+		}
+	}()
+
+	for i, record := range records {
+		queryGen := self.makeQueryGen(collection)
+		queryGen.Type = generators.SqlInsertStatement
+
+		for k, v := range rows[i] {
+			if !isGeneratedField(collection, k) {
+				queryGen.InputData[k] = v
+			}
+		}
+
+		queryGen.InputData[collection.IdentityField] = convertId(record.ID)
+
+		if stmt, err := filter.Render(queryGen, collection.Name, filter.Null()); err == nil {
+			query := string(stmt[:])
+			prepared, ok := statements[query]
+
+			if !ok {
+				querylog.Debugf("[%T] %s", self, query)
+
+				if prepared, err = tx.Prepare(query); err == nil {
+					statements[query] = prepared
+				} else {
+					return err
+				}
+			}
+
+			_, err := prepared.Exec(queryGen.GetValues()...)
+			queryGen.Release()
+
+			if err != nil {
+				return translateSqlError(err)
+			}
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns the type wrapped by the given type (e.g.: "String" in "Nullable(String)")
+func clickhouseUnwrapType(columnType string, wrapper string) (string, bool) {
+	if strings.HasPrefix(columnType, wrapper+`(`) && strings.HasSuffix(columnType, `)`) {
+		return columnType[len(wrapper)+1 : len(columnType)-1], true
+	}
+
+	return columnType, false
+}
+
+// maps native types to DAL types
+func clickhouseFieldType(columnType string, comment string) dal.Type {
+	switch {
+	case columnType == `Int8`:
+		return dal.BooleanType
+	case strings.HasPrefix(columnType, `Int`), strings.HasPrefix(columnType, `UInt`):
+		return dal.IntType
+	case strings.HasPrefix(columnType, `Float`), strings.HasPrefix(columnType, `Decimal`):
+		return dal.FloatType
+	case strings.HasPrefix(columnType, `Date`):
+		return dal.TimeType
+	case strings.HasPrefix(columnType, `Array`), strings.HasPrefix(columnType, `Map`), strings.HasPrefix(columnType, `Tuple`):
+		return dal.ObjectType
+	case columnType == `String`, strings.HasPrefix(columnType, `FixedString`):
+		switch comment {
+		case ClickhouseObjectComment:
+			return dal.ObjectType
+		case ClickhouseRawComment:
+			return dal.RawType
+		default:
+			return dal.StringType
+		}
+	default:
+		return dal.RawType
+	}
+}
@@ -0,0 +1,16 @@
+package backends
+
+// CockroachDB speaks the PostgreSQL protocol and dialect, so it is set up the same way as PostgreSQL
+// except where the two differ.  Transactions that CockroachDB aborts with a serialization failure
+// (which it does far more often than PostgreSQL) are retried; see withTransaction.
+func (self *SqlBackend) initializeCockroach() (string, string, error) {
+	if name, _, err := self.initializePostgres(); err == nil {
+		// SERIAL columns are backed by unique_rowid() rather than sequences, and the values it
+		// generates don't fit in anything smaller than an INT8
+		self.createPrimaryKeyIntFormat = `%s INT8 PRIMARY KEY DEFAULT unique_rowid()`
+
+		return name, self.postgresDSN(26257), nil
+	} else {
+		return ``, ``, err
+	}
+}
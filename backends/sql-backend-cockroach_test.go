@@ -0,0 +1,102 @@
+package backends
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeCockroach(t *testing.T) {
+	assert := require.New(t)
+
+	for connString, dsn := range map[string]string{
+		`cockroach://db.example.com/test`:              `postgres://db.example.com:26257/test?sslmode=disable`,
+		`cockroachdb://db.example.com:5432/test`:       `postgres://db.example.com:5432/test?sslmode=disable`,
+		`cockroach://db.example.com/test?sslmode=full`: `postgres://db.example.com:26257/test?sslmode=full`,
+	} {
+		cs, err := dal.ParseConnectionString(connString)
+		assert.NoError(err)
+
+		backend := NewSqlBackend(cs).(*SqlBackend)
+		name, actual, err := backend.initializeCockroach()
+		assert.NoError(err)
+		assert.Equal(`postgres`, name)
+		assert.Equal(dsn, actual, connString)
+
+		// row IDs are generated by CockroachDB rather than by a sequence
+		assert.Equal(`%s INT8 PRIMARY KEY DEFAULT unique_rowid()`, backend.createPrimaryKeyIntFormat)
+		assert.Equal(`$%d`, backend.queryGenPlaceholderFormat)
+	}
+}
+
+func TestIsRetryableSqlError(t *testing.T) {
+	assert := require.New(t)
+
+	assert.True(isRetryableSqlError(&pq.Error{Code: `40001`}))
+	assert.True(isRetryableSqlError(&pq.Error{Code: `40P01`}))
+	assert.True(isRetryableSqlError(&mysql.MySQLError{Number: 1205}))
+	assert.True(isRetryableSqlError(&mysql.MySQLError{Number: 1213}))
+	assert.True(isRetryableSqlError(fmt.Errorf("insert failed: %w", &pq.Error{Code: `40001`})))
+
+	assert.False(isRetryableSqlError(&pq.Error{Code: `23505`}))
+	assert.False(isRetryableSqlError(&mysql.MySQLError{Number: 1062}))
+	assert.False(isRetryableSqlError(errors.New(`serialization failure`)))
+	assert.False(isRetryableSqlError(nil))
+}
+
+func TestSqlTransactionRetries(t *testing.T) {
+	assert := require.New(t)
+
+	db, err := sql.Open(`sqlite3`, `:memory:`)
+	assert.NoError(err)
+	defer db.Close()
+
+	cs, err := dal.ParseConnectionString(`cockroach://localhost/test?deadlockRetries=2&deadlockRetryDelay=1ms`)
+	assert.NoError(err)
+
+	backend := NewSqlBackend(cs).(*SqlBackend)
+	backend.db = db
+
+	// transactions aborted by contention are retried from the start
+	var attempts int
+	restart := &pq.Error{Code: `40001`}
+
+	assert.NoError(backend.withTransaction(func(tx *sql.Tx) error {
+		attempts += 1
+
+		if attempts < 3 {
+			return restart
+		}
+
+		return nil
+	}))
+
+	assert.Equal(3, attempts)
+
+	// ...up to the configured number of times
+	attempts = 0
+
+	assert.Equal(restart, backend.withTransaction(func(tx *sql.Tx) error {
+		attempts += 1
+		return restart
+	}))
+
+	assert.Equal(3, attempts)
+
+	// other errors aren't retried
+	attempts = 0
+	failure := &pq.Error{Code: `23505`}
+
+	assert.Equal(failure, backend.withTransaction(func(tx *sql.Tx) error {
+		attempts += 1
+		return failure
+	}))
+
+	assert.Equal(1, attempts)
+}
@@ -0,0 +1,190 @@
+package backends
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter/generators"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+// The sequence integer IDs are drawn from, since DuckDB has no auto-incrementing column types.
+var DuckdbIdentitySequence = `pivot_id_seq`
+
+// DuckDB is an embedded analytical database, and so is opened much like sqlite: "duckdb://file.db"
+// (or "duckdb:///path/to/file.db") opens a database file, and "duckdb://memory" opens an in-memory
+// database.
+func (self *SqlBackend) initializeDuckdb() (string, string, error) {
+	// tell the backend cool details about generating compatible SQL
+	self.queryGenTypeMapping = generators.DuckdbTypeMapping
+	self.queryGenTableFormat = "%q"
+	self.queryGenFieldFormat = "%q"
+	self.queryGenNestedFieldFormat = "json_extract_string(\"%s\", '$.%s')"
+	self.queryGenNestedIndexFormat = `[%s]`
+	self.queryGenNormalizerFormat = `regexp_replace(lower(%v), '[:*\[\]]+', ' ', 'g')`
+	self.listAllTablesQuery = `SELECT table_name FROM information_schema.tables WHERE table_schema = 'main'`
+	self.createPrimaryKeyIntFormat = `%s BIGINT PRIMARY KEY DEFAULT nextval('` + DuckdbIdentitySequence + `')`
+	self.createPrimaryKeyStrFormat = `%s VARCHAR PRIMARY KEY`
+	self.initializeStatements = []string{
+		`CREATE SEQUENCE IF NOT EXISTS ` + DuckdbIdentitySequence,
+	}
+	self.timeBucketFormats = map[TimeInterval]string{
+		Minutely: `date_trunc('minute', %s)`,
+		Hourly:   `date_trunc('hour', %s)`,
+		Daily:    `date_trunc('day', %s)`,
+		Weekly:   `date_trunc('week', %s)`,
+		Monthly:  `date_trunc('month', %s)`,
+		Yearly:   `date_trunc('year', %s)`,
+	}
+
+	// the bespoke method for determining table information for DuckDB
+	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
+		var primaryKeys, uniqueConstraints []string
+
+		if p, u, err := self.duckdbGetTableKeys(collectionName); err == nil {
+			primaryKeys = p
+			uniqueConstraints = u
+		} else {
+			return nil, err
+		}
+
+		stmt := `SELECT column_name, data_type, is_nullable, column_default ` +
+			`FROM information_schema.columns ` +
+			`WHERE table_schema = 'main' AND table_name = ? ` +
+			`ORDER BY ordinal_position`
+
+		querylog.Debugf("[%T] %s %v", self, stmt, collectionName)
+
+		if rows, err := self.db.Query(stmt, collectionName); err == nil {
+			defer rows.Close()
+
+			collection := dal.NewCollection(collectionName)
+			queryGen := self.makeQueryGen(nil)
+
+			var foundPrimaryKey bool
+
+			for rows.Next() {
+				var column, columnType, nullable string
+				var defaultValue sql.NullString
+
+				if err := rows.Scan(&column, &columnType, &nullable, &defaultValue); err == nil {
+					field := dal.Field{
+						Name:       column,
+						NativeType: columnType,
+						Required:   (nullable == `NO`),
+						Unique:     sliceutil.ContainsString(uniqueConstraints, column),
+					}
+
+					// set default value if it's not NULL (or drawn from a sequence)
+					if defaultValue.Valid && !strings.HasPrefix(defaultValue.String, `nextval(`) {
+						field.DefaultValue = stringutil.Autotype(strings.Trim(defaultValue.String, `'`))
+					}
+
+					field.Type = duckdbFieldType(queryGen, columnType)
+
+					if sliceutil.ContainsString(primaryKeys, column) {
+						if !foundPrimaryKey {
+							field.Identity = true
+							foundPrimaryKey = true
+							collection.IdentityField = column
+							collection.IdentityFieldType = field.Type
+						} else {
+							field.Key = true
+						}
+					}
+
+					collection.Fields = append(collection.Fields, field)
+				} else {
+					return nil, err
+				}
+			}
+
+			return collection, rows.Err()
+		} else {
+			return nil, err
+		}
+	}
+
+	var dsn string
+
+	if host, dataset := self.conn.Host(), self.conn.Dataset(); host == `memory` || (host == `` && dataset == `memory`) {
+		// every connection to an in-memory database gets its own (empty) database, so only ever
+		// open one
+		self.maxOpenConns = 1
+	} else if path, err := kvFilePath(*self.conn); err == nil {
+		dsn = path
+	} else {
+		return ``, ``, err
+	}
+
+	if opts := self.driverOptions(nil); len(opts) > 0 {
+		dsn += `?` + opts.Encode()
+	}
+
+	return `duckdb`, dsn, nil
+}
+
+// returns the columns that make up the primary key and those with UNIQUE constraints on the given table
+func (self *SqlBackend) duckdbGetTableKeys(collectionName string) ([]string, []string, error) {
+	primaryKeys := make([]string, 0)
+	uniqueConstraints := make([]string, 0)
+
+	stmt := `SELECT constraint_type, unnest(constraint_column_names) ` +
+		`FROM duckdb_constraints() ` +
+		`WHERE table_name = ? AND constraint_type IN ('PRIMARY KEY', 'UNIQUE')`
+
+	querylog.Debugf("[%T] %s %v", self, stmt, collectionName)
+
+	if rows, err := self.db.Query(stmt, collectionName); err == nil {
+		defer rows.Close()
+
+		for rows.Next() {
+			var constraintType, column string
+
+			if err := rows.Scan(&constraintType, &column); err == nil {
+				switch constraintType {
+				case `PRIMARY KEY`:
+					primaryKeys = append(primaryKeys, column)
+				case `UNIQUE`:
+					uniqueConstraints = append(uniqueConstraints, column)
+				}
+			} else {
+				return nil, nil, err
+			}
+		}
+
+		return primaryKeys, uniqueConstraints, rows.Err()
+	} else {
+		return nil, nil, err
+	}
+}
+
+// maps native types to DAL types
+func duckdbFieldType(queryGen *generators.Sql, columnType string) dal.Type {
+	// lists (e.g.: "VARCHAR[]") and nested types are stored as objects
+	if strings.HasSuffix(columnType, `]`) {
+		return dal.ObjectType
+	}
+
+	columnType, _, _ = queryGen.SplitTypeLength(columnType)
+
+	switch columnType {
+	case `VARCHAR`, `UUID`:
+		return dal.StringType
+	case `TINYINT`, `SMALLINT`, `INTEGER`, `BIGINT`, `HUGEINT`, `UTINYINT`, `USMALLINT`, `UINTEGER`, `UBIGINT`:
+		return dal.IntType
+	case `BOOLEAN`:
+		return dal.BooleanType
+	case `FLOAT`, `REAL`, `DOUBLE`, `DECIMAL`:
+		return dal.FloatType
+	case `TIMESTAMP`, `TIMESTAMP WITH TIME ZONE`, `DATE`, `TIME`:
+		return dal.TimeType
+	case `JSON`, `STRUCT`, `MAP`:
+		return dal.ObjectType
+	default:
+		return dal.RawType
+	}
+}
@@ -0,0 +1,67 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeDuckdb(t *testing.T) {
+	assert := require.New(t)
+
+	for connString, dsn := range map[string]string{
+		`duckdb://memory`:                                ``,
+		`duckdb:///memory`:                               ``,
+		`duckdb:///var/lib/pivot/app.db`:                 `/var/lib/pivot/app.db`,
+		`duckdb:///var/lib/pivot/app.db?threads=4`:       `/var/lib/pivot/app.db?threads=4`,
+		`duckdb:///var/lib/pivot/app.db?lazySchema=true`: `/var/lib/pivot/app.db`,
+	} {
+		cs, err := dal.ParseConnectionString(connString)
+		assert.NoError(err)
+
+		backend := NewSqlBackend(cs).(*SqlBackend)
+		name, actual, err := backend.initializeDuckdb()
+		assert.NoError(err)
+		assert.Equal(`duckdb`, name)
+		assert.Equal(dsn, actual, connString)
+
+		// every connection to an in-memory database would get a different database
+		if dsn == `` {
+			assert.Equal(1, backend.maxOpenConns)
+		} else {
+			assert.Zero(backend.maxOpenConns)
+		}
+	}
+}
+
+func TestDuckdbFieldType(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`duckdb://memory`)
+	assert.NoError(err)
+
+	backend := NewSqlBackend(cs).(*SqlBackend)
+	_, _, err = backend.initializeDuckdb()
+	assert.NoError(err)
+
+	gen := backend.makeQueryGen(nil)
+
+	for columnType, expected := range map[string]dal.Type{
+		`VARCHAR`:                  dal.StringType,
+		`UUID`:                     dal.StringType,
+		`INTEGER`:                  dal.IntType,
+		`UBIGINT`:                  dal.IntType,
+		`BOOLEAN`:                  dal.BooleanType,
+		`DECIMAL(18,3)`:            dal.FloatType,
+		`DOUBLE`:                   dal.FloatType,
+		`TIMESTAMP`:                dal.TimeType,
+		`TIMESTAMP WITH TIME ZONE`: dal.TimeType,
+		`JSON`:                     dal.ObjectType,
+		`VARCHAR[]`:                dal.ObjectType,
+		`INTEGER[3]`:               dal.ObjectType,
+		`BLOB`:                     dal.RawType,
+	} {
+		assert.Equal(expected, duckdbFieldType(gen, columnType), columnType)
+	}
+}
@@ -0,0 +1,232 @@
+package backends
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	_ "github.com/denisenkom/go-mssqldb"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/ghetzel/pivot/filter/generators"
+)
+
+func (self *SqlBackend) initializeMssql() (string, string, error) {
+	// tell the backend cool details about generating compatible SQL
+	self.queryGenTypeMapping = generators.MssqlTypeMapping
+	self.queryGenPlaceholderFormat = `@p%d`
+	self.queryGenPlaceholderArgument = `index1`
+	self.queryGenTableFormat = "[%s]"
+	self.queryGenFieldFormat = "[%s]"
+	self.queryGenNestedFieldFormat = "JSON_VALUE([%s], '$.%s')"
+	self.queryGenNestedIndexFormat = `[%s]`
+	self.queryGenNormalizerFormat = "LOWER(REPLACE(REPLACE(REPLACE(REPLACE(%v, ':', ' '), '[', ' '), ']', ' '), '*', ' '))"
+	self.queryGenOffsetFetch = true
+	self.listAllTablesQuery = `SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_CATALOG = DB_NAME()`
+	self.createPrimaryKeyIntFormat = `%s BIGINT IDENTITY(1,1) NOT NULL PRIMARY KEY`
+	self.createPrimaryKeyStrFormat = `%s NVARCHAR(255) NOT NULL PRIMARY KEY`
+
+	// the bespoke method for determining table information for SQL Server
+	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
+		keyStmt := `SELECT ` +
+			`kc.COLUMN_NAME, tc.CONSTRAINT_TYPE ` +
+			`FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc, INFORMATION_SCHEMA.KEY_COLUMN_USAGE kc ` +
+			`WHERE kc.TABLE_NAME = tc.TABLE_NAME ` +
+			`AND kc.TABLE_SCHEMA = tc.TABLE_SCHEMA ` +
+			`AND kc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME ` +
+			`AND tc.CONSTRAINT_CATALOG = DB_NAME() ` +
+			`AND tc.TABLE_NAME = @p1 ` +
+			`ORDER BY kc.COLUMN_NAME, tc.CONSTRAINT_TYPE`
+
+		primaryKeys := make(map[string]bool)
+		uniqueKeys := make(map[string]bool)
+		foreignKeys := make(map[string]bool)
+
+		if keyRows, err := self.db.Query(string(keyStmt[:]), collectionName); err == nil {
+			defer keyRows.Close()
+
+			// for each key on this table...
+			for keyRows.Next() {
+				var columnName, constraintType string
+
+				if err := keyRows.Scan(&columnName, &constraintType); err == nil {
+					switch constraintType {
+					case `PRIMARY KEY`:
+						primaryKeys[columnName] = true
+					case `FOREIGN KEY`:
+						foreignKeys[columnName] = true
+					case `UNIQUE`:
+						uniqueKeys[columnName] = true
+					}
+				} else {
+					return nil, err
+				}
+			}
+
+			keyRows.Close()
+		} else {
+			return nil, err
+		}
+
+		if f, err := filter.FromMap(map[string]interface{}{
+			`TABLE_CATALOG`: datasetName,
+			`TABLE_NAME`:    collectionName,
+		}); err == nil {
+			f.Fields = []string{
+				`ORDINAL_POSITION`,
+				`COLUMN_NAME`,
+				`DATA_TYPE`,
+				`CHARACTER_MAXIMUM_LENGTH`,
+				`NUMERIC_PRECISION`,
+				`NUMERIC_SCALE`,
+				`IS_NULLABLE`,
+				`COLUMN_DEFAULT`,
+			}
+
+			queryGen := self.makeQueryGen(nil)
+
+			// make this instance of the query generator use the table name as given because
+			// we need to reference another schema (INFORMATION_SCHEMA)
+			queryGen.TableNameFormat = "%s"
+
+			if stmt, err := filter.Render(queryGen, `INFORMATION_SCHEMA.COLUMNS`, f); err == nil {
+				querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+				if rows, err := self.db.Query(string(stmt[:]), queryGen.GetValues()...); err == nil {
+					defer rows.Close()
+
+					collection := dal.NewCollection(collectionName)
+
+					// for each field in the schema description for this table...
+					for rows.Next() {
+						var i int
+						var maxLength, numericPrecision, numericScale sql.NullInt64
+						var column, columnType, nullable string
+						var defaultValue sql.NullString
+
+						// populate variables from column values
+						if err := rows.Scan(&i, &column, &columnType, &maxLength, &numericPrecision, &numericScale, &nullable, &defaultValue); err == nil {
+							// start building the dal.Field
+							field := dal.Field{
+								Name:       column,
+								NativeType: columnType,
+								Required:   (nullable != `YES`),
+							}
+
+							// set default value if it's not NULL
+							if defaultValue.Valid {
+								field.DefaultValue = stringutil.Autotype(mssqlUnwrapDefault(defaultValue.String))
+							}
+
+							columnType = strings.ToUpper(columnType)
+
+							// map native types to DAL types
+							if strings.HasSuffix(columnType, `CHAR`) || strings.HasSuffix(columnType, `TEXT`) {
+								// NVARCHAR(MAX) columns are reported with a length of -1, and are
+								// where objects are stored
+								if maxLength.Int64 < 0 {
+									field.Type = dal.ObjectType
+								} else {
+									field.Type = dal.StringType
+									field.Length = int(maxLength.Int64)
+								}
+
+							} else if columnType == `BIT` {
+								field.Type = dal.BooleanType
+
+							} else if strings.HasSuffix(columnType, `INT`) {
+								field.Type = dal.IntType
+
+							} else if columnType == `FLOAT` || columnType == `REAL` || columnType == `DECIMAL` || columnType == `NUMERIC` || strings.HasSuffix(columnType, `MONEY`) {
+								field.Type = dal.FloatType
+
+								if columnType == `DECIMAL` || columnType == `NUMERIC` {
+									field.Length = int(numericPrecision.Int64)
+									field.Precision = int(numericScale.Int64)
+								}
+
+							} else if strings.HasPrefix(columnType, `DATE`) || strings.Contains(columnType, `TIME`) {
+								field.Type = dal.TimeType
+
+							} else {
+								field.Type = dal.RawType
+							}
+
+							// figure out keying
+							if v, ok := primaryKeys[column]; ok && v {
+								field.Identity = true
+								collection.IdentityField = column
+								collection.IdentityFieldType = field.Type
+							} else if v, ok := foreignKeys[column]; ok && v {
+								field.Key = true
+							}
+
+							if v, ok := uniqueKeys[column]; ok && v {
+								field.Unique = true
+							}
+
+							// add field to the collection we're building
+							collection.Fields = append(collection.Fields, field)
+						} else {
+							return nil, err
+						}
+					}
+
+					return collection, rows.Err()
+				} else {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	var host string
+
+	// prepend port to host if not present
+	if strings.Contains(self.conn.Host(), `:`) {
+		host = self.conn.Host()
+	} else {
+		host = fmt.Sprintf("%s:1433", self.conn.Host())
+	}
+
+	dsn := &url.URL{
+		Scheme: `sqlserver`,
+		Host:   host,
+	}
+
+	if u, p, ok := self.conn.Credentials(); ok {
+		dsn.User = url.UserPassword(u, p)
+	}
+
+	opts := self.driverOptions(map[string]string{
+		`database`: self.conn.Dataset(),
+	})
+
+	dsn.RawQuery = opts.Encode()
+
+	return `sqlserver`, dsn.String(), nil
+}
+
+// SQL Server reports column defaults as they were written in the table definition, wrapped in
+// parentheses (e.g.: "((42))" or "(N'hello')")
+func mssqlUnwrapDefault(in string) string {
+	for stringutil.IsSurroundedBy(in, `(`, `)`) {
+		in = in[1 : len(in)-1]
+	}
+
+	if stringutil.IsSurroundedBy(in, `N'`, `'`) {
+		in = in[1:]
+	}
+
+	if stringutil.IsSurroundedBy(in, `'`, `'`) {
+		in = strings.Replace(in[1:len(in)-1], `''`, `'`, -1)
+	}
+
+	return in
+}
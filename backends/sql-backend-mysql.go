@@ -19,10 +19,20 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 	self.queryGenPlaceholderArgument = ``
 	self.queryGenTableFormat = "`%s`"
 	self.queryGenFieldFormat = "`%s`"
+	self.queryGenNestedFieldFormat = "JSON_UNQUOTE(JSON_EXTRACT(`%s`, '$.%s'))"
+	self.queryGenNestedIndexFormat = `[%s]`
 	self.queryGenNormalizerFormat = "LOWER(REPLACE(REPLACE(REPLACE(REPLACE(%v, ':', ' '), '[', ' '), ']', ' '), '*', ' '))"
 	self.listAllTablesQuery = `SHOW TABLES`
 	self.createPrimaryKeyIntFormat = `%s INT AUTO_INCREMENT NOT NULL PRIMARY KEY`
 	self.createPrimaryKeyStrFormat = `%s VARCHAR(255) NOT NULL PRIMARY KEY`
+	self.timeBucketFormats = map[TimeInterval]string{
+		Minutely: `DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:%%i:00')`,
+		Hourly:   `DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:00:00')`,
+		Daily:    `DATE(%s)`,
+		Weekly:   `DATE_SUB(DATE(%[1]s), INTERVAL WEEKDAY(%[1]s) DAY)`,
+		Monthly:  `DATE_FORMAT(%s, '%%Y-%%m-01')`,
+		Yearly:   `DATE_FORMAT(%s, '%%Y-01-01')`,
+	}
 
 	// the bespoke method for determining table information for sqlite3
 	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
@@ -37,6 +47,8 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 				`IS_NULLABLE`,
 				`COLUMN_DEFAULT`,
 				`COLUMN_KEY`,
+				`EXTRA`,
+				`GENERATION_EXPRESSION`,
 			}
 
 			queryGen := self.makeQueryGen(nil)
@@ -57,10 +69,10 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 					for rows.Next() {
 						var i int
 						var column, columnType, nullable string
-						var defaultValue, keyType sql.NullString
+						var defaultValue, keyType, extra, generated sql.NullString
 
 						// populate variables from column values
-						if err := rows.Scan(&i, &column, &columnType, &nullable, &defaultValue, &keyType); err == nil {
+						if err := rows.Scan(&i, &column, &columnType, &nullable, &defaultValue, &keyType, &extra, &generated); err == nil {
 							// start building the dal.Field
 							field := dal.Field{
 								Name:       column,
@@ -68,8 +80,12 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 								Required:   (nullable != `YES`),
 							}
 
-							// set default value if it's not NULL
-							if defaultValue.Valid {
+							// generated columns are flagged as "VIRTUAL GENERATED" or "STORED GENERATED"
+							if strings.Contains(extra.String, `GENERATED`) && generated.String != `` {
+								field.Generated = generated.String
+								field.GeneratedStored = strings.Contains(extra.String, `STORED`)
+							} else if defaultValue.Valid {
+								// set default value if it's not NULL
 								field.DefaultValue = stringutil.Autotype(defaultValue.String)
 							}
 
@@ -97,6 +113,15 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 							} else if strings.HasPrefix(columnType, `DATE`) || strings.Contains(columnType, `TIME`) {
 								field.Type = dal.TimeType
 
+							} else if columnType == `JSON` {
+								field.Type = dal.ObjectType
+
+							} else if columnType == `POINT` {
+								field.Type = dal.PointType
+
+							} else if columnType == `POLYGON` {
+								field.Type = dal.PolygonType
+
 							} else {
 								if field.Length == objectFieldHintLength {
 									field.Type = dal.ObjectType
@@ -165,5 +190,15 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 		self.conn.Dataset(),
 	)
 
+	// have the driver return DATE and DATETIME columns as time.Time values
+	opts := self.driverOptions(map[string]string{
+		`parseTime`: `true`,
+		`loc`:       `UTC`,
+	})
+
+	if len(opts) > 0 {
+		dsn = dsn + `?` + opts.Encode()
+	}
+
 	return `mysql`, dsn, nil
 }
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/pivot/dal"
 	"github.com/ghetzel/pivot/filter"
@@ -20,10 +19,20 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 	self.queryGenPlaceholderArgument = `index1`
 	self.queryGenTableFormat = "%q"
 	self.queryGenFieldFormat = "%q"
+	self.queryGenNestedFieldFormat = "%q #>> '{%s}'"
+	self.queryGenNestedFieldJoiner = `,`
 	self.queryGenNormalizerFormat = "regexp_replace(lower(%v), '[\\:\\[\\]\\*]+', ' ')"
-	self.listAllTablesQuery = `SELECT table_name from information_schema.TABLES WHERE table_catalog = CURRENT_CATALOG AND table_schema = 'public'`
+	self.listAllTablesQuery = `SELECT table_name from information_schema.TABLES WHERE table_catalog = current_database() AND table_schema = 'public'`
 	self.createPrimaryKeyIntFormat = `%s BIGSERIAL PRIMARY KEY`
 	self.createPrimaryKeyStrFormat = `%s VARCHAR(255) PRIMARY KEY`
+	self.timeBucketFormats = map[TimeInterval]string{
+		Minutely: `date_trunc('minute', %s)`,
+		Hourly:   `date_trunc('hour', %s)`,
+		Daily:    `date_trunc('day', %s)`,
+		Weekly:   `date_trunc('week', %s)`,
+		Monthly:  `date_trunc('month', %s)`,
+		Yearly:   `date_trunc('year', %s)`,
+	}
 
 	// the bespoke method for determining table information for sqlite3
 	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
@@ -33,7 +42,7 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 			`WHERE kc.table_name = tc.table_name ` +
 			`AND kc.table_schema = tc.table_schema ` +
 			`AND kc.constraint_name = tc.constraint_name ` +
-			`AND tc.constraint_catalog = CURRENT_CATALOG ` +
+			`AND tc.constraint_catalog = current_database() ` +
 			`AND tc.table_name = $1 ` +
 			`ORDER BY kc.column_name, tc.constraint_type`
 
@@ -79,6 +88,7 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 				`character_octet_length`,
 				`is_nullable`,
 				`column_default`,
+				`udt_name`,
 			}
 
 			queryGen := self.makeQueryGen(nil)
@@ -94,16 +104,17 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 					defer rows.Close()
 
 					collection := dal.NewCollection(collectionName)
+					var hasGeometry bool
 
 					// for each field in the schema description for this table...
 					for rows.Next() {
 						var i int
 						var octetLength sql.NullInt64
-						var column, columnType, nullable string
+						var column, columnType, nullable, udtName string
 						var defaultValue sql.NullString
 
 						// populate variables from column values
-						if err := rows.Scan(&i, &column, &columnType, &octetLength, &nullable, &defaultValue); err == nil {
+						if err := rows.Scan(&i, &column, &columnType, &octetLength, &nullable, &defaultValue, &udtName); err == nil {
 							// start building the dal.Field
 							field := dal.Field{
 								Name:       column,
@@ -111,8 +122,8 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 								Required:   (nullable != `YES`),
 							}
 
-							// set default value if it's not NULL
-							if defaultValue.Valid && !stringutil.IsSurroundedBy(defaultValue.String, `nextval(`, `)`) {
+							// set default value if it's not NULL (or generated, like sequences and CockroachDB's row IDs)
+							if defaultValue.Valid && !stringutil.IsSurroundedBy(defaultValue.String, `nextval(`, `)`) && defaultValue.String != `unique_rowid()` {
 								field.DefaultValue = stringutil.Autotype(defaultValue.String)
 							}
 
@@ -142,6 +153,15 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 							} else if strings.HasPrefix(columnType, `DATE`) || strings.Contains(columnType, `TIME`) {
 								field.Type = dal.TimeType
 
+							} else if strings.HasPrefix(columnType, `JSON`) {
+								field.Type = dal.ObjectType
+
+							} else if udtName == `geometry` {
+								// PostGIS geometry columns are all of the same type, whose subtype
+								// (point or polygon) is looked up below
+								field.Type = dal.PointType
+								hasGeometry = true
+
 							} else {
 								if field.Length == objectFieldHintLength {
 									field.Type = dal.ObjectType
@@ -170,7 +190,15 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 						}
 					}
 
-					return collection, rows.Err()
+					if err := rows.Err(); err != nil {
+						return nil, err
+					} else if hasGeometry {
+						if err := self.postgresGeometryTypes(collection); err != nil {
+							return nil, err
+						}
+					}
+
+					return collection, nil
 				} else {
 					return nil, err
 				}
@@ -182,6 +210,12 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 		}
 	}
 
+	return `postgres`, self.postgresDSN(5432), nil
+}
+
+// builds the DSN of a database that speaks the PostgreSQL protocol, listening on the given port
+// unless the connection string specifies one
+func (self *SqlBackend) postgresDSN(defaultPort int) string {
 	var dsn, host string
 
 	dsn = `postgres://`
@@ -190,7 +224,7 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 	if strings.Contains(self.conn.Host(), `:`) {
 		host = self.conn.Host()
 	} else {
-		host = fmt.Sprintf("%s:5432", self.conn.Host())
+		host = fmt.Sprintf("%s:%d", self.conn.Host(), defaultPort)
 	}
 
 	if u, p, ok := self.conn.Credentials(); ok {
@@ -200,22 +234,41 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 	dsn += host
 	dsn += `/` + self.conn.Dataset()
 
-	opts := self.conn.URI.Query()
-
-	// pull out pivot-specific options first
-	for k, vv := range opts {
-		switch k {
-		case `autoregister`:
-			self.conn.Options[k] = strings.Join(vv, `,`)
-			opts.Del(k)
-		}
-	}
-
-	opts.Set(`sslmode`, sliceutil.OrString(opts.Get(`sslmode`), `disable`))
+	opts := self.driverOptions(map[string]string{
+		`sslmode`: `disable`,
+	})
 
 	if v := opts.Encode(); v != `` {
 		dsn += `?` + v
 	}
 
-	return `postgres`, dsn, nil
+	return dsn
+}
+
+// sets the type of the collection's geometry fields from the geometry type PostGIS has for them
+func (self *SqlBackend) postgresGeometryTypes(collection *dal.Collection) error {
+	stmt := `SELECT f_geometry_column, type FROM geometry_columns WHERE f_table_schema = 'public' AND f_table_name = $1`
+	querylog.Debugf("[%T] %s %v", self, stmt, collection.Name)
+
+	if rows, err := self.db.Query(stmt, collection.Name); err == nil {
+		defer rows.Close()
+
+		for rows.Next() {
+			var column, geometryType string
+
+			if err := rows.Scan(&column, &geometryType); err == nil {
+				for i, field := range collection.Fields {
+					if field.Name == column && strings.ToUpper(geometryType) == `POLYGON` {
+						collection.Fields[i].Type = dal.PolygonType
+					}
+				}
+			} else {
+				return err
+			}
+		}
+
+		return rows.Err()
+	} else {
+		return err
+	}
 }
@@ -3,18 +3,34 @@ package backends
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/ghetzel/go-stockutil/maputil"
 	"github.com/ghetzel/go-stockutil/pathutil"
 	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/pivot/dal"
 	"github.com/ghetzel/pivot/filter/generators"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
+// How long sqlite connections wait for locks held by other connections before failing with "database
+// is locked".  This can be set per-connection with the busy_timeout option (in milliseconds).
+var SqliteBusyTimeout = 5 * time.Second
+
+// The journal mode of file-backed sqlite databases.  Write-ahead logging allows reads to happen
+// concurrently with writes.  This can be set per-connection with the journal_mode option.
+var SqliteJournalMode = `WAL`
+
+// The SpatiaLite extension loaded by connections opened with the spatialite option, which stores
+// geometry fields natively and indexes them with R-trees.
+var SpatialiteExtension = `mod_spatialite`
+
+var registerSpatialiteDriver sync.Once
+
 func (self *SqlBackend) initializeSqlite() (string, string, error) {
 	// tell the backend cool details about generating compatible SQL
 	self.queryGenTypeMapping = generators.SqliteTypeMapping
@@ -24,6 +40,28 @@ func (self *SqlBackend) initializeSqlite() (string, string, error) {
 	self.listAllTablesQuery = `SELECT name FROM sqlite_master`
 	self.createPrimaryKeyIntFormat = `%s INTEGER NOT NULL PRIMARY KEY ASC`
 	self.createPrimaryKeyStrFormat = `%s TEXT NOT NULL PRIMARY KEY`
+	self.timeBucketFormats = map[TimeInterval]string{
+		Minutely: `strftime('%%Y-%%m-%%d %%H:%%M:00', %s)`,
+		Hourly:   `strftime('%%Y-%%m-%%d %%H:00:00', %s)`,
+		Daily:    `date(%s)`,
+		Weekly:   `date(%s, 'weekday 0', '-6 days')`,
+		Monthly:  `strftime('%%Y-%%m-01', %s)`,
+		Yearly:   `strftime('%%Y-01-01', %s)`,
+	}
+
+	driver := `sqlite3`
+
+	if self.conn.OptBool(`spatialite`, false) {
+		registerSpatialiteDriver.Do(func() {
+			sql.Register(`sqlite3_spatialite`, &sqlite3.SQLiteDriver{
+				Extensions: []string{SpatialiteExtension},
+			})
+		})
+
+		driver = `sqlite3_spatialite`
+		self.queryGenTypeMapping = generators.SpatialiteTypeMapping
+		self.spatialite = true
+	}
 
 	// the bespoke method for determining table information for sqlite3
 	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
@@ -48,6 +86,7 @@ func (self *SqlBackend) initializeSqlite() (string, string, error) {
 					switch option {
 					case `ENABLE_JSON1`:
 						self.queryGenNestedFieldFormat = "json_extract(%v, '$.%v')"
+						self.queryGenNestedIndexFormat = `[%v]`
 						log.Debugf("sqlite: using JSON1 extension")
 					}
 				} else {
@@ -107,6 +146,18 @@ func (self *SqlBackend) initializeSqlite() (string, string, error) {
 					case `REAL`:
 						field.Type = dal.FloatType
 
+					case `DATETIME`, `TIMESTAMP`, `DATE`:
+						field.Type = dal.TimeType
+
+					case `JSON`:
+						field.Type = dal.ObjectType
+
+					case `POINT`:
+						field.Type = dal.PointType
+
+					case `POLYGON`:
+						field.Type = dal.PolygonType
+
 					default:
 						if field.Length == objectFieldHintLength {
 							field.Type = dal.ObjectType
@@ -141,11 +192,22 @@ func (self *SqlBackend) initializeSqlite() (string, string, error) {
 	}
 
 	dataset := self.conn.Dataset()
-	var dsn string
+	pragmas := self.sqlitePragmas()
+
+	switch {
+	case dataset == `memory`:
+		// every connection to an unnamed in-memory database gets its own (empty) database, so
+		// only ever open one
+		self.maxOpenConns = 1
+		return driver, `:memory:`, nil
+
+	case strings.HasPrefix(dataset, `memory:`):
+		// named in-memory databases are shared by every connection that opens them by name
+		pragmas.Set(`mode`, `memory`)
+		pragmas.Set(`cache`, `shared`)
+
+		return driver, `file:` + strings.TrimPrefix(dataset, `memory:`) + `?` + pragmas.Encode(), nil
 
-	switch dataset {
-	case `memory`:
-		return `sqlite3`, `:memory:`, nil
 	default:
 		if strings.HasPrefix(dataset, `~`) {
 			if v, err := pathutil.ExpandUser(dataset); err == nil {
@@ -165,24 +227,36 @@ func (self *SqlBackend) initializeSqlite() (string, string, error) {
 			dataset = `/` + dataset
 		}
 
-		dsn = dataset
+		if pragmas.Get(`_journal_mode`) == `` && SqliteJournalMode != `` {
+			pragmas.Set(`_journal_mode`, SqliteJournalMode)
+		}
 
-		opts := make(map[string]interface{})
+		return driver, `file:` + dataset + `?` + pragmas.Encode(), nil
+	}
+}
 
-		if v := self.conn.OptString(`cache`, `shared`); v != `` {
-			opts[`cache`] = v
-		}
+// converts the sqlite options given in the connection string into the query string parameters
+// understood by the driver
+func (self *SqlBackend) sqlitePragmas() url.Values {
+	opts := self.driverOptions(nil)
+	pragmas := make(url.Values)
 
-		if v := self.conn.OptString(`mode`, `memory`); v != `` {
-			opts[`mode`] = v
-		}
+	for key := range opts {
+		value := opts.Get(key)
 
-		if len(opts) > 0 {
-			dsn = dsn + `?` + maputil.Join(opts, `=`, `&`)
+		switch key {
+		case `journal_mode`, `busy_timeout`, `synchronous`, `foreign_keys`, `txlock`, `cache_size`:
+			pragmas.Set(`_`+key, value)
+		default:
+			pragmas.Set(key, value)
 		}
+	}
 
-		return `sqlite3`, dsn, nil
+	if pragmas.Get(`_busy_timeout`) == `` && SqliteBusyTimeout > 0 {
+		pragmas.Set(`_busy_timeout`, fmt.Sprintf("%d", SqliteBusyTimeout/time.Millisecond))
 	}
+
+	return pragmas
 }
 
 func (self *SqlBackend) sqliteGetTableConstraints(constraintType string, collectionName string) ([]string, error) {
@@ -0,0 +1,39 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeSqlite(t *testing.T) {
+	assert := require.New(t)
+
+	for connString, dsn := range map[string]string{
+		`sqlite:///memory`:                                       `:memory:`,
+		`sqlite:///memory:shared`:                                `file:shared?_busy_timeout=5000&cache=shared&mode=memory`,
+		`sqlite:///var/lib/pivot/app.db`:                         `file:/var/lib/pivot/app.db?_busy_timeout=5000&_journal_mode=WAL`,
+		`sqlite:///var/lib/pivot/app.db?journal_mode=DELETE`:     `file:/var/lib/pivot/app.db?_busy_timeout=5000&_journal_mode=DELETE`,
+		`sqlite:///var/lib/pivot/app.db?busy_timeout=100`:        `file:/var/lib/pivot/app.db?_busy_timeout=100&_journal_mode=WAL`,
+		`sqlite:///var/lib/pivot/app.db?foreign_keys=1`:          `file:/var/lib/pivot/app.db?_busy_timeout=5000&_foreign_keys=1&_journal_mode=WAL`,
+		`sqlite:///var/lib/pivot/app.db?lazySchema=true`:         `file:/var/lib/pivot/app.db?_busy_timeout=5000&_journal_mode=WAL`,
+		`sqlite:///memory:shared?lazySchema=true&busy_timeout=0`: `file:shared?_busy_timeout=0&cache=shared&mode=memory`,
+	} {
+		cs, err := dal.ParseConnectionString(connString)
+		assert.NoError(err)
+
+		backend := NewSqlBackend(cs).(*SqlBackend)
+		name, actual, err := backend.initializeSqlite()
+		assert.NoError(err)
+		assert.Equal(`sqlite3`, name)
+		assert.Equal(dsn, actual, connString)
+
+		// every connection to an unnamed in-memory database would get a different database
+		if dsn == `:memory:` {
+			assert.Equal(1, backend.maxOpenConns)
+		} else {
+			assert.Zero(backend.maxOpenConns)
+		}
+	}
+}
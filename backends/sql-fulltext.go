@@ -0,0 +1,146 @@
+package backends
+
+import (
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// The most values a single statement may bind, for databases that accept fewer than
+// DefaultSqlMaxQueryParameters.  This can be overridden per connection with the
+// "maxQueryParameters" option.
+var SqlMaxQueryParameters = map[string]int{
+	`sqlite`:    999,
+	`mssql`:     2100,
+	`sqlserver`: 2100,
+	`odbc`:      999,
+	`ansi`:      999,
+}
+
+var DefaultSqlMaxQueryParameters = 65535
+
+// Full-text criteria (e.g.: "body/match:quick fox") in queries made against a SQL backend that has a
+// separate search index are performed by the search index, and the IDs of the records it finds
+// replace those criteria in the SQL query.  Without a search index, the criteria are performed by
+// the database as substring matches.
+//
+// Each ID is bound as a parameter, so searches that find more records than the database can accept
+// in one statement fail with an ErrLimitExceeded error rather than being sent to the database.
+//
+// Returns the filter to give to the database, and false if the search index found no records (in
+// which case the query has no results).
+func (self *SqlBackend) resolveFullText(collection *dal.Collection, f *filter.Filter) (*filter.Filter, bool, error) {
+	if f == nil || self.textIndexer == nil || !f.HasFullText() {
+		return f, true, nil
+	}
+
+	text, rest := f.SplitFullText()
+	text.Fields = []string{collection.IdentityField}
+	text.Sort = nil
+	text.Offset = 0
+
+	maxIds := self.maxQueryParameters()
+
+	// leave room for the values bound by the rest of the filter
+	if rest != nil {
+		for _, criterion := range rest.Criteria {
+			maxIds -= len(criterion.Values)
+		}
+	}
+
+	if maxIds < 1 {
+		maxIds = 1
+	}
+
+	// one more than we can use is enough to know there are too many
+	text.Limit = maxIds + 1
+
+	ids := make([]interface{}, 0)
+
+	if err := self.textIndexer.QueryFunc(collection, text, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		} else if record != nil {
+			ids = append(ids, record.ID)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, false, err
+	}
+
+	if len(ids) == 0 {
+		return nil, false, nil
+	} else if len(ids) > maxIds {
+		return nil, false, dal.LimitExceeded(
+			"full-text search matched more than %d records in %s; narrow the search or query the search index directly",
+			maxIds,
+			collection.Name,
+		)
+	}
+
+	resolved := filter.Copy(f)
+	resolved.MatchAll = false
+	resolved.Criteria = []filter.Criterion{
+		{
+			Type:   dal.AutoType,
+			Field:  collection.IdentityField,
+			Values: ids,
+		},
+	}
+
+	if rest != nil {
+		resolved.Criteria = append(resolved.Criteria, rest.Criteria...)
+	}
+
+	resolved.Spec = resolved.String()
+
+	return &resolved, true, nil
+}
+
+// the most values this backend's database accepts in a single statement
+func (self *SqlBackend) maxQueryParameters() int {
+	fallback := DefaultSqlMaxQueryParameters
+
+	if max, ok := SqlMaxQueryParameters[self.conn.Backend()]; ok {
+		fallback = max
+	}
+
+	return int(self.conn.OptInt(`maxQueryParameters`, int64(fallback)))
+}
+
+// resolves full-text criteria in the (optional) filter given to an aggregator
+func (self *SqlBackend) resolveFullTextAggregate(collection *dal.Collection, f []*filter.Filter) ([]*filter.Filter, bool, error) {
+	if len(f) == 0 {
+		return f, true, nil
+	}
+
+	if resolved, found, err := self.resolveFullText(collection, f[0]); err == nil {
+		return append([]*filter.Filter{resolved}, f[1:]...), found, nil
+	} else {
+		return nil, false, err
+	}
+}
+
+// whether a search should be performed by this backend (which hands the full-text criteria to the
+// search index) rather than by the search index alone
+func (self *SqlBackend) shouldResolveFullText(filters []*filter.Filter) bool {
+	if self.textIndexer == nil {
+		return false
+	}
+
+	for _, f := range filters {
+		if f != nil && f.HasFullText() {
+			if _, rest := f.SplitFullText(); rest != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// whether queries should be given to a QueryPlanner, which decides for each one whether to use the
+// search index, the database, or both
+func (self *SqlBackend) planQueries() bool {
+	return self.textIndexer != nil && self.conn.OptBool(`planQueries`, false)
+}
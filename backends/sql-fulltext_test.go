@@ -0,0 +1,102 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func newFullTextBackend(connString string, count int) (*SqlBackend, *dal.Collection, error) {
+	cs, err := dal.ParseConnectionString(connString)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := NewMockBackend()
+	collection := dal.NewCollection(`posts`).AddFields(dal.Field{
+		Name: `body`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `author`,
+		Type: dal.StringType,
+	})
+
+	if err := index.CreateCollection(collection); err != nil {
+		return nil, nil, err
+	}
+
+	records := dal.NewRecordSet()
+
+	for i := 1; i <= count; i++ {
+		records.Push(dal.NewRecord(i).Set(`body`, fmt.Sprintf("quick fox %d", i)))
+	}
+
+	return &SqlBackend{
+		conn:        &cs,
+		textIndexer: index,
+	}, collection, index.Insert(`posts`, records)
+}
+
+func TestResolveFullText(t *testing.T) {
+	assert := require.New(t)
+
+	backend, collection, err := newFullTextBackend(`sqlite:///tmp/test.db`, 3)
+	assert.NoError(err)
+
+	resolved, found, err := backend.resolveFullText(collection, filter.MustParse(`body/match:quick/author/alice`))
+	assert.NoError(err)
+	assert.True(found)
+	assert.Len(resolved.Criteria, 2)
+	assert.Equal(`id`, resolved.Criteria[0].Field)
+	assert.Len(resolved.Criteria[0].Values, 3)
+	assert.Equal(`author`, resolved.Criteria[1].Field)
+
+	_, found, err = backend.resolveFullText(collection, filter.MustParse(`body/match:slow`))
+	assert.NoError(err)
+	assert.False(found)
+}
+
+func TestResolveFullTextTooManyIds(t *testing.T) {
+	assert := require.New(t)
+
+	backend, collection, err := newFullTextBackend(`sqlite:///tmp/test.db?maxQueryParameters=3`, 5)
+	assert.NoError(err)
+	assert.Equal(3, backend.maxQueryParameters())
+
+	_, _, err = backend.resolveFullText(collection, filter.MustParse(`body/match:quick`))
+	assert.True(errors.Is(err, dal.ErrLimitExceeded))
+
+	// the search index is only asked for one more record than can be used
+	calls := backend.textIndexer.(*MockBackend).CallsTo(`QueryFunc`)
+	assert.Len(calls, 1)
+	assert.Equal(4, calls[0].Args[1].(*filter.Filter).Limit)
+
+	// values bound by the rest of the filter count against the limit
+	_, _, err = backend.resolveFullText(collection, filter.MustParse(`body/match:quick 1/author/alice|bob`))
+	assert.NoError(err)
+
+	_, _, err = backend.resolveFullText(collection, filter.MustParse(`body/match:fox/author/alice|bob`))
+	assert.True(errors.Is(err, dal.ErrLimitExceeded))
+}
+
+func TestSqlMaxQueryParameters(t *testing.T) {
+	assert := require.New(t)
+
+	for connString, max := range map[string]int{
+		`sqlite:///tmp/test.db`:                       999,
+		`mssql://localhost/test`:                      2100,
+		`postgres://localhost/test`:                   DefaultSqlMaxQueryParameters,
+		`mysql://localhost/test`:                      DefaultSqlMaxQueryParameters,
+		`odbc://localhost/test`:                       999,
+		`sqlite:///tmp/test.db?maxQueryParameters=50`: 50,
+	} {
+		cs, err := dal.ParseConnectionString(connString)
+		assert.NoError(err)
+		assert.Equal(max, (&SqlBackend{conn: &cs}).maxQueryParameters(), connString)
+	}
+}
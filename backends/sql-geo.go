@@ -0,0 +1,103 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter/generators"
+)
+
+// Returns the statements that create spatial (R-tree) indexes on the given geometry fields of a
+// table.  Geometry fields of SpatiaLite databases are also added to the table this way, since they
+// must be registered with SpatiaLite's metadata rather than declared with the table.
+//
+// PostGIS must already be installed in PostgreSQL databases (CREATE EXTENSION postgis), and MySQL
+// only indexes geometry fields that are required.
+func (self *SqlBackend) geometryStatements(gen *generators.Sql, collection *dal.Collection, fields []dal.Field) []string {
+	stmts := make([]string, 0)
+	table := gen.ToTableName(collection.Name)
+
+	for _, field := range fields {
+		if !field.Type.IsGeometry() {
+			continue
+		}
+
+		column := gen.ToFieldName(field.Name)
+		index := fmt.Sprintf(gen.FieldNameFormat, fmt.Sprintf("%s_%s_spatial", collection.Name, field.Name))
+
+		switch self.conn.Backend() {
+		case `sqlite`:
+			if !self.spatialite {
+				continue
+			}
+
+			if len(stmts) == 0 {
+				stmts = append(stmts, `SELECT InitSpatialMetaData('WGS84_ONLY') WHERE CheckSpatialMetaData() = 0`)
+			}
+
+			var notNull int
+
+			if field.Required {
+				notNull = 1
+			}
+
+			stmts = append(stmts, fmt.Sprintf(
+				"SELECT AddGeometryColumn(%s, %s, 4326, %s, 'XY', %d)",
+				sqlStringLiteral(collection.Name),
+				sqlStringLiteral(gen.NamingConvention.FieldName(field.Name)),
+				sqlStringLiteral(strings.ToUpper(field.Type.String())),
+				notNull,
+			))
+
+			stmts = append(stmts, fmt.Sprintf(
+				"SELECT CreateSpatialIndex(%s, %s)",
+				sqlStringLiteral(collection.Name),
+				sqlStringLiteral(gen.NamingConvention.FieldName(field.Name)),
+			))
+
+		case `mysql`:
+			if !field.Required {
+				querylog.Debugf("[%T] %s.%s: spatial indexes require NOT NULL columns, not indexing", self, collection.Name, field.Name)
+				continue
+			}
+
+			stmts = append(stmts, fmt.Sprintf("CREATE SPATIAL INDEX %s ON %s (%s)", index, table, column))
+
+		case `postgres`, `postgresql`, `psql`, `cockroach`, `cockroachdb`:
+			stmts = append(stmts, fmt.Sprintf("CREATE INDEX %s ON %s USING GIST (%s)", index, table, column))
+		}
+	}
+
+	return stmts
+}
+
+// Spatial databases return geometries in formats of their own, which are converted into the
+// Well-Known Binary that dal.ParsePoint and dal.ParsePolygon understand (PostGIS' hex-encoded EWKB,
+// and the WKT that geometries are stored as in databases without spatial support, are understood
+// as-is.)
+func sqlScanGeometry(v []byte) interface{} {
+	if len(v) == 0 {
+		return nil
+	}
+
+	// SpatiaLite: 0x00, byte order, SRID (4 bytes), bounding box (32 bytes), 0x7C, the geometry as WKB
+	// without its byte order, 0xFE
+	if len(v) > 43 && v[0] == 0x00 && v[38] == 0x7C && v[len(v)-1] == 0xFE {
+		return append([]byte{v[1]}, v[39:len(v)-1]...)
+	}
+
+	// MySQL: SRID (4 bytes), WKB
+	if _, err := dal.ParseWKB(v); err != nil && len(v) > 4 {
+		if _, err := dal.ParseWKB(v[4:]); err == nil {
+			return v[4:]
+		}
+	}
+
+	return v
+}
+
+// quotes a string for use as a literal in SQL statements
+func sqlStringLiteral(in string) string {
+	return `'` + strings.Replace(in, `'`, `''`, -1) + `'`
+}
@@ -3,8 +3,8 @@ package backends
 // this file satifies the Indexer interface for SqlBackend
 
 import (
+	"database/sql"
 	"math"
-	"reflect"
 
 	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/pivot/dal"
@@ -13,7 +13,15 @@ import (
 )
 
 func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
-	defer stats.NewTiming().Send(`pivot.backends.sql.query_time`)
+	defer timeOperation(`pivot.backends.sql.query_time`)()
+
+	if resolved, found, err := self.resolveFullText(collection, f); err != nil {
+		return err
+	} else if !found {
+		return nil
+	} else {
+		f = resolved
+	}
 
 	f.IdentityField = collection.IdentityField
 	page := 1
@@ -45,8 +53,7 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 
 				if err := prequeryGen.Initialize(collection.Name); err == nil {
 					// render the count query
-					if stmt, err := filter.Render(prequeryGen, collection.Name, f); err == nil {
-						values := prequeryGen.GetValues()
+					if stmt, values, err := self.statementCache.Render(prequeryGen, collection.Name, f); err == nil {
 						querylog.Debugf("[%T] %s %v", self, string(stmt[:]), values)
 
 						// perform the count query
@@ -65,7 +72,7 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 
 							rows.Close()
 						} else {
-							return err
+							return self.checkSchemaError(collection.Name, err)
 						}
 					} else {
 						return err
@@ -78,8 +85,7 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 				totalPages = int(math.Ceil(float64(totalResults) / float64(f.Limit)))
 			}
 
-			if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
-				values := queryGen.GetValues()
+			if stmt, values, err := self.statementCache.Render(queryGen, collection.Name, f); err == nil {
 				querylog.Debugf("[%T] %s %v", self, string(stmt[:]), values)
 
 				// perform query
@@ -88,11 +94,13 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 
 					if columns, err := rows.Columns(); err == nil {
 						processedThisQuery := 0
+						plan := newSqlScanPlan(queryGen, collection, columns, f.Fields)
+						defer plan.Release()
 
 						for rows.Next() {
 							// log.Debugf("  row: %d", processed)
 
-							if record, err := self.scanFnValueToRecord(queryGen, collection, columns, reflect.ValueOf(rows.Scan), f.Fields); err == nil {
+							if record, err := plan.Scan(rows); err == nil {
 								processed += 1
 								processedThisQuery += 1
 
@@ -111,11 +119,11 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 									Offset:       offset,
 									TotalResults: totalResults,
 								}); err != nil {
-									return err
+									return queryStopped(err)
 								}
 							} else {
 								if err := resultFn(dal.NewRecord(nil).Set(`error`, err.Error()), err, IndexPage{}); err != nil {
-									return err
+									return queryStopped(err)
 								}
 
 								// if the resultFn didn't stop us, move on to the next row
@@ -139,7 +147,7 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 						return err
 					}
 				} else {
-					return err
+					return self.checkSchemaError(collection.Name, err)
 				}
 
 			} else {
@@ -159,11 +167,62 @@ func (self *SqlBackend) Query(collection *dal.Collection, f *filter.Filter, resu
 
 		// use the record that comes back from the QueryFunc as-is
 		f.Options[`ForceIndexRecord`] = true
+
+		if f.Parallelism > 1 {
+			if partitions, err := self.Partitions(collection, f); err == nil {
+				if len(partitions) > 1 {
+					return ParallelQuery(self, collection, f, partitions, resultFns...)
+				}
+			} else {
+				return nil, err
+			}
+		}
 	}
 
 	return DefaultQueryImplementation(self, collection, f, resultFns...)
 }
 
+// Splits the given filter into (at most) f.Parallelism filters that each cover an equal range of
+// identity values, as determined by the smallest and largest identities that match the filter.  Only
+// collections with integer identities can be split this way; others are returned as a single
+// partition.
+func (self *SqlBackend) Partitions(collection *dal.Collection, f *filter.Filter) ([]*filter.Filter, error) {
+	if f.Parallelism < 2 {
+		return []*filter.Filter{f}, nil
+	}
+
+	identityType := collection.IdentityFieldType
+
+	if identityType == `` {
+		identityType = dal.DefaultIdentityFieldType
+	}
+
+	if identityType != dal.IntType {
+		return []*filter.Filter{f}, nil
+	}
+
+	identityField := collection.IdentityField
+
+	if identityField == `` {
+		identityField = dal.DefaultIdentityField
+	}
+
+	bounds := filter.Copy(f)
+	bounds.Offset = 0
+	bounds.Limit = 0
+	bounds.Sort = nil
+
+	if min, err := self.Minimum(collection, identityField, &bounds); err == nil {
+		if max, err := self.Maximum(collection, identityField, &bounds); err == nil {
+			return f.SplitRange(identityField, int64(min), int64(max), f.Parallelism), nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
 func (self *SqlBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
 	for i, f := range fields {
 		if f == `id` {
@@ -190,8 +249,11 @@ func (self *SqlBackend) ListValues(collection *dal.Collection, fields []string,
 			}
 
 			if field == collection.IdentityField {
-				for _, result := range results.Records {
+				if err := results.Each(func(result *dal.Record) error {
 					values = append(values, result.ID)
+					return nil
+				}); err != nil {
+					return nil, err
 				}
 			} else {
 				values = sliceutil.Compact(results.Pluck(field))
@@ -206,6 +268,78 @@ func (self *SqlBackend) ListValues(collection *dal.Collection, fields []string,
 	return output, nil
 }
 
+// Counts the distinct values of each of the given fields using a grouped COUNT query.
+func (self *SqlBackend) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]FacetBucket, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	output := make(map[string][]FacetBucket)
+
+	if resolved, found, err := self.resolveFullText(collection, f); err != nil {
+		return nil, err
+	} else if !found {
+		for _, field := range fields {
+			output[field] = make([]FacetBucket, 0)
+		}
+
+		return output, nil
+	} else {
+		f = resolved
+	}
+
+	for _, field := range fields {
+		if field == `id` {
+			field = collection.IdentityField
+		}
+
+		queryGen := self.makeQueryGen(collection)
+		queryGen.Count = true
+		queryGen.GroupByField(field)
+
+		if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
+			querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+			if rows, err := self.db.Query(string(stmt[:]), queryGen.GetValues()...); err == nil {
+				defer rows.Close()
+				buckets := make([]FacetBucket, 0)
+
+				for rows.Next() {
+					var value interface{}
+					var count int64
+
+					if err := rows.Scan(&value, &count); err != nil {
+						return nil, err
+					}
+
+					if value == nil {
+						continue
+					} else if v, ok := value.([]byte); ok {
+						value = string(v)
+					}
+
+					buckets = append(buckets, FacetBucket{
+						Value: collection.ConvertValue(field, value),
+						Count: count,
+					})
+				}
+
+				if err := rows.Err(); err != nil {
+					return nil, err
+				}
+
+				output[field] = sortFacetBuckets(buckets)
+			} else {
+				return nil, self.checkSchemaError(collection.Name, err)
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return output, nil
+}
+
 func (self *SqlBackend) IndexConnectionString() *dal.ConnectionString {
 	return self.GetConnectionString()
 }
@@ -236,9 +370,18 @@ func (self *SqlBackend) IndexRemove(collection *dal.Collection, ids []interface{
 	return nil
 }
 
-// DeleteQuery removes records using a filter
+// DeleteQuery removes records using a filter in a single DELETE statement, rather than retrieving
+// the IDs of the matching records and deleting them individually.
 func (self *SqlBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
-	if tx, err := self.db.Begin(); err == nil {
+	if resolved, found, err := self.resolveFullText(collection, f); err != nil {
+		return err
+	} else if !found {
+		return nil
+	} else {
+		f = resolved
+	}
+
+	return self.withTransaction(func(tx *sql.Tx) error {
 		queryGen := self.makeQueryGen(collection)
 		queryGen.Type = generators.SqlDeleteStatement
 
@@ -247,23 +390,12 @@ func (self *SqlBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter
 			querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
 
 			// execute SQL
-			if _, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...); err == nil {
-				if err := tx.Commit(); err == nil {
-					return nil
-				} else {
-					return err
-				}
-			} else {
-				defer tx.Rollback()
-				return err
-			}
+			_, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...)
+			return err
 		} else {
-			defer tx.Rollback()
 			return err
 		}
-	} else {
-		return err
-	}
+	})
 }
 
 func (self *SqlBackend) FlushIndex() error {
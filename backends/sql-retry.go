@@ -0,0 +1,97 @@
+package backends
+
+import (
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// How many times a transaction that failed because of a deadlock, lock wait timeout or
+// serialization failure is retried before giving up.  Overridden by the "deadlockRetries" option.
+var DefaultDeadlockRetries = 3
+
+// The delay before the first retry of a deadlocked transaction, which doubles for each subsequent
+// attempt.  Overridden by the "deadlockRetryDelay" option (e.g.: "?deadlockRetryDelay=100ms").
+var DefaultDeadlockRetryDelay = 50 * time.Millisecond
+
+// MySQL errors indicating that a transaction was rolled back (or should be) because of contention
+// with another transaction, and can be retried as a whole.
+var mysqlRetryableErrors = []uint16{
+	1205, // ER_LOCK_WAIT_TIMEOUT
+	1213, // ER_LOCK_DEADLOCK
+}
+
+// PostgreSQL (and CockroachDB) errors indicating that a transaction was aborted because of contention
+// with another transaction, and can be retried as a whole.
+var postgresRetryableErrors = []pq.ErrorCode{
+	`40001`, // serialization_failure (CockroachDB's transaction restart errors)
+	`40P01`, // deadlock_detected
+}
+
+// Runs fn inside a new transaction, committing it if fn succeeds and rolling it back otherwise.
+// Transactions that fail because of a deadlock, lock wait timeout or serialization failure are
+// retried from the start with a jittered, exponentially increasing delay.
+func (self *SqlBackend) withTransaction(fn func(tx *sql.Tx) error) error {
+	retries := int(self.conn.OptInt(`deadlockRetries`, int64(DefaultDeadlockRetries)))
+	delay := DefaultDeadlockRetryDelay
+
+	if v, err := time.ParseDuration(self.conn.OptString(`deadlockRetryDelay`, ``)); err == nil {
+		delay = v
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := self.runTransaction(fn)
+
+		if err == nil || attempt >= retries || !isRetryableSqlError(err) {
+			return err
+		}
+
+		// wait somewhere between half and all of the current delay so that the transactions that
+		// collided don't just collide again
+		wait := delay << uint(attempt)
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+
+		querylog.Debugf("[%T] transaction failed (attempt %d of %d), retrying in %v: %v", self, attempt+1, retries+1, wait, err)
+		time.Sleep(wait)
+	}
+}
+
+func (self *SqlBackend) runTransaction(fn func(tx *sql.Tx) error) error {
+	if tx, err := self.db.Begin(); err == nil {
+		if err := fn(tx); err != nil {
+			defer tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	} else {
+		return err
+	}
+}
+
+// Returns whether the given error indicates a transaction lost out to a concurrent one, and
+// should be retried.
+func isRetryableSqlError(err error) bool {
+	var myerr *mysql.MySQLError
+	var pqerr *pq.Error
+
+	if errors.As(err, &myerr) {
+		for _, number := range mysqlRetryableErrors {
+			if myerr.Number == number {
+				return true
+			}
+		}
+	} else if errors.As(err, &pqerr) {
+		for _, code := range postgresRetryableErrors {
+			if pqerr.Code == code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,65 @@
+package backends
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlDeadlockRetryMysql(t *testing.T) {
+	assert := require.New(t)
+
+	backend, db, err := newSqliteSchemaTestBackend(`deadlockRetries=2&deadlockRetryDelay=1ms`)
+	assert.NoError(err)
+	defer db.Close()
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: `Deadlock found when trying to get lock`}
+	attempts := 0
+
+	// a deadlocked transaction is rolled back and run again from the start, so only the final
+	// attempt's changes are kept
+	assert.NoError(backend.withTransaction(func(tx *sql.Tx) error {
+		attempts += 1
+
+		if _, err := tx.Exec(`INSERT INTO users (name) VALUES ('first')`); err != nil {
+			return err
+		}
+
+		if attempts < 3 {
+			return fmt.Errorf("insert failed: %w", deadlock)
+		}
+
+		return nil
+	}))
+
+	assert.Equal(3, attempts)
+
+	var count int
+	assert.NoError(db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count))
+	assert.Equal(1, count)
+
+	// ...until the retries are used up
+	attempts = 0
+
+	err = backend.withTransaction(func(tx *sql.Tx) error {
+		attempts += 1
+		return deadlock
+	})
+
+	assert.Equal(deadlock, err)
+	assert.Equal(3, attempts)
+
+	// other errors are returned immediately
+	attempts = 0
+
+	err = backend.withTransaction(func(tx *sql.Tx) error {
+		attempts += 1
+		return &mysql.MySQLError{Number: 1062, Message: `Duplicate entry`}
+	})
+
+	assert.Error(err)
+	assert.Equal(1, attempts)
+}
@@ -0,0 +1,250 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter/generators"
+)
+
+// The formats that times stored as text are parsed with, in the order they are tried.
+var SqlTimeFormats = []string{
+	time.RFC3339Nano,
+	`2006-01-02 15:04:05.999999999-07:00`,
+	`2006-01-02T15:04:05.999999999-07:00`,
+	`2006-01-02 15:04:05.999999999`,
+	`2006-01-02T15:04:05.999999999`,
+	`2006-01-02 15:04:05`,
+	`2006-01-02T15:04:05`,
+	`2006-01-02 15:04`,
+	`2006-01-02T15:04`,
+	`2006-01-02`,
+}
+
+// Implemented by *sql.Row and *sql.Rows.
+type sqlRowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// How to convert the value of one column of a result set into a record field.
+type sqlScanColumn struct {
+	field      dal.Field
+	known      bool
+	identity   bool
+	wanted     bool
+	nestedPath []string
+	fromBytes  func([]byte) interface{}
+}
+
+// A plan for converting the rows of a result set into records.  Everything that depends only on the
+// collection and the result set's columns (field lookups, nested paths, field selection, and how to
+// decode each column) is worked out once when the plan is built, leaving only the scan and value
+// conversion to be done for each row.
+type sqlScanPlan struct {
+	collection *dal.Collection
+	columns    []sqlScanColumn
+	buffers    *sqlScanBuffers
+}
+
+// The destination slices passed to Scan, which are reused for every row scanned by a plan and
+// returned to a pool when the plan is released.
+type sqlScanBuffers struct {
+	values []interface{}
+	dest   []interface{}
+}
+
+var sqlScanBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(sqlScanBuffers)
+	},
+}
+
+func newSqlScanPlan(queryGen *generators.Sql, collection *dal.Collection, columns []string, wantedFields []string) *sqlScanPlan {
+	plan := &sqlScanPlan{
+		collection: collection,
+		columns:    make([]sqlScanColumn, len(columns)),
+		buffers:    sqlScanBufferPool.Get().(*sqlScanBuffers),
+	}
+
+	if cap(plan.buffers.values) < len(columns) {
+		plan.buffers.values = make([]interface{}, len(columns))
+		plan.buffers.dest = make([]interface{}, len(columns))
+	}
+
+	plan.buffers.values = plan.buffers.values[:len(columns)]
+	plan.buffers.dest = plan.buffers.dest[:len(columns)]
+
+	for i := range plan.buffers.values {
+		plan.buffers.dest[i] = &plan.buffers.values[i]
+	}
+
+	for i, column := range columns {
+		nestedPath := strings.Split(column, queryGen.NestedFieldSeparator)
+		baseColumn := nestedPath[0]
+		field, ok := collection.GetField(baseColumn)
+
+		sc := sqlScanColumn{
+			field:      field,
+			known:      ok,
+			identity:   (column == collection.IdentityField),
+			wanted:     (len(wantedFields) == 0),
+			nestedPath: nestedPath,
+		}
+
+		for _, wantedField := range wantedFields {
+			if strings.Split(wantedField, queryGen.NestedFieldSeparator)[0] == baseColumn {
+				sc.wanted = true
+				break
+			}
+		}
+
+		// raw byte arrays will either be strings, blobs, or binary-encoded objects; which one is
+		// determined by the field type
+		switch field.Type {
+		case dal.ObjectType:
+			sc.fromBytes = sqlScanObject
+		case dal.RawType:
+			sc.fromBytes = sqlScanRaw
+		case dal.TimeType:
+			sc.fromBytes = sqlScanTime
+		case dal.PointType, dal.PolygonType:
+			sc.fromBytes = sqlScanGeometry
+		default:
+			sc.fromBytes = sqlScanString
+		}
+
+		plan.columns[i] = sc
+	}
+
+	return plan
+}
+
+// Scans the current row of the given result set into a new record.
+func (self *sqlScanPlan) Scan(scanner sqlRowScanner) (*dal.Record, error) {
+	values := self.buffers.values
+
+	if err := scanner.Scan(self.buffers.dest...); err != nil {
+		return nil, err
+	}
+
+	var id interface{}
+	fields := make(map[string]interface{})
+
+	for i, column := range self.columns {
+		if !column.known || (!column.identity && !column.wanted) {
+			continue
+		}
+
+		value := values[i]
+
+		// NULL columns are kept as explicit nils so they can be told apart from zero values
+		if value == nil {
+			if !column.identity {
+				if newFields, ok := maputil.DeepSet(fields, column.nestedPath, nil).(map[string]interface{}); ok {
+					fields = newFields
+				}
+			}
+
+			continue
+		}
+
+		if v, ok := value.([]byte); ok {
+			value = column.fromBytes(v)
+		} else if v, ok := value.(string); ok && column.field.Type == dal.ObjectType {
+			// some drivers return the contents of JSON columns as text
+			value = column.fromBytes([]byte(v))
+		}
+
+		if v, err := column.field.ConvertValue(value); err == nil {
+			if column.identity {
+				id = v
+			} else if newFields, ok := maputil.DeepSet(fields, column.nestedPath, v).(map[string]interface{}); ok {
+				fields = newFields
+			}
+		}
+	}
+
+	record := dal.NewRecord(id).SetFields(fields)
+
+	// do this AFTER populating the record's fields from the database
+	if err := record.Populate(record, self.collection); err != nil {
+		return nil, fmt.Errorf("error populating record: %v", err)
+	}
+
+	return record, nil
+}
+
+// Returns the plan's scan buffers to the pool.  The plan must not be used afterwards.
+func (self *sqlScanPlan) Release() {
+	if self.buffers != nil {
+		for i := range self.buffers.values {
+			self.buffers.values[i] = nil
+		}
+
+		sqlScanBufferPool.Put(self.buffers)
+		self.buffers = nil
+	}
+}
+
+func sqlScanObject(v []byte) interface{} {
+	var dest map[string]interface{}
+
+	if err := generators.SqlObjectTypeDecode(v, &dest); err == nil {
+		return dest
+	}
+
+	return string(v)
+}
+
+// blindly attempt to load the data as if it were an object, then fallback to using the raw byte array
+func sqlScanRaw(v []byte) interface{} {
+	var dest map[string]interface{}
+
+	if err := generators.SqlObjectTypeDecode(v, &dest); err == nil {
+		return dest
+	}
+
+	return v
+}
+
+// parses times returned as text (e.g.: by drivers that don't natively return time.Time values) using
+// the formats the supported databases write them in, falling back to the value as a string
+func sqlScanTime(v []byte) interface{} {
+	if len(v) == 0 {
+		return nil
+	}
+
+	for _, format := range SqlTimeFormats {
+		if t, err := time.Parse(format, string(v)); err == nil {
+			return t
+		}
+	}
+
+	return sqlScanString(v)
+}
+
+// strips non-printable characters from strings, treating empty strings as null
+func sqlScanString(v []byte) interface{} {
+	if len(v) == 0 {
+		return nil
+	}
+
+	normalized := strings.Map(func(r rune) rune {
+		if unicode.IsGraphic(r) {
+			return r
+		}
+
+		return -1
+	}, string(v))
+
+	if normalized != `` {
+		return normalized
+	}
+
+	return nil
+}
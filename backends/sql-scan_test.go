@@ -0,0 +1,441 @@
+package backends
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter/generators"
+	"github.com/stretchr/testify/require"
+)
+
+// a result set whose rows are given as the values a driver would return for each column
+type fakeSqlRows struct {
+	rows [][]interface{}
+	next int
+}
+
+func (self *fakeSqlRows) Scan(dest ...interface{}) error {
+	if self.next >= len(self.rows) {
+		return sql.ErrNoRows
+	}
+
+	row := self.rows[self.next]
+	self.next++
+
+	if len(dest) != len(row) {
+		return fmt.Errorf("expected %d destinations, got %d", len(row), len(dest))
+	}
+
+	for i, value := range row {
+		*(dest[i].(*interface{})) = value
+	}
+
+	return nil
+}
+
+// returns a point as little-endian Well-Known Binary
+func testPointWKB(point dal.Point) []byte {
+	wkb := make([]byte, 21)
+	wkb[0] = 1
+	binary.LittleEndian.PutUint32(wkb[1:], 1)
+	binary.LittleEndian.PutUint64(wkb[5:], math.Float64bits(point.Longitude))
+	binary.LittleEndian.PutUint64(wkb[13:], math.Float64bits(point.Latitude))
+
+	return wkb
+}
+
+func newSqlScanTestCollection() *dal.Collection {
+	return dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `address`,
+		Type: dal.ObjectType,
+	}, dal.Field{
+		Name: `meta`,
+		Type: dal.ObjectType,
+	}, dal.Field{
+		Name: `data`,
+		Type: dal.RawType,
+	}, dal.Field{
+		Name: `created_at`,
+		Type: dal.TimeType,
+	}, dal.Field{
+		Name: `location`,
+		Type: dal.PointType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	})
+}
+
+func TestSqlScanPlan(t *testing.T) {
+	assert := require.New(t)
+
+	collection := newSqlScanTestCollection()
+	columns := []string{`id`, `name`, `address.city`, `meta`, `data`, `created_at`, `location`, `age`, `extra`}
+	statue := dal.Point{Latitude: 40.6892, Longitude: -74.0445}
+
+	// MySQL prefixes geometries with their SRID
+	srid := make([]byte, 4)
+	binary.LittleEndian.PutUint32(srid, 4326)
+
+	rows := &fakeSqlRows{
+		rows: [][]interface{}{
+			{
+				int64(1),
+				[]byte("alice\x00"),
+				[]byte(`Paris`),
+				[]byte(`{"admin": true}`),
+				[]byte("\x00\x01binary"),
+				[]byte(`2020-01-02 03:04:05`),
+				append(srid, testPointWKB(statue)...),
+				int64(31),
+				`not in the collection`,
+			},
+			{
+				int64(2),
+				nil,
+				nil,
+				`{"admin": false}`,
+				nil,
+				time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+				testPointWKB(statue),
+				nil,
+				nil,
+			},
+		},
+	}
+
+	plan := newSqlScanPlan(generators.NewSqlGenerator(), collection, columns, nil)
+	defer plan.Release()
+
+	record, err := plan.Scan(rows)
+	assert.NoError(err)
+	assert.EqualValues(1, record.ID)
+
+	// non-printable characters are stripped from strings
+	assert.Equal(`alice`, record.Get(`name`))
+
+	// nested columns are set in the objects containing them
+	assert.Equal(map[string]interface{}{
+		`city`: `Paris`,
+	}, record.Get(`address`))
+
+	assert.Equal(map[string]interface{}{
+		`admin`: true,
+	}, record.Get(`meta`))
+
+	assert.Equal([]byte("\x00\x01binary"), record.Get(`data`))
+	assert.True(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(record.Get(`created_at`).(time.Time)))
+	assert.Equal(statue, record.Get(`location`))
+	assert.EqualValues(31, record.Get(`age`))
+
+	// unknown columns are ignored
+	assert.NotContains(record.Fields, `extra`)
+
+	// the buffers are reused for the next row, which must not disturb the first record
+	record2, err := plan.Scan(rows)
+	assert.NoError(err)
+	assert.EqualValues(2, record2.ID)
+	assert.Equal(`alice`, record.Get(`name`))
+
+	// NULLs are kept as explicit nils, distinct from missing fields
+	assert.Contains(record2.Fields, `name`)
+	assert.Nil(record2.Get(`name`))
+	assert.Contains(record2.Fields, `age`)
+	assert.Nil(record2.Get(`age`))
+	assert.Nil(record2.Get(`data`))
+
+	// some drivers return JSON columns as text, and times natively
+	assert.Equal(map[string]interface{}{
+		`admin`: false,
+	}, record2.Get(`meta`))
+
+	assert.True(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC).Equal(record2.Get(`created_at`).(time.Time)))
+	assert.Equal(statue, record2.Get(`location`))
+
+	// errors from the driver are returned
+	_, err = plan.Scan(rows)
+	assert.Equal(sql.ErrNoRows, err)
+}
+
+func TestSqlScanPlanWantedFields(t *testing.T) {
+	assert := require.New(t)
+
+	collection := newSqlScanTestCollection()
+	columns := []string{`id`, `name`, `address.city`, `age`}
+
+	plan := newSqlScanPlan(generators.NewSqlGenerator(), collection, columns, []string{`address.city`})
+	defer plan.Release()
+
+	record, err := plan.Scan(&fakeSqlRows{
+		rows: [][]interface{}{
+			{int64(7), []byte(`alice`), []byte(`Paris`), int64(31)},
+		},
+	})
+
+	assert.NoError(err)
+
+	// the identity is always kept, along with the fields that were asked for
+	assert.EqualValues(7, record.ID)
+	assert.Equal(map[string]interface{}{
+		`address`: map[string]interface{}{
+			`city`: `Paris`,
+		},
+	}, record.Fields)
+}
+
+func TestSqlScanTime(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Nil(sqlScanTime(nil))
+	assert.Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), sqlScanTime([]byte(`2020-01-02`)))
+	assert.Equal(time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC), sqlScanTime([]byte(`2020-01-02T03:04`)))
+	assert.Equal(`yesterday`, sqlScanTime([]byte(`yesterday`)))
+}
+
+// creates an in-memory SQLite table of the given number of rows for the scan benchmarks
+func newSqlScanBenchmarkRows(b *testing.B, count int) (*sql.DB, *dal.Collection) {
+	db, err := sql.Open(`sqlite3`, `:memory:`)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, meta TEXT, created_at TEXT, age INTEGER)`); err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 1; i <= count; i++ {
+		if _, err := db.Exec(
+			`INSERT INTO users (id, name, meta, created_at, age) VALUES (?, ?, ?, ?, ?)`,
+			i,
+			fmt.Sprintf("user%d", i),
+			`{"admin": false, "tags": ["a", "b"]}`,
+			`2020-01-02 03:04:05`,
+			i%90,
+		); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	collection := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `meta`,
+		Type: dal.ObjectType,
+	}, dal.Field{
+		Name: `created_at`,
+		Type: dal.TimeType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	})
+
+	return db, collection
+}
+
+func benchmarkSqlScan(b *testing.B, scan func(*generators.Sql, *dal.Collection, []string, *sql.Rows) error) {
+	db, collection := newSqlScanBenchmarkRows(b, 1000)
+	defer db.Close()
+
+	queryGen := generators.NewSqlGenerator()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if rows, err := db.Query(`SELECT id, name, meta, created_at, age FROM users`); err == nil {
+			if columns, err := rows.Columns(); err == nil {
+				if err := scan(queryGen, collection, columns, rows); err != nil {
+					b.Fatal(err)
+				}
+			} else {
+				b.Fatal(err)
+			}
+
+			rows.Close()
+		} else {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSqlScanPlan(b *testing.B) {
+	benchmarkSqlScan(b, func(queryGen *generators.Sql, collection *dal.Collection, columns []string, rows *sql.Rows) error {
+		plan := newSqlScanPlan(queryGen, collection, columns, nil)
+		defer plan.Release()
+
+		for rows.Next() {
+			if _, err := plan.Scan(rows); err != nil {
+				return err
+			}
+		}
+
+		return rows.Err()
+	})
+}
+
+func BenchmarkSqlScanReflect(b *testing.B) {
+	benchmarkSqlScan(b, func(queryGen *generators.Sql, collection *dal.Collection, columns []string, rows *sql.Rows) error {
+		for rows.Next() {
+			if _, err := legacySqlScanRow(queryGen, collection, columns, reflect.ValueOf(rows.Scan), nil); err != nil {
+				return err
+			}
+		}
+
+		return rows.Err()
+	})
+}
+
+// The row scanning implementation that sqlScanPlan replaced, kept here as the baseline for
+// BenchmarkSqlScanReflect.
+func legacySqlScanRow(queryGen *generators.Sql, collection *dal.Collection, columns []string, scanFn reflect.Value, wantedFields []string) (*dal.Record, error) {
+	output := make([]interface{}, len(columns))
+
+	for i, column := range columns {
+		baseColumn := strings.Split(column, queryGen.NestedFieldSeparator)[0]
+
+		if field, ok := collection.GetField(baseColumn); ok {
+			if field.DefaultValue != nil {
+				output[i] = field.GetDefaultValue()
+			} else if field.Required {
+				output[i] = field.GetTypeInstance()
+			} else {
+				switch field.Type {
+				case dal.StringType, dal.TimeType, dal.ObjectType:
+					output[i] = sql.NullString{}
+				case dal.BooleanType:
+					output[i] = sql.NullBool{}
+				case dal.IntType:
+					output[i] = sql.NullInt64{}
+				case dal.FloatType:
+					output[i] = sql.NullFloat64{}
+				default:
+					output[i] = make([]byte, 0)
+				}
+			}
+		}
+	}
+
+	rRowArgs := make([]reflect.Value, len(output))
+
+	for i := range output {
+		rRowArgs[i] = reflect.ValueOf(output).Index(i).Addr()
+	}
+
+	if result := scanFn.Call(rRowArgs); len(result) == 1 {
+		if err, ok := result[0].Interface().(error); ok && err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("invalid response from row scan call")
+	}
+
+	var id interface{}
+	fields := make(map[string]interface{})
+
+ColumnLoop:
+	for i, column := range columns {
+		nestedPath := strings.Split(column, queryGen.NestedFieldSeparator)
+		baseColumn := nestedPath[0]
+
+		if field, ok := collection.GetField(baseColumn); ok {
+			var value interface{}
+
+			switch v := output[i].(type) {
+			case []uint8:
+				var dest map[string]interface{}
+
+				switch field.Type {
+				case dal.ObjectType:
+					if err := generators.SqlObjectTypeDecode(v, &dest); err == nil {
+						value = dest
+					} else {
+						value = string(v)
+					}
+				case dal.RawType:
+					if err := generators.SqlObjectTypeDecode(v, &dest); err == nil {
+						value = dest
+					} else {
+						value = v
+					}
+				default:
+					var normalized []rune
+
+					for _, r := range string(v) {
+						if unicode.IsGraphic(r) {
+							normalized = append(normalized, r)
+						}
+					}
+
+					if len(normalized) > 0 {
+						value = string(normalized)
+					}
+				}
+			case sql.NullString:
+				if v.Valid {
+					value = v.String
+				}
+			case sql.NullBool:
+				if v.Valid {
+					value = v.Bool
+				}
+			case sql.NullInt64:
+				if v.Valid {
+					value = v.Int64
+				}
+			case sql.NullFloat64:
+				if v.Valid {
+					value = v.Float64
+				}
+			default:
+				value = output[i]
+			}
+
+			if v, err := field.ConvertValue(value); err == nil {
+				if column == collection.IdentityField {
+					id = v
+				} else {
+					if len(wantedFields) > 0 {
+						shouldSkip := true
+
+						for _, wantedField := range wantedFields {
+							if strings.Split(wantedField, queryGen.NestedFieldSeparator)[0] == baseColumn {
+								shouldSkip = false
+								break
+							}
+						}
+
+						if shouldSkip {
+							continue ColumnLoop
+						}
+					}
+
+					if newFields, ok := maputil.DeepSet(fields, nestedPath, v).(map[string]interface{}); ok {
+						fields = newFields
+					}
+				}
+			}
+		}
+	}
+
+	record := dal.NewRecord(id).SetFields(fields)
+
+	if err := record.Populate(record, collection); err != nil {
+		return nil, fmt.Errorf("error populating record: %v", err)
+	}
+
+	return record, nil
+}
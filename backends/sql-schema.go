@@ -0,0 +1,230 @@
+package backends
+
+// this file manages the SqlBackend's cache of collection schemas
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/dal"
+)
+
+// How long a collection schema read from the database is trusted before it is introspected again.
+// A zero value caches schemas until they are explicitly invalidated.  This can be overridden per
+// connection with the "schemaCacheTTL" option (e.g.: "?schemaCacheTTL=5m").
+var DefaultSchemaCacheTTL time.Duration = 0
+
+// Whether a query failing because it references a column the database doesn't know about should
+// cause the collection's schema to be reloaded.  Overridden by the "refreshOnSchemaError" option.
+var DefaultRefreshOnSchemaError = false
+
+// Whether to defer reading a table's schema from the database until the first time the collection is
+// used, rather than introspecting every table when the backend is initialized.  Overridden by the
+// "lazySchema" option.
+var DefaultLazySchema = false
+
+// Whether collections registered with the backend should be used as-is, without reading their schema
+// from the database.  Overridden by the "trustRegistered" option.
+var DefaultTrustRegistered = false
+
+// When trusting registered collections, whether to verify that each table has the columns its
+// definition describes the first time it is used.  Overridden by the "verifyRegistered" option.
+var DefaultVerifyRegistered = false
+
+// Errors from the various supported databases indicating that a statement references a column that
+// does not exist (i.e.: the cached schema no longer reflects the table.)
+var sqlUnknownColumnErrors = []string{
+	`unknown column`,
+	`no such column`,
+	`has no column named`,
+	`column "`,
+}
+
+// Implemented by backends that cache collection schemas read from the underlying database.
+type SchemaCacheInvalidator interface {
+	InvalidateSchemaCache(collection string)
+}
+
+// Marks the cached schema for the named collection as stale, causing it to be read from the database
+// the next time it is used.  If no name is given, all cached schemas are invalidated.
+func (self *SqlBackend) InvalidateSchemaCache(collection string) {
+	if collection == `` {
+		self.schemaCachedAt.Range(func(key, _ interface{}) bool {
+			self.schemaCachedAt.Delete(key)
+			return true
+		})
+	} else {
+		self.schemaCachedAt.Delete(collection)
+	}
+
+	querylog.Debugf("[%T] invalidated schema cache for %q", self, collection)
+}
+
+func (self *SqlBackend) lazySchema() bool {
+	return self.conn.OptBool(`lazySchema`, DefaultLazySchema)
+}
+
+func (self *SqlBackend) trustRegistered() bool {
+	return self.conn.OptBool(`trustRegistered`, DefaultTrustRegistered)
+}
+
+func (self *SqlBackend) schemaCacheTTL() time.Duration {
+	if v, err := time.ParseDuration(self.conn.OptString(`schemaCacheTTL`, ``)); err == nil {
+		return v
+	}
+
+	return DefaultSchemaCacheTTL
+}
+
+// Returns whether the named collection's schema needs to be read from the database, either because
+// it was invalidated, because it is older than the schema cache TTL, or (in lazy mode) because it has
+// never been read.
+func (self *SqlBackend) schemaCacheExpired(name string) bool {
+	if _, ok := self.registeredCollections.Load(name); !ok && !self.lazySchema() {
+		return false
+	}
+
+	if cachedAt, ok := self.schemaCachedAt.Load(name); ok {
+		if ttl := self.schemaCacheTTL(); ttl > 0 {
+			return time.Since(cachedAt.(time.Time)) > ttl
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// Returns the names of all tables in the database, along with any registered collections that do
+// not exist yet, without introspecting them.
+func (self *SqlBackend) listAllTables() ([]string, error) {
+	names := maputil.StringKeys(&self.registeredCollections)
+
+	if rows, err := self.db.Query(self.listAllTablesQuery); err == nil {
+		defer rows.Close()
+
+		for rows.Next() {
+			var tableName string
+
+			if err := rows.Scan(&tableName); err == nil {
+				if !sliceutil.ContainsString(names, tableName) {
+					names = append(names, tableName)
+				}
+			} else {
+				return nil, err
+			}
+		}
+
+		sort.Strings(names)
+		return names, rows.Err()
+	} else {
+		return nil, err
+	}
+}
+
+// Stores the given collection in the schema cache.
+func (self *SqlBackend) cacheCollection(collection *dal.Collection) {
+	self.registeredCollections.Store(collection.Name, collection)
+	self.schemaCachedAt.Store(collection.Name, time.Now())
+}
+
+// Caches the given definition as the authoritative schema for its collection without introspecting
+// the table.  If verifyRegistered is enabled, the table is checked for the definition's columns the
+// first time this happens.
+func (self *SqlBackend) trustDefinition(definition *dal.Collection) error {
+	if self.conn.OptBool(`verifyRegistered`, DefaultVerifyRegistered) {
+		if _, ok := self.verifiedCollections.Load(definition.Name); !ok {
+			if err := self.verifyDefinition(definition); err != nil {
+				return err
+			}
+
+			self.verifiedCollections.Store(definition.Name, true)
+		}
+	}
+
+	self.RegisterCollection(definition)
+	self.knownCollections.Store(definition.Name, true)
+
+	return nil
+}
+
+// Verifies that the table for the given definition has all of the columns it describes.  Rather than
+// consulting the database's schema tables, this selects no rows from the table and inspects the
+// columns of the (empty) result.
+func (self *SqlBackend) verifyDefinition(definition *dal.Collection) error {
+	gen := self.makeQueryGen(definition)
+	stmt := fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", gen.ToTableName(definition.Name))
+	querylog.Debugf("[%T] %s", self, stmt)
+
+	if rows, err := self.db.Query(stmt); err == nil {
+		defer rows.Close()
+
+		if columns, err := rows.Columns(); err == nil {
+			identityField := definition.IdentityField
+
+			if identityField == `` {
+				identityField = dal.DefaultIdentityField
+			}
+
+			expected := []string{identityField}
+			missing := make([]string, 0)
+
+			for _, field := range definition.Fields {
+				expected = append(expected, field.Name)
+			}
+
+			for _, name := range expected {
+				if !sliceutil.ContainsString(columns, name) {
+					missing = append(missing, name)
+				}
+			}
+
+			if len(missing) > 0 {
+				return fmt.Errorf("Collection %q is missing columns: %s", definition.Name, strings.Join(missing, `, `))
+			}
+
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return fmt.Errorf("Cannot verify collection %q: %v", definition.Name, err)
+	}
+}
+
+// Returns the definition that was explicitly registered for the named collection, if any.
+func (self *SqlBackend) definitionFor(name string) *dal.Collection {
+	if definition, ok := self.definedCollections.Load(name); ok {
+		return definition.(*dal.Collection)
+	}
+
+	return nil
+}
+
+// Inspects an error returned from the database, and if it indicates that the cached schema for the
+// named collection is out of date (and refreshOnSchemaError is enabled), invalidates it.  The error is
+// returned unmodified.
+func (self *SqlBackend) checkSchemaError(name string, err error) error {
+	if err == nil || !self.conn.OptBool(`refreshOnSchemaError`, DefaultRefreshOnSchemaError) {
+		return err
+	}
+
+	msg := strings.ToLower(err.Error())
+
+	for _, pattern := range sqlUnknownColumnErrors {
+		if strings.Contains(msg, pattern) {
+			if pattern == `column "` && !strings.Contains(msg, `does not exist`) {
+				continue
+			}
+
+			self.InvalidateSchemaCache(name)
+			break
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,147 @@
+package backends
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a SQLite backend with the given connection string options, using an in-memory database
+// that already has a "users" table
+func newSqliteSchemaTestBackend(options string) (*SqlBackend, *sql.DB, error) {
+	db, err := sql.Open(`sqlite3`, `:memory:`)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`); err != nil {
+		return nil, nil, err
+	}
+
+	if backend, err := NewSqlBackendFromDB(db, `sqlite:///?`+options); err == nil {
+		return backend.(*SqlBackend), db, backend.Initialize()
+	} else {
+		return nil, nil, err
+	}
+}
+
+func TestSqlLazySchema(t *testing.T) {
+	assert := require.New(t)
+
+	// tables are normally introspected when the backend is initialized...
+	backend, db, err := newSqliteSchemaTestBackend(`autoregister=true`)
+	assert.NoError(err)
+	defer db.Close()
+
+	_, ok := backend.schemaCachedAt.Load(`users`)
+	assert.True(ok)
+
+	// ...but lazily, not until they are used
+	backend, db, err = newSqliteSchemaTestBackend(`autoregister=true&lazySchema=true`)
+	assert.NoError(err)
+	defer db.Close()
+
+	_, ok = backend.schemaCachedAt.Load(`users`)
+	assert.False(ok)
+
+	// tables are listed without being introspected
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`users`}, names)
+
+	_, ok = backend.schemaCachedAt.Load(`users`)
+	assert.False(ok)
+
+	collection, err := backend.GetCollection(`users`)
+	assert.NoError(err)
+
+	_, ok = collection.GetField(`name`)
+	assert.True(ok)
+
+	_, ok = backend.schemaCachedAt.Load(`users`)
+	assert.True(ok)
+}
+
+func TestSqlTrustRegistered(t *testing.T) {
+	assert := require.New(t)
+
+	definition := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name:        `nickname`,
+		Type:        dal.StringType,
+		Description: `only known to the definition`,
+	})
+
+	// registered definitions are used as-is, without reading the table
+	backend, db, err := newSqliteSchemaTestBackend(`trustRegistered=true`)
+	assert.NoError(err)
+	defer db.Close()
+
+	backend.RegisterCollection(definition)
+
+	collection, err := backend.GetCollection(`users`)
+	assert.NoError(err)
+
+	field, ok := collection.GetField(`nickname`)
+	assert.True(ok)
+	assert.Equal(`only known to the definition`, field.Description)
+
+	// ...unless asked to verify them, in which case columns the table lacks are reported
+	backend, db, err = newSqliteSchemaTestBackend(`trustRegistered=true&verifyRegistered=true`)
+	assert.NoError(err)
+	defer db.Close()
+
+	backend.RegisterCollection(definition)
+
+	_, err = backend.GetCollection(`users`)
+	assert.Error(err)
+	assert.Contains(err.Error(), `nickname`)
+
+	_, err = db.Exec(`ALTER TABLE users ADD COLUMN nickname TEXT`)
+	assert.NoError(err)
+
+	_, err = backend.GetCollection(`users`)
+	assert.NoError(err)
+}
+
+func TestSqlSchemaCacheTTL(t *testing.T) {
+	assert := require.New(t)
+
+	backend, db, err := newSqliteSchemaTestBackend(`autoregister=true&schemaCacheTTL=1h`)
+	assert.NoError(err)
+	defer db.Close()
+
+	_, err = db.Exec(`ALTER TABLE users ADD COLUMN age INTEGER`)
+	assert.NoError(err)
+
+	// the cached schema is used until it expires
+	assert.False(backend.schemaCacheExpired(`users`))
+
+	collection, err := backend.getCollectionFromCache(`users`)
+	assert.NoError(err)
+
+	_, ok := collection.GetField(`age`)
+	assert.False(ok)
+
+	backend.schemaCachedAt.Store(`users`, time.Now().Add(-2*time.Hour))
+	assert.True(backend.schemaCacheExpired(`users`))
+
+	collection, err = backend.getCollectionFromCache(`users`)
+	assert.NoError(err)
+
+	_, ok = collection.GetField(`age`)
+	assert.True(ok)
+	assert.False(backend.schemaCacheExpired(`users`))
+
+	// explicitly invalidated schemas are read again regardless of their age
+	backend.InvalidateSchemaCache(`users`)
+	assert.True(backend.schemaCacheExpired(`users`))
+}
@@ -0,0 +1,251 @@
+package backends
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/lib/pq"
+)
+
+// The prefix of the channel that changes to a PostgreSQL table are published on.
+var PostgresNotifyChannelPrefix = `pivot_changes_`
+
+// How long to wait for the trigger used by a change feed to be created or removed.
+var PostgresWatchStatementTimeout = 10 * time.Second
+
+// How long to wait before reconnecting a change feed whose connection to PostgreSQL was lost.
+var PostgresListenerMinReconnect = 1 * time.Second
+var PostgresListenerMaxReconnect = 30 * time.Second
+
+// How often an idle change feed checks that its connection to PostgreSQL is still alive.
+var PostgresListenerPingInterval = 90 * time.Second
+
+// published by the trigger on each change to a row in a watched table.  Notification payloads are
+// limited to 8000 bytes, so deleted rows are only included if they're comfortably smaller than that.
+const postgresNotifyFunction = `CREATE OR REPLACE FUNCTION pivot_notify_change() RETURNS trigger AS $$
+DECLARE
+	payload json;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		IF octet_length(to_jsonb(OLD)::text) < 7000 THEN
+			payload := json_build_object('type', 'delete', 'id', to_jsonb(OLD) ->> TG_ARGV[0], 'old', to_jsonb(OLD));
+		ELSE
+			payload := json_build_object('type', 'delete', 'id', to_jsonb(OLD) ->> TG_ARGV[0]);
+		END IF;
+	ELSE
+		payload := json_build_object('type', lower(TG_OP), 'id', to_jsonb(NEW) ->> TG_ARGV[0]);
+	END IF;
+
+	PERFORM pg_notify(TG_ARGV[1], payload::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`
+
+type postgresNotification struct {
+	Type string                 `json:"type"`
+	ID   interface{}            `json:"id"`
+	Old  map[string]interface{} `json:"old,omitempty"`
+}
+
+// Watches a collection for changes.  PostgreSQL databases are watched using a trigger that
+// publishes changes with NOTIFY; each watch creates its own trigger, which is dropped when the
+// watch ends.  Other databases (and connection pools we were given rather than opened ourselves)
+// are not supported, and are polled instead.
+func (self *SqlBackend) Watch(ctx context.Context, collection *dal.Collection, f *filter.Filter) (<-chan *ChangeEvent, error) {
+	if self.dsn == `` {
+		return nil, NotImplementedError
+	}
+
+	switch self.conn.Backend() {
+	case `postgres`, `postgresql`, `psql`:
+		return self.watchPostgres(ctx, collection, f)
+	default:
+		return nil, NotImplementedError
+	}
+}
+
+func (self *SqlBackend) watchPostgres(ctx context.Context, collection *dal.Collection, f *filter.Filter) (<-chan *ChangeEvent, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	trigger, channel, err := postgresWatchNames(collection.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	table := self.makeQueryGen(collection).ToTableName(collection.Name)
+
+	// create the trigger that publishes changes to the table for this watch
+	if err := self.execWatchStatements(
+		postgresNotifyFunction,
+		fmt.Sprintf(
+			"CREATE TRIGGER %s AFTER INSERT OR UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE PROCEDURE pivot_notify_change(%s, %s)",
+			pq.QuoteIdentifier(trigger),
+			table,
+			pq.QuoteLiteral(collection.IdentityField),
+			pq.QuoteLiteral(channel),
+		),
+	); err != nil {
+		return nil, err
+	}
+
+	dropTrigger := func() {
+		if err := self.execWatchStatements(
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", pq.QuoteIdentifier(trigger), table),
+		); err != nil {
+			log.Warningf("[%T] watch %s: failed to remove trigger %s: %v", self, collection.Name, trigger, err)
+		}
+	}
+
+	listener := pq.NewListener(self.dsn, PostgresListenerMinReconnect, PostgresListenerMaxReconnect, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Warningf("[%T] watch %s: %v", self, collection.Name, err)
+		}
+	})
+
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		dropTrigger()
+		return nil, err
+	}
+
+	events := make(chan *ChangeEvent)
+
+	go func() {
+		defer close(events)
+		defer dropTrigger()
+		defer listener.Close()
+
+		ping := time.NewTicker(PostgresListenerPingInterval)
+		defer ping.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ping.C:
+				if err := listener.Ping(); err != nil {
+					log.Warningf("[%T] watch %s: %v", self, collection.Name, err)
+				}
+
+			case notification := <-listener.Notify:
+				// the listener sends nil after reconnecting, since notifications may have been missed
+				if notification == nil {
+					log.Warningf("[%T] watch %s: reconnected, some changes may not have been reported", self, collection.Name)
+					continue
+				}
+
+				if event := self.postgresChangeEvent(collection, f, notification.Extra); event != nil {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// converts a notification payload into a change event, or returns nil if the changed record does
+// not match the filter
+func (self *SqlBackend) postgresChangeEvent(collection *dal.Collection, f *filter.Filter, payload string) *ChangeEvent {
+	var notification postgresNotification
+
+	if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+		log.Warningf("[%T] watch %s: invalid notification: %v", self, collection.Name, err)
+		return nil
+	}
+
+	event := &ChangeEvent{
+		Collection: collection.Name,
+		ID:         notification.ID,
+		Timestamp:  time.Now(),
+	}
+
+	switch notification.Type {
+	case `insert`:
+		event.Type = ChangeCreate
+	case `update`:
+		event.Type = ChangeUpdate
+	case `delete`:
+		event.Type = ChangeDelete
+
+		// deleted rows too large to be included in the notification are always reported
+		if notification.Old != nil && !f.IsMatchAll() {
+			if !f.MatchesRecord(dal.NewRecord(notification.ID).SetFields(notification.Old)) {
+				return nil
+			}
+		}
+
+		return event
+	default:
+		return nil
+	}
+
+	if record, err := self.Retrieve(collection.Name, notification.ID); err == nil {
+		if !f.IsMatchAll() && !f.MatchesRecord(record) {
+			return nil
+		}
+
+		event.Record = record
+		return event
+	} else {
+		// the record may have been deleted since, in which case we'll hear about that next
+		if !dal.IsNotExistError(err) {
+			log.Warningf("[%T] watch %s: %v", self, collection.Name, err)
+		}
+
+		return nil
+	}
+}
+
+// runs the given statements in a transaction, giving up after PostgresWatchStatementTimeout
+func (self *SqlBackend) execWatchStatements(stmts ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), PostgresWatchStatementTimeout)
+	defer cancel()
+
+	return self.withTransaction(func(tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			querylog.Debugf("[%T] %s", self, stmt)
+
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Returns the names of a new trigger and the channel it publishes on.  Each watch has its own, so
+// that ending one doesn't affect any others on the same table.  Both are identifiers, which
+// PostgreSQL truncates beyond 63 bytes, so the collection name is shortened as needed.
+func postgresWatchNames(collectionName string) (string, string, error) {
+	id := make([]byte, 8)
+
+	if _, err := rand.Read(id); err != nil {
+		return ``, ``, err
+	}
+
+	suffix := `_` + hex.EncodeToString(id)
+	channel := PostgresNotifyChannelPrefix + collectionName
+
+	if len(channel)+len(suffix) > 63 {
+		channel = channel[:63-len(suffix)]
+	}
+
+	return `pivot_notify_change` + suffix, channel + suffix, nil
+}
@@ -0,0 +1,57 @@
+package backends
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresWatchNames(t *testing.T) {
+	assert := require.New(t)
+
+	trigger, channel, err := postgresWatchNames(`users`)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(trigger, `pivot_notify_change_`))
+	assert.True(strings.HasPrefix(channel, PostgresNotifyChannelPrefix+`users_`))
+
+	// every watch gets its own trigger and channel
+	otherTrigger, otherChannel, err := postgresWatchNames(`users`)
+	assert.NoError(err)
+	assert.NotEqual(trigger, otherTrigger)
+	assert.NotEqual(channel, otherChannel)
+
+	// long names are shortened without losing what makes them unique
+	trigger, channel, err = postgresWatchNames(strings.Repeat(`x`, 100))
+	assert.NoError(err)
+	assert.True(len(trigger) <= 63)
+	assert.Len(channel, 63)
+	assert.Equal(trigger[len(trigger)-16:], channel[len(channel)-16:])
+}
+
+func TestPostgresChangeEventDeletes(t *testing.T) {
+	assert := require.New(t)
+
+	backend := &SqlBackend{}
+	collection := dal.NewCollection(`users`)
+
+	event := backend.postgresChangeEvent(collection, filter.All(), `{"type":"delete","id":"1"}`)
+	assert.NotNil(event)
+	assert.Equal(ChangeDelete, event.Type)
+	assert.Equal(`users`, event.Collection)
+	assert.Equal(`1`, event.ID)
+	assert.Nil(event.Record)
+
+	// deleted rows are matched against the filter when they're included
+	f := filter.MustParse(`name/alice`)
+
+	assert.NotNil(backend.postgresChangeEvent(collection, f, `{"type":"delete","id":"1","old":{"name":"alice"}}`))
+	assert.Nil(backend.postgresChangeEvent(collection, f, `{"type":"delete","id":"1","old":{"name":"bob"}}`))
+	assert.NotNil(backend.postgresChangeEvent(collection, f, `{"type":"delete","id":"1"}`))
+
+	// unknown and malformed notifications are ignored
+	assert.Nil(backend.postgresChangeEvent(collection, f, `{"type":"truncate"}`))
+	assert.Nil(backend.postgresChangeEvent(collection, f, `not json`))
+}
@@ -4,11 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"reflect"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
-	"unicode"
 
 	"github.com/ghetzel/go-stockutil/maputil"
 	"github.com/ghetzel/go-stockutil/sliceutil"
@@ -21,6 +20,32 @@ import (
 var objectFieldHintLength = 131071
 var InitialPingTimeout = time.Duration(10) * time.Second
 
+// The maximum number of rendered SELECT statements to cache per backend.  This can be overridden per
+// connection with the "statementCacheSize" option; a value of zero disables the cache.
+var DefaultSqlStatementCacheSize = 1024
+
+// Connection string options that are handled by the SQL backend itself.  All other options are
+// passed through to the database driver as part of its DSN.
+var SqlBackendOptions = []string{
+	`autoregister`,
+	`deadlockRetries`,
+	`deadlockRetryDelay`,
+	`describeTableQuery`,
+	`dsn`,
+	`engine`,
+	`lazySchema`,
+	`listTablesQuery`,
+	`maxQueryParameters`,
+	`planQueries`,
+	`refreshOnSchemaError`,
+	`schemaCacheTTL`,
+	`spatialite`,
+	`sqlDriver`,
+	`statementCacheSize`,
+	`trustRegistered`,
+	`verifyRegistered`,
+}
+
 type sqlTableDetails struct {
 	Index        int
 	Name         string
@@ -42,13 +67,20 @@ type additionalIndexer struct {
 
 type sqlTableDetailsFunc func(datasetName string, collectionName string) (*dal.Collection, error)
 
+type sqlColumnDefinitionFunc func(gen *generators.Sql, field dal.Field) (string, error)
+
 type SqlBackend struct {
 	Backend
 	Indexer
 	Aggregator
 	conn                        *dal.ConnectionString
 	db                          *sql.DB
+	dsn                         string
+	externalDB                  bool
+	maxOpenConns                int
+	spatialite                  bool
 	indexer                     Indexer
+	textIndexer                 Indexer
 	aggregator                  map[string]Aggregator
 	queryGenTypeMapping         generators.SqlTypeMapping
 	queryGenPlaceholderArgument string
@@ -56,15 +88,28 @@ type SqlBackend struct {
 	queryGenTableFormat         string
 	queryGenFieldFormat         string
 	queryGenNestedFieldFormat   string
+	queryGenNestedFieldJoiner   string
+	queryGenNestedIndexFormat   string
 	queryGenNormalizerFormat    string
+	queryGenOffsetFetch         bool
+	queryGenUnorderedFetch      bool
+	queryGenMutations           bool
 	listAllTablesQuery          string
+	initializeStatements        []string
 	createPrimaryKeyIntFormat   string
 	createPrimaryKeyStrFormat   string
+	createTableSuffixFormat     string
+	columnDefinitionFunc        sqlColumnDefinitionFunc
 	showTableDetailQuery        string
 	refreshCollectionFunc       sqlTableDetailsFunc
 	dropTableQuery              string
+	timeBucketFormats           map[TimeInterval]string
 	registeredCollections       sync.Map
-	knownCollections            map[string]bool
+	definedCollections          sync.Map
+	knownCollections            sync.Map
+	schemaCachedAt              sync.Map
+	verifiedCollections         sync.Map
+	statementCache              *generators.SqlStatementCache
 }
 
 func NewSqlBackend(connection dal.ConnectionString) Backend {
@@ -74,26 +119,49 @@ func NewSqlBackend(connection dal.ConnectionString) Backend {
 		queryGenPlaceholderFormat: `?`,
 		dropTableQuery:            `DROP TABLE %s`,
 		aggregator:                make(map[string]Aggregator),
-		knownCollections:          make(map[string]bool),
 	}
 
 	backend.indexer = backend
 	return backend
 }
 
+// Returns a SQL backend that uses an existing connection pool instead of opening its own, for
+// applications that manage their own connections (e.g.: with custom dialers or authentication).
+// The dialect is the name of the backend the database is ("sqlite", "mysql", "postgres", "cockroach", "mssql", "clickhouse", "duckdb", or "ansi"), or a
+// connection string (e.g.: "postgres:///mydb?autoregister=true") to also specify the dataset and
+// other options.  The pool is never closed by the backend, and Initialize must still be called.
+func NewSqlBackendFromDB(db *sql.DB, dialect string) (Backend, error) {
+	if !strings.Contains(dialect, `://`) {
+		dialect = dialect + `:///`
+	}
+
+	if cs, err := dal.ParseConnectionString(dialect); err == nil {
+		backend := NewSqlBackend(cs).(*SqlBackend)
+		backend.db = db
+		backend.externalDB = true
+
+		return backend, nil
+	} else {
+		return nil, err
+	}
+}
+
 func (self *SqlBackend) GetConnectionString() *dal.ConnectionString {
 	return self.conn
 }
 
 func (self *SqlBackend) RegisterCollection(collection *dal.Collection) {
 	if collection != nil {
-		self.registeredCollections.Store(collection.Name, collection)
+		self.definedCollections.Store(collection.Name, collection)
+		self.cacheCollection(collection)
 		log.Debugf("[%T] register collection %v", self, collection.Name)
 	}
 }
 
 func (self *SqlBackend) SetIndexer(indexConnString dal.ConnectionString) error {
 	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.textIndexer = indexer
+
 		if indexConnString.OptBool(`fallbackToBackend`, false) {
 			log.Debugf("Indexer fallback to backend %T", self)
 
@@ -128,6 +196,16 @@ func (self *SqlBackend) Initialize() error {
 		name, dsn, err = self.initializeMysql()
 	case `postgres`, `postgresql`, `psql`:
 		name, dsn, err = self.initializePostgres()
+	case `cockroach`, `cockroachdb`:
+		name, dsn, err = self.initializeCockroach()
+	case `clickhouse`:
+		name, dsn, err = self.initializeClickhouse()
+	case `duckdb`:
+		name, dsn, err = self.initializeDuckdb()
+	case `mssql`, `sqlserver`:
+		name, dsn, err = self.initializeMssql()
+	case `odbc`, `ansi`:
+		name, dsn, err = self.initializeAnsi()
 	default:
 		return fmt.Errorf("Unsupported backend %q", backend)
 	}
@@ -138,11 +216,22 @@ func (self *SqlBackend) Initialize() error {
 		internalBackend = name
 	}
 
-	// setup the database driver for use
-	if db, err := sql.Open(internalBackend, dsn); err == nil {
-		self.db = db
-	} else {
-		return err
+	self.statementCache = generators.NewSqlStatementCache(
+		int(self.conn.OptInt(`statementCacheSize`, int64(DefaultSqlStatementCacheSize))),
+	)
+
+	// setup the database driver for use (unless we were given a connection pool to use)
+	if !self.externalDB {
+		if db, err := sql.Open(internalBackend, dsn); err == nil {
+			self.db = db
+			self.dsn = dsn
+
+			if self.maxOpenConns > 0 {
+				db.SetMaxOpenConns(self.maxOpenConns)
+			}
+		} else {
+			return err
+		}
 	}
 
 	// actually verify database connectivity at this time
@@ -150,9 +239,28 @@ func (self *SqlBackend) Initialize() error {
 		return err
 	}
 
-	// refresh schema cache
-	if err := self.refreshAllCollections(); err != nil {
-		return err
+	// create anything the dialect relies on that the database may not have yet
+	for _, stmt := range self.initializeStatements {
+		querylog.Debugf("[%T] %s", self, stmt)
+
+		if _, err := self.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// a connection pool we were given may not have told us which database it is connected to
+	if self.externalDB && self.conn.Dataset() == `` {
+		if err := self.detectDataset(); err != nil {
+			return err
+		}
+	}
+
+	// refresh schema cache (unless we're going to introspect each table as it is first used, or
+	// we've been told to use the registered collections as-is)
+	if !self.lazySchema() && !self.trustRegistered() {
+		if err := self.refreshAllCollections(); err != nil {
+			return err
+		}
 	}
 
 	if err := self.indexer.IndexInitialize(self); err != nil {
@@ -181,76 +289,102 @@ func (self *SqlBackend) Ping(timeout time.Duration) error {
 }
 
 func (self *SqlBackend) Insert(name string, recordset *dal.RecordSet) error {
+	defer timeOperation(`pivot.backends.sql.insert_time`)()
+
 	if collection, err := self.getCollectionFromCache(name); err == nil {
-		if tx, err := self.db.Begin(); err == nil {
-			switch self.conn.Backend() {
-			case `mysql`:
-				// disable zero-means-use-autoincrement for inserts in MySQL
-				if _, err := tx.Exec(`SET sql_mode='NO_AUTO_VALUE_ON_ZERO'`); err != nil {
-					defer tx.Rollback()
-					return err
-				}
+		if err := self.withTransaction(func(tx *sql.Tx) error {
+			return self.insertTx(tx, collection, recordset)
+		}); err != nil {
+			return self.checkSchemaError(name, err)
+		}
+
+		if search := self.WithSearch(collection); search != nil {
+			if err := search.Index(collection, recordset); err != nil {
+				querylog.Debugf("[%T] index error %v", self, err)
+			} else {
+				return err
 			}
+		}
 
-			// for each record being inserted...
-			for _, record := range recordset.Records {
-				if r, err := collection.MakeRecord(record); err == nil {
-					record = r
-				} else {
-					return err
-				}
+		return nil
+	} else {
+		return err
+	}
+}
 
-				// setup query generator
-				queryGen := self.makeQueryGen(collection)
-				queryGen.Type = generators.SqlInsertStatement
+// Inserts the given records within an existing transaction.
+func (self *SqlBackend) insertTx(tx *sql.Tx, collection *dal.Collection, recordset *dal.RecordSet) error {
+	switch self.conn.Backend() {
+	case `mysql`:
+		// disable zero-means-use-autoincrement for inserts in MySQL
+		if _, err := tx.Exec(`SET sql_mode='NO_AUTO_VALUE_ON_ZERO'`); err != nil {
+			return err
+		}
+	case `clickhouse`:
+		return self.insertClickhouseTx(tx, collection, recordset)
+	case `mssql`, `sqlserver`:
+		// SQL Server rejects explicit values for IDENTITY columns unless told to accept them
+		if collection.IdentityFieldType != dal.StringType && recordsHaveIDs(recordset) {
+			table := self.makeQueryGen(collection).ToTableName(collection.Name)
+
+			if _, err := tx.Exec(fmt.Sprintf("SET IDENTITY_INSERT %s ON", table)); err != nil {
+				return err
+			}
 
-				// add record data to query input
-				for k, v := range record.Fields {
-					// convert incoming values to their destination field types
-					queryGen.InputData[k] = collection.ConvertValue(k, v)
-				}
+			defer tx.Exec(fmt.Sprintf("SET IDENTITY_INSERT %s OFF", table))
+		}
+	}
 
-				// set the primary key
-				if !typeutil.IsZero(record.ID) && fmt.Sprintf("%v", record.ID) != `0` {
-					// convert incoming ID to it's destination field type
-					queryGen.InputData[collection.IdentityField] = collection.ConvertValue(collection.IdentityField, record.ID)
-				}
+	records := make([]*dal.Record, len(recordset.Records))
 
-				// render the query into the final SQL
-				if stmt, err := filter.Render(queryGen, collection.Name, filter.Null()); err == nil {
-					querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+	for i, record := range recordset.Records {
+		if r, err := collection.MakeRecord(record); err == nil {
+			records[i] = r
+		} else {
+			return err
+		}
+	}
 
-					// execute the SQL
-					if _, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...); err != nil {
-						defer tx.Rollback()
-						return err
-					}
-				} else {
-					defer tx.Rollback()
-					return err
-				}
+	// convert incoming values to their destination field types
+	rows := collection.ConvertRecordValues(records)
+	convertId := collection.ValueConverter(collection.IdentityField)
+
+	// for each record being inserted...
+	for i, record := range records {
+		// setup query generator
+		queryGen := self.makeQueryGen(collection)
+		queryGen.Type = generators.SqlInsertStatement
+
+		// add record data to query input
+		for k, v := range rows[i] {
+			if !isGeneratedField(collection, k) {
+				queryGen.InputData[k] = v
 			}
+		}
 
-			// commit transaction
-			if err := tx.Commit(); err == nil {
-				if search := self.WithSearch(collection); search != nil {
-					if err := search.Index(collection, recordset); err != nil {
-						querylog.Debugf("[%T] index error %v", self, err)
-					} else {
-						return err
-					}
-				}
+		// set the primary key
+		if !typeutil.IsZero(record.ID) && fmt.Sprintf("%v", record.ID) != `0` {
+			// convert incoming ID to it's destination field type
+			queryGen.InputData[collection.IdentityField] = convertId(record.ID)
+		}
 
-				return nil
-			} else {
-				return err
+		// render the query into the final SQL
+		if stmt, err := filter.Render(queryGen, collection.Name, filter.Null()); err == nil {
+			querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+			// execute the SQL
+			_, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...)
+			queryGen.Release()
+
+			if err != nil {
+				return translateSqlError(err)
 			}
 		} else {
 			return err
 		}
-	} else {
-		return err
 	}
+
+	return nil
 }
 
 func (self *SqlBackend) Exists(name string, id interface{}) bool {
@@ -295,6 +429,8 @@ func (self *SqlBackend) Exists(name string, id interface{}) bool {
 }
 
 func (self *SqlBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	defer timeOperation(`pivot.backends.sql.retrieve_time`)()
+
 	if collection, err := self.getCollectionFromCache(name); err == nil {
 		if f, err := filter.FromMap(map[string]interface{}{
 			collection.IdentityField: fmt.Sprintf("is:%v", id),
@@ -312,20 +448,23 @@ func (self *SqlBackend) Retrieve(name string, id interface{}, fields ...string)
 
 						if columns, err := rows.Columns(); err == nil {
 							if rows.Next() {
-								return self.scanFnValueToRecord(queryGen, collection, columns, reflect.ValueOf(rows.Scan), fields)
+								plan := newSqlScanPlan(queryGen, collection, columns, fields)
+								defer plan.Release()
+
+								return plan.Scan(rows)
 							} else {
 								// if it doesn't exist, make sure it's not indexed
 								if search := self.WithSearch(collection); search != nil {
 									defer search.IndexRemove(collection, []interface{}{id})
 								}
 
-								return nil, fmt.Errorf("Record %v does not exist", id)
+								return nil, dal.RecordNotFound(id)
 							}
 						} else {
 							return nil, err
 						}
 					} else {
-						return nil, err
+						return nil, self.checkSchemaError(name, err)
 					}
 				} else {
 					return nil, err
@@ -342,6 +481,8 @@ func (self *SqlBackend) Retrieve(name string, id interface{}, fields ...string)
 }
 
 func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	defer timeOperation(`pivot.backends.sql.update_time`)()
+
 	var targetFilter *filter.Filter
 
 	if len(target) > 0 {
@@ -353,129 +494,136 @@ func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...
 	}
 
 	if collection, err := self.getCollectionFromCache(name); err == nil {
-		if tx, err := self.db.Begin(); err == nil {
-			// for each record being updated...
-			for _, record := range recordset.Records {
-				if r, err := collection.MakeRecord(record); err == nil {
-					record = r
-				} else {
-					return err
-				}
+		if err := self.withTransaction(func(tx *sql.Tx) error {
+			return self.updateTx(tx, collection, recordset, targetFilter)
+		}); err != nil {
+			return self.checkSchemaError(name, err)
+		}
 
-				// setup query generator
-				queryGen := self.makeQueryGen(collection)
-				queryGen.Type = generators.SqlUpdateStatement
+		if search := self.WithSearch(collection); search != nil {
+			if err := search.Index(collection, recordset); err != nil {
+				return err
+			}
+		}
 
-				var recordUpdateFilter *filter.Filter
+		return nil
+	} else {
+		return err
+	}
+}
 
-				// if this record was specified without a specific ID, attempt to use the broader
-				// target filter (if given)
-				if record.ID == `` {
-					if len(target) > 0 {
-						recordUpdateFilter = targetFilter
-					} else {
-						defer tx.Rollback()
-						return fmt.Errorf("Update must target at least one record")
-					}
-				} else {
-					// try to build a filter targeting this specific record
-					if f, err := filter.FromMap(map[string]interface{}{
-						collection.IdentityField: fmt.Sprintf("is:%v", record.ID),
-					}); err == nil {
-						recordUpdateFilter = f
-					} else {
-						defer tx.Rollback()
-						return err
-					}
-				}
+// Updates the given records within an existing transaction.  Records without an ID are applied
+// to all records matching targetFilter (if given).
+func (self *SqlBackend) updateTx(tx *sql.Tx, collection *dal.Collection, recordset *dal.RecordSet, targetFilter *filter.Filter) error {
+	// for each record being updated...
+	for _, record := range recordset.Records {
+		if r, err := collection.MakeRecord(record); err == nil {
+			record = r
+		} else {
+			return err
+		}
 
-				// add all non-ID fields to the record's Fields set
-				for k, v := range record.Fields {
-					if k != collection.IdentityField {
-						queryGen.InputData[k] = v
-					}
-				}
+		// setup query generator
+		queryGen := self.makeQueryGen(collection)
+		queryGen.Type = generators.SqlUpdateStatement
 
-				// generate SQL
-				if stmt, err := filter.Render(queryGen, collection.Name, recordUpdateFilter); err == nil {
-					querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+		var recordUpdateFilter *filter.Filter
 
-					// execute SQL
-					if _, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...); err != nil {
-						defer tx.Rollback()
-						return err
-					}
-				} else {
-					defer tx.Rollback()
-					return err
-				}
+		// if this record was specified without a specific ID, attempt to use the broader
+		// target filter (if given)
+		if record.ID == `` {
+			if targetFilter != nil {
+				recordUpdateFilter = targetFilter
+			} else {
+				return fmt.Errorf("Update must target at least one record")
 			}
-
-			if err := tx.Commit(); err == nil {
-				if search := self.WithSearch(collection); search != nil {
-					if err := search.Index(collection, recordset); err != nil {
-						return err
-					}
-				}
-
-				return nil
+		} else {
+			// try to build a filter targeting this specific record
+			if f, err := filter.FromMap(map[string]interface{}{
+				collection.IdentityField: fmt.Sprintf("is:%v", record.ID),
+			}); err == nil {
+				recordUpdateFilter = f
 			} else {
 				return err
 			}
+		}
+
+		// add all non-ID fields to the record's Fields set
+		for k, v := range record.Fields {
+			if k != collection.IdentityField && !isGeneratedField(collection, k) {
+				queryGen.InputData[k] = v
+			}
+		}
+
+		// generate SQL
+		if stmt, err := filter.Render(queryGen, collection.Name, recordUpdateFilter); err == nil {
+			querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+			// execute SQL
+			if _, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...); err != nil {
+				return translateSqlError(err)
+			}
 		} else {
 			return err
 		}
-	} else {
-		return err
 	}
+
+	return nil
 }
 
 func (self *SqlBackend) Delete(name string, ids ...interface{}) error {
+	defer timeOperation(`pivot.backends.sql.delete_time`)()
+
 	if collection, err := self.getCollectionFromCache(name); err == nil {
 		// remove documents from index
 		if search := self.WithSearch(collection); search != nil {
 			defer search.IndexRemove(collection, ids)
 		}
 
-		f := filter.New()
-
-		f.AddCriteria(filter.Criterion{
-			Field:  collection.IdentityField,
-			Values: ids,
+		return self.withTransaction(func(tx *sql.Tx) error {
+			return self.deleteTx(tx, collection, ids)
 		})
+	} else {
+		return err
+	}
+}
 
-		if tx, err := self.db.Begin(); err == nil {
-			queryGen := self.makeQueryGen(collection)
-			queryGen.Type = generators.SqlDeleteStatement
+// Deletes the records with the given IDs within an existing transaction.
+func (self *SqlBackend) deleteTx(tx *sql.Tx, collection *dal.Collection, ids []interface{}) error {
+	f := filter.New()
 
-			// generate SQL
-			if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
-				querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+	f.AddCriteria(filter.Criterion{
+		Field:  collection.IdentityField,
+		Values: ids,
+	})
 
-				// execute SQL
-				if _, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...); err == nil {
-					if err := tx.Commit(); err == nil {
-						return nil
-					} else {
-						return err
-					}
-				} else {
-					defer tx.Rollback()
-					return err
-				}
-			} else {
-				defer tx.Rollback()
-				return err
-			}
-		} else {
-			return err
-		}
+	queryGen := self.makeQueryGen(collection)
+	queryGen.Type = generators.SqlDeleteStatement
+
+	// generate SQL
+	if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
+		querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+		// execute SQL
+		_, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...)
+		return err
 	} else {
 		return err
 	}
 }
 
 func (self *SqlBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	// let the planner decide between the search index and the database for each query
+	if self.planQueries() {
+		return NewQueryPlanner(self, self.textIndexer, self)
+	}
+
+	// full-text searches combined with other criteria are split between the search index and the
+	// database
+	if self.shouldResolveFullText(filters) {
+		return self
+	}
+
 	return self.indexer
 }
 
@@ -490,6 +638,10 @@ func (self *SqlBackend) WithAggregator(collection *dal.Collection) Aggregator {
 }
 
 func (self *SqlBackend) ListCollections() ([]string, error) {
+	if self.lazySchema() {
+		return self.listAllTables()
+	}
+
 	return maputil.StringKeys(&self.registeredCollections), nil
 }
 
@@ -549,6 +701,21 @@ func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
 	for _, field := range definition.Fields {
 		var def string
 
+		// SpatiaLite geometry fields are added once the table exists
+		if field.Type.IsGeometry() && self.spatialite {
+			continue
+		}
+
+		// some databases declare columns in ways of their own
+		if self.columnDefinitionFunc != nil {
+			if def, err := self.columnDefinitionFunc(gen, field); err == nil {
+				fields = append(fields, def)
+				continue
+			} else {
+				return err
+			}
+		}
+
 		// This is weird...
 		//
 		// So Raw fields and Object fields are stored using the same datatype (BLOB), which
@@ -569,6 +736,15 @@ func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
 			return err
 		}
 
+		// generated columns are computed from an expression, and so cannot have a default value
+		if field.Generated != `` {
+			if field.GeneratedStored {
+				def += fmt.Sprintf(" GENERATED ALWAYS AS (%s) STORED", field.Generated)
+			} else {
+				def += fmt.Sprintf(" GENERATED ALWAYS AS (%s) VIRTUAL", field.Generated)
+			}
+		}
+
 		if field.Required {
 			def += ` NOT NULL`
 		}
@@ -578,7 +754,7 @@ func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
 		}
 
 		// if the default value is neither nil nor a function
-		if v := field.DefaultValue; v != nil && !typeutil.IsFunction(field.DefaultValue) {
+		if v := field.DefaultValue; v != nil && field.Generated == `` && !typeutil.IsFunction(field.DefaultValue) {
 			def += fmt.Sprintf(" DEFAULT %v", gen.ToNativeValue(field.Type, []dal.Type{field.Subtype}, v))
 		}
 
@@ -588,10 +764,23 @@ func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
 	stmt += strings.Join(fields, `, `)
 	stmt += `)`
 
+	if self.createTableSuffixFormat != `` {
+		stmt += fmt.Sprintf(self.createTableSuffixFormat, gen.ToFieldName(definition.IdentityField))
+	}
+
 	if tx, err := self.db.Begin(); err == nil {
 		querylog.Debugf("[%T] %s %v", self, string(stmt[:]), values)
 
 		if _, err := tx.Exec(stmt, values...); err == nil {
+			for _, geoStmt := range self.geometryStatements(gen, definition, definition.Fields) {
+				querylog.Debugf("[%T] %s", self, geoStmt)
+
+				if _, err := tx.Exec(geoStmt); err != nil {
+					defer tx.Rollback()
+					return err
+				}
+			}
+
 			defer func() {
 				self.RegisterCollection(definition)
 
@@ -633,7 +822,7 @@ func (self *SqlBackend) DeleteCollection(collectionName string) error {
 
 func (self *SqlBackend) GetCollection(name string) (*dal.Collection, error) {
 	if err := self.refreshCollectionFromDatabase(name, nil); err == nil {
-		if _, ok := self.knownCollections[name]; !ok {
+		if _, ok := self.knownCollections.Load(name); !ok {
 			return nil, dal.CollectionNotFound
 		}
 
@@ -679,7 +868,21 @@ func (self *SqlBackend) makeQueryGen(collection *dal.Collection) *generators.Sql
 		queryGen.NestedFieldNameFormat = v
 	}
 
+	if v := self.queryGenNestedFieldJoiner; v != `` {
+		queryGen.NestedFieldJoiner = v
+	}
+
+	if v := self.queryGenNestedIndexFormat; v != `` {
+		queryGen.NestedFieldIndexFormat = v
+	}
+
+	queryGen.OffsetFetch = self.queryGenOffsetFetch
+	queryGen.UnorderedOffsetFetch = self.queryGenUnorderedFetch
+	queryGen.Mutations = self.queryGenMutations
+
 	if collection != nil {
+		queryGen.NamingConvention = collection.GetNamingConvention()
+
 		// perform string normalization on non-pk, non-key string fields
 		for _, field := range collection.Fields {
 			if field.Identity || field.Key {
@@ -700,250 +903,128 @@ func (self *SqlBackend) makeQueryGen(collection *dal.Collection) *generators.Sql
 	return queryGen
 }
 
-func (self *SqlBackend) scanFnValueToRecord(queryGen *generators.Sql, collection *dal.Collection, columns []string, scanFn reflect.Value, wantedFields []string) (*dal.Record, error) {
-	if scanFn.Kind() != reflect.Func {
-		return nil, fmt.Errorf("Can only accept a function value")
+// Applies the given schema differences to the database.  Only additive changes (i.e.: adding
+// fields that exist in the registered definition but not in the table) are supported.  Every delta
+// is checked before any table is modified, so unsupported changes leave the table untouched.  If
+// adding a column fails anyway, the returned *MigrationError names the fields already added.
+func (self *SqlBackend) Migrate(diff []dal.SchemaDelta) error {
+	type pendingColumn struct {
+		collection *dal.Collection
+		field      string
+		stmts      []string
 	}
 
-	// sql.Row.Scan is strict about how we call it (e.g.: won't return results as a map),
-	// so we hack...
-	//
-	output := make([]interface{}, len(columns))
-
-	// put a zero-value instance of each column's type in the result array, which will
-	// serve as a hint to the sql.Scan function as to how to convert the data
-	for i, column := range columns {
-		baseColumn := strings.Split(column, queryGen.NestedFieldSeparator)[0]
-
-		if field, ok := collection.GetField(baseColumn); ok {
-			if field.DefaultValue != nil {
-				output[i] = field.GetDefaultValue()
-			} else if field.Required {
-				output[i] = field.GetTypeInstance()
+	var pending []pendingColumn
+
+	for _, delta := range diff {
+		switch delta.Issue {
+		case dal.FieldMissingIssue:
+			if collection, err := self.getCollectionFromCache(delta.Collection); err == nil {
+				if field, ok := collection.GetField(delta.Name); ok {
+					if stmts, err := self.addColumnStatements(collection, field); err == nil {
+						pending = append(pending, pendingColumn{
+							collection: collection,
+							field:      field.Name,
+							stmts:      stmts,
+						})
+					} else {
+						return fmt.Errorf("Cannot add field %q: %v", delta.Name, err)
+					}
+				} else {
+					return fmt.Errorf("Cannot add field %q: not in collection %q", delta.Name, delta.Collection)
+				}
 			} else {
-				switch field.Type {
-				case dal.StringType, dal.TimeType, dal.ObjectType:
-					output[i] = sql.NullString{}
-
-				case dal.BooleanType:
-					output[i] = sql.NullBool{}
+				return fmt.Errorf("Cannot add field %q: %v", delta.Name, err)
+			}
 
-				case dal.IntType:
-					output[i] = sql.NullInt64{}
+		default:
+			return fmt.Errorf("Cannot migrate collection %q: %v", delta.Collection, delta)
+		}
+	}
 
-				case dal.FloatType:
-					output[i] = sql.NullFloat64{}
+	var added []string
 
-				default:
-					output[i] = make([]byte, 0)
-				}
+	for _, column := range pending {
+		if err := self.execStatements(column.stmts); err != nil {
+			return &MigrationError{
+				Collection: column.collection.Name,
+				Added:      added,
+				Err:        fmt.Errorf("Cannot add field %q: %v", column.field, err),
 			}
 		}
-	}
 
-	rRowArgs := make([]reflect.Value, len(output))
+		added = append(added, column.field)
+	}
 
-	// each argument in the call to scan will be the address of the corresponding
-	// item in the output array
-	for i, _ := range output {
-		rRowArgs[i] = reflect.ValueOf(output).Index(i).Addr()
+	if len(diff) > 0 {
+		return self.refreshCollectionFromDatabase(diff[0].Collection, nil)
 	}
 
-	// perform the call to the Scan() function with the correct number of "arguments"
-	rRowResult := scanFn.Call(rRowArgs)
+	return nil
+}
 
-	var err error
+// Returns the statements that add the given field to the collection's table.
+func (self *SqlBackend) addColumnStatements(collection *dal.Collection, field dal.Field) ([]string, error) {
+	gen := self.makeQueryGen(collection)
 
-	// the function should only return one value of type error
-	if len(rRowResult) == 1 {
-		v := rRowResult[0].Interface()
+	if field.Type == dal.ObjectType {
+		field.Length = objectFieldHintLength
+	}
+
+	var def string
 
-		if e, ok := v.(error); ok || v == nil {
-			err = e
+	if self.columnDefinitionFunc != nil {
+		if d, err := self.columnDefinitionFunc(gen, field); err == nil {
+			def = d
 		} else {
-			return nil, fmt.Errorf("row scan call returned invalid type (%T)", v)
+			return nil, err
 		}
 	} else {
-		return nil, fmt.Errorf("invalid response from row scan call")
-	}
-
-	// this is the actual error returned from calling Scan()
-	if err == nil {
-		var id interface{}
-		fields := make(map[string]interface{})
-
-		// for each column in the resultset
-
-	ColumnLoop:
-		for i, column := range columns {
-			nestedPath := strings.Split(column, queryGen.NestedFieldSeparator)
-			baseColumn := nestedPath[0]
-
-			if field, ok := collection.GetField(baseColumn); ok {
-				var value interface{}
-
-				// convert value types as needed
-				switch output[i].(type) {
-				// raw byte arrays will either be strings, blobs, or binary-encoded objects
-				// we need to figure out which
-				case []uint8:
-					v := output[i].([]uint8)
-
-					var dest map[string]interface{}
-
-					switch field.Type {
-					case dal.ObjectType:
-						if err := generators.SqlObjectTypeDecode([]byte(v), &dest); err == nil {
-							value = dest
-						} else {
-							value = string(v[:])
-						}
-
-					// if this field is a raw type, then it's not a string, which
-					// leaves raw or object
-					//
-					case dal.RawType:
-						// blindly attempt to load the data as if it were an object, then
-						// fallback to using the raw byte array
-						//
-						if err := generators.SqlObjectTypeDecode([]byte(v), &dest); err == nil {
-							value = dest
-						} else {
-							value = []byte(v)
-						}
-
-					default:
-						value = nil
-
-						if vStr := string(v); len(vStr) > 0 {
-							var normalized []rune
-
-							for _, r := range vStr {
-								if unicode.IsGraphic(r) {
-									normalized = append(normalized, r)
-								}
-							}
-
-							if nS := string(normalized); nS != `` {
-								value = nS
-							}
-						}
-					}
-				case sql.NullString:
-					v := output[i].(sql.NullString)
-
-					if v.Valid {
-						value = v.String
-					} else {
-						value = nil
-					}
-
-				case sql.NullBool:
-					v := output[i].(sql.NullBool)
-
-					if v.Valid {
-						value = v.Bool
-					} else {
-						value = nil
-					}
-
-				case sql.NullInt64:
-					v := output[i].(sql.NullInt64)
-
-					if v.Valid {
-						value = v.Int64
-					} else {
-						value = nil
-					}
-
-				case sql.NullFloat64:
-					v := output[i].(sql.NullFloat64)
+		if nativeType, err := gen.ToNativeType(field.Type, []dal.Type{field.Subtype}, field.Length); err == nil {
+			def = fmt.Sprintf("%s %s", gen.ToFieldName(field.Name), nativeType)
+		} else {
+			return nil, err
+		}
 
-					if v.Valid {
-						value = v.Float64
-					} else {
-						value = nil
-					}
-				default:
-					value = output[i]
-				}
+		if field.Required {
+			def += ` NOT NULL`
+		}
 
-				// set the appropriate field for the dal.Record
-				if v, err := field.ConvertValue(value); err == nil {
-					if column == collection.IdentityField {
-						id = v
-					} else {
-						if len(wantedFields) > 0 {
-							shouldSkip := true
+		if v := field.DefaultValue; v != nil && !typeutil.IsFunction(field.DefaultValue) {
+			def += fmt.Sprintf(" DEFAULT %v", gen.ToNativeValue(field.Type, []dal.Type{field.Subtype}, v))
+		}
+	}
 
-							for _, wantedField := range wantedFields {
-								parts := strings.Split(wantedField, queryGen.NestedFieldSeparator)
+	stmts := []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", gen.ToTableName(collection.Name), def),
+	}
 
-								if parts[0] == baseColumn {
-									shouldSkip = false
-									break
-								}
-							}
+	// SpatiaLite geometry fields are added by the statements that index them
+	if field.Type.IsGeometry() && self.spatialite {
+		stmts = nil
+	}
 
-							if shouldSkip {
-								continue ColumnLoop
-							}
+	return append(stmts, self.geometryStatements(gen, collection, []dal.Field{field})...), nil
+}
 
-						}
+// Executes the given statements in a single transaction.
+func (self *SqlBackend) execStatements(stmts []string) error {
+	if tx, err := self.db.Begin(); err == nil {
+		for _, stmt := range stmts {
+			querylog.Debugf("[%T] %s", self, stmt)
 
-						if newFields, ok := maputil.DeepSet(fields, nestedPath, v).(map[string]interface{}); ok {
-							fields = newFields
-						}
-					}
-				}
+			if _, err := tx.Exec(stmt); err != nil {
+				defer tx.Rollback()
+				return err
 			}
 		}
 
-		record := dal.NewRecord(id).SetFields(fields)
-
-		// do this AFTER populating the record's fields from the database
-		if err := record.Populate(record, collection); err != nil {
-			return nil, fmt.Errorf("error populating record: %v", err)
-		}
-
-		return record, nil
+		return tx.Commit()
 	} else {
-		return nil, err
+		return err
 	}
 }
 
-// func (self *SqlBackend) Migrate(diff []dal.SchemaDelta) error {
-// 	for _, delta := range diff {
-// 		switch delta.Issue {
-// 		case dal.CollectionKeyNameIssue, dal.CollectionKeyTypeIssue:
-// 			return fmt.Errorf("Cannot alter key name or type for %T", self)
-
-// 		case dal.FieldMissingIssue:
-// 			// ALTER TABLE ADD COLUMN ...
-// 			if collection, err := self.getCollectionFromCache(delta.Collection); err == nil {
-// 				if field, ok := collection.GetField(delta.Name); ok {
-
-// 				} else {
-// 					return fmt.Errorf("Cannot add field %q: not in collection %q", delta.Name, delta.Collection)
-// 				}
-// 			} else {
-// 				return fmt.Errorf("Cannot add field %q: %v", delta.Name, err)
-// 			}
-
-// 		case dal.FieldNameIssue:
-// 			// ALTER TABLE ADD COLUMN ...
-// 		case dal.FieldLengthIssue:
-// 			// ALTER TABLE  ...
-// 		case dal.FieldTypeIssue:
-// 			// ALTER TABLE  ...
-// 		case dal.FieldPropertyIssue:
-// 			// ...
-// 		}
-// 	}
-
-// 	return fmt.Errorf("Not Implemented")
-// }
-
 func (self *SqlBackend) refreshAllCollections() error {
 	if !self.conn.OptBool(`autoregister`, DefaultAutoregister) {
 		return nil
@@ -991,6 +1072,16 @@ func (self *SqlBackend) refreshAllCollections() error {
 }
 
 func (self *SqlBackend) refreshCollectionFromDatabase(name string, definition *dal.Collection) error {
+	if self.trustRegistered() {
+		if definition == nil {
+			definition = self.definitionFor(name)
+		}
+
+		if definition != nil {
+			return self.trustDefinition(definition)
+		}
+	}
+
 	if collection, err := self.refreshCollectionFunc(
 		self.conn.Dataset(),
 		name,
@@ -1004,22 +1095,409 @@ func (self *SqlBackend) refreshCollectionFromDatabase(name string, definition *d
 				self.RegisterCollection(definition)
 
 			} else if self.conn.OptBool(`autoregister`, DefaultAutoregister) {
-				self.RegisterCollection(collection)
+				self.cacheCollection(collection)
 			}
 
-			self.knownCollections[name] = true
+			self.knownCollections.Store(name, true)
+		} else {
+			// the table no longer exists; forget everything we knew about it except what the
+			// caller told us explicitly
+			self.knownCollections.Delete(name)
+
+			if definition == nil {
+				self.registeredCollections.Delete(name)
+			}
 		}
 
+		self.schemaCachedAt.Store(name, time.Now())
 		return nil
 	} else {
 		return err
 	}
 }
 
+// asks the database which dataset (database) the connection pool is using
+func (self *SqlBackend) detectDataset() error {
+	var stmt string
+
+	switch self.conn.Backend() {
+	case `mysql`:
+		stmt = `SELECT DATABASE()`
+	case `postgres`, `postgresql`, `psql`, `cockroach`, `cockroachdb`:
+		stmt = `SELECT current_database()`
+	case `mssql`, `sqlserver`:
+		stmt = `SELECT DB_NAME()`
+	case `clickhouse`:
+		stmt = `SELECT currentDatabase()`
+	default:
+		return nil
+	}
+
+	querylog.Debugf("[%T] %s", self, stmt)
+	var dataset sql.NullString
+
+	if err := self.db.QueryRow(stmt).Scan(&dataset); err == nil {
+		if dataset.Valid {
+			self.conn.URI.Path = `/` + dataset.String
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// generated columns are computed by the database, which rejects attempts to write to them
+func isGeneratedField(collection *dal.Collection, name string) bool {
+	if field, ok := collection.GetField(name); ok {
+		return field.Generated != ``
+	}
+
+	return false
+}
+
+// whether any of the records being inserted specify their own ID
+func recordsHaveIDs(recordset *dal.RecordSet) bool {
+	for _, record := range recordset.Records {
+		if record != nil && !typeutil.IsZero(record.ID) && fmt.Sprintf("%v", record.ID) != `0` {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the connection string options that should be given to the database driver, with the given
+// defaults applied for any options that weren't specified.  Options explicitly given an empty value
+// are omitted.
+func (self *SqlBackend) driverOptions(defaults map[string]string) url.Values {
+	opts := self.conn.PassthroughOptions(SqlBackendOptions...)
+
+	for key, value := range defaults {
+		if _, ok := opts[key]; !ok {
+			opts.Set(key, value)
+		}
+	}
+
+	for key := range opts {
+		if opts.Get(key) == `` {
+			opts.Del(key)
+		}
+	}
+
+	return opts
+}
+
 func (self *SqlBackend) getCollectionFromCache(name string) (*dal.Collection, error) {
+	if self.schemaCacheExpired(name) {
+		if err := self.refreshCollectionFromDatabase(name, self.definitionFor(name)); err != nil {
+			querylog.Debugf("[%T] failed to refresh collection %v: %v", self, name, err)
+		}
+	}
+
+	// cached collections are shared, so callers get a snapshot they can use without racing a refresh
 	if registered, ok := self.registeredCollections.Load(name); ok {
-		return registered.(*dal.Collection), nil
+		return registered.(*dal.Collection).Copy(), nil
 	} else {
 		return nil, dal.CollectionNotFound
 	}
 }
+
+// Returns statistics describing the current state of the database connection pool.
+func (self *SqlBackend) PoolStats() map[string]interface{} {
+	if self.db == nil {
+		return nil
+	}
+
+	dbstats := self.db.Stats()
+
+	return map[string]interface{}{
+		`max_open_connections`: dbstats.MaxOpenConnections,
+		`open_connections`:     dbstats.OpenConnections,
+		`in_use`:               dbstats.InUse,
+		`idle`:                 dbstats.Idle,
+		`wait_count`:           dbstats.WaitCount,
+		`wait_duration`:        dbstats.WaitDuration.String(),
+		`max_idle_closed`:      dbstats.MaxIdleClosed,
+		`max_lifetime_closed`:  dbstats.MaxLifetimeClosed,
+	}
+}
+
+// Executes the given operations inside a single transaction.  If any operation fails, the
+// transaction is rolled back and a *BatchError identifying the failed operation is returned.
+func (self *SqlBackend) Batch(operations []BatchOperation) error {
+	collections := make([]*dal.Collection, len(operations))
+
+	for i, op := range operations {
+		if err := op.Validate(); err != nil {
+			return &BatchError{i, err}
+		}
+
+		if collection, err := self.getCollectionFromCache(op.Collection); err == nil {
+			collections[i] = collection
+		} else {
+			return &BatchError{i, err}
+		}
+	}
+
+	if err := self.withTransaction(func(tx *sql.Tx) error {
+		for i, op := range operations {
+			var err error
+
+			switch op.Type {
+			case BatchInsert:
+				err = self.insertTx(tx, collections[i], dal.NewRecordSet(op.Records...))
+			case BatchUpdate:
+				err = self.updateTx(tx, collections[i], dal.NewRecordSet(op.Records...), nil)
+			case BatchDelete:
+				err = self.deleteTx(tx, collections[i], op.IDs)
+			}
+
+			if err != nil {
+				return &BatchError{i, err}
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// now that the changes are committed, bring the index up to date
+	if search := self.WithSearch(nil); search != nil {
+		for i, op := range operations {
+			var err error
+
+			switch op.Type {
+			case BatchInsert, BatchUpdate:
+				err = search.Index(collections[i], dal.NewRecordSet(op.Records...))
+			case BatchDelete:
+				err = search.IndexRemove(collections[i], op.IDs)
+			}
+
+			if err != nil {
+				querylog.Debugf("[%T] index error %v", self, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the indexers this backend writes to other than itself.
+func (self *SqlBackend) externalIndexers() []Indexer {
+	indexers := make([]Indexer, 0)
+
+	if multi, ok := self.indexer.(*MultiIndex); ok {
+		for _, indexer := range multi.indexers {
+			if indexer != Indexer(self) {
+				indexers = append(indexers, indexer)
+			}
+		}
+	} else if self.indexer != nil && self.indexer != Indexer(self) {
+		indexers = append(indexers, self.indexer)
+	}
+
+	return indexers
+}
+
+// Repopulates any external search indexes for the given collection from the records stored in the
+// database.  If the backend is its own indexer, there is nothing to rebuild.
+func (self *SqlBackend) IndexRebuild(collection *dal.Collection, options IndexRebuildOptions) (int, error) {
+	indexers := self.externalIndexers()
+
+	if len(indexers) == 0 {
+		return 0, nil
+	}
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultIndexRebuildBatchSize
+	}
+
+	if options.Clear {
+		for _, indexer := range indexers {
+			if err := clearIndex(indexer, collection, options.BatchSize); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var indexed int
+	batch := dal.NewRecordSet()
+
+	flush := func() error {
+		if len(batch.Records) == 0 {
+			return nil
+		}
+
+		for _, indexer := range indexers {
+			if err := indexer.Index(collection, batch); err != nil {
+				return err
+			}
+		}
+
+		indexed += len(batch.Records)
+		batch = dal.NewRecordSet()
+
+		if options.Progress != nil {
+			options.Progress(indexed)
+		}
+
+		return nil
+	}
+
+	f := filter.All()
+	f.IdentityField = collection.IdentityField
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		} else if record == nil {
+			return nil
+		}
+
+		batch.Push(record)
+
+		if len(batch.Records) >= options.BatchSize {
+			return flush()
+		}
+
+		return nil
+	}); err != nil {
+		return indexed, err
+	}
+
+	if err := flush(); err != nil {
+		return indexed, err
+	}
+
+	for _, indexer := range indexers {
+		if err := indexer.FlushIndex(); err != nil {
+			return indexed, err
+		}
+	}
+
+	return indexed, nil
+}
+
+// Renders the given filter into the SQL statement that would be executed to query it, and asks the
+// database to explain how it will execute that statement.  If searches are handled by a separate
+// indexer, that indexer is asked instead (or if queries are planned, the planner is).
+func (self *SqlBackend) Explain(collection *dal.Collection, f *filter.Filter) (*QueryPlan, error) {
+	if self.planQueries() {
+		return NewQueryPlanner(self, self.textIndexer, self).Explain(collection, f)
+	}
+
+	if self.indexer != Indexer(self) {
+		if explainer, ok := self.indexer.(Explainer); ok {
+			return explainer.Explain(collection, f)
+		}
+	}
+
+	return self.explainSql(collection, f)
+}
+
+func (self *SqlBackend) explainSql(collection *dal.Collection, f *filter.Filter) (*QueryPlan, error) {
+	f.IdentityField = collection.IdentityField
+	queryGen := self.makeQueryGen(collection)
+
+	if err := queryGen.Initialize(collection.Name); err != nil {
+		return nil, err
+	}
+
+	stmt, err := filter.Render(queryGen, collection.Name, f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &QueryPlan{
+		Collection: collection.Name,
+		Filter:     f.String(),
+		Backend:    self.conn.Backend(),
+		Query:      string(stmt[:]),
+		Values:     queryGen.GetValues(),
+	}
+
+	var explain string
+
+	switch self.conn.Backend() {
+	case `sqlite`:
+		explain = `EXPLAIN QUERY PLAN ` + plan.Query
+	case `mssql`, `sqlserver`:
+		// SQL Server only returns plans for statements in a separate batch from SET SHOWPLAN_ALL
+		return nil, dal.Unsupported("%T cannot explain queries made against SQL Server", self)
+	default:
+		explain = `EXPLAIN ` + plan.Query
+	}
+
+	querylog.Debugf("[%T] %s %v", self, explain, plan.Values)
+
+	if rows, err := self.db.Query(explain, plan.Values...); err == nil {
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			values := make([]interface{}, len(columns))
+			pointers := make([]interface{}, len(columns))
+
+			for i := range values {
+				pointers[i] = &values[i]
+			}
+
+			if err := rows.Scan(pointers...); err != nil {
+				return nil, err
+			}
+
+			step := make(map[string]interface{})
+
+			for i, column := range columns {
+				if v, ok := values[i].([]byte); ok {
+					step[column] = string(v)
+				} else {
+					step[column] = values[i]
+				}
+			}
+
+			plan.Plan = append(plan.Plan, step)
+		}
+
+		return plan, rows.Err()
+	} else {
+		return nil, err
+	}
+}
+
+// Errors from the various supported databases indicating that a write violated a uniqueness
+// constraint.
+var sqlUniqueViolationErrors = []string{
+	`unique constraint failed`,
+	`duplicate entry`,
+	`duplicate key value violates unique constraint`,
+	`cannot insert duplicate key`,
+	`violates primary key constraint`,
+	`violates unique constraint`,
+}
+
+// Wraps driver errors that correspond to one of the errors defined in the dal package so that callers
+// can test for them with errors.Is.  Other errors are returned unmodified.
+func translateSqlError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := strings.ToLower(err.Error())
+
+	for _, pattern := range sqlUniqueViolationErrors {
+		if strings.Contains(msg, pattern) {
+			return dal.UniqueViolation(err)
+		}
+	}
+
+	return err
+}
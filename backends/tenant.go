@@ -0,0 +1,333 @@
+package backends
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+var DefaultTenantSeparator = `__`
+
+// A TenantBackend wraps another backend and scopes all collections to a given tenant by
+// prefixing collection names with the tenant name.  Because the prefixed name is used for all
+// operations, this isolation extends to any indexers and aggregators used by the parent backend.
+//
+// So that one tenant's scoped names can never be the same as another's, tenant names may not contain
+// underscores, and collection names may not start with an underscore or contain the separator.
+//
+// Collection definitions are registered once (without a tenant prefix) in a set of definitions
+// shared by all tenants, and are registered with the parent backend under the tenant-scoped name
+// as they are first used.
+type TenantBackend struct {
+	Backend
+	tenant      string
+	separator   string
+	definitions *sync.Map
+	registered  sync.Map
+}
+
+func NewTenantBackend(parent Backend, tenant string, definitions *sync.Map) *TenantBackend {
+	if definitions == nil {
+		definitions = new(sync.Map)
+	}
+
+	return &TenantBackend{
+		Backend:     parent,
+		tenant:      tenant,
+		separator:   DefaultTenantSeparator,
+		definitions: definitions,
+	}
+}
+
+// Returns the tenant this backend is scoped to.
+func (self *TenantBackend) Tenant() string {
+	return self.tenant
+}
+
+// Returns the name of the given collection as it is known to the parent backend.
+func (self *TenantBackend) ScopedName(name string) string {
+	return self.tenant + self.separator + name
+}
+
+func (self *TenantBackend) unscopedName(name string) (string, bool) {
+	prefix := self.tenant + self.separator
+
+	if strings.HasPrefix(name, prefix) {
+		unscoped := strings.TrimPrefix(name, prefix)
+
+		if self.checkName(unscoped) == nil {
+			return unscoped, true
+		}
+	}
+
+	return ``, false
+}
+
+func (self *TenantBackend) scopedDefinition(definition *dal.Collection) *dal.Collection {
+	scoped := definition.Copy()
+	scoped.Name = self.ScopedName(definition.Name)
+
+	if definition.IndexName != `` {
+		scoped.IndexName = self.ScopedName(definition.IndexName)
+	}
+
+	return scoped
+}
+
+// Returns an error if the tenant or the given collection name could produce a scoped name that
+// belongs to another tenant.
+func (self *TenantBackend) checkName(name string) error {
+	if self.tenant == `` || strings.Contains(self.tenant, `_`) || strings.Contains(self.tenant, self.separator) {
+		return fmt.Errorf("Invalid tenant %q", self.tenant)
+	} else if name == `` || strings.HasPrefix(name, `_`) || strings.Contains(name, self.separator) {
+		return fmt.Errorf("Invalid collection name %q: names cannot start with '_' or contain %q", name, self.separator)
+	}
+
+	return nil
+}
+
+// register the tenant-scoped copy of a shared definition with the parent (if one exists), and
+// return the scoped name
+func (self *TenantBackend) scope(name string) (string, error) {
+	if err := self.checkName(name); err != nil {
+		return ``, err
+	}
+
+	if _, ok := self.registered.Load(name); !ok {
+		if definitionI, ok := self.definitions.Load(name); ok {
+			self.Backend.RegisterCollection(self.scopedDefinition(definitionI.(*dal.Collection)))
+			self.registered.Store(name, true)
+		}
+	}
+
+	return self.ScopedName(name), nil
+}
+
+func (self *TenantBackend) RegisterCollection(definition *dal.Collection) {
+	if definition != nil && self.checkName(definition.Name) == nil {
+		self.definitions.Store(definition.Name, definition)
+		self.registered.Delete(definition.Name)
+	}
+}
+
+func (self *TenantBackend) Exists(collection string, id interface{}) bool {
+	if name, err := self.scope(collection); err == nil {
+		return self.Backend.Exists(name, id)
+	}
+
+	return false
+}
+
+func (self *TenantBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	if name, err := self.scope(collection); err == nil {
+		return self.Backend.Retrieve(name, id, fields...)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *TenantBackend) Insert(collection string, records *dal.RecordSet) error {
+	if name, err := self.scope(collection); err == nil {
+		return self.Backend.Insert(name, records)
+	} else {
+		return err
+	}
+}
+
+func (self *TenantBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	if name, err := self.scope(collection); err == nil {
+		return self.Backend.Update(name, records, target...)
+	} else {
+		return err
+	}
+}
+
+func (self *TenantBackend) Delete(collection string, ids ...interface{}) error {
+	if name, err := self.scope(collection); err == nil {
+		return self.Backend.Delete(name, ids...)
+	} else {
+		return err
+	}
+}
+
+func (self *TenantBackend) CreateCollection(definition *dal.Collection) error {
+	if definition == nil {
+		return fmt.Errorf("must provide a collection definition")
+	} else if err := self.checkName(definition.Name); err != nil {
+		return err
+	}
+
+	if err := self.Backend.CreateCollection(self.scopedDefinition(definition)); err == nil {
+		self.definitions.LoadOrStore(definition.Name, definition)
+		self.registered.Store(definition.Name, true)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *TenantBackend) DeleteCollection(collection string) error {
+	if name, err := self.scope(collection); err == nil {
+		return self.Backend.DeleteCollection(name)
+	} else {
+		return err
+	}
+}
+
+// Lists the collections belonging to this tenant, with the tenant prefix removed.
+func (self *TenantBackend) ListCollections() ([]string, error) {
+	if names, err := self.Backend.ListCollections(); err == nil {
+		scoped := make([]string, 0)
+
+		for _, name := range names {
+			if unscoped, ok := self.unscopedName(name); ok {
+				scoped = append(scoped, unscoped)
+			}
+		}
+
+		return scoped, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Retrieves the tenant-scoped collection from the parent backend.  The returned collection's
+// Name is the scoped name, which allows it to be passed directly to indexers and aggregators.
+func (self *TenantBackend) GetCollection(collection string) (*dal.Collection, error) {
+	if name, err := self.scope(collection); err == nil {
+		return self.Backend.GetCollection(name)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *TenantBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.Backend.WithSearch(collection, filters...)
+}
+
+func (self *TenantBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return self.Backend.WithAggregator(collection)
+}
+
+// Applies the given schema differences to the tenant-scoped collections, provided the parent
+// backend supports migrations.
+func (self *TenantBackend) Migrate(diff []dal.SchemaDelta) error {
+	if migratable, ok := self.Backend.(Migratable); ok {
+		scoped := make([]dal.SchemaDelta, len(diff))
+
+		for i, delta := range diff {
+			if name, err := self.scope(delta.Collection); err == nil {
+				delta.Collection = name
+				scoped[i] = delta
+			} else {
+				return err
+			}
+		}
+
+		return migratable.Migrate(scoped)
+	} else {
+		return dal.Unsupported("Backend %T does not support schema changes", self.Backend)
+	}
+}
+
+// Executes the given operations against the tenant-scoped collections, provided the parent
+// backend supports atomic batches.
+func (self *TenantBackend) Batch(operations []BatchOperation) error {
+	if batcher, ok := self.Backend.(Batcher); ok {
+		scoped := make([]BatchOperation, len(operations))
+
+		for i, op := range operations {
+			if name, err := self.scope(op.Collection); err == nil {
+				op.Collection = name
+				scoped[i] = op
+			} else {
+				return &BatchError{
+					Index: i,
+					Err:   err,
+				}
+			}
+		}
+
+		return batcher.Batch(scoped)
+	} else {
+		return dal.Unsupported("Backend %T does not support atomic batches", self.Backend)
+	}
+}
+
+func (self *TenantBackend) WriteBlob(collection string, id interface{}, field string, reader io.Reader) error {
+	if streamer, ok := self.Backend.(BlobStreamer); ok {
+		if name, err := self.scope(collection); err == nil {
+			return streamer.WriteBlob(name, id, field, reader)
+		} else {
+			return err
+		}
+	} else {
+		return NotImplementedError
+	}
+}
+
+func (self *TenantBackend) ReadBlob(collection string, id interface{}, field string) (io.ReadSeeker, int64, error) {
+	if streamer, ok := self.Backend.(BlobStreamer); ok {
+		if name, err := self.scope(collection); err == nil {
+			return streamer.ReadBlob(name, id, field)
+		} else {
+			return nil, 0, err
+		}
+	} else {
+		return nil, 0, NotImplementedError
+	}
+}
+
+func (self *TenantBackend) IndexRebuild(collection *dal.Collection, options IndexRebuildOptions) (int, error) {
+	if rebuilder, ok := self.Backend.(IndexRebuilder); ok {
+		return rebuilder.IndexRebuild(self.scopedDefinition(collection), options)
+	} else {
+		return 0, NotImplementedError
+	}
+}
+
+// Watches the parent backend's change feed (if it has one) or polls for changes otherwise.
+func (self *TenantBackend) Watch(ctx context.Context, collection *dal.Collection, f *filter.Filter) (<-chan *ChangeEvent, error) {
+	watcher, ok := self.Backend.(Watcher)
+
+	if !ok {
+		return pollForChanges(ctx, self, collection, f, WatchOptions{})
+	}
+
+	if scoped, err := watcher.Watch(ctx, self.scopedDefinition(collection), f); err == nil {
+		events := make(chan *ChangeEvent)
+
+		go func() {
+			defer close(events)
+
+			for event := range scoped {
+				if name, ok := self.unscopedName(event.Collection); ok {
+					event.Collection = name
+				}
+
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return events, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *TenantBackend) Explain(collection *dal.Collection, f *filter.Filter) (*QueryPlan, error) {
+	if explainer, ok := self.Backend.(Explainer); ok {
+		return explainer.Explain(self.scopedDefinition(collection), f)
+	} else {
+		return nil, NotImplementedError
+	}
+}
@@ -0,0 +1,194 @@
+package backends
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// The upper bounds of the buckets that operation timings are counted in.  Timings longer than the
+// last bound are counted in a final, unbounded bucket.
+var TimingBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+type TimingBucket struct {
+	UpperBound time.Duration `json:"upper_bound"` // zero for the unbounded bucket
+	Count      int64         `json:"count"`
+}
+
+// A histogram of how long a particular operation has taken.
+type TimingHistogram struct {
+	Name    string         `json:"name"`
+	Count   int64          `json:"count"`
+	Total   time.Duration  `json:"total"`
+	Min     time.Duration  `json:"min"`
+	Max     time.Duration  `json:"max"`
+	Buckets []TimingBucket `json:"buckets"`
+}
+
+// Returns the mean duration of the operation.
+func (self *TimingHistogram) Mean() time.Duration {
+	if self.Count == 0 {
+		return 0
+	}
+
+	return self.Total / time.Duration(self.Count)
+}
+
+// Returns an estimate of the given percentile (0.0-1.0), which is the upper bound of the bucket that
+// the percentile falls in (or the maximum, if that is smaller).
+func (self *TimingHistogram) Percentile(p float64) time.Duration {
+	if self.Count == 0 {
+		return 0
+	}
+
+	target := int64(float64(self.Count) * p)
+	var seen int64
+
+	for _, bucket := range self.Buckets {
+		seen += bucket.Count
+
+		if seen > target || seen == self.Count {
+			if bucket.UpperBound == 0 || bucket.UpperBound > self.Max {
+				return self.Max
+			}
+
+			return bucket.UpperBound
+		}
+	}
+
+	return self.Max
+}
+
+func (self *TimingHistogram) record(took time.Duration) {
+	if self.Count == 0 || took < self.Min {
+		self.Min = took
+	}
+
+	if took > self.Max {
+		self.Max = took
+	}
+
+	self.Count += 1
+	self.Total += took
+
+	for i, bucket := range self.Buckets {
+		if bucket.UpperBound == 0 || took <= bucket.UpperBound {
+			self.Buckets[i].Count += 1
+			return
+		}
+	}
+}
+
+// Collects timing histograms for named operations.
+type OperationTimings struct {
+	histograms map[string]*TimingHistogram
+	lock       sync.Mutex
+}
+
+func NewOperationTimings() *OperationTimings {
+	return &OperationTimings{
+		histograms: make(map[string]*TimingHistogram),
+	}
+}
+
+// The timings recorded by the backends and indexers in this package, keyed on the same names that
+// are sent to statsd.
+var Timings = NewOperationTimings()
+
+// Adds a timing for the named operation.
+func (self *OperationTimings) Record(name string, took time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	histogram, ok := self.histograms[name]
+
+	if !ok {
+		histogram = &TimingHistogram{
+			Name:    name,
+			Buckets: make([]TimingBucket, len(TimingBuckets)+1),
+		}
+
+		for i, bound := range TimingBuckets {
+			histogram.Buckets[i].UpperBound = bound
+		}
+
+		self.histograms[name] = histogram
+	}
+
+	histogram.record(took)
+}
+
+// Returns a copy of the histogram for the named operation.
+func (self *OperationTimings) Get(name string) (TimingHistogram, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if histogram, ok := self.histograms[name]; ok {
+		return histogram.copy(), true
+	}
+
+	return TimingHistogram{}, false
+}
+
+// Returns copies of all histograms, sorted by name.
+func (self *OperationTimings) Snapshot() []TimingHistogram {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	snapshot := make([]TimingHistogram, 0, len(self.histograms))
+
+	for _, histogram := range self.histograms {
+		snapshot = append(snapshot, histogram.copy())
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Name < snapshot[j].Name
+	})
+
+	return snapshot
+}
+
+// Discards all recorded timings.
+func (self *OperationTimings) Reset() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.histograms = make(map[string]*TimingHistogram)
+}
+
+func (self *TimingHistogram) copy() TimingHistogram {
+	out := *self
+	out.Buckets = make([]TimingBucket, len(self.Buckets))
+	copy(out.Buckets, self.Buckets)
+
+	return out
+}
+
+// Starts timing the named operation; the returned function records the timing (in Timings and statsd)
+// when called, and is intended to be deferred.
+func timeOperation(name string) func() {
+	timing := stats.NewTiming()
+	started := time.Now()
+
+	return func() {
+		timing.Send(name)
+		Timings.Record(name, time.Since(started))
+	}
+}
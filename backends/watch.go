@@ -0,0 +1,192 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+var DefaultWatchPollInterval = time.Duration(2) * time.Second
+
+type ChangeType string
+
+const (
+	ChangeCreate ChangeType = `create`
+	ChangeUpdate ChangeType = `update`
+	ChangeDelete ChangeType = `delete`
+)
+
+// Describes a change made to a record in a collection.
+type ChangeEvent struct {
+	Type       ChangeType  `json:"type"`
+	Collection string      `json:"collection"`
+	ID         interface{} `json:"id"`
+	Record     *dal.Record `json:"record,omitempty"`
+	Timestamp  time.Time   `json:"timestamp"`
+}
+
+// Implemented by backends that can natively notify subscribers of changes to records as they
+// happen.  Events are delivered on the returned channel until the given context is cancelled, at
+// which point the channel is closed.
+type Watcher interface {
+	Watch(ctx context.Context, collection *dal.Collection, f *filter.Filter) (<-chan *ChangeEvent, error)
+}
+
+type WatchOptions struct {
+	// How often to query for changes when the backend does not support watching natively.
+	PollInterval time.Duration
+
+	// Whether to emit a create event for every matching record that exists when the watch starts.
+	// Only applies to polling.
+	Initial bool
+}
+
+// Returns a channel of changes made to records in the given collection that match the given filter.
+// If the backend implements Watcher, its change feed is used unless it returns NotImplementedError.
+// Otherwise, the collection is queried periodically and successive results are compared; in this
+// case, a record that no longer matches the filter is reported as deleted.
+func Watch(ctx context.Context, backend Backend, collection *dal.Collection, f *filter.Filter, options WatchOptions) (<-chan *ChangeEvent, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	// backends may only be able to watch some collections (or connections) natively
+	if watcher, ok := backend.(Watcher); ok {
+		if events, err := watcher.Watch(ctx, collection, f); err != NotImplementedError {
+			return events, err
+		}
+	}
+
+	return pollForChanges(ctx, backend, collection, f, options)
+}
+
+func pollForChanges(ctx context.Context, backend Backend, collection *dal.Collection, f *filter.Filter, options WatchOptions) (<-chan *ChangeEvent, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	search := backend.WithSearch(collection, f)
+
+	if search == nil {
+		return nil, dal.Unsupported("Backend %T does not support watching or querying collection %q", backend, collection.Name)
+	}
+
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultWatchPollInterval
+	}
+
+	f.IdentityField = collection.IdentityField
+	f.Limit = 0
+
+	// take a snapshot up front so that errors in the query are reported to the caller
+	previous, err := watchSnapshot(search, collection, f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *ChangeEvent)
+
+	go func() {
+		defer close(events)
+
+		emit := func(changeType ChangeType, id interface{}, record *dal.Record) bool {
+			select {
+			case events <- &ChangeEvent{
+				Type:       changeType,
+				Collection: collection.Name,
+				ID:         id,
+				Record:     record,
+				Timestamp:  time.Now(),
+			}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if options.Initial {
+			for _, snap := range previous {
+				if !emit(ChangeCreate, snap.record.ID, snap.record) {
+					return
+				}
+			}
+		}
+
+		ticker := time.NewTicker(options.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := watchSnapshot(search, collection, f)
+
+			if err != nil {
+				log.Warningf("[%T] watch %s: %v", backend, collection.Name, err)
+				continue
+			}
+
+			for key, snap := range current {
+				if prev, ok := previous[key]; !ok {
+					if !emit(ChangeCreate, snap.record.ID, snap.record) {
+						return
+					}
+				} else if prev.fingerprint != snap.fingerprint {
+					if !emit(ChangeUpdate, snap.record.ID, snap.record) {
+						return
+					}
+				}
+			}
+
+			for key, prev := range previous {
+				if _, ok := current[key]; !ok {
+					if !emit(ChangeDelete, prev.record.ID, nil) {
+						return
+					}
+				}
+			}
+
+			previous = current
+		}
+	}()
+
+	return events, nil
+}
+
+type watchedRecord struct {
+	record      *dal.Record
+	fingerprint string
+}
+
+func watchSnapshot(search Indexer, collection *dal.Collection, f *filter.Filter) (map[string]watchedRecord, error) {
+	snapshot := make(map[string]watchedRecord)
+
+	err := search.QueryFunc(collection, f, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		} else if record == nil {
+			return nil
+		}
+
+		if data, err := json.Marshal(record.Fields); err == nil {
+			snapshot[fmt.Sprintf("%v", record.ID)] = watchedRecord{
+				record:      record,
+				fingerprint: string(data),
+			}
+
+			return nil
+		} else {
+			return err
+		}
+	})
+
+	return snapshot, err
+}
@@ -0,0 +1,177 @@
+package backends
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// a mock backend with a native change feed, or none if events is nil
+type watchingBackend struct {
+	*MockBackend
+	events chan *ChangeEvent
+}
+
+func (self *watchingBackend) Watch(ctx context.Context, collection *dal.Collection, f *filter.Filter) (<-chan *ChangeEvent, error) {
+	if self.events == nil {
+		return nil, NotImplementedError
+	}
+
+	return self.events, nil
+}
+
+func newWatchedBackend() (*MockBackend, *dal.Collection, error) {
+	mock := NewMockBackend()
+	collection := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	if err := mock.CreateCollection(collection); err != nil {
+		return nil, nil, err
+	}
+
+	return mock, collection, mock.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `alice`),
+		dal.NewRecord(2).Set(`name`, `bob`),
+	))
+}
+
+// waits for the next event, failing if none arrives in time
+func nextChange(events <-chan *ChangeEvent) *ChangeEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+func TestWatchPolling(t *testing.T) {
+	assert := require.New(t)
+
+	mock, collection, err := newWatchedBackend()
+	assert.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := Watch(ctx, mock, collection, nil, WatchOptions{
+		PollInterval: 10 * time.Millisecond,
+		Initial:      true,
+	})
+
+	assert.NoError(err)
+
+	// existing records are reported first
+	seen := make(map[interface{}]ChangeType)
+
+	for i := 0; i < 2; i++ {
+		event := nextChange(events)
+		assert.NotNil(event)
+		assert.Equal(`users`, event.Collection)
+		seen[event.ID] = event.Type
+	}
+
+	assert.Equal(map[interface{}]ChangeType{1: ChangeCreate, 2: ChangeCreate}, seen)
+
+	assert.NoError(mock.Insert(`users`, dal.NewRecordSet(dal.NewRecord(3).Set(`name`, `carol`))))
+
+	event := nextChange(events)
+	assert.NotNil(event)
+	assert.Equal(ChangeCreate, event.Type)
+	assert.EqualValues(3, event.ID)
+	assert.Equal(`carol`, event.Record.Get(`name`))
+
+	assert.NoError(mock.Update(`users`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `alicia`))))
+
+	event = nextChange(events)
+	assert.NotNil(event)
+	assert.Equal(ChangeUpdate, event.Type)
+	assert.EqualValues(1, event.ID)
+	assert.Equal(`alicia`, event.Record.Get(`name`))
+
+	assert.NoError(mock.Delete(`users`, 2))
+
+	event = nextChange(events)
+	assert.NotNil(event)
+	assert.Equal(ChangeDelete, event.Type)
+	assert.EqualValues(2, event.ID)
+	assert.Nil(event.Record)
+
+	// the channel is closed once the context is cancelled
+	cancel()
+
+	for range events {
+	}
+}
+
+func TestWatchPollingFilter(t *testing.T) {
+	assert := require.New(t)
+
+	mock, collection, err := newWatchedBackend()
+	assert.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := Watch(ctx, mock, collection, filter.MustParse(`name/alice`), WatchOptions{
+		PollInterval: 10 * time.Millisecond,
+	})
+
+	assert.NoError(err)
+
+	// records that stop matching the filter are reported as deleted
+	assert.NoError(mock.Update(`users`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `alicia`))))
+
+	event := nextChange(events)
+	assert.NotNil(event)
+	assert.Equal(ChangeDelete, event.Type)
+	assert.EqualValues(1, event.ID)
+
+	// query errors are reported up front
+	mock.FailWith(`QueryFunc`, dal.Unsupported("no queries"))
+
+	_, err = Watch(ctx, mock, collection, nil, WatchOptions{})
+	assert.Error(err)
+}
+
+func TestWatchNative(t *testing.T) {
+	assert := require.New(t)
+
+	mock, collection, err := newWatchedBackend()
+	assert.NoError(err)
+
+	backend := &watchingBackend{
+		MockBackend: mock,
+		events:      make(chan *ChangeEvent, 1),
+	}
+
+	backend.events <- &ChangeEvent{
+		Type: ChangeDelete,
+		ID:   5,
+	}
+
+	events, err := Watch(context.Background(), backend, collection, nil, WatchOptions{})
+	assert.NoError(err)
+
+	event := nextChange(events)
+	assert.NotNil(event)
+	assert.Equal(ChangeDelete, event.Type)
+	assert.Equal(5, event.ID)
+	assert.Empty(mock.CallsTo(`QueryFunc`))
+
+	// backends that can't watch natively fall back to polling
+	backend.events = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = Watch(ctx, backend, collection, nil, WatchOptions{})
+	assert.NoError(err)
+	assert.Len(mock.CallsTo(`QueryFunc`), 1)
+}
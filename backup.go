@@ -0,0 +1,241 @@
+package pivot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+var BackupFormatVersion = 1
+var DefaultRestoreBatchSize = 100
+
+type backupEntryType string
+
+const (
+	backupHeader     backupEntryType = `header`
+	backupCollection backupEntryType = `collection`
+	backupRecord     backupEntryType = `record`
+)
+
+// A backup is written as newline-delimited JSON: a header entry, followed by each collection's
+// definition and then its records.
+type backupEntry struct {
+	Type       backupEntryType `json:"type"`
+	Version    int             `json:"version,omitempty"`
+	CreatedAt  *time.Time      `json:"created_at,omitempty"`
+	Collection string          `json:"collection,omitempty"`
+	Definition *dal.Collection `json:"definition,omitempty"`
+	Record     *dal.Record     `json:"record,omitempty"`
+}
+
+// Called periodically during a backup or restore with the number of records processed so far for
+// the given collection.
+type ProgressFunc func(collection string, records int)
+
+type BackupOptions struct {
+	Collections []string
+	Progress    ProgressFunc
+}
+
+type RestoreOptions struct {
+	Collections      []string
+	CreateMissing    bool
+	BatchSize        int
+	Progress         ProgressFunc
+	SkipSchemaChecks bool
+}
+
+// Writes the definitions and records of the given collections (or all collections, if none are
+// specified) to the given writer.
+func Backup(db backends.Backend, w io.Writer, options BackupOptions) error {
+	encoder := json.NewEncoder(w)
+	now := time.Now()
+	collections := options.Collections
+
+	if len(collections) == 0 {
+		if names, err := db.ListCollections(); err == nil {
+			collections = names
+		} else {
+			return err
+		}
+	}
+
+	if err := encoder.Encode(&backupEntry{
+		Type:      backupHeader,
+		Version:   BackupFormatVersion,
+		CreatedAt: &now,
+	}); err != nil {
+		return err
+	}
+
+	for _, name := range collections {
+		collection, err := db.GetCollection(name)
+
+		if err != nil {
+			return fmt.Errorf("collection %q: %v", name, err)
+		}
+
+		if err := encoder.Encode(&backupEntry{
+			Type:       backupCollection,
+			Collection: name,
+			Definition: collection,
+		}); err != nil {
+			return err
+		}
+
+		search := db.WithSearch(collection)
+
+		if search == nil {
+			return dal.Unsupported("collection %q: backend %T does not support enumerating records", name, db)
+		}
+
+		var count int
+		f := filter.All()
+		f.IdentityField = collection.IdentityField
+
+		if _, err := search.Query(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+			if err != nil {
+				return err
+			}
+
+			if err := encoder.Encode(&backupEntry{
+				Type:       backupRecord,
+				Collection: name,
+				Record:     record,
+			}); err != nil {
+				return err
+			}
+
+			count += 1
+
+			if options.Progress != nil {
+				options.Progress(name, count)
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("collection %q: %v", name, err)
+		}
+
+		if options.Progress != nil {
+			options.Progress(name, count)
+		}
+	}
+
+	return nil
+}
+
+// Reads a backup produced by Backup and writes its contents to the given backend.  If specific
+// collections are given, only those collections are restored.
+func Restore(db backends.Backend, r io.Reader, options RestoreOptions) error {
+	var current string
+	var count int
+	var skip bool
+
+	batchSize := options.BatchSize
+
+	if batchSize <= 0 {
+		batchSize = DefaultRestoreBatchSize
+	}
+
+	batch := dal.NewRecordSet()
+	include := make(map[string]bool)
+
+	for _, name := range options.Collections {
+		include[name] = true
+	}
+
+	flush := func() error {
+		if len(batch.Records) > 0 {
+			if err := db.Insert(current, batch); err != nil {
+				return fmt.Errorf("collection %q: %v", current, err)
+			}
+
+			count += len(batch.Records)
+			batch = dal.NewRecordSet()
+
+			if options.Progress != nil {
+				options.Progress(current, count)
+			}
+		}
+
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+
+	for scanner.Scan() {
+		var entry backupEntry
+		line += 1
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+
+		switch entry.Type {
+		case backupHeader:
+			if entry.Version > BackupFormatVersion {
+				return fmt.Errorf("unsupported backup version %d", entry.Version)
+			}
+
+		case backupCollection:
+			if err := flush(); err != nil {
+				return err
+			}
+
+			current = entry.Collection
+			count = 0
+			skip = len(include) > 0 && !include[current]
+
+			if skip || entry.Definition == nil {
+				continue
+			}
+
+			if existing, err := db.GetCollection(current); err == nil {
+				if !options.SkipSchemaChecks {
+					if diffs := existing.Diff(entry.Definition); len(diffs) > 0 {
+						return fmt.Errorf("collection %q: schema differs from backup (%d differences)", current, len(diffs))
+					}
+				}
+			} else if dal.IsCollectionNotFoundErr(err) && options.CreateMissing {
+				if err := db.CreateCollection(entry.Definition); err != nil {
+					return fmt.Errorf("collection %q: %v", current, err)
+				}
+			} else {
+				return fmt.Errorf("collection %q: %v", current, err)
+			}
+
+		case backupRecord:
+			if skip || entry.Record == nil {
+				continue
+			} else if entry.Collection != current {
+				return fmt.Errorf("line %d: record for collection %q appears outside of its collection", line, entry.Collection)
+			}
+
+			batch.Push(entry.Record)
+
+			if len(batch.Records) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+
+		default:
+			return fmt.Errorf("line %d: unknown entry type %q", line, entry.Type)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
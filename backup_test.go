@@ -0,0 +1,137 @@
+package pivot
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a mock backend with two collections containing the given number of records each
+func newBackupSource(records int) (*backends.MockBackend, error) {
+	mock := backends.NewMockBackend()
+
+	for _, name := range []string{`users`, `groups`} {
+		if err := mock.CreateCollection(dal.NewCollection(name).AddFields(dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		})); err != nil {
+			return nil, err
+		}
+
+		recordset := dal.NewRecordSet()
+
+		for i := 1; i <= records; i++ {
+			recordset.Push(dal.NewRecord(i).Set(`name`, name))
+		}
+
+		if err := mock.Insert(name, recordset); err != nil {
+			return nil, err
+		}
+	}
+
+	return mock, nil
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	assert := require.New(t)
+	var buf bytes.Buffer
+
+	source, err := newBackupSource(5)
+	assert.NoError(err)
+
+	progress := make(map[string]int)
+
+	assert.NoError(Backup(source, &buf, BackupOptions{
+		Progress: func(collection string, records int) {
+			progress[collection] = records
+		},
+	}))
+
+	assert.Equal(map[string]int{`users`: 5, `groups`: 5}, progress)
+
+	// header, then each collection followed by its records
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(lines, 13)
+
+	var entry backupEntry
+	assert.NoError(json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(backupHeader, entry.Type)
+	assert.Equal(BackupFormatVersion, entry.Version)
+
+	target := backends.NewMockBackend()
+
+	assert.NoError(Restore(target, bytes.NewReader(buf.Bytes()), RestoreOptions{
+		CreateMissing: true,
+		BatchSize:     2,
+	}))
+
+	// 5 records in batches of 2, for each of 2 collections
+	assert.Len(target.CallsTo(`Insert`), 6)
+
+	for _, name := range []string{`users`, `groups`} {
+		for i := 1; i <= 5; i++ {
+			record, err := target.Retrieve(name, i)
+			assert.NoError(err)
+			assert.Equal(name, record.Get(`name`))
+		}
+	}
+}
+
+func TestRestoreOptions(t *testing.T) {
+	assert := require.New(t)
+	var buf bytes.Buffer
+
+	source, err := newBackupSource(2)
+	assert.NoError(err)
+	assert.NoError(Backup(source, &buf, BackupOptions{
+		Collections: []string{`users`, `groups`},
+	}))
+
+	// missing collections aren't created unless asked
+	target := backends.NewMockBackend()
+	assert.Error(Restore(target, bytes.NewReader(buf.Bytes()), RestoreOptions{}))
+
+	// only the given collections are restored
+	assert.NoError(Restore(target, bytes.NewReader(buf.Bytes()), RestoreOptions{
+		Collections:   []string{`groups`},
+		CreateMissing: true,
+	}))
+
+	names, err := target.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`groups`}, names)
+
+	// existing collections must match the backup's definitions
+	target = backends.NewMockBackend()
+	assert.NoError(target.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.IntType,
+	})))
+
+	assert.Error(Restore(target, bytes.NewReader(buf.Bytes()), RestoreOptions{
+		Collections: []string{`users`},
+	}))
+
+	assert.NoError(Restore(target, bytes.NewReader(buf.Bytes()), RestoreOptions{
+		Collections:      []string{`users`},
+		SkipSchemaChecks: true,
+	}))
+}
+
+func TestRestoreInvalidInput(t *testing.T) {
+	assert := require.New(t)
+
+	for _, input := range []string{
+		`not json`,
+		`{"type":"header","version":99}`,
+		`{"type":"mystery"}`,
+		"{\"type\":\"collection\",\"collection\":\"users\"}\n{\"type\":\"record\",\"collection\":\"groups\",\"record\":{\"id\":1}}",
+	} {
+		assert.Error(Restore(backends.NewMockBackend(), strings.NewReader(input), RestoreOptions{}), input)
+	}
+}
@@ -0,0 +1,113 @@
+package pivot
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal/encoding"
+)
+
+type BatchRequest struct {
+	Operations []backends.BatchOperation `json:"operations"`
+}
+
+type BatchResult struct {
+	Index      int                         `json:"index"`
+	Type       backends.BatchOperationType `json:"type"`
+	Collection string                      `json:"collection"`
+	Count      int                         `json:"count"`
+}
+
+// Executes an ordered list of inserts, updates, and deletes (possibly spanning several
+// collections) as a single transaction.  Either every operation is applied and a result is
+// returned for each, or none are and the failed operation is identified in the response.
+func (self *Server) handleBatch(w http.ResponseWriter, req *http.Request) {
+	var batch BatchRequest
+
+	if err := parseRequest(req, &batch); err != nil {
+		respond(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	if len(batch.Operations) == 0 {
+		respond(w, req, fmt.Errorf("Batch must contain at least one operation"), http.StatusBadRequest)
+		return
+	}
+
+	for i, op := range batch.Operations {
+		if err := op.Validate(); err != nil {
+			respond(w, req, map[string]interface{}{
+				`error`:            err.Error(),
+				`failed_operation`: i,
+			}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	problems := make(encoding.SchemaErrors, 0)
+
+	for i, op := range batch.Operations {
+		if len(op.Records) == 0 {
+			continue
+		}
+
+		if opProblems, err := self.payloadProblems(req, op.Collection, op.Records, op.Type == backends.BatchUpdate); err == nil {
+			for _, problem := range opProblems {
+				if len(op.Records) > 1 {
+					problem.Path = fmt.Sprintf("/operations/%d%s", i, problem.Path)
+				} else {
+					problem.Path = fmt.Sprintf("/operations/%d/records/0%s", i, problem.Path)
+				}
+
+				problems = append(problems, problem)
+			}
+		} else {
+			respond(w, req, err)
+			return
+		}
+	}
+
+	if len(problems) > 0 {
+		respondSchemaErrors(w, req, problems)
+		return
+	}
+
+	batcher, ok := self.db(req).(backends.Batcher)
+
+	if !ok {
+		respond(w, req, fmt.Errorf("Backend %T does not support atomic batches", self.db(req)), http.StatusNotImplemented)
+		return
+	}
+
+	querylog.Debugf("[%s] batch: %d operations", GetRequestId(req), len(batch.Operations))
+
+	if err := batcher.Batch(batch.Operations); err == nil {
+		results := make([]BatchResult, len(batch.Operations))
+		total := 0
+
+		for i, op := range batch.Operations {
+			results[i] = BatchResult{
+				Index:      i,
+				Type:       op.Type,
+				Collection: op.Collection,
+				Count:      op.Count(),
+			}
+
+			total += op.Count()
+		}
+
+		setRequestRecordCount(req, total)
+
+		respond(w, req, map[string]interface{}{
+			`results`: results,
+		})
+	} else if batchErr, ok := err.(*backends.BatchError); ok {
+		respond(w, req, map[string]interface{}{
+			`error`:            batchErr.Err.Error(),
+			`failed_operation`: batchErr.Index,
+		}, http.StatusUnprocessableEntity)
+	} else {
+		respond(w, req, err)
+	}
+}
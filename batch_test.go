@@ -0,0 +1,154 @@
+package pivot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// a MockBackend that records the batches given to it instead of executing them
+type testBatcher struct {
+	*backends.MockBackend
+	batches [][]backends.BatchOperation
+	err     error
+}
+
+func (self *testBatcher) Batch(operations []backends.BatchOperation) error {
+	self.batches = append(self.batches, operations)
+	return self.err
+}
+
+func TestHandleBatch(t *testing.T) {
+	assert := require.New(t)
+	var batcher *testBatcher
+
+	_, _, handler := newTestServer(func(server *Server) {
+		batcher = &testBatcher{
+			MockBackend: server.backend.(*backends.MockBackend),
+		}
+
+		server.backend = batcher
+	})
+
+	w := testRequest(handler, `POST`, `/api/batch`, `{
+		"operations": [
+			{"type": "insert", "collection": "users", "records": [{"id": 1}, {"id": 2}]},
+			{"type": "delete", "collection": "groups", "ids": [3]}
+		]
+	}`)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Len(batcher.batches, 1)
+	assert.Len(batcher.batches[0], 2)
+	assert.Equal(backends.BatchInsert, batcher.batches[0][0].Type)
+	assert.Equal(`groups`, batcher.batches[0][1].Collection)
+
+	var out struct {
+		Results []BatchResult `json:"results"`
+	}
+
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &out))
+	assert.Equal([]BatchResult{
+		{Index: 0, Type: backends.BatchInsert, Collection: `users`, Count: 2},
+		{Index: 1, Type: backends.BatchDelete, Collection: `groups`, Count: 1},
+	}, out.Results)
+
+	// failed operations are identified
+	batcher.err = &backends.BatchError{
+		Index: 1,
+		Err:   fmt.Errorf("not found"),
+	}
+
+	w = testRequest(handler, `POST`, `/api/batch`, `{"operations": [{"type": "delete", "collection": "users", "ids": [1]}]}`)
+	assert.Equal(http.StatusUnprocessableEntity, w.Code)
+
+	var failure map[string]interface{}
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &failure))
+	assert.Equal(`not found`, failure[`error`])
+	assert.EqualValues(1, failure[`failed_operation`])
+
+	// empty and malformed batches are refused
+	assert.Equal(http.StatusBadRequest, testRequest(handler, `POST`, `/api/batch`, `{"operations": []}`).Code)
+	assert.Equal(http.StatusBadRequest, testRequest(handler, `POST`, `/api/batch`, `{"operations":`).Code)
+	assert.Len(batcher.batches, 2)
+
+	// invalid operations are identified without running any of the batch
+	for _, op := range []string{
+		`{"type": "insert", "records": [{"id": 1}]}`,
+		`{"type": "insert", "collection": "users"}`,
+		`{"type": "delete", "collection": "users"}`,
+		`{"type": "upsert", "collection": "users", "records": [{"id": 1}]}`,
+	} {
+		w = testRequest(handler, `POST`, `/api/batch`, `{"operations": [
+			{"type": "delete", "collection": "users", "ids": [1]},
+			`+op+`
+		]}`)
+
+		assert.Equal(http.StatusBadRequest, w.Code, op)
+
+		failure = nil
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &failure))
+		assert.NotEmpty(failure[`error`])
+		assert.EqualValues(1, failure[`failed_operation`])
+	}
+
+	assert.Len(batcher.batches, 2)
+}
+
+func TestHandleBatchUnsupported(t *testing.T) {
+	assert := require.New(t)
+	_, _, handler := newTestServer()
+
+	w := testRequest(handler, `POST`, `/api/batch`, `{"operations": [{"type": "delete", "collection": "users", "ids": [1]}]}`)
+	assert.Equal(http.StatusNotImplemented, w.Code)
+}
+
+func TestHandleBatchValidation(t *testing.T) {
+	assert := require.New(t)
+	var batcher *testBatcher
+
+	_, mock, handler := newTestServer(func(server *Server) {
+		batcher = &testBatcher{
+			MockBackend: server.backend.(*backends.MockBackend),
+		}
+
+		server.backend = batcher
+		server.ValidatePayloads = true
+	})
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name:     `name`,
+		Type:     dal.StringType,
+		Required: true,
+	})))
+
+	w := testRequest(handler, `POST`, `/api/batch`, `{
+		"operations": [
+			{"type": "insert", "collection": "users", "records": [{"id": 1, "fields": {"name": 5}}]},
+			{"type": "insert", "collection": "users", "records": [{"id": 2, "fields": {"name": "b"}}, {"id": 3}]},
+			{"type": "update", "collection": "users", "records": [{"id": 2}]}
+		]
+	}`)
+
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Empty(batcher.batches)
+
+	var out struct {
+		Errors []map[string]interface{} `json:"errors"`
+	}
+
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &out))
+	assert.Len(out.Errors, 2)
+	assert.Equal(`/operations/0/records/0/fields/name`, out.Errors[0][`path`])
+	assert.Equal(`/operations/1/records/1/fields/name`, out.Errors[1][`path`])
+
+	// partial records are allowed when updating
+	w = testRequest(handler, `POST`, `/api/batch`, `{"operations": [{"type": "update", "collection": "users", "records": [{"id": 2}]}]}`)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Len(batcher.batches, 1)
+}
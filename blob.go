@@ -0,0 +1,209 @@
+package pivot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/husobee/vestigo"
+)
+
+// The largest blob that will be accepted by backends that do not support streaming blobs, since
+// these uploads must be buffered in memory before being written.
+var MaxBufferedBlobSize int64 = 64 * 1024 * 1024
+
+// Retrieves the collection and RawType field being addressed by a blob request, writing an
+// appropriate error response if either is invalid.
+func (self *Server) blobField(w http.ResponseWriter, req *http.Request) (string, string, *dal.Field, bool) {
+	name := vestigo.Param(req, `collection`)
+	fieldName := vestigo.Param(req, `field`)
+
+	if collection, err := self.db(req).GetCollection(name); err == nil {
+		if field, ok := collection.GetField(fieldName); ok {
+			if field.Type != dal.RawType {
+				respond(w, req, fmt.Errorf("Field %q is not a raw field", fieldName), http.StatusBadRequest)
+				return ``, ``, nil, false
+			}
+
+			return name, fieldName, &field, true
+		} else {
+			respond(w, req, fmt.Errorf("Collection %q has no field %q", name, fieldName), http.StatusNotFound)
+		}
+	} else if dal.IsCollectionNotFoundErr(err) {
+		respond(w, req, err, http.StatusNotFound)
+	} else {
+		respond(w, req, err)
+	}
+
+	return ``, ``, nil, false
+}
+
+// Serves the contents of a RawType field as an attachment, supporting ranged requests.  The content
+// type is detected from the content itself, and clients are told not to second-guess it, so that
+// stored content can't be rendered by a browser as a page served from the API's origin.
+func (self *Server) handleBlobDownload(w http.ResponseWriter, req *http.Request) {
+	var reader io.ReadSeeker
+
+	name, fieldName, field, ok := self.blobField(w, req)
+
+	if !ok {
+		return
+	} else if field.Hidden || field.Redacted {
+		respond(w, req, fmt.Errorf("Field %q cannot be retrieved", fieldName), http.StatusForbidden)
+		return
+	}
+
+	id := vestigo.Param(req, `id`)
+
+	if streamer, ok := self.db(req).(backends.BlobStreamer); ok {
+		if r, _, err := streamer.ReadBlob(name, id, fieldName); err == nil {
+			reader = r
+		} else if err != backends.NotImplementedError {
+			respond(w, req, err)
+			return
+		}
+	}
+
+	// fall back to retrieving the field value from the record
+	if reader == nil {
+		if record, err := self.db(req).Retrieve(name, id, fieldName); err == nil {
+			switch v := record.Get(fieldName).(type) {
+			case []byte:
+				reader = bytes.NewReader(v)
+			case string:
+				reader = strings.NewReader(v)
+			case nil:
+				reader = bytes.NewReader(nil)
+			default:
+				respond(w, req, fmt.Errorf("Field %q contains %T, not raw data", fieldName, v))
+				return
+			}
+		} else if dal.IsNotExistError(err) {
+			respond(w, req, err, http.StatusNotFound)
+			return
+		} else {
+			respond(w, req, err)
+			return
+		}
+	}
+
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	w.Header().Set(`X-Content-Type-Options`, `nosniff`)
+	w.Header().Set(`Content-Disposition`, mime.FormatMediaType(`attachment`, map[string]string{
+		`filename`: fieldName,
+	}))
+
+	setRequestRecordCount(req, 1)
+	http.ServeContent(w, req, fieldName, time.Time{}, reader)
+}
+
+// Writes the request body to a RawType field.  The body may either be the raw content itself, or
+// a multipart/form-data upload, in which case the first file in the form is used.  Hidden and
+// generated fields cannot be written this way.
+func (self *Server) handleBlobUpload(w http.ResponseWriter, req *http.Request) {
+	var body io.Reader = req.Body
+
+	name, fieldName, field, ok := self.blobField(w, req)
+
+	if !ok {
+		return
+	} else if field.Hidden || field.Generated != `` || field.Identity {
+		respond(w, req, fmt.Errorf("Field %q cannot be written", fieldName), http.StatusForbidden)
+		return
+	}
+
+	id := vestigo.Param(req, `id`)
+
+	if !self.checkIfMatch(w, req, name, id) {
+		return
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(req.Header.Get(`Content-Type`)); err == nil && mediaType == `multipart/form-data` {
+		if mr, err := req.MultipartReader(); err == nil {
+			for {
+				if part, err := mr.NextPart(); err == nil {
+					if part.FileName() != `` {
+						body = part
+						break
+					}
+				} else if err == io.EOF {
+					respond(w, req, fmt.Errorf("No file was included in the upload"), http.StatusBadRequest)
+					return
+				} else {
+					respond(w, req, err, http.StatusBadRequest)
+					return
+				}
+			}
+		} else {
+			respond(w, req, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	counter := &countingReader{Reader: body}
+
+	// blob fields are raw, and any content matches a raw field's schema, so streamed content isn't
+	// validated; buffered content is written as an update to the record, so it is checked like one
+	if streamer, ok := self.db(req).(backends.BlobStreamer); ok {
+		if err := streamer.WriteBlob(name, id, fieldName, counter); err == nil {
+			self.respondBlobWritten(w, req, id, fieldName, counter.count)
+			return
+		} else if err != backends.NotImplementedError {
+			respond(w, req, err)
+			return
+		}
+	}
+
+	// fall back to buffering the content and updating the record
+	if data, err := ioutil.ReadAll(io.LimitReader(counter, MaxBufferedBlobSize+1)); err == nil {
+		if int64(len(data)) > MaxBufferedBlobSize {
+			respond(w, req, fmt.Errorf("Content exceeds the maximum size of %d bytes", MaxBufferedBlobSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		recordset := dal.NewRecordSet(dal.NewRecord(id).Set(fieldName, data))
+
+		if !self.validatePayload(w, req, name, recordset, true) {
+			return
+		}
+
+		if err := self.db(req).Update(name, recordset); err == nil {
+			self.respondBlobWritten(w, req, id, fieldName, counter.count)
+		} else {
+			respond(w, req, err)
+		}
+	} else {
+		respond(w, req, err, http.StatusBadRequest)
+	}
+}
+
+func (self *Server) respondBlobWritten(w http.ResponseWriter, req *http.Request, id interface{}, field string, size int64) {
+	setRequestRecordCount(req, 1)
+
+	respond(w, req, map[string]interface{}{
+		`id`:    id,
+		`field`: field,
+		`size`:  size,
+	})
+}
+
+type countingReader struct {
+	io.Reader
+	count int64
+}
+
+func (self *countingReader) Read(p []byte) (int, error) {
+	n, err := self.Reader.Read(p)
+	self.count += int64(n)
+	return n, err
+}
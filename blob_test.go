@@ -0,0 +1,152 @@
+package pivot
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobUploadAndDownload(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`files`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `content`,
+		Type: dal.RawType,
+	})))
+
+	assert.NoError(mock.Insert(`files`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `logo.png`))))
+
+	content := "\x89PNG\r\n\x1a\n<script>alert(1)</script>"
+
+	w := testRequest(handler, `PUT`, `/api/collections/files/records/1/blobs/content`, content)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `"size":`)
+
+	record, err := mock.Retrieve(`files`, 1)
+	assert.NoError(err)
+	assert.Equal([]byte(content), record.Get(`content`))
+
+	// the client doesn't get to choose how the content is interpreted
+	w = testRequest(handler, `GET`, `/api/collections/files/records/1/blobs/content?type=text/html`, ``)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(content, w.Body.String())
+	assert.Equal(`image/png`, w.Header().Get(`Content-Type`))
+	assert.Equal(`nosniff`, w.Header().Get(`X-Content-Type-Options`))
+	assert.Equal(`attachment; filename=content`, w.Header().Get(`Content-Disposition`))
+
+	// ranges are supported
+	w = testRequest(handler, `GET`, `/api/collections/files/records/1/blobs/content`, ``, `Range`, `bytes=0-3`)
+	assert.Equal(http.StatusPartialContent, w.Code)
+	assert.Equal("\x89PNG", w.Body.String())
+
+	// multipart uploads use the first file in the form
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	assert.NoError(form.WriteField(`description`, `not this`))
+
+	part, err := form.CreateFormFile(`file`, `logo.png`)
+	assert.NoError(err)
+	part.Write([]byte(`replaced`))
+	assert.NoError(form.Close())
+
+	w = testRequest(handler, `POST`, `/api/collections/files/records/1/blobs/content`, body.String(), `Content-Type`, form.FormDataContentType())
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = testRequest(handler, `GET`, `/api/collections/files/records/1/blobs/content`, ``)
+	assert.Equal(`replaced`, w.Body.String())
+
+	// only raw fields that exist can be addressed
+	assert.Equal(http.StatusBadRequest, testRequest(handler, `GET`, `/api/collections/files/records/1/blobs/name`, ``).Code)
+	assert.Equal(http.StatusNotFound, testRequest(handler, `GET`, `/api/collections/files/records/1/blobs/missing`, ``).Code)
+	assert.Equal(http.StatusNotFound, testRequest(handler, `GET`, `/api/collections/other/records/1/blobs/content`, ``).Code)
+}
+
+func TestBlobFieldRestrictions(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`files`).AddFields(dal.Field{
+		Name:   `secret`,
+		Type:   dal.RawType,
+		Hidden: true,
+	}, dal.Field{
+		Name:     `private`,
+		Type:     dal.RawType,
+		Redacted: true,
+	}, dal.Field{
+		Name:      `thumbnail`,
+		Type:      dal.RawType,
+		Generated: `compress(content)`,
+	})))
+
+	assert.NoError(mock.Insert(`files`, dal.NewRecordSet(dal.NewRecord(1).Set(`secret`, []byte(`hunter2`)))))
+
+	for _, field := range []string{`secret`, `private`} {
+		w := testRequest(handler, `GET`, `/api/collections/files/records/1/blobs/`+field, ``)
+		assert.Equal(http.StatusForbidden, w.Code, field)
+		assert.NotContains(w.Body.String(), `hunter2`, field)
+	}
+
+	for _, field := range []string{`secret`, `thumbnail`} {
+		w := testRequest(handler, `PUT`, `/api/collections/files/records/1/blobs/`+field, `overwritten`)
+		assert.Equal(http.StatusForbidden, w.Code, field)
+	}
+
+	record, err := mock.Retrieve(`files`, 1)
+	assert.NoError(err)
+	assert.Equal([]byte(`hunter2`), record.Get(`secret`))
+	assert.Nil(record.Get(`thumbnail`))
+}
+
+func TestBlobUploadLimit(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer()
+
+	limit := MaxBufferedBlobSize
+	MaxBufferedBlobSize = 8
+	defer func() {
+		MaxBufferedBlobSize = limit
+	}()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`files`).AddFields(dal.Field{
+		Name: `content`,
+		Type: dal.RawType,
+	})))
+
+	assert.NoError(mock.Insert(`files`, dal.NewRecordSet(dal.NewRecord(1))))
+
+	w := testRequest(handler, `PUT`, `/api/collections/files/records/1/blobs/content`, `this is too long`)
+	assert.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	assert.Empty(mock.CallsTo(`Update`))
+}
+
+func TestBlobUploadValidation(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer(func(server *Server) {
+		server.ValidatePayloads = true
+	})
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`files`).AddFields(dal.Field{
+		Name:     `content`,
+		Type:     dal.RawType,
+		Required: true,
+	})))
+
+	assert.NoError(mock.Insert(`files`, dal.NewRecordSet(dal.NewRecord(1).Set(`content`, []byte(`old`)))))
+
+	// any content is valid for a raw field
+	w := testRequest(handler, `PUT`, `/api/collections/files/records/1/blobs/content`, "\x00\xff binary")
+	assert.Equal(http.StatusOK, w.Code)
+
+	record, err := mock.Retrieve(`files`, 1)
+	assert.NoError(err)
+	assert.Equal([]byte("\x00\xff binary"), record.Get(`content`))
+}
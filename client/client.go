@@ -0,0 +1,562 @@
+// Package client provides a Backend that talks to a remote pivot server over its REST API, allowing
+// applications to switch between an embedded database and a remote one by changing only the
+// connection string.
+//
+// Importing this package registers the "pivot" backend, e.g.:
+//
+//	pivot://localhost:29029
+//	pivot+https://pivot.example.com?token=ADMIN_TOKEN&tenant=acme
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+var DefaultRequestTimeout = time.Duration(30) * time.Second
+
+func init() {
+	backends.RegisterBackend(`pivot`, NewClient)
+}
+
+type Client struct {
+	backends.Backend
+	backends.Indexer
+	conn                  dal.ConnectionString
+	baseURL               string
+	client                *http.Client
+	headers               http.Header
+	registeredCollections sync.Map
+}
+
+// Creates a new client for the server at the given connection string.  The following options are
+// supported:
+//
+//	token    - the admin token to present for schema modifications
+//	tenant   - the tenant to make requests on behalf of (for servers using header-based tenancy)
+//	api_key  - the key that requests are attributed to for quota purposes
+//	timeout  - the request timeout (e.g.: "10s")
+func NewClient(connection dal.ConnectionString) backends.Backend {
+	protocol := connection.Protocol()
+
+	if protocol == `` {
+		protocol = `http`
+	}
+
+	timeout := DefaultRequestTimeout
+
+	if v, err := time.ParseDuration(connection.OptString(`timeout`, ``)); err == nil {
+		timeout = v
+	}
+
+	headers := make(http.Header)
+
+	if v := connection.OptString(`token`, ``); v != `` {
+		headers.Set(`X-Pivot-Admin-Token`, v)
+	}
+
+	if v := connection.OptString(`tenant`, ``); v != `` {
+		headers.Set(`X-Pivot-Tenant`, v)
+	}
+
+	if v := connection.OptString(`api_key`, ``); v != `` {
+		headers.Set(`X-Pivot-Api-Key`, v)
+	}
+
+	return &Client{
+		conn:    connection,
+		baseURL: fmt.Sprintf("%s://%s", protocol, connection.Host()),
+		client: &http.Client{
+			Timeout: timeout,
+		},
+		headers: headers,
+	}
+}
+
+func (self *Client) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *Client) Initialize() error {
+	return self.Ping(self.client.Timeout)
+}
+
+func (self *Client) SetIndexer(dal.ConnectionString) error {
+	return fmt.Errorf("Indexers are configured by the remote server")
+}
+
+// Registers a collection definition locally; this is used to interpret records but is not sent to
+// the remote server.  Use CreateCollection to create collections remotely.
+func (self *Client) RegisterCollection(definition *dal.Collection) {
+	if definition != nil {
+		self.registeredCollections.Store(definition.Name, definition)
+	}
+}
+
+func (self *Client) Ping(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if req, err := self.newRequest(`GET`, `/api/status`, nil, nil); err == nil {
+		if response, err := self.client.Do(req.WithContext(ctx)); err == nil {
+			response.Body.Close()
+
+			if response.StatusCode < 400 {
+				return nil
+			} else {
+				return fmt.Errorf("Backend unavailable: %v", response.Status)
+			}
+		} else {
+			return fmt.Errorf("Backend unavailable: %v", err)
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *Client) Exists(collection string, id interface{}) bool {
+	if _, err := self.Retrieve(collection, id); err == nil {
+		return true
+	}
+
+	return false
+}
+
+func (self *Client) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	var record dal.Record
+	query := make(url.Values)
+
+	if len(fields) > 0 {
+		query.Set(`fields`, strings.Join(fields, `,`))
+	}
+
+	if err := self.do(`GET`, recordPath(collection, id), query, nil, &record); err == nil {
+		return &record, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Inserts the given records.  Any IDs generated by the server are copied back into the records.
+func (self *Client) Insert(collection string, records *dal.RecordSet) error {
+	var inserted dal.RecordSet
+
+	if err := self.do(`POST`, fmt.Sprintf("/api/collections/%s/records", url.PathEscape(collection)), nil, records, &inserted); err == nil {
+		if len(inserted.Records) == len(records.Records) {
+			for i, record := range inserted.Records {
+				records.Records[i].ID = record.ID
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *Client) Update(collection string, records *dal.RecordSet, target ...string) error {
+	if len(target) > 0 {
+		return fmt.Errorf("Targeted updates are not supported by %T", self)
+	}
+
+	return self.do(`PUT`, fmt.Sprintf("/api/collections/%s/records", url.PathEscape(collection)), nil, records, nil)
+}
+
+// Deletes the given records.  If a single *filter.Filter is given instead of IDs, all records
+// matching the filter are deleted.
+func (self *Client) Delete(collection string, ids ...interface{}) error {
+	if len(ids) == 1 {
+		if f, ok := ids[0].(*filter.Filter); ok {
+			return self.DeleteQuery(&dal.Collection{
+				Name: collection,
+			}, f)
+		}
+	}
+
+	for _, id := range ids {
+		if err := self.do(`DELETE`, recordPath(collection, id), nil, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *Client) CreateCollection(definition *dal.Collection) error {
+	return self.do(`POST`, `/api/schema`, nil, definition, nil)
+}
+
+func (self *Client) DeleteCollection(collection string) error {
+	return self.do(`DELETE`, fmt.Sprintf("/api/schema/%s", url.PathEscape(collection)), nil, nil, nil)
+}
+
+func (self *Client) ListCollections() ([]string, error) {
+	var names []string
+
+	if err := self.do(`GET`, `/api/schema`, nil, nil, &names); err == nil {
+		return names, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *Client) GetCollection(collection string) (*dal.Collection, error) {
+	var definition dal.Collection
+
+	if err := self.do(`GET`, fmt.Sprintf("/api/schema/%s", url.PathEscape(collection)), nil, nil, &definition); err == nil {
+		return &definition, nil
+	} else if registered, ok := self.registeredCollections.Load(collection); ok {
+		return registered.(*dal.Collection), nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *Client) WithSearch(collection *dal.Collection, filters ...*filter.Filter) backends.Indexer {
+	return self
+}
+
+func (self *Client) WithAggregator(collection *dal.Collection) backends.Aggregator {
+	return nil
+}
+
+func (self *Client) Flush() error {
+	return nil
+}
+
+func (self *Client) IndexConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *Client) IndexInitialize(backends.Backend) error {
+	return nil
+}
+
+func (self *Client) GetBackend() backends.Backend {
+	return self
+}
+
+func (self *Client) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.Name, id)
+}
+
+func (self *Client) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.Name, id)
+}
+
+func (self *Client) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+// Indexing is performed by the remote server as records are written, so this is a no-op.
+func (self *Client) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Performs a query on the remote server, streaming the results as they are received.
+func (self *Client) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn backends.IndexResultFunc) error {
+	if f == nil {
+		f = filter.All()
+	}
+
+	body, err := structuredFilter(f)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := self.newRequest(`POST`, fmt.Sprintf("/api/collections/%s/query", url.PathEscape(collection.Name)), nil, body)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(`Accept`, `application/x-ndjson`)
+
+	// streamed queries may legitimately take longer than the client timeout
+	response, err := (&http.Client{
+		Transport: self.client.Transport,
+	}).Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if err := responseError(response); err != nil {
+		return err
+	}
+
+	page := backends.IndexPage{
+		Limit:        f.Limit,
+		Offset:       f.Offset,
+		TotalResults: -1,
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+
+		// skip heartbeats
+		if len(line) == 0 {
+			continue
+		}
+
+		var result struct {
+			dal.Record
+			Error string `json:"error"`
+		}
+
+		if err := json.Unmarshal(line, &result); err != nil {
+			return fmt.Errorf("invalid query response: %v", err)
+		} else if result.Error != `` && result.ID == nil {
+			return fmt.Errorf("%s", result.Error)
+		}
+
+		record := result.Record
+
+		if err := resultFn(&record, nil, page); err == backends.IndexResultsStop {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (self *Client) Query(collection *dal.Collection, f *filter.Filter, resultFns ...backends.IndexResultFunc) (*dal.RecordSet, error) {
+	recordset := dal.NewRecordSet()
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page backends.IndexPage) error {
+		if len(resultFns) > 0 {
+			return resultFns[0](record, err, page)
+		} else {
+			recordset.Records = append(recordset.Records, record)
+			return nil
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	recordset.ResultCount = int64(len(recordset.Records))
+	return recordset, nil
+}
+
+func (self *Client) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	var values map[string][]interface{}
+	query := make(url.Values)
+
+	if f != nil {
+		query.Set(`q`, f.String())
+	}
+
+	escaped := make([]string, len(fields))
+
+	for i, field := range fields {
+		escaped[i] = url.PathEscape(field)
+	}
+
+	if err := self.do(`GET`, fmt.Sprintf(
+		"/api/collections/%s/list/%s",
+		url.PathEscape(collection.Name),
+		strings.Join(escaped, `/`),
+	), query, nil, &values); err == nil {
+		return values, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *Client) Facets(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]backends.FacetBucket, error) {
+	var facets map[string][]backends.FacetBucket
+	query := make(url.Values)
+
+	if f != nil {
+		query.Set(`q`, f.String())
+	}
+
+	escaped := make([]string, len(fields))
+
+	for i, field := range fields {
+		escaped[i] = url.PathEscape(field)
+	}
+
+	if err := self.do(`GET`, fmt.Sprintf(
+		"/api/collections/%s/facets/%s",
+		url.PathEscape(collection.Name),
+		strings.Join(escaped, `/`),
+	), query, nil, &facets); err == nil {
+		return facets, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *Client) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	return self.do(`DELETE`, fmt.Sprintf(
+		"/api/collections/%s/where/%s",
+		url.PathEscape(collection.Name),
+		f.String(),
+	), nil, nil, nil)
+}
+
+func (self *Client) FlushIndex() error {
+	return nil
+}
+
+func (self *Client) newRequest(method string, path string, query url.Values, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+
+	if body != nil {
+		if data, err := json.Marshal(body); err == nil {
+			reader = bytes.NewReader(data)
+		} else {
+			return nil, err
+		}
+	}
+
+	uri := self.baseURL + path
+
+	if len(query) > 0 {
+		uri += `?` + query.Encode()
+	}
+
+	if req, err := http.NewRequest(method, uri, reader); err == nil {
+		for k, v := range self.headers {
+			req.Header[k] = v
+		}
+
+		if body != nil {
+			req.Header.Set(`Content-Type`, `application/json`)
+		}
+
+		req.Header.Set(`Accept`, `application/json`)
+
+		return req, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Performs a request, decoding the JSON response into output (if non-nil).
+func (self *Client) do(method string, path string, query url.Values, body interface{}, output interface{}) error {
+	if req, err := self.newRequest(method, path, query, body); err == nil {
+		if response, err := self.client.Do(req); err == nil {
+			defer response.Body.Close()
+
+			if err := responseError(response); err != nil {
+				return err
+			}
+
+			if output != nil {
+				if err := json.NewDecoder(response.Body).Decode(output); err != nil && err != io.EOF {
+					return fmt.Errorf("invalid response: %v", err)
+				}
+			}
+
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// Converts an error response from the server into an error.  Statuses that correspond to one of the
+// errors defined in the dal package are returned as that kind of error, so that they can be tested
+// for (e.g.: with dal.IsNotExistError) the same way as errors from any other backend.
+func responseError(response *http.Response) error {
+	if response.StatusCode < 400 {
+		return nil
+	}
+
+	var kind error
+	var body struct {
+		Error string `json:"error"`
+	}
+
+	message := response.Status
+
+	if err := json.NewDecoder(response.Body).Decode(&body); err == nil && body.Error != `` {
+		message = body.Error
+	}
+
+	switch response.StatusCode {
+	case http.StatusNotFound:
+		if message == dal.ERR_COLLECTION_NOT_FOUND {
+			kind = dal.ErrCollectionNotFound
+		} else {
+			kind = dal.ErrRecordNotFound
+		}
+	case http.StatusConflict:
+		kind = dal.ErrUniqueViolation
+	case http.StatusPreconditionFailed:
+		kind = dal.ErrStaleRecord
+	case http.StatusForbidden:
+		kind = dal.ErrPermissionDenied
+	case http.StatusRequestEntityTooLarge:
+		kind = dal.ErrLimitExceeded
+	case http.StatusNotImplemented:
+		kind = dal.ErrUnsupported
+	default:
+		return fmt.Errorf("%s", message)
+	}
+
+	return &dal.Error{
+		Kind:    kind,
+		Message: message,
+	}
+}
+
+func recordPath(collection string, id interface{}) string {
+	var parts []string
+
+	if ids, ok := id.([]interface{}); ok {
+		for _, v := range ids {
+			parts = append(parts, url.PathEscape(fmt.Sprintf("%v", v)))
+		}
+	} else {
+		parts = append(parts, url.PathEscape(fmt.Sprintf("%v", id)))
+	}
+
+	return fmt.Sprintf("/api/collections/%s/records/%s", url.PathEscape(collection), strings.Join(parts, `/`))
+}
+
+// Encodes a filter in the structured JSON form accepted by the query endpoint.  The criteria key
+// is always present (even when empty) so that the server does not interpret the body as a map of
+// field values.
+func structuredFilter(f *filter.Filter) (map[string]interface{}, error) {
+	var body map[string]interface{}
+
+	if data, err := f.ToJSON(); err == nil {
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+
+	if _, ok := body[`criteria`]; !ok {
+		body[`criteria`] = []interface{}{}
+	}
+
+	return body, nil
+}
@@ -0,0 +1,266 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// starts a server that handles requests with the given function, and returns a client for it
+func newTestClient(handler http.HandlerFunc, options ...string) (*httptest.Server, *Client, error) {
+	server := httptest.NewServer(handler)
+	uri := `pivot://` + strings.TrimPrefix(server.URL, `http://`)
+
+	if len(options) > 0 {
+		uri += `?` + strings.Join(options, `&`)
+	}
+
+	if cs, err := dal.ParseConnectionString(uri); err == nil {
+		return server, NewClient(cs).(*Client), nil
+	} else {
+		server.Close()
+		return nil, nil, err
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set(`Content-Type`, `application/json`)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func TestClientHeaders(t *testing.T) {
+	assert := require.New(t)
+	var seen http.Header
+
+	server, client, err := newTestClient(func(w http.ResponseWriter, req *http.Request) {
+		seen = req.Header
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	}, `token=s3cr3t`, `tenant=acme`, `api_key=k1`)
+
+	assert.NoError(err)
+	defer server.Close()
+
+	assert.NoError(client.Ping(DefaultRequestTimeout))
+	assert.Equal(`s3cr3t`, seen.Get(`X-Pivot-Admin-Token`))
+	assert.Equal(`acme`, seen.Get(`X-Pivot-Tenant`))
+	assert.Equal(`k1`, seen.Get(`X-Pivot-Api-Key`))
+	assert.Equal(`application/json`, seen.Get(`Accept`))
+}
+
+func TestClientRetrieve(t *testing.T) {
+	assert := require.New(t)
+
+	server, client, err := newTestClient(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case `/api/collections/users/records/1`:
+			assert.Equal(`GET`, req.Method)
+			assert.Equal(`name,email`, req.URL.Query().Get(`fields`))
+
+			writeJSON(w, http.StatusOK, dal.NewRecord(1).Set(`name`, `tester`))
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				`error`: `Record 2 does not exist`,
+			})
+		}
+	})
+
+	assert.NoError(err)
+	defer server.Close()
+
+	record, err := client.Retrieve(`users`, 1, `name`, `email`)
+	assert.NoError(err)
+	assert.EqualValues(1, record.ID)
+	assert.Equal(`tester`, record.Get(`name`))
+
+	_, err = client.Retrieve(`users`, 2)
+	assert.Error(err)
+	assert.True(dal.IsNotExistError(err))
+	assert.Equal(`Record 2 does not exist`, err.Error())
+
+	assert.False(client.Exists(`users`, 2))
+}
+
+func TestClientInsertAndUpdate(t *testing.T) {
+	assert := require.New(t)
+	var methods []string
+
+	server, client, err := newTestClient(func(w http.ResponseWriter, req *http.Request) {
+		var records dal.RecordSet
+
+		assert.Equal(`/api/collections/users/records`, req.URL.Path)
+		assert.Equal(`application/json`, req.Header.Get(`Content-Type`))
+		assert.NoError(json.NewDecoder(req.Body).Decode(&records))
+
+		methods = append(methods, req.Method)
+
+		// the server generates IDs for inserted records
+		for i, record := range records.Records {
+			if record.ID == nil {
+				record.ID = fmt.Sprintf("generated-%d", i)
+			}
+		}
+
+		writeJSON(w, http.StatusOK, records)
+	})
+
+	assert.NoError(err)
+	defer server.Close()
+
+	records := dal.NewRecordSet(
+		dal.NewRecord(nil).Set(`name`, `first`),
+		dal.NewRecord(nil).Set(`name`, `second`),
+	)
+
+	assert.NoError(client.Insert(`users`, records))
+	assert.Equal(`generated-0`, records.Records[0].ID)
+	assert.Equal(`generated-1`, records.Records[1].ID)
+
+	assert.NoError(client.Update(`users`, records))
+	assert.Error(client.Update(`users`, records, `name`))
+	assert.Equal([]string{`POST`, `PUT`}, methods)
+}
+
+func TestClientDelete(t *testing.T) {
+	assert := require.New(t)
+	var paths []string
+
+	server, client, err := newTestClient(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(`DELETE`, req.Method)
+		paths = append(paths, req.URL.Path)
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	})
+
+	assert.NoError(err)
+	defer server.Close()
+
+	assert.NoError(client.Delete(`users`, 1, `two`))
+	assert.NoError(client.Delete(`users`, filter.MustParse(`name/tester`)))
+
+	assert.Equal([]string{
+		`/api/collections/users/records/1`,
+		`/api/collections/users/records/two`,
+		`/api/collections/users/where/name/tester`,
+	}, paths)
+}
+
+func TestClientQuery(t *testing.T) {
+	assert := require.New(t)
+
+	server, client, err := newTestClient(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+
+		assert.Equal(`POST`, req.Method)
+		assert.Equal(`/api/collections/users/query`, req.URL.Path)
+		assert.Equal(`application/x-ndjson`, req.Header.Get(`Accept`))
+		assert.NoError(json.NewDecoder(req.Body).Decode(&body))
+		assert.Contains(body, `criteria`)
+
+		w.Header().Set(`Content-Type`, `application/x-ndjson`)
+
+		if body[`limit`] == float64(1) {
+			w.Write([]byte("{\"id\":1}\n{\"error\":\"backend went away\"}\n"))
+		} else {
+			w.Write([]byte("{\"id\":1,\"fields\":{\"name\":\"first\"}}\n\n{\"id\":2,\"fields\":{\"name\":\"second\"}}\n"))
+		}
+	})
+
+	assert.NoError(err)
+	defer server.Close()
+
+	collection := dal.NewCollection(`users`)
+
+	// heartbeats are skipped
+	recordset, err := client.Query(collection, nil)
+	assert.NoError(err)
+	assert.Equal(int64(2), recordset.ResultCount)
+	assert.Equal(`first`, recordset.Records[0].Get(`name`))
+	assert.Equal(`second`, recordset.Records[1].Get(`name`))
+
+	// results can be stopped early
+	var seen int
+
+	assert.NoError(client.QueryFunc(collection, filter.All(), func(record *dal.Record, err error, page backends.IndexPage) error {
+		seen += 1
+		return backends.IndexResultsStop
+	}))
+
+	assert.Equal(1, seen)
+
+	// errors reported mid-stream are returned
+	f := filter.All()
+	f.Limit = 1
+
+	_, err = client.Query(collection, f)
+	assert.Error(err)
+	assert.Equal(`backend went away`, err.Error())
+}
+
+func TestClientErrors(t *testing.T) {
+	assert := require.New(t)
+	var status int
+	var message string
+
+	server, client, err := newTestClient(func(w http.ResponseWriter, req *http.Request) {
+		if message == `` {
+			w.WriteHeader(status)
+		} else {
+			writeJSON(w, status, map[string]interface{}{
+				`error`: message,
+			})
+		}
+	})
+
+	assert.NoError(err)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		Status  int
+		Message string
+		Kind    error
+	}{
+		{http.StatusNotFound, dal.ERR_COLLECTION_NOT_FOUND, dal.ErrCollectionNotFound},
+		{http.StatusNotFound, `Record 1 does not exist`, dal.ErrRecordNotFound},
+		{http.StatusConflict, `duplicate key`, dal.ErrUniqueViolation},
+		{http.StatusPreconditionFailed, `Record 1 has been modified`, dal.ErrStaleRecord},
+		{http.StatusForbidden, `This operation requires administrative access`, dal.ErrPermissionDenied},
+		{http.StatusRequestEntityTooLarge, `too big`, dal.ErrLimitExceeded},
+		{http.StatusNotImplemented, `not supported`, dal.ErrUnsupported},
+		{http.StatusInternalServerError, `broken`, nil},
+		{http.StatusBadGateway, ``, nil},
+	} {
+		status, message = tc.Status, tc.Message
+
+		_, err := client.GetCollection(`users`)
+		assert.Error(err, "%+v", tc)
+
+		if tc.Message != `` {
+			assert.Equal(tc.Message, err.Error(), "%+v", tc)
+		} else {
+			assert.Equal(`502 Bad Gateway`, err.Error())
+		}
+
+		if tc.Kind != nil {
+			assert.True(errors.Is(err, tc.Kind), "%+v", tc)
+		} else {
+			_, ok := err.(*dal.Error)
+			assert.False(ok, "%+v", tc)
+		}
+	}
+
+	// locally-registered definitions are used if the server doesn't have one
+	client.RegisterCollection(dal.NewCollection(`users`))
+
+	collection, err := client.GetCollection(`users`)
+	assert.NoError(err)
+	assert.Equal(`users`, collection.Name)
+}
@@ -0,0 +1,188 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/ghetzel/pivot/dal"
+)
+
+type ProtoOptions struct {
+	// The protobuf package the generated messages belong to.
+	Package string
+
+	// If set, the Go import path of the package that Go bindings will be generated into (the
+	// go_package file option).
+	GoPackage string
+}
+
+type protoField struct {
+	Name    string
+	Type    string
+	Number  int
+	Comment string
+}
+
+type protoMessage struct {
+	Name       string
+	Collection string
+	Fields     []protoField
+}
+
+var protoTemplate = template.Must(template.New(`proto`).Parse(`// Code generated by pivot codegen; DO NOT EDIT.
+
+syntax = "proto3";
+
+package {{ .Package }};
+{{ if .GoPackage }}
+option go_package = "{{ .GoPackage }}";
+{{ end }}
+{{- if .Imports }}
+{{ range .Imports -}}
+import "{{ . }}";
+{{ end }}
+{{- end }}
+{{- range $message := .Messages }}
+// {{ $message.Name }} represents a record in the "{{ $message.Collection }}" collection.
+message {{ $message.Name }} {
+{{- range $message.Fields }}
+{{- if .Comment }}
+  // {{ .Comment }}
+{{- end }}
+  {{ .Type }} {{ .Name }} = {{ .Number }};
+{{- end }}
+}
+{{ end }}`))
+
+// Writes a proto3 file containing a message for each of the given collections.  The identity field is
+// always field number 1, and the remaining fields are numbered in the order they are defined in, so
+// fields should only be appended to collections whose messages are in use.
+func GenerateProto(w io.Writer, collections []*dal.Collection, options ProtoOptions) error {
+	if options.Package == `` {
+		options.Package = `pivot`
+	}
+
+	imports := make(map[string]bool)
+	messages := make([]protoMessage, 0)
+
+	for _, collection := range collections {
+		message := protoMessage{
+			Name:       GoName(singular(collection.Name)),
+			Collection: collection.Name,
+		}
+
+		identityField := collection.IdentityField
+
+		if identityField == `` {
+			identityField = dal.DefaultIdentityField
+		}
+
+		identityType := collection.IdentityFieldType
+
+		if identityType == `` {
+			identityType = dal.DefaultIdentityFieldType
+		}
+
+		message.Fields = append(message.Fields, protoField{
+			Name:   ProtoName(identityField),
+			Type:   protoType(identityType, imports),
+			Number: 1,
+		})
+
+		for _, field := range collection.Fields {
+			if field.Name == identityField {
+				continue
+			}
+
+			message.Fields = append(message.Fields, protoField{
+				Name:    ProtoName(field.Name),
+				Type:    protoType(field.Type, imports),
+				Number:  len(message.Fields) + 1,
+				Comment: strings.TrimSpace(strings.Replace(field.Description, "\n", ` `, -1)),
+			})
+		}
+
+		messages = append(messages, message)
+	}
+
+	importList := make([]string, 0)
+
+	for imp := range imports {
+		importList = append(importList, imp)
+	}
+
+	sort.Strings(importList)
+
+	var buf bytes.Buffer
+
+	if err := protoTemplate.Execute(&buf, map[string]interface{}{
+		`Package`:   options.Package,
+		`GoPackage`: options.GoPackage,
+		`Imports`:   importList,
+		`Messages`:  messages,
+	}); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func protoType(t dal.Type, imports map[string]bool) string {
+	switch t {
+	case dal.StringType:
+		return `string`
+	case dal.BooleanType:
+		return `bool`
+	case dal.IntType:
+		return `int64`
+	case dal.FloatType:
+		return `double`
+	case dal.TimeType:
+		imports[`google/protobuf/timestamp.proto`] = true
+		return `google.protobuf.Timestamp`
+	case dal.ObjectType:
+		imports[`google/protobuf/struct.proto`] = true
+		return `google.protobuf.Struct`
+	case dal.RawType:
+		return `bytes`
+	default:
+		imports[`google/protobuf/struct.proto`] = true
+		return `google.protobuf.Value`
+	}
+}
+
+// Converts a field name (e.g.: "createdAt", "user-id") into a lower_snake_case protobuf field name
+// (e.g.: "created_at", "user_id").
+func ProtoName(name string) string {
+	var out bytes.Buffer
+	runes := []rune(name)
+
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+				out.WriteRune('_')
+			}
+
+			out.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			out.WriteRune(r)
+		default:
+			out.WriteRune('_')
+		}
+	}
+
+	if out.Len() == 0 {
+		return `x`
+	} else if s := out.String(); !unicode.IsLetter([]rune(s)[0]) {
+		return fmt.Sprintf("x_%s", strings.TrimLeft(s, `_`))
+	} else {
+		return s
+	}
+}
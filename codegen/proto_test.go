@@ -0,0 +1,53 @@
+package codegen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtoName(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(`created_at`, ProtoName(`createdAt`))
+	assert.Equal(`user_id`, ProtoName(`user-id`))
+	assert.Equal(`name`, ProtoName(`name`))
+	assert.Equal(`x_2fa`, ProtoName(`2fa`))
+}
+
+func TestGenerateProto(t *testing.T) {
+	assert := require.New(t)
+	var buf bytes.Buffer
+
+	assert.Nil(GenerateProto(&buf, []*dal.Collection{
+		dal.NewCollection(`users`).AddFields(dal.Field{
+			Name:        `name`,
+			Type:        dal.StringType,
+			Description: `The user's full name.`,
+		}, dal.Field{
+			Name: `created_at`,
+			Type: dal.TimeType,
+		}, dal.Field{
+			Name: `properties`,
+			Type: dal.ObjectType,
+		}),
+	}, ProtoOptions{
+		Package:   `example.models`,
+		GoPackage: `example.com/models`,
+	}))
+
+	src := buf.String()
+
+	assert.Contains(src, "syntax = \"proto3\";\n")
+	assert.Contains(src, "package example.models;\n")
+	assert.Contains(src, "option go_package = \"example.com/models\";\n")
+	assert.Contains(src, "import \"google/protobuf/struct.proto\";\n")
+	assert.Contains(src, "import \"google/protobuf/timestamp.proto\";\n")
+	assert.Contains(src, "message User {\n")
+	assert.Contains(src, "  int64 id = 1;\n")
+	assert.Contains(src, "  // The user's full name.\n  string name = 2;\n")
+	assert.Contains(src, "  google.protobuf.Timestamp created_at = 3;\n")
+	assert.Contains(src, "  google.protobuf.Struct properties = 4;\n")
+}
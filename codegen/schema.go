@@ -0,0 +1,39 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghodss/yaml"
+)
+
+// Writes the given collection definitions in a format that can be loaded as a schema file
+// ("json" or "yaml").
+func GenerateSchema(w io.Writer, collections []*dal.Collection, format string) error {
+	if collections == nil {
+		collections = make([]*dal.Collection, 0)
+	}
+
+	var data []byte
+	var err error
+
+	switch format {
+	case `json`, ``:
+		if data, err = json.MarshalIndent(collections, ``, `  `); err == nil {
+			data = append(data, '\n')
+		}
+	case `yaml`, `yml`:
+		data, err = yaml.Marshal(collections)
+	default:
+		return fmt.Errorf("unsupported schema format %q", format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
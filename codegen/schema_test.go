@@ -0,0 +1,30 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSchema(t *testing.T) {
+	assert := require.New(t)
+	var buf bytes.Buffer
+
+	assert.Nil(GenerateSchema(&buf, []*dal.Collection{
+		dal.NewCollection(`users`).AddFields(dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		}),
+	}, `json`))
+
+	var collections []*dal.Collection
+	assert.Nil(json.Unmarshal(buf.Bytes(), &collections))
+	assert.Len(collections, 1)
+	assert.Equal(`users`, collections[0].Name)
+	assert.Equal(`name`, collections[0].Fields[0].Name)
+
+	assert.Error(GenerateSchema(&buf, nil, `xml`))
+}
@@ -0,0 +1,235 @@
+// Package codegen generates Go source code and schema definitions from pivot collections.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/ghetzel/pivot/dal"
+)
+
+type StructOptions struct {
+	// The name of the package the generated code belongs to.
+	Package string
+
+	// Whether to generate getter and setter methods for each field.
+	Accessors bool
+
+	// Whether to generate a Validate() method stub for each type.  Since the stubs are intended to be
+	// filled in by hand, the output is not marked as generated code when this is set.
+	Hooks bool
+}
+
+type structField struct {
+	Name     string
+	GoName   string
+	Type     string
+	Tag      string
+	Comment  string
+	Identity bool
+}
+
+type structType struct {
+	Name       string
+	Collection string
+	Fields     []structField
+}
+
+var structTemplate = template.Must(template.New(`structs`).Parse(`
+{{- if .Hooks }}// Code generated by pivot codegen.{{ else }}// Code generated by pivot codegen; DO NOT EDIT.{{ end }}
+
+package {{ .Package }}
+{{ if .Imports }}
+import (
+{{- range .Imports }}
+	"{{ . }}"
+{{- end }}
+)
+{{ end }}
+{{- range $type := .Types }}
+// {{ $type.Name }} represents a record in the "{{ $type.Collection }}" collection.
+type {{ $type.Name }} struct {
+{{- range $type.Fields }}
+{{- if .Comment }}
+	// {{ .Comment }}
+{{- end }}
+	{{ .GoName }} {{ .Type }} ` + "`{{ .Tag }}`" + `
+{{- end }}
+}
+{{ if $.Accessors }}
+{{- range $type.Fields }}{{ if not .Identity }}
+func (self *{{ $type.Name }}) Get{{ .GoName }}() {{ .Type }} {
+	return self.{{ .GoName }}
+}
+
+func (self *{{ $type.Name }}) Set{{ .GoName }}(value {{ .Type }}) {
+	self.{{ .GoName }} = value
+}
+{{ end }}{{ end }}
+{{- end }}
+{{- if $.Hooks }}
+// Validate is a hook for checking that a {{ $type.Name }} is valid before it is written.
+func (self *{{ $type.Name }}) Validate() error {
+	return nil
+}
+{{ end }}
+{{- end }}`))
+
+// Writes Go struct definitions for each of the given collections to the given writer.  The output is
+// formatted with gofmt.
+func GenerateStructs(w io.Writer, collections []*dal.Collection, options StructOptions) error {
+	if options.Package == `` {
+		options.Package = `models`
+	}
+
+	imports := make(map[string]bool)
+	types := make([]structType, 0)
+
+	for _, collection := range collections {
+		typ := structType{
+			Name:       GoName(singular(collection.Name)),
+			Collection: collection.Name,
+		}
+
+		identityField := collection.IdentityField
+
+		if identityField == `` {
+			identityField = dal.DefaultIdentityField
+		}
+
+		identityType := collection.IdentityFieldType
+
+		if identityType == `` {
+			identityType = dal.DefaultIdentityFieldType
+		}
+
+		typ.Fields = append(typ.Fields, structField{
+			Name:     identityField,
+			GoName:   `ID`,
+			Type:     goType(identityType, imports),
+			Tag:      fmt.Sprintf("pivot:\"%s,identity\" json:\"%s\"", identityField, identityField),
+			Identity: true,
+		})
+
+		for _, field := range collection.Fields {
+			if field.Name == identityField {
+				continue
+			}
+
+			sf := structField{
+				Name:    field.Name,
+				GoName:  GoName(field.Name),
+				Type:    goType(field.Type, imports),
+				Comment: strings.TrimSpace(strings.Replace(field.Description, "\n", ` `, -1)),
+			}
+
+			if field.Required {
+				sf.Tag = fmt.Sprintf("pivot:\"%s\" json:\"%s\"", field.Name, field.Name)
+			} else {
+				sf.Tag = fmt.Sprintf("pivot:\"%s,omitempty\" json:\"%s,omitempty\"", field.Name, field.Name)
+			}
+
+			typ.Fields = append(typ.Fields, sf)
+		}
+
+		types = append(types, typ)
+	}
+
+	importList := make([]string, 0)
+
+	for imp := range imports {
+		importList = append(importList, imp)
+	}
+
+	sort.Strings(importList)
+
+	var buf bytes.Buffer
+
+	if err := structTemplate.Execute(&buf, map[string]interface{}{
+		`Package`:   options.Package,
+		`Imports`:   importList,
+		`Types`:     types,
+		`Accessors`: options.Accessors,
+		`Hooks`:     options.Hooks,
+	}); err != nil {
+		return err
+	}
+
+	if src, err := format.Source(buf.Bytes()); err == nil {
+		_, err = w.Write(src)
+		return err
+	} else {
+		return fmt.Errorf("failed to format generated code: %v", err)
+	}
+}
+
+func goType(t dal.Type, imports map[string]bool) string {
+	switch t {
+	case dal.StringType:
+		return `string`
+	case dal.BooleanType:
+		return `bool`
+	case dal.IntType:
+		return `int64`
+	case dal.FloatType:
+		return `float64`
+	case dal.TimeType:
+		imports[`time`] = true
+		return `time.Time`
+	case dal.ObjectType:
+		return `map[string]interface{}`
+	case dal.RawType:
+		return `[]byte`
+	default:
+		return `interface{}`
+	}
+}
+
+// Converts a field or collection name (e.g.: "user_id", "created-at") into an exported Go
+// identifier (e.g.: "UserID", "CreatedAt").
+func GoName(name string) string {
+	var out bytes.Buffer
+
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		switch upper := strings.ToUpper(word); upper {
+		case `ID`, `URL`, `URI`, `API`, `HTTP`, `JSON`, `UUID`, `IP`:
+			out.WriteString(upper)
+		default:
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+			out.WriteString(string(runes))
+		}
+	}
+
+	if out.Len() == 0 {
+		return `X`
+	} else if s := out.String(); unicode.IsDigit([]rune(s)[0]) {
+		return `X` + s
+	} else {
+		return s
+	}
+}
+
+// A naive conversion of a plural collection name to the singular name of the records within it.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, `ies`):
+		return strings.TrimSuffix(name, `ies`) + `y`
+	case strings.HasSuffix(name, `sses`), strings.HasSuffix(name, `xes`):
+		return strings.TrimSuffix(name, `es`)
+	case strings.HasSuffix(name, `ss`):
+		return name
+	case strings.HasSuffix(name, `s`):
+		return strings.TrimSuffix(name, `s`)
+	default:
+		return name
+	}
+}
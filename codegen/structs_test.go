@@ -0,0 +1,50 @@
+package codegen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoName(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(`UserID`, GoName(`user_id`))
+	assert.Equal(`CreatedAt`, GoName(`created-at`))
+	assert.Equal(`APIKey`, GoName(`api_key`))
+	assert.Equal(`X2fa`, GoName(`2fa`))
+	assert.Equal(`Name`, GoName(`Name`))
+}
+
+func TestGenerateStructs(t *testing.T) {
+	assert := require.New(t)
+	var buf bytes.Buffer
+
+	assert.Nil(GenerateStructs(&buf, []*dal.Collection{
+		dal.NewCollection(`users`).AddFields(dal.Field{
+			Name:     `name`,
+			Type:     dal.StringType,
+			Required: true,
+		}, dal.Field{
+			Name: `created_at`,
+			Type: dal.TimeType,
+		}),
+	}, StructOptions{
+		Package:   `models`,
+		Accessors: true,
+	}))
+
+	src := buf.String()
+
+	assert.Contains(src, "package models\n")
+	assert.Contains(src, `"time"`)
+	assert.Contains(src, "type User struct {\n")
+	assert.Contains(src, "ID        int64     `pivot:\"id,identity\" json:\"id\"`")
+	assert.Contains(src, "Name      string    `pivot:\"name\" json:\"name\"`")
+	assert.Contains(src, "CreatedAt time.Time `pivot:\"created_at,omitempty\" json:\"created_at,omitempty\"`")
+	assert.Contains(src, `func (self *User) GetName() string {`)
+	assert.Contains(src, `func (self *User) SetCreatedAt(value time.Time) {`)
+	assert.NotContains(src, `Validate()`)
+}
@@ -0,0 +1,239 @@
+package pivot
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var DefaultCompressionMinSize = 1024
+
+var compressibleContentTypes = []string{
+	`application/json`,
+	ContentTypeNDJSON,
+	ContentTypeMsgPack,
+	ContentTypeCBOR,
+	`text/`,
+	`application/javascript`,
+	`image/svg+xml`,
+}
+
+// A negroni middleware that compresses responses using gzip or deflate (as negotiated with the
+// client via Accept-Encoding).  Responses are buffered until they reach MinSize bytes; smaller
+// responses are sent uncompressed since the savings would not be worth the overhead.
+type Compressor struct {
+	MinSize int
+	Level   int
+}
+
+func NewCompressor(minSize int) *Compressor {
+	return &Compressor{
+		MinSize: minSize,
+		Level:   gzip.DefaultCompression,
+	}
+}
+
+func (self *Compressor) ServeHTTP(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	encoding := negotiateEncoding(req.Header.Get(`Accept-Encoding`))
+
+	if encoding == `` || req.Method == `HEAD` {
+		next(w, req)
+		return
+	}
+
+	w.Header().Add(`Vary`, `Accept-Encoding`)
+
+	cw := &compressWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		minSize:        self.MinSize,
+		level:          self.Level,
+	}
+
+	defer cw.Close()
+	next(cw, req)
+}
+
+// Returns the preferred supported encoding from the given Accept-Encoding header, or an empty
+// string if none of the supported encodings are acceptable.
+func negotiateEncoding(acceptEncoding string) string {
+	var deflateOk bool
+
+	for _, part := range strings.Split(acceptEncoding, `,`) {
+		coding := strings.TrimSpace(part)
+		q := 1.0
+
+		if i := strings.Index(coding, `;`); i >= 0 {
+			if v := strings.TrimSpace(coding[i+1:]); strings.HasPrefix(v, `q=`) {
+				if f, err := strconv.ParseFloat(strings.TrimPrefix(v, `q=`), 64); err == nil {
+					q = f
+				}
+			}
+
+			coding = strings.TrimSpace(coding[:i])
+		}
+
+		if q <= 0 {
+			continue
+		}
+
+		switch strings.ToLower(coding) {
+		case `gzip`, `*`:
+			return `gzip`
+		case `deflate`:
+			deflateOk = true
+		}
+	}
+
+	if deflateOk {
+		return `deflate`
+	}
+
+	return ``
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding  string
+	minSize   int
+	level     int
+	status    int
+	buffer    []byte
+	committed bool
+	encoder   io.WriteCloser
+}
+
+func (self *compressWriter) WriteHeader(status int) {
+	if self.status == 0 {
+		self.status = status
+	}
+}
+
+func (self *compressWriter) Write(data []byte) (int, error) {
+	if self.committed {
+		if self.encoder != nil {
+			return self.encoder.Write(data)
+		} else {
+			return self.ResponseWriter.Write(data)
+		}
+	}
+
+	self.buffer = append(self.buffer, data...)
+
+	if len(self.buffer) >= self.minSize {
+		if err := self.commit(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+// Flushing commits to compressing the response (if possible), since responses that are flushed are
+// typically streamed and of indeterminate length.
+func (self *compressWriter) Flush() {
+	if !self.committed {
+		self.commit(true)
+	}
+
+	if flusher, ok := self.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (self *compressWriter) Close() error {
+	if !self.committed {
+		if err := self.commit(len(self.buffer) >= self.minSize); err != nil {
+			return err
+		}
+	}
+
+	if self.encoder != nil {
+		return self.encoder.Close()
+	}
+
+	return nil
+}
+
+func (self *compressWriter) shouldCompress() bool {
+	header := self.ResponseWriter.Header()
+
+	if header.Get(`Content-Encoding`) != `` || header.Get(`Content-Range`) != `` {
+		return false
+	}
+
+	switch self.status {
+	case 0, http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNonAuthoritativeInfo,
+		http.StatusMultiStatus:
+		break
+	default:
+		if self.status < 400 {
+			return false
+		}
+	}
+
+	contentType := header.Get(`Content-Type`)
+
+	if contentType == `` && len(self.buffer) > 0 {
+		contentType = http.DetectContentType(self.buffer)
+	}
+
+	for _, ct := range compressibleContentTypes {
+		if strings.HasPrefix(contentType, ct) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *compressWriter) commit(compress bool) error {
+	self.committed = true
+
+	if compress && self.shouldCompress() {
+		header := self.ResponseWriter.Header()
+		header.Set(`Content-Encoding`, self.encoding)
+		header.Del(`Content-Length`)
+
+		switch self.encoding {
+		case `deflate`:
+			if encoder, err := flate.NewWriter(self.ResponseWriter, self.level); err == nil {
+				self.encoder = encoder
+			} else {
+				return err
+			}
+		default:
+			if encoder, err := gzip.NewWriterLevel(self.ResponseWriter, self.level); err == nil {
+				self.encoder = encoder
+			} else {
+				return err
+			}
+		}
+	}
+
+	if self.status != 0 {
+		self.ResponseWriter.WriteHeader(self.status)
+	}
+
+	if len(self.buffer) > 0 {
+		var err error
+
+		if self.encoder != nil {
+			_, err = self.encoder.Write(self.buffer)
+		} else {
+			_, err = self.ResponseWriter.Write(self.buffer)
+		}
+
+		self.buffer = nil
+		return err
+	}
+
+	return nil
+}
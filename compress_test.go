@@ -0,0 +1,132 @@
+package pivot
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert := require.New(t)
+
+	for acceptEncoding, expected := range map[string]string{
+		``:                        ``,
+		`identity`:                ``,
+		`gzip`:                    `gzip`,
+		`GZIP`:                    `gzip`,
+		`*`:                       `gzip`,
+		`deflate`:                 `deflate`,
+		`deflate, gzip`:           `gzip`,
+		`gzip;q=0, deflate`:       `deflate`,
+		`gzip;q=0, deflate;q=0`:   ``,
+		`br, deflate;q=0.5`:       `deflate`,
+		`gzip;q=0.1, deflate;q=1`: `gzip`,
+	} {
+		assert.Equal(expected, negotiateEncoding(acceptEncoding), acceptEncoding)
+	}
+}
+
+// returns a handler that responds with the given content type, status, and body
+func compressedHandler(contentType string, status int, body string) http.Handler {
+	server := negroni.New(NewCompressor(16))
+
+	server.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if contentType != `` {
+			w.Header().Set(`Content-Type`, contentType)
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+
+	return server
+}
+
+func TestCompressor(t *testing.T) {
+	assert := require.New(t)
+	large := `{"data":"` + strings.Repeat(`abcdef`, 100) + `"}`
+
+	// gzip
+	w := testRequest(compressedHandler(`application/json`, http.StatusOK, large), `GET`, `/`, ``, `Accept-Encoding`, `gzip`)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`gzip`, w.Header().Get(`Content-Encoding`))
+	assert.Equal(`Accept-Encoding`, w.Header().Get(`Vary`))
+	assert.True(w.Body.Len() < len(large))
+
+	reader, err := gzip.NewReader(w.Body)
+	assert.NoError(err)
+
+	data, err := ioutil.ReadAll(reader)
+	assert.NoError(err)
+	assert.Equal(large, string(data))
+
+	// deflate
+	w = testRequest(compressedHandler(`application/json`, http.StatusOK, large), `GET`, `/`, ``, `Accept-Encoding`, `deflate`)
+	assert.Equal(`deflate`, w.Header().Get(`Content-Encoding`))
+
+	data, err = ioutil.ReadAll(flate.NewReader(w.Body))
+	assert.NoError(err)
+	assert.Equal(large, string(data))
+
+	// errors are compressed as well
+	w = testRequest(compressedHandler(`application/json`, http.StatusNotFound, large), `GET`, `/`, ``, `Accept-Encoding`, `gzip`)
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Equal(`gzip`, w.Header().Get(`Content-Encoding`))
+
+	// but these aren't
+	for _, tc := range []struct {
+		ContentType    string
+		Status         int
+		Body           string
+		AcceptEncoding string
+	}{
+		{`application/json`, http.StatusOK, large, ``},
+		{`application/json`, http.StatusOK, `{}`, `gzip`},
+		{`image/png`, http.StatusOK, large, `gzip`},
+		{`application/json`, http.StatusNotModified, ``, `gzip`},
+	} {
+		w = testRequest(compressedHandler(tc.ContentType, tc.Status, tc.Body), `GET`, `/`, ``, `Accept-Encoding`, tc.AcceptEncoding)
+		assert.Equal(tc.Status, w.Code, "%+v", tc)
+		assert.Empty(w.Header().Get(`Content-Encoding`), "%+v", tc)
+		assert.Equal(tc.Body, w.Body.String(), "%+v", tc)
+	}
+
+	// nor are HEAD requests
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(`HEAD`, `/`, nil)
+	req.Header.Set(`Accept-Encoding`, `gzip`)
+	compressedHandler(`application/json`, http.StatusOK, large).ServeHTTP(w, req)
+	assert.Empty(w.Header().Get(`Content-Encoding`))
+}
+
+func TestCompressorFlush(t *testing.T) {
+	assert := require.New(t)
+
+	server := negroni.New(NewCompressor(1024))
+	server.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(`Content-Type`, ContentTypeNDJSON)
+		w.Write([]byte("{}\n"))
+
+		// flushing commits to compression even though the response is small
+		w.(http.Flusher).Flush()
+		w.Write([]byte("{}\n"))
+	}))
+
+	w := testRequest(server, `GET`, `/`, ``, `Accept-Encoding`, `gzip`)
+	assert.Equal(`gzip`, w.Header().Get(`Content-Encoding`))
+	assert.True(w.Flushed)
+
+	reader, err := gzip.NewReader(w.Body)
+	assert.NoError(err)
+
+	data, err := ioutil.ReadAll(reader)
+	assert.NoError(err)
+	assert.Equal("{}\n{}\n", string(data))
+}
@@ -0,0 +1,125 @@
+package pivot
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+)
+
+// Computes an entity tag for the given record.  If the collection specifies a VersionField and
+// the record has a value for it, that value is used.  Otherwise, a hash of the record's contents
+// is used, with the collection's hidden and redacted fields masked so that the tag reveals nothing
+// about their values.
+func etagForRecord(collection *dal.Collection, record *dal.Record) string {
+	if record == nil {
+		return ``
+	}
+
+	if collection != nil && collection.VersionField != `` {
+		if v := record.Get(collection.VersionField); v != nil {
+			switch v.(type) {
+			case time.Time:
+				v = v.(time.Time).UnixNano()
+			case *time.Time:
+				v = v.(*time.Time).UnixNano()
+			}
+
+			return fmt.Sprintf("%q", fmt.Sprintf("%v-%v", record.ID, v))
+		}
+	}
+
+	if collection != nil {
+		record = collection.MaskRecord(record)
+	}
+
+	if data, err := json.Marshal(record); err == nil {
+		sum := sha1.Sum(data)
+		return fmt.Sprintf("%q", hex.EncodeToString(sum[:]))
+	}
+
+	return ``
+}
+
+// Returns whether the given header value (e.g.: If-Match, If-None-Match) matches the given ETag.
+// Strong comparison (as If-Match requires) never matches weak tags; weak comparison (as
+// If-None-Match uses) ignores the W/ prefix on either side.
+func etagMatches(header string, etag string, strong bool) bool {
+	if etag == `` {
+		return false
+	}
+
+	if strings.HasPrefix(etag, `W/`) {
+		if strong {
+			return false
+		}
+
+		etag = strings.TrimPrefix(etag, `W/`)
+	}
+
+	for _, candidate := range strings.Split(header, `,`) {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == `*` {
+			return true
+		} else if strings.HasPrefix(candidate, `W/`) {
+			if strong {
+				continue
+			}
+
+			candidate = strings.TrimPrefix(candidate, `W/`)
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verifies that the current version of the record identified by the given ID matches the If-Match
+// header of the request (if one was given).  If the precondition fails, a 412 response is written
+// and false is returned.
+//
+// Note that this check and the subsequent write are not performed atomically, so this provides
+// protection against lost updates between clients but not against true races.
+func (self *Server) checkIfMatch(w http.ResponseWriter, req *http.Request, name string, id interface{}) bool {
+	ifMatch := req.Header.Get(`If-Match`)
+
+	if ifMatch == `` {
+		return true
+	}
+
+	// entity tags identify a single record
+	if ids, ok := id.([]string); ok {
+		if len(ids) != 1 {
+			respond(w, req, fmt.Errorf("If-Match can only be used with a single record"), http.StatusBadRequest)
+			return false
+		}
+
+		id = ids[0]
+	}
+
+	if collection, err := self.db(req).GetCollection(name); err == nil {
+		if current, err := self.db(req).Retrieve(name, id); err == nil {
+			if etagMatches(ifMatch, etagForRecord(collection, current), true) {
+				return true
+			}
+		} else if !dal.IsNotExistError(err) {
+			respond(w, req, err)
+			return false
+		}
+	} else {
+		respond(w, req, err, http.StatusNotFound)
+		return false
+	}
+
+	respond(w, req, dal.StaleRecord(id), http.StatusPreconditionFailed)
+	return false
+}
@@ -0,0 +1,118 @@
+package pivot
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtagForRecord(t *testing.T) {
+	assert := require.New(t)
+
+	collection := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name:   `password`,
+		Type:   dal.StringType,
+		Hidden: true,
+	}, dal.Field{
+		Name:     `email`,
+		Type:     dal.StringType,
+		Redacted: true,
+	})
+
+	a := dal.NewRecord(1).Set(`name`, `tester`).Set(`password`, `hunter2`).Set(`email`, `a@example.com`)
+	b := dal.NewRecord(1).Set(`name`, `tester`).Set(`password`, `hunter3`).Set(`email`, `b@example.com`)
+	c := dal.NewRecord(1).Set(`name`, `other`).Set(`password`, `hunter2`).Set(`email`, `a@example.com`)
+
+	assert.Empty(etagForRecord(collection, nil))
+	assert.NotEmpty(etagForRecord(collection, a))
+
+	// hidden and redacted values don't contribute to the tag
+	assert.Equal(etagForRecord(collection, a), etagForRecord(collection, b))
+	assert.NotEqual(etagForRecord(collection, a), etagForRecord(collection, c))
+
+	// and computing it leaves the record alone
+	assert.Equal(`hunter2`, a.Get(`password`))
+
+	// version fields are used as-is
+	collection.VersionField = `updated_at`
+	a.Set(`updated_at`, time.Unix(0, 42))
+
+	assert.Equal(`"1-42"`, etagForRecord(collection, a))
+}
+
+func TestEtagMatches(t *testing.T) {
+	assert := require.New(t)
+
+	for _, strong := range []bool{true, false} {
+		assert.True(etagMatches(`"abc"`, `"abc"`, strong))
+		assert.True(etagMatches(`"xyz", "abc"`, `"abc"`, strong))
+		assert.True(etagMatches(`*`, `"abc"`, strong))
+		assert.False(etagMatches(`"xyz"`, `"abc"`, strong))
+		assert.False(etagMatches(`*`, ``, strong))
+	}
+
+	// weak tags only match when compared weakly (as If-None-Match does)
+	assert.True(etagMatches(`W/"abc"`, `"abc"`, false))
+	assert.True(etagMatches(`"abc"`, `W/"abc"`, false))
+	assert.True(etagMatches(`W/"abc"`, `W/"abc"`, false))
+
+	// ...but never when compared strongly (as If-Match does)
+	assert.False(etagMatches(`W/"abc"`, `"abc"`, true))
+	assert.False(etagMatches(`"abc"`, `W/"abc"`, true))
+	assert.False(etagMatches(`W/"abc"`, `W/"abc"`, true))
+	assert.True(etagMatches(`W/"abc", "abc"`, `"abc"`, true))
+}
+
+func TestConditionalRequests(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+	assert.NoError(mock.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `first`),
+		dal.NewRecord(2).Set(`name`, `second`),
+	)))
+
+	w := testRequest(handler, `GET`, `/api/collections/users/records/1`, ``)
+	assert.Equal(http.StatusOK, w.Code)
+
+	etag := w.Header().Get(`ETag`)
+	assert.NotEmpty(etag)
+
+	// unchanged records aren't sent again
+	w = testRequest(handler, `GET`, `/api/collections/users/records/1`, ``, `If-None-Match`, etag)
+	assert.Equal(http.StatusNotModified, w.Code)
+
+	// ...even when asked about weakly
+	w = testRequest(handler, `GET`, `/api/collections/users/records/1`, ``, `If-None-Match`, `W/`+etag)
+	assert.Equal(http.StatusNotModified, w.Code)
+
+	// writes with a stale or weak tag are refused
+	w = testRequest(handler, `PUT`, `/api/collections/users/records/1`, `{"fields":{"name":"changed"}}`, `If-Match`, `W/`+etag)
+	assert.Equal(http.StatusPreconditionFailed, w.Code)
+
+	w = testRequest(handler, `PUT`, `/api/collections/users/records/1`, `{"fields":{"name":"changed"}}`, `If-Match`, `"stale"`)
+	assert.Equal(http.StatusPreconditionFailed, w.Code)
+
+	w = testRequest(handler, `PUT`, `/api/collections/users/records/1`, `{"fields":{"name":"changed"}}`, `If-Match`, etag)
+	assert.Equal(http.StatusOK, w.Code)
+
+	// the tag changes with the record
+	w = testRequest(handler, `GET`, `/api/collections/users/records/1`, ``, `If-None-Match`, etag)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.NotEqual(etag, w.Header().Get(`ETag`))
+
+	w = testRequest(handler, `DELETE`, `/api/collections/users/records/1`, ``, `If-Match`, etag)
+	assert.Equal(http.StatusPreconditionFailed, w.Code)
+
+	// a tag can't vouch for several records at once
+	w = testRequest(handler, `DELETE`, `/api/collections/users/records/1/2`, ``, `If-Match`, `*`)
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Empty(mock.CallsTo(`Delete`))
+}
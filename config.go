@@ -8,9 +8,40 @@ import (
 )
 
 type Configuration struct {
-	Backend      string                   `json:"backend"`
-	Indexer      string                   `json:"indexer"`
-	Environments map[string]Configuration `json:"environments"`
+	Backend           string                   `json:"backend"`
+	Indexer           string                   `json:"indexer"`
+	AdminToken        string                   `json:"admin_token,omitempty"`
+	TenantTokenSecret string                   `json:"tenant_token_secret,omitempty"`
+	Cors              *CorsConfig              `json:"cors,omitempty"`
+	TLS               *TLSConfig               `json:"tls,omitempty"`
+	Quotas            *QuotaConfig             `json:"quotas,omitempty"`
+	Environments      map[string]Configuration `json:"environments"`
+}
+
+type CorsConfig struct {
+	AllowOrigins     []string `json:"allow_origins"`
+	AllowMethods     []string `json:"allow_methods"`
+	AllowHeaders     []string `json:"allow_headers"`
+	ExposeHeaders    []string `json:"expose_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"`
+}
+
+func DefaultCorsConfig() *CorsConfig {
+	return &CorsConfig{
+		AllowOrigins:     []string{`*`},
+		AllowMethods:     []string{`GET`, `POST`, `PUT`, `DELETE`},
+		AllowHeaders:     []string{`*`},
+		AllowCredentials: true,
+		MaxAge:           3600,
+	}
+}
+
+type TLSConfig struct {
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	ClientCAFile string `json:"client_ca_file,omitempty"`
+	ClientAuth   string `json:"client_auth,omitempty"`
 }
 
 func LoadConfigFile(path string) (Configuration, error) {
@@ -0,0 +1,75 @@
+package pivot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigFileCors(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-config-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, `pivot.yml`)
+
+	assert.NoError(ioutil.WriteFile(filename, []byte(`
+backend: sqlite:///tmp/test.db
+cors:
+  allow_origins: ['https://app.example.com']
+  allow_methods: [GET]
+  max_age: 60
+environments:
+  production:
+    cors:
+      allow_origins: ['https://example.com']
+`), 0644))
+
+	config, err := LoadConfigFile(filename)
+	assert.NoError(err)
+	assert.Equal([]string{`https://app.example.com`}, config.Cors.AllowOrigins)
+	assert.Equal([]string{`GET`}, config.Cors.AllowMethods)
+	assert.Equal(60, config.Cors.MaxAge)
+
+	production := config.ForEnv(`production`)
+	assert.Equal(`sqlite:///tmp/test.db`, production.Backend)
+	assert.Equal([]string{`https://example.com`}, production.Cors.AllowOrigins)
+
+	// unknown environments get the general configuration
+	assert.Equal(config.Cors, config.ForEnv(`staging`).Cors)
+}
+
+func TestCorsHeaders(t *testing.T) {
+	assert := require.New(t)
+
+	_, mock, handler := newTestServer(func(server *Server) {
+		server.Cors = &CorsConfig{
+			AllowOrigins: []string{`https://app.example.com`},
+			AllowMethods: []string{`GET`},
+		}
+	})
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+
+	w := testRequest(handler, `GET`, `/api/schema/users`, ``, `Origin`, `https://app.example.com`)
+	assert.Equal(`https://app.example.com`, w.Header().Get(`Access-Control-Allow-Origin`))
+
+	w = testRequest(handler, `GET`, `/api/schema/users`, ``, `Origin`, `https://evil.example.com`)
+	assert.Empty(w.Header().Get(`Access-Control-Allow-Origin`))
+
+	// with CORS disabled, no origins are permitted
+	_, mock, handler = newTestServer(func(server *Server) {
+		server.Cors = nil
+	})
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+
+	w = testRequest(handler, `GET`, `/api/schema/users`, ``, `Origin`, `https://app.example.com`)
+	assert.Empty(w.Header().Get(`Access-Control-Allow-Origin`))
+}
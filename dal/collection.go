@@ -3,6 +3,8 @@ package dal
 import (
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
 
 	"github.com/ghetzel/go-stockutil/typeutil"
 )
@@ -18,6 +20,7 @@ const (
 )
 
 var DefaultIdentityField = `id`
+var RedactedValue = `********`
 var DefaultIdentityFieldType Type = IntType
 
 // Used by consumers Collection.NewInstance that wish to modify the instance
@@ -33,15 +36,25 @@ type Collection struct {
 	IndexName                string                  `json:"index_name,omitempty"`
 	IndexCompoundFields      []string                `json:"index_compound_fields,omitempty"`
 	IndexCompoundFieldJoiner string                  `json:"index_compound_field_joiner,omitempty"`
+	IndexShards              int                     `json:"index_shards,omitempty"`
+	IndexLanguage            string                  `json:"index_language,omitempty"`
 	SkipIndexPersistence     bool                    `json:"skip_index_persistence,omitempty"`
 	Fields                   []Field                 `json:"fields"`
 	IdentityField            string                  `json:"identity_field,omitempty"`
 	IdentityFieldType        Type                    `json:"identity_field_type,omitempty"`
+	VersionField             string                  `json:"version_field,omitempty"`
+	NamingConvention         *NamingConvention       `json:"naming_convention,omitempty"`
+	MaxRecordBytes           int                     `json:"max_record_bytes,omitempty"`
+	MaxFields                int                     `json:"max_fields,omitempty"`
+	MaxStringLength          int                     `json:"max_string_length,omitempty"`
+	MaxArrayElements         int                     `json:"max_array_elements,omitempty"`
+	TTL                      int                     `json:"ttl,omitempty"`
 	IdentityFieldFormatter   FieldFormatterFunc      `json:"-"`
 	IdentityFieldValidator   FieldValidatorFunc      `json:"-"`
 	PreSaveValidator         CollectionValidatorFunc `json:"-"`
 	recordType               reflect.Type
 	instanceInitializer      InitializerFunc
+	lock                     sync.RWMutex
 }
 
 func NewCollection(name string) *Collection {
@@ -70,7 +83,21 @@ func (self *Collection) GetAggregatorName() string {
 	return self.Name
 }
 
+// Returns how long records in this collection are kept after they were last written, as given (in
+// seconds) by the TTL field.  Zero means records never expire.  Only backends that can expire records
+// support collections with a TTL.
+func (self *Collection) GetTTL() time.Duration {
+	if self.TTL > 0 {
+		return time.Duration(self.TTL) * time.Second
+	}
+
+	return 0
+}
+
 func (self *Collection) SetIdentity(name string, idtype Type, formatter FieldFormatterFunc, validator FieldValidatorFunc) *Collection {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
 	if name != `` {
 		self.IdentityField = name
 	}
@@ -88,11 +115,102 @@ func (self *Collection) SetIdentity(name string, idtype Type, formatter FieldFor
 	return self
 }
 
+// Returns the naming convention used to map struct fields to this collection's fields.
+func (self *Collection) GetNamingConvention() *NamingConvention {
+	if self != nil && self.NamingConvention != nil {
+		return self.NamingConvention
+	}
+
+	return DefaultNamingConvention
+}
+
 func (self *Collection) AddFields(fields ...Field) *Collection {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
 	self.Fields = append(self.Fields, fields...)
 	return self
 }
 
+// Replaces the field with the same name as the one given, or adds it if no such field exists.
+func (self *Collection) SetField(field Field) *Collection {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for i, existing := range self.Fields {
+		if existing.Name == field.Name {
+			fields := make([]Field, len(self.Fields))
+			copy(fields, self.Fields)
+			fields[i] = field
+			self.Fields = fields
+			return self
+		}
+	}
+
+	self.Fields = append(self.Fields, field)
+	return self
+}
+
+// Removes the named field from the collection (if present).
+func (self *Collection) RemoveField(name string) *Collection {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	fields := make([]Field, 0, len(self.Fields))
+
+	for _, field := range self.Fields {
+		if field.Name != name {
+			fields = append(fields, field)
+		}
+	}
+
+	self.Fields = fields
+	return self
+}
+
+// Returns a copy of the collection that is safe to read (and modify) without affecting this one.
+// Collections shared between goroutines (such as those cached by backends) should only be changed
+// using the methods on Collection, and read directly only from a copy.
+func (self *Collection) Copy() *Collection {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	fields := make([]Field, len(self.Fields))
+	copy(fields, self.Fields)
+
+	var compoundFields []string
+
+	if self.IndexCompoundFields != nil {
+		compoundFields = make([]string, len(self.IndexCompoundFields))
+		copy(compoundFields, self.IndexCompoundFields)
+	}
+
+	return &Collection{
+		Name:                     self.Name,
+		IndexName:                self.IndexName,
+		IndexCompoundFields:      compoundFields,
+		IndexCompoundFieldJoiner: self.IndexCompoundFieldJoiner,
+		IndexShards:              self.IndexShards,
+		IndexLanguage:            self.IndexLanguage,
+		SkipIndexPersistence:     self.SkipIndexPersistence,
+		Fields:                   fields,
+		IdentityField:            self.IdentityField,
+		IdentityFieldType:        self.IdentityFieldType,
+		VersionField:             self.VersionField,
+		NamingConvention:         self.NamingConvention,
+		MaxRecordBytes:           self.MaxRecordBytes,
+		MaxFields:                self.MaxFields,
+		MaxStringLength:          self.MaxStringLength,
+		MaxArrayElements:         self.MaxArrayElements,
+		TTL:                      self.TTL,
+		IdentityFieldFormatter:   self.IdentityFieldFormatter,
+		IdentityFieldValidator:   self.IdentityFieldValidator,
+		PreSaveValidator:         self.PreSaveValidator,
+		recordType:               self.recordType,
+		instanceInitializer:      self.instanceInitializer,
+	}
+}
+
 // Copies certain collection and field properties from the definition object into this collection
 // instance.  This is useful for collections that are created by parsing the schema as it exists on
 // the remote datastore, which will have some but not all of the information we need to work with the
@@ -104,6 +222,11 @@ func (self *Collection) AddFields(fields ...Field) *Collection {
 //
 func (self *Collection) ApplyDefinition(definition *Collection) error {
 	if definition != nil {
+		definition = definition.Copy()
+
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
 		if v := definition.IdentityField; v != `` {
 			self.IdentityField = v
 		}
@@ -112,6 +235,34 @@ func (self *Collection) ApplyDefinition(definition *Collection) error {
 			self.IdentityFieldType = v
 		}
 
+		if v := definition.IndexLanguage; v != `` {
+			self.IndexLanguage = v
+		}
+
+		if v := definition.NamingConvention; v != nil {
+			self.NamingConvention = v
+		}
+
+		if v := definition.MaxRecordBytes; v > 0 {
+			self.MaxRecordBytes = v
+		}
+
+		if v := definition.MaxFields; v > 0 {
+			self.MaxFields = v
+		}
+
+		if v := definition.MaxStringLength; v > 0 {
+			self.MaxStringLength = v
+		}
+
+		if v := definition.MaxArrayElements; v > 0 {
+			self.MaxArrayElements = v
+		}
+
+		if v := definition.TTL; v > 0 {
+			self.TTL = v
+		}
+
 		if fn := definition.IdentityFieldFormatter; fn != nil {
 			self.IdentityFieldFormatter = fn
 		}
@@ -120,40 +271,54 @@ func (self *Collection) ApplyDefinition(definition *Collection) error {
 			self.IdentityFieldValidator = fn
 		}
 
+		// fields are updated in a copy so that anything still holding the old slice is unaffected
+		fields := make([]Field, len(self.Fields))
+		copy(fields, self.Fields)
+
 		for i, field := range self.Fields {
 			if defField, ok := definition.GetField(field.Name); ok {
 				if field.Description == `` {
-					self.Fields[i].Description = defField.Description
+					fields[i].Description = defField.Description
 				}
 
 				if field.Length == 0 && defField.Length != 0 {
-					self.Fields[i].Length = defField.Length
+					fields[i].Length = defField.Length
 				}
 
 				if field.Precision == 0 && defField.Precision != 0 {
-					self.Fields[i].Precision = defField.Precision
+					fields[i].Precision = defField.Precision
 				}
 
 				// unconditionally pull these over as they are either client-only fields or we know better
 				// than the database on this one
-				self.Fields[i].Required = defField.Required
-				self.Fields[i].Type = defField.Type
-				self.Fields[i].KeyType = defField.KeyType
-				self.Fields[i].Subtype = defField.Subtype
-				self.Fields[i].DefaultValue = defField.DefaultValue
-				self.Fields[i].ValidateOnPopulate = defField.ValidateOnPopulate
-				self.Fields[i].Validator = defField.Validator
-				self.Fields[i].Formatter = defField.Formatter
+				fields[i].Required = defField.Required
+				fields[i].Type = defField.Type
+				fields[i].KeyType = defField.KeyType
+				fields[i].Subtype = defField.Subtype
+				fields[i].DefaultValue = defField.DefaultValue
+				fields[i].ValidateOnPopulate = defField.ValidateOnPopulate
+				fields[i].Hidden = defField.Hidden
+				fields[i].Redacted = defField.Redacted
+				fields[i].IndexAnalyzer = defField.IndexAnalyzer
+				fields[i].IndexNoStore = defField.IndexNoStore
+				fields[i].IndexLanguage = defField.IndexLanguage
+				fields[i].Validator = defField.Validator
+				fields[i].Formatter = defField.Formatter
 			} else {
 				return fmt.Errorf("Definition is missing field %q", field.Name)
 			}
 		}
+
+		self.Fields = fields
 	}
 
 	return nil
 }
 
 func (self *Collection) SetRecordType(in interface{}) *Collection {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
 	inV := reflect.ValueOf(in)
 
 	if inV.Kind() == reflect.Ptr {
@@ -179,6 +344,9 @@ func (self *Collection) HasRecordType() bool {
 }
 
 func (self *Collection) SetInitializer(init InitializerFunc) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
 	self.instanceInitializer = init
 }
 
@@ -199,7 +367,7 @@ func (self *Collection) NewInstance(initializers ...InitializerFunc) interface{}
 		instanceV = reflect.ValueOf(instance).Elem()
 	}
 
-	structFields, _ := getFieldsForStruct(instance)
+	structFields, _ := getFieldsForStruct(instance, self.GetNamingConvention())
 
 	for _, field := range self.Fields {
 		var zeroValue interface{}
@@ -269,7 +437,8 @@ func (self *Collection) NewInstance(initializers ...InitializerFunc) interface{}
 func (self *Collection) FillDefaults(record *Record) {
 	for _, field := range self.Fields {
 		if field.DefaultValue != nil {
-			if typeutil.IsZero(record.Get(field.Name)) {
+			// fields explicitly set to null are left that way
+			if !record.IsNull(field.Name) && typeutil.IsZero(record.Get(field.Name)) {
 				record.Set(field.Name, field.GetDefaultValue())
 			}
 		}
@@ -277,6 +446,9 @@ func (self *Collection) FillDefaults(record *Record) {
 }
 
 func (self *Collection) GetField(name string) (Field, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
 	for _, field := range self.Fields {
 		if field.Name == name {
 			return field, true
@@ -296,6 +468,67 @@ func (self *Collection) GetField(name string) (Field, bool) {
 	return Field{}, false
 }
 
+// Returns a copy of the given record with hidden fields removed and the values of redacted fields
+// replaced with RedactedValue.  This is intended to be applied to records as they are returned to
+// clients; the given record (which may be shared with a backend's cache) is not modified.
+func (self *Collection) MaskRecord(record *Record) *Record {
+	if record == nil {
+		return nil
+	}
+
+	masked := &Record{
+		ID:       record.ID,
+		Fields:   make(map[string]interface{}, len(record.Fields)),
+		Data:     record.Data,
+		Error:    record.Error,
+		Score:    record.Score,
+		Revision: record.Revision,
+	}
+
+	for k, v := range record.Fields {
+		masked.Fields[k] = v
+	}
+
+	for _, field := range self.Fields {
+		if field.Hidden {
+			delete(masked.Fields, field.Name)
+		} else if field.Redacted {
+			if _, ok := masked.Fields[field.Name]; ok {
+				masked.Fields[field.Name] = RedactedValue
+			}
+		}
+	}
+
+	return masked
+}
+
+// Returns a copy of the given RecordSet with MaskRecord applied to every record in it, including any
+// that were spilled to disk.
+func (self *Collection) MaskRecordSet(recordset *RecordSet) *RecordSet {
+	if recordset == nil {
+		return nil
+	}
+
+	masked := &RecordSet{
+		ResultCount:    recordset.ResultCount,
+		Page:           recordset.Page,
+		TotalPages:     recordset.TotalPages,
+		RecordsPerPage: recordset.RecordsPerPage,
+		Records:        make([]*Record, 0, recordset.Len()),
+		Options:        recordset.Options,
+		KnownSize:      recordset.KnownSize,
+	}
+
+	if err := recordset.Each(func(record *Record) error {
+		masked.Records = append(masked.Records, self.MaskRecord(record))
+		return nil
+	}); err != nil {
+		log.Warningf("failed to read spilled records: %v", err)
+	}
+
+	return masked
+}
+
 func (self *Collection) IsIdentityField(name string) bool {
 	if field, ok := self.GetField(name); ok {
 		return field.Identity
@@ -322,6 +555,18 @@ func (self *Collection) GetFirstNonIdentityKeyField() (Field, bool) {
 	return Field{}, false
 }
 
+// Returns the field flagged as the sort key, which backends that partition records by their identity
+// (e.g.: DynamoDB) use to order (and further identify) the records within each partition.
+func (self *Collection) GetSortKeyField() (Field, bool) {
+	for _, field := range self.Fields {
+		if field.SortKey && !field.Identity {
+			return field, true
+		}
+	}
+
+	return Field{}, false
+}
+
 func (self *Collection) ConvertValue(name string, value interface{}) interface{} {
 	if field, ok := self.GetField(name); ok {
 		if v, err := field.ConvertValue(value); err == nil {
@@ -405,7 +650,7 @@ func (self *Collection) MakeRecord(in interface{}) (*Record, error) {
 	self.FillDefaults(record)
 
 	// get details for the fields present on the given input struct
-	if fields, err := getFieldsForStruct(in); err == nil {
+	if fields, err := getFieldsForStruct(in, self.GetNamingConvention()); err == nil {
 		// for each field descriptor...
 		for tagName, fieldDescr := range fields {
 			if fieldDescr.Field.IsExported() {
@@ -505,6 +750,10 @@ func (self *Collection) MakeRecord(in interface{}) (*Record, error) {
 func (self *Collection) ValidateRecord(record *Record, op FieldOperation) error {
 	switch op {
 	case PersistOperation:
+		if err := self.CheckLimits(record); err != nil {
+			return err
+		}
+
 		// validate whole record (if specified)
 		if self.PreSaveValidator != nil {
 			if err := self.PreSaveValidator(record); err != nil {
@@ -516,7 +765,72 @@ func (self *Collection) ValidateRecord(record *Record, op FieldOperation) error
 	return nil
 }
 
+// Describes why a field in an existing record does not satisfy the collection's schema.
+type FieldViolation struct {
+	Field string      `json:"field,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+	Error string      `json:"error"`
+}
+
+// Checks the given record against the collection's schema, returning a description of every field
+// that is missing, cannot be converted to the field's declared type, or fails the field's validator.
+// Record-level validators are also applied.  Unlike MakeRecord, the record is not modified.
+func (self *Collection) CheckRecord(record *Record) []FieldViolation {
+	violations := make([]FieldViolation, 0)
+
+	for _, field := range self.Fields {
+		if self.IsIdentityField(field.Name) {
+			continue
+		}
+
+		value := record.Get(field.Name)
+
+		if value == nil {
+			if field.Required && field.DefaultValue == nil {
+				violations = append(violations, FieldViolation{
+					Field: field.Name,
+					Error: `required field is missing`,
+				})
+			}
+
+			continue
+		}
+
+		converted, err := field.ConvertValue(value)
+
+		if err != nil {
+			violations = append(violations, FieldViolation{
+				Field: field.Name,
+				Value: value,
+				Error: fmt.Sprintf("cannot convert to %s: %v", field.Type, err),
+			})
+
+			continue
+		}
+
+		if err := field.Validate(converted); err != nil {
+			violations = append(violations, FieldViolation{
+				Field: field.Name,
+				Value: value,
+				Error: err.Error(),
+			})
+		}
+	}
+
+	if err := self.ValidateRecord(record, PersistOperation); err != nil {
+		violations = append(violations, FieldViolation{
+			Error: err.Error(),
+		})
+	}
+
+	return violations
+}
+
 func (self *Collection) Diff(actual *Collection) []SchemaDelta {
+	// compare snapshots so that neither collection can change out from under us
+	self = self.Copy()
+	actual = actual.Copy()
+
 	differences := make([]SchemaDelta, 0)
 
 	if self.Name != actual.Name {
@@ -3,6 +3,7 @@ package dal
 import (
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -142,3 +143,197 @@ func TestCollectionValidator(t *testing.T) {
 	assert.Error(collection.ValidateRecord(NewRecord(`two`), PersistOperation))
 	assert.NoError(collection.ValidateRecord(NewRecord(`three`), PersistOperation))
 }
+
+func TestCollectionCheckRecord(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionCheckRecord`).AddFields(Field{
+		Name:     `name`,
+		Type:     StringType,
+		Required: true,
+	}, Field{
+		Name:      `status`,
+		Type:      StringType,
+		Validator: ValidateIsOneOf(`active`, `inactive`),
+	}, Field{
+		Name: `created_at`,
+		Type: TimeType,
+	})
+
+	assert.Empty(collection.CheckRecord(NewRecord(1).SetFields(map[string]interface{}{
+		`name`:       `First`,
+		`status`:     `active`,
+		`created_at`: `2018-01-01T00:00:00Z`,
+	})))
+
+	violations := collection.CheckRecord(NewRecord(2).SetFields(map[string]interface{}{
+		`status`:     `deleted`,
+		`created_at`: `not a date`,
+	}))
+
+	assert.Len(violations, 3)
+	assert.Equal(`name`, violations[0].Field)
+	assert.Equal(`status`, violations[1].Field)
+	assert.Equal(`deleted`, violations[1].Value)
+	assert.Equal(`created_at`, violations[2].Field)
+}
+
+func TestCollectionMaskRecord(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionMaskRecord`)
+	collection.AddFields([]Field{
+		{
+			Name: `name`,
+			Type: StringType,
+		}, {
+			Name:   `password`,
+			Type:   StringType,
+			Hidden: true,
+		}, {
+			Name:     `token`,
+			Type:     StringType,
+			Redacted: true,
+		}, {
+			Name:     `secret`,
+			Type:     StringType,
+			Redacted: true,
+		},
+	}...)
+
+	record := NewRecord(1).SetFields(map[string]interface{}{
+		`name`:     `tester`,
+		`password`: `hunter2`,
+		`token`:    `abc123`,
+	})
+
+	masked := collection.MaskRecord(record)
+
+	assert.Equal(`tester`, masked.Get(`name`))
+	assert.Nil(masked.Get(`password`))
+	assert.Equal(RedactedValue, masked.Get(`token`))
+
+	_, ok := masked.Fields[`secret`]
+	assert.False(ok)
+
+	// the original record is left alone, since it may be shared (e.g.: with a backend's cache)
+	assert.Equal(`hunter2`, record.Get(`password`))
+	assert.Equal(`abc123`, record.Get(`token`))
+	assert.Nil(collection.MaskRecord(nil))
+}
+
+func TestCollectionMaskRecordSet(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionMaskRecordSet`).AddFields(Field{
+		Name: `name`,
+		Type: StringType,
+	}, Field{
+		Name:   `password`,
+		Type:   StringType,
+		Hidden: true,
+	})
+
+	recordset := NewRecordSet().SetMemoryLimit(1, collection)
+	defer recordset.Release()
+
+	recordset.Push(NewRecord(1).Set(`name`, `first`).Set(`password`, `hunter2`))
+	recordset.Push(NewRecord(2).Set(`name`, `second`).Set(`password`, `hunter3`))
+	assert.Equal(1, recordset.Spilled())
+
+	// records that were spilled to disk are masked too
+	masked := collection.MaskRecordSet(recordset)
+	assert.Equal(2, masked.Len())
+	assert.Equal([]interface{}{`first`, `second`}, masked.Pluck(`name`))
+	assert.Equal([]interface{}{nil, nil}, masked.Pluck(`password`))
+	assert.Equal(`hunter2`, recordset.Records[0].Get(`password`))
+}
+
+func TestCollectionCopyAndConcurrentMutation(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionCopyAndConcurrentMutation`).AddFields(Field{
+		Name: `name`,
+		Type: StringType,
+	})
+
+	snapshot := collection.Copy()
+	collection.SetField(Field{
+		Name: `name`,
+		Type: IntType,
+	}).AddFields(Field{
+		Name: `age`,
+		Type: IntType,
+	})
+
+	// the snapshot is unaffected by changes to the original
+	assert.Len(snapshot.Fields, 1)
+	assert.Equal(StringType, snapshot.Fields[0].Type)
+
+	field, ok := collection.GetField(`name`)
+	assert.True(ok)
+	assert.Equal(IntType, field.Type)
+
+	collection.RemoveField(`name`)
+	_, ok = collection.GetField(`name`)
+	assert.False(ok)
+	assert.Len(collection.Fields, 1)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			collection.AddFields(Field{
+				Name: fmt.Sprintf("field%d", i),
+				Type: StringType,
+			})
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			collection.Diff(snapshot)
+			collection.GetField(`age`)
+			collection.Copy()
+		}()
+	}
+
+	wg.Wait()
+	assert.Len(collection.Fields, 9)
+}
+
+func TestCollectionGetSortKeyField(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionGetSortKeyField`).AddFields(Field{
+		Name: `name`,
+		Type: StringType,
+		Key:  true,
+	})
+
+	_, ok := collection.GetSortKeyField()
+	assert.False(ok)
+
+	collection.AddFields(Field{
+		Name:    `created_at`,
+		Type:    TimeType,
+		SortKey: true,
+	})
+
+	field, ok := collection.GetSortKeyField()
+	assert.True(ok)
+	assert.Equal(`created_at`, field.Name)
+}
+
+func TestCollectionGetTTL(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionGetTTL`)
+	assert.Zero(collection.GetTTL())
+
+	collection.TTL = 90
+	assert.Equal(90*time.Second, collection.GetTTL())
+	assert.Equal(90*time.Second, collection.Copy().GetTTL())
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/jdxcode/netrc"
 )
@@ -119,6 +120,22 @@ func (self *ConnectionString) HasOpt(key string) bool {
 	return ok
 }
 
+// Returns the options given in the connection string except those named, exactly as they were given.
+// Backends use this to pass options they don't handle themselves through to the database driver.
+func (self *ConnectionString) PassthroughOptions(exclude ...string) url.Values {
+	opts := make(url.Values)
+
+	if self.URI != nil {
+		for key, values := range self.URI.Query() {
+			if !sliceutil.ContainsString(exclude, key) {
+				opts[key] = values
+			}
+		}
+	}
+
+	return opts
+}
+
 func (self *ConnectionString) OptString(key string, fallback string) string {
 	if v, ok := self.Options[key]; ok {
 		if vConv, err := stringutil.ConvertToString(v); err == nil {
@@ -0,0 +1,24 @@
+package dal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionStringPassthroughOptions(t *testing.T) {
+	assert := require.New(t)
+
+	conn, err := ParseConnectionString(`mysql://localhost/test?autoregister=true&collation=utf8mb4_unicode_ci&readTimeout=30s&loc=America%2FNew_York`)
+	assert.NoError(err)
+
+	opts := conn.PassthroughOptions(`autoregister`)
+	assert.Len(opts, 3)
+	assert.Equal(`utf8mb4_unicode_ci`, opts.Get(`collation`))
+	assert.Equal(`30s`, opts.Get(`readTimeout`))
+	assert.Equal(`America/New_York`, opts.Get(`loc`))
+	assert.Empty(opts.Get(`autoregister`))
+
+	// options are still available to pivot as usual
+	assert.True(conn.OptBool(`autoregister`, false))
+}
@@ -0,0 +1,103 @@
+package dal
+
+import (
+	"time"
+)
+
+// Converts a single value to the type of a particular field.
+type ValueConverter func(value interface{}) interface{}
+
+// Returns a ValueConverter for the named field, which behaves like ConvertValue but only looks the
+// field up once, and returns values that are already of the field's type without reconverting them.
+// Values of unknown fields are returned as-is.
+func (self *Collection) ValueConverter(name string) ValueConverter {
+	field, ok := self.GetField(name)
+
+	if !ok {
+		return func(value interface{}) interface{} {
+			return value
+		}
+	}
+
+	convert := func(value interface{}) interface{} {
+		if v, err := field.ConvertValue(value); err == nil {
+			return v
+		}
+
+		return value
+	}
+
+	switch field.Type {
+	case StringType:
+		return func(value interface{}) interface{} {
+			if v, ok := value.(string); ok && v != `` {
+				return v
+			}
+
+			return convert(value)
+		}
+	case IntType:
+		return func(value interface{}) interface{} {
+			if v, ok := value.(int64); ok && v != 0 {
+				return v
+			}
+
+			return convert(value)
+		}
+	case FloatType:
+		return func(value interface{}) interface{} {
+			if v, ok := value.(float64); ok && v != 0 {
+				return v
+			}
+
+			return convert(value)
+		}
+	case BooleanType:
+		return func(value interface{}) interface{} {
+			if v, ok := value.(bool); ok && v {
+				return v
+			}
+
+			return convert(value)
+		}
+	case TimeType:
+		return func(value interface{}) interface{} {
+			if v, ok := value.(time.Time); ok && !v.IsZero() {
+				return v
+			}
+
+			return convert(value)
+		}
+	default:
+		return convert
+	}
+}
+
+// Converts the field values of the given records to their destination types, working through one
+// field (column) at a time so that each field's converter is only built once no matter how many
+// records there are.  The converted values for each record are returned in the same order as the
+// records; the records themselves are not modified.
+func (self *Collection) ConvertRecordValues(records []*Record) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(records))
+	converters := make(map[string]ValueConverter)
+
+	for i, record := range records {
+		rows[i] = make(map[string]interface{}, len(record.Fields))
+
+		for name := range record.Fields {
+			if _, ok := converters[name]; !ok {
+				converters[name] = self.ValueConverter(name)
+			}
+		}
+	}
+
+	for name, convert := range converters {
+		for i, record := range records {
+			if value, ok := record.Fields[name]; ok {
+				rows[i][name] = convert(value)
+			}
+		}
+	}
+
+	return rows
+}
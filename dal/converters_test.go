@@ -0,0 +1,61 @@
+package dal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertRecordValues(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestConvertRecordValues`).AddFields(Field{
+		Name: `name`,
+		Type: StringType,
+	}, Field{
+		Name: `count`,
+		Type: IntType,
+	}, Field{
+		Name: `enabled`,
+		Type: BooleanType,
+	}, Field{
+		Name: `created_at`,
+		Type: TimeType,
+	})
+
+	now := time.Now()
+
+	records := []*Record{
+		NewRecord(1).Set(`name`, `first`).Set(`count`, `42`).Set(`enabled`, `true`),
+		NewRecord(2).Set(`name`, 1234).Set(`count`, int64(7)).Set(`created_at`, now),
+		NewRecord(3).Set(`other`, `unknown`),
+	}
+
+	rows := collection.ConvertRecordValues(records)
+	assert.Len(rows, 3)
+
+	assert.Equal(map[string]interface{}{
+		`name`:    `first`,
+		`count`:   int64(42),
+		`enabled`: true,
+	}, rows[0])
+
+	assert.Equal(map[string]interface{}{
+		`name`:       `1234`,
+		`count`:      int64(7),
+		`created_at`: now,
+	}, rows[1])
+
+	assert.Equal(map[string]interface{}{
+		`other`: `unknown`,
+	}, rows[2])
+
+	// the records themselves are left alone
+	assert.Equal(`42`, records[0].Get(`count`))
+
+	// converters agree with ConvertValue
+	for _, value := range []interface{}{nil, 0, `0`, int64(5), `5`, 5.0} {
+		assert.Equal(collection.ConvertValue(`count`, value), collection.ValueConverter(`count`)(value))
+	}
+}
@@ -0,0 +1,412 @@
+package dal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The number of rows sampled to infer field types when importing into a new collection.
+var DefaultCSVSampleSize = 1000
+
+// The number of records inserted at a time when importing.
+var DefaultCSVBatchSize = 500
+
+// The layouts that values must match to be inferred (and imported) as times.
+var CSVTimeLayouts = []string{
+	time.RFC3339Nano,
+	`2006-01-02 15:04:05`,
+	`2006-01-02T15:04:05`,
+	`2006-01-02`,
+}
+
+// The subset of a backend's functionality needed to import records into it.
+type ImportTarget interface {
+	GetCollection(name string) (*Collection, error)
+	CreateCollection(definition *Collection) error
+	Insert(collection string, records *RecordSet) error
+}
+
+type CSVImportOptions struct {
+	// The field delimiter (defaults to a comma).
+	Delimiter rune
+
+	// The column holding record IDs.  Defaults to the collection's identity field; if the CSV has no
+	// such column, records are numbered by row instead.
+	IdentityColumn string
+
+	// The number of rows used to infer field types (defaults to DefaultCSVSampleSize).
+	SampleSize int
+
+	// The number of records inserted at a time (defaults to DefaultCSVBatchSize).
+	BatchSize int
+
+	// Stop importing once this many rows have failed.  Zero means never stop.
+	MaxErrors int
+
+	// Called after each batch is inserted with the running totals of imported and failed rows.
+	Progress func(imported int, failed int)
+}
+
+// A row that could not be imported.  Rows are numbered from 1, not counting the header.
+type CSVRowError struct {
+	Row int
+	Err error
+}
+
+func (self CSVRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", self.Row, self.Err)
+}
+
+type CSVImportResult struct {
+	Collection *Collection
+	Created    bool
+	Imported   int
+	Errors     []CSVRowError
+}
+
+type csvRow struct {
+	number int
+	values []string
+}
+
+// Imports the rows of a CSV file (whose first row names its columns) into the named collection.  If
+// the collection doesn't exist, it is created with field types inferred from the first rows of the
+// file.  Rows that fail to import are collected in the result rather than stopping the import, unless
+// there are more than MaxErrors of them.
+func ImportCSV(target ImportTarget, collection string, r io.Reader, options CSVImportOptions) (*CSVImportResult, error) {
+	if options.SampleSize <= 0 {
+		options.SampleSize = DefaultCSVSampleSize
+	}
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultCSVBatchSize
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	if options.Delimiter != 0 {
+		reader.Comma = options.Delimiter
+	}
+
+	var header []string
+	result := &CSVImportResult{}
+
+	if h, err := reader.Read(); err == nil {
+		header = make([]string, len(h))
+
+		for i, name := range h {
+			header[i] = strings.TrimSpace(name)
+		}
+	} else if err == io.EOF {
+		return nil, fmt.Errorf("CSV data is empty")
+	} else {
+		return nil, err
+	}
+
+	// read the sample up front so that it can be used to infer types
+	sample := make([]csvRow, 0)
+	rowNumber := 0
+	eof := false
+
+	for len(sample) < options.SampleSize {
+		if values, err := reader.Read(); err == nil {
+			rowNumber += 1
+			sample = append(sample, csvRow{rowNumber, values})
+		} else if err == io.EOF {
+			eof = true
+			break
+		} else if _, ok := err.(*csv.ParseError); ok {
+			rowNumber += 1
+			result.Errors = append(result.Errors, CSVRowError{rowNumber, err})
+		} else {
+			return nil, err
+		}
+	}
+
+	if existing, err := target.GetCollection(collection); err == nil {
+		result.Collection = existing
+	} else if IsCollectionNotFoundErr(err) {
+		values := make([][]string, len(sample))
+
+		for i, row := range sample {
+			values[i] = row.values
+		}
+
+		definition := InferCSVCollection(collection, header, values, options.IdentityColumn)
+
+		if err := target.CreateCollection(definition); err == nil {
+			result.Collection = definition
+			result.Created = true
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+
+	identityColumn := csvIdentityColumn(result.Collection, header, options.IdentityColumn)
+	converters := make([]ValueConverter, len(header))
+
+	for i, name := range header {
+		converters[i] = result.Collection.ValueConverter(name)
+	}
+
+	batch := make([]*Record, 0, options.BatchSize)
+	rows := make([]int, 0, options.BatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		result.insertBatch(target, batch, rows)
+		batch = batch[:0]
+		rows = rows[:0]
+
+		if options.Progress != nil {
+			options.Progress(result.Imported, len(result.Errors))
+		}
+
+		if options.MaxErrors > 0 && len(result.Errors) > options.MaxErrors {
+			return fmt.Errorf("import stopped after %d errors", len(result.Errors))
+		}
+
+		return nil
+	}
+
+	add := func(row csvRow) error {
+		if record, err := csvRecord(result.Collection, header, identityColumn, converters, row); err == nil {
+			batch = append(batch, record)
+			rows = append(rows, row.number)
+		} else {
+			result.Errors = append(result.Errors, CSVRowError{row.number, err})
+		}
+
+		if len(batch) >= options.BatchSize {
+			return flush()
+		}
+
+		return nil
+	}
+
+	for _, row := range sample {
+		if err := add(row); err != nil {
+			return result, err
+		}
+	}
+
+	for !eof {
+		if values, err := reader.Read(); err == nil {
+			rowNumber += 1
+
+			if err := add(csvRow{rowNumber, values}); err != nil {
+				return result, err
+			}
+		} else if err == io.EOF {
+			break
+		} else if _, ok := err.(*csv.ParseError); ok {
+			rowNumber += 1
+			result.Errors = append(result.Errors, CSVRowError{rowNumber, err})
+		} else {
+			return result, err
+		}
+	}
+
+	return result, flush()
+}
+
+// inserts a batch of records; if the batch fails, its records are inserted one at a time so that the
+// rows that caused the failure can be reported
+func (self *CSVImportResult) insertBatch(target ImportTarget, batch []*Record, rows []int) {
+	if err := target.Insert(self.Collection.Name, NewRecordSet(batch...)); err == nil {
+		self.Imported += len(batch)
+		return
+	} else if len(batch) == 1 {
+		self.Errors = append(self.Errors, CSVRowError{rows[0], err})
+		return
+	}
+
+	for i, record := range batch {
+		if err := target.Insert(self.Collection.Name, NewRecordSet(record)); err == nil {
+			self.Imported += 1
+		} else {
+			self.Errors = append(self.Errors, CSVRowError{rows[i], err})
+		}
+	}
+}
+
+// Returns a collection definition whose field types are inferred from the given sample of rows.  The
+// identity field is taken from the named column (or an "id" column, if present); otherwise records
+// are identified by their row number.
+func InferCSVCollection(name string, header []string, sample [][]string, identityColumn string) *Collection {
+	collection := NewCollection(name)
+	identity := csvIdentityColumn(collection, header, identityColumn)
+
+	for i, column := range header {
+		values := make([]string, 0, len(sample))
+
+		for _, row := range sample {
+			if i < len(row) {
+				values = append(values, row[i])
+			}
+		}
+
+		fieldType := InferType(values)
+
+		if i == identity {
+			collection.IdentityField = column
+
+			if fieldType == IntType {
+				collection.IdentityFieldType = IntType
+			} else {
+				collection.IdentityFieldType = StringType
+			}
+
+			continue
+		}
+
+		collection.AddFields(Field{
+			Name: column,
+			Type: fieldType,
+		})
+	}
+
+	return collection
+}
+
+// Returns the most specific type that all of the given (non-empty) values can be parsed as.
+func InferType(values []string) Type {
+	candidates := map[Type]bool{
+		IntType:     true,
+		FloatType:   true,
+		BooleanType: true,
+		TimeType:    true,
+	}
+
+	seen := false
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+
+		if value == `` {
+			continue
+		}
+
+		seen = true
+
+		if candidates[IntType] {
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				candidates[IntType] = false
+			}
+		}
+
+		if candidates[FloatType] {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				candidates[FloatType] = false
+			}
+		}
+
+		if candidates[BooleanType] {
+			switch strings.ToLower(value) {
+			case `true`, `false`:
+			default:
+				candidates[BooleanType] = false
+			}
+		}
+
+		if candidates[TimeType] {
+			if _, ok := parseCSVTime(value); !ok {
+				candidates[TimeType] = false
+			}
+		}
+	}
+
+	if !seen {
+		return StringType
+	}
+
+	for _, t := range []Type{IntType, FloatType, BooleanType, TimeType} {
+		if candidates[t] {
+			return t
+		}
+	}
+
+	return StringType
+}
+
+func parseCSVTime(value string) (time.Time, bool) {
+	for _, layout := range CSVTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// returns the index of the column holding record IDs, or -1 if there isn't one
+func csvIdentityColumn(collection *Collection, header []string, identityColumn string) int {
+	if identityColumn == `` {
+		identityColumn = collection.IdentityField
+	}
+
+	if identityColumn == `` {
+		identityColumn = DefaultIdentityField
+	}
+
+	for i, column := range header {
+		if column == identityColumn {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func csvRecord(collection *Collection, header []string, identity int, converters []ValueConverter, row csvRow) (*Record, error) {
+	if len(row.values) != len(header) {
+		return nil, fmt.Errorf("expected %d columns, got %d", len(header), len(row.values))
+	}
+
+	record := NewRecord(nil)
+
+	if identity < 0 {
+		record.ID = row.number
+	}
+
+	for i, value := range row.values {
+		if value == `` {
+			continue
+		}
+
+		if i == identity {
+			if collection.IdentityFieldType == IntType {
+				if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+					record.ID = id
+				} else {
+					return nil, fmt.Errorf("invalid %s: %v", header[i], err)
+				}
+			} else {
+				record.ID = value
+			}
+
+			continue
+		}
+
+		if field, ok := collection.GetField(header[i]); ok && field.Type == TimeType {
+			if t, ok := parseCSVTime(value); ok {
+				record.Set(header[i], t)
+				continue
+			}
+		}
+
+		record.Set(header[i], converters[i](value))
+	}
+
+	return record, nil
+}
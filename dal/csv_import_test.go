@@ -0,0 +1,141 @@
+package dal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testImportTarget struct {
+	collections map[string]*Collection
+	records     map[interface{}]*Record
+	inserts     int
+}
+
+func (self *testImportTarget) GetCollection(name string) (*Collection, error) {
+	if collection, ok := self.collections[name]; ok {
+		return collection, nil
+	}
+
+	return nil, CollectionNotFound
+}
+
+func (self *testImportTarget) CreateCollection(definition *Collection) error {
+	self.collections[definition.Name] = definition
+	return nil
+}
+
+func (self *testImportTarget) Insert(name string, recordset *RecordSet) error {
+	self.inserts += 1
+
+	for _, record := range recordset.Records {
+		if record.Get(`name`) == `bad` {
+			return fmt.Errorf("bad record %v", record.ID)
+		}
+	}
+
+	for _, record := range recordset.Records {
+		self.records[record.ID] = record
+	}
+
+	return nil
+}
+
+func TestInferType(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(IntType, InferType([]string{`1`, ``, `-42`}))
+	assert.Equal(FloatType, InferType([]string{`1`, `2.5`}))
+	assert.Equal(BooleanType, InferType([]string{`true`, `FALSE`}))
+	assert.Equal(TimeType, InferType([]string{`2018-05-04`, `2018-05-04T03:02:01Z`}))
+	assert.Equal(StringType, InferType([]string{`1`, `two`}))
+	assert.Equal(StringType, InferType([]string{``, ``}))
+}
+
+func TestImportCSV(t *testing.T) {
+	assert := require.New(t)
+	target := &testImportTarget{
+		collections: make(map[string]*Collection),
+		records:     make(map[interface{}]*Record),
+	}
+
+	progress := 0
+
+	result, err := ImportCSV(target, `people`, strings.NewReader(
+		"id,name,age,score,active,born\n"+
+			"1,alice,31,9.5,true,1987-03-01\n"+
+			"2,bad,40,1,false,1978-01-01\n"+
+			"3,carol,,7,true,\n"+
+			"4,dave\n",
+	), CSVImportOptions{
+		BatchSize: 2,
+		Progress: func(imported int, failed int) {
+			progress += 1
+		},
+	})
+
+	assert.NoError(err)
+	assert.True(result.Created)
+	assert.Equal(2, result.Imported)
+	assert.Len(result.Errors, 2)
+	assert.Equal(2, result.Errors[0].Row)
+	assert.Equal(4, result.Errors[1].Row)
+	assert.Equal(2, progress)
+
+	collection := target.collections[`people`]
+	assert.Equal(`id`, collection.IdentityField)
+	assert.Equal(IntType, collection.IdentityFieldType)
+
+	for name, fieldType := range map[string]Type{
+		`name`:   StringType,
+		`age`:    IntType,
+		`score`:  FloatType,
+		`active`: BooleanType,
+		`born`:   TimeType,
+	} {
+		field, ok := collection.GetField(name)
+		assert.True(ok)
+		assert.Equal(fieldType, field.Type, name)
+	}
+
+	alice := target.records[int64(1)]
+	assert.NotNil(alice)
+	assert.Equal(`alice`, alice.Get(`name`))
+	assert.Equal(int64(31), alice.Get(`age`))
+	assert.Equal(9.5, alice.Get(`score`))
+	assert.Equal(true, alice.Get(`active`))
+	assert.Equal(time.Date(1987, 3, 1, 0, 0, 0, 0, time.UTC), alice.Get(`born`))
+
+	carol := target.records[int64(3)]
+	assert.NotNil(carol)
+	assert.Nil(carol.Get(`age`))
+}
+
+func TestImportCSVExistingCollection(t *testing.T) {
+	assert := require.New(t)
+	target := &testImportTarget{
+		collections: map[string]*Collection{
+			`things`: NewCollection(`things`).AddFields(Field{
+				Name: `name`,
+				Type: StringType,
+			}, Field{
+				Name: `code`,
+				Type: StringType,
+			}),
+		},
+		records: make(map[interface{}]*Record),
+	}
+
+	result, err := ImportCSV(target, `things`, strings.NewReader("name;code\nfirst;001\nsecond;002\n"), CSVImportOptions{
+		Delimiter: ';',
+	})
+
+	assert.NoError(err)
+	assert.False(result.Created)
+	assert.Equal(2, result.Imported)
+	assert.Equal(`001`, target.records[1].Get(`code`))
+	assert.Equal(`002`, target.records[2].Get(`code`))
+}
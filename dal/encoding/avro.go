@@ -0,0 +1,354 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/linkedin/goavro"
+)
+
+// The namespace of the Avro record schemas generated for collections.
+var AvroNamespace = `pivot`
+
+// The number of records written to each block of an Avro container file.
+var AvroBlockSize = 100
+
+var avroInvalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Returns the Avro record schema for the given collection.  Every field is a union of null and the
+// field's type.  Times are stored as timestamp-millis longs, and objects as JSON text.  Names that
+// aren't valid Avro names have their invalid characters replaced with underscores.
+func AvroSchema(collection *dal.Collection) (string, error) {
+	identity, fields := columnsFor(collection)
+
+	avroFields := []map[string]interface{}{
+		avroField(identity, identityType(collection)),
+	}
+
+	for _, field := range fields {
+		avroFields = append(avroFields, avroField(field.Name, field.Type))
+	}
+
+	if data, err := json.Marshal(map[string]interface{}{
+		`type`:      `record`,
+		`name`:      avroName(collection.Name),
+		`namespace`: AvroNamespace,
+		`fields`:    avroFields,
+	}); err == nil {
+		return string(data), nil
+	} else {
+		return ``, err
+	}
+}
+
+// Returns a codec for encoding and decoding the records of the given collection.
+func AvroCodec(collection *dal.Collection) (*goavro.Codec, error) {
+	if schema, err := AvroSchema(collection); err == nil {
+		return goavro.NewCodec(schema)
+	} else {
+		return nil, err
+	}
+}
+
+func avroField(name string, fieldType dal.Type) map[string]interface{} {
+	return map[string]interface{}{
+		`name`:    avroName(name),
+		`type`:    []interface{}{`null`, avroType(fieldType)},
+		`default`: nil,
+	}
+}
+
+func avroType(fieldType dal.Type) interface{} {
+	switch fieldType {
+	case dal.BooleanType:
+		return `boolean`
+	case dal.IntType:
+		return `long`
+	case dal.FloatType:
+		return `double`
+	case dal.TimeType:
+		return map[string]interface{}{
+			`type`:        `long`,
+			`logicalType`: `timestamp-millis`,
+		}
+	case dal.RawType:
+		return `bytes`
+	default:
+		return `string`
+	}
+}
+
+// the name of a union branch for the given type, as used by goavro
+func avroUnionName(fieldType dal.Type) string {
+	switch fieldType {
+	case dal.TimeType:
+		return `long.timestamp-millis`
+	default:
+		return avroType(fieldType).(string)
+	}
+}
+
+func avroName(name string) string {
+	name = avroInvalidNameChars.ReplaceAllString(name, `_`)
+
+	if name == `` || (name[0] >= '0' && name[0] <= '9') {
+		name = `_` + name
+	}
+
+	return name
+}
+
+// Converts a record into the native form understood by the collection's Avro codec.
+func AvroNative(collection *dal.Collection, record *dal.Record) (map[string]interface{}, error) {
+	identity, fields := columnsFor(collection)
+	native := make(map[string]interface{})
+
+	if value, err := avroValue(identityType(collection), record.ID); err == nil {
+		native[avroName(identity)] = value
+	} else {
+		return nil, fmt.Errorf("record %v: %v", record.ID, err)
+	}
+
+	for _, field := range fields {
+		if value, err := avroValue(field.Type, record.Get(field.Name)); err == nil {
+			native[avroName(field.Name)] = value
+		} else {
+			return nil, fmt.Errorf("record %v: field %s: %v", record.ID, field.Name, err)
+		}
+	}
+
+	return native, nil
+}
+
+// Converts a value decoded by the collection's Avro codec back into a record.
+func RecordFromAvroNative(collection *dal.Collection, native map[string]interface{}) (*dal.Record, error) {
+	identity, fields := columnsFor(collection)
+	record := dal.NewRecord(nil)
+
+	if value, err := nativeAvroValue(identityType(collection), native[avroName(identity)]); err == nil {
+		record.ID = value
+	} else {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		if value, err := nativeAvroValue(field.Type, native[avroName(field.Name)]); err == nil {
+			if value != nil {
+				record.Set(field.Name, value)
+			}
+		} else {
+			return nil, fmt.Errorf("field %s: %v", field.Name, err)
+		}
+	}
+
+	return record, nil
+}
+
+// Encodes a single record in Avro binary form (without a container), as is typical for messages
+// published to Kafka.
+func MarshalAvro(collection *dal.Collection, record *dal.Record) ([]byte, error) {
+	if codec, err := AvroCodec(collection); err == nil {
+		if native, err := AvroNative(collection, record); err == nil {
+			return codec.BinaryFromNative(nil, native)
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Decodes a single record encoded by MarshalAvro.
+func UnmarshalAvro(collection *dal.Collection, data []byte) (*dal.Record, error) {
+	if codec, err := AvroCodec(collection); err == nil {
+		if native, _, err := codec.NativeFromBinary(data); err == nil {
+			if nativeMap, ok := native.(map[string]interface{}); ok {
+				return RecordFromAvroNative(collection, nativeMap)
+			} else {
+				return nil, fmt.Errorf("expected an Avro record, got %T", native)
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Writes records to an Avro object container file, with the collection's schema embedded in its
+// header.  Records are written in blocks of AvroBlockSize; Close writes any that remain.
+type AvroWriter struct {
+	collection *dal.Collection
+	writer     *goavro.OCFWriter
+	pending    []interface{}
+}
+
+func NewAvroWriter(w io.Writer, collection *dal.Collection) (*AvroWriter, error) {
+	if codec, err := AvroCodec(collection); err == nil {
+		if ocf, err := goavro.NewOCFWriter(goavro.OCFConfig{
+			W:     w,
+			Codec: codec,
+		}); err == nil {
+			return &AvroWriter{
+				collection: collection,
+				writer:     ocf,
+			}, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *AvroWriter) Write(record *dal.Record) error {
+	if native, err := AvroNative(self.collection, record); err == nil {
+		self.pending = append(self.pending, native)
+
+		if len(self.pending) >= AvroBlockSize {
+			return self.Flush()
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Writes any buffered records as a block.
+func (self *AvroWriter) Flush() error {
+	if len(self.pending) == 0 {
+		return nil
+	}
+
+	err := self.writer.Append(self.pending)
+	self.pending = nil
+
+	return err
+}
+
+// Writes any buffered records.  The underlying writer is not closed.
+func (self *AvroWriter) Close() error {
+	return self.Flush()
+}
+
+// Writes all of the records in the given RecordSet to w as an Avro object container file.
+func WriteAvro(w io.Writer, collection *dal.Collection, recordset *dal.RecordSet) error {
+	if aw, err := NewAvroWriter(w, collection); err == nil {
+		if err := recordset.Each(aw.Write); err != nil {
+			return err
+		}
+
+		return aw.Close()
+	} else {
+		return err
+	}
+}
+
+// Reads the records in an Avro object container file written using the collection's schema (or a
+// compatible one).
+func ReadAvro(r io.Reader, collection *dal.Collection) (*dal.RecordSet, error) {
+	recordset := dal.NewRecordSet()
+
+	if ocf, err := goavro.NewOCFReader(r); err == nil {
+		for ocf.Scan() {
+			if native, err := ocf.Read(); err == nil {
+				if nativeMap, ok := native.(map[string]interface{}); ok {
+					if record, err := RecordFromAvroNative(collection, nativeMap); err == nil {
+						recordset.Push(record)
+					} else {
+						return nil, err
+					}
+				} else {
+					return nil, fmt.Errorf("expected an Avro record, got %T", native)
+				}
+			} else {
+				return nil, err
+			}
+		}
+
+		return recordset, ocf.Err()
+	} else {
+		return nil, err
+	}
+}
+
+// converts a value into a union of null and the given type
+func avroValue(fieldType dal.Type, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	var out interface{}
+
+	switch fieldType {
+	case dal.BooleanType:
+		out = typeutil.V(value).Bool()
+	case dal.IntType:
+		out = typeutil.V(value).Int()
+	case dal.FloatType:
+		out = typeutil.V(value).Float()
+	case dal.TimeType:
+		if t := typeutil.V(value).Time(); !t.IsZero() {
+			out = t
+		} else {
+			return nil, nil
+		}
+	case dal.RawType:
+		if data, ok := value.([]byte); ok {
+			out = data
+		} else {
+			out = []byte(typeutil.V(value).String())
+		}
+	case dal.ObjectType:
+		if data, err := json.Marshal(value); err == nil {
+			out = string(data)
+		} else {
+			return nil, err
+		}
+	default:
+		out = typeutil.V(value).String()
+	}
+
+	return goavro.Union(avroUnionName(fieldType), out), nil
+}
+
+// unwraps a decoded union of null and the given type
+func nativeAvroValue(fieldType dal.Type, value interface{}) (interface{}, error) {
+	if union, ok := value.(map[string]interface{}); ok {
+		for _, v := range union {
+			value = v
+		}
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	switch fieldType {
+	case dal.TimeType:
+		switch v := value.(type) {
+		case time.Time:
+			return v.UTC(), nil
+		case int64:
+			return time.Unix(0, v*int64(time.Millisecond)).UTC(), nil
+		}
+	case dal.ObjectType:
+		if text, ok := value.(string); ok {
+			var out interface{}
+
+			if err := json.Unmarshal([]byte(text), &out); err == nil {
+				return out, nil
+			} else {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
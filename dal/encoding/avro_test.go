@@ -0,0 +1,76 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvroSchema(t *testing.T) {
+	assert := require.New(t)
+
+	schema, err := AvroSchema(testEncodingCollection())
+	assert.NoError(err)
+
+	var parsed map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(schema), &parsed))
+
+	assert.Equal(`record`, parsed[`type`])
+	assert.Equal(`TestEncoding`, parsed[`name`])
+	assert.Equal(`pivot`, parsed[`namespace`])
+	assert.Len(parsed[`fields`], 7)
+
+	_, err = AvroCodec(testEncodingCollection())
+	assert.NoError(err)
+
+	assert.Equal(`user_name`, avroName(`user-name`))
+	assert.Equal(`_1st`, avroName(`1st`))
+}
+
+func TestAvroRoundTrip(t *testing.T) {
+	assert := require.New(t)
+	collection := testEncodingCollection()
+	created := time.Date(2018, 5, 4, 3, 2, 1, 0, time.UTC)
+
+	record := dal.NewRecord(int64(1)).SetFields(map[string]interface{}{
+		`name`:       `First`,
+		`count`:      3,
+		`enabled`:    true,
+		`created_at`: created,
+		`properties`: map[string]interface{}{
+			`color`: `red`,
+		},
+	})
+
+	data, err := MarshalAvro(collection, record)
+	assert.NoError(err)
+
+	decoded, err := UnmarshalAvro(collection, data)
+	assert.NoError(err)
+	assert.Equal(int64(1), decoded.ID)
+	assert.Equal(`First`, decoded.Get(`name`))
+	assert.Equal(int64(3), decoded.Get(`count`))
+	assert.Equal(true, decoded.Get(`enabled`))
+	assert.Equal(created, decoded.Get(`created_at`))
+	assert.Equal(map[string]interface{}{
+		`color`: `red`,
+	}, decoded.Get(`properties`))
+	assert.Nil(decoded.Get(`factor`))
+
+	var buf bytes.Buffer
+
+	assert.NoError(WriteAvro(&buf, collection, dal.NewRecordSet(
+		record,
+		dal.NewRecord(int64(2)).Set(`name`, `Second`),
+	)))
+
+	recordset, err := ReadAvro(&buf, collection)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+	assert.Equal(int64(2), recordset.Records[1].ID)
+	assert.Equal(`Second`, recordset.Records[1].Get(`name`))
+}
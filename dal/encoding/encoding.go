@@ -0,0 +1,38 @@
+// Package encoding reads and writes records in file formats used outside of pivot, with schemas
+// derived from collection definitions.
+package encoding
+
+import (
+	"github.com/ghetzel/pivot/dal"
+)
+
+// The name of the column that record IDs are stored in when a collection doesn't specify one.
+var DefaultIdentityColumn = dal.DefaultIdentityField
+
+// returns the name of the collection's identity column, followed by its fields
+func columnsFor(collection *dal.Collection) (string, []dal.Field) {
+	identity := collection.IdentityField
+
+	if identity == `` {
+		identity = DefaultIdentityColumn
+	}
+
+	fields := make([]dal.Field, 0, len(collection.Fields))
+
+	for _, field := range collection.Fields {
+		if field.Name != identity {
+			fields = append(fields, field)
+		}
+	}
+
+	return identity, fields
+}
+
+// returns the type of the collection's identity field
+func identityType(collection *dal.Collection) dal.Type {
+	if collection.IdentityFieldType != `` {
+		return collection.IdentityFieldType
+	}
+
+	return dal.DefaultIdentityFieldType
+}
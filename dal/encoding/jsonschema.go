@@ -0,0 +1,195 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// The JSON Schema draft that generated schemas declare.
+var JSONSchemaDraft = `http://json-schema.org/draft-07/schema#`
+
+// compiled schemas, keyed on their JSON
+var jsonSchemaCache sync.Map
+
+// A single way in which a record failed to match its collection's JSON Schema.
+type SchemaError struct {
+	Path    string `json:"path"` // a JSON pointer to the offending value, e.g.: "/fields/name"
+	Message string `json:"message"`
+}
+
+// All of the ways in which a record failed to match its collection's JSON Schema.
+type SchemaErrors []SchemaError
+
+func (self SchemaErrors) Error() string {
+	messages := make([]string, len(self))
+
+	for i, err := range self {
+		messages[i] = fmt.Sprintf("%s: %s", err.Path, err.Message)
+	}
+
+	return `Record does not match schema: ` + strings.Join(messages, `; `)
+}
+
+// Returns a JSON Schema describing the records of the given collection, as they are represented in
+// JSON (an "id" and an object of "fields").  Fields that aren't required may be null.  If partial is
+// true, required fields may be omitted (as is the case when updating a record).  IDs are not
+// constrained, since they are often given as strings (e.g.: in URLs) regardless of their type.
+func JSONSchema(collection *dal.Collection, partial bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+	_, fields := columnsFor(collection)
+
+	for _, field := range fields {
+		property := jsonSchemaType(field.Type, !field.Required)
+
+		if field.Description != `` {
+			property[`description`] = field.Description
+		}
+
+		if field.Length > 0 && field.Type == dal.StringType {
+			property[`maxLength`] = field.Length
+		}
+
+		if field.DefaultValue != nil && !field.Required {
+			if _, err := json.Marshal(field.DefaultValue); err == nil {
+				property[`default`] = field.DefaultValue
+			}
+		}
+
+		properties[field.Name] = property
+
+		if field.Required && field.DefaultValue == nil && !partial {
+			required = append(required, field.Name)
+		}
+	}
+
+	fieldsSchema := map[string]interface{}{
+		`type`:       `object`,
+		`properties`: properties,
+	}
+
+	if len(required) > 0 {
+		fieldsSchema[`required`] = required
+	}
+
+	return map[string]interface{}{
+		`$schema`: JSONSchemaDraft,
+		`title`:   collection.Name,
+		`type`:    `object`,
+		`properties`: map[string]interface{}{
+			`id`:     map[string]interface{}{},
+			`fields`: fieldsSchema,
+		},
+	}
+}
+
+func jsonSchemaType(fieldType dal.Type, nullable bool) map[string]interface{} {
+	var types []string
+	property := make(map[string]interface{})
+
+	switch fieldType {
+	case dal.StringType:
+		types = []string{`string`}
+	case dal.BooleanType:
+		types = []string{`boolean`}
+	case dal.IntType:
+		types = []string{`integer`}
+	case dal.FloatType:
+		types = []string{`number`}
+	case dal.TimeType:
+		// times may also be given as epoch seconds or milliseconds
+		types = []string{`string`, `number`}
+		property[`format`] = `date-time`
+	case dal.ObjectType:
+		types = []string{`object`, `array`}
+	case dal.RawType:
+		types = []string{`string`}
+		property[`contentEncoding`] = `base64`
+	default:
+		return property
+	}
+
+	if nullable {
+		types = append(types, `null`)
+	}
+
+	if len(types) == 1 {
+		property[`type`] = types[0]
+	} else {
+		property[`type`] = types
+	}
+
+	return property
+}
+
+// Validates the given record against its collection's JSON Schema, returning SchemaErrors describing
+// each problem that was found.
+func ValidateJSONSchema(collection *dal.Collection, record *dal.Record, partial bool) error {
+	var schema *gojsonschema.Schema
+
+	if data, err := json.Marshal(JSONSchema(collection, partial)); err == nil {
+		if cached, ok := jsonSchemaCache.Load(string(data)); ok {
+			schema = cached.(*gojsonschema.Schema)
+		} else if compiled, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data)); err == nil {
+			jsonSchemaCache.Store(string(data), compiled)
+			schema = compiled
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	fields := record.Fields
+
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+
+	document := map[string]interface{}{
+		`fields`: fields,
+	}
+
+	if result, err := schema.Validate(gojsonschema.NewGoLoader(document)); err == nil {
+		if result.Valid() {
+			return nil
+		}
+
+		errs := make(SchemaErrors, 0)
+
+		for _, resultErr := range result.Errors() {
+			path := resultErr.Field()
+
+			// missing required properties are reported against the object that should contain them
+			if property, ok := resultErr.Details()[`property`]; ok && resultErr.Type() == `required` {
+				path = fmt.Sprintf("%s.%v", path, property)
+			}
+
+			errs = append(errs, SchemaError{
+				Path:    jsonPointer(path),
+				Message: resultErr.Description(),
+			})
+		}
+
+		return errs
+	} else {
+		return err
+	}
+}
+
+// converts a gojsonschema field path (e.g.: "(root).fields.name") into a JSON pointer
+func jsonPointer(path string) string {
+	path = strings.TrimPrefix(path, `(root)`)
+	path = strings.TrimPrefix(path, `.`)
+
+	if path == `` {
+		return `/`
+	}
+
+	return `/` + strings.Replace(path, `.`, `/`, -1)
+}
@@ -0,0 +1,67 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchema(t *testing.T) {
+	assert := require.New(t)
+	collection := testEncodingCollection()
+	collection.Fields[0].Required = true
+	collection.Fields[0].Length = 8
+
+	schema := JSONSchema(collection, false)
+	assert.Equal(`TestEncoding`, schema[`title`])
+
+	fields := schema[`properties`].(map[string]interface{})[`fields`].(map[string]interface{})
+	properties := fields[`properties`].(map[string]interface{})
+
+	assert.Equal([]string{`name`}, fields[`required`])
+	assert.Equal(`string`, properties[`name`].(map[string]interface{})[`type`])
+	assert.Equal(8, properties[`name`].(map[string]interface{})[`maxLength`])
+	assert.Equal([]string{`integer`, `null`}, properties[`count`].(map[string]interface{})[`type`])
+
+	partial := JSONSchema(collection, true)
+	fields = partial[`properties`].(map[string]interface{})[`fields`].(map[string]interface{})
+	assert.NotContains(fields, `required`)
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	assert := require.New(t)
+	collection := testEncodingCollection()
+	collection.Fields[0].Required = true
+
+	assert.NoError(ValidateJSONSchema(collection, dal.NewRecord(`1`).SetFields(map[string]interface{}{
+		`name`:    `First`,
+		`count`:   3,
+		`enabled`: nil,
+	}), false))
+
+	err := ValidateJSONSchema(collection, dal.NewRecord(1).SetFields(map[string]interface{}{
+		`count`:   `three`,
+		`enabled`: true,
+	}), false)
+
+	assert.Error(err)
+	errs, ok := err.(SchemaErrors)
+	assert.True(ok)
+	assert.Len(errs, 2)
+
+	paths := []string{errs[0].Path, errs[1].Path}
+	assert.Contains(paths, `/fields/name`)
+	assert.Contains(paths, `/fields/count`)
+
+	// updates may omit required fields
+	assert.NoError(ValidateJSONSchema(collection, dal.NewRecord(1).Set(`count`, 4), true))
+}
+
+func TestJSONPointer(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(`/`, jsonPointer(`(root)`))
+	assert.Equal(`/fields`, jsonPointer(`(root).fields`))
+	assert.Equal(`/fields/name`, jsonPointer(`fields.name`))
+}
@@ -0,0 +1,304 @@
+package encoding
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go-source/writerfile"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// The number of goroutines used to encode and decode Parquet data.
+var ParquetParallelism int64 = 4
+
+const parquetRootName = `parquet_go_root`
+
+// Returns the Parquet schema (in the JSON form understood by parquet-go) for the given collection.
+// Every column is optional.  Times are stored as milliseconds since the epoch, objects as JSON
+// text, and raw values as base64 text.
+func ParquetSchema(collection *dal.Collection) (string, error) {
+	identity, fields := columnsFor(collection)
+
+	columns := []map[string]string{
+		{
+			`Tag`: parquetColumnTag(identity, identityType(collection)),
+		},
+	}
+
+	for _, field := range fields {
+		columns = append(columns, map[string]string{
+			`Tag`: parquetColumnTag(field.Name, field.Type),
+		})
+	}
+
+	if data, err := json.Marshal(map[string]interface{}{
+		`Tag`:    fmt.Sprintf("name=%s, repetitiontype=REQUIRED", parquetRootName),
+		`Fields`: columns,
+	}); err == nil {
+		return string(data), nil
+	} else {
+		return ``, err
+	}
+}
+
+func parquetColumnTag(name string, fieldType dal.Type) string {
+	var physical string
+
+	switch fieldType {
+	case dal.BooleanType:
+		physical = `type=BOOLEAN`
+	case dal.IntType:
+		physical = `type=INT64`
+	case dal.FloatType:
+		physical = `type=DOUBLE`
+	case dal.TimeType:
+		physical = `type=INT64, convertedtype=TIMESTAMP_MILLIS`
+	default:
+		physical = `type=BYTE_ARRAY, convertedtype=UTF8`
+	}
+
+	return fmt.Sprintf("name=%s, %s, repetitiontype=OPTIONAL", name, physical)
+}
+
+// Writes records to a Parquet file as they are given to it.  The file is not complete until Close is
+// called.
+type ParquetWriter struct {
+	collection *dal.Collection
+	identity   string
+	fields     []dal.Field
+	writer     *writer.JSONWriter
+}
+
+func NewParquetWriter(w io.Writer, collection *dal.Collection) (*ParquetWriter, error) {
+	if schema, err := ParquetSchema(collection); err == nil {
+		if jw, err := writer.NewJSONWriter(schema, writerfile.NewWriterFile(w), ParquetParallelism); err == nil {
+			identity, fields := columnsFor(collection)
+
+			return &ParquetWriter{
+				collection: collection,
+				identity:   identity,
+				fields:     fields,
+				writer:     jw,
+			}, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Writes a record as a row of the Parquet file.  Fields that aren't part of the collection are
+// not written.
+func (self *ParquetWriter) Write(record *dal.Record) error {
+	row := make(map[string]interface{})
+
+	if value, err := parquetValue(identityType(self.collection), record.ID); err == nil {
+		row[self.identity] = value
+	} else {
+		return fmt.Errorf("record %v: %v", record.ID, err)
+	}
+
+	for _, field := range self.fields {
+		if value, err := parquetValue(field.Type, record.Get(field.Name)); err == nil {
+			row[field.Name] = value
+		} else {
+			return fmt.Errorf("record %v: field %s: %v", record.ID, field.Name, err)
+		}
+	}
+
+	if data, err := json.Marshal(row); err == nil {
+		return self.writer.Write(string(data))
+	} else {
+		return err
+	}
+}
+
+// Writes the file footer.  The underlying writer is not closed.
+func (self *ParquetWriter) Close() error {
+	return self.writer.WriteStop()
+}
+
+// Writes all of the records in the given RecordSet to w as a Parquet file.
+func WriteParquet(w io.Writer, collection *dal.Collection, recordset *dal.RecordSet) error {
+	if pw, err := NewParquetWriter(w, collection); err == nil {
+		if err := recordset.Each(pw.Write); err != nil {
+			return err
+		}
+
+		return pw.Close()
+	} else {
+		return err
+	}
+}
+
+// Reads a Parquet file into records, converting values to the types of the collection's fields.  Only
+// columns named after the collection's identity and fields are read.
+func ReadParquet(r io.Reader, collection *dal.Collection) (*dal.RecordSet, error) {
+	var data []byte
+
+	if d, err := ioutil.ReadAll(r); err == nil {
+		data = d
+	} else {
+		return nil, err
+	}
+
+	file, err := buffer.NewBufferFileFromBytes(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := reader.NewParquetColumnReader(file, ParquetParallelism)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer pr.ReadStop()
+
+	identity, fields := columnsFor(collection)
+	numRows := pr.GetNumRows()
+	records := make([]*dal.Record, numRows)
+
+	if ids, err := readParquetColumn(pr, identity, numRows); err == nil {
+		for i := range records {
+			var id interface{}
+
+			if ids != nil {
+				if v, err := nativeValue(identityType(collection), ids[i]); err == nil {
+					id = v
+				} else {
+					return nil, err
+				}
+			}
+
+			records[i] = dal.NewRecord(id)
+		}
+	} else {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		if values, err := readParquetColumn(pr, field.Name, numRows); err == nil {
+			if values == nil {
+				continue
+			}
+
+			for i, record := range records {
+				if values[i] == nil {
+					continue
+				}
+
+				if v, err := nativeValue(field.Type, values[i]); err == nil {
+					record.Set(field.Name, v)
+				} else {
+					return nil, fmt.Errorf("row %d: field %s: %v", i, field.Name, err)
+				}
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return dal.NewRecordSet(records...), nil
+}
+
+// reads all values of the named column, returning nil if the file doesn't have that column
+func readParquetColumn(pr *reader.ParquetReader, name string, numRows int64) ([]interface{}, error) {
+	path := parquetRootName + common.PAR_GO_PATH_DELIMITER + name
+
+	found := false
+
+	for _, element := range pr.Footer.Schema[1:] {
+		if element.GetName() == name {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	if values, _, _, err := pr.ReadColumnByPath(path, numRows); err == nil {
+		if int64(len(values)) != numRows {
+			return nil, fmt.Errorf("column %s: expected %d values, got %d", name, numRows, len(values))
+		}
+
+		return values, nil
+	} else {
+		return nil, err
+	}
+}
+
+// converts a value to what is written in a Parquet column of the given type
+func parquetValue(fieldType dal.Type, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch fieldType {
+	case dal.BooleanType:
+		return typeutil.V(value).Bool(), nil
+	case dal.IntType:
+		return typeutil.V(value).Int(), nil
+	case dal.FloatType:
+		return typeutil.V(value).Float(), nil
+	case dal.TimeType:
+		if t := typeutil.V(value).Time(); !t.IsZero() {
+			return t.UnixNano() / int64(time.Millisecond), nil
+		}
+
+		return nil, nil
+	case dal.ObjectType:
+		if data, err := json.Marshal(value); err == nil {
+			return string(data), nil
+		} else {
+			return nil, err
+		}
+	case dal.RawType:
+		if data, ok := value.([]byte); ok {
+			return base64.StdEncoding.EncodeToString(data), nil
+		}
+
+		return base64.StdEncoding.EncodeToString([]byte(typeutil.V(value).String())), nil
+	}
+
+	return typeutil.V(value).String(), nil
+}
+
+// converts a value read from a Parquet column back into the type of the field it belongs to
+func nativeValue(fieldType dal.Type, value interface{}) (interface{}, error) {
+	switch fieldType {
+	case dal.TimeType:
+		if ms, ok := value.(int64); ok {
+			return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+		}
+	case dal.ObjectType:
+		if text, ok := value.(string); ok {
+			var out interface{}
+
+			if err := json.Unmarshal([]byte(text), &out); err == nil {
+				return out, nil
+			} else {
+				return nil, err
+			}
+		}
+	case dal.RawType:
+		if text, ok := value.(string); ok {
+			return base64.StdEncoding.DecodeString(text)
+		}
+	}
+
+	return value, nil
+}
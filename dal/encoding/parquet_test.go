@@ -0,0 +1,83 @@
+package encoding
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func testEncodingCollection() *dal.Collection {
+	collection := dal.NewCollection(`TestEncoding`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `count`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name: `factor`,
+		Type: dal.FloatType,
+	}, dal.Field{
+		Name: `enabled`,
+		Type: dal.BooleanType,
+	}, dal.Field{
+		Name: `created_at`,
+		Type: dal.TimeType,
+	}, dal.Field{
+		Name: `properties`,
+		Type: dal.ObjectType,
+	})
+
+	collection.IdentityFieldType = dal.IntType
+
+	return collection
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	assert := require.New(t)
+	collection := testEncodingCollection()
+	created := time.Date(2018, 5, 4, 3, 2, 1, 0, time.UTC)
+
+	recordset := dal.NewRecordSet(
+		dal.NewRecord(int64(1)).SetFields(map[string]interface{}{
+			`name`:       `First`,
+			`count`:      int64(3),
+			`factor`:     0.5,
+			`enabled`:    true,
+			`created_at`: created,
+			`properties`: map[string]interface{}{
+				`color`: `red`,
+			},
+		}),
+		dal.NewRecord(int64(2)).SetFields(map[string]interface{}{
+			`name`: `Second`,
+		}),
+	)
+
+	var buf bytes.Buffer
+
+	assert.NoError(WriteParquet(&buf, collection, recordset))
+	assert.True(buf.Len() > 0)
+
+	out, err := ReadParquet(&buf, collection)
+	assert.NoError(err)
+	assert.Len(out.Records, 2)
+
+	first := out.Records[0]
+	assert.Equal(int64(1), first.ID)
+	assert.Equal(`First`, first.Get(`name`))
+	assert.Equal(int64(3), first.Get(`count`))
+	assert.Equal(0.5, first.Get(`factor`))
+	assert.Equal(true, first.Get(`enabled`))
+	assert.Equal(created, first.Get(`created_at`))
+	assert.Equal(map[string]interface{}{
+		`color`: `red`,
+	}, first.Get(`properties`))
+
+	second := out.Records[1]
+	assert.Equal(int64(2), second.ID)
+	assert.Equal(`Second`, second.Get(`name`))
+	assert.Nil(second.Get(`count`))
+}
@@ -1,6 +1,7 @@
 package dal
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -11,12 +12,98 @@ const (
 
 var CollectionNotFound = fmt.Errorf(ERR_COLLECTION_NOT_FOUND)
 
+// Sentinel errors returned (possibly wrapped) by backends.  Use errors.Is to test for them, or
+// errors.As with *Error to retrieve the underlying driver error.
+var ErrCollectionNotFound = CollectionNotFound
+var ErrRecordNotFound = errors.New(`Record not found`)
+var ErrUniqueViolation = errors.New(`Unique constraint violated`)
+var ErrStaleRecord = errors.New(`Record has been modified`)
+var ErrUnsupported = errors.New(`Not Implemented`)
+var ErrLimitExceeded = errors.New(`Record exceeds collection limits`)
+var ErrPermissionDenied = errors.New(`Permission denied`)
+
+// An error of a known kind (one of the Err* sentinel values), optionally wrapping the error returned
+// by the underlying driver.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (self *Error) Error() string {
+	if self.Message != `` {
+		return self.Message
+	} else if self.Err != nil {
+		return self.Err.Error()
+	}
+
+	return self.Kind.Error()
+}
+
+func (self *Error) Is(target error) bool {
+	return target == self.Kind
+}
+
+func (self *Error) Unwrap() error {
+	return self.Err
+}
+
+// Returns an error indicating that the record with the given ID does not exist.
+func RecordNotFound(id interface{}) error {
+	return &Error{
+		Kind:    ErrRecordNotFound,
+		Message: fmt.Sprintf("Record %v does not exist", id),
+	}
+}
+
+// Returns an error indicating that writing a record would violate a uniqueness constraint, wrapping
+// the driver error that reported it.
+func UniqueViolation(err error) error {
+	return &Error{
+		Kind: ErrUniqueViolation,
+		Err:  err,
+	}
+}
+
+// Returns an error indicating that the record with the given ID was changed by someone else since
+// it was last read.
+func StaleRecord(id interface{}) error {
+	return &Error{
+		Kind:    ErrStaleRecord,
+		Message: fmt.Sprintf("Record %v has been modified", id),
+	}
+}
+
+// Returns an error indicating that an operation is not supported by a backend or indexer.
+func Unsupported(format string, args ...interface{}) error {
+	return &Error{
+		Kind:    ErrUnsupported,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Returns an error indicating that a record or query exceeds one of the limits placed on it.
+func LimitExceeded(format string, args ...interface{}) error {
+	return &Error{
+		Kind:    ErrLimitExceeded,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Returns an error indicating that an operation is not permitted on a record or collection.
+func PermissionDenied(format string, args ...interface{}) error {
+	return &Error{
+		Kind:    ErrPermissionDenied,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
 func IsCollectionNotFoundErr(err error) bool {
 	if err == nil {
 		return false
 	}
 
-	return (err.Error() == ERR_COLLECTION_NOT_FOUND)
+	return errors.Is(err, ErrCollectionNotFound) || (err.Error() == ERR_COLLECTION_NOT_FOUND)
 }
 
 func IsNotExistError(err error) bool {
@@ -24,6 +111,10 @@ func IsNotExistError(err error) bool {
 		return false
 	}
 
+	if errors.Is(err, ErrRecordNotFound) {
+		return true
+	}
+
 	return strings.HasSuffix(err.Error(), ` does not exist`)
 }
 
@@ -32,5 +123,9 @@ func IsExistError(err error) bool {
 		return false
 	}
 
+	if errors.Is(err, ErrUniqueViolation) {
+		return true
+	}
+
 	return strings.HasSuffix(err.Error(), ` already exists`)
 }
@@ -0,0 +1,47 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	assert := require.New(t)
+
+	err := RecordNotFound(42)
+	assert.Equal(`Record 42 does not exist`, err.Error())
+	assert.True(errors.Is(err, ErrRecordNotFound))
+	assert.False(errors.Is(err, ErrUniqueViolation))
+	assert.True(IsNotExistError(err))
+	assert.True(IsNotExistError(fmt.Errorf("Thing does not exist")))
+
+	driverErr := fmt.Errorf("UNIQUE constraint failed: things.id")
+	err = UniqueViolation(driverErr)
+	assert.Equal(driverErr.Error(), err.Error())
+	assert.True(errors.Is(err, ErrUniqueViolation))
+	assert.True(errors.Is(err, driverErr))
+	assert.True(IsExistError(err))
+
+	var dalErr *Error
+	assert.True(errors.As(err, &dalErr))
+	assert.Equal(driverErr, dalErr.Err)
+
+	err = StaleRecord(`abc`)
+	assert.Equal(`Record abc has been modified`, err.Error())
+	assert.True(errors.Is(err, ErrStaleRecord))
+
+	err = Unsupported("Backend %s does not support widgets", `foo`)
+	assert.True(errors.Is(err, ErrUnsupported))
+	assert.Equal(`Backend foo does not support widgets`, err.Error())
+
+	err = PermissionDenied("Record %v is not in scope", 7)
+	assert.True(errors.Is(err, ErrPermissionDenied))
+	assert.Equal(`Record 7 is not in scope`, err.Error())
+
+	assert.True(IsCollectionNotFoundErr(CollectionNotFound))
+	assert.True(errors.Is(CollectionNotFound, ErrCollectionNotFound))
+	assert.False(IsCollectionNotFoundErr(nil))
+}
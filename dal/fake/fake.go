@@ -0,0 +1,307 @@
+// Package fake generates realistic-looking records from collection definitions, for use in load
+// tests, demos, and anywhere else a populated dataset is needed.  Generated values match the types of
+// their fields and satisfy their validators and uniqueness constraints.  Given the same seed, a
+// Generator always produces the same records.
+package fake
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/dal"
+)
+
+// The probability that a field that isn't required is left empty.
+var DefaultNullProbability = 0.1
+
+// The number of times a value is regenerated when it fails validation or is not unique.
+var MaxAttempts = 100
+
+// Generated times fall within this long before the generator's reference time.
+var TimeRange = 365 * 24 * time.Hour
+
+// Generates fake records for a single collection.
+type Generator struct {
+	Collection      *dal.Collection
+	NullProbability float64
+	Now             time.Time
+	rand            *rand.Rand
+	nextID          int64
+	seen            map[string]map[string]bool
+}
+
+// Creates a generator for the given collection.  Generators created with the same seed produce the
+// same sequence of records (as long as Now is also the same).
+func New(collection *dal.Collection, seed int64) *Generator {
+	return &Generator{
+		Collection:      collection,
+		NullProbability: DefaultNullProbability,
+		Now:             time.Now(),
+		rand:            rand.New(rand.NewSource(seed)),
+		seen:            make(map[string]map[string]bool),
+	}
+}
+
+// Generates the given number of records.
+func (self *Generator) Records(count int) (*dal.RecordSet, error) {
+	recordset := dal.NewRecordSet()
+
+	for i := 0; i < count; i++ {
+		if record, err := self.Record(); err == nil {
+			recordset.Push(record)
+		} else {
+			return nil, err
+		}
+	}
+
+	return recordset, nil
+}
+
+// Generates a single record.  IDs are sequential integers for collections with integer identity
+// fields and random UUIDs otherwise.
+func (self *Generator) Record() (*dal.Record, error) {
+	record := dal.NewRecord(self.id())
+
+	for _, field := range self.Collection.Fields {
+		if field.Identity || field.Name == self.Collection.IdentityField {
+			continue
+		}
+
+		if value, err := self.Value(field); err == nil {
+			if value != nil {
+				record.Set(field.Name, value)
+			}
+		} else {
+			return nil, fmt.Errorf("field %s: %v", field.Name, err)
+		}
+	}
+
+	return record, nil
+}
+
+func (self *Generator) id() interface{} {
+	switch self.Collection.IdentityFieldType {
+	case dal.StringType:
+		return self.uuid()
+	default:
+		self.nextID += 1
+		return self.nextID
+	}
+}
+
+// Generates a value for the given field that satisfies its validators (and, for unique fields, has
+// not been generated before).  A nil value means the field should be left empty.
+func (self *Generator) Value(field dal.Field) (interface{}, error) {
+	if !field.Required && !mustBeSet(field) && self.rand.Float64() < self.NullProbability {
+		return nil, nil
+	}
+
+	var lastErr error
+
+	for attempt := 0; attempt < MaxAttempts; attempt++ {
+		value := self.value(field, attempt)
+
+		if err := field.Validate(value); err != nil {
+			lastErr = err
+			continue
+		}
+
+		if field.Unique {
+			key := fmt.Sprintf("%v", value)
+
+			if self.seen[field.Name] == nil {
+				self.seen[field.Name] = make(map[string]bool)
+			}
+
+			if self.seen[field.Name][key] {
+				lastErr = fmt.Errorf("could not generate a unique value")
+				continue
+			}
+
+			self.seen[field.Name][key] = true
+		}
+
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("gave up after %d attempts: %v", MaxAttempts, lastErr)
+}
+
+// fields with these validators can't be left empty
+func mustBeSet(field dal.Field) bool {
+	for _, name := range []string{`not-zero`, `not-empty`, `positive-integer`, `one-of`} {
+		if _, ok := field.ValidatorConfig[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *Generator) value(field dal.Field, attempt int) interface{} {
+	if choices, ok := field.ValidatorConfig[`one-of`]; ok {
+		if values := sliceutil.Sliceify(choices); len(values) > 0 {
+			return values[self.rand.Intn(len(values))]
+		}
+	}
+
+	name := strings.ToLower(field.Name)
+
+	switch field.Type {
+	case dal.BooleanType:
+		return self.rand.Intn(2) == 1
+	case dal.IntType:
+		return self.integer(field, name)
+	case dal.FloatType:
+		return self.float(name)
+	case dal.TimeType:
+		return self.Now.Add(-time.Duration(self.rand.Int63n(int64(TimeRange)))).Truncate(time.Second).UTC()
+	case dal.ObjectType:
+		return map[string]interface{}{
+			self.pick(words): self.pick(words),
+			self.pick(words): self.rand.Intn(100),
+		}
+	case dal.RawType:
+		data := make([]byte, 16)
+		self.rand.Read(data)
+		return data
+	case dal.PointType:
+		return self.point()
+	case dal.PolygonType:
+		// a small triangle around a random point
+		center := self.point()
+
+		return dal.Polygon{
+			{Latitude: center.Latitude, Longitude: center.Longitude},
+			{Latitude: center.Latitude + 0.01, Longitude: center.Longitude},
+			{Latitude: center.Latitude, Longitude: center.Longitude + 0.01},
+		}
+	default:
+		value := self.text(name)
+
+		// after a few collisions, make unique values unique by numbering them
+		if field.Unique && attempt > 2 {
+			value = fmt.Sprintf("%s-%d", value, self.rand.Intn(1000000))
+		}
+
+		if field.Length > 0 && len(value) > field.Length {
+			value = value[:field.Length]
+		}
+
+		return value
+	}
+}
+
+func (self *Generator) integer(field dal.Field, name string) int64 {
+	var min, max int64 = 0, 10000
+
+	switch {
+	case strings.Contains(name, `age`):
+		min, max = 18, 90
+	case strings.Contains(name, `year`):
+		min, max = 1970, int64(self.Now.Year())
+	case strings.Contains(name, `count`), strings.Contains(name, `quantity`), strings.Contains(name, `qty`):
+		max = 100
+	case strings.Contains(name, `rating`), strings.Contains(name, `stars`):
+		min, max = 1, 5
+	}
+
+	if _, ok := field.ValidatorConfig[`positive-integer`]; ok && min < 1 {
+		min = 1
+	} else if _, ok := field.ValidatorConfig[`not-zero`]; ok && min < 1 {
+		min = 1
+	}
+
+	return min + self.rand.Int63n(max-min+1)
+}
+
+func (self *Generator) float(name string) float64 {
+	switch {
+	case strings.Contains(name, `lat`):
+		return self.rand.Float64()*180 - 90
+	case strings.Contains(name, `lon`), strings.Contains(name, `lng`):
+		return self.rand.Float64()*360 - 180
+	case strings.Contains(name, `price`), strings.Contains(name, `amount`), strings.Contains(name, `cost`):
+		return float64(self.rand.Intn(100000)) / 100
+	case strings.Contains(name, `percent`), strings.Contains(name, `ratio`):
+		return self.rand.Float64()
+	default:
+		return self.rand.Float64() * 1000
+	}
+}
+
+// a random point, away from the poles and the antimeridian so that nearby points are also valid
+func (self *Generator) point() dal.Point {
+	return dal.Point{
+		Latitude:  self.rand.Float64()*178 - 89,
+		Longitude: self.rand.Float64()*358 - 179,
+	}
+}
+
+// generates text based on what the field's name suggests it holds
+func (self *Generator) text(name string) string {
+	switch {
+	case strings.Contains(name, `email`):
+		return fmt.Sprintf("%s.%s@%s",
+			strings.ToLower(self.pick(firstNames)),
+			strings.ToLower(self.pick(lastNames)),
+			self.pick(domains),
+		)
+	case strings.Contains(name, `first`):
+		return self.pick(firstNames)
+	case strings.Contains(name, `last`), strings.Contains(name, `surname`):
+		return self.pick(lastNames)
+	case strings.Contains(name, `user`):
+		return fmt.Sprintf("%s%d", strings.ToLower(self.pick(firstNames)), self.rand.Intn(1000))
+	case strings.Contains(name, `name`):
+		return self.pick(firstNames) + ` ` + self.pick(lastNames)
+	case strings.Contains(name, `phone`):
+		return fmt.Sprintf("+1-%03d-%03d-%04d", 200+self.rand.Intn(800), self.rand.Intn(1000), self.rand.Intn(10000))
+	case strings.Contains(name, `city`):
+		return self.pick(cities)
+	case strings.Contains(name, `country`):
+		return self.pick(countries)
+	case strings.Contains(name, `address`), strings.Contains(name, `street`):
+		return fmt.Sprintf("%d %s %s", 1+self.rand.Intn(9999), self.pick(lastNames), self.pick(streetTypes))
+	case strings.Contains(name, `url`), strings.Contains(name, `website`), strings.Contains(name, `link`):
+		return fmt.Sprintf("https://%s/%s", self.pick(domains), self.pick(words))
+	case strings.Contains(name, `uuid`), strings.Contains(name, `guid`):
+		return self.uuid()
+	case strings.Contains(name, `color`), strings.Contains(name, `colour`):
+		return self.pick(colors)
+	case strings.Contains(name, `description`), strings.Contains(name, `body`), strings.Contains(name, `text`), strings.Contains(name, `comment`):
+		return self.sentence(8 + self.rand.Intn(12))
+	case strings.Contains(name, `title`), strings.Contains(name, `subject`):
+		return strings.Title(self.sentence(2 + self.rand.Intn(4)))
+	default:
+		return self.pick(words)
+	}
+}
+
+func (self *Generator) sentence(length int) string {
+	out := make([]string, length)
+
+	for i := range out {
+		out[i] = self.pick(words)
+	}
+
+	return strings.Title(out[0][:1]) + strings.Join(out, ` `)[1:] + `.`
+}
+
+func (self *Generator) uuid() string {
+	data := make([]byte, 16)
+	self.rand.Read(data)
+
+	// version 4, RFC 4122 variant
+	data[6] = (data[6] & 0x0f) | 0x40
+	data[8] = (data[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", data[0:4], data[4:6], data[6:8], data[8:10], data[10:16])
+}
+
+func (self *Generator) pick(values []string) string {
+	return values[self.rand.Intn(len(values))]
+}
@@ -0,0 +1,116 @@
+package fake
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func testFakeCollection() *dal.Collection {
+	status := dal.Field{
+		Name: `status`,
+		Type: dal.StringType,
+		ValidatorConfig: map[string]interface{}{
+			`one-of`: []interface{}{`active`, `suspended`},
+		},
+	}
+
+	status.Validator, _ = dal.ValidatorFromMap(status.ValidatorConfig)
+
+	return dal.NewCollection(`users`).AddFields(dal.Field{
+		Name:     `email`,
+		Type:     dal.StringType,
+		Required: true,
+		Unique:   true,
+	}, dal.Field{
+		Name:   `first_name`,
+		Type:   dal.StringType,
+		Length: 4,
+	}, dal.Field{
+		Name:     `age`,
+		Type:     dal.IntType,
+		Required: true,
+	}, dal.Field{
+		Name: `balance`,
+		Type: dal.FloatType,
+	}, dal.Field{
+		Name: `verified`,
+		Type: dal.BooleanType,
+	}, dal.Field{
+		Name: `created_at`,
+		Type: dal.TimeType,
+	}, status)
+}
+
+func TestGeneratorRecords(t *testing.T) {
+	assert := require.New(t)
+	collection := testFakeCollection()
+
+	recordset, err := New(collection, 42).Records(200)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 200)
+
+	emails := make(map[string]bool)
+
+	for i, record := range recordset.Records {
+		assert.Equal(int64(i+1), record.ID)
+
+		email, ok := record.Get(`email`).(string)
+		assert.True(ok)
+		assert.Contains(email, `@`)
+		assert.False(emails[email])
+		emails[email] = true
+
+		age := record.Get(`age`).(int64)
+		assert.True(age >= 18 && age <= 90)
+
+		assert.Contains([]interface{}{`active`, `suspended`}, record.Get(`status`))
+
+		if name := record.Get(`first_name`); name != nil {
+			assert.True(len(name.(string)) <= 4)
+		}
+
+		if createdAt := record.Get(`created_at`); createdAt != nil {
+			assert.True(createdAt.(time.Time).Before(time.Now()))
+		}
+
+		_, err := collection.MakeRecord(record)
+		assert.NoError(err)
+	}
+}
+
+func TestGeneratorSeeded(t *testing.T) {
+	assert := require.New(t)
+	collection := testFakeCollection()
+	now := time.Date(2018, 5, 4, 3, 2, 1, 0, time.UTC)
+
+	a := New(collection, 7)
+	a.Now = now
+	b := New(collection, 7)
+	b.Now = now
+	c := New(collection, 8)
+	c.Now = now
+
+	first, err := a.Records(10)
+	assert.NoError(err)
+	second, err := b.Records(10)
+	assert.NoError(err)
+	third, err := c.Records(10)
+	assert.NoError(err)
+
+	assert.Equal(first.Records, second.Records)
+	assert.NotEqual(first.Records, third.Records)
+}
+
+func TestGeneratorStringIdentity(t *testing.T) {
+	assert := require.New(t)
+	collection := dal.NewCollection(`things`)
+	collection.IdentityFieldType = dal.StringType
+
+	record, err := New(collection, 1).Record()
+	assert.NoError(err)
+	assert.Len(strings.Split(record.ID.(string), `-`), 5)
+}
@@ -0,0 +1,43 @@
+package fake
+
+var firstNames = []string{
+	`Ada`, `Ben`, `Chloe`, `Diego`, `Emma`, `Farah`, `Grace`, `Hugo`, `Iris`, `Jonah`, `Kira`,
+	`Liam`, `Maya`, `Nikhil`, `Olivia`, `Pablo`, `Rosa`, `Sam`, `Tariq`, `Uma`, `Victor`, `Wren`,
+	`Ximena`, `Yusuf`, `Zoe`,
+}
+
+var lastNames = []string{
+	`Anderson`, `Brooks`, `Castillo`, `Dubois`, `Evans`, `Fischer`, `Garcia`, `Hughes`, `Ito`,
+	`Johansson`, `Kowalski`, `Lee`, `Moreau`, `Nakamura`, `Okafor`, `Patel`, `Rossi`, `Silva`,
+	`Thompson`, `Urban`, `Volkov`, `Walsh`, `Xu`, `Yilmaz`, `Zimmerman`,
+}
+
+var domains = []string{
+	`example.com`, `example.net`, `example.org`, `mail.example.com`, `test.example`,
+}
+
+var cities = []string{
+	`Amsterdam`, `Austin`, `Berlin`, `Buenos Aires`, `Cairo`, `Chicago`, `Dublin`, `Lagos`, `Lisbon`,
+	`London`, `Melbourne`, `Montreal`, `Mumbai`, `Nairobi`, `Osaka`, `Paris`, `Seoul`, `Toronto`,
+}
+
+var countries = []string{
+	`Argentina`, `Australia`, `Brazil`, `Canada`, `Egypt`, `France`, `Germany`, `India`, `Ireland`,
+	`Japan`, `Kenya`, `Mexico`, `Netherlands`, `Nigeria`, `Portugal`, `South Korea`, `United States`,
+}
+
+var streetTypes = []string{
+	`Street`, `Avenue`, `Road`, `Lane`, `Boulevard`, `Drive`, `Court`, `Way`,
+}
+
+var colors = []string{
+	`red`, `orange`, `yellow`, `green`, `blue`, `indigo`, `violet`, `black`, `white`, `gray`,
+}
+
+var words = []string{
+	`alpha`, `anchor`, `autumn`, `basin`, `beacon`, `birch`, `bright`, `canyon`, `cedar`, `cloud`,
+	`copper`, `delta`, `drift`, `ember`, `falcon`, `field`, `forest`, `frost`, `garden`, `harbor`,
+	`hollow`, `island`, `juniper`, `lantern`, `meadow`, `mesa`, `north`, `ocean`, `orbit`, `pine`,
+	`prairie`, `quartz`, `river`, `sable`, `shore`, `signal`, `spring`, `stone`, `summit`, `thunder`,
+	`timber`, `valley`, `willow`, `winter`, `zephyr`,
+}
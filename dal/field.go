@@ -22,11 +22,19 @@ type Field struct {
 	Precision          int                    `json:"precision,omitempty"`
 	Identity           bool                   `json:"identity,omitempty"`
 	Key                bool                   `json:"key,omitempty"`
+	SortKey            bool                   `json:"sort_key,omitempty"`
 	Required           bool                   `json:"required,omitempty"`
 	Unique             bool                   `json:"unique,omitempty"`
 	DefaultValue       interface{}            `json:"default,omitempty"`
 	NativeType         string                 `json:"native_type,omitempty"`
+	Generated          string                 `json:"generated,omitempty"`
+	GeneratedStored    bool                   `json:"generated_stored,omitempty"`
 	ValidateOnPopulate bool                   `json:"validate_on_populate,omitempty"`
+	Hidden             bool                   `json:"hidden,omitempty"`
+	Redacted           bool                   `json:"redacted,omitempty"`
+	IndexAnalyzer      string                 `json:"index_analyzer,omitempty"`
+	IndexNoStore       bool                   `json:"index_no_store,omitempty"`
+	IndexLanguage      string                 `json:"index_language,omitempty"`
 	Validator          FieldValidatorFunc     `json:"-"`
 	Formatter          FieldFormatterFunc     `json:"-"`
 	FormatterConfig    map[string]interface{} `json:"formatters,omitempty"`
@@ -36,6 +44,9 @@ type Field struct {
 func (self *Field) ConvertValue(in interface{}) (interface{}, error) {
 	var convertType stringutil.ConvertType
 
+	// non-empty zero values (e.g.: 0) are distinct from null, and are preserved for numeric types
+	explicitValue := (in != nil && fmt.Sprintf("%v", in) != ``)
+
 	switch self.Type {
 	case StringType:
 		convertType = stringutil.String
@@ -51,6 +62,8 @@ func (self *Field) ConvertValue(in interface{}) (interface{}, error) {
 		convertType = stringutil.Integer
 	case FloatType:
 		convertType = stringutil.Float
+	case PointType, PolygonType:
+		return self.convertGeometry(in)
 	case TimeType:
 		// parse incoming int64s as epoch or epoch milliseconds
 		if inInt64, ok := in.(int64); ok {
@@ -81,7 +94,10 @@ func (self *Field) ConvertValue(in interface{}) (interface{}, error) {
 
 	// decide what to do with the now-normalized type
 	if typeutil.IsZero(in) {
-		if self.DefaultValue != nil {
+		if explicitValue && (self.Type == IntType || self.Type == FloatType) {
+			return in, nil
+
+		} else if self.DefaultValue != nil {
 			return self.GetDefaultValue(), nil
 
 		} else if self.Type == BooleanType && in != nil {
@@ -98,6 +114,23 @@ func (self *Field) ConvertValue(in interface{}) (interface{}, error) {
 	}
 }
 
+// geographic values are parsed from any of the representations ParsePoint and ParsePolygon accept
+func (self *Field) convertGeometry(in interface{}) (interface{}, error) {
+	if in == nil || fmt.Sprintf("%v", in) == `` {
+		if self.DefaultValue != nil {
+			in = self.GetDefaultValue()
+		} else {
+			return nil, nil
+		}
+	}
+
+	if self.Type == PointType {
+		return ParsePoint(in)
+	} else {
+		return ParsePolygon(in)
+	}
+}
+
 func (self *Field) GetDefaultValue() interface{} {
 	if self.DefaultValue == nil {
 		return nil
@@ -124,6 +157,10 @@ func (self *Field) GetTypeInstance() interface{} {
 		return &time.Time{}
 	case ObjectType:
 		return make(map[string]interface{})
+	case PointType:
+		return Point{}
+	case PolygonType:
+		return Polygon{}
 	default:
 		return make([]byte, 0)
 	}
@@ -175,8 +212,14 @@ func (self *Field) Diff(other *Field) []SchemaDelta {
 			//		this is largely for the use of the client application and won't always have a backend-persistent counterpart
 			//  DefaultValue:
 			//		this is a value that is interpreted by the backend and may not be retrievable after definition
+			//  Hidden, Redacted:
+			//		these only affect how values are presented in API responses
+			//  IndexAnalyzer, IndexNoStore, IndexLanguage:
+			//		these only affect how values are stored in search indexes
+			//  Generated, GeneratedStored:
+			//		backends rewrite generated column expressions, and not all of them report these back
 			//
-			case `NativeType`, `Description`, `DefaultValue`, `Validator`, `Formatter`, `FormatterConfig`, `ValidatorConfig`:
+			case `NativeType`, `Description`, `DefaultValue`, `Validator`, `Formatter`, `FormatterConfig`, `ValidatorConfig`, `Hidden`, `Redacted`, `IndexAnalyzer`, `IndexNoStore`, `IndexLanguage`, `Generated`, `GeneratedStored`:
 				continue
 			case `Length`:
 				if myV, ok := myField.Value().(int); ok {
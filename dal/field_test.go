@@ -235,6 +235,62 @@ func TestFieldConvertValueBool(t *testing.T) {
 	}
 }
 
+func TestFieldConvertValueNumericZero(t *testing.T) {
+	assert := require.New(t)
+
+	for _, field := range []*Field{
+		{Type: IntType},
+		{Type: FloatType},
+		{Type: IntType, DefaultValue: 42},
+	} {
+		value, err := field.ConvertValue(nil)
+		assert.NoError(err)
+
+		if field.DefaultValue == nil {
+			assert.Nil(value)
+		} else {
+			assert.Equal(42, value)
+		}
+
+		value, err = field.ConvertValue(0)
+		assert.NoError(err)
+		assert.NotNil(value)
+		assert.Zero(value)
+
+		value, err = field.ConvertValue(`0`)
+		assert.NoError(err)
+		assert.NotNil(value)
+		assert.Zero(value)
+	}
+}
+
+func TestFieldConvertValueGeometry(t *testing.T) {
+	assert := require.New(t)
+
+	field := &Field{Type: PointType}
+
+	value, err := field.ConvertValue(nil)
+	assert.NoError(err)
+	assert.Nil(value)
+
+	value, err = field.ConvertValue(`40.6892,-74.0445`)
+	assert.NoError(err)
+	assert.Equal(Point{Latitude: 40.6892, Longitude: -74.0445}, value)
+
+	value, err = field.ConvertValue(Point{})
+	assert.NoError(err)
+	assert.Equal(Point{}, value)
+
+	_, err = field.ConvertValue(`not a point`)
+	assert.Error(err)
+
+	field = &Field{Type: PolygonType}
+
+	value, err = field.ConvertValue(`POLYGON((0 0, 1 0, 1 1, 0 0))`)
+	assert.NoError(err)
+	assert.Equal(Polygon{{0, 0}, {0, 1}, {1, 1}}, value)
+}
+
 // TODO: basically the *worst* thing you can write in a file full of tests
 // func TestFieldConvertValueInteger(t *testing.T) {}
 // func TestFieldConvertValueFloat(t *testing.T) {}
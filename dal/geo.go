@@ -0,0 +1,490 @@
+package dal
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+)
+
+// A geographic location, in degrees (WGS 84).
+type Point struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+}
+
+// A geographic area, described by the points along its boundary.
+type Polygon []Point
+
+// the geometry types used in Well-Known Binary
+const (
+	wkbPoint   uint32 = 1
+	wkbPolygon uint32 = 3
+
+	// set in the type of PostGIS' "extended" WKB to indicate that an SRID follows it
+	ewkbSRIDFlag uint32 = 0x20000000
+)
+
+// Returns the point in Well-Known Text format (longitude first, as with all WKT and GeoJSON).
+func (self Point) WKT() string {
+	return fmt.Sprintf("POINT(%v %v)", self.Longitude, self.Latitude)
+}
+
+// Returns the point as a GeoJSON geometry.
+func (self Point) GeoJSON() map[string]interface{} {
+	return map[string]interface{}{
+		`type`:        `Point`,
+		`coordinates`: []float64{self.Longitude, self.Latitude},
+	}
+}
+
+func (self Point) String() string {
+	return fmt.Sprintf("%v,%v", self.Latitude, self.Longitude)
+}
+
+// Returns the polygon's boundary, ending with the point it starts at.
+func (self Polygon) Ring() []Point {
+	if len(self) > 0 && self[0] != self[len(self)-1] {
+		return append(append([]Point{}, self...), self[0])
+	}
+
+	return self
+}
+
+// boundaries are given ending with the point they start at, which Polygons leave implied
+func (self Polygon) open() Polygon {
+	if len(self) > 1 && self[0] == self[len(self)-1] {
+		return self[:len(self)-1]
+	}
+
+	return self
+}
+
+// Returns the polygon in Well-Known Text format.
+func (self Polygon) WKT() string {
+	points := make([]string, 0, len(self)+1)
+
+	for _, point := range self.Ring() {
+		points = append(points, fmt.Sprintf("%v %v", point.Longitude, point.Latitude))
+	}
+
+	return fmt.Sprintf("POLYGON((%s))", strings.Join(points, `, `))
+}
+
+// Returns the polygon as a GeoJSON geometry.
+func (self Polygon) GeoJSON() map[string]interface{} {
+	coordinates := make([][]float64, 0, len(self)+1)
+
+	for _, point := range self.Ring() {
+		coordinates = append(coordinates, []float64{point.Longitude, point.Latitude})
+	}
+
+	return map[string]interface{}{
+		`type`:        `Polygon`,
+		`coordinates`: [][][]float64{coordinates},
+	}
+}
+
+// Parses a point from any of the following:
+//
+//   - a Point (or pointer to one)
+//   - a string of the form "lat,lon"
+//   - Well-Known Text (e.g.: "POINT(lon lat)") or Well-Known Binary (including PostGIS' EWKB)
+//   - a map with "lat" and "lon" keys (or "latitude", "lng", and "longitude")
+//   - a GeoJSON Point geometry
+//   - a two-element array of [lon, lat] (as used by GeoJSON)
+//   - any of the above encoded as JSON
+func ParsePoint(in interface{}) (Point, error) {
+	// maps of other types (e.g.: those decoded by database drivers) are treated as plain maps
+	if m, ok := mapOf(in); ok {
+		in = m
+	}
+
+	switch v := in.(type) {
+	case Point:
+		return v, nil
+	case *Point:
+		if v != nil {
+			return *v, nil
+		}
+	case []byte:
+		if geometry, err := ParseWKB(v); err == nil {
+			if point, ok := geometry.(Point); ok {
+				return point, nil
+			}
+
+			return Point{}, fmt.Errorf("Expected a point, got %T", geometry)
+		}
+
+		return ParsePoint(string(v))
+
+	case string:
+		v = strings.TrimSpace(v)
+
+		if stringutil.IsSurroundedBy(v, `{`, `}`) || stringutil.IsSurroundedBy(v, `[`, `]`) {
+			var data interface{}
+
+			if err := json.Unmarshal([]byte(v), &data); err == nil {
+				return ParsePoint(data)
+			} else {
+				return Point{}, err
+			}
+		}
+
+		if coordinates, ok := wktCoordinates(v, `POINT`); ok {
+			if points, err := parseWktPoints(coordinates); err == nil && len(points) == 1 {
+				return points[0], nil
+			}
+		} else if parts := strings.Split(v, `,`); len(parts) == 2 {
+			return pointFromValues(parts[0], parts[1])
+		}
+
+	case map[string]interface{}:
+		if coordinates, ok := v[`coordinates`]; ok {
+			return ParsePoint(coordinates)
+		}
+
+		lat, hasLat := firstKey(v, `lat`, `latitude`)
+		lon, hasLon := firstKey(v, `lon`, `lng`, `longitude`)
+
+		if hasLat && hasLon {
+			return pointFromValues(lat, lon)
+		}
+
+	default:
+		if values, ok := sliceOf(in); ok && len(values) == 2 {
+			return pointFromValues(values[1], values[0])
+		}
+	}
+
+	return Point{}, fmt.Errorf("Cannot parse %T value as a point", in)
+}
+
+// Parses a polygon from any of the following:
+//
+//   - a Polygon or a slice of Points
+//   - Well-Known Text (e.g.: "POLYGON((lon lat, ...))") or Well-Known Binary (including PostGIS' EWKB)
+//   - a GeoJSON Polygon geometry (of which only the outer boundary is used)
+//   - an array of values that can be parsed as points
+//   - any of the above encoded as JSON
+func ParsePolygon(in interface{}) (Polygon, error) {
+	// maps of other types (e.g.: those decoded by database drivers) are treated as plain maps
+	if m, ok := mapOf(in); ok {
+		in = m
+	}
+
+	switch v := in.(type) {
+	case Polygon:
+		return v, nil
+	case []Point:
+		return Polygon(v), nil
+	case []byte:
+		if geometry, err := ParseWKB(v); err == nil {
+			if polygon, ok := geometry.(Polygon); ok {
+				return polygon, nil
+			}
+
+			return nil, fmt.Errorf("Expected a polygon, got %T", geometry)
+		}
+
+		return ParsePolygon(string(v))
+
+	case string:
+		v = strings.TrimSpace(v)
+
+		if stringutil.IsSurroundedBy(v, `{`, `}`) || stringutil.IsSurroundedBy(v, `[`, `]`) {
+			var data interface{}
+
+			if err := json.Unmarshal([]byte(v), &data); err == nil {
+				return ParsePolygon(data)
+			} else {
+				return nil, err
+			}
+		}
+
+		if coordinates, ok := wktCoordinates(v, `POLYGON`); ok {
+			// only the outer boundary is used
+			if end := strings.Index(coordinates, `)`); end >= 0 {
+				coordinates = coordinates[:end]
+			}
+
+			if points, err := parseWktPoints(strings.TrimPrefix(coordinates, `(`)); err == nil {
+				return Polygon(points).open(), nil
+			} else {
+				return nil, err
+			}
+		}
+
+	case map[string]interface{}:
+		if coordinates, ok := v[`coordinates`]; ok {
+			return ParsePolygon(coordinates)
+		}
+
+	default:
+		if values, ok := sliceOf(in); ok {
+			// GeoJSON polygons are a list of rings, the first of which is the outer boundary
+			if len(values) > 0 {
+				if ring, ok := sliceOf(values[0]); ok && len(ring) > 0 {
+					if _, ok := sliceOf(ring[0]); ok {
+						return ParsePolygon(values[0])
+					}
+				}
+			}
+
+			polygon := make(Polygon, len(values))
+
+			for i, value := range values {
+				if point, err := ParsePoint(value); err == nil {
+					polygon[i] = point
+				} else {
+					return nil, err
+				}
+			}
+
+			return polygon.open(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("Cannot parse %T value as a polygon", in)
+}
+
+// Parses a Point or Polygon from Well-Known Binary, as returned by spatial databases.  PostGIS'
+// extended WKB (which includes an SRID) is also accepted, as is either encoded as a hex string.
+func ParseWKB(data []byte) (interface{}, error) {
+	if decoded, ok := decodeHexWKB(data); ok {
+		data = decoded
+	}
+
+	reader := &wkbReader{
+		data: data,
+	}
+
+	switch reader.byte() {
+	case 0:
+		reader.order = binary.BigEndian
+	case 1:
+		reader.order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("Invalid WKB byte order")
+	}
+
+	geometryType := reader.uint32()
+
+	if geometryType&ewkbSRIDFlag != 0 {
+		geometryType &^= ewkbSRIDFlag
+		reader.uint32()
+	}
+
+	var geometry interface{}
+
+	switch geometryType {
+	case wkbPoint:
+		geometry = reader.point()
+
+	case wkbPolygon:
+		var polygon Polygon
+
+		for ring, rings := uint32(0), reader.uint32(); ring < rings && reader.err == nil; ring++ {
+			points := reader.uint32()
+
+			if reader.err == nil && uint64(points)*16 > uint64(len(reader.data)) {
+				reader.err = fmt.Errorf("Truncated WKB")
+				break
+			}
+
+			for i := uint32(0); i < points; i++ {
+				// only the outer boundary is used
+				if point := reader.point(); ring == 0 {
+					polygon = append(polygon, point)
+				}
+			}
+		}
+
+		geometry = polygon.open()
+
+	default:
+		return nil, fmt.Errorf("Unsupported WKB geometry type %d", geometryType)
+	}
+
+	if reader.err != nil {
+		return nil, reader.err
+	} else if len(reader.data) > 0 {
+		return nil, fmt.Errorf("Unexpected data after WKB geometry")
+	}
+
+	return geometry, nil
+}
+
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (self *wkbReader) take(n int) []byte {
+	if self.err != nil {
+		return make([]byte, n)
+	} else if len(self.data) < n {
+		self.err = fmt.Errorf("Truncated WKB")
+		return make([]byte, n)
+	}
+
+	out := self.data[:n]
+	self.data = self.data[n:]
+
+	return out
+}
+
+func (self *wkbReader) byte() byte {
+	return self.take(1)[0]
+}
+
+func (self *wkbReader) uint32() uint32 {
+	data := self.take(4)
+
+	if self.order == nil {
+		return 0
+	}
+
+	return self.order.Uint32(data)
+}
+
+func (self *wkbReader) point() Point {
+	data := self.take(16)
+
+	if self.order == nil {
+		return Point{}
+	}
+
+	return Point{
+		Longitude: math.Float64frombits(self.order.Uint64(data[0:8])),
+		Latitude:  math.Float64frombits(self.order.Uint64(data[8:16])),
+	}
+}
+
+// PostgreSQL returns geometries as hex-encoded EWKB
+func decodeHexWKB(data []byte) ([]byte, bool) {
+	if len(data) < 10 {
+		return nil, false
+	}
+
+	decoded := make([]byte, hex.DecodedLen(len(data)))
+
+	if _, err := hex.Decode(decoded, data); err == nil {
+		return decoded, true
+	}
+
+	return nil, false
+}
+
+// returns what's inside the parentheses of WKT geometry of the given type
+func wktCoordinates(in string, geometryType string) (string, bool) {
+	in = strings.TrimSpace(in)
+
+	if len(in) > len(geometryType) && strings.EqualFold(in[:len(geometryType)], geometryType) {
+		in = strings.TrimSpace(in[len(geometryType):])
+
+		if stringutil.IsSurroundedBy(in, `(`, `)`) {
+			return strings.TrimSpace(in[1 : len(in)-1]), true
+		}
+	}
+
+	return ``, false
+}
+
+// parses a comma-separated list of "lon lat" pairs
+func parseWktPoints(in string) ([]Point, error) {
+	points := make([]Point, 0)
+
+	for _, pair := range strings.Split(in, `,`) {
+		if coordinates := strings.Fields(pair); len(coordinates) == 2 {
+			if point, err := pointFromValues(coordinates[1], coordinates[0]); err == nil {
+				points = append(points, point)
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, fmt.Errorf("Invalid WKT coordinates %q", pair)
+		}
+	}
+
+	return points, nil
+}
+
+func pointFromValues(lat interface{}, lon interface{}) (Point, error) {
+	var point Point
+
+	if v, err := stringutil.ConvertToFloat(strings.TrimSpace(fmt.Sprintf("%v", lat))); err == nil {
+		point.Latitude = v
+	} else {
+		return Point{}, fmt.Errorf("Invalid latitude: %v", err)
+	}
+
+	if v, err := stringutil.ConvertToFloat(strings.TrimSpace(fmt.Sprintf("%v", lon))); err == nil {
+		point.Longitude = v
+	} else {
+		return Point{}, fmt.Errorf("Invalid longitude: %v", err)
+	}
+
+	if point.Latitude < -90 || point.Latitude > 90 {
+		return Point{}, fmt.Errorf("Latitude %v is out of range", point.Latitude)
+	} else if point.Longitude < -180 || point.Longitude > 180 {
+		return Point{}, fmt.Errorf("Longitude %v is out of range", point.Longitude)
+	}
+
+	return point, nil
+}
+
+func firstKey(in map[string]interface{}, keys ...string) (interface{}, bool) {
+	for _, key := range keys {
+		if v, ok := in[key]; ok {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+func mapOf(in interface{}) (map[string]interface{}, bool) {
+	if in == nil {
+		return nil, false
+	} else if m, ok := in.(map[string]interface{}); ok {
+		return m, true
+	}
+
+	if value := reflect.ValueOf(in); value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String {
+		out := make(map[string]interface{}, value.Len())
+
+		for _, key := range value.MapKeys() {
+			out[key.String()] = value.MapIndex(key).Interface()
+		}
+
+		return out, true
+	}
+
+	return nil, false
+}
+
+func sliceOf(in interface{}) ([]interface{}, bool) {
+	if in == nil {
+		return nil, false
+	}
+
+	switch value := reflect.ValueOf(in); value.Kind() {
+	case reflect.Slice, reflect.Array:
+		out := make([]interface{}, value.Len())
+
+		for i := 0; i < value.Len(); i++ {
+			out[i] = value.Index(i).Interface()
+		}
+
+		return out, true
+	default:
+		return nil, false
+	}
+}
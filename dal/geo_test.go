@@ -0,0 +1,134 @@
+package dal
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePoint(t *testing.T) {
+	assert := require.New(t)
+	expected := Point{Latitude: 40.6892, Longitude: -74.0445}
+
+	for _, in := range []interface{}{
+		expected,
+		&expected,
+		`40.6892,-74.0445`,
+		` 40.6892, -74.0445 `,
+		`POINT(-74.0445 40.6892)`,
+		`point (-74.0445 40.6892)`,
+		[]float64{-74.0445, 40.6892},
+		[]interface{}{-74.0445, 40.6892},
+		map[string]interface{}{`lat`: 40.6892, `lon`: -74.0445},
+		map[string]interface{}{`latitude`: `40.6892`, `longitude`: `-74.0445`},
+		map[string]interface{}{`lat`: 40.6892, `lng`: -74.0445},
+		map[string]interface{}{`type`: `Point`, `coordinates`: []interface{}{-74.0445, 40.6892}},
+		`{"lat": 40.6892, "lon": -74.0445}`,
+		`[-74.0445, 40.6892]`,
+		map[string]float64{`lat`: 40.6892, `lon`: -74.0445},
+		[]byte(`40.6892,-74.0445`),
+	} {
+		point, err := ParsePoint(in)
+		assert.NoError(err, "%v", in)
+		assert.Equal(expected, point, "%v", in)
+	}
+
+	for _, in := range []interface{}{
+		nil,
+		``,
+		`40.6892`,
+		`91,0`,
+		`0,181`,
+		`POINT(1)`,
+		[]float64{1, 2, 3},
+		map[string]interface{}{`lat`: 1},
+	} {
+		_, err := ParsePoint(in)
+		assert.Error(err, "%v", in)
+	}
+
+	assert.Equal(`POINT(-74.0445 40.6892)`, expected.WKT())
+	assert.Equal(`40.6892,-74.0445`, expected.String())
+	assert.Equal([]float64{-74.0445, 40.6892}, expected.GeoJSON()[`coordinates`])
+}
+
+func TestParsePolygon(t *testing.T) {
+	assert := require.New(t)
+	expected := Polygon{{0, 0}, {0, 1}, {1, 1}}
+
+	for _, in := range []interface{}{
+		expected,
+		[]Point{{0, 0}, {0, 1}, {1, 1}},
+		`POLYGON((0 0, 1 0, 1 1))`,
+		`POLYGON ((0 0,1 0,1 1), (0.1 0.1, 0.2 0.1, 0.2 0.2))`,
+		[]interface{}{`0,0`, `0,1`, `1,1`},
+		[][]float64{{0, 0}, {1, 0}, {1, 1}},
+		map[string]interface{}{
+			`type`:        `Polygon`,
+			`coordinates`: [][][]float64{{{0, 0}, {1, 0}, {1, 1}}},
+		},
+		`[[0, 0], [1, 0], [1, 1]]`,
+		`POLYGON((0 0, 1 0, 1 1, 0 0))`,
+		`[[[0, 0], [1, 0], [1, 1], [0, 0]]]`,
+	} {
+		polygon, err := ParsePolygon(in)
+		assert.NoError(err, "%v", in)
+		assert.Equal(expected, polygon, "%v", in)
+	}
+
+	_, err := ParsePolygon(`POINT(0 0)`)
+	assert.Error(err)
+
+	_, err = ParsePolygon(42)
+	assert.Error(err)
+
+	// the boundary is closed when written out
+	assert.Equal(`POLYGON((0 0, 1 0, 1 1, 0 0))`, expected.WKT())
+	assert.Len(expected.Ring(), 4)
+	assert.Len(Polygon{{0, 0}, {0, 1}, {1, 1}, {0, 0}}.Ring(), 4)
+}
+
+func TestParseWKB(t *testing.T) {
+	assert := require.New(t)
+
+	// POINT(1 2), little-endian
+	point := []byte{
+		0x01,
+		0x01, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40,
+	}
+
+	geometry, err := ParseWKB(point)
+	assert.NoError(err)
+	assert.Equal(Point{Latitude: 2, Longitude: 1}, geometry)
+
+	// the same point as hex-encoded EWKB with SRID 4326 (as returned by PostGIS)
+	geometry, err = ParseWKB([]byte(`0101000020E6100000000000000000F03F0000000000000040`))
+	assert.NoError(err)
+	assert.Equal(Point{Latitude: 2, Longitude: 1}, geometry)
+
+	// POLYGON((0 0, 1 0, 1 1, 0 0)), big-endian
+	polygon, err := hex.DecodeString(
+		`00000000030000000100000004` +
+			`00000000000000000000000000000000` +
+			`3ff00000000000000000000000000000` +
+			`3ff00000000000003ff0000000000000` +
+			`00000000000000000000000000000000`,
+	)
+	assert.NoError(err)
+
+	geometry, err = ParseWKB(polygon)
+	assert.NoError(err)
+	assert.Equal(Polygon{{0, 0}, {0, 1}, {1, 1}}, geometry)
+
+	_, err = ParseWKB(polygon[:20])
+	assert.Error(err)
+
+	_, err = ParseWKB(append(point, 0x00))
+	assert.Error(err)
+
+	_, err = ParseWKB([]byte{0x02, 0x01, 0x00, 0x00, 0x00})
+	assert.Error(err)
+}
@@ -0,0 +1,84 @@
+package dal
+
+import (
+	"encoding/json"
+	"reflect"
+	"unicode/utf8"
+)
+
+// Checks the given record against the collection's size limits (MaxRecordBytes, MaxFields,
+// MaxStringLength, and MaxArrayElements), returning an ErrLimitExceeded error describing the first
+// limit that was exceeded.  Limits that are zero are not enforced.
+func (self *Collection) CheckLimits(record *Record) error {
+	if record == nil {
+		return nil
+	}
+
+	if max := self.MaxFields; max > 0 && len(record.Fields) > max {
+		return LimitExceeded("Record %v has %d fields, the maximum is %d", record.ID, len(record.Fields), max)
+	}
+
+	if self.MaxStringLength > 0 || self.MaxArrayElements > 0 {
+		for key, value := range record.Fields {
+			if err := self.checkValueLimits(record, key, reflect.ValueOf(value)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if max := self.MaxRecordBytes; max > 0 {
+		if data, err := json.Marshal(record); err == nil {
+			if len(data) > max {
+				return LimitExceeded("Record %v is %d bytes, the maximum is %d", record.ID, len(data), max)
+			}
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *Collection) checkValueLimits(record *Record, key string, value reflect.Value) error {
+	for value.IsValid() && (value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr) {
+		value = value.Elem()
+	}
+
+	if !value.IsValid() {
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		if max := self.MaxStringLength; max > 0 {
+			if l := utf8.RuneCountInString(value.String()); l > max {
+				return LimitExceeded("Field %q of record %v is %d characters long, the maximum is %d", key, record.ID, l, max)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		// byte arrays are raw data rather than lists of values
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+
+		if max := self.MaxArrayElements; max > 0 && value.Len() > max {
+			return LimitExceeded("Field %q of record %v has %d elements, the maximum is %d", key, record.ID, value.Len(), max)
+		}
+
+		for i := 0; i < value.Len(); i++ {
+			if err := self.checkValueLimits(record, key, value.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		for _, mapKey := range value.MapKeys() {
+			if err := self.checkValueLimits(record, key, value.MapIndex(mapKey)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package dal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionCheckLimits(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionCheckLimits`)
+	assert.NoError(collection.CheckLimits(NewRecord(1).Set(`name`, strings.Repeat(`x`, 4096))))
+
+	collection.MaxFields = 2
+	assert.NoError(collection.CheckLimits(NewRecord(1).Set(`a`, 1).Set(`b`, 2)))
+
+	err := collection.CheckLimits(NewRecord(1).Set(`a`, 1).Set(`b`, 2).Set(`c`, 3))
+	assert.True(errors.Is(err, ErrLimitExceeded))
+	assert.Equal(`Record 1 has 3 fields, the maximum is 2`, err.Error())
+
+	collection.MaxFields = 0
+	collection.MaxStringLength = 4
+	assert.NoError(collection.CheckLimits(NewRecord(1).Set(`name`, `café`)))
+	assert.True(errors.Is(collection.CheckLimits(NewRecord(1).Set(`name`, `cafés`)), ErrLimitExceeded))
+
+	// nested values are checked too
+	assert.True(errors.Is(collection.CheckLimits(NewRecord(1).Set(`tags`, []interface{}{
+		map[string]interface{}{
+			`name`: `toolong`,
+		},
+	})), ErrLimitExceeded))
+
+	collection.MaxStringLength = 0
+	collection.MaxArrayElements = 2
+	assert.NoError(collection.CheckLimits(NewRecord(1).Set(`tags`, []string{`a`, `b`})))
+	assert.NoError(collection.CheckLimits(NewRecord(1).Set(`data`, []byte(`abcdef`))))
+	assert.True(errors.Is(collection.CheckLimits(NewRecord(1).Set(`tags`, []string{`a`, `b`, `c`})), ErrLimitExceeded))
+
+	collection.MaxArrayElements = 0
+	collection.MaxRecordBytes = 64
+	assert.NoError(collection.CheckLimits(NewRecord(1).Set(`name`, `short`)))
+	assert.True(errors.Is(collection.CheckLimits(NewRecord(1).Set(`name`, strings.Repeat(`x`, 64))), ErrLimitExceeded))
+
+	// limits are enforced when making records
+	collection.MaxRecordBytes = 0
+	collection.MaxFields = 1
+
+	_, err = collection.MakeRecord(NewRecord(1).Set(`a`, 1).Set(`b`, 2))
+	assert.NoError(err)
+
+	collection.AddFields(Field{
+		Name: `a`,
+		Type: IntType,
+	}, Field{
+		Name: `b`,
+		Type: IntType,
+	})
+
+	_, err = collection.MakeRecord(NewRecord(1).Set(`a`, 1).Set(`b`, 2))
+	assert.True(errors.Is(err, ErrLimitExceeded))
+}
@@ -0,0 +1,82 @@
+package dal
+
+import (
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+)
+
+type NamingCase string
+
+const (
+	AsIsCase   NamingCase = ``
+	SnakeCase             = `snake`
+	CamelCase             = `camel`
+	PascalCase            = `pascal`
+)
+
+// Describes how the names of struct fields are translated into the names of the record fields (and
+// columns) they are stored in, so that types don't need a struct tag on every field to work with
+// existing naming schemes.  Fields whose struct tag specifies a name are never renamed.
+//
+// Converting a name that already follows the convention returns it unchanged, which allows the same
+// convention to be applied to field names given in filters (which may be either struct field names
+// or the stored names).
+type NamingConvention struct {
+	Case   NamingCase          `json:"case,omitempty"`
+	Prefix string              `json:"prefix,omitempty"`
+	Suffix string              `json:"suffix,omitempty"`
+	Func   func(string) string `json:"-"` // a custom rule applied after the case, prefix, and suffix
+}
+
+// The naming convention used by collections that don't specify one, and when populating structs
+// without a collection.  The default (nil) leaves struct field names as-is.
+var DefaultNamingConvention *NamingConvention
+
+// Returns the stored name for the given struct field name.
+func (self *NamingConvention) FieldName(name string) string {
+	if self == nil || name == `` {
+		return name
+	}
+
+	switch self.Case {
+	case SnakeCase:
+		name = stringutil.Underscore(name)
+	case CamelCase:
+		name = pascalize(name)
+
+		if name != `` {
+			name = strings.ToLower(name[:1]) + name[1:]
+		}
+	case PascalCase:
+		name = pascalize(name)
+	}
+
+	if self.Prefix != `` && !strings.HasPrefix(name, self.Prefix) {
+		name = self.Prefix + name
+	}
+
+	if self.Suffix != `` && !strings.HasSuffix(name, self.Suffix) {
+		name = name + self.Suffix
+	}
+
+	if self.Func != nil {
+		name = self.Func(name)
+	}
+
+	return name
+}
+
+// joins the words in a delimited name with the first letter of each word capitalized, leaving the
+// rest of each word (and names that are already capitalized this way) alone
+func pascalize(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	})
+
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+
+	return strings.Join(words, ``)
+}
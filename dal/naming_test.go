@@ -0,0 +1,102 @@
+package dal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamingConventionFieldName(t *testing.T) {
+	assert := require.New(t)
+
+	var naming *NamingConvention
+	assert.Equal(`FirstName`, naming.FieldName(`FirstName`))
+
+	naming = &NamingConvention{
+		Case: SnakeCase,
+	}
+
+	assert.Equal(`first_name`, naming.FieldName(`FirstName`))
+	assert.Equal(`first_name`, naming.FieldName(`first_name`))
+
+	naming = &NamingConvention{
+		Case: PascalCase,
+	}
+
+	assert.Equal(`FirstName`, naming.FieldName(`first_name`))
+	assert.Equal(`FirstName`, naming.FieldName(`FirstName`))
+
+	naming = &NamingConvention{
+		Case: CamelCase,
+	}
+
+	assert.Equal(`firstName`, naming.FieldName(`first_name`))
+	assert.Equal(`firstName`, naming.FieldName(`FirstName`))
+
+	naming = &NamingConvention{
+		Case:   SnakeCase,
+		Prefix: `usr_`,
+		Suffix: `_c`,
+	}
+
+	assert.Equal(`usr_first_name_c`, naming.FieldName(`FirstName`))
+	assert.Equal(`usr_first_name_c`, naming.FieldName(`usr_first_name_c`))
+
+	naming = &NamingConvention{
+		Case: SnakeCase,
+		Func: strings.ToUpper,
+	}
+
+	assert.Equal(`FIRST_NAME`, naming.FieldName(`FirstName`))
+}
+
+func TestNamingConventionRecords(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestNamingConventionRecords`)
+	collection.NamingConvention = &NamingConvention{
+		Case: SnakeCase,
+	}
+
+	collection.AddFields([]Field{
+		{
+			Name: `first_name`,
+			Type: StringType,
+		}, {
+			Name: `login_count`,
+			Type: IntType,
+		}, {
+			Name: `enabled`,
+			Type: BooleanType,
+		},
+	}...)
+
+	type TestUser struct {
+		ID         int `pivot:"id,identity"`
+		FirstName  string
+		LoginCount int
+		Active     bool `pivot:"enabled"`
+	}
+
+	record, err := collection.MakeRecord(&TestUser{
+		ID:         1,
+		FirstName:  `Bob`,
+		LoginCount: 3,
+		Active:     true,
+	})
+
+	assert.NoError(err)
+	assert.Equal(1, record.ID)
+	assert.Equal(`Bob`, record.Get(`first_name`))
+	assert.Equal(3, record.Get(`login_count`))
+	assert.Equal(true, record.Get(`enabled`))
+
+	var user TestUser
+
+	assert.NoError(NewRecord(2).Set(`first_name`, `Ted`).Set(`login_count`, 5).Set(`enabled`, true).Populate(&user, collection))
+	assert.Equal(2, user.ID)
+	assert.Equal(`Ted`, user.FirstName)
+	assert.Equal(5, user.LoginCount)
+	assert.True(user.Active)
+}
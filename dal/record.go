@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/structs"
@@ -20,13 +21,32 @@ type Record struct {
 	Fields map[string]interface{} `json:"fields,omitempty"`
 	Data   []byte                 `json:"data,omitempty"`
 	Error  error                  `json:"error,omitempty"`
+	Score  float64                `json:"_score,omitempty"`
+
+	// An opaque token identifying the stored version of the record, set by backends that track
+	// revisions (e.g.: CouchDB's _rev).  Those backends only update records whose revision is unchanged
+	// since they were read, failing with a stale record error otherwise.
+	Revision string `json:"_rev,omitempty"`
+}
+
+// Records (and their field maps) are drawn from this pool, and returned to it by Release.
+var recordPool = sync.Pool{
+	New: func() interface{} {
+		return &Record{
+			Fields: make(map[string]interface{}),
+		}
+	},
 }
 
 func NewRecord(id interface{}) *Record {
-	return &Record{
-		ID:     id,
-		Fields: make(map[string]interface{}),
+	record := recordPool.Get().(*Record)
+	record.ID = id
+
+	if record.Fields == nil {
+		record.Fields = make(map[string]interface{})
 	}
+
+	return record
 }
 
 func NewRecordErr(id interface{}, err error) *Record {
@@ -37,6 +57,24 @@ func NewRecordErr(id interface{}, err error) *Record {
 	}
 }
 
+// Clears the record and returns it to a pool so that it (and its field map) can be reused by
+// subsequent calls to NewRecord.  This is optional; callers that process large numbers of records may
+// release each one once they are done with it to reduce allocations.  The record must not be used
+// after it has been released.
+func (self *Record) Release() {
+	for k := range self.Fields {
+		delete(self.Fields, k)
+	}
+
+	self.ID = nil
+	self.Data = nil
+	self.Error = nil
+	self.Score = 0
+	self.Revision = ``
+
+	recordPool.Put(self)
+}
+
 func (self *Record) init() {
 	if self.Fields == nil {
 		self.Fields = make(map[string]interface{})
@@ -48,6 +86,8 @@ func (self *Record) Copy(other *Record) {
 		self.ID = other.ID
 		self.Fields = other.Fields
 		self.Data = other.Data
+		self.Score = other.Score
+		self.Revision = other.Revision
 	}
 }
 
@@ -89,6 +129,19 @@ func (self *Record) GetString(key string, fallback ...string) string {
 	}
 }
 
+// Returns whether the given field is present in the record and explicitly set to nil (e.g.: it was
+// read from a NULL column, or should be written as one.)  Fields that are not present at all are not
+// considered null.
+func (self *Record) IsNull(key string) bool {
+	self.init()
+
+	if v, ok := self.Fields[key]; ok {
+		return (v == nil)
+	}
+
+	return false
+}
+
 func (self *Record) Set(key string, value interface{}) *Record {
 	self.init()
 
@@ -143,6 +196,12 @@ func (self *Record) Populate(into interface{}, collection *Collection) error {
 		}
 
 		for key, value := range record.Fields {
+			// explicit nulls are kept as-is rather than being converted to zero or default values
+			if value == nil {
+				self.Set(key, nil)
+				continue
+			}
+
 			if collection != nil {
 				if collectionField, ok := collection.GetField(key); ok {
 					// use the field's type in the collection schema to convert the value
@@ -176,6 +235,10 @@ func (self *Record) Populate(into interface{}, collection *Collection) error {
 
 		self.ID = record.ID
 
+		if record.Revision != `` {
+			self.Revision = record.Revision
+		}
+
 		if collection != nil {
 			if idI, err := collection.formatAndValidateId(self.ID, RetrieveOperation, self); err == nil {
 				self.ID = idI
@@ -218,7 +281,7 @@ func (self *Record) Populate(into interface{}, collection *Collection) error {
 
 		if idFieldName != `` {
 			// get field descriptors for the output struct
-			if fields, err := getFieldsForStruct(into); err == nil {
+			if fields, err := getFieldsForStruct(into, collection.GetNamingConvention()); err == nil {
 				// for each value in the record's fields map...
 				for key, value := range self.Fields {
 					// only operate on fields that exist in the output struct
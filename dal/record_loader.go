@@ -70,7 +70,10 @@ func validatePtrToStructType(instance interface{}) error {
 	return fmt.Errorf("Can only operate on pointer to struct, got %T", instance)
 }
 
-func getFieldsForStruct(instance interface{}) (map[string]fieldDescription, error) {
+// Returns descriptors for the fields of the given struct, keyed on the name of the record field each
+// one maps to.  Fields without an explicit name in their struct tag are named using the given
+// convention (if any).
+func getFieldsForStruct(instance interface{}, naming *NamingConvention) (map[string]fieldDescription, error) {
 	fields := make(map[string]fieldDescription)
 	identitySet := false
 
@@ -89,7 +92,7 @@ func getFieldsForStruct(instance interface{}) (map[string]fieldDescription, erro
 	for _, field := range instanceStruct.Fields() {
 		var identity, omitEmpty bool
 
-		name := field.Name()
+		name := naming.FieldName(field.Name())
 
 		// read struct tags to determine how values are mapped to struct fields
 		if tag := field.Tag(RecordStructTag); tag != `` {
@@ -32,6 +32,42 @@ func TestRecordGet(t *testing.T) {
 
 }
 
+func TestRecordIsNull(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestRecordIsNull`).AddFields(Field{
+		Name:     `count`,
+		Type:     IntType,
+		Required: true,
+	}, Field{
+		Name:         `name`,
+		Type:         StringType,
+		DefaultValue: `unnamed`,
+	})
+
+	record := NewRecord(`1`).Set(`count`, nil).Set(`name`, nil)
+	assert.True(record.IsNull(`count`))
+	assert.True(record.IsNull(`name`))
+	assert.False(record.IsNull(`missing`))
+
+	// explicit nulls survive defaults and type conversion
+	collection.FillDefaults(record)
+	assert.True(record.IsNull(`name`))
+
+	populated := NewRecord(nil)
+	assert.NoError(populated.Populate(record, collection))
+	assert.True(populated.IsNull(`count`))
+	assert.True(populated.IsNull(`name`))
+
+	// ...while zero values are kept distinct from null
+	record = NewRecord(`2`).Set(`count`, 0)
+	populated = NewRecord(nil)
+	assert.NoError(populated.Populate(record, collection))
+	assert.False(populated.IsNull(`count`))
+	assert.Equal(int64(0), populated.Get(`count`))
+	assert.Equal(`unnamed`, populated.Get(`name`))
+}
+
 func TestRecordAppend(t *testing.T) {
 	assert := require.New(t)
 
@@ -260,3 +296,21 @@ func TestRecordPopulateStructWithFormatterValidator(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(`test_value`, thing.Name)
 }
+
+func TestRecordRevision(t *testing.T) {
+	assert := require.New(t)
+
+	stored := NewRecord(1).Set(`name`, `first`)
+	stored.Revision = `1-abc`
+
+	record := NewRecord(nil)
+	assert.NoError(record.Populate(stored, nil))
+	assert.Equal(`1-abc`, record.Revision)
+
+	copied := NewRecord(nil)
+	copied.Copy(record)
+	assert.Equal(`1-abc`, copied.Revision)
+
+	copied.Release()
+	assert.Empty(NewRecord(nil).Revision)
+}
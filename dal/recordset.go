@@ -1,10 +1,28 @@
 package dal
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"reflect"
+	"runtime"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
 )
 
+// The most records a query result will hold in memory before the rest are written to a temporary file
+// (see RecordSet.SetMemoryLimit).  Zero or less disables spilling, which is the default: spilled records
+// are not in the Records slice, so only enable this if every consumer of query results iterates them
+// with Each.
+var DefaultMaxInMemoryRecords = 0
+
+// The directory that spilled records are written to; empty uses the system temporary directory.
+var RecordSpillDirectory = ``
+
 type RecordSet struct {
 	ResultCount    int64                  `json:"result_count"`
 	Page           int                    `json:"page,omitempty"`
@@ -13,6 +31,27 @@ type RecordSet struct {
 	Records        []*Record              `json:"records"`
 	Options        map[string]interface{} `json:"options"`
 	KnownSize      bool                   `json:"known_size"`
+	maxInMemory    int
+	spill          *recordSpill
+}
+
+// Records past a RecordSet's in-memory limit are appended to a temporary file as lines of JSON, and
+// read back (and converted using the collection, if any) when the set is iterated.
+type recordSpill struct {
+	file       *os.File
+	writer     *bufio.Writer
+	encoder    *json.Encoder
+	count      int
+	collection *Collection
+}
+
+type spilledRecord struct {
+	ID       interface{}            `json:"id"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+	Data     []byte                 `json:"data,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Score    float64                `json:"_score,omitempty"`
+	Revision string                 `json:"_rev,omitempty"`
 }
 
 func NewRecordSet(records ...*Record) *RecordSet {
@@ -22,15 +61,121 @@ func NewRecordSet(records ...*Record) *RecordSet {
 	}
 }
 
+// Limits the number of records Push will hold in memory.  Once the limit is reached, subsequently
+// pushed records are written to a temporary file instead, and are only visible through the methods
+// that iterate over the whole set (Each, Len, Pluck, PopulateFromRecords, etc.), not the Records
+// slice.  Values read back from the file are converted to the types of the given collection's fields.
+// Release removes the file.
+func (self *RecordSet) SetMemoryLimit(maxRecords int, collection *Collection) *RecordSet {
+	self.maxInMemory = maxRecords
+
+	if self.spill != nil {
+		self.spill.collection = collection
+	} else if collection != nil {
+		self.spill = &recordSpill{
+			collection: collection,
+		}
+	}
+
+	return self
+}
+
 func (self *RecordSet) Push(record *Record) *RecordSet {
+	if self.maxInMemory > 0 && len(self.Records) >= self.maxInMemory {
+		if err := self.spillRecord(record); err == nil {
+			self.ResultCount = self.ResultCount + 1
+			return self
+		} else {
+			log.Warningf("failed to spill record %v to disk, keeping it in memory: %v", record.ID, err)
+		}
+	}
+
 	self.Records = append(self.Records, record)
 	self.ResultCount = self.ResultCount + 1
 	return self
 }
 
+// Returns the number of records that have been written to disk because the set exceeded its
+// in-memory limit.
+func (self *RecordSet) Spilled() int {
+	if self.spill != nil {
+		return self.spill.count
+	}
+
+	return 0
+}
+
+// Returns the number of records in the set, including any that were spilled to disk.
+func (self *RecordSet) Len() int {
+	return len(self.Records) + self.Spilled()
+}
+
+// Calls the given function for every record in the set, in the order they were added, reading any
+// spilled records back from disk.  Iteration stops at the first error returned by the function.
+func (self *RecordSet) Each(fn func(record *Record) error) error {
+	for _, record := range self.Records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	if self.Spilled() == 0 {
+		return nil
+	}
+
+	spill := self.spill
+
+	if err := spill.writer.Flush(); err != nil {
+		return err
+	}
+
+	if file, err := os.Open(spill.file.Name()); err == nil {
+		defer file.Close()
+
+		decoder := json.NewDecoder(bufio.NewReader(file))
+		decoder.UseNumber()
+
+		for {
+			var data spilledRecord
+
+			if err := decoder.Decode(&data); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if err := fn(spill.record(&data)); err != nil {
+				return err
+			}
+		}
+	} else {
+		return err
+	}
+}
+
+// Releases every record in the set (see Record.Release) and empties it.  Callers that opt in to
+// pooling should only do this once nothing else holds references to the set's records.
+func (self *RecordSet) Release() {
+	for _, record := range self.Records {
+		if record != nil {
+			record.Release()
+		}
+	}
+
+	self.Records = nil
+	self.ResultCount = 0
+
+	if self.spill != nil {
+		self.spill.remove()
+	}
+}
+
 func (self *RecordSet) Append(other *RecordSet) *RecordSet {
-	for _, record := range other.Records {
+	if err := other.Each(func(record *Record) error {
 		self.Push(record)
+		return nil
+	}); err != nil {
+		log.Warningf("failed to read spilled records: %v", err)
 	}
 
 	return self
@@ -39,6 +184,21 @@ func (self *RecordSet) Append(other *RecordSet) *RecordSet {
 func (self *RecordSet) GetRecord(index int) (*Record, bool) {
 	if index < len(self.Records) {
 		return self.Records[index], true
+	} else if index < self.Len() {
+		var found *Record
+		i := 0
+
+		self.Each(func(record *Record) error {
+			if i == index {
+				found = record
+				return io.EOF
+			}
+
+			i += 1
+			return nil
+		})
+
+		return found, (found != nil)
 	}
 
 	return nil, false
@@ -47,8 +207,11 @@ func (self *RecordSet) GetRecord(index int) (*Record, bool) {
 func (self *RecordSet) Pluck(field string, fallback ...interface{}) []interface{} {
 	rv := make([]interface{}, 0)
 
-	for _, record := range self.Records {
+	if err := self.Each(func(record *Record) error {
 		rv = append(rv, record.Get(field, fallback...))
+		return nil
+	}); err != nil {
+		log.Warningf("failed to read spilled records: %v", err)
 	}
 
 	return rv
@@ -90,7 +253,7 @@ func (self *RecordSet) PopulateFromRecords(into interface{}, schema *Collection)
 		}
 
 		// for each resulting record...
-		for _, record := range self.Records {
+		return self.Each(func(record *Record) error {
 			// make a new zero-valued instance of the slice type
 			elem := reflect.New(sliceType)
 
@@ -109,12 +272,12 @@ func (self *RecordSet) PopulateFromRecords(into interface{}, schema *Collection)
 				} else {
 					vInto.Set(reflect.Append(vInto, elem))
 				}
+
+				return nil
 			} else {
 				return err
 			}
-		}
-
-		return nil
+		})
 	case reflect.Struct:
 		if rs, ok := into.(*RecordSet); ok {
 			*rs = *self
@@ -124,3 +287,148 @@ func (self *RecordSet) PopulateFromRecords(into interface{}, schema *Collection)
 
 	return fmt.Errorf("RecordSet can only populate records into slice or array, got %T", into)
 }
+
+// Encodes the set as JSON, including any records that were spilled to disk.
+func (self *RecordSet) MarshalJSON() ([]byte, error) {
+	type recordSetAlias RecordSet
+	alias := recordSetAlias(*self)
+
+	if self.Spilled() == 0 {
+		return json.Marshal(&alias)
+	}
+
+	alias.Records = nil
+
+	if data, err := json.Marshal(&alias); err == nil {
+		var records bytes.Buffer
+
+		records.WriteString(`"records":[`)
+		i := 0
+
+		if err := self.Each(func(record *Record) error {
+			if i > 0 {
+				records.WriteByte(',')
+			}
+
+			i += 1
+
+			if rdata, err := json.Marshal(record); err == nil {
+				records.Write(rdata)
+				return nil
+			} else {
+				return err
+			}
+		}); err != nil {
+			return nil, err
+		}
+
+		records.WriteString(`]`)
+
+		return bytes.Replace(data, []byte(`"records":null`), records.Bytes(), 1), nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *RecordSet) spillRecord(record *Record) error {
+	if self.spill == nil {
+		self.spill = &recordSpill{}
+	}
+
+	spill := self.spill
+
+	if spill.file == nil {
+		if file, err := ioutil.TempFile(RecordSpillDirectory, `pivot-recordset-`); err == nil {
+			spill.file = file
+			spill.writer = bufio.NewWriter(file)
+			spill.encoder = json.NewEncoder(spill.writer)
+
+			// sets that are never released still clean up after themselves once they're collected
+			runtime.SetFinalizer(spill, (*recordSpill).remove)
+		} else {
+			return err
+		}
+	}
+
+	data := spilledRecord{
+		ID:       record.ID,
+		Fields:   record.Fields,
+		Data:     record.Data,
+		Score:    record.Score,
+		Revision: record.Revision,
+	}
+
+	if record.Error != nil {
+		data.Error = record.Error.Error()
+	}
+
+	if err := spill.encoder.Encode(&data); err == nil {
+		spill.count += 1
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *recordSpill) remove() {
+	if self.file != nil {
+		self.file.Close()
+		os.Remove(self.file.Name())
+
+		self.file = nil
+		self.writer = nil
+		self.encoder = nil
+		self.count = 0
+	}
+}
+
+func (self *recordSpill) record(data *spilledRecord) *Record {
+	record := NewRecord(spilledValue(data.ID))
+	record.Data = data.Data
+	record.Score = data.Score
+	record.Revision = data.Revision
+
+	if data.Error != `` {
+		record.Error = fmt.Errorf("%s", data.Error)
+	}
+
+	for key, value := range data.Fields {
+		value = spilledValue(value)
+
+		if self.collection != nil {
+			value = self.collection.ConvertValue(key, value)
+		}
+
+		record.Fields[key] = value
+	}
+
+	if self.collection != nil && record.ID != nil {
+		identity := self.collection.IdentityField
+
+		if identity == `` {
+			identity = DefaultIdentityField
+		}
+
+		record.ID = self.collection.ConvertValue(identity, record.ID)
+	}
+
+	return record
+}
+
+// converts the json.Numbers produced when decoding spilled records back into ints or floats
+func spilledValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		return stringutil.Autotype(v.String())
+	case []interface{}:
+		for i, item := range v {
+			v[i] = spilledValue(item)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = spilledValue(item)
+		}
+	}
+
+	return value
+}
@@ -1,6 +1,8 @@
 package dal
 
 import (
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -78,3 +80,91 @@ func TestRecordSet(t *testing.T) {
 	assert.Equal(0.3, dest[2].Factor)
 	assert.Equal(deftime, dest[2].CreatedAt)
 }
+
+func TestRecordSetRelease(t *testing.T) {
+	assert := require.New(t)
+
+	first := NewRecord(1).Set(`name`, `First`)
+	second := NewRecord(2).Set(`name`, `Second`)
+	recordset := NewRecordSet(first, second)
+
+	recordset.Release()
+	assert.Empty(recordset.Records)
+	assert.Equal(int64(0), recordset.ResultCount)
+
+	// released records come back empty
+	assert.Nil(first.ID)
+	assert.Empty(first.Fields)
+
+	record := NewRecord(3)
+	assert.Equal(3, record.ID)
+	assert.Nil(record.Get(`name`))
+	assert.NotNil(record.Fields)
+}
+
+func TestRecordSetSpill(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestRecordSetSpill`)
+	collection.IdentityFieldType = IntType
+	collection.AddFields(Field{
+		Name: `name`,
+		Type: StringType,
+	}, Field{
+		Name: `count`,
+		Type: IntType,
+	})
+
+	recordset := NewRecordSet().SetMemoryLimit(2, collection)
+
+	for i := 1; i <= 5; i++ {
+		recordset.Push(NewRecord(i).Set(`name`, fmt.Sprintf("record%d", i)).Set(`count`, i*10))
+	}
+
+	assert.Len(recordset.Records, 2)
+	assert.Equal(3, recordset.Spilled())
+	assert.Equal(5, recordset.Len())
+	assert.Equal(int64(5), recordset.ResultCount)
+
+	i := 0
+
+	assert.NoError(recordset.Each(func(record *Record) error {
+		i += 1
+
+		assert.EqualValues(i, record.ID)
+		assert.Equal(fmt.Sprintf("record%d", i), record.Get(`name`))
+		assert.EqualValues(i*10, record.Get(`count`))
+		return nil
+	}))
+
+	assert.Equal(5, i)
+	assert.Equal([]interface{}{`record1`, `record2`, `record3`, `record4`, `record5`}, recordset.Pluck(`name`))
+
+	record, ok := recordset.GetRecord(3)
+	assert.True(ok)
+	assert.EqualValues(4, record.ID)
+
+	data, err := json.Marshal(recordset)
+	assert.NoError(err)
+
+	var decoded map[string]interface{}
+	assert.NoError(json.Unmarshal(data, &decoded))
+	assert.Len(decoded[`records`], 5)
+
+	recordset.Release()
+	assert.Equal(0, recordset.Len())
+}
+
+func TestRecordSetSpillIsOptIn(t *testing.T) {
+	assert := require.New(t)
+
+	recordset := NewRecordSet().SetMemoryLimit(DefaultMaxInMemoryRecords, NewCollection(`TestRecordSetSpillIsOptIn`))
+
+	for i := 1; i <= 5; i++ {
+		recordset.Push(NewRecord(i))
+	}
+
+	// by default, every record stays in the Records slice
+	assert.Len(recordset.Records, 5)
+	assert.Zero(recordset.Spilled())
+}
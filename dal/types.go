@@ -20,12 +20,19 @@ const (
 	TimeType         = `time`
 	ObjectType       = `object`
 	RawType          = `raw`
+	PointType        = `point`
+	PolygonType      = `polygon`
 )
 
 func (self Type) String() string {
 	return string(self)
 }
 
+// Whether values of this type are geographic shapes (stored by SQL backends as native geometries).
+func (self Type) IsGeometry() bool {
+	return self == PointType || self == PolygonType
+}
+
 type FieldOperation int
 
 const (
@@ -58,6 +65,29 @@ const (
 	FieldPropertyIssue
 )
 
+func (self DeltaIssue) String() string {
+	switch self {
+	case CollectionNameIssue:
+		return `collection-name`
+	case CollectionKeyNameIssue:
+		return `key-name`
+	case CollectionKeyTypeIssue:
+		return `key-type`
+	case FieldMissingIssue:
+		return `field-missing`
+	case FieldNameIssue:
+		return `field-name`
+	case FieldLengthIssue:
+		return `field-length`
+	case FieldTypeIssue:
+		return `field-type`
+	case FieldPropertyIssue:
+		return `field-property`
+	default:
+		return `unknown`
+	}
+}
+
 type SchemaDelta struct {
 	Type       DeltaType
 	Issue      DeltaIssue
@@ -1,16 +1,23 @@
 package pivot
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/backends/benchtest"
+	"github.com/ghetzel/pivot/backends/conformancetest"
 	"github.com/ghetzel/pivot/dal"
 	"github.com/ghetzel/pivot/filter"
 	"github.com/stretchr/testify/require"
@@ -145,6 +152,30 @@ func setupTestTiedot(run func()) {
 	}
 }
 
+func setupTestBolt(run func()) {
+	if root, err := ioutil.TempDir(``, `pivot-backend-bolt-`); err == nil {
+		defer os.RemoveAll(root)
+
+		if b, err := makeBackend(fmt.Sprintf("bolt://%s/test.db", root)); err == nil {
+			backend = b
+			run()
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to create backend: %v\n", err)
+		}
+	} else {
+		panic(err.Error())
+	}
+}
+
+func setupTestMemory(run func()) {
+	if b, err := makeBackend(`memory://`); err == nil {
+		backend = b
+		run()
+	} else {
+		fmt.Fprintf(os.Stderr, "Failed to create backend: %v\n", err)
+	}
+}
+
 func setupTestMongo(run func()) {
 	if b, err := makeBackend(`mongodb://localhost/test`); err == nil {
 		backend = b
@@ -176,9 +207,82 @@ func TestMain(m *testing.M) {
 		setupTestFilesystemDefault(run)
 		setupTestFilesystemYaml(run)
 		setupTestFilesystemJson(run)
+		setupTestBolt(run)
+		setupTestMemory(run)
+	} else {
+		// without a backend to test against, only the tests that don't need one are run
+		os.Exit(m.Run())
 	}
 }
 
+// skips tests that run against the backend set up by TestMain when there isn't one
+func requireBackend(t *testing.T) {
+	if backend == nil {
+		t.Skip("no backend configured")
+	}
+}
+
+func BenchmarkBackend(b *testing.B) {
+	if backend == nil {
+		b.Skip("no backend configured")
+	}
+
+	benchtest.RunAll(b, backend)
+}
+
+func TestConformance(t *testing.T) {
+	requireBackend(t)
+
+	conformancetest.RunBackend(t, backend)
+}
+
+// runs the conformance suite against the backend whose connection string is in the named environment
+// variable, for backends that need a server that isn't available everywhere
+func runConformanceFromEnv(t *testing.T, name string) {
+	if conn := os.Getenv(name); conn != `` {
+		conformancetest.Run(t, conn)
+	} else {
+		t.Skipf("%s is not set", name)
+	}
+}
+
+func TestRedisConformance(t *testing.T) {
+	runConformanceFromEnv(t, `PIVOT_TEST_REDIS`)
+}
+
+func TestElasticsearchConformance(t *testing.T) {
+	runConformanceFromEnv(t, `PIVOT_TEST_ELASTICSEARCH`)
+}
+
+func TestEtcdConformance(t *testing.T) {
+	runConformanceFromEnv(t, `PIVOT_TEST_ETCD`)
+}
+
+func TestConsulConformance(t *testing.T) {
+	runConformanceFromEnv(t, `PIVOT_TEST_CONSUL`)
+}
+
+func TestCockroachConformance(t *testing.T) {
+	runConformanceFromEnv(t, `PIVOT_TEST_COCKROACH`)
+}
+
+func TestClickhouseConformance(t *testing.T) {
+	runConformanceFromEnv(t, `PIVOT_TEST_CLICKHOUSE`)
+}
+
+// DuckDB is embedded, so it can always be tested in memory
+func TestDuckdbConformance(t *testing.T) {
+	conformancetest.Run(t, `duckdb://memory`)
+}
+
+func TestFirestoreConformance(t *testing.T) {
+	runConformanceFromEnv(t, `PIVOT_TEST_FIRESTORE`)
+}
+
+func TestBigqueryConformance(t *testing.T) {
+	runConformanceFromEnv(t, `PIVOT_TEST_BIGQUERY`)
+}
+
 func makeBackend(conn string, options ...backends.ConnectOptions) (backends.Backend, error) {
 	if cs, err := dal.ParseConnectionString(conn); err == nil {
 		if backend, err := backends.MakeBackend(cs); err == nil {
@@ -196,6 +300,8 @@ func makeBackend(conn string, options ...backends.ConnectOptions) (backends.Back
 }
 
 func TestCollectionManagement(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 
 	err := backend.CreateCollection(dal.NewCollection(`TestCollectionManagement`))
@@ -214,6 +320,8 @@ func TestCollectionManagement(t *testing.T) {
 }
 
 func TestBasicCRUD(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 
 	err := backend.CreateCollection(
@@ -324,7 +432,419 @@ func TestBasicCRUD(t *testing.T) {
 	assert.Nil(backend.Delete(`TestBasicCRUD`, recordset.Records[1].ID))
 }
 
+func TestErrors(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+
+	err := backend.CreateCollection(
+		dal.NewCollection(`TestErrors`).
+			AddFields(dal.Field{
+				Name: `name`,
+				Type: dal.StringType,
+			}))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestErrors`))
+	}()
+
+	assert.Nil(err)
+
+	_, err = backend.Retrieve(`TestErrors`, `99`)
+	assert.True(errors.Is(err, dal.ErrRecordNotFound), "%v", err)
+
+	_, err = backend.GetCollection(`TestErrorsMissing`)
+	assert.True(errors.Is(err, dal.ErrCollectionNotFound), "%v", err)
+
+	// backends that generate their own IDs can't be made to collide
+	if testCrudIdSet[0] == nil {
+		return
+	}
+
+	assert.Nil(backend.Insert(`TestErrors`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`name`, `First`),
+	)))
+
+	err = backend.Insert(`TestErrors`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`name`, `Again`),
+	))
+
+	assert.True(errors.Is(err, dal.ErrUniqueViolation), "%v", err)
+}
+
+func TestNullValues(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+
+	err := backend.CreateCollection(
+		dal.NewCollection(`TestNullValues`).
+			AddFields(dal.Field{
+				Name: `name`,
+				Type: dal.StringType,
+			}, dal.Field{
+				Name: `count`,
+				Type: dal.IntType,
+			}))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestNullValues`))
+	}()
+
+	assert.Nil(err)
+
+	recordset := dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`name`, `zero`).Set(`count`, 0),
+		dal.NewRecord(testCrudIdSet[1]).Set(`name`, `null`).Set(`count`, nil),
+	)
+
+	assert.Nil(backend.Insert(`TestNullValues`, recordset))
+
+	record, err := backend.Retrieve(`TestNullValues`, recordset.Records[0].ID)
+	assert.Nil(err)
+	assert.False(record.IsNull(`count`))
+	assert.EqualValues(0, record.Get(`count`))
+
+	record, err = backend.Retrieve(`TestNullValues`, recordset.Records[1].ID)
+	assert.Nil(err)
+	assert.True(record.IsNull(`count`))
+
+	// explicitly set a value to null
+	assert.Nil(backend.Update(`TestNullValues`, dal.NewRecordSet(
+		dal.NewRecord(recordset.Records[0].ID).Set(`name`, `zero`).Set(`count`, nil),
+	)))
+
+	record, err = backend.Retrieve(`TestNullValues`, recordset.Records[0].ID)
+	assert.Nil(err)
+	assert.True(record.IsNull(`count`))
+	assert.Equal(`zero`, record.Get(`name`))
+}
+
+func TestBatch(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+
+	batcher, ok := backend.(backends.Batcher)
+
+	if !ok {
+		t.Skipf("backend %T does not support batches", backend)
+		return
+	}
+
+	assert.Nil(backend.CreateCollection(
+		dal.NewCollection(`TestBatch`).
+			AddFields(dal.Field{
+				Name: `name`,
+				Type: dal.StringType,
+			})))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestBatch`))
+	}()
+
+	assert.Nil(batcher.Batch([]backends.BatchOperation{
+		{
+			Type:       backends.BatchInsert,
+			Collection: `TestBatch`,
+			Records: []*dal.Record{
+				dal.NewRecord(1).Set(`name`, `First`),
+				dal.NewRecord(2).Set(`name`, `Second`),
+			},
+		}, {
+			Type:       backends.BatchUpdate,
+			Collection: `TestBatch`,
+			Records: []*dal.Record{
+				dal.NewRecord(2).Set(`name`, `Deuce`),
+			},
+		}, {
+			Type:       backends.BatchDelete,
+			Collection: `TestBatch`,
+			IDs:        []interface{}{1},
+		},
+	}))
+
+	assert.False(backend.Exists(`TestBatch`, 1))
+
+	record, err := backend.Retrieve(`TestBatch`, 2)
+	assert.Nil(err)
+	assert.Equal(`Deuce`, record.Get(`name`))
+
+	// a failing operation should roll back the entire batch
+	err = batcher.Batch([]backends.BatchOperation{
+		{
+			Type:       backends.BatchInsert,
+			Collection: `TestBatch`,
+			Records: []*dal.Record{
+				dal.NewRecord(3).Set(`name`, `Third`),
+			},
+		}, {
+			Type:       backends.BatchInsert,
+			Collection: `TestBatch`,
+			Records: []*dal.Record{
+				dal.NewRecord(2).Set(`name`, `Duplicate`),
+			},
+		},
+	})
+
+	assert.NotNil(err)
+	assert.IsType(&backends.BatchError{}, err)
+	assert.Equal(1, err.(*backends.BatchError).Index)
+	assert.False(backend.Exists(`TestBatch`, 3))
+}
+
+func TestBackupRestore(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+
+	assert.Nil(backend.CreateCollection(
+		dal.NewCollection(`TestBackupRestore`).
+			AddFields(dal.Field{
+				Name: `name`,
+				Type: dal.StringType,
+			})))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestBackupRestore`))
+	}()
+
+	assert.Nil(backend.Insert(`TestBackupRestore`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `First`),
+		dal.NewRecord(2).Set(`name`, `Second`),
+		dal.NewRecord(3).Set(`name`, `Third`))))
+
+	var buf bytes.Buffer
+
+	assert.Nil(Backup(backend, &buf, BackupOptions{
+		Collections: []string{`TestBackupRestore`},
+	}))
+
+	assert.Nil(backend.Delete(`TestBackupRestore`, 1, 2, 3))
+	assert.False(backend.Exists(`TestBackupRestore`, 2))
+
+	assert.Nil(Restore(backend, &buf, RestoreOptions{
+		BatchSize: 2,
+	}))
+
+	record, err := backend.Retrieve(`TestBackupRestore`, 2)
+	assert.Nil(err)
+	assert.Equal(`Second`, record.Get(`name`))
+	assert.True(backend.Exists(`TestBackupRestore`, 3))
+}
+
+func TestMigrations(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+
+	defer func() {
+		backend.DeleteCollection(MigrationsCollection)
+		backend.DeleteCollection(MigrationLockCollection)
+	}()
+
+	migrator := NewMigrator(backend, []*Migration{
+		{
+			Version: 1,
+			Name:    `create`,
+			Up: []MigrationStep{
+				{
+					Create: dal.NewCollection(`TestMigrations`).AddFields(dal.Field{
+						Name: `name`,
+						Type: dal.StringType,
+					}),
+				},
+			},
+			Down: []MigrationStep{
+				{Drop: `TestMigrations`},
+			},
+		}, {
+			Version: 2,
+			Name:    `populate`,
+			Up: []MigrationStep{
+				{
+					Insert: &MigrationRecords{
+						Collection: `TestMigrations`,
+						Records: []*dal.Record{
+							dal.NewRecord(1).Set(`name`, `First`),
+						},
+					},
+				},
+			},
+			Down: []MigrationStep{
+				{
+					Delete: &MigrationRecords{
+						Collection: `TestMigrations`,
+						IDs:        []interface{}{1},
+					},
+				},
+			},
+		},
+	})
+
+	assert.Nil(migrator.Lock())
+
+	// a second migrator cannot acquire the lock while it is held
+	other := NewMigrator(backend, migrator.Migrations)
+	other.Owner = `other`
+	assert.Error(other.Lock())
+
+	applied, err := migrator.Up(0)
+	assert.Nil(err)
+	assert.Len(applied, 2)
+	assert.True(backend.Exists(`TestMigrations`, 1))
+
+	statuses, err := migrator.Status()
+	assert.Nil(err)
+	assert.True(statuses[0].Applied)
+	assert.True(statuses[1].Applied)
+
+	reverted, err := migrator.Down(1)
+	assert.Nil(err)
+	assert.Len(reverted, 1)
+	assert.Equal(int64(2), reverted[0].Version)
+	assert.False(backend.Exists(`TestMigrations`, 1))
+
+	reverted, err = migrator.Down(0)
+	assert.Nil(err)
+	assert.Len(reverted, 1)
+
+	_, err = backend.GetCollection(`TestMigrations`)
+	assert.True(dal.IsCollectionNotFoundErr(err))
+
+	assert.Nil(migrator.Unlock())
+	assert.Nil(other.Lock())
+	assert.Nil(other.Unlock())
+}
+
+func TestExplain(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+
+	explainer, ok := backend.(backends.Explainer)
+
+	if !ok {
+		return
+	}
+
+	assert.Nil(backend.CreateCollection(
+		dal.NewCollection(`TestExplain`).
+			AddFields(dal.Field{
+				Name: `name`,
+				Type: dal.StringType,
+			})))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestExplain`))
+	}()
+
+	collection, err := backend.GetCollection(`TestExplain`)
+	assert.Nil(err)
+
+	plan, err := explainer.Explain(collection, filter.MustParse(`name/First`))
+	assert.Nil(err)
+	assert.Equal(`TestExplain`, plan.Collection)
+	assert.NotEmpty(plan.Query)
+	assert.NotEmpty(plan.Plan)
+}
+
+func TestParallelQuery(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+	c := dal.NewCollection(`TestParallelQuery`).
+		AddFields(dal.Field{
+			Name: `value`,
+			Type: dal.IntType,
+		})
+
+	search := backend.WithSearch(c)
+
+	if _, ok := search.(backends.Partitioner); !ok {
+		return
+	}
+
+	assert.Nil(backend.CreateCollection(c))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestParallelQuery`))
+	}()
+
+	rsSave := dal.NewRecordSet()
+
+	for i := 1; i <= 20; i++ {
+		rsSave.Push(dal.NewRecord(i).Set(`value`, i%7))
+	}
+
+	assert.Nil(backend.Insert(`TestParallelQuery`, rsSave))
+
+	f := filter.MustParse(`int:value/gt:0`)
+	f.Sort = []string{`-value`, `id`}
+	f.Offset = 2
+	f.Limit = 5
+	f.Parallelism = 4
+
+	recordset, err := search.Query(c, f)
+	assert.Nil(err)
+	assert.Len(recordset.Records, 5)
+
+	// 6, 13 have value 6 and 5, 12 have value 5; skipping two leaves 5, 12, 4, 11, 18
+	ids := make([]int64, 0)
+
+	for _, record := range recordset.Records {
+		id, err := stringutil.ConvertToInteger(record.ID)
+		assert.Nil(err)
+		ids = append(ids, id)
+	}
+
+	assert.Equal([]int64{5, 12, 4, 11, 18}, ids)
+}
+
+func TestSchemaCacheInvalidation(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+
+	invalidator, ok := backend.(backends.SchemaCacheInvalidator)
+
+	if !ok {
+		return
+	}
+
+	assert.Nil(backend.CreateCollection(
+		dal.NewCollection(`TestSchemaCacheInvalidation`).
+			AddFields(dal.Field{
+				Name: `name`,
+				Type: dal.StringType,
+			})))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestSchemaCacheInvalidation`))
+	}()
+
+	invalidator.InvalidateSchemaCache(`TestSchemaCacheInvalidation`)
+
+	assert.Nil(backend.Insert(`TestSchemaCacheInvalidation`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `first`),
+	)))
+
+	invalidator.InvalidateSchemaCache(``)
+
+	record, err := backend.Retrieve(`TestSchemaCacheInvalidation`, 1)
+	assert.Nil(err)
+	assert.Equal(`first`, record.Get(`name`))
+
+	collection, err := backend.GetCollection(`TestSchemaCacheInvalidation`)
+	assert.Nil(err)
+
+	_, ok = collection.GetField(`name`)
+	assert.True(ok)
+}
+
 func TestIdFormattersRandomId(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 
 	assert.Nil(backend.CreateCollection(
@@ -374,6 +894,8 @@ func TestIdFormattersRandomId(t *testing.T) {
 }
 
 func TestIdFormattersIdFromFieldValues(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 
 	assert.Nil(backend.CreateCollection(
@@ -430,6 +952,8 @@ func TestIdFormattersIdFromFieldValues(t *testing.T) {
 }
 
 func TestSearchQuery(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 	collection := dal.NewCollection(`TestSearchQuery`).
 		AddFields(dal.Field{
@@ -511,6 +1035,8 @@ func TestSearchQuery(t *testing.T) {
 }
 
 func TestSearchQueryPaginated(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 	collection := dal.NewCollection(`TestSearchQueryPaginated`)
 
@@ -553,6 +1079,8 @@ func TestSearchQueryPaginated(t *testing.T) {
 }
 
 func TestSearchQueryLimit(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 	backends.IndexerPageSize = 100
 	c := dal.NewCollection(`TestSearchQueryLimit`)
@@ -599,6 +1127,8 @@ func TestSearchQueryLimit(t *testing.T) {
 }
 
 func TestSearchQueryOffset(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 	backends.IndexerPageSize = 100
 	c := dal.NewCollection(`TestSearchQueryOffset`)
@@ -644,6 +1174,8 @@ func TestSearchQueryOffset(t *testing.T) {
 }
 
 func TestSearchQueryOffsetLimit(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 	c := dal.NewCollection(`TestSearchQueryOffsetLimit`)
 
@@ -695,7 +1227,65 @@ func TestSearchQueryOffsetLimit(t *testing.T) {
 	}
 }
 
+func TestSearchQueryStop(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+	c := dal.NewCollection(`TestSearchQueryStop`)
+
+	if search := backend.WithSearch(c); search != nil {
+		c.IdentityFieldType = dal.StringType
+		err := backend.CreateCollection(c)
+
+		defer func() {
+			assert.Nil(backend.DeleteCollection(`TestSearchQueryStop`))
+		}()
+
+		assert.Nil(err)
+
+		rsSave := dal.NewRecordSet()
+
+		for i := 0; i < 10; i++ {
+			rsSave.Push(dal.NewRecord(fmt.Sprintf("%02d", i)))
+		}
+
+		assert.Nil(backend.Insert(`TestSearchQueryStop`, rsSave))
+
+		var seen int
+
+		assert.NoError(search.QueryFunc(c, filter.All(), func(*dal.Record, error, backends.IndexPage) error {
+			seen += 1
+
+			if seen == 3 {
+				return backends.IndexResultsStop
+			}
+
+			return nil
+		}))
+
+		assert.Equal(3, seen)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		seen = 0
+
+		err = backends.QueryContext(ctx, search, c, filter.All(), func(*dal.Record, error, backends.IndexPage) error {
+			seen += 1
+
+			if seen == 2 {
+				cancel()
+			}
+
+			return nil
+		})
+
+		assert.Equal(context.Canceled, err)
+		assert.Equal(2, seen)
+	}
+}
+
 func TestListValues(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 	collection := dal.NewCollection(`TestListValues`).
 		AddFields(dal.Field{
@@ -764,7 +1354,53 @@ func TestListValues(t *testing.T) {
 	}
 }
 
+func TestFacets(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+	collection := dal.NewCollection(`TestFacets`).
+		AddFields(dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `group`,
+			Type: dal.StringType,
+		})
+
+	if search := backend.WithSearch(collection); search != nil {
+		err := backend.CreateCollection(collection)
+
+		defer func() {
+			assert.Nil(backend.DeleteCollection(`TestFacets`))
+		}()
+
+		assert.Nil(err)
+
+		assert.Nil(backend.Insert(`TestFacets`, dal.NewRecordSet(
+			dal.NewRecord(`1`).Set(`name`, `first`).Set(`group`, `reds`),
+			dal.NewRecord(`2`).Set(`name`, `second`).Set(`group`, `blues`),
+			dal.NewRecord(`3`).Set(`name`, `third`).Set(`group`, `reds`),
+		)))
+
+		facets, err := search.Facets(collection, []string{`group`}, filter.All())
+		assert.Nil(err)
+		assert.Equal([]backends.FacetBucket{
+			{Value: `reds`, Count: 2},
+			{Value: `blues`, Count: 1},
+		}, facets[`group`])
+
+		facets, err = search.Facets(collection, []string{`group`}, filter.MustParse(`name/not:first`))
+		assert.Nil(err)
+		assert.Equal([]backends.FacetBucket{
+			{Value: `blues`, Count: 1},
+			{Value: `reds`, Count: 1},
+		}, facets[`group`])
+	}
+}
+
 func TestSearchAnalysis(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 	collection := dal.NewCollection(`TestSearchAnalysis`).
 		AddFields(dal.Field{
@@ -815,7 +1451,56 @@ func TestSearchAnalysis(t *testing.T) {
 	}
 }
 
+func TestSearchLanguage(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+	collection := dal.NewCollection(`TestSearchLanguage`).
+		AddFields(dal.Field{
+			Name: `title`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name:          `exact`,
+			Type:          dal.StringType,
+			IndexAnalyzer: `keyword`,
+		})
+
+	collection.IndexLanguage = `english`
+
+	if search := backend.WithSearch(collection); search != nil {
+		if _, ok := search.(*backends.BleveIndexer); !ok {
+			return
+		}
+
+		err := backend.CreateCollection(collection)
+
+		defer func() {
+			assert.Nil(backend.DeleteCollection(`TestSearchLanguage`))
+		}()
+
+		assert.Nil(err)
+
+		assert.Nil(backend.Insert(`TestSearchLanguage`, dal.NewRecordSet(
+			dal.NewRecord(`1`).Set(`title`, `running`).Set(`exact`, `running`),
+			dal.NewRecord(`2`).Set(`title`, `runs`).Set(`exact`, `runs`),
+			dal.NewRecord(`3`).Set(`title`, `walked`).Set(`exact`, `walked`),
+		)))
+
+		// stemmed according to the collection's language
+		recordset, err := search.Query(collection, filter.MustParse(`title/is:run`))
+		assert.Nil(err)
+		assert.EqualValues(2, recordset.ResultCount)
+
+		// explicit analyzers take precedence over the language
+		recordset, err = search.Query(collection, filter.MustParse(`exact/is:run`))
+		assert.Nil(err)
+		assert.EqualValues(0, recordset.ResultCount)
+	}
+}
+
 func TestObjectType(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 
 	err := backend.CreateCollection(
@@ -865,7 +1550,52 @@ func TestObjectType(t *testing.T) {
 	assert.EqualValues(1, record.GetNested(`properties.count`))
 }
 
+func TestGeometryType(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+
+	err := backend.CreateCollection(
+		dal.NewCollection(`TestGeometryType`).
+			AddFields(dal.Field{
+				Name: `location`,
+				Type: dal.PointType,
+			}, dal.Field{
+				Name: `area`,
+				Type: dal.PolygonType,
+			}))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestGeometryType`))
+	}()
+
+	assert.Nil(err)
+
+	area := dal.Polygon{{40.68, -74.05}, {40.70, -74.05}, {40.70, -74.03}}
+
+	recordset := dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`location`, `40.6892,-74.0445`).Set(`area`, area))
+
+	assert.Nil(backend.Insert(`TestGeometryType`, recordset))
+
+	record, err := backend.Retrieve(`TestGeometryType`, recordset.Records[0].ID)
+	assert.NoError(err)
+
+	// backends without native geometries may return them in any form that can be parsed
+	location, err := dal.ParsePoint(record.Get(`location`))
+	assert.NoError(err)
+	assert.InDelta(40.6892, location.Latitude, 0.000001)
+	assert.InDelta(-74.0445, location.Longitude, 0.000001)
+
+	polygon, err := dal.ParsePolygon(record.Get(`area`))
+	assert.NoError(err)
+	assert.Len(polygon, 3)
+	assert.InDelta(40.70, polygon[1].Latitude, 0.000001)
+}
+
 func TestAggregators(t *testing.T) {
+	requireBackend(t)
+
 	assert := require.New(t)
 	collection := dal.NewCollection(`TestAggregators`).
 		AddFields(dal.Field{
@@ -929,3 +1659,285 @@ func TestAggregators(t *testing.T) {
 		assert.Equal(float64(9.8), vf)
 	}
 }
+
+func TestAggregateByTime(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+	collection := dal.NewCollection(`TestAggregateByTime`).
+		AddFields(dal.Field{
+			Name:     `inventory`,
+			Type:     dal.IntType,
+			Required: true,
+		}, dal.Field{
+			Name: `created_at`,
+			Type: dal.TimeType,
+		})
+
+	err := backend.CreateCollection(collection)
+
+	defer func() {
+		assert.NoError(backend.DeleteCollection(`TestAggregateByTime`))
+	}()
+
+	assert.NoError(err)
+
+	day := func(d int, h int) time.Time {
+		return time.Date(2020, time.January, d, h, 0, 0, 0, time.UTC)
+	}
+
+	assert.NoError(backend.Insert(`TestAggregateByTime`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`inventory`, 10).Set(`created_at`, day(6, 10)),
+		dal.NewRecord(2).Set(`inventory`, 20).Set(`created_at`, day(6, 15)),
+		dal.NewRecord(3).Set(`inventory`, 5).Set(`created_at`, day(8, 9)),
+	)))
+
+	buckets, err := backends.AggregateByTime(backend, collection, `created_at`, backends.Daily, filter.Aggregate{
+		Aggregation: filter.Count,
+	})
+
+	if errors.Is(err, dal.ErrUnsupported) {
+		return
+	}
+
+	assert.NoError(err)
+	assert.Equal([]backends.TimeBucket{
+		{Time: day(6, 0), Count: 2, Value: 2},
+		{Time: day(7, 0)},
+		{Time: day(8, 0), Count: 1, Value: 1},
+	}, buckets)
+
+	buckets, err = backends.AggregateByTime(backend, collection, `created_at`, backends.Weekly, filter.Aggregate{
+		Aggregation: filter.Sum,
+		Field:       `inventory`,
+	})
+
+	assert.NoError(err)
+	assert.Equal([]backends.TimeBucket{
+		{Time: day(6, 0), Count: 3, Value: 35},
+	}, buckets)
+
+	buckets, err = backends.AggregateByTime(backend, collection, `created_at`, backends.Daily, filter.Aggregate{
+		Aggregation: filter.Maximum,
+		Field:       `inventory`,
+	}, filter.MustParse(`int:inventory/gt:5`))
+
+	assert.NoError(err)
+	assert.Equal([]backends.TimeBucket{
+		{Time: day(6, 0), Count: 2, Value: 20},
+	}, buckets)
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+	collection := dal.NewCollection(`TestTemplateFuncs`).
+		AddFields(dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		})
+
+	if search := backend.WithSearch(collection); search != nil {
+		err := backend.CreateCollection(collection)
+
+		defer func() {
+			assert.Nil(backend.DeleteCollection(`TestTemplateFuncs`))
+		}()
+
+		assert.Nil(err)
+
+		assert.Nil(backend.Insert(`TestTemplateFuncs`, dal.NewRecordSet(
+			dal.NewRecord(testCrudIdSet[0]).Set(`name`, `First`),
+			dal.NewRecord(testCrudIdSet[1]).Set(`name`, `Second`),
+			dal.NewRecord(testCrudIdSet[2]).Set(`name`, `Third`))))
+
+		tmpl, err := template.New(`test`).Funcs(TemplateFuncs(backend)).Parse(
+			`{{ range query "TestTemplateFuncs" "+name/suffix:d" }}{{ .Get "name" }},{{ end }}` +
+				`{{ (retrieve "TestTemplateFuncs" 1).Get "name" }},` +
+				`{{ count "TestTemplateFuncs" }},` +
+				`{{ count "TestTemplateFuncs" "name/First" }}`,
+		)
+
+		assert.NoError(err)
+
+		var out bytes.Buffer
+		assert.NoError(tmpl.Execute(&out, nil))
+		assert.Equal(`Second,Third,First,3,1`, out.String())
+	}
+}
+
+func TestSqlBackendFromDB(t *testing.T) {
+	assert := require.New(t)
+
+	db, err := sql.Open(`sqlite3`, `:memory:`)
+	assert.NoError(err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	wrapped, err := backends.NewSqlBackendFromDB(db, `sqlite`)
+	assert.NoError(err)
+	assert.NoError(wrapped.Initialize())
+
+	assert.NoError(wrapped.CreateCollection(dal.NewCollection(`TestSqlBackendFromDB`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(wrapped.Insert(`TestSqlBackendFromDB`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `First`),
+	)))
+
+	// the record was written using the pool we gave it
+	var name string
+	assert.NoError(db.QueryRow(`SELECT name FROM TestSqlBackendFromDB WHERE id = 1`).Scan(&name))
+	assert.Equal(`First`, name)
+}
+
+func TestSqlBackendMigrate(t *testing.T) {
+	assert := require.New(t)
+
+	db, err := sql.Open(`sqlite3`, `:memory:`)
+	assert.NoError(err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	wrapped, err := backends.NewSqlBackendFromDB(db, `sqlite`)
+	assert.NoError(err)
+	assert.NoError(wrapped.Initialize())
+
+	collection := dal.NewCollection(`TestSqlBackendMigrate`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	assert.NoError(wrapped.CreateCollection(collection))
+
+	columns := func() []string {
+		var names []string
+
+		rows, err := db.Query(`SELECT name FROM pragma_table_info('TestSqlBackendMigrate')`)
+		assert.NoError(err)
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			assert.NoError(rows.Scan(&name))
+			names = append(names, name)
+		}
+
+		return names
+	}
+
+	definition := collection.Copy()
+	definition.AddFields(dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name:     `email`,
+		Type:     dal.StringType,
+		Required: true,
+	})
+
+	migratable := wrapped.(backends.Migratable)
+	wrapped.RegisterCollection(definition)
+
+	// deltas that can't be applied are found before any column is added
+	err = migratable.Migrate([]dal.SchemaDelta{
+		{Issue: dal.FieldMissingIssue, Collection: `TestSqlBackendMigrate`, Name: `age`},
+		{Issue: dal.FieldTypeIssue, Collection: `TestSqlBackendMigrate`, Name: `name`},
+	})
+
+	assert.Error(err)
+	assert.Equal([]string{`id`, `name`}, columns())
+
+	// SQLite can't add a NOT NULL column without a default, so only the first column is added
+	err = migratable.Migrate([]dal.SchemaDelta{
+		{Issue: dal.FieldMissingIssue, Collection: `TestSqlBackendMigrate`, Name: `age`},
+		{Issue: dal.FieldMissingIssue, Collection: `TestSqlBackendMigrate`, Name: `email`},
+	})
+
+	var migrationErr *backends.MigrationError
+
+	assert.True(errors.As(err, &migrationErr))
+	assert.Equal([]string{`age`}, migrationErr.Added)
+	assert.Equal([]string{`id`, `name`, `age`}, columns())
+}
+
+type testScopeOwner struct{}
+
+func TestScopedBackend(t *testing.T) {
+	requireBackend(t)
+
+	assert := require.New(t)
+	collection := dal.NewCollection(`TestScopedBackend`).
+		AddFields(dal.Field{
+			Name: `owner`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		})
+
+	if search := backend.WithSearch(collection); search != nil {
+		err := backend.CreateCollection(collection)
+
+		defer func() {
+			assert.Nil(backend.DeleteCollection(`TestScopedBackend`))
+		}()
+
+		assert.Nil(err)
+
+		assert.Nil(backend.Insert(`TestScopedBackend`, dal.NewRecordSet(
+			dal.NewRecord(testCrudIdSet[0]).Set(`owner`, `alice`).Set(`name`, `First`),
+			dal.NewRecord(testCrudIdSet[1]).Set(`owner`, `bob`).Set(`name`, `Second`),
+			dal.NewRecord(testCrudIdSet[2]).Set(`owner`, `alice`).Set(`name`, `Third`))))
+
+		alice := backends.NewScopedBackend(context.WithValue(context.Background(), testScopeOwner{}, `alice`), backend, nil)
+		alice.SetScope(`TestScopedBackend`, func(ctx context.Context, _ *dal.Collection) (*filter.Filter, error) {
+			return filter.Parse(fmt.Sprintf("owner/%v", ctx.Value(testScopeOwner{})))
+		})
+
+		bob := alice.WithContext(context.WithValue(context.Background(), testScopeOwner{}, `bob`))
+
+		// queries only see records in scope
+		recordset, err := alice.WithSearch(collection).Query(collection, filter.All())
+		assert.NoError(err)
+		assert.EqualValues(2, recordset.ResultCount)
+
+		recordset, err = bob.WithSearch(collection).Query(collection, filter.MustParse(`name/First`))
+		assert.NoError(err)
+		assert.EqualValues(0, recordset.ResultCount)
+
+		// records out of scope don't exist
+		_, err = bob.Retrieve(`TestScopedBackend`, testCrudIdSet[0])
+		assert.True(dal.IsNotExistError(err))
+		assert.False(bob.Exists(`TestScopedBackend`, testCrudIdSet[0]))
+		assert.True(bob.Exists(`TestScopedBackend`, testCrudIdSet[1]))
+		assert.True(dal.IsNotExistError(bob.Delete(`TestScopedBackend`, testCrudIdSet[0])))
+
+		// records can't be written or moved out of scope
+		err = bob.Insert(`TestScopedBackend`, dal.NewRecordSet(dal.NewRecord(`4`).Set(`owner`, `alice`)))
+		assert.True(errors.Is(err, dal.ErrPermissionDenied))
+
+		err = alice.Update(`TestScopedBackend`, dal.NewRecordSet(dal.NewRecord(testCrudIdSet[0]).Set(`owner`, `bob`)))
+		assert.True(errors.Is(err, dal.ErrPermissionDenied))
+
+		assert.NoError(alice.Update(`TestScopedBackend`, dal.NewRecordSet(dal.NewRecord(testCrudIdSet[0]).Set(`name`, `Uno`))))
+
+		record, err := backend.Retrieve(`TestScopedBackend`, testCrudIdSet[0])
+		assert.NoError(err)
+		assert.Equal(`Uno`, record.Get(`name`))
+
+		// aggregates only see records in scope
+		if aggregator := bob.WithAggregator(collection); aggregator != nil {
+			count, err := aggregator.Count(collection)
+			assert.NoError(err)
+			assert.EqualValues(1, count)
+		}
+
+		assert.True(errors.Is(alice.DeleteCollection(`TestScopedBackend`), dal.ErrPermissionDenied))
+	}
+}
@@ -0,0 +1,142 @@
+package pivot
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/httputil"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/husobee/vestigo"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	ContentTypeJSON    = `application/json`
+	ContentTypeMsgPack = `application/msgpack`
+	ContentTypeCBOR    = `application/cbor`
+)
+
+var msgpackHandle = &codec.MsgpackHandle{}
+var cborHandle = &codec.CborHandle{}
+
+func init() {
+	mapType := reflect.TypeOf(map[string]interface{}(nil))
+
+	msgpackHandle.MapType = mapType
+	msgpackHandle.RawToString = true
+	cborHandle.MapType = mapType
+}
+
+// Returns the codec handle that should be used for the given MIME type, or nil if the type
+// should be handled as JSON.
+func codecForContentType(contentType string) (codec.Handle, string) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case ContentTypeMsgPack, `application/x-msgpack`:
+			return msgpackHandle, ContentTypeMsgPack
+		case ContentTypeCBOR:
+			return cborHandle, ContentTypeCBOR
+		}
+	}
+
+	return nil, ContentTypeJSON
+}
+
+// Selects the best supported response encoding from the request's Accept header.
+func negotiateResponseCodec(req *http.Request) (codec.Handle, string) {
+	for _, accept := range strings.Split(req.Header.Get(`Accept`), `,`) {
+		if handle, contentType := codecForContentType(strings.TrimSpace(accept)); handle != nil {
+			return handle, contentType
+		}
+	}
+
+	return nil, ContentTypeJSON
+}
+
+// Writes the given data to the response, encoded according to what the client indicated it
+// accepts.  Responses are JSON-encoded unless MsgPack or CBOR are explicitly requested.  Records are
+// masked (see maskResponse) as they are written.
+func respond(w http.ResponseWriter, req *http.Request, data interface{}, code ...int) {
+	data = maskResponse(req, data)
+
+	if handle, contentType := negotiateResponseCodec(req); handle != nil {
+		status := http.StatusOK
+
+		switch data.(type) {
+		case error:
+			status = http.StatusInternalServerError
+			data = map[string]interface{}{
+				`error`: data.(error).Error(),
+			}
+		case []error:
+			status = http.StatusInternalServerError
+			errs := make([]string, 0)
+
+			for _, err := range data.([]error) {
+				errs = append(errs, err.Error())
+			}
+
+			data = map[string]interface{}{
+				`errors`: errs,
+			}
+		}
+
+		if len(code) > 0 {
+			status = code[0]
+		}
+
+		w.Header().Set(`Content-Type`, contentType)
+		w.WriteHeader(status)
+
+		if data != nil {
+			if err := codec.NewEncoder(w, handle).Encode(data); err != nil {
+				log.Warningf("Failed to encode %s response: %v", contentType, err)
+			}
+		}
+	} else {
+		httputil.RespondJSON(w, data, code...)
+	}
+}
+
+// Applies the hidden and redacted field rules of the requested collection to records being returned
+// to the client, so that no handler can forget to.  Records are copied rather than masked in place,
+// since they may be shared with a backend's cache.
+func maskResponse(req *http.Request, data interface{}) interface{} {
+	switch data.(type) {
+	case *dal.Record, *dal.RecordSet:
+		break
+	default:
+		return data
+	}
+
+	if backend := requestBackend(req); backend != nil {
+		if name := vestigo.Param(req, `collection`); name != `` {
+			if collection, err := backend.GetCollection(name); err == nil {
+				switch data.(type) {
+				case *dal.Record:
+					return collection.MaskRecord(data.(*dal.Record))
+				case *dal.RecordSet:
+					return collection.MaskRecordSet(data.(*dal.RecordSet))
+				}
+			}
+		}
+	}
+
+	return data
+}
+
+// Decodes the request body into the given value, honoring MsgPack and CBOR content types.
+func parseRequest(req *http.Request, into interface{}) error {
+	if handle, contentType := codecForContentType(req.Header.Get(`Content-Type`)); handle != nil {
+		if err := codec.NewDecoder(req.Body, handle).Decode(into); err != nil {
+			return fmt.Errorf("invalid %s request body: %v", contentType, err)
+		}
+
+		return nil
+	}
+
+	return httputil.ParseRequest(req, into)
+}
@@ -0,0 +1,144 @@
+package pivot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+func TestNegotiateResponseCodec(t *testing.T) {
+	assert := require.New(t)
+
+	for accept, expected := range map[string]string{
+		``:                                      ContentTypeJSON,
+		`*/*`:                                   ContentTypeJSON,
+		`application/json`:                      ContentTypeJSON,
+		`application/msgpack`:                   ContentTypeMsgPack,
+		`application/x-msgpack`:                 ContentTypeMsgPack,
+		`application/cbor`:                      ContentTypeCBOR,
+		`text/html, application/cbor;q=0.9`:     ContentTypeCBOR,
+		`application/json, application/cbor`:    ContentTypeCBOR,
+		`application/msgpack, application/json`: ContentTypeMsgPack,
+	} {
+		req := httptest.NewRequest(`GET`, `/`, nil)
+		req.Header.Set(`Accept`, accept)
+
+		_, contentType := negotiateResponseCodec(req)
+		assert.Equal(expected, contentType, accept)
+	}
+}
+
+func TestRespondEncodings(t *testing.T) {
+	assert := require.New(t)
+
+	for contentType, handle := range map[string]codec.Handle{
+		ContentTypeMsgPack: msgpackHandle,
+		ContentTypeCBOR:    cborHandle,
+	} {
+		req := httptest.NewRequest(`GET`, `/`, nil)
+		req.Header.Set(`Accept`, contentType)
+
+		// values
+		w := httptest.NewRecorder()
+		respond(w, req, map[string]interface{}{`name`: `tester`})
+
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Equal(contentType, w.Header().Get(`Content-Type`))
+
+		var out map[string]interface{}
+		assert.NoError(codec.NewDecoder(w.Body, handle).Decode(&out))
+		assert.Equal(`tester`, out[`name`])
+
+		// errors
+		w = httptest.NewRecorder()
+		respond(w, req, fmt.Errorf("broken"), http.StatusBadRequest)
+
+		assert.Equal(http.StatusBadRequest, w.Code)
+
+		out = nil
+		assert.NoError(codec.NewDecoder(w.Body, handle).Decode(&out))
+		assert.Equal(`broken`, out[`error`])
+	}
+}
+
+func TestParseRequestEncodings(t *testing.T) {
+	assert := require.New(t)
+
+	for contentType, handle := range map[string]codec.Handle{
+		ContentTypeMsgPack: msgpackHandle,
+		ContentTypeCBOR:    cborHandle,
+	} {
+		var body bytes.Buffer
+
+		assert.NoError(codec.NewEncoder(&body, handle).Encode(map[string]interface{}{
+			`id`: `1`,
+			`fields`: map[string]interface{}{
+				`name`: `tester`,
+			},
+		}))
+
+		req := httptest.NewRequest(`POST`, `/`, &body)
+		req.Header.Set(`Content-Type`, contentType)
+
+		var record dal.Record
+		assert.NoError(parseRequest(req, &record), contentType)
+		assert.Equal(`1`, record.ID)
+		assert.Equal(`tester`, record.Get(`name`))
+
+		// malformed bodies are rejected
+		req = httptest.NewRequest(`POST`, `/`, bytes.NewBufferString("\xc1\xc1\xc1"))
+		req.Header.Set(`Content-Type`, contentType)
+		assert.Error(parseRequest(req, &record), contentType)
+	}
+
+	// everything else is JSON
+	req := httptest.NewRequest(`POST`, `/`, bytes.NewBufferString(`{"id":"2"}`))
+	req.Header.Set(`Content-Type`, `application/json`)
+
+	var record dal.Record
+	assert.NoError(parseRequest(req, &record))
+	assert.Equal(`2`, record.ID)
+}
+
+func TestEncodedRecordRoundTrip(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+
+	var body bytes.Buffer
+	assert.NoError(codec.NewEncoder(&body, msgpackHandle).Encode(map[string]interface{}{
+		`fields`: map[string]interface{}{
+			`name`: `tester`,
+		},
+	}))
+
+	req := httptest.NewRequest(`POST`, `/api/collections/users/records/1`, &body)
+	req.Header.Set(`Content-Type`, ContentTypeMsgPack)
+	req.Header.Set(`Accept`, ContentTypeCBOR)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(ContentTypeCBOR, w.Header().Get(`Content-Type`))
+
+	var out map[string]interface{}
+	assert.NoError(codec.NewDecoder(w.Body, cborHandle).Decode(&out))
+	assert.Equal(`1`, out[`id`])
+
+	// and it can be read back as JSON
+	w = testRequest(handler, `GET`, `/api/collections/users/records/1`, ``)
+	assert.Equal(http.StatusOK, w.Code)
+
+	out = nil
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &out))
+	assert.Equal(`tester`, out[`fields`].(map[string]interface{})[`name`])
+}
@@ -0,0 +1,208 @@
+package filter
+
+import (
+	"github.com/ghetzel/pivot/dal"
+)
+
+// Builds a Filter from Go code, e.g.:
+//
+//	f := filter.Where(`age`).Gte(21).And(`status`).In(`active`, `pending`).SortDesc(`created_at`).Limit(50).Filter()
+//
+// Each of Where and And names the field that the next comparison applies to; all comparisons must
+// match for a record to match the filter.
+type Builder struct {
+	filter *Filter
+	field  string
+	fType  dal.Type
+}
+
+// Starts a new filter whose first criterion applies to the given field.
+func Where(field string) *Builder {
+	return &Builder{
+		filter: New(),
+		field:  field,
+	}
+}
+
+// Starts a new filter that matches all records, to which sorting, limits, and so on can be applied.
+func Everything() *Builder {
+	builder := &Builder{
+		filter: New(),
+	}
+
+	builder.filter.MatchAll = true
+
+	return builder
+}
+
+// Names the field that the next comparison applies to.
+func (self *Builder) And(field string) *Builder {
+	self.field = field
+	self.fType = ``
+	return self
+}
+
+// Sets the type the next comparison's values should be compared as.
+func (self *Builder) As(fType dal.Type) *Builder {
+	self.fType = fType
+	return self
+}
+
+// Matches records whose field is equal to any of the given values.
+func (self *Builder) Is(values ...interface{}) *Builder {
+	return self.add(``, values...)
+}
+
+// Matches records whose field is equal to any of the given values.  This is the same as Is.
+func (self *Builder) In(values ...interface{}) *Builder {
+	return self.add(``, values...)
+}
+
+// Matches records whose field is not equal to any of the given values.
+func (self *Builder) Not(values ...interface{}) *Builder {
+	return self.add(`not`, values...)
+}
+
+// Matches records whose field is empty.
+func (self *Builder) IsNull() *Builder {
+	return self.add(``, `null`)
+}
+
+// Matches records whose field is not empty.
+func (self *Builder) IsNotNull() *Builder {
+	return self.add(`not`, `null`)
+}
+
+func (self *Builder) Gt(value interface{}) *Builder {
+	return self.add(`gt`, value)
+}
+
+func (self *Builder) Gte(value interface{}) *Builder {
+	return self.add(`gte`, value)
+}
+
+func (self *Builder) Lt(value interface{}) *Builder {
+	return self.add(`lt`, value)
+}
+
+func (self *Builder) Lte(value interface{}) *Builder {
+	return self.add(`lte`, value)
+}
+
+// Matches records whose field is between min and max (inclusive).
+func (self *Builder) Between(min interface{}, max interface{}) *Builder {
+	return self.Gte(min).Lte(max)
+}
+
+// Matches records whose field is equal to any of the given values, ignoring case and punctuation.
+func (self *Builder) Like(values ...interface{}) *Builder {
+	return self.add(`like`, values...)
+}
+
+// Matches records whose field is not equal to any of the given values, ignoring case and
+// punctuation.
+func (self *Builder) Unlike(values ...interface{}) *Builder {
+	return self.add(`unlike`, values...)
+}
+
+func (self *Builder) Prefix(values ...interface{}) *Builder {
+	return self.add(`prefix`, values...)
+}
+
+func (self *Builder) Suffix(values ...interface{}) *Builder {
+	return self.add(`suffix`, values...)
+}
+
+func (self *Builder) Contains(values ...interface{}) *Builder {
+	return self.add(`contains`, values...)
+}
+
+// Matches records whose field contains all of the words in any of the given values.  Backends with a
+// search index use it to perform the search.
+func (self *Builder) Match(values ...interface{}) *Builder {
+	return self.add(`match`, values...)
+}
+
+// Matches records whose field is within the given edit distance of any of the given values.  A
+// distance of zero uses DefaultFuzziness.
+func (self *Builder) Fuzzy(distance int, values ...interface{}) *Builder {
+	self.add(`fuzzy`, values...)
+
+	if n := len(self.filter.Criteria); n > 0 {
+		self.filter.Criteria[n-1].Fuzziness = distance
+	}
+
+	return self
+}
+
+// Sorts results by the given fields in ascending order, after any sorting already given.
+func (self *Builder) SortAsc(fields ...string) *Builder {
+	self.filter.Sort = append(self.filter.Sort, fields...)
+	return self
+}
+
+// Sorts results by the given fields in descending order, after any sorting already given.
+func (self *Builder) SortDesc(fields ...string) *Builder {
+	for _, field := range fields {
+		self.filter.Sort = append(self.filter.Sort, SortDescending+field)
+	}
+
+	return self
+}
+
+func (self *Builder) Limit(limit int) *Builder {
+	self.filter.Limit = limit
+	return self
+}
+
+func (self *Builder) Offset(offset int) *Builder {
+	self.filter.Offset = offset
+	return self
+}
+
+// Restricts the fields returned in each result.
+func (self *Builder) Fields(fields ...string) *Builder {
+	self.filter.Fields = append(self.filter.Fields, fields...)
+	return self
+}
+
+// Sets a backend-specific filter option.
+func (self *Builder) Option(key string, value interface{}) *Builder {
+	self.filter.Options[key] = value
+	return self
+}
+
+// Returns the filter that has been built.  The builder should not be used afterwards.
+func (self *Builder) Filter() *Filter {
+	if len(self.filter.Criteria) == 0 {
+		self.filter.MatchAll = true
+		self.filter.Spec = AllValue
+	} else {
+		self.filter.MatchAll = false
+		self.filter.Spec = self.filter.String()
+	}
+
+	return self.filter
+}
+
+// Returns the filter that has been built in its string form (as understood by Parse).
+func (self *Builder) String() string {
+	return self.Filter().String()
+}
+
+func (self *Builder) add(operator string, values ...interface{}) *Builder {
+	fType := self.fType
+
+	if fType == `` {
+		fType = dal.AutoType
+	}
+
+	self.filter.AddCriteria(Criterion{
+		Type:     fType,
+		Field:    self.field,
+		Operator: operator,
+		Values:   values,
+	})
+
+	return self
+}
@@ -0,0 +1,69 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	assert := require.New(t)
+
+	f := Where(`age`).Gte(21).And(`status`).In(`a`, `b`).SortDesc(`created_at`).Limit(50).Filter()
+
+	assert.False(f.MatchAll)
+	assert.Len(f.Criteria, 2)
+
+	assert.Equal(`age`, f.Criteria[0].Field)
+	assert.Equal(`gte`, f.Criteria[0].Operator)
+	assert.Equal([]interface{}{21}, f.Criteria[0].Values)
+
+	assert.Equal(`status`, f.Criteria[1].Field)
+	assert.Equal(``, f.Criteria[1].Operator)
+	assert.Equal([]interface{}{`a`, `b`}, f.Criteria[1].Values)
+
+	assert.Equal([]string{`-created_at`}, f.Sort)
+	assert.Equal(50, f.Limit)
+	assert.Equal(f.String(), f.Spec)
+
+	// the built filter is equivalent to its parsed form
+	parsed, err := Parse(f.String())
+	assert.NoError(err)
+	assert.Equal(f.String(), parsed.String())
+
+	typed := Where(`count`).As(dal.IntType).Between(1, 5).And(`name`).Fuzzy(2, `bob`).Filter()
+	assert.Equal(dal.IntType, typed.Criteria[0].Type)
+	assert.Equal(dal.IntType, typed.Criteria[1].Type)
+	assert.Equal(`lte`, typed.Criteria[1].Operator)
+	assert.Equal(dal.AutoType, typed.Criteria[2].Type)
+	assert.Equal(2, typed.Criteria[2].Fuzziness)
+}
+
+func TestBuilderMatchesRecord(t *testing.T) {
+	assert := require.New(t)
+
+	f := Where(`name`).Prefix(`fi`).And(`count`).IsNotNull().Filter()
+
+	assert.True(f.MatchesRecord(dal.NewRecord(1).Set(`name`, `First`).Set(`count`, 1)))
+	assert.False(f.MatchesRecord(dal.NewRecord(2).Set(`name`, `First`)))
+	assert.False(f.MatchesRecord(dal.NewRecord(3).Set(`name`, `Second`).Set(`count`, 1)))
+
+	text := Where(`body`).Match(`quick fox`).Filter()
+	assert.True(text.HasFullText())
+	assert.Equal(`body/match:quick fox`, text.String())
+	assert.True(text.MatchesRecord(dal.NewRecord(4).Set(`body`, `The quick brown fox`)))
+}
+
+func TestBuilderEverything(t *testing.T) {
+	assert := require.New(t)
+
+	f := Everything().SortAsc(`name`).Offset(10).Limit(5).Fields(`name`).Filter()
+
+	assert.True(f.IsMatchAll())
+	assert.Equal(AllValue, f.String())
+	assert.Equal([]string{`name`}, f.Sort)
+	assert.Equal(10, f.Offset)
+	assert.Equal(5, f.Limit)
+	assert.Equal([]string{`name`}, f.Fields)
+}
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fatih/structs"
 	"github.com/ghetzel/go-stockutil/sliceutil"
@@ -23,7 +24,10 @@ var AllValue = `all`
 var SortAscending = `+`
 var SortDescending = `-`
 var DefaultIdentityField = `id`
+var RelevanceField = `_score`
+var DefaultFuzziness = 1
 var rxCharFilter = regexp.MustCompile(`[\W\s\_]+`)
+var rxWordSeparator = regexp.MustCompile(`[^\pL\pN]+`)
 
 type NormalizerFunc func(in string) string // {}
 
@@ -39,6 +43,7 @@ type Criterion struct {
 	Operator    string        `json:"operator,omitempty"`
 	Values      []interface{} `json:"values"`
 	Aggregation Aggregation   `json:"aggregation,omitempty"`
+	Fuzziness   int           `json:"fuzziness,omitempty"`
 }
 
 type SortBy struct {
@@ -77,7 +82,11 @@ func (self *Criterion) String() string {
 	rv += self.Field + FieldTermSeparator
 
 	if self.Operator != `` {
-		rv += self.Operator + ModifierDelimiter
+		if self.Fuzziness > 0 {
+			rv += fmt.Sprintf("%s%s%d%s", self.Operator, FieldLengthDelimiter, self.Fuzziness, ModifierDelimiter)
+		} else {
+			rv += self.Operator + ModifierDelimiter
+		}
 	}
 
 	values := make([]string, 0)
@@ -97,6 +106,16 @@ func (self *Criterion) String() string {
 	return rv
 }
 
+// Returns the maximum edit distance a value may be from this criterion's values and still match
+// when using the "fuzzy" operator.
+func (self *Criterion) GetFuzziness() int {
+	if self.Fuzziness > 0 {
+		return self.Fuzziness
+	}
+
+	return DefaultFuzziness
+}
+
 type Filter struct {
 	Spec          string
 	MatchAll      bool
@@ -109,6 +128,10 @@ type Filter struct {
 	Paginate      bool
 	IdentityField string
 	Normalizer    NormalizerFunc
+
+	// The maximum number of sub-queries to run concurrently for backends that can split a query
+	// into partitions.  Values less than 2 disable parallel execution.
+	Parallelism int
 }
 
 func New() *Filter {
@@ -190,7 +213,7 @@ func All() *Filter {
 // field      ::= ? US-ASCII field name ?;
 // value      ::= ? UTF-8 field value ?;
 // type       ::= str | bool | int | float | date
-// comparator :=  is | not | gt | gte | lt | lte | prefix | suffix | regex
+// comparator :=  is | not | gt | gte | lt | lte | prefix | suffix | regex | fuzzy[#distance] | match | search
 //
 func Parse(spec string) (*Filter, error) {
 	var criterion Criterion
@@ -277,6 +300,16 @@ func Parse(spec string) (*Filter, error) {
 				criterion.Values = make([]interface{}, 0)
 
 				if vOper != `` {
+					// operators may specify an edit distance (e.g.: "fuzzy#2:value")
+					if opDistPair := strings.SplitN(vOper, FieldLengthDelimiter, 2); len(opDistPair) == 2 {
+						if v, err := strconv.ParseUint(opDistPair[1], 10, 32); err == nil {
+							vOper = opDistPair[0]
+							criterion.Fuzziness = int(v)
+						} else {
+							return rv, err
+						}
+					}
+
 					criterion.Operator = vOper
 				}
 
@@ -345,6 +378,76 @@ func (self *Filter) BoundedBy(limit int, offset int) *Filter {
 	return self
 }
 
+// Splits the filter into at most n filters that each match a contiguous, non-overlapping range of
+// integer values of the given field between min and max (inclusive).  Together, the returned
+// filters match the same records as the original.  Offset and limit are not applied to the
+// returned filters, since the records on a given page may come from any of them.
+func (self *Filter) SplitRange(field string, min int64, max int64, n int) []*Filter {
+	if n < 2 || max <= min {
+		return []*Filter{self}
+	}
+
+	span := max - min + 1
+
+	if int64(n) > span {
+		n = int(span)
+	}
+
+	step := span / int64(n)
+
+	if span%int64(n) != 0 {
+		step += 1
+	}
+
+	filters := make([]*Filter, 0, n)
+
+	for lower := min; lower <= max; lower += step {
+		upper := lower + step
+
+		part := Copy(self)
+		part.Spec = ``
+		part.MatchAll = false
+		part.Offset = 0
+		part.Limit = 0
+		part.Criteria = make([]Criterion, len(self.Criteria))
+		part.Options = make(map[string]interface{})
+
+		copy(part.Criteria, self.Criteria)
+
+		for k, v := range self.Options {
+			part.Options[k] = v
+		}
+
+		part.AddCriteria(Criterion{
+			Type:     dal.IntType,
+			Field:    field,
+			Operator: `gte`,
+			Values:   []interface{}{lower},
+		})
+
+		if upper > max {
+			part.AddCriteria(Criterion{
+				Type:     dal.IntType,
+				Field:    field,
+				Operator: `lte`,
+				Values:   []interface{}{max},
+			})
+		} else {
+			part.AddCriteria(Criterion{
+				Type:     dal.IntType,
+				Field:    field,
+				Operator: `lt`,
+				Values:   []interface{}{upper},
+			})
+		}
+
+		part.Spec = part.String()
+		filters = append(filters, &part)
+	}
+
+	return filters
+}
+
 func (self *Filter) CriteriaFields() []string {
 	fields := make([]string, len(self.Criteria))
 
@@ -553,6 +656,13 @@ func (self *Filter) MatchesRecord(record *dal.Record) bool {
 						}
 					}
 
+				case dal.TimeType:
+					if vT, err := stringutil.ConvertToTime(vI); err == nil {
+						if cT, err := stringutil.ConvertToTime(cmpValue); err == nil {
+							isEqual = vT.Equal(cT)
+						}
+					}
+
 				default:
 					isEqual = (vI == cmpValue)
 				}
@@ -576,11 +686,34 @@ func (self *Filter) MatchesRecord(record *dal.Record) bool {
 					return false
 				}
 
+			case `fuzzy`:
+				if editDistance(cmpValueS, vStr) > criterion.GetFuzziness() {
+					return false
+				}
+
+			case `match`, `search`:
+				// words are compared individually, so the values are tokenized rather than normalized
+				if cmpValue == nil || !containsWords(fmt.Sprintf("%v", cmpValue), fmt.Sprintf("%v", vI)) {
+					return false
+				}
+
 			case `gt`, `lt`, `gte`, `lte`:
 				var cmpValueF float64
 				var vF float64
 
-				if v, err := stringutil.ConvertToFloat(vI); err == nil {
+				// times are compared by their position on the timeline (in nanoseconds)
+				if _, isTime := cmpValue.(time.Time); isTime || criterion.Type == dal.TimeType {
+					if v, err := stringutil.ConvertToTime(vI); err == nil {
+						if c, err := stringutil.ConvertToTime(cmpValue); err == nil {
+							vF = float64(v.UnixNano())
+							cmpValueF = float64(c.UnixNano())
+						} else {
+							return false
+						}
+					} else {
+						return false
+					}
+				} else if v, err := stringutil.ConvertToFloat(vI); err == nil {
 					vF = v
 
 					if c, err := stringutil.ConvertToFloat(cmpValue); err == nil {
@@ -620,6 +753,62 @@ func (self *Filter) MatchesRecord(record *dal.Record) bool {
 	return true
 }
 
+// Returns whether any of the filter's criteria are full-text searches.
+func (self *Filter) HasFullText() bool {
+	for _, criterion := range self.Criteria {
+		if IsFullTextOperator(criterion.Operator) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Splits the filter into one containing only its full-text criteria and one containing the rest.
+// Both have the same options as this filter; if either has no criteria, it is nil.
+func (self *Filter) SplitFullText() (*Filter, *Filter) {
+	var text, rest *Filter
+
+	for _, criterion := range self.Criteria {
+		if IsFullTextOperator(criterion.Operator) {
+			if text == nil {
+				text = self.withoutCriteria()
+			}
+
+			text.AddCriteria(criterion)
+		} else {
+			if rest == nil {
+				rest = self.withoutCriteria()
+			}
+
+			rest.AddCriteria(criterion)
+		}
+	}
+
+	if text != nil {
+		text.Spec = text.String()
+	}
+
+	if rest != nil {
+		rest.Spec = rest.String()
+	}
+
+	return text, rest
+}
+
+func (self *Filter) withoutCriteria() *Filter {
+	f := Copy(self)
+	f.MatchAll = false
+	f.Criteria = make([]Criterion, 0)
+	f.Options = make(map[string]interface{})
+
+	for k, v := range self.Options {
+		f.Options[k] = v
+	}
+
+	return &f
+}
+
 func IsExactMatchOperator(operator string) bool {
 	switch operator {
 	case ``, `is`, `not`, `gt`, `gte`, `lt`, `lte`:
@@ -629,6 +818,70 @@ func IsExactMatchOperator(operator string) bool {
 	return false
 }
 
+// Returns the Levenshtein distance between two strings (the number of single-character insertions,
+// deletions, and substitutions needed to turn one into the other.)
+func editDistance(a string, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+	row := make([]int, len(rb)+1)
+
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current := row[j]
+			row[j] = prev + cost
+
+			if v := row[j-1] + 1; v < row[j] {
+				row[j] = v
+			}
+
+			if v := current + 1; v < row[j] {
+				row[j] = v
+			}
+
+			prev = current
+		}
+	}
+
+	return row[len(rb)]
+}
+
+// Returns whether the given operator performs a full-text search, which is best handled by a search
+// index rather than the backend itself.
+func IsFullTextOperator(operator string) bool {
+	switch operator {
+	case `match`, `search`:
+		return true
+	}
+
+	return false
+}
+
+// returns whether every word in the query appears in the value, ignoring case and punctuation
+func containsWords(value string, query string) bool {
+	words := rxWordSeparator.Split(strings.ToLower(value), -1)
+
+	for _, term := range rxWordSeparator.Split(strings.ToLower(query), -1) {
+		if term != `` && !sliceutil.ContainsString(words, term) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func IsInvertingOperator(operator string) bool {
 	switch operator {
 	case `not`, `unlike`:
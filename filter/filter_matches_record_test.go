@@ -55,4 +55,10 @@ func TestFilterMatchesRecord(t *testing.T) {
 	assert.True(MustParse(`name/contains:olden rod`).MatchesRecord(dal.NewRecord(1).Set(`name`, `Golden rod`)))
 	assert.True(MustParse(`name/Golden rod`).MatchesRecord(dal.NewRecord(1).Set(`name`, `Golden rod`)))
 	assert.True(MustParse(`name/like:golden rod`).MatchesRecord(dal.NewRecord(1).Set(`name`, `Golden rod`)))
+
+	assert.True(MustParse(`body/match:quick fox`).MatchesRecord(dal.NewRecord(1).Set(`body`, `The quick, brown Fox.`)))
+	assert.True(MustParse(`body/search:QUICK`).MatchesRecord(dal.NewRecord(1).Set(`body`, `The quick, brown Fox.`)))
+	assert.False(MustParse(`body/match:quick dog`).MatchesRecord(dal.NewRecord(1).Set(`body`, `The quick, brown Fox.`)))
+	assert.False(MustParse(`body/match:qui`).MatchesRecord(dal.NewRecord(1).Set(`body`, `The quick, brown Fox.`)))
+	assert.False(MustParse(`body/match:quick`).MatchesRecord(dal.NewRecord(1)))
 }
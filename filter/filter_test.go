@@ -1,7 +1,9 @@
 package filter
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/ghetzel/pivot/dal"
 	"github.com/stretchr/testify/require"
@@ -198,3 +200,208 @@ func TestFilterCopy(t *testing.T) {
 		},
 	}, f2.Criteria)
 }
+
+func TestFilterFromJSON(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := FromJSON([]byte(`{
+		"criteria": [
+			{"field": "name", "operator": "prefix", "values": ["foo"]},
+			{"type": "int", "field": "age", "operator": "gte", "values": [21]}
+		],
+		"sort":   ["-age"],
+		"fields": ["name"],
+		"limit":  10,
+		"offset": 5
+	}`))
+
+	assert.Nil(err)
+	assert.False(f.IsMatchAll())
+	assert.Equal(2, len(f.Criteria))
+	assert.True(dal.AutoType == f.Criteria[0].Type)
+	assert.Equal(`prefix`, f.Criteria[0].Operator)
+	assert.True(dal.IntType == f.Criteria[1].Type)
+	assert.Equal([]string{`-age`}, f.Sort)
+	assert.Equal([]string{`name`}, f.Fields)
+	assert.Equal(10, f.Limit)
+	assert.Equal(5, f.Offset)
+	assert.Equal(`name/prefix:foo/int:age/gte:21`, f.String())
+
+	cursor := f.NextCursor()
+	assert.NotEmpty(cursor)
+
+	f, err = FromJSON([]byte(`{"limit": 10, "cursor": "` + cursor + `"}`))
+	assert.Nil(err)
+	assert.True(f.IsMatchAll())
+	assert.Equal(15, f.Offset)
+
+	_, err = FromJSON([]byte(`{"cursor": "nope"}`))
+	assert.NotNil(err)
+
+	_, err = FromJSON([]byte(`{"criteria": [{"values": [1]}]}`))
+	assert.NotNil(err)
+}
+
+func TestFilterJSONRoundTrip(t *testing.T) {
+	assert := require.New(t)
+
+	f := MustParse(`name/prefix:foo/int:age/gte:21`)
+	f.Sort = []string{`-age`}
+	f.Fields = []string{`name`}
+	f.Limit = 10
+	f.Offset = 20
+
+	data, err := f.ToJSON()
+	assert.NoError(err)
+
+	parsed, err := FromJSON(data)
+	assert.NoError(err)
+	assert.Equal(f.String(), parsed.String())
+	assert.Equal(f.Sort, parsed.Sort)
+	assert.Equal(f.Fields, parsed.Fields)
+	assert.Equal(f.Limit, parsed.Limit)
+	assert.Equal(f.Offset, parsed.Offset)
+
+	data, err = All().ToJSON()
+	assert.NoError(err)
+
+	parsed, err = FromJSON(data)
+	assert.NoError(err)
+	assert.True(parsed.IsMatchAll())
+}
+
+// the JSON wire format must not change how types that embed a Filter are decoded
+func TestFilterEmbeddedJSON(t *testing.T) {
+	assert := require.New(t)
+
+	var saved struct {
+		Filter
+		Name string `json:"name"`
+	}
+
+	assert.NoError(json.Unmarshal([]byte(`{
+		"name":   "adults",
+		"Spec":   "age/gte:21",
+		"Limit":  10,
+		"Fields": ["name"]
+	}`), &saved))
+
+	assert.Equal(`adults`, saved.Name)
+	assert.Equal(`age/gte:21`, saved.Spec)
+	assert.Equal(10, saved.Limit)
+	assert.Equal([]string{`name`}, saved.Fields)
+}
+
+func TestFilterSplitRange(t *testing.T) {
+	assert := require.New(t)
+
+	CriteriaSeparator = `/`
+	FieldTermSeparator = `/`
+
+	f := MustParse(`name/prefix:foo`)
+	f.Limit = 10
+	f.Offset = 20
+
+	parts := f.SplitRange(`id`, 1, 10, 3)
+	assert.Len(parts, 3)
+
+	assert.Equal(`name/prefix:foo/int:id/gte:1/int:id/lt:5`, parts[0].String())
+	assert.Equal(`name/prefix:foo/int:id/gte:5/int:id/lt:9`, parts[1].String())
+	assert.Equal(`name/prefix:foo/int:id/gte:9/int:id/lte:10`, parts[2].String())
+
+	for _, part := range parts {
+		assert.Equal(0, part.Limit)
+		assert.Equal(0, part.Offset)
+	}
+
+	// the original is left untouched
+	assert.Len(f.Criteria, 1)
+	assert.Equal(10, f.Limit)
+
+	all := All().SplitRange(`id`, 1, 2, 8)
+	assert.Len(all, 2)
+	assert.False(all[0].IsMatchAll())
+	assert.Equal(`int:id/gte:1/int:id/lt:2`, all[0].String())
+	assert.Equal(`int:id/gte:2/int:id/lte:2`, all[1].String())
+
+	// ranges that cannot be split return the filter itself
+	assert.True(f == f.SplitRange(`id`, 5, 5, 4)[0])
+	assert.True(f == f.SplitRange(`id`, 1, 100, 1)[0])
+}
+
+func TestFilterFuzzy(t *testing.T) {
+	assert := require.New(t)
+
+	CriteriaSeparator = `/`
+	FieldTermSeparator = `/`
+
+	f := MustParse(`name/fuzzy:bob/city/fuzzy#2:boston`)
+	assert.Len(f.Criteria, 2)
+
+	assert.Equal(`fuzzy`, f.Criteria[0].Operator)
+	assert.Equal(0, f.Criteria[0].Fuzziness)
+	assert.Equal(DefaultFuzziness, f.Criteria[0].GetFuzziness())
+
+	assert.Equal(`fuzzy`, f.Criteria[1].Operator)
+	assert.Equal(2, f.Criteria[1].Fuzziness)
+	assert.Equal([]interface{}{`boston`}, f.Criteria[1].Values)
+	assert.Equal(`name/fuzzy:bob/city/fuzzy#2:boston`, f.String())
+
+	assert.True(f.MatchesRecord(dal.NewRecord(1).Set(`name`, `Rob`).Set(`city`, `Bostn`)))
+	assert.False(f.MatchesRecord(dal.NewRecord(1).Set(`name`, `bob`).Set(`city`, `Austin`)))
+	assert.False(f.MatchesRecord(dal.NewRecord(1).Set(`name`, `Robert`).Set(`city`, `Boston`)))
+
+	_, err := Parse(`name/fuzzy#x:bob`)
+	assert.NotNil(err)
+
+	assert.Equal(3, editDistance(`kitten`, `sitting`))
+	assert.Equal(0, editDistance(``, ``))
+	assert.Equal(4, editDistance(``, `four`))
+}
+
+func TestFilterTimeComparisons(t *testing.T) {
+	assert := require.New(t)
+
+	CriteriaSeparator = `/`
+	FieldTermSeparator = `/`
+
+	then := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	record := dal.NewRecord(1).Set(`created`, then)
+
+	assert.True(MustParse(`time:created/is:2018-01-01T12:00:00Z`).MatchesRecord(record))
+	assert.True(MustParse(`time:created/is:2018-01-01T07:00:00-05:00`).MatchesRecord(record))
+	assert.False(MustParse(`time:created/is:2018-01-02T12:00:00Z`).MatchesRecord(record))
+
+	assert.True(MustParse(`time:created/gt:2017-12-31T23:59:59Z`).MatchesRecord(record))
+	assert.False(MustParse(`time:created/gt:2018-01-01T12:00:00Z`).MatchesRecord(record))
+	assert.True(MustParse(`time:created/gte:2018-01-01T12:00:00Z`).MatchesRecord(record))
+	assert.True(MustParse(`time:created/lt:2018-01-01T12:00:01Z`).MatchesRecord(record))
+	assert.False(MustParse(`time:created/lte:2018-01-01T11:59:59Z`).MatchesRecord(record))
+
+	// record values that are times are compared as times even without an explicit type
+	f := MustParse(`created/gt:0`)
+	f.Criteria[0].Values = []interface{}{then.Add(-time.Hour)}
+	assert.True(f.MatchesRecord(record))
+
+	f.Criteria[0].Values = []interface{}{then.Add(time.Hour)}
+	assert.False(f.MatchesRecord(record))
+}
+
+func TestFilterSplitFullText(t *testing.T) {
+	assert := require.New(t)
+
+	f := MustParse(`body/match:quick fox/status/active/body/not:null`)
+	f.Limit = 5
+	assert.True(f.HasFullText())
+
+	text, rest := f.SplitFullText()
+	assert.Equal(`body/match:quick fox`, text.String())
+	assert.Equal(`status/active/body/not:null`, rest.String())
+	assert.Equal(5, text.Limit)
+	assert.Equal(5, rest.Limit)
+
+	text, rest = MustParse(`status/active`).SplitFullText()
+	assert.Nil(text)
+	assert.Equal(`status/active`, rest.String())
+	assert.False(rest.HasFullText())
+}
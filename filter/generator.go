@@ -1,5 +1,17 @@
 package filter
 
+import (
+	"sync"
+)
+
+// Rendered payloads are built in buffers taken from this pool; generators return them via Release.
+var payloadPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
 type IGenerator interface {
 	Initialize(string) error
 	Finalize(*Filter) error
@@ -57,12 +69,23 @@ func Render(generator IGenerator, collectionName string, filter *Filter) ([]byte
 
 func (self *Generator) Push(data []byte) {
 	if self.payload == nil {
-		self.payload = make([]byte, 0)
+		self.payload = (*payloadPool.Get().(*[]byte))[:0]
 	}
 
 	self.payload = append(self.payload, data...)
 }
 
+// Returns the generator's payload buffer to a pool for reuse by other generators.  This is optional,
+// but callers rendering many statements may call it once they are done with the payload (including
+// any slices of it returned by Render) to reduce allocations.
+func (self *Generator) Release() {
+	if self.payload != nil {
+		buf := self.payload[:0]
+		self.payload = nil
+		payloadPool.Put(&buf)
+	}
+}
+
 func (self *Generator) Reset() {
 	self.payload = nil
 }
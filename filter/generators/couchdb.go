@@ -0,0 +1,357 @@
+package generators
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// CouchDB Mango Query Generator
+//
+// Renders filters as the body of a CouchDB _find request: a Mango selector, along with the fields,
+// sort order, limit and skip given by the filter.
+
+// The selector used to match every document, since Mango requires at least one condition.
+var CouchDBMatchAllSelector = map[string]interface{}{
+	`_id`: map[string]interface{}{
+		`$gt`: nil,
+	},
+}
+
+type CouchDB struct {
+	filter.Generator
+	collection string
+	fields     []string
+	criteria   []map[string]interface{}
+	options    map[string]interface{}
+	values     []interface{}
+}
+
+func NewCouchDBGenerator() *CouchDB {
+	return &CouchDB{
+		Generator: filter.Generator{},
+	}
+}
+
+func (self *CouchDB) Initialize(collectionName string) error {
+	self.Reset()
+	self.collection = collectionName
+	self.fields = make([]string, 0)
+	self.criteria = make([]map[string]interface{}, 0)
+	self.options = make(map[string]interface{})
+	self.values = make([]interface{}, 0)
+
+	return nil
+}
+
+func (self *CouchDB) Finalize(flt *filter.Filter) error {
+	var selector map[string]interface{}
+
+	if flt.IsMatchAll() || len(self.criteria) == 0 {
+		selector = CouchDBMatchAllSelector
+	} else if len(self.criteria) == 1 {
+		selector = self.criteria[0]
+	} else {
+		selector = map[string]interface{}{
+			`$and`: self.criteria,
+		}
+	}
+
+	query := map[string]interface{}{
+		`selector`: selector,
+	}
+
+	if len(self.fields) > 0 {
+		// the ID and revision are always needed to make records out of the documents
+		query[`fields`] = append([]string{`_id`, `_rev`}, self.fields...)
+	}
+
+	if sortBy := flt.GetSort(); len(sortBy) > 0 {
+		sort := make([]map[string]interface{}, len(sortBy))
+
+		for i, s := range sortBy {
+			order := `asc`
+
+			if s.Descending {
+				order = `desc`
+			}
+
+			sort[i] = map[string]interface{}{
+				couchdbField(s.Field): order,
+			}
+		}
+
+		query[`sort`] = sort
+	}
+
+	if flt.Limit > 0 {
+		query[`limit`] = flt.Limit
+	}
+
+	if flt.Offset > 0 {
+		query[`skip`] = flt.Offset
+	}
+
+	for key, value := range self.options {
+		query[key] = value
+	}
+
+	if data, err := json.MarshalIndent(query, ``, `    `); err == nil {
+		self.Push(data)
+	} else {
+		return err
+	}
+
+	return nil
+}
+
+func (self *CouchDB) WithField(field string) error {
+	if field = couchdbField(field); field != `_id` {
+		self.fields = append(self.fields, field)
+	}
+
+	return nil
+}
+
+// Options are added to the _find request as-is (e.g.: "use_index", "bookmark", or "r").
+func (self *CouchDB) SetOption(key string, value interface{}) error {
+	self.options[key] = value
+	return nil
+}
+
+func (self *CouchDB) GroupByField(field string) error {
+	return fmt.Errorf("Grouping is not supported by Mango queries")
+}
+
+func (self *CouchDB) AggregateByField(agg filter.Aggregation, field string) error {
+	return fmt.Errorf("Aggregation is not supported by Mango queries")
+}
+
+func (self *CouchDB) GetValues() []interface{} {
+	return self.values
+}
+
+func (self *CouchDB) WithCriterion(criterion filter.Criterion) error {
+	var c map[string]interface{}
+	var err error
+
+	criterion.Field = couchdbField(criterion.Field)
+
+	for i, value := range criterion.Values {
+		if criterion.Field == `_id` {
+			// document IDs are always strings
+			criterion.Values[i] = fmt.Sprintf("%v", value)
+		} else if _, ok := value.(string); ok {
+			criterion.Values[i] = stringutil.Autotype(value)
+		}
+	}
+
+	switch criterion.Operator {
+	case `is`, ``:
+		c, err = couchdbCriterionOperatorIs(self, criterion)
+	case `not`:
+		c, err = couchdbCriterionOperatorNot(self, criterion)
+	case `contains`, `prefix`, `suffix`, `like`, `unlike`, `match`, `search`:
+		c, err = couchdbCriterionOperatorPattern(self, criterion.Operator, criterion)
+	case `gt`, `gte`, `lt`, `lte`, `range`:
+		c, err = couchdbCriterionOperatorRange(self, criterion, criterion.Operator)
+	default:
+		return fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
+	}
+
+	if err != nil {
+		return err
+	} else {
+		self.criteria = append(self.criteria, c)
+	}
+
+	return nil
+}
+
+func couchdbField(field string) string {
+	if field == `id` {
+		return `_id`
+	}
+
+	return field
+}
+
+func couchdbCriterionOperatorIs(gen *CouchDB, criterion filter.Criterion) (map[string]interface{}, error) {
+	switch len(criterion.Values) {
+	case 0:
+		return nil, fmt.Errorf("No values given for criterion %v", criterion.Field)
+	case 1:
+		gen.values = append(gen.values, criterion.Values[0])
+
+		if criterion.Values[0] == nil {
+			return map[string]interface{}{
+				`$or`: []map[string]interface{}{
+					{
+						criterion.Field: map[string]interface{}{
+							`$exists`: false,
+						},
+					}, {
+						criterion.Field: map[string]interface{}{
+							`$eq`: nil,
+						},
+					},
+				},
+			}, nil
+		}
+
+		return map[string]interface{}{
+			criterion.Field: map[string]interface{}{
+				`$eq`: criterion.Values[0],
+			},
+		}, nil
+	default:
+		gen.values = append(gen.values, criterion.Values...)
+
+		return map[string]interface{}{
+			criterion.Field: map[string]interface{}{
+				`$in`: criterion.Values,
+			},
+		}, nil
+	}
+}
+
+func couchdbCriterionOperatorNot(gen *CouchDB, criterion filter.Criterion) (map[string]interface{}, error) {
+	switch len(criterion.Values) {
+	case 0:
+		return nil, fmt.Errorf("The not criterion must have at least one value")
+	case 1:
+		gen.values = append(gen.values, criterion.Values[0])
+
+		if criterion.Values[0] == nil {
+			return map[string]interface{}{
+				criterion.Field: map[string]interface{}{
+					`$exists`: true,
+					`$ne`:     nil,
+				},
+			}, nil
+		}
+
+		return map[string]interface{}{
+			criterion.Field: map[string]interface{}{
+				`$ne`: criterion.Values[0],
+			},
+		}, nil
+	default:
+		gen.values = append(gen.values, criterion.Values...)
+
+		return map[string]interface{}{
+			criterion.Field: map[string]interface{}{
+				`$nin`: criterion.Values,
+			},
+		}, nil
+	}
+}
+
+// Mango regular expressions are Erlang (PCRE) patterns, which take their flags inline.
+func couchdbCriterionOperatorPattern(gen *CouchDB, opname string, criterion filter.Criterion) (map[string]interface{}, error) {
+	if len(criterion.Values) == 0 {
+		return nil, fmt.Errorf("The %v criterion must have at least one value", opname)
+	}
+
+	clauses := make([]map[string]interface{}, 0)
+
+	for _, value := range criterion.Values {
+		gen.values = append(gen.values, value)
+		str := fmt.Sprintf("%v", value)
+		var pattern string
+
+		switch opname {
+		case `contains`:
+			pattern = regexp.QuoteMeta(str)
+		case `prefix`:
+			pattern = `^` + regexp.QuoteMeta(str)
+		case `suffix`:
+			pattern = regexp.QuoteMeta(str) + `$`
+		case `like`, `unlike`:
+			pattern = `^` + rxCharFilter.ReplaceAllString(str, `.`) + `$`
+		case `match`, `search`:
+			// every word must appear somewhere in the value
+			for _, word := range strings.Fields(str) {
+				pattern += fmt.Sprintf("(?=.*\\b%s\\b)", regexp.QuoteMeta(word))
+			}
+		default:
+			return nil, fmt.Errorf("Unsupported pattern operator %q", opname)
+		}
+
+		clause := map[string]interface{}{
+			criterion.Field: map[string]interface{}{
+				`$regex`: `(?si)` + pattern,
+			},
+		}
+
+		if opname == `unlike` {
+			clause = map[string]interface{}{
+				`$not`: clause,
+			}
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	if len(clauses) == 1 {
+		return clauses[0], nil
+	} else if opname == `unlike` {
+		return map[string]interface{}{
+			`$and`: clauses,
+		}, nil
+	} else {
+		return map[string]interface{}{
+			`$or`: clauses,
+		}, nil
+	}
+}
+
+func couchdbCriterionOperatorRange(gen *CouchDB, criterion filter.Criterion, operator string) (map[string]interface{}, error) {
+	switch operator {
+	case `range`:
+		if l := len(criterion.Values); l > 0 && (l%2 == 0) {
+			clauses := make([]map[string]interface{}, 0)
+
+			for i := 0; i < l; i += 2 {
+				gen.values = append(gen.values, criterion.Values[i], criterion.Values[i+1])
+
+				clauses = append(clauses, map[string]interface{}{
+					criterion.Field: map[string]interface{}{
+						`$gte`: criterion.Values[i],
+						`$lt`:  criterion.Values[i+1],
+					},
+				})
+			}
+
+			if len(clauses) == 1 {
+				return clauses[0], nil
+			} else {
+				return map[string]interface{}{
+					`$or`: clauses,
+				}, nil
+			}
+		} else {
+			return nil, fmt.Errorf("Ranging criteria can only accept pairs of values, %d given", l)
+		}
+
+	default:
+		switch l := len(criterion.Values); l {
+		case 0:
+			return nil, fmt.Errorf("No values given for criterion %v", criterion.Field)
+		case 1:
+			gen.values = append(gen.values, criterion.Values[0])
+
+			return map[string]interface{}{
+				criterion.Field: map[string]interface{}{
+					`$` + operator: criterion.Values[0],
+				},
+			}, nil
+		default:
+			return nil, fmt.Errorf("Numeric comparators can only accept one value, %d given", l)
+		}
+	}
+}
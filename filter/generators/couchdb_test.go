@@ -0,0 +1,122 @@
+package generators
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCouchDB(t *testing.T) {
+	assert := require.New(t)
+
+	tests := map[string]map[string]interface{}{
+		`all`: {
+			`_id`: map[string]interface{}{
+				`$gt`: nil,
+			},
+		},
+		`id/1`: {
+			`_id`: map[string]interface{}{
+				`$eq`: `1`,
+			},
+		},
+		`name/Bob Johnson`: {
+			`name`: map[string]interface{}{
+				`$eq`: `Bob Johnson`,
+			},
+		},
+		`age/21`: {
+			`age`: map[string]interface{}{
+				`$eq`: float64(21),
+			},
+		},
+		`enabled/false`: {
+			`enabled`: map[string]interface{}{
+				`$eq`: false,
+			},
+		},
+		`name/not:Bob|Ted`: {
+			`name`: map[string]interface{}{
+				`$nin`: []interface{}{`Bob`, `Ted`},
+			},
+		},
+		`age/gte:21`: {
+			`age`: map[string]interface{}{
+				`$gte`: float64(21),
+			},
+		},
+		`age/range:18|30`: {
+			`age`: map[string]interface{}{
+				`$gte`: float64(18),
+				`$lt`:  float64(30),
+			},
+		},
+		`name/prefix:ob.`: {
+			`name`: map[string]interface{}{
+				`$regex`: `(?si)^ob\.`,
+			},
+		},
+		`name/unlike:bob`: {
+			`$not`: map[string]interface{}{
+				`name`: map[string]interface{}{
+					`$regex`: `(?si)^bob$`,
+				},
+			},
+		},
+		`age/7/name/ted`: {
+			`$and`: []interface{}{
+				map[string]interface{}{
+					`age`: map[string]interface{}{
+						`$eq`: float64(7),
+					},
+				},
+				map[string]interface{}{
+					`name`: map[string]interface{}{
+						`$eq`: `ted`,
+					},
+				},
+			},
+		},
+	}
+
+	for spec, expected := range tests {
+		f, err := filter.Parse(spec)
+		assert.NoError(err)
+
+		actual, err := filter.Render(NewCouchDBGenerator(), `foo`, f)
+		assert.NoError(err)
+
+		var query map[string]interface{}
+		assert.NoError(json.Unmarshal(actual, &query))
+		assert.Equal(expected, query[`selector`], "filter: %v", spec)
+	}
+}
+
+func TestCouchDBFindOptions(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := filter.Parse(`age/gt:21`)
+	assert.NoError(err)
+
+	f.Fields = []string{`id`, `name`}
+	f.Sort = []string{`-age`}
+	f.Limit = 10
+	f.Offset = 20
+
+	actual, err := filter.Render(NewCouchDBGenerator(), `foo`, f)
+	assert.NoError(err)
+
+	var query map[string]interface{}
+	assert.NoError(json.Unmarshal(actual, &query))
+
+	assert.Equal([]interface{}{`_id`, `_rev`, `name`}, query[`fields`])
+	assert.Equal([]interface{}{
+		map[string]interface{}{
+			`age`: `desc`,
+		},
+	}, query[`sort`])
+	assert.Equal(float64(10), query[`limit`])
+	assert.Equal(float64(20), query[`skip`])
+}
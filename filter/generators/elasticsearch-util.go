@@ -173,3 +173,63 @@ func esCriterionOperatorRange(gen *Elasticsearch, criterion filter.Criterion, op
 
 	return c, nil
 }
+
+func esCriterionOperatorFuzzy(gen *Elasticsearch, criterion filter.Criterion) (map[string]interface{}, error) {
+	c := make(map[string]interface{})
+
+	if len(criterion.Values) == 0 {
+		return nil, fmt.Errorf("The fuzzy criterion must have at least one value")
+	} else {
+		or_fuzzy := make([]map[string]interface{}, 0)
+
+		for _, value := range criterion.Values {
+			gen.values = append(gen.values, value)
+
+			or_fuzzy = append(or_fuzzy, map[string]interface{}{
+				`fuzzy`: map[string]interface{}{
+					criterion.Field: map[string]interface{}{
+						`value`:     value,
+						`fuzziness`: criterion.GetFuzziness(),
+					},
+				},
+			})
+		}
+
+		c[`bool`] = map[string]interface{}{
+			`should`:               or_fuzzy,
+			`minimum_should_match`: 1,
+		}
+	}
+
+	return c, nil
+}
+
+func esCriterionOperatorMatch(gen *Elasticsearch, criterion filter.Criterion) (map[string]interface{}, error) {
+	c := make(map[string]interface{})
+
+	if len(criterion.Values) == 0 {
+		return nil, fmt.Errorf("The match criterion must have at least one value")
+	} else {
+		or_match := make([]map[string]interface{}, 0)
+
+		for _, value := range criterion.Values {
+			gen.values = append(gen.values, value)
+
+			or_match = append(or_match, map[string]interface{}{
+				`match`: map[string]interface{}{
+					criterion.Field: map[string]interface{}{
+						`query`:    value,
+						`operator`: `and`,
+					},
+				},
+			})
+		}
+
+		c[`bool`] = map[string]interface{}{
+			`should`:               or_match,
+			`minimum_should_match`: 1,
+		}
+	}
+
+	return c, nil
+}
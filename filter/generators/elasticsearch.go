@@ -14,6 +14,7 @@ type Elasticsearch struct {
 	collection  string
 	fields      []string
 	criteria    []map[string]interface{}
+	scoring     []map[string]interface{}
 	options     map[string]interface{}
 	values      []interface{}
 	facetFields []string
@@ -31,6 +32,7 @@ func (self *Elasticsearch) Initialize(collectionName string) error {
 	self.collection = collectionName
 	self.fields = make([]string, 0)
 	self.criteria = make([]map[string]interface{}, 0)
+	self.scoring = make([]map[string]interface{}, 0)
 	self.options = make(map[string]interface{})
 	self.values = make([]interface{}, 0)
 
@@ -40,7 +42,7 @@ func (self *Elasticsearch) Initialize(collectionName string) error {
 func (self *Elasticsearch) Finalize(flt *filter.Filter) error {
 	var query map[string]interface{}
 
-	if flt.Spec == `all` {
+	if flt.Spec == `all` || len(self.criteria) == 0 {
 		query = map[string]interface{}{
 			`match_all`: map[string]interface{}{},
 		}
@@ -56,10 +58,39 @@ func (self *Elasticsearch) Finalize(flt *filter.Filter) error {
 		`from`:   flt.Offset,
 	}
 
+	// criteria that affect relevance are applied as a query so that hits are scored by them
+	if len(self.scoring) > 0 {
+		payload[`query`] = map[string]interface{}{
+			`bool`: map[string]interface{}{
+				`must`: self.scoring,
+			},
+		}
+	}
+
 	if len(flt.Fields) > 0 {
 		payload[`fields`] = flt.Fields
 	}
 
+	if sortBy := flt.GetSort(); len(sortBy) > 0 {
+		sort := make([]map[string]interface{}, len(sortBy))
+
+		for i, s := range sortBy {
+			order := `asc`
+
+			if s.Descending {
+				order = `desc`
+			}
+
+			sort[i] = map[string]interface{}{
+				s.Field: map[string]interface{}{
+					`order`: order,
+				},
+			}
+		}
+
+		payload[`sort`] = sort
+	}
+
 	if data, err := json.MarshalIndent(payload, ``, `    `); err == nil {
 		self.Push(data)
 	} else {
@@ -110,6 +141,20 @@ func (self *Elasticsearch) WithCriterion(criterion filter.Criterion) error {
 		c, err = esCriterionOperatorPattern(self, criterion.Operator, criterion)
 	case `gt`, `gte`, `lt`, `lte`:
 		c, err = esCriterionOperatorRange(self, criterion, criterion.Operator)
+	case `match`, `search`:
+		if c, err = esCriterionOperatorMatch(self, criterion); err == nil {
+			self.scoring = append(self.scoring, c)
+			return nil
+		} else {
+			return err
+		}
+	case `fuzzy`:
+		if c, err = esCriterionOperatorFuzzy(self, criterion); err == nil {
+			self.scoring = append(self.scoring, c)
+			return nil
+		} else {
+			return err
+		}
 	default:
 		return fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
 	}
@@ -3,6 +3,7 @@ package generators
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/pivot/filter"
@@ -106,6 +107,11 @@ func mongoCriterionOperatorPattern(gen *MongoDB, opname string, criterion filter
 				valueClause = fmt.Sprintf(".*%v$", value)
 			case `like`, `unlike`:
 				valueClause = rxCharFilter.ReplaceAllString(fmt.Sprintf("%v", value), `.`)
+			case `match`, `search`:
+				// every word must appear somewhere in the value
+				for _, word := range strings.Fields(fmt.Sprintf("%v", value)) {
+					valueClause += fmt.Sprintf("(?=.*\\b%s\\b)", regexp.QuoteMeta(word))
+				}
 			default:
 				return nil, fmt.Errorf("Unsupported pattern operator %q", opname)
 			}
@@ -0,0 +1,156 @@
+package generators
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// Caches the SQL rendered for SELECT statements, keyed by the collection, the generator's settings and
+// the structure of the filter (its fields, sort, limit, offset and the field, type, operator and number of
+// values of each criterion.)  Rendering a filter whose structure has been seen before reuses the cached
+// statement, and only the filter's values are converted and bound.
+type SqlStatementCache struct {
+	MaxEntries int
+	statements map[string][]byte
+	lock       sync.RWMutex
+	hits       int64
+	misses     int64
+}
+
+func NewSqlStatementCache(maxEntries int) *SqlStatementCache {
+	return &SqlStatementCache{
+		MaxEntries: maxEntries,
+		statements: make(map[string][]byte),
+	}
+}
+
+// Renders the given filter using the generator (or retrieves the statement previously rendered for
+// a filter with the same structure), returning the statement and the values that should be bound to it.
+// Statements that are not SELECTs, or that group or aggregate, are always rendered.
+func (self *SqlStatementCache) Render(generator *Sql, collectionName string, f *filter.Filter) ([]byte, []interface{}, error) {
+	if self == nil || self.MaxEntries <= 0 || !generator.cacheable() {
+		stmt, err := filter.Render(generator, collectionName, f)
+		return stmt, generator.GetValues(), err
+	}
+
+	key := generator.statementKey(collectionName, f)
+
+	self.lock.RLock()
+	stmt, ok := self.statements[key]
+	self.lock.RUnlock()
+
+	if ok {
+		atomic.AddInt64(&self.hits, 1)
+		values := make([]interface{}, 0)
+
+		for _, criterion := range f.Criteria {
+			for _, vI := range criterion.Values {
+				if value, err := generator.criterionValue(criterion, vI); err == nil {
+					values = append(values, value)
+				} else {
+					return nil, nil, err
+				}
+			}
+		}
+
+		return stmt, values, nil
+	}
+
+	atomic.AddInt64(&self.misses, 1)
+
+	if payload, err := filter.Render(generator, collectionName, f); err == nil {
+		// the generator's payload may be returned to a pool, so cache a copy of it
+		stmt = make([]byte, len(payload))
+		copy(stmt, payload)
+
+		self.lock.Lock()
+
+		if len(self.statements) >= self.MaxEntries {
+			self.statements = make(map[string][]byte)
+		}
+
+		self.statements[key] = stmt
+		self.lock.Unlock()
+
+		return payload, generator.GetValues(), nil
+	} else {
+		return nil, nil, err
+	}
+}
+
+// Returns the number of cache hits and misses since the cache was created.
+func (self *SqlStatementCache) Stats() (int64, int64) {
+	return atomic.LoadInt64(&self.hits), atomic.LoadInt64(&self.misses)
+}
+
+// Removes all cached statements.
+func (self *SqlStatementCache) Clear() {
+	self.lock.Lock()
+	self.statements = make(map[string][]byte)
+	self.lock.Unlock()
+}
+
+func (self *Sql) cacheable() bool {
+	return self.Type == SqlSelectStatement &&
+		len(self.InputData) == 0 &&
+		len(self.groupBy) == 0 &&
+		len(self.aggregateBy) == 0
+}
+
+// Builds a string that uniquely identifies the statement that would be rendered for the given filter
+// with the generator's current settings, without regard to the filter's values.
+func (self *Sql) statementKey(collectionName string, f *filter.Filter) string {
+	var key strings.Builder
+
+	normalize := make([]string, len(self.NormalizeFields))
+	copy(normalize, self.NormalizeFields)
+	sort.Strings(normalize)
+
+	fmt.Fprintf(&key, "%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%v|%v|%v|%v",
+		collectionName,
+		self.TableNameFormat,
+		self.FieldNameFormat,
+		self.NestedFieldNameFormat,
+		self.NestedFieldSeparator,
+		self.NestedFieldJoiner,
+		self.NestedFieldIndexFormat,
+		self.PlaceholderFormat,
+		self.PlaceholderArgument,
+		self.NormalizerFormat,
+		normalize,
+		self.UseInStatement,
+		self.Distinct,
+		self.Count,
+	)
+
+	wrapped := maputil.StringKeys(self.FieldWrappers)
+	sort.Strings(wrapped)
+
+	for _, field := range wrapped {
+		fmt.Fprintf(&key, "|w:%s=%s", field, self.FieldWrappers[field])
+	}
+
+	fmt.Fprintf(&key, "|n:%p", self.NamingConvention)
+	fmt.Fprintf(&key, "|f:%v|s:%v|l:%d|o:%d", f.Fields, f.Sort, f.Limit, f.Offset)
+
+	for _, criterion := range f.Criteria {
+		fmt.Fprintf(&key, "|c:%s:%s:%s:", criterion.Type, criterion.Field, criterion.Operator)
+
+		// NULL values are rendered into the statement rather than bound, so they are part of the structure
+		for _, vI := range criterion.Values {
+			if vI != nil && strings.ToUpper(fmt.Sprintf("%v", vI)) == `NULL` {
+				key.WriteByte('N')
+			} else {
+				key.WriteByte('v')
+			}
+		}
+	}
+
+	return key.String()
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -48,7 +49,11 @@ type SqlTypeMapping struct {
 	BooleanTypeLength  int
 	DateTimeType       string
 	ObjectType         string
+	ObjectTypeIsJson   bool // whether ObjectType is a native JSON type, in which case objects are bound as JSON text
 	RawType            string
+	PointType          string
+	PolygonType        string
+	GeometryFormat     string // if set, the format string used to wrap placeholders for geometries, which are bound as Well-Known Text
 	SubtypeFormat      string
 	MultiSubtypeFormat string
 }
@@ -77,69 +82,157 @@ var MysqlTypeMapping = SqlTypeMapping{
 	FloatTypePrecision: 8,
 	BooleanType:        `BOOL`,
 	DateTimeType:       `DATETIME`,
-	ObjectType:         `BLOB`,
+	ObjectType:         `JSON`,
+	ObjectTypeIsJson:   true,
 	RawType:            `BLOB`,
+	PointType:          `POINT SRID 4326`,
+	PolygonType:        `POLYGON SRID 4326`,
+	GeometryFormat:     `ST_GeomFromText(%s, 4326, 'axis-order=long-lat')`,
+}
+
+// SQL Server has no JSON type, so objects are stored as JSON text
+var MssqlTypeMapping = SqlTypeMapping{
+	StringType:       `NVARCHAR`,
+	StringTypeLength: 255,
+	IntegerType:      `BIGINT`,
+	FloatType:        `FLOAT`,
+	BooleanType:      `BIT`,
+	DateTimeType:     `DATETIME2`,
+	ObjectType:       `NVARCHAR(MAX)`,
+	ObjectTypeIsJson: true,
+	RawType:          `VARBINARY(MAX)`,
 }
 
 var PostgresTypeMapping = SqlTypeMapping{
-	StringType:   `TEXT`,
-	IntegerType:  `BIGINT`,
-	FloatType:    `NUMERIC`,
-	BooleanType:  `BOOLEAN`,
-	DateTimeType: `TIMESTAMP`,
-	ObjectType:   `BLOB`,
-	RawType:      `BLOB`,
-}
-
-var PostgresJsonTypeMapping = SqlTypeMapping{
-	StringType:   `TEXT`,
-	IntegerType:  `BIGINT`,
-	FloatType:    `NUMERIC`,
-	BooleanType:  `BOOLEAN`,
-	DateTimeType: `TIMESTAMP`,
-	// ObjectType:   `JSONB`, // TODO: implement the JSONB functionality in PostgreSQL 9.2+
-	ObjectType: `BLOB`,
-	RawType:    `BLOB`,
+	StringType:       `TEXT`,
+	IntegerType:      `BIGINT`,
+	FloatType:        `NUMERIC`,
+	BooleanType:      `BOOLEAN`,
+	DateTimeType:     `TIMESTAMP`,
+	ObjectType:       `JSONB`,
+	ObjectTypeIsJson: true,
+	RawType:          `BLOB`,
+	PointType:        `GEOMETRY(POINT, 4326)`,
+	PolygonType:      `GEOMETRY(POLYGON, 4326)`,
+	GeometryFormat:   `ST_GeomFromText(%s, 4326)`,
 }
 
+var PostgresJsonTypeMapping = PostgresTypeMapping
+
 var SqliteTypeMapping = SqlTypeMapping{
 	StringType:        `TEXT`,
 	IntegerType:       `INTEGER`,
 	FloatType:         `REAL`,
 	BooleanType:       `INTEGER`,
 	BooleanTypeLength: 1,
-	DateTimeType:      `INTEGER`,
-	ObjectType:        `BLOB`,
+	DateTimeType:      `DATETIME`,
+	ObjectType:        `JSON`,
+	ObjectTypeIsJson:  true,
+	RawType:           `BLOB`,
+	PointType:         `POINT`,
+	PolygonType:       `POLYGON`,
+}
+
+// SpatiaLite geometry columns are added with AddGeometryColumn() rather than declared with the table
+var SpatialiteTypeMapping = SqlTypeMapping{
+	StringType:        `TEXT`,
+	IntegerType:       `INTEGER`,
+	FloatType:         `REAL`,
+	BooleanType:       `INTEGER`,
+	BooleanTypeLength: 1,
+	DateTimeType:      `DATETIME`,
+	ObjectType:        `JSON`,
+	ObjectTypeIsJson:  true,
 	RawType:           `BLOB`,
+	PointType:         `POINT`,
+	PolygonType:       `POLYGON`,
+	GeometryFormat:    `GeomFromText(%s, 4326)`,
+}
+
+// ClickHouse type names are case-sensitive, so these are the case-insensitive aliases it accepts for
+// them.  Objects and raw data are both stored as strings.
+var ClickhouseTypeMapping = SqlTypeMapping{
+	StringType:       `TEXT`,
+	IntegerType:      `BIGINT`,
+	FloatType:        `DOUBLE`,
+	BooleanType:      `TINYINT`,
+	DateTimeType:     `DATETIME`,
+	ObjectType:       `TEXT`,
+	ObjectTypeIsJson: true,
+	RawType:          `BLOB`,
+}
+
+var DuckdbTypeMapping = SqlTypeMapping{
+	StringType:       `VARCHAR`,
+	IntegerType:      `BIGINT`,
+	FloatType:        `DOUBLE`,
+	BooleanType:      `BOOLEAN`,
+	DateTimeType:     `TIMESTAMP`,
+	ObjectType:       `JSON`,
+	ObjectTypeIsJson: true,
+	RawType:          `BLOB`,
+}
+
+// BigQuery Standard SQL types.  Objects are stored as JSON strings, and geometries as geographies.
+var BigqueryTypeMapping = SqlTypeMapping{
+	StringType:       `STRING`,
+	IntegerType:      `INT64`,
+	FloatType:        `FLOAT64`,
+	BooleanType:      `BOOL`,
+	DateTimeType:     `TIMESTAMP`,
+	ObjectType:       `STRING`,
+	ObjectTypeIsJson: true,
+	RawType:          `BYTES`,
+	PointType:        `GEOGRAPHY`,
+	PolygonType:      `GEOGRAPHY`,
+	GeometryFormat:   `ST_GEOGFROMTEXT(%s)`,
+}
+
+// Types common to most databases that follow the SQL standard.  Objects are stored as JSON text.
+var AnsiTypeMapping = SqlTypeMapping{
+	StringType:       `VARCHAR`,
+	StringTypeLength: 255,
+	IntegerType:      `BIGINT`,
+	FloatType:        `DOUBLE PRECISION`,
+	BooleanType:      `BOOLEAN`,
+	DateTimeType:     `TIMESTAMP`,
+	ObjectType:       `CLOB`,
+	ObjectTypeIsJson: true,
+	RawType:          `BLOB`,
 }
 
 var DefaultSqlTypeMapping = MysqlTypeMapping
 
 type Sql struct {
 	filter.Generator
-	TableNameFormat       string                 // format string used to wrap table names
-	FieldNameFormat       string                 // format string used to wrap field names
-	NestedFieldNameFormat string                 // map of field name-format strings to wrap fields addressing nested map keys. supercedes FieldNameFormat
-	NestedFieldSeparator  string                 // the string used to denote nesting in a nested field name
-	NestedFieldJoiner     string                 // the string used to re-join all but the first value in a nested field when interpolating into NestedFieldNameFormat
-	FieldWrappers         map[string]string      // map of field name-format strings to wrap specific fields in after FieldNameFormat is applied
-	PlaceholderFormat     string                 // if using placeholders, the format string used to insert them
-	PlaceholderArgument   string                 // if specified, either "index", "index1" or "field"
-	NormalizeFields       []string               // a list of field names that should have the NormalizerFormat applied to them and their corresponding values
-	NormalizerFormat      string                 // format string used to wrap fields and value clauses for the purpose of doing fuzzy searches
-	UseInStatement        bool                   // whether multiple values in a criterion should be tested using an IN() statement
-	Distinct              bool                   // whether a DISTINCT clause should be used in SELECT statements
-	Count                 bool                   // whether this query is being used to count rows, which means that SELECT fields are discarded in favor of COUNT(1)
-	TypeMapping           SqlTypeMapping         // provides mapping information between DAL types and native SQL types
-	Type                  SqlStatementType       // what type of SQL statement is being generated
-	InputData             map[string]interface{} // key-value data for statement types that require input data (e.g.: inserts, updates)
-	collection            string
-	fields                []string
-	criteria              []string
-	inputValues           []interface{}
-	values                []interface{}
-	groupBy               []string
-	aggregateBy           []filter.Aggregate
+	TableNameFormat        string                 // format string used to wrap table names
+	FieldNameFormat        string                 // format string used to wrap field names
+	NestedFieldNameFormat  string                 // map of field name-format strings to wrap fields addressing nested map keys. supercedes FieldNameFormat
+	NestedFieldSeparator   string                 // the string used to denote nesting in a nested field name
+	NestedFieldJoiner      string                 // the string used to re-join all but the first value in a nested field when interpolating into NestedFieldNameFormat
+	NestedFieldIndexFormat string                 // if set, the format string used for numeric (array index) parts of a nested field, which are appended without a NestedFieldJoiner
+	FieldWrappers          map[string]string      // map of field name-format strings to wrap specific fields in after FieldNameFormat is applied
+	PlaceholderFormat      string                 // if using placeholders, the format string used to insert them
+	PlaceholderArgument    string                 // if specified, either "index", "index1" or "field"
+	NormalizeFields        []string               // a list of field names that should have the NormalizerFormat applied to them and their corresponding values
+	NormalizerFormat       string                 // format string used to wrap fields and value clauses for the purpose of doing fuzzy searches
+	UseInStatement         bool                   // whether multiple values in a criterion should be tested using an IN() statement
+	OffsetFetch            bool                   // whether to paginate with OFFSET/FETCH NEXT (as SQL Server does) instead of LIMIT/OFFSET
+	UnorderedOffsetFetch   bool                   // whether OFFSET/FETCH NEXT may be used without an ORDER BY clause (as ANSI SQL allows)
+	Mutations              bool                   // whether to update and delete rows with ALTER TABLE mutations (as ClickHouse does) instead of UPDATE and DELETE statements
+	Distinct               bool                   // whether a DISTINCT clause should be used in SELECT statements
+	Count                  bool                   // whether this query is being used to count rows, which means that SELECT fields are discarded in favor of COUNT(1)
+	TypeMapping            SqlTypeMapping         // provides mapping information between DAL types and native SQL types
+	NamingConvention       *dal.NamingConvention  // if set, used to translate the field names given in filters and input data into column names
+	Type                   SqlStatementType       // what type of SQL statement is being generated
+	InputData              map[string]interface{} // key-value data for statement types that require input data (e.g.: inserts, updates)
+	collection             string
+	fields                 []string
+	criteria               []string
+	inputValues            []interface{}
+	values                 []interface{}
+	groupBy                []string
+	aggregateBy            []filter.Aggregate
 }
 
 func NewSqlGenerator() *Sql {
@@ -179,7 +272,12 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 		self.Push([]byte(`SELECT `))
 
 		if self.Count {
-			self.Push([]byte(`COUNT(1) `))
+			// when counting groups, include the values being grouped by alongside each count
+			for _, groupBy := range self.groupBy {
+				self.Push([]byte(self.ToFieldName(groupBy) + `, `))
+			}
+
+			self.Push([]byte(`COUNT(1)`))
 		} else {
 			if self.Distinct {
 				self.Push([]byte(`DISTINCT `))
@@ -224,8 +322,8 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 		self.populateGroupBy()
 
 		if !self.Count {
-			self.populateOrderBy(f)
-			self.populateLimitOffset(f)
+			ordered := self.populateOrderBy(f)
+			self.populateLimitOffset(f, ordered)
 		}
 
 	case SqlInsertStatement:
@@ -253,7 +351,7 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 
 		for i, field := range maputil.StringKeys(self.InputData) {
 			v, _ := self.InputData[field]
-			values = append(values, self.GetPlaceholder(field, i))
+			values = append(values, self.wrapGeometry(v, self.GetPlaceholder(field, i)))
 
 			if vv, err := self.PrepareInputValue(field, v); err == nil {
 				self.inputValues = append(self.inputValues, vv)
@@ -270,9 +368,15 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 			return fmt.Errorf("UPDATE statements must specify input data")
 		}
 
-		self.Push([]byte(`UPDATE `))
-		self.Push([]byte(self.collection))
-		self.Push([]byte(` SET `))
+		if self.Mutations {
+			self.Push([]byte(`ALTER TABLE `))
+			self.Push([]byte(self.collection))
+			self.Push([]byte(` UPDATE `))
+		} else {
+			self.Push([]byte(`UPDATE `))
+			self.Push([]byte(self.collection))
+			self.Push([]byte(` SET `))
+		}
 
 		updatePairs := make([]string, 0)
 
@@ -291,7 +395,7 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 			}
 
 			field := self.ToFieldName(field)
-			updatePairs = append(updatePairs, fmt.Sprintf("%s = %s", field, self.GetPlaceholder(field, i)))
+			updatePairs = append(updatePairs, fmt.Sprintf("%s = %s", field, self.wrapGeometry(value, self.GetPlaceholder(field, i))))
 
 			i += 1
 		}
@@ -301,8 +405,15 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 		self.populateWhereClause()
 
 	case SqlDeleteStatement:
-		self.Push([]byte(`DELETE FROM `))
-		self.Push([]byte(self.collection))
+		if self.Mutations {
+			self.Push([]byte(`ALTER TABLE `))
+			self.Push([]byte(self.collection))
+			self.Push([]byte(` DELETE`))
+		} else {
+			self.Push([]byte(`DELETE FROM `))
+			self.Push([]byte(self.collection))
+		}
+
 		self.populateWhereClause()
 
 	default:
@@ -376,53 +487,15 @@ func (self *Sql) WithCriterion(criterion filter.Criterion) error {
 
 	// for each value being tested in this criterion
 	for _, vI := range criterion.Values {
-		var typedValue interface{}
-
 		value := fmt.Sprintf("%v", vI)
 
 		// convert the value string into the appropriate language-native type
-		if vI == nil || strings.ToUpper(value) == `NULL` {
-			value = strings.ToUpper(value)
-			typedValue = nil
-
+		if typedValue, err := self.criterionValue(criterion, vI); err == nil {
+			self.values = append(self.values, typedValue)
 		} else {
-			var convertErr error
-
-			// type conversion/normalization for values extracted from the criterion
-			switch criterion.Type {
-			case dal.StringType:
-				typedValue, convertErr = stringutil.ConvertTo(stringutil.String, value)
-			case dal.FloatType:
-				typedValue, convertErr = stringutil.ConvertTo(stringutil.Float, value)
-			case dal.IntType:
-				typedValue, convertErr = stringutil.ConvertTo(stringutil.Integer, value)
-			case dal.BooleanType:
-				typedValue, convertErr = stringutil.ConvertTo(stringutil.Boolean, value)
-			case dal.TimeType:
-				typedValue, convertErr = stringutil.ConvertTo(stringutil.Time, value)
-			case dal.ObjectType:
-				typedValue, convertErr = SqlObjectTypeEncode(value)
-			default:
-				typedValue = stringutil.Autotype(value)
-			}
-
-			if convertErr != nil {
-				return convertErr
-			}
+			return err
 		}
 
-		// these operators use a LIKE statement, so we need to add in the right LIKE syntax
-		switch criterion.Operator {
-		case `prefix`:
-			typedValue = fmt.Sprintf("%v", typedValue) + `%%`
-		case `contains`:
-			typedValue = `%%` + fmt.Sprintf("%v", typedValue) + `%%`
-		case `suffix`:
-			typedValue = `%%` + fmt.Sprintf("%v", typedValue)
-		}
-
-		self.values = append(self.values, typedValue)
-
 		// get the syntax-appropriate representation of the value, wrapped in normalization functions
 		// if this field is (or should be treated as) a string.
 		switch strings.ToUpper(value) {
@@ -479,9 +552,10 @@ func (self *Sql) WithCriterion(criterion filter.Criterion) error {
 					}
 				}
 			}
-		case `contains`, `prefix`, `suffix`:
+		case `contains`, `prefix`, `suffix`, `match`, `search`:
 			// wrap the field in any string normalizing functions (the same thing
-			// will happen to the values being compared)
+			// will happen to the values being compared).  full-text searches that reach this point
+			// weren't handled by a search index, and fall back to substring matches.
 			outVal = self.ApplyNormalizer(criterion.Field, outVal) + fmt.Sprintf(` LIKE %s`, self.ApplyNormalizer(criterion.Field, value))
 
 		case `gt`:
@@ -516,6 +590,51 @@ func (self *Sql) WithCriterion(criterion filter.Criterion) error {
 	return nil
 }
 
+// Converts a value from the given criterion into the value that will be bound to its placeholder.
+func (self *Sql) criterionValue(criterion filter.Criterion, vI interface{}) (interface{}, error) {
+	var typedValue interface{}
+
+	value := fmt.Sprintf("%v", vI)
+
+	if vI != nil && strings.ToUpper(value) != `NULL` {
+		var convertErr error
+
+		// type conversion/normalization for values extracted from the criterion
+		switch criterion.Type {
+		case dal.StringType:
+			typedValue, convertErr = stringutil.ConvertTo(stringutil.String, value)
+		case dal.FloatType:
+			typedValue, convertErr = stringutil.ConvertTo(stringutil.Float, value)
+		case dal.IntType:
+			typedValue, convertErr = stringutil.ConvertTo(stringutil.Integer, value)
+		case dal.BooleanType:
+			typedValue, convertErr = stringutil.ConvertTo(stringutil.Boolean, value)
+		case dal.TimeType:
+			typedValue, convertErr = stringutil.ConvertTo(stringutil.Time, value)
+		case dal.ObjectType:
+			typedValue, convertErr = self.encodeObject(value)
+		default:
+			typedValue = stringutil.Autotype(value)
+		}
+
+		if convertErr != nil {
+			return nil, convertErr
+		}
+	}
+
+	// these operators use a LIKE statement, so we need to add in the right LIKE syntax
+	switch criterion.Operator {
+	case `prefix`:
+		typedValue = fmt.Sprintf("%v", typedValue) + `%%`
+	case `contains`, `match`, `search`:
+		typedValue = `%%` + fmt.Sprintf("%v", typedValue) + `%%`
+	case `suffix`:
+		typedValue = `%%` + fmt.Sprintf("%v", typedValue)
+	}
+
+	return typedValue, nil
+}
+
 func (self *Sql) ToTableName(table string) string {
 	return fmt.Sprintf(self.TableNameFormat, table)
 }
@@ -524,14 +643,17 @@ func (self *Sql) ToFieldName(field string) string {
 	var formattedField string
 
 	if field != `` {
-		if nestFmt := self.NestedFieldNameFormat; nestFmt != `` {
-			if parts := strings.Split(field, self.NestedFieldSeparator); len(parts) > 1 {
-				formattedField = fmt.Sprintf(nestFmt, parts[0], strings.Join(parts[1:], self.NestedFieldJoiner))
-			}
+		parts := strings.Split(field, self.NestedFieldSeparator)
+
+		// only the column is renamed, keys within nested fields are left alone
+		parts[0] = self.NamingConvention.FieldName(parts[0])
+
+		if nestFmt := self.NestedFieldNameFormat; nestFmt != `` && len(parts) > 1 {
+			formattedField = fmt.Sprintf(nestFmt, parts[0], self.joinNestedPath(parts[1:]))
 		}
 
 		if formattedField == `` {
-			formattedField = fmt.Sprintf(self.FieldNameFormat, field)
+			formattedField = fmt.Sprintf(self.FieldNameFormat, strings.Join(parts, self.NestedFieldSeparator))
 		}
 	}
 
@@ -542,6 +664,27 @@ func (self *Sql) ToFieldName(field string) string {
 	return formattedField
 }
 
+// joins the parts of a nested field (not including the column) into a path
+func (self *Sql) joinNestedPath(parts []string) string {
+	if self.NestedFieldIndexFormat == `` {
+		return strings.Join(parts, self.NestedFieldJoiner)
+	}
+
+	var path string
+
+	for i, part := range parts {
+		if i == 0 {
+			path = part
+		} else if _, err := strconv.Atoi(part); err == nil {
+			path += fmt.Sprintf(self.NestedFieldIndexFormat, part)
+		} else {
+			path += self.NestedFieldJoiner + part
+		}
+	}
+
+	return path
+}
+
 func (self *Sql) ToAggregatedFieldName(agg filter.Aggregation, field string) string {
 	field = self.ToFieldName(field)
 
@@ -619,6 +762,11 @@ func (self *Sql) ToNativeType(in dal.Type, subtypes []dal.Type, length int) (str
 		out = self.TypeMapping.DateTimeType
 
 	case dal.ObjectType:
+		// native JSON types don't take a length
+		if self.TypeMapping.ObjectTypeIsJson {
+			length = 0
+		}
+
 		if f := self.TypeMapping.MultiSubtypeFormat; f == `` {
 			out = self.TypeMapping.ObjectType
 		} else if len(subtypes) == 2 {
@@ -633,6 +781,12 @@ func (self *Sql) ToNativeType(in dal.Type, subtypes []dal.Type, length int) (str
 	case dal.RawType:
 		out = self.TypeMapping.RawType
 
+	case dal.PointType:
+		out = self.TypeMapping.PointType
+
+	case dal.PolygonType:
+		out = self.TypeMapping.PolygonType
+
 	default:
 		out = strings.ToUpper(in.String())
 	}
@@ -688,7 +842,7 @@ func (self *Sql) GetPlaceholder(fieldName string, fieldIndex int) string {
 }
 
 func (self *Sql) ApplyNormalizer(fieldName string, in string) string {
-	if sliceutil.ContainsString(self.NormalizeFields, fieldName) {
+	if sliceutil.ContainsString(self.NormalizeFields, self.NamingConvention.FieldName(fieldName)) {
 		return fmt.Sprintf(self.NormalizerFormat, in)
 	} else {
 		return in
@@ -701,15 +855,58 @@ func (self *Sql) PrepareInputValue(f string, value interface{}) (interface{}, er
 		return value, nil
 	}
 
+	// geometries are bound as Well-Known Text
+	if geometry, ok := value.(sqlGeometry); ok {
+		return geometry.WKT(), nil
+	}
+
 	switch reflect.ValueOf(value).Kind() {
 	case reflect.Struct, reflect.Map, reflect.Ptr, reflect.Array, reflect.Slice:
-		return SqlObjectTypeEncode(value)
+		if _, ok := value.([]byte); ok {
+			return SqlObjectTypeEncode(value)
+		}
+
+		return self.encodeObject(value)
 	default:
 		return value, nil
 	}
 }
 
+// Implemented by dal.Point and dal.Polygon.
+type sqlGeometry interface {
+	WKT() string
+}
+
+// wraps the placeholder of a geometry value in the function that converts it from Well-Known Text
+func (self *Sql) wrapGeometry(value interface{}, placeholder string) string {
+	if _, ok := value.(sqlGeometry); ok && self.TypeMapping.GeometryFormat != `` {
+		return fmt.Sprintf(self.TypeMapping.GeometryFormat, placeholder)
+	}
+
+	return placeholder
+}
+
+// Encodes a value for storage in an ObjectType column.  Native JSON columns are given the encoded
+// value as a string, since drivers bind byte slices as binary data (which JSON columns reject).
+func (self *Sql) encodeObject(value interface{}) (interface{}, error) {
+	if data, err := SqlObjectTypeEncode(value); err == nil {
+		if self.TypeMapping.ObjectTypeIsJson {
+			return strings.TrimSpace(string(data)), nil
+		}
+
+		return data, nil
+	} else {
+		return nil, err
+	}
+}
+
 func (self *Sql) populateWhereClause() {
+	// mutations must always have a WHERE clause, even when they apply to every row
+	if len(self.criteria) == 0 && self.Mutations && self.Type != SqlSelectStatement {
+		self.Push([]byte(` WHERE 1`))
+		return
+	}
+
 	if len(self.criteria) > 0 {
 		self.Push([]byte(` `))
 
@@ -736,12 +933,17 @@ func (self *Sql) populateGroupBy() {
 	}
 }
 
-func (self *Sql) populateOrderBy(f *filter.Filter) {
+// adds an ORDER BY clause for the filter's sort fields, returning whether one was added
+func (self *Sql) populateOrderBy(f *filter.Filter) bool {
 	if sortFields := sliceutil.CompactString(f.Sort); len(sortFields) > 0 {
-		self.Push([]byte(` ORDER BY `))
-		orderByFields := make([]string, len(sortFields))
+		orderByFields := make([]string, 0)
+
+		for _, sortBy := range f.GetSort() {
+			// SQL results have no relevance score, so they are all equally relevant
+			if sortBy.Field == filter.RelevanceField {
+				continue
+			}
 
-		for i, sortBy := range f.GetSort() {
 			v := self.ToFieldName(sortBy.Field)
 
 			if !sortBy.Descending {
@@ -750,14 +952,37 @@ func (self *Sql) populateOrderBy(f *filter.Filter) {
 				v += ` DESC`
 			}
 
-			orderByFields[i] = v
+			orderByFields = append(orderByFields, v)
 		}
 
-		self.Push([]byte(strings.Join(orderByFields, `, `)))
+		if len(orderByFields) > 0 {
+			self.Push([]byte(` ORDER BY `))
+			self.Push([]byte(strings.Join(orderByFields, `, `)))
+			return true
+		}
 	}
+
+	return false
 }
 
-func (self *Sql) populateLimitOffset(f *filter.Filter) {
+func (self *Sql) populateLimitOffset(f *filter.Filter, ordered bool) {
+	if self.OffsetFetch {
+		if f.Limit > 0 || f.Offset > 0 {
+			// OFFSET/FETCH is part of the ORDER BY clause in SQL Server, so one is required even if unsorted
+			if !ordered && !self.UnorderedOffsetFetch {
+				self.Push([]byte(` ORDER BY (SELECT NULL)`))
+			}
+
+			self.Push([]byte(fmt.Sprintf(" OFFSET %d ROWS", f.Offset)))
+
+			if f.Limit > 0 {
+				self.Push([]byte(fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", f.Limit)))
+			}
+		}
+
+		return
+	}
+
 	if f.Limit > 0 {
 		self.Push([]byte(fmt.Sprintf(" LIMIT %d", f.Limit)))
 
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/pivot/dal"
 	"github.com/ghetzel/pivot/filter"
 	"github.com/stretchr/testify/require"
 )
@@ -565,6 +566,153 @@ func TestSqlTypeMapping(t *testing.T) {
 	)
 }
 
+func TestSqlJsonObjects(t *testing.T) {
+	assert := require.New(t)
+
+	// native JSON columns don't take the object length hint
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	nativeType, err := gen.ToNativeType(dal.ObjectType, nil, 131071)
+	assert.Nil(err)
+	assert.Equal(`JSONB`, nativeType)
+
+	gen.TypeMapping = SqliteTypeMapping
+	nativeType, err = gen.ToNativeType(dal.ObjectType, nil, 131071)
+	assert.Nil(err)
+	assert.Equal(`JSON`, nativeType)
+
+	// objects are bound as JSON text for JSON columns
+	gen = NewSqlGenerator()
+	gen.TypeMapping = MysqlTypeMapping
+	gen.Type = SqlInsertStatement
+	gen.InputData = map[string]interface{}{
+		`properties`: map[string]interface{}{
+			`color`: `red`,
+		},
+	}
+
+	actual, err := filter.Render(gen, `foo`, filter.New())
+	assert.Nil(err)
+	assert.Equal(`INSERT INTO foo (properties) VALUES (?)`, string(actual[:]))
+	assert.Equal([]interface{}{`{"color":"red"}`}, gen.GetValues())
+
+	// nested fields are addressed by path
+	f, err := filter.Parse(`properties.color/red/properties.size.width/gt:4`)
+	assert.Nil(err)
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	gen.FieldNameFormat = "%q"
+	gen.NestedFieldNameFormat = "%q #>> '{%s}'"
+	gen.NestedFieldJoiner = `,`
+
+	actual, err = filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+	assert.Equal(
+		`SELECT * FROM foo `+
+			`WHERE ("properties" #>> '{color}' = ?) `+
+			`AND ("properties" #>> '{size,width}' > ?)`,
+		string(actual[:]),
+	)
+}
+
+func TestSqlGeometry(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	nativeType, err := gen.ToNativeType(dal.PointType, nil, 0)
+	assert.Nil(err)
+	assert.Equal(`GEOMETRY(POINT, 4326)`, nativeType)
+
+	nativeType, err = gen.ToNativeType(dal.PolygonType, nil, 0)
+	assert.Nil(err)
+	assert.Equal(`GEOMETRY(POLYGON, 4326)`, nativeType)
+
+	// geometries are bound as WKT and converted by the database
+	gen = NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	gen.PlaceholderFormat = `$%d`
+	gen.PlaceholderArgument = `index1`
+	gen.Type = SqlInsertStatement
+	gen.InputData = map[string]interface{}{
+		`location`: dal.Point{Latitude: 40.6892, Longitude: -74.0445},
+	}
+
+	actual, err := filter.Render(gen, `foo`, filter.New())
+	assert.Nil(err)
+	assert.Equal(`INSERT INTO foo (location) VALUES (ST_GeomFromText($1, 4326))`, string(actual[:]))
+	assert.Equal([]interface{}{`POINT(-74.0445 40.6892)`}, gen.GetValues())
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	gen.Type = SqlUpdateStatement
+	gen.InputData = map[string]interface{}{
+		`area`: dal.Polygon{{0, 0}, {0, 1}, {1, 1}},
+	}
+
+	actual, err = filter.Render(gen, `foo`, filter.New())
+	assert.Nil(err)
+	assert.Equal(`UPDATE foo SET area = ST_GeomFromText(?, 4326)`, string(actual[:]))
+	assert.Equal([]interface{}{`POLYGON((0 0, 1 0, 1 1, 0 0))`}, gen.GetValues())
+
+	// without a conversion function, geometries are stored as WKT
+	gen = NewSqlGenerator()
+	gen.TypeMapping = SqliteTypeMapping
+	gen.Type = SqlInsertStatement
+	gen.InputData = map[string]interface{}{
+		`location`: dal.Point{Latitude: 1, Longitude: 2},
+	}
+
+	actual, err = filter.Render(gen, `foo`, filter.New())
+	assert.Nil(err)
+	assert.Equal(`INSERT INTO foo (location) VALUES (?)`, string(actual[:]))
+	assert.Equal([]interface{}{`POINT(2 1)`}, gen.GetValues())
+}
+
+func TestSqlNestedFieldIndex(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := filter.Parse(`properties.tags.0/red/properties.sizes.1.width/gt:4`)
+	assert.Nil(err)
+
+	gen := NewSqlGenerator()
+	gen.FieldNameFormat = "`%s`"
+	gen.NestedFieldNameFormat = "JSON_UNQUOTE(JSON_EXTRACT(`%s`, '$.%s'))"
+	gen.NestedFieldIndexFormat = `[%s]`
+
+	actual, err := filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+	assert.Equal(
+		"SELECT * FROM foo "+
+			"WHERE (JSON_UNQUOTE(JSON_EXTRACT(`properties`, '$.tags[0]')) = ?) "+
+			"AND (JSON_UNQUOTE(JSON_EXTRACT(`properties`, '$.sizes[1].width')) > ?)",
+		string(actual[:]),
+	)
+}
+
+func TestSqlNamingConvention(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := filter.Parse(`FirstName/bob/login_count/gt:2/Properties.colorName/red`)
+	assert.Nil(err)
+
+	gen := NewSqlGenerator()
+	gen.NamingConvention = &dal.NamingConvention{
+		Case: dal.SnakeCase,
+	}
+
+	actual, err := filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+	assert.Equal(
+		`SELECT * FROM foo `+
+			`WHERE (first_name = ?) `+
+			`AND (login_count > ?) `+
+			`AND (properties.colorName = ?)`,
+		string(actual[:]),
+	)
+}
+
 func TestSqlFieldQuoting(t *testing.T) {
 	assert := require.New(t)
 
@@ -796,6 +944,21 @@ func TestSqlSorting(t *testing.T) {
 	assert.Nil(err)
 
 	assert.Equal(`SELECT * FROM foo ORDER BY name ASC, age DESC`, string(sql[:]))
+
+	// relevance is not a column, so it doesn't affect the ordering
+	f.Sort = []string{`-_score`, `+name`}
+	gen = NewSqlGenerator()
+
+	sql, err = filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+	assert.Equal(`SELECT * FROM foo ORDER BY name ASC`, string(sql[:]))
+
+	f.Sort = []string{`-_score`}
+	gen = NewSqlGenerator()
+
+	sql, err = filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+	assert.Equal(`SELECT * FROM foo`, string(sql[:]))
 }
 
 func TestSqlLimitOffset(t *testing.T) {
@@ -817,6 +980,89 @@ func TestSqlLimitOffset(t *testing.T) {
 	assert.Equal(`SELECT * FROM foo LIMIT 4 OFFSET 12`, string(sql[:]))
 }
 
+func TestSqlOffsetFetch(t *testing.T) {
+	assert := require.New(t)
+
+	f := filter.All()
+	f.Limit = 4
+	gen := NewSqlGenerator()
+	gen.OffsetFetch = true
+	sql, err := filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+	assert.Equal(`SELECT * FROM foo ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 4 ROWS ONLY`, string(sql[:]))
+
+	f = filter.All()
+	f.Sort = []string{`-age`}
+	f.Limit = 4
+	f.Offset = 12
+	gen = NewSqlGenerator()
+	gen.OffsetFetch = true
+	sql, err = filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+	assert.Equal(`SELECT * FROM foo ORDER BY age DESC OFFSET 12 ROWS FETCH NEXT 4 ROWS ONLY`, string(sql[:]))
+
+	f = filter.All()
+	f.Sort = []string{`name`}
+	gen = NewSqlGenerator()
+	gen.OffsetFetch = true
+	sql, err = filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+	assert.Equal(`SELECT * FROM foo ORDER BY name ASC`, string(sql[:]))
+
+	f = filter.All()
+	f.Limit = 4
+	f.Offset = 8
+	gen = NewSqlGenerator()
+	gen.OffsetFetch = true
+	gen.UnorderedOffsetFetch = true
+	sql, err = filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+	assert.Equal(`SELECT * FROM foo OFFSET 8 ROWS FETCH NEXT 4 ROWS ONLY`, string(sql[:]))
+}
+
+func TestSqlMutations(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := filter.Parse(`id/42`)
+	assert.NoError(err)
+
+	gen := NewSqlGenerator()
+	gen.Mutations = true
+	gen.Type = SqlUpdateStatement
+	gen.InputData = map[string]interface{}{
+		`name`: `ted`,
+		`age`:  7,
+	}
+
+	sql, err := filter.Render(gen, `foo`, f)
+	assert.NoError(err)
+	assert.Equal(`ALTER TABLE foo UPDATE age = ?, name = ? WHERE (id = ?)`, string(sql[:]))
+
+	gen = NewSqlGenerator()
+	gen.Mutations = true
+	gen.Type = SqlDeleteStatement
+
+	sql, err = filter.Render(gen, `foo`, f)
+	assert.NoError(err)
+	assert.Equal(`ALTER TABLE foo DELETE WHERE (id = ?)`, string(sql[:]))
+
+	gen = NewSqlGenerator()
+	gen.Mutations = true
+	gen.Type = SqlDeleteStatement
+
+	sql, err = filter.Render(gen, `foo`, filter.All())
+	assert.NoError(err)
+	assert.Equal(`ALTER TABLE foo DELETE WHERE 1`, string(sql[:]))
+
+	// selects are unaffected
+	gen = NewSqlGenerator()
+	gen.Mutations = true
+
+	sql, err = filter.Render(gen, `foo`, filter.All())
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM foo`, string(sql[:]))
+}
+
 func TestSqlSelectFull(t *testing.T) {
 	assert := require.New(t)
 
@@ -939,6 +1185,25 @@ func TestSqlSelectGroupBy(t *testing.T) {
 	)
 }
 
+func TestSqlSelectCountGroupBy(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := filter.Parse(`age/gt:21`)
+	assert.Nil(err)
+
+	gen := NewSqlGenerator()
+	gen.Count = true
+	gen.GroupByField(`state`)
+
+	sql, err := filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+
+	assert.Equal(
+		`SELECT state, COUNT(1) FROM foo WHERE (age > ?) GROUP BY state`,
+		string(sql[:]),
+	)
+}
+
 func TestSqlBulkDelete(t *testing.T) {
 	assert := require.New(t)
 
@@ -988,3 +1253,45 @@ func TestSqlBulkDeleteWithNormalizers(t *testing.T) {
 		`Steve`,
 	}, gen.GetValues())
 }
+
+func TestSqlStatementCache(t *testing.T) {
+	assert := require.New(t)
+
+	cache := NewSqlStatementCache(2)
+
+	stmt, values, err := cache.Render(NewSqlGenerator(), `foo`, filter.MustParse(`age/gt:21/name/prefix:Bob`))
+	assert.Nil(err)
+	assert.Equal(`SELECT * FROM foo WHERE (age > ?) AND (name LIKE ?)`, string(stmt[:]))
+	assert.Equal([]interface{}{int64(21), `Bob%%`}, values)
+
+	stmt, values, err = cache.Render(NewSqlGenerator(), `foo`, filter.MustParse(`age/gt:30/name/prefix:Alice`))
+	assert.Nil(err)
+	assert.Equal(`SELECT * FROM foo WHERE (age > ?) AND (name LIKE ?)`, string(stmt[:]))
+	assert.Equal([]interface{}{int64(30), `Alice%%`}, values)
+
+	hits, misses := cache.Stats()
+	assert.Equal(int64(1), hits)
+	assert.Equal(int64(1), misses)
+
+	// a different number of values is a different statement
+	stmt, values, err = cache.Render(NewSqlGenerator(), `foo`, filter.MustParse(`age/1|2|3`))
+	assert.Nil(err)
+	assert.Equal(`SELECT * FROM foo WHERE (age IN(?, ?, ?))`, string(stmt[:]))
+	assert.Equal([]interface{}{int64(1), int64(2), int64(3)}, values)
+
+	hits, misses = cache.Stats()
+	assert.Equal(int64(1), hits)
+	assert.Equal(int64(2), misses)
+
+	// non-SELECT statements are never cached
+	gen := NewSqlGenerator()
+	gen.Type = SqlDeleteStatement
+
+	stmt, _, err = cache.Render(gen, `foo`, filter.MustParse(`id/1`))
+	assert.Nil(err)
+	assert.Equal(`DELETE FROM foo WHERE (id = ?)`, string(stmt[:]))
+
+	hits, misses = cache.Stats()
+	assert.Equal(int64(1), hits)
+	assert.Equal(int64(2), misses)
+}
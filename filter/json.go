@@ -0,0 +1,126 @@
+package filter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ghetzel/pivot/dal"
+)
+
+var cursorPrefix = `offset:`
+
+// The JSON representation of a Filter.
+type jsonFilter struct {
+	All         bool                   `json:"all,omitempty"`
+	Criteria    []Criterion            `json:"criteria,omitempty"`
+	Sort        []string               `json:"sort,omitempty"`
+	Fields      []string               `json:"fields,omitempty"`
+	Limit       int                    `json:"limit,omitempty"`
+	Offset      int                    `json:"offset,omitempty"`
+	Cursor      string                 `json:"cursor,omitempty"`
+	Options     map[string]interface{} `json:"options,omitempty"`
+	Parallelism int                    `json:"parallelism,omitempty"`
+}
+
+// Parses a JSON-encoded filter of the form:
+//
+//	{
+//	    "criteria": [{"field": "name", "operator": "prefix", "values": ["a"]}],
+//	    "sort":     ["-created_at"],
+//	    "fields":   ["name", "created_at"],
+//	    "limit":    25,
+//	    "cursor":   "b2Zmc2V0OjI1"
+//	}
+//
+// If a cursor is given, it takes precedence over the offset.
+//
+// This is a separate wire format rather than Filter's own JSON encoding, so that types which embed
+// a Filter keep their existing serialization.
+func FromJSON(data []byte) (*Filter, error) {
+	var in jsonFilter
+
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+
+	f := New()
+
+	for i, criterion := range in.Criteria {
+		if criterion.Field == `` {
+			return nil, fmt.Errorf("criterion %d: must specify a field", i)
+		}
+
+		if criterion.Type == `` {
+			in.Criteria[i].Type = dal.AutoType
+		}
+	}
+
+	f.Criteria = in.Criteria
+	f.Sort = in.Sort
+	f.Fields = in.Fields
+	f.Limit = in.Limit
+	f.Offset = in.Offset
+	f.Parallelism = in.Parallelism
+
+	for k, v := range in.Options {
+		f.Options[k] = v
+	}
+
+	if in.All || len(f.Criteria) == 0 {
+		f.MatchAll = true
+		f.Spec = AllValue
+	} else {
+		f.Spec = f.String()
+	}
+
+	if in.Cursor != `` {
+		if offset, err := ParseCursor(in.Cursor); err == nil {
+			f.Offset = offset
+		} else {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// Encodes the filter in the form accepted by FromJSON.
+func (self *Filter) ToJSON() ([]byte, error) {
+	return json.Marshal(&jsonFilter{
+		All:         self.IsMatchAll(),
+		Criteria:    self.Criteria,
+		Sort:        self.Sort,
+		Fields:      self.Fields,
+		Limit:       self.Limit,
+		Offset:      self.Offset,
+		Options:     self.Options,
+		Parallelism: self.Parallelism,
+	})
+}
+
+// Returns an opaque cursor that can be used to retrieve the next page of results for this filter.
+func (self *Filter) NextCursor() string {
+	if self.Limit <= 0 {
+		return ``
+	}
+
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(cursorPrefix + strconv.Itoa(self.Offset+self.Limit)),
+	)
+}
+
+// Parses a cursor returned from NextCursor.
+func ParseCursor(cursor string) (int, error) {
+	if data, err := base64.RawURLEncoding.DecodeString(cursor); err == nil {
+		if v := string(data); strings.HasPrefix(v, cursorPrefix) {
+			if offset, err := strconv.Atoi(strings.TrimPrefix(v, cursorPrefix)); err == nil && offset >= 0 {
+				return offset, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
+}
@@ -0,0 +1,102 @@
+package pivot
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ghetzel/pivot/backends"
+)
+
+var HealthCheckTimeout = time.Duration(5) * time.Second
+
+type ComponentHealth struct {
+	Type      string                 `json:"type"`
+	Address   string                 `json:"address,omitempty"`
+	Reachable bool                   `json:"reachable"`
+	Error     string                 `json:"error,omitempty"`
+	Latency   string                 `json:"latency,omitempty"`
+	Pool      map[string]interface{} `json:"pool,omitempty"`
+	Queued    *int                   `json:"queued,omitempty"`
+}
+
+type HealthReport struct {
+	Ready   bool             `json:"ready"`
+	Uptime  string           `json:"uptime"`
+	Backend *ComponentHealth `json:"backend,omitempty"`
+	Indexer *ComponentHealth `json:"indexer,omitempty"`
+}
+
+// Checks the reachability of the server's backend and indexer, and gathers connection pool and
+// indexing queue statistics where the backend supports reporting them.
+func (self *Server) Health() *HealthReport {
+	report := &HealthReport{
+		Uptime: time.Since(self.startedAt).Round(time.Second).String(),
+	}
+
+	if self.backend == nil {
+		return report
+	}
+
+	report.Ready = true
+	report.Backend = &ComponentHealth{
+		Type: self.backend.GetConnectionString().Backend(),
+	}
+
+	started := time.Now()
+
+	if err := self.backend.Ping(HealthCheckTimeout); err == nil {
+		report.Backend.Reachable = true
+		report.Backend.Latency = time.Since(started).String()
+	} else {
+		report.Backend.Error = err.Error()
+		report.Ready = false
+	}
+
+	if statter, ok := self.backend.(backends.PoolStatter); ok {
+		report.Backend.Pool = statter.PoolStats()
+	}
+
+	if indexer := self.backend.WithSearch(nil, nil); indexer != nil {
+		report.Indexer = &ComponentHealth{
+			Reachable: true,
+		}
+
+		if cs := indexer.IndexConnectionString(); cs != nil {
+			report.Indexer.Type = cs.Backend()
+		}
+
+		if pinger, ok := indexer.(backends.IndexPinger); ok {
+			started := time.Now()
+
+			if err := pinger.IndexPing(HealthCheckTimeout); err == nil {
+				report.Indexer.Latency = time.Since(started).String()
+			} else {
+				report.Indexer.Reachable = false
+				report.Indexer.Error = err.Error()
+				report.Ready = false
+			}
+		}
+
+		if queuer, ok := indexer.(backends.IndexQueuer); ok {
+			depth := queuer.IndexQueueDepth()
+			report.Indexer.Queued = &depth
+		}
+	}
+
+	return report
+}
+
+// Liveness probe: responds successfully as long as the server is able to handle requests, and
+// includes the current health report for diagnostic purposes.
+func (self *Server) handleHealthz(w http.ResponseWriter, req *http.Request) {
+	respond(w, req, self.Health())
+}
+
+// Readiness probe: responds with 503 Service Unavailable if the backend or indexer are unreachable.
+func (self *Server) handleReadyz(w http.ResponseWriter, req *http.Request) {
+	if report := self.Health(); report.Ready {
+		respond(w, req, report)
+	} else {
+		respond(w, req, report, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,68 @@
+package pivot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	assert := require.New(t)
+	server, mock, _ := newTestServer()
+
+	report := server.Health()
+	assert.True(report.Ready)
+	assert.NotNil(report.Backend)
+	assert.Equal(`mock`, report.Backend.Type)
+	assert.True(report.Backend.Reachable)
+	assert.NotEmpty(report.Backend.Latency)
+	assert.NotNil(report.Indexer)
+	assert.True(report.Indexer.Reachable)
+
+	// an unreachable backend makes the server unready
+	mock.FailWith(`Ping`, fmt.Errorf("connection refused"))
+
+	report = server.Health()
+	assert.False(report.Ready)
+	assert.False(report.Backend.Reachable)
+	assert.Equal(`connection refused`, report.Backend.Error)
+
+	// as does having no backend at all
+	server.backend = nil
+	assert.False(server.Health().Ready)
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	assert := require.New(t)
+	server, mock, _ := newTestServer()
+
+	for _, probe := range []http.HandlerFunc{server.handleHealthz, server.handleReadyz} {
+		w := httptest.NewRecorder()
+		probe(w, httptest.NewRequest(`GET`, `/`, nil))
+		assert.Equal(http.StatusOK, w.Code)
+
+		var report HealthReport
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &report))
+		assert.True(report.Ready)
+	}
+
+	mock.FailWith(`Ping`, fmt.Errorf("connection refused"))
+
+	// liveness doesn't depend on the backend, but readiness does
+	w := httptest.NewRecorder()
+	server.handleHealthz(w, httptest.NewRequest(`GET`, `/healthz`, nil))
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	server.handleReadyz(w, httptest.NewRequest(`GET`, `/readyz`, nil))
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+
+	var report HealthReport
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	assert.False(report.Ready)
+	assert.Equal(`connection refused`, report.Backend.Error)
+}
@@ -26,6 +26,7 @@ type Mapper interface {
 	Get(id interface{}, into interface{}) error
 	Update(from interface{}) error
 	CreateOrUpdate(id interface{}, from interface{}) error
+	Save(from interface{}) error
 	Delete(ids ...interface{}) error
 	Find(flt interface{}, into interface{}) error
 	FindFunc(flt interface{}, destZeroValue interface{}, resultFn ResultFunc) error
@@ -33,6 +34,7 @@ type Mapper interface {
 	Each(destZeroValue interface{}, resultFn ResultFunc) error
 	List(fields []string) (map[string][]interface{}, error)
 	ListWithFilter(fields []string, flt interface{}) (map[string][]interface{}, error)
+	Facets(fields []string, flt interface{}) (map[string][]backends.FacetBucket, error)
 	Sum(field string, flt interface{}) (float64, error)
 	Count(flt interface{}) (uint64, error)
 	Minimum(field string, flt interface{}) (float64, error)
@@ -177,6 +179,21 @@ func (self *Model) CreateOrUpdate(id interface{}, from interface{}) error {
 	}
 }
 
+// Creates or updates an instance of the model from the given struct or dal.Record, depending on
+// whether a record with its ID already exists.
+//
+func (self *Model) Save(from interface{}) error {
+	if record, err := self.collection.MakeRecord(from); err == nil {
+		if record.ID != nil && self.Exists(record.ID) {
+			return self.db.Update(self.collection.Name, dal.NewRecordSet(record))
+		} else {
+			return self.db.Insert(self.collection.Name, dal.NewRecordSet(record))
+		}
+	} else {
+		return err
+	}
+}
+
 // Delete instances of the model identified by the given IDs
 //
 func (self *Model) Delete(ids ...interface{}) error {
@@ -200,7 +217,7 @@ func (self *Model) Find(flt interface{}, into interface{}) error {
 				return err
 			}
 		} else {
-			return fmt.Errorf("backend %T does not support searching", self.db)
+			return dal.Unsupported("backend %T does not support searching", self.db)
 		}
 	} else {
 		return err
@@ -241,7 +258,7 @@ func (self *Model) FindFunc(flt interface{}, destZeroValue interface{}, resultFn
 
 			return err
 		} else {
-			return fmt.Errorf("backend %T does not support searching", self.db)
+			return dal.Unsupported("backend %T does not support searching", self.db)
 		}
 	} else {
 		return err
@@ -267,7 +284,21 @@ func (self *Model) ListWithFilter(fields []string, flt interface{}) (map[string]
 		if search := self.db.WithSearch(self.collection, f); search != nil {
 			return search.ListValues(self.collection, fields, f)
 		} else {
-			return nil, fmt.Errorf("backend %T does not support searching", self.db)
+			return nil, dal.Unsupported("backend %T does not support searching", self.db)
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *Model) Facets(fields []string, flt interface{}) (map[string][]backends.FacetBucket, error) {
+	if f, err := self.filterFromInterface(flt); err == nil {
+		f.IdentityField = self.collection.IdentityField
+
+		if search := self.db.WithSearch(self.collection, f); search != nil {
+			return search.Facets(self.collection, fields, f)
+		} else {
+			return nil, dal.Unsupported("backend %T does not support searching", self.db)
 		}
 	} else {
 		return nil, err
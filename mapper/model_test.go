@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/ghetzel/pivot"
+	"github.com/ghetzel/pivot/backends"
 	"github.com/ghetzel/pivot/dal"
 	"github.com/stretchr/testify/require"
 )
@@ -226,3 +227,43 @@ func TestModelList(t *testing.T) {
 		},
 	}, values)
 }
+
+func TestModelSave(t *testing.T) {
+	assert := require.New(t)
+
+	db := backends.NewMockBackend()
+
+	type ModelThree struct {
+		ID   int
+		Name string `pivot:"name"`
+	}
+
+	model := NewModel(db, &dal.Collection{
+		Name: `model_three`,
+		Fields: []dal.Field{
+			{
+				Name: `name`,
+				Type: dal.StringType,
+			},
+		},
+	})
+
+	assert.Nil(model.Migrate())
+
+	assert.Nil(model.Save(&ModelThree{
+		ID:   1,
+		Name: `first`,
+	}))
+
+	assert.Nil(model.Save(&ModelThree{
+		ID:   1,
+		Name: `second`,
+	}))
+
+	v := new(ModelThree)
+	assert.Nil(model.Get(1, v))
+	assert.Equal(`second`, v.Name)
+
+	assert.Len(db.CallsTo(`Insert`), 1)
+	assert.Len(db.CallsTo(`Update`), 1)
+}
@@ -0,0 +1,381 @@
+package pivot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+)
+
+var MigrationsCollection = `_pivot_migrations`
+var MigrationLockCollection = `_pivot_migration_lock`
+var MigrationLockTTL = time.Duration(15) * time.Minute
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([^/]+)\.json$`)
+
+const migrationLockID = `lock`
+
+// A single change made by a migration.  Exactly one of the fields should be set.
+type MigrationStep struct {
+	// Create a collection with the given definition.
+	Create *dal.Collection `json:"create,omitempty"`
+
+	// Change an existing collection's schema to match the given definition.
+	Alter *dal.Collection `json:"alter,omitempty"`
+
+	// Delete the named collection and all of its records.
+	Drop string `json:"drop,omitempty"`
+
+	// Insert, update, or delete records.
+	Insert *MigrationRecords `json:"insert,omitempty"`
+	Update *MigrationRecords `json:"update,omitempty"`
+	Delete *MigrationRecords `json:"delete,omitempty"`
+}
+
+type MigrationRecords struct {
+	Collection string        `json:"collection"`
+	Records    []*dal.Record `json:"records,omitempty"`
+	IDs        []interface{} `json:"ids,omitempty"`
+}
+
+// A versioned set of changes, loaded from a file named VERSION_NAME.json (e.g.:
+// "0003_add_user_roles.json") containing the steps to apply ("up") and revert ("down") it.
+type Migration struct {
+	Version  int64           `json:"-"`
+	Name     string          `json:"-"`
+	Filename string          `json:"-"`
+	Up       []MigrationStep `json:"up"`
+	Down     []MigrationStep `json:"down"`
+}
+
+type MigrationStatus struct {
+	Migration *Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Loads all migration files in the given directory, ordered by version.
+func LoadMigrations(dir string) ([]*Migration, error) {
+	entries, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]*Migration, 0)
+	versions := make(map[int64]string)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid version: %v", entry.Name(), err)
+		}
+
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("%s: version %d is already used by %s", entry.Name(), version, other)
+		}
+
+		filename := filepath.Join(dir, entry.Name())
+		migration := &Migration{
+			Version:  version,
+			Name:     match[2],
+			Filename: filename,
+		}
+
+		if data, err := ioutil.ReadFile(filename); err == nil {
+			if err := json.Unmarshal(data, migration); err != nil {
+				return nil, fmt.Errorf("%s: %v", entry.Name(), err)
+			}
+		} else {
+			return nil, err
+		}
+
+		versions[version] = entry.Name()
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Applies and reverts migrations against a backend, recording which versions have been applied in
+// the MigrationsCollection.
+type Migrator struct {
+	Migrations []*Migration
+	Owner      string
+	db         backends.Backend
+}
+
+func NewMigrator(db backends.Backend, migrations []*Migration) *Migrator {
+	owner := fmt.Sprintf("%d", os.Getpid())
+
+	if hostname, err := os.Hostname(); err == nil {
+		owner = hostname + `:` + owner
+	}
+
+	return &Migrator{
+		Migrations: migrations,
+		Owner:      owner,
+		db:         db,
+	}
+}
+
+func (self *Migrator) ensureCollection(collection *dal.Collection) error {
+	if _, err := self.db.GetCollection(collection.Name); err == nil {
+		return nil
+	} else if dal.IsCollectionNotFoundErr(err) {
+		return self.db.CreateCollection(collection)
+	} else {
+		return err
+	}
+}
+
+func (self *Migrator) ensureCollections() error {
+	if err := self.ensureCollection(dal.NewCollection(MigrationsCollection).SetIdentity(
+		`version`, dal.IntType, nil, nil,
+	).AddFields(dal.Field{
+		Name:     `name`,
+		Type:     dal.StringType,
+		Required: true,
+	}, dal.Field{
+		Name:     `applied_at`,
+		Type:     dal.TimeType,
+		Required: true,
+	})); err != nil {
+		return err
+	}
+
+	return self.ensureCollection(dal.NewCollection(MigrationLockCollection).SetIdentity(
+		`id`, dal.StringType, nil, nil,
+	).AddFields(dal.Field{
+		Name:     `owner`,
+		Type:     dal.StringType,
+		Required: true,
+	}, dal.Field{
+		Name:     `acquired_at`,
+		Type:     dal.TimeType,
+		Required: true,
+	}))
+}
+
+// Acquires the migration lock, ensuring that only one process can apply migrations at a time.
+// Locks older than MigrationLockTTL are assumed to have been abandoned and are taken over.
+func (self *Migrator) Lock() error {
+	if err := self.ensureCollections(); err != nil {
+		return err
+	}
+
+	if lock, err := self.db.Retrieve(MigrationLockCollection, migrationLockID); err == nil {
+		acquiredAt, _ := stringutil.ConvertToTime(lock.Get(`acquired_at`))
+
+		if time.Since(acquiredAt) < MigrationLockTTL {
+			return fmt.Errorf(
+				"migrations are locked by %v (since %v)",
+				lock.Get(`owner`),
+				acquiredAt.Format(time.RFC3339),
+			)
+		}
+
+		log.Warningf("taking over stale migration lock held by %v", lock.Get(`owner`))
+
+		if err := self.db.Delete(MigrationLockCollection, migrationLockID); err != nil {
+			return err
+		}
+	} else if !dal.IsNotExistError(err) {
+		return err
+	}
+
+	// the insert will fail if another process acquired the lock in the meantime
+	if err := self.db.Insert(MigrationLockCollection, dal.NewRecordSet(
+		dal.NewRecord(migrationLockID).SetFields(map[string]interface{}{
+			`owner`:       self.Owner,
+			`acquired_at`: time.Now(),
+		}),
+	)); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+
+	// verify that we're the ones holding it
+	if lock, err := self.db.Retrieve(MigrationLockCollection, migrationLockID); err == nil {
+		if owner := lock.GetString(`owner`); owner != self.Owner {
+			return fmt.Errorf("migrations are locked by %v", owner)
+		}
+	} else {
+		return err
+	}
+
+	return nil
+}
+
+// Releases the migration lock.
+func (self *Migrator) Unlock() error {
+	return self.db.Delete(MigrationLockCollection, migrationLockID)
+}
+
+// Returns each known migration and whether it has been applied.
+func (self *Migrator) Status() ([]MigrationStatus, error) {
+	if err := self.ensureCollections(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(self.Migrations))
+
+	for i, migration := range self.Migrations {
+		statuses[i].Migration = migration
+
+		if record, err := self.db.Retrieve(MigrationsCollection, migration.Version); err == nil {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt, _ = stringutil.ConvertToTime(record.Get(`applied_at`))
+		} else if !dal.IsNotExistError(err) {
+			return nil, err
+		}
+	}
+
+	return statuses, nil
+}
+
+// Applies up to the given number of pending migrations (or all of them if steps is zero) in order,
+// returning the ones that were applied.
+func (self *Migrator) Up(steps int) ([]*Migration, error) {
+	statuses, err := self.Status()
+
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]*Migration, 0)
+
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		} else if steps > 0 && len(applied) >= steps {
+			break
+		}
+
+		if err := self.apply(status.Migration.Up); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %v", status.Migration.Version, status.Migration.Name, err)
+		}
+
+		if err := self.db.Insert(MigrationsCollection, dal.NewRecordSet(
+			dal.NewRecord(status.Migration.Version).SetFields(map[string]interface{}{
+				`name`:       status.Migration.Name,
+				`applied_at`: time.Now(),
+			}),
+		)); err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, status.Migration)
+	}
+
+	return applied, nil
+}
+
+// Reverts up to the given number of applied migrations (or all of them if steps is zero), most
+// recent first, returning the ones that were reverted.
+func (self *Migrator) Down(steps int) ([]*Migration, error) {
+	statuses, err := self.Status()
+
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := make([]*Migration, 0)
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+
+		if !status.Applied {
+			continue
+		} else if steps > 0 && len(reverted) >= steps {
+			break
+		}
+
+		if err := self.apply(status.Migration.Down); err != nil {
+			return reverted, fmt.Errorf("migration %d (%s): %v", status.Migration.Version, status.Migration.Name, err)
+		}
+
+		if err := self.db.Delete(MigrationsCollection, status.Migration.Version); err != nil {
+			return reverted, err
+		}
+
+		reverted = append(reverted, status.Migration)
+	}
+
+	return reverted, nil
+}
+
+func (self *Migrator) apply(steps []MigrationStep) error {
+	for i, step := range steps {
+		var err error
+
+		switch {
+		case step.Create != nil:
+			err = self.db.CreateCollection(step.Create)
+
+		case step.Alter != nil:
+			err = self.alter(step.Alter)
+
+		case step.Drop != ``:
+			err = self.db.DeleteCollection(step.Drop)
+
+		case step.Insert != nil:
+			err = self.db.Insert(step.Insert.Collection, dal.NewRecordSet(step.Insert.Records...))
+
+		case step.Update != nil:
+			err = self.db.Update(step.Update.Collection, dal.NewRecordSet(step.Update.Records...))
+
+		case step.Delete != nil:
+			err = self.db.Delete(step.Delete.Collection, step.Delete.IDs...)
+
+		default:
+			err = fmt.Errorf("no operation specified")
+		}
+
+		if err != nil {
+			return fmt.Errorf("step %d: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (self *Migrator) alter(definition *dal.Collection) error {
+	migratable, ok := self.db.(backends.Migratable)
+
+	if !ok {
+		return dal.Unsupported("Backend %T does not support altering collections", self.db)
+	}
+
+	if actual, err := self.db.GetCollection(definition.Name); err == nil {
+		if deltas := definition.Diff(actual); len(deltas) > 0 {
+			return migratable.Migrate(deltas)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
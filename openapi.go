@@ -0,0 +1,302 @@
+package pivot
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/util"
+)
+
+var OpenAPIVersion = `3.0.0`
+
+// Generates an OpenAPI 3 specification document describing the API endpoints for all collections
+// registered with the given backend.
+func GenerateOpenAPI(backend backends.Backend) (map[string]interface{}, error) {
+	paths := make(map[string]interface{})
+	schemas := map[string]interface{}{
+		`Error`: map[string]interface{}{
+			`type`: `object`,
+			`properties`: map[string]interface{}{
+				`error`: map[string]interface{}{
+					`type`: `string`,
+				},
+			},
+		},
+	}
+
+	if names, err := backend.ListCollections(); err == nil {
+		for _, name := range names {
+			if collection, err := backend.GetCollection(name); err == nil {
+				schemas[name] = openApiSchemaForCollection(collection)
+				schemas[name+`Record`] = openApiRecordSchema(name)
+				schemas[name+`RecordSet`] = openApiRecordSetSchema(name)
+
+				for path, item := range openApiPathsForCollection(name, collection) {
+					paths[path] = item
+				}
+			} else {
+				return nil, fmt.Errorf("collection %q: %v", name, err)
+			}
+		}
+	} else {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		`openapi`: OpenAPIVersion,
+		`info`: map[string]interface{}{
+			`title`:       util.ApplicationName,
+			`description`: util.ApplicationSummary,
+			`version`:     util.ApplicationVersion,
+		},
+		`paths`: paths,
+		`components`: map[string]interface{}{
+			`schemas`: schemas,
+		},
+	}, nil
+}
+
+func openApiSchemaForCollection(collection *dal.Collection) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	for _, field := range collection.Fields {
+		properties[field.Name] = openApiSchemaForField(&field)
+
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		`type`:       `object`,
+		`properties`: properties,
+	}
+
+	if len(required) > 0 {
+		schema[`required`] = required
+	}
+
+	return schema
+}
+
+func openApiSchemaForField(field *dal.Field) map[string]interface{} {
+	schema := openApiSchemaForType(field.Type)
+
+	if field.Description != `` {
+		schema[`description`] = field.Description
+	}
+
+	if field.Hidden || field.Redacted {
+		schema[`writeOnly`] = true
+	}
+
+	if field.Length > 0 && field.Type == dal.StringType {
+		schema[`maxLength`] = field.Length
+	}
+
+	if field.DefaultValue != nil {
+		if v := field.GetDefaultValue(); v != nil {
+			schema[`default`] = v
+		}
+	}
+
+	return schema
+}
+
+func openApiSchemaForType(t dal.Type) map[string]interface{} {
+	switch t {
+	case dal.StringType:
+		return map[string]interface{}{`type`: `string`}
+	case dal.BooleanType:
+		return map[string]interface{}{`type`: `boolean`}
+	case dal.IntType:
+		return map[string]interface{}{`type`: `integer`, `format`: `int64`}
+	case dal.FloatType:
+		return map[string]interface{}{`type`: `number`, `format`: `double`}
+	case dal.TimeType:
+		return map[string]interface{}{`type`: `string`, `format`: `date-time`}
+	case dal.ObjectType:
+		return map[string]interface{}{`type`: `object`}
+	case dal.RawType:
+		return map[string]interface{}{`type`: `string`, `format`: `byte`}
+	case dal.PointType:
+		return map[string]interface{}{
+			`type`: `object`,
+			`properties`: map[string]interface{}{
+				`lat`: map[string]interface{}{`type`: `number`},
+				`lon`: map[string]interface{}{`type`: `number`},
+			},
+		}
+	case dal.PolygonType:
+		return map[string]interface{}{
+			`type`:  `array`,
+			`items`: openApiSchemaForType(dal.PointType),
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func openApiRecordSchema(name string) map[string]interface{} {
+	return map[string]interface{}{
+		`type`: `object`,
+		`properties`: map[string]interface{}{
+			`id`: map[string]interface{}{},
+			`fields`: map[string]interface{}{
+				`$ref`: openApiRef(name),
+			},
+		},
+	}
+}
+
+func openApiRecordSetSchema(name string) map[string]interface{} {
+	return map[string]interface{}{
+		`type`: `object`,
+		`properties`: map[string]interface{}{
+			`result_count`:     map[string]interface{}{`type`: `integer`},
+			`page`:             map[string]interface{}{`type`: `integer`},
+			`total_pages`:      map[string]interface{}{`type`: `integer`},
+			`records_per_page`: map[string]interface{}{`type`: `integer`},
+			`known_size`:       map[string]interface{}{`type`: `boolean`},
+			`records`: map[string]interface{}{
+				`type`:  `array`,
+				`items`: map[string]interface{}{`$ref`: openApiRef(name + `Record`)},
+			},
+		},
+	}
+}
+
+// Describes the endpoints for the given collection.  The collection is named separately because
+// backends may know it by a different (e.g.: tenant-scoped) name than clients use.
+func openApiPathsForCollection(name string, collection *dal.Collection) map[string]interface{} {
+	base := fmt.Sprintf("/api/collections/%s", name)
+	tags := []string{name}
+
+	filterParams := []interface{}{
+		openApiQueryParam(`limit`, `Maximum number of records to return.`, dal.IntType),
+		openApiQueryParam(`offset`, `Number of records to skip.`, dal.IntType),
+		openApiQueryParam(`sort`, `Comma-separated list of fields to sort by; prefix with "-" for descending.`, dal.StringType),
+		openApiQueryParam(`fields`, `Comma-separated list of fields to return.`, dal.StringType),
+		openApiQueryParam(`parallelism`, `Maximum number of partitions of the collection to query concurrently.`, dal.IntType),
+	}
+
+	idParam := map[string]interface{}{
+		`name`:     `id`,
+		`in`:       `path`,
+		`required`: true,
+		`schema`:   openApiSchemaForType(collection.IdentityFieldType),
+	}
+
+	return map[string]interface{}{
+		base + `/query/`: map[string]interface{}{
+			`get`: map[string]interface{}{
+				`tags`:       tags,
+				`summary`:    fmt.Sprintf("Query %s records", name),
+				`parameters`: filterParams,
+				`responses`:  openApiResponses(name+`RecordSet`, `200`),
+			},
+		},
+		base + `/where/{query}`: map[string]interface{}{
+			`get`: map[string]interface{}{
+				`tags`:    tags,
+				`summary`: fmt.Sprintf("Query %s records using a filter expression", name),
+				`parameters`: append([]interface{}{
+					map[string]interface{}{
+						`name`:     `query`,
+						`in`:       `path`,
+						`required`: true,
+						`schema`:   openApiSchemaForType(dal.StringType),
+					},
+				}, filterParams...),
+				`responses`: openApiResponses(name+`RecordSet`, `200`),
+			},
+		},
+		base + `/records`: map[string]interface{}{
+			`post`: map[string]interface{}{
+				`tags`:        tags,
+				`summary`:     fmt.Sprintf("Create %s records", name),
+				`requestBody`: openApiRequestBody(name + `RecordSet`),
+				`responses`:   openApiResponses(name+`RecordSet`, `200`),
+			},
+			`put`: map[string]interface{}{
+				`tags`:        tags,
+				`summary`:     fmt.Sprintf("Update %s records", name),
+				`requestBody`: openApiRequestBody(name + `RecordSet`),
+				`responses`:   openApiResponses(``, `200`),
+			},
+		},
+		base + `/records/{id}`: map[string]interface{}{
+			`get`: map[string]interface{}{
+				`tags`:       tags,
+				`summary`:    fmt.Sprintf("Retrieve a %s record", name),
+				`parameters`: []interface{}{idParam, filterParams[3]},
+				`responses`:  openApiResponses(name+`Record`, `200`),
+			},
+			`post`: map[string]interface{}{
+				`tags`:        tags,
+				`summary`:     fmt.Sprintf("Create or update a %s record", name),
+				`parameters`:  []interface{}{idParam},
+				`requestBody`: openApiRequestBody(name + `Record`),
+				`responses`:   openApiResponses(name+`Record`, `200`),
+			},
+			`delete`: map[string]interface{}{
+				`tags`:       tags,
+				`summary`:    fmt.Sprintf("Delete a %s record", name),
+				`parameters`: []interface{}{idParam},
+				`responses`:  openApiResponses(``, `200`),
+			},
+		},
+	}
+}
+
+func openApiQueryParam(name string, description string, t dal.Type) map[string]interface{} {
+	return map[string]interface{}{
+		`name`:        name,
+		`in`:          `query`,
+		`description`: description,
+		`schema`:      openApiSchemaForType(t),
+	}
+}
+
+func openApiRequestBody(schemaName string) map[string]interface{} {
+	return map[string]interface{}{
+		`required`: true,
+		`content`: map[string]interface{}{
+			`application/json`: map[string]interface{}{
+				`schema`: map[string]interface{}{`$ref`: openApiRef(schemaName)},
+			},
+		},
+	}
+}
+
+func openApiResponses(schemaName string, status string) map[string]interface{} {
+	success := map[string]interface{}{
+		`description`: `Success`,
+	}
+
+	if schemaName != `` {
+		success[`content`] = map[string]interface{}{
+			`application/json`: map[string]interface{}{
+				`schema`: map[string]interface{}{`$ref`: openApiRef(schemaName)},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		status: success,
+		`default`: map[string]interface{}{
+			`description`: `Error`,
+			`content`: map[string]interface{}{
+				`application/json`: map[string]interface{}{
+					`schema`: map[string]interface{}{`$ref`: openApiRef(`Error`)},
+				},
+			},
+		},
+	}
+}
+
+func openApiRef(name string) string {
+	return `#/components/schemas/` + name
+}
@@ -0,0 +1,112 @@
+package pivot
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOpenAPI(t *testing.T) {
+	assert := require.New(t)
+	mock := backends.NewMockBackend()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name:     `name`,
+		Type:     dal.StringType,
+		Length:   64,
+		Required: true,
+	}, dal.Field{
+		Name:     `password`,
+		Type:     dal.StringType,
+		Redacted: true,
+	}, dal.Field{
+		Name: `created_at`,
+		Type: dal.TimeType,
+	})))
+
+	spec, err := GenerateOpenAPI(mock)
+	assert.NoError(err)
+	assert.Equal(OpenAPIVersion, spec[`openapi`])
+
+	paths := spec[`paths`].(map[string]interface{})
+	assert.Contains(paths, `/api/collections/users/query/`)
+	assert.Contains(paths, `/api/collections/users/where/{query}`)
+	assert.Contains(paths, `/api/collections/users/records`)
+	assert.Contains(paths, `/api/collections/users/records/{id}`)
+
+	record := paths[`/api/collections/users/records/{id}`].(map[string]interface{})
+	assert.Contains(record, `get`)
+	assert.Contains(record, `post`)
+	assert.Contains(record, `delete`)
+
+	schemas := spec[`components`].(map[string]interface{})[`schemas`].(map[string]interface{})
+	assert.Contains(schemas, `Error`)
+	assert.Contains(schemas, `usersRecord`)
+	assert.Contains(schemas, `usersRecordSet`)
+
+	users := schemas[`users`].(map[string]interface{})
+	assert.Equal([]string{`name`}, users[`required`])
+
+	properties := users[`properties`].(map[string]interface{})
+	assert.Equal(map[string]interface{}{
+		`type`:      `string`,
+		`maxLength`: 64,
+	}, properties[`name`])
+
+	assert.Equal(map[string]interface{}{
+		`type`:      `string`,
+		`writeOnly`: true,
+	}, properties[`password`])
+
+	assert.Equal(map[string]interface{}{
+		`type`:   `string`,
+		`format`: `date-time`,
+	}, properties[`created_at`])
+}
+
+func TestGenerateOpenAPITenant(t *testing.T) {
+	assert := require.New(t)
+	tenant := backends.NewTenantBackend(backends.NewMockBackend(), `acme`, nil)
+
+	assert.NoError(tenant.CreateCollection(dal.NewCollection(`users`)))
+
+	spec, err := GenerateOpenAPI(tenant)
+	assert.NoError(err)
+
+	// clients of a tenant only ever see the unscoped collection names
+	paths := spec[`paths`].(map[string]interface{})
+	assert.Contains(paths, `/api/collections/users/records`)
+	assert.NotContains(paths, `/api/collections/`+tenant.ScopedName(`users`)+`/records`)
+
+	schemas := spec[`components`].(map[string]interface{})[`schemas`].(map[string]interface{})
+	assert.Contains(schemas, `users`)
+	assert.Contains(schemas, `usersRecordSet`)
+	assert.Len(schemas, 4)
+}
+
+func TestGenerateOpenAPIErrors(t *testing.T) {
+	assert := require.New(t)
+	mock := backends.NewMockBackend()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+	mock.FailWith(`ListCollections`, dal.CollectionNotFound)
+
+	_, err := GenerateOpenAPI(mock)
+	assert.Error(err)
+
+	mock.FailWith(`ListCollections`, nil)
+
+	spec, err := GenerateOpenAPI(mock)
+	assert.NoError(err)
+
+	// the document must survive being served as JSON
+	data, err := json.Marshal(spec)
+	assert.NoError(err)
+
+	var decoded map[string]interface{}
+	assert.NoError(json.Unmarshal(data, &decoded))
+	assert.Contains(decoded[`paths`], `/api/collections/users/records`)
+}
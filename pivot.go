@@ -15,6 +15,7 @@ import (
 )
 
 var log = logging.MustGetLogger(`pivot`)
+var querylog = logging.MustGetLogger(`pivot/querylog`)
 var MonitorCheckInterval = time.Duration(10) * time.Second
 var NetrcFile = ``
 
@@ -26,6 +27,20 @@ func NewDatabaseWithOptions(connection string, options backends.ConnectOptions)
 			}
 		}
 
+		for key, value := range map[string]string{
+			`datacenter`:         options.Datacenter,
+			`listTablesQuery`:    options.ListTablesQuery,
+			`describeTableQuery`: options.DescribeTableQuery,
+		} {
+			if value != `` {
+				if cs.Options == nil {
+					cs.Options = make(map[string]interface{})
+				}
+
+				cs.Options[key] = value
+			}
+		}
+
 		if backend, err := backends.MakeBackend(cs); err == nil {
 			// set indexer
 			if options.Indexer != `` {
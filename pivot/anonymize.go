@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// Loads the anonymization rules file given in the named flag (if any), using the salt given by the
+// --salt flag or a random one.
+func loadAnonymizeRules(c *cli.Context, flag string) (*pivot.AnonymizeRules, error) {
+	filename := c.String(flag)
+
+	if filename == `` {
+		return nil, nil
+	}
+
+	rules, err := pivot.LoadAnonymizeRules(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rules.Salt = c.String(`salt`); rules.Salt == `` {
+		salt := make([]byte, 16)
+
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+
+		rules.Salt = hex.EncodeToString(salt)
+	}
+
+	return rules, nil
+}
+
+// Rewrites the fields designated by a rules file in every record of the collections it names.
+func anonymize(c *cli.Context) error {
+	db, err := connect(c, c.Args().First())
+
+	if err != nil {
+		return err
+	}
+
+	rules, err := loadAnonymizeRules(c, `rules`)
+
+	if err != nil {
+		return err
+	} else if rules == nil {
+		return fmt.Errorf("Must specify a rules file with --rules")
+	}
+
+	names := make([]string, 0)
+
+	for name := range rules.Collections {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := anonymizeCollection(c, db, rules, name); err != nil {
+			return fmt.Errorf("collection %q: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func anonymizeCollection(c *cli.Context, db backends.Backend, rules *pivot.AnonymizeRules, name string) error {
+	collection, err := db.GetCollection(name)
+
+	if err != nil {
+		return err
+	}
+
+	search := db.WithSearch(collection)
+
+	if search == nil {
+		return fmt.Errorf("collection is not enumerable")
+	}
+
+	dryRun := c.Bool(`dry-run`)
+	batchSize := c.Int(`batch-size`)
+	batch := dal.NewRecordSet()
+	progress := progressReporter(`anonymized`)
+	var count int
+
+	flush := func() error {
+		if len(batch.Records) > 0 {
+			if dryRun {
+				printRecordTable(os.Stdout, collection, batch.Records)
+			} else if err := db.Update(name, batch); err != nil {
+				return err
+			}
+
+			count += len(batch.Records)
+			batch = dal.NewRecordSet()
+
+			if !dryRun {
+				progress(name, count)
+			}
+		}
+
+		return nil
+	}
+
+	f := filter.All()
+	f.IdentityField = collection.IdentityField
+
+	// gather all records before writing any of them so that updates don't disturb the query
+	var records []*dal.Record
+
+	if _, err := search.Query(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+		if err != nil {
+			return err
+		} else if record != nil {
+			records = append(records, record)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := rules.Apply(name, record); err != nil {
+			return fmt.Errorf("record %v: %v", record.ID, err)
+		}
+
+		batch.Push(record)
+
+		if batchSize <= 0 || len(batch.Records) >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if !dryRun {
+		fmt.Fprintln(os.Stderr)
+		log.Noticef("Anonymized %d records in collection %q", count, name)
+	}
+
+	return nil
+}
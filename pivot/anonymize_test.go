@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a context for the anonymize command with the given flags set
+func newAnonymizeContext(args ...string) (*cli.Context, error) {
+	set := flag.NewFlagSet(`anonymize`, flag.ContinueOnError)
+	set.Bool(`dry-run`, false, ``)
+	set.Int(`batch-size`, 2, ``)
+
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return cli.NewContext(nil, set, nil), nil
+}
+
+func TestAnonymizeCollection(t *testing.T) {
+	assert := require.New(t)
+
+	mock, err := newTestBackend()
+	assert.NoError(err)
+
+	c, err := newAnonymizeContext()
+	assert.NoError(err)
+
+	rules := &pivot.AnonymizeRules{
+		Salt: `salt`,
+		Collections: map[string]map[string]pivot.AnonymizeRule{
+			`users`: {
+				`name`: {Type: pivot.AnonymizeHash},
+			},
+		},
+	}
+
+	assert.NoError(anonymizeCollection(c, mock, rules, `users`))
+
+	// 3 records in batches of 2
+	assert.Len(mock.CallsTo(`Update`), 2)
+
+	seen := make(map[interface{}]bool)
+
+	for id, name := range map[int]string{1: `alice`, 2: `bob`, 3: `carol`} {
+		record, err := mock.Retrieve(`users`, id)
+		assert.NoError(err)
+		assert.NotEqual(name, record.Get(`name`))
+		assert.Len(record.Get(`name`), 32)
+		assert.False(seen[record.Get(`name`)])
+
+		// fields without rules are kept
+		assert.NotNil(record.Get(`age`))
+
+		seen[record.Get(`name`)] = true
+	}
+
+	assert.Error(anonymizeCollection(c, mock, rules, `missing`))
+}
+
+func TestAnonymizeCollectionDryRun(t *testing.T) {
+	assert := require.New(t)
+
+	mock, err := newTestBackend()
+	assert.NoError(err)
+
+	c, err := newAnonymizeContext(`--dry-run`)
+	assert.NoError(err)
+
+	rules := &pivot.AnonymizeRules{
+		Collections: map[string]map[string]pivot.AnonymizeRule{
+			`users`: {
+				`name`: {Type: pivot.AnonymizeRedact},
+			},
+		},
+	}
+
+	assert.NoError(anonymizeCollection(c, mock, rules, `users`))
+	assert.Empty(mock.CallsTo(`Update`))
+
+	record, err := mock.Retrieve(`users`, 1)
+	assert.NoError(err)
+	assert.Equal(`alice`, record.Get(`name`))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+type benchResult struct {
+	Workload  string
+	Ops       int
+	Errors    int64
+	Elapsed   time.Duration
+	Latencies []time.Duration
+}
+
+func (self *benchResult) percentile(p float64) time.Duration {
+	if len(self.Latencies) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(self.Latencies)-1) * p)
+	return self.Latencies[i]
+}
+
+// Runs the given operation count times across the given number of workers, recording the latency of
+// each operation.
+func runBenchWorkload(name string, count int, concurrency int, op func(i int) error) *benchResult {
+	result := &benchResult{
+		Workload:  name,
+		Ops:       count,
+		Latencies: make([]time.Duration, count),
+	}
+
+	var wg sync.WaitGroup
+	var next int64 = -1
+
+	started := time.Now()
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+
+				if i >= count {
+					return
+				}
+
+				opStarted := time.Now()
+
+				if err := op(i); err != nil {
+					atomic.AddInt64(&result.Errors, 1)
+					log.Debugf("%s %d: %v", name, i, err)
+				}
+
+				result.Latencies[i] = time.Since(opStarted)
+			}
+		}()
+	}
+
+	wg.Wait()
+	result.Elapsed = time.Since(started)
+
+	sort.Slice(result.Latencies, func(i, j int) bool {
+		return result.Latencies[i] < result.Latencies[j]
+	})
+
+	return result
+}
+
+func printBenchResults(results []*benchResult) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "WORKLOAD\tOPS\tERRORS\tELAPSED\tOPS/SEC\tP50\tP90\tP99\tMAX\t")
+
+	for _, result := range results {
+		fmt.Fprintf(
+			tw,
+			"%s\t%d\t%d\t%v\t%.1f\t%v\t%v\t%v\t%v\t\n",
+			result.Workload,
+			result.Ops,
+			result.Errors,
+			result.Elapsed.Round(time.Millisecond),
+			float64(result.Ops)/result.Elapsed.Seconds(),
+			result.percentile(0.5).Round(time.Microsecond),
+			result.percentile(0.9).Round(time.Microsecond),
+			result.percentile(0.99).Round(time.Microsecond),
+			result.percentile(1).Round(time.Microsecond),
+		)
+	}
+}
+
+func bench(c *cli.Context) error {
+	db, err := connect(c, c.Args().First())
+
+	if err != nil {
+		return err
+	}
+
+	name := c.String(`collection`)
+	records := c.Int(`records`)
+	concurrency := c.Int(`concurrency`)
+	workloads := strings.Split(c.String(`workloads`), `,`)
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	if records < 1 {
+		return fmt.Errorf("--records must be at least 1")
+	}
+
+	collection := dal.NewCollection(name).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `value`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name: `created_at`,
+		Type: dal.TimeType,
+	})
+
+	if _, err := db.GetCollection(name); err == nil {
+		return fmt.Errorf("collection %q already exists; specify another with --collection", name)
+	}
+
+	if err := db.CreateCollection(collection); err != nil {
+		return err
+	}
+
+	if !c.Bool(`keep`) {
+		defer db.DeleteCollection(name)
+	}
+
+	var results []*benchResult
+
+	for _, workload := range workloads {
+		var result *benchResult
+
+		switch strings.TrimSpace(workload) {
+		case `insert`:
+			result = runBenchWorkload(`insert`, records, concurrency, func(i int) error {
+				return db.Insert(name, dal.NewRecordSet(
+					dal.NewRecord(i+1).SetFields(map[string]interface{}{
+						`name`:       fmt.Sprintf("record-%d", i+1),
+						`value`:      rand.Intn(records),
+						`created_at`: time.Now(),
+					}),
+				))
+			})
+
+		case `retrieve`:
+			result = runBenchWorkload(`retrieve`, records, concurrency, func(i int) error {
+				_, err := db.Retrieve(name, rand.Intn(records)+1)
+				return err
+			})
+
+		case `query`:
+			search := db.WithSearch(collection)
+
+			if search == nil {
+				log.Warningf("backend %T does not support queries, skipping query workload", db)
+				continue
+			}
+
+			result = runBenchWorkload(`query`, c.Int(`queries`), concurrency, func(i int) error {
+				if f, err := filter.Parse(fmt.Sprintf("value/gte:%d", rand.Intn(records))); err == nil {
+					f.Limit = c.Int(`query-limit`)
+					_, err := search.Query(collection, f)
+					return err
+				} else {
+					return err
+				}
+			})
+
+		case `update`:
+			result = runBenchWorkload(`update`, records, concurrency, func(i int) error {
+				return db.Update(name, dal.NewRecordSet(
+					dal.NewRecord(rand.Intn(records)+1).Set(`value`, rand.Intn(records)),
+				))
+			})
+
+		case `delete`:
+			result = runBenchWorkload(`delete`, records, concurrency, func(i int) error {
+				return db.Delete(name, i+1)
+			})
+
+		default:
+			return fmt.Errorf("unknown workload %q", workload)
+		}
+
+		results = append(results, result)
+	}
+
+	printBenchResults(results)
+	return nil
+}
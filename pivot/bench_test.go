@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/cli"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a context for the bench command with the given arguments
+func newBenchContext(args ...string) (*cli.Context, error) {
+	set := flag.NewFlagSet(`bench`, flag.ContinueOnError)
+	set.String(`collection`, `pivot_bench`, ``)
+	set.String(`workloads`, `insert,retrieve,query`, ``)
+	set.Int(`records`, 20, ``)
+	set.Int(`queries`, 10, ``)
+	set.Int(`query-limit`, 5, ``)
+	set.Int(`concurrency`, 1, ``)
+	set.Bool(`keep`, false, ``)
+
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return cli.NewContext(nil, set, nil), nil
+}
+
+func TestRunBenchWorkload(t *testing.T) {
+	assert := require.New(t)
+	var lock sync.Mutex
+	seen := make(map[int]bool)
+
+	result := runBenchWorkload(`test`, 100, 4, func(i int) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		seen[i] = true
+
+		if i%10 == 0 {
+			return fmt.Errorf("failed")
+		}
+
+		return nil
+	})
+
+	// every operation runs exactly once
+	assert.Len(seen, 100)
+	assert.Equal(`test`, result.Workload)
+	assert.Equal(100, result.Ops)
+	assert.EqualValues(10, result.Errors)
+	assert.Len(result.Latencies, 100)
+	assert.True(sort.SliceIsSorted(result.Latencies, func(i, j int) bool {
+		return result.Latencies[i] < result.Latencies[j]
+	}))
+}
+
+func TestBenchPercentile(t *testing.T) {
+	assert := require.New(t)
+
+	result := &benchResult{}
+	assert.Zero(result.percentile(0.5))
+
+	for i := 1; i <= 100; i++ {
+		result.Latencies = append(result.Latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(time.Millisecond, result.percentile(0))
+	assert.Equal(50*time.Millisecond, result.percentile(0.5))
+	assert.Equal(99*time.Millisecond, result.percentile(0.99))
+	assert.Equal(100*time.Millisecond, result.percentile(1))
+}
+
+func TestBench(t *testing.T) {
+	assert := require.New(t)
+
+	c, err := newBenchContext(`-workloads`, `insert,retrieve,query,update,delete`, `-concurrency`, `4`, `mock://`)
+	assert.NoError(err)
+	assert.NoError(bench(c))
+
+	c, err = newBenchContext(`-records`, `0`, `mock://`)
+	assert.NoError(err)
+	assert.Error(bench(c))
+
+	c, err = newBenchContext(`-workloads`, `insert,bogus`, `mock://`)
+	assert.NoError(err)
+	assert.Error(bench(c))
+
+	c, err = newBenchContext()
+	assert.NoError(err)
+	assert.Error(bench(c))
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot"
+	"github.com/ghetzel/pivot/codegen"
+	"github.com/ghetzel/pivot/dal"
+)
+
+// Returns the collections to generate code for: those defined in the files given via the global
+// --schema flag or, if a connection string is given, those that exist in the datasource.
+func codegenCollections(c *cli.Context) ([]*dal.Collection, error) {
+	collections := make([]*dal.Collection, 0)
+
+	if c.NArg() == 0 {
+		filenames := c.GlobalStringSlice(`schema`)
+
+		if len(filenames) == 0 {
+			return nil, fmt.Errorf("Must specify a connection string or at least one --schema file")
+		}
+
+		for _, filename := range filenames {
+			if definitions, err := pivot.LoadSchemataFromFile(filename); err == nil {
+				collections = append(collections, definitions...)
+			} else {
+				return nil, err
+			}
+		}
+
+		return collections, nil
+	}
+
+	db, err := connect(c, c.Args().First())
+
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := selectCollections(db, c.StringSlice(`collection`))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if collection, err := db.GetCollection(name); err == nil {
+			collections = append(collections, collection)
+		} else {
+			return nil, err
+		}
+	}
+
+	return collections, nil
+}
+
+func codegenOutput(c *cli.Context) (io.WriteCloser, error) {
+	if filename := c.String(`output`); filename != `` && filename != `-` {
+		return os.Create(filename)
+	}
+
+	return os.Stdout, nil
+}
+
+func codegenStructs(c *cli.Context) error {
+	collections, err := codegenCollections(c)
+
+	if err != nil {
+		return err
+	}
+
+	out, err := codegenOutput(c)
+
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+
+	return codegen.GenerateStructs(out, collections, codegen.StructOptions{
+		Package:   c.String(`package`),
+		Accessors: c.Bool(`accessors`),
+		Hooks:     c.Bool(`hooks`),
+	})
+}
+
+func codegenProto(c *cli.Context) error {
+	goOut := c.String(`go-out`)
+	filename := c.String(`output`)
+
+	if goOut != `` && (filename == `` || filename == `-`) {
+		return fmt.Errorf("--go-out requires an --output file")
+	}
+
+	collections, err := codegenCollections(c)
+
+	if err != nil {
+		return err
+	}
+
+	out, err := codegenOutput(c)
+
+	if err != nil {
+		return err
+	}
+
+	err = codegen.GenerateProto(out, collections, codegen.ProtoOptions{
+		Package:   c.String(`package`),
+		GoPackage: c.String(`go-package`),
+	})
+
+	out.Close()
+
+	if err != nil || goOut == `` {
+		return err
+	}
+
+	// generate Go bindings from the file we just wrote
+	protoc := exec.Command(
+		`protoc`,
+		fmt.Sprintf("--proto_path=%s", filepath.Dir(filename)),
+		fmt.Sprintf("--go_out=%s", goOut),
+		filepath.Base(filename),
+	)
+
+	protoc.Stdout = os.Stdout
+	protoc.Stderr = os.Stderr
+
+	return protoc.Run()
+}
+
+func codegenSchema(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("Must specify a connection string")
+	}
+
+	collections, err := codegenCollections(c)
+
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool(`include-internal`) {
+		filtered := make([]*dal.Collection, 0)
+
+		for _, collection := range collections {
+			if !strings.HasPrefix(collection.Name, `_pivot_`) {
+				filtered = append(filtered, collection)
+			}
+		}
+
+		collections = filtered
+	}
+
+	format := c.String(`format`)
+
+	// write one file per collection into the given directory
+	if dir := c.String(`split`); dir != `` {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		for _, collection := range collections {
+			filename := filepath.Join(dir, collection.Name+`.`+format)
+
+			if file, err := os.Create(filename); err == nil {
+				err := codegen.GenerateSchema(file, []*dal.Collection{collection}, format)
+				file.Close()
+
+				if err != nil {
+					return err
+				}
+
+				log.Infof("wrote %s", filename)
+			} else {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	out, err := codegenOutput(c)
+
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+
+	return codegen.GenerateSchema(out, collections, format)
+}
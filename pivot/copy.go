@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+func copyCollections(c *cli.Context) error {
+	sourceURI := c.String(`from`)
+	destinationURI := c.String(`to`)
+
+	if sourceURI == `` {
+		sourceURI = c.Args().Get(0)
+	}
+
+	if destinationURI == `` {
+		destinationURI = c.Args().Get(1)
+	}
+
+	if sourceURI == `` {
+		return fmt.Errorf("Must specify a source")
+	} else if destinationURI == `` {
+		return fmt.Errorf("Must specify a destination")
+	}
+
+	source, err := connect(c, sourceURI)
+
+	if err != nil {
+		return fmt.Errorf("failed to connect to source: %v", err)
+	}
+
+	destination, err := connect(c, destinationURI)
+
+	if err != nil {
+		return fmt.Errorf("failed to connect to destination: %v", err)
+	}
+
+	collections, err := selectCollections(source, c.StringSlice(`collections`))
+
+	if err != nil {
+		return fmt.Errorf("failed to list source collections: %v", err)
+	}
+
+	if c.Bool(`dry-run`) {
+		return copyPlan(c, source, destination, collections)
+	}
+
+	rules, err := loadAnonymizeRules(c, `anonymize`)
+
+	if err != nil {
+		return fmt.Errorf("failed to load anonymization rules: %v", err)
+	}
+
+	log.Debugf("Copying %d collections", len(collections))
+
+	for _, name := range collections {
+		if err := copyCollection(c, source, destination, name, rules); err != nil {
+			log.Errorf("Failed to copy collection %q: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns the destination collection to copy into, creating it from the source definition if it
+// doesn't exist (unless creation is disabled).
+func destinationCollection(c *cli.Context, destination backends.Backend, collection *dal.Collection) (*dal.Collection, bool, error) {
+	if dc, err := destination.GetCollection(collection.Name); err == nil {
+		return dc, false, nil
+	} else if dal.IsCollectionNotFoundErr(err) {
+		if c.Bool(`no-create`) {
+			return nil, false, fmt.Errorf("collection does not exist in the destination")
+		}
+
+		return collection, true, nil
+	} else {
+		return nil, false, err
+	}
+}
+
+func copyCollection(c *cli.Context, source backends.Backend, destination backends.Backend, name string, rules *pivot.AnonymizeRules) error {
+	collection, err := source.GetCollection(name)
+
+	if err != nil {
+		return err
+	}
+
+	search := source.WithSearch(collection)
+
+	if search == nil {
+		return fmt.Errorf("collection is not enumerable")
+	}
+
+	destCollection, create, err := destinationCollection(c, destination, collection)
+
+	if err != nil {
+		return err
+	}
+
+	if create {
+		if err := destination.CreateCollection(collection); err != nil {
+			return fmt.Errorf("cannot create destination collection: %v", err)
+		}
+	} else if diffs := destCollection.Diff(collection); len(diffs) > 0 && !c.Bool(`no-schema-check`) {
+		for _, diff := range diffs {
+			log.Errorf("  %v", diff)
+		}
+
+		return fmt.Errorf("collections differ")
+	}
+
+	batchSize := c.Int(`batch-size`)
+	batch := dal.NewRecordSet()
+	progress := progressReporter(`copied`)
+	var count int
+
+	flush := func() error {
+		if len(batch.Records) > 0 {
+			if err := destination.Insert(name, batch); err != nil {
+				return fmt.Errorf("failed to write records to destination: %v", err)
+			}
+
+			count += len(batch.Records)
+			batch = dal.NewRecordSet()
+			progress(name, count)
+		}
+
+		return nil
+	}
+
+	f := filter.All()
+	f.IdentityField = collection.IdentityField
+
+	if _, err := search.Query(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+		if err != nil {
+			log.Warningf("failed to copy record %v: %v", record.ID, err)
+			return nil
+		}
+
+		if err := rules.Apply(name, record); err != nil {
+			return fmt.Errorf("failed to anonymize record %v: %v", record.ID, err)
+		}
+
+		batch.Push(record)
+
+		if batchSize <= 0 || len(batch.Records) >= batchSize {
+			return flush()
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr)
+	log.Noticef("Successfully copied %d records from collection %q", count, name)
+	return nil
+}
+
+// Prints what a copy would do without writing anything to the destination.
+func copyPlan(c *cli.Context, source backends.Backend, destination backends.Backend, collections []string) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "COLLECTION\tRECORDS\tACTION")
+
+	for _, name := range collections {
+		var action string
+		var records = `?`
+
+		if collection, err := source.GetCollection(name); err == nil {
+			if aggregator := source.WithAggregator(collection); aggregator != nil {
+				if count, err := aggregator.Count(collection); err == nil {
+					records = fmt.Sprintf("%d", count)
+				}
+			}
+
+			if destCollection, create, err := destinationCollection(c, destination, collection); err != nil {
+				action = fmt.Sprintf("skip: %v", err)
+			} else if create {
+				action = `create collection, copy records`
+			} else if diffs := destCollection.Diff(collection); len(diffs) > 0 && !c.Bool(`no-schema-check`) {
+				action = fmt.Sprintf("skip: schemas differ (%d differences)", len(diffs))
+			} else {
+				action = `copy records`
+			}
+		} else {
+			action = fmt.Sprintf("skip: %v", err)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, records, action)
+	}
+
+	return nil
+}
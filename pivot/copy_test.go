@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a context for the copy command with the given flags set
+func newCopyContext(args ...string) (*cli.Context, error) {
+	set := flag.NewFlagSet(`copy`, flag.ContinueOnError)
+	set.Bool(`no-create`, false, ``)
+	set.Bool(`no-schema-check`, false, ``)
+	set.Bool(`dry-run`, false, ``)
+	set.Int(`batch-size`, 2, ``)
+
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return cli.NewContext(nil, set, nil), nil
+}
+
+func TestCopyCollection(t *testing.T) {
+	assert := require.New(t)
+
+	source, err := newTestBackend()
+	assert.NoError(err)
+
+	c, err := newCopyContext()
+	assert.NoError(err)
+
+	// missing collections are created in the destination
+	destination := backends.NewMockBackend()
+	assert.NoError(copyCollection(c, source, destination, `users`, nil))
+	assert.Len(destination.CallsTo(`CreateCollection`), 1)
+
+	// 3 records in batches of 2
+	assert.Len(destination.CallsTo(`Insert`), 2)
+
+	for id, name := range map[int]string{1: `alice`, 2: `bob`, 3: `carol`} {
+		record, err := destination.Retrieve(`users`, id)
+		assert.NoError(err)
+		assert.Equal(name, record.Get(`name`))
+	}
+
+	// empty collections are created but nothing is written
+	assert.NoError(copyCollection(c, source, destination, `groups`, nil))
+	assert.Len(destination.CallsTo(`CreateCollection`), 2)
+	assert.Len(destination.CallsTo(`Insert`), 2)
+
+	assert.Error(copyCollection(c, source, destination, `missing`, nil))
+}
+
+func TestCopyCollectionNoCreate(t *testing.T) {
+	assert := require.New(t)
+
+	source, err := newTestBackend()
+	assert.NoError(err)
+
+	c, err := newCopyContext(`-no-create`)
+	assert.NoError(err)
+
+	destination := backends.NewMockBackend()
+	assert.Error(copyCollection(c, source, destination, `users`, nil))
+	assert.Empty(destination.CallsTo(`CreateCollection`))
+	assert.Empty(destination.CallsTo(`Insert`))
+
+	collection, err := source.GetCollection(`users`)
+	assert.NoError(err)
+
+	_, _, err = destinationCollection(c, destination, collection)
+	assert.Error(err)
+
+	// existing collections are used as-is
+	assert.NoError(destination.CreateCollection(collection))
+
+	dc, create, err := destinationCollection(c, destination, collection)
+	assert.NoError(err)
+	assert.False(create)
+	assert.Equal(`users`, dc.Name)
+
+	assert.NoError(copyCollection(c, source, destination, `users`, nil))
+	assert.Len(destination.CallsTo(`Insert`), 2)
+}
+
+func TestCopyCollectionSchemaCheck(t *testing.T) {
+	assert := require.New(t)
+
+	source, err := newTestBackend()
+	assert.NoError(err)
+
+	destination := backends.NewMockBackend()
+	assert.NoError(destination.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.IntType,
+	})))
+
+	c, err := newCopyContext()
+	assert.NoError(err)
+	assert.Error(copyCollection(c, source, destination, `users`, nil))
+	assert.Empty(destination.CallsTo(`Insert`))
+
+	c, err = newCopyContext(`-no-schema-check`, `-batch-size`, `0`)
+	assert.NoError(err)
+	assert.NoError(copyCollection(c, source, destination, `users`, nil))
+
+	// a batch size of zero writes each record as it's read
+	assert.Len(destination.CallsTo(`Insert`), 3)
+}
+
+func TestCopyCollectionWriteErrors(t *testing.T) {
+	assert := require.New(t)
+
+	source, err := newTestBackend()
+	assert.NoError(err)
+
+	c, err := newCopyContext()
+	assert.NoError(err)
+
+	destination := backends.NewMockBackend()
+	destination.FailWith(`Insert`, dal.Unsupported("read-only"))
+
+	assert.Error(copyCollection(c, source, destination, `users`, nil))
+	assert.Len(destination.CallsTo(`Insert`), 1)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot"
+)
+
+func dump(c *cli.Context) error {
+	var w io.Writer = os.Stdout
+
+	db, err := connect(c, c.Args().First())
+
+	if err != nil {
+		return err
+	}
+
+	collections, err := selectCollections(db, c.StringSlice(`collection`))
+
+	if err != nil {
+		return err
+	}
+
+	output := c.String(`output`)
+
+	if output != `` && output != `-` {
+		if file, err := os.Create(output); err == nil {
+			defer file.Close()
+			w = file
+		} else {
+			return err
+		}
+	}
+
+	buffered := bufio.NewWriter(w)
+	defer buffered.Flush()
+	w = buffered
+
+	if c.Bool(`compress`) || strings.HasSuffix(output, `.gz`) {
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		w = gz
+	}
+
+	options := pivot.BackupOptions{
+		Collections: collections,
+	}
+
+	if !c.Bool(`quiet`) {
+		options.Progress = progressReporter(`dumped`)
+		defer fmt.Fprintln(os.Stderr)
+	}
+
+	return pivot.Backup(db, w, options)
+}
+
+func restore(c *cli.Context) error {
+	var r io.Reader = os.Stdin
+
+	db, err := connect(c, c.Args().First())
+
+	if err != nil {
+		return err
+	}
+
+	if input := c.String(`input`); input != `` && input != `-` {
+		if file, err := os.Open(input); err == nil {
+			defer file.Close()
+			r = file
+		} else {
+			return err
+		}
+	}
+
+	buffered := bufio.NewReader(r)
+	r = buffered
+
+	// detect gzip-compressed input by its magic number
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		if gz, err := gzip.NewReader(buffered); err == nil {
+			defer gz.Close()
+			r = gz
+		} else {
+			return err
+		}
+	}
+
+	var collections []string
+
+	for _, pattern := range c.StringSlice(`collection`) {
+		collections = append(collections, strings.Split(pattern, `,`)...)
+	}
+
+	options := pivot.RestoreOptions{
+		Collections:      collections,
+		CreateMissing:    !c.Bool(`no-create`),
+		BatchSize:        c.Int(`batch-size`),
+		SkipSchemaChecks: c.Bool(`no-schema-check`),
+	}
+
+	if !c.Bool(`quiet`) {
+		options.Progress = progressReporter(`restored`)
+		defer fmt.Fprintln(os.Stderr)
+	}
+
+	return pivot.Restore(db, r, options)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/filter"
+)
+
+func explain(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("Must specify a connection string and a collection")
+	}
+
+	db, err := connect(c, c.Args().Get(0))
+
+	if err != nil {
+		return err
+	}
+
+	explainer, ok := db.(backends.Explainer)
+
+	if !ok {
+		return fmt.Errorf("Backend %T cannot explain queries", db)
+	}
+
+	collection, err := db.GetCollection(c.Args().Get(1))
+
+	if err != nil {
+		return err
+	}
+
+	spec := c.String(`query`)
+
+	if spec == `` {
+		spec = filter.AllValue
+	}
+
+	f, err := filter.Parse(spec)
+
+	if err != nil {
+		return err
+	}
+
+	if limit := c.Int(`limit`); limit > 0 {
+		f.Limit = limit
+	}
+
+	plan, err := explainer.Explain(collection, f)
+
+	if err != nil {
+		return err
+	}
+
+	if c.String(`format`) == `json` {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent(``, `  `)
+		return encoder.Encode(plan)
+	}
+
+	printQueryPlan(plan)
+
+	// plans made by a query planner consist of the plans for the search index and backend
+	for i, step := range plan.Steps {
+		fmt.Printf("\nStep %d (%s):\n", i+1, step.Strategy)
+		printQueryPlan(step)
+	}
+
+	return nil
+}
+
+func printQueryPlan(plan *backends.QueryPlan) {
+	fmt.Printf("Backend: %s\n", plan.Backend)
+	fmt.Printf("Filter:  %s\n", plan.Filter)
+
+	if plan.Query != `` {
+		fmt.Printf("Query:   %s\n", plan.Query)
+	}
+
+	if len(plan.Steps) > 0 {
+		fmt.Printf("Plan:    %s (%s)\n", plan.Strategy, plan.Reason)
+	}
+
+	if len(plan.Values) > 0 {
+		fmt.Println("Values:")
+
+		for i, value := range plan.Values {
+			fmt.Printf("  $%d = %#v\n", i+1, value)
+		}
+	}
+
+	if len(plan.Plan) > 0 {
+		fmt.Println("\nPlan:")
+		printPlan(plan.Plan)
+	}
+}
+
+// Prints the steps of a query plan as a table if they are flat, or as indented JSON otherwise.
+func printPlan(steps []map[string]interface{}) {
+	columns := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, step := range steps {
+		for column, value := range step {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				data, _ := json.MarshalIndent(steps, ``, `  `)
+				fmt.Println(string(data))
+				return
+			}
+
+			if !seen[column] {
+				columns = append(columns, column)
+				seen[column] = true
+			}
+		}
+	}
+
+	sort.Strings(columns)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+
+	for _, step := range steps {
+		row := make([]string, len(columns))
+
+		for i, column := range columns {
+			if value, ok := step[column]; ok && value != nil {
+				row[i] = fmt.Sprintf("%v", value)
+			} else {
+				row[i] = `-`
+			}
+		}
+
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+)
+
+// Connects to and initializes the backend at the given connection string, registering any schema
+// definitions given via the global --schema flag.
+func connect(c *cli.Context, uri string) (backends.Backend, error) {
+	if uri == `` {
+		return nil, fmt.Errorf("Must specify a connection string")
+	}
+
+	if db, err := pivot.NewDatabase(uri); err == nil {
+		for _, filename := range c.GlobalStringSlice(`schema`) {
+			if collections, err := pivot.LoadSchemataFromFile(filename); err == nil {
+				for _, collection := range collections {
+					db.RegisterCollection(collection)
+				}
+			} else {
+				return nil, err
+			}
+		}
+
+		return db, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Returns the column names to display for the given records: the identity field followed by the
+// collection's fields (if known), followed by any other fields present in the records.
+func recordColumns(collection *dal.Collection, records []*dal.Record) []string {
+	columns := []string{`id`}
+	seen := map[string]bool{`id`: true}
+
+	if collection != nil {
+		seen[collection.IdentityField] = true
+
+		for _, field := range collection.Fields {
+			if !seen[field.Name] {
+				columns = append(columns, field.Name)
+				seen[field.Name] = true
+			}
+		}
+	}
+
+	extra := make([]string, 0)
+
+	for _, record := range records {
+		for k := range record.Fields {
+			if !seen[k] {
+				extra = append(extra, k)
+				seen[k] = true
+			}
+		}
+	}
+
+	sort.Strings(extra)
+	return append(columns, extra...)
+}
+
+// Writes the given records as an aligned table.
+func printRecordTable(w io.Writer, collection *dal.Collection, records []*dal.Record) {
+	writeRecordTable(w, recordColumns(collection, records), records, true)
+}
+
+// Writes the given columns of each record as an aligned table, optionally preceded by a header row.
+func writeRecordTable(w io.Writer, columns []string, records []*dal.Record, header bool) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	if header {
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	}
+
+	for _, record := range records {
+		row := make([]string, len(columns))
+
+		for i, column := range columns {
+			if value := recordValue(record, column); value == nil {
+				row[i] = `-`
+			} else {
+				row[i] = truncate(fmt.Sprintf("%v", value), 48)
+			}
+		}
+
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	tw.Flush()
+}
+
+// Returns the value of the named column in the given record, where "id" refers to the record ID.
+func recordValue(record *dal.Record, column string) interface{} {
+	if column == `id` {
+		return record.ID
+	}
+
+	return record.Get(column)
+}
+
+func truncate(in string, length int) string {
+	in = strings.Replace(in, "\n", ` `, -1)
+	in = strings.Replace(in, "\t", ` `, -1)
+
+	if len(in) > length {
+		return in[:length-3] + `...`
+	}
+
+	return in
+}
+
+// Returns the names of the collections in the given backend that match any of the given patterns.
+// Patterns may contain shell-style wildcards and may be comma-separated.  If no patterns are given,
+// all collections are returned.
+func selectCollections(db backends.Backend, patterns []string) ([]string, error) {
+	names, err := db.ListCollections()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var expanded []string
+
+	for _, pattern := range patterns {
+		for _, p := range strings.Split(pattern, `,`) {
+			if p = strings.TrimSpace(p); p != `` {
+				expanded = append(expanded, p)
+			}
+		}
+	}
+
+	if len(expanded) == 0 {
+		sort.Strings(names)
+		return names, nil
+	}
+
+	selected := make([]string, 0)
+
+	for _, name := range names {
+		for _, pattern := range expanded {
+			if matched, err := filepath.Match(pattern, name); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			} else if matched {
+				selected = append(selected, name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(selected)
+	return selected, nil
+}
+
+// Returns a progress function that reports the number of records processed per collection to
+// standard error, overwriting the current line as it goes.
+func progressReporter(verb string) pivot.ProgressFunc {
+	var last string
+	var lastCount int
+	started := time.Now()
+
+	return func(collection string, records int) {
+		if collection != last {
+			if last != `` {
+				fmt.Fprintf(os.Stderr, "\r%s %s: %d records\n", verb, last, lastCount)
+			}
+
+			last = collection
+			started = time.Now()
+		} else if records == lastCount {
+			return
+		}
+
+		lastCount = records
+
+		if elapsed := time.Since(started).Seconds(); elapsed > 0 {
+			fmt.Fprintf(os.Stderr, "\r%s %s: %d records (%.0f/s)", verb, collection, records, float64(records)/elapsed)
+		} else {
+			fmt.Fprintf(os.Stderr, "\r%s %s: %d records", verb, collection, records)
+		}
+	}
+}
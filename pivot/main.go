@@ -9,8 +9,7 @@ import (
 	"github.com/ghetzel/cli"
 	"github.com/ghetzel/pivot"
 	"github.com/ghetzel/pivot/backends"
-	"github.com/ghetzel/pivot/dal"
-	"github.com/ghetzel/pivot/mapper"
+	_ "github.com/ghetzel/pivot/client"
 	"github.com/ghetzel/pivot/util"
 	"github.com/op/go-logging"
 )
@@ -90,6 +89,74 @@ func main() {
 					Usage: `The path to the UI directory`,
 					Value: pivot.DefaultUiDirectory,
 				},
+				cli.StringSliceFlag{
+					Name:  `cors-origin`,
+					Usage: `An origin that is permitted to make cross-origin requests (can be specified multiple times).`,
+				},
+				cli.BoolFlag{
+					Name:  `no-cors`,
+					Usage: `Disable cross-origin resource sharing headers entirely.`,
+				},
+				cli.IntFlag{
+					Name:  `compress-min-size`,
+					Usage: `The minimum response size (in bytes) that will be compressed.`,
+					Value: pivot.DefaultCompressionMinSize,
+				},
+				cli.BoolFlag{
+					Name:  `no-compress`,
+					Usage: `Disable response compression.`,
+				},
+				cli.StringFlag{
+					Name:  `access-log`,
+					Usage: "Write JSON-formatted access logs to the given file (or \"-\" for standard output).",
+				},
+				cli.StringFlag{
+					Name:  `tenancy`,
+					Usage: `Isolate collections per tenant, identified by: "header", "subdomain", or "claim".`,
+				},
+				cli.StringFlag{
+					Name:   `tenant-token-secret`,
+					Usage:  `The HMAC secret used to verify bearer tokens when using claim-based tenancy.`,
+					EnvVar: `PIVOT_TENANT_TOKEN_SECRET`,
+				},
+				cli.StringFlag{
+					Name:  `tls-cert`,
+					Usage: `Serve over HTTPS using the given PEM-encoded certificate (reloaded when changed).`,
+				},
+				cli.StringFlag{
+					Name:  `tls-key`,
+					Usage: `The PEM-encoded private key for the TLS certificate.`,
+				},
+				cli.StringFlag{
+					Name:  `tls-client-ca`,
+					Usage: `Require clients to present a certificate signed by a CA in the given PEM file.`,
+				},
+				cli.StringFlag{
+					Name:  `tls-client-auth`,
+					Usage: `Whether client certificates are required ("require") or only verified if given ("request").`,
+				},
+				cli.DurationFlag{
+					Name:  `reload-interval`,
+					Usage: `How often to check the configuration and schema files for changes (0 to only reload on SIGHUP).`,
+					Value: pivot.DefaultReloadInterval,
+				},
+				cli.StringFlag{
+					Name:   `admin-token`,
+					Usage:  `The token required for schema modifications and other administrative API operations. If unset, these operations are denied (unless --insecure-admin is given); previous versions allowed them.`,
+					EnvVar: `PIVOT_ADMIN_TOKEN`,
+				},
+				cli.BoolFlag{
+					Name:  `insecure-admin`,
+					Usage: `Permit administrative API operations without a token when no admin token is set (the behavior of previous versions).`,
+				},
+				cli.BoolFlag{
+					Name:  `validate-payloads`,
+					Usage: `Check records written via the API against their collection's JSON Schema before writing them.`,
+				},
+				cli.BoolFlag{
+					Name:  `pprof`,
+					Usage: `Serve runtime profiling data at /debug/pprof/ (requires the admin token).`,
+				},
 			},
 			Action: func(c *cli.Context) {
 				var backend string
@@ -122,6 +189,64 @@ func main() {
 				server := pivot.NewServer(backend)
 				server.Address = c.String(`address`)
 				server.UiDirectory = c.String(`ui-dir`)
+				server.AdminToken = c.String(`admin-token`)
+				server.InsecureAdmin = c.Bool(`insecure-admin`)
+				server.TenancyMode = pivot.TenancyMode(c.String(`tenancy`))
+				server.TenantTokenSecret = c.String(`tenant-token-secret`)
+				server.ConfigFile = c.GlobalString(`config`)
+				server.ConfigEnv = os.Getenv(`PIVOT_ENV`)
+				server.ReloadInterval = c.Duration(`reload-interval`)
+				server.Profiling = c.Bool(`pprof`)
+				server.ValidatePayloads = c.Bool(`validate-payloads`)
+
+				if config.Cors != nil {
+					server.Cors = config.Cors
+				}
+
+				if origins := c.StringSlice(`cors-origin`); len(origins) > 0 {
+					server.Cors.AllowOrigins = origins
+				}
+
+				if c.Bool(`no-cors`) {
+					server.Cors = nil
+				}
+
+				if c.Bool(`no-compress`) {
+					server.Compression = nil
+				} else {
+					server.Compression.MinSize = c.Int(`compress-min-size`)
+				}
+
+				if config.Quotas != nil {
+					server.Quotas = config.Quotas
+				}
+
+				if config.TLS != nil {
+					server.TLS = config.TLS
+				}
+
+				if cert := c.String(`tls-cert`); cert != `` {
+					server.TLS = &pivot.TLSConfig{
+						CertFile:     cert,
+						KeyFile:      c.String(`tls-key`),
+						ClientCAFile: c.String(`tls-client-ca`),
+						ClientAuth:   c.String(`tls-client-auth`),
+					}
+				}
+
+				switch accessLog := c.String(`access-log`); accessLog {
+				case ``:
+					break
+				case `-`:
+					server.AccessLog = os.Stdout
+				default:
+					if file, err := os.OpenFile(accessLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644); err == nil {
+						defer file.Close()
+						server.AccessLog = file
+					} else {
+						log.Fatalf("Cannot open access log: %v", err)
+					}
+				}
 				server.ConnectOptions.Indexer = indexer
 
 				for _, filename := range c.GlobalStringSlice(`schema`) {
@@ -135,117 +260,576 @@ func main() {
 			},
 		},
 		{
-			Name:      `copy`,
-			Usage:     `Copies data from one datasource to another`,
-			ArgsUsage: `SOURCE DESTINATION`,
+			Name:      `shell`,
+			Usage:     `Start an interactive shell for querying a datasource.`,
+			ArgsUsage: `CONNECTION_STRING`,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  `limit, l`,
+					Usage: `The default maximum number of records returned by queries.`,
+					Value: ShellDefaultLimit,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if db, err := connect(c, c.Args().First()); err == nil {
+					ShellDefaultLimit = c.Int(`limit`)
+
+					if err := runShell(db); err != nil {
+						log.Fatalf("shell error: %v", err)
+					}
+				} else {
+					log.Fatalf("failed to connect: %v", err)
+				}
+			},
+		},
+		{
+			Name:      `dump`,
+			Usage:     `Write the schema and contents of a datasource to a file.`,
+			ArgsUsage: `CONNECTION_STRING`,
 			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `output, o`,
+					Usage: `The file to write to (default: standard output).`,
+				},
 				cli.StringSliceFlag{
 					Name:  `collection, c`,
-					Usage: `A specific collection to copy (can be specified multiple times).`,
+					Usage: `A collection (or wildcard pattern) to dump (can be specified multiple times).`,
+				},
+				cli.BoolFlag{
+					Name:  `compress, z`,
+					Usage: `Compress the output with gzip (implied if the output file ends in ".gz").`,
+				},
+				cli.BoolFlag{
+					Name:  `quiet, q`,
+					Usage: `Do not report progress.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := dump(c); err != nil {
+					log.Fatalf("dump failed: %v", err)
+				}
+			},
+		},
+		{
+			Name:      `restore`,
+			Usage:     `Load a file produced by "dump" into a datasource.`,
+			ArgsUsage: `CONNECTION_STRING`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `input, i`,
+					Usage: `The file to read from (default: standard input).  Compressed input is detected automatically.`,
+				},
+				cli.StringSliceFlag{
+					Name:  `collection, c`,
+					Usage: `A specific collection to restore (can be specified multiple times).`,
+				},
+				cli.IntFlag{
+					Name:  `batch-size, b`,
+					Usage: `The number of records to insert at a time.`,
+					Value: pivot.DefaultRestoreBatchSize,
+				},
+				cli.BoolFlag{
+					Name:  `no-create`,
+					Usage: `Do not create collections that do not exist in the destination.`,
 				},
 				cli.BoolFlag{
 					Name:  `no-schema-check, S`,
-					Usage: `Skip verifying schema equality.`,
+					Usage: `Skip verifying that existing collections match the dumped schema.`,
+				},
+				cli.BoolFlag{
+					Name:  `quiet, q`,
+					Usage: `Do not report progress.`,
 				},
 			},
 			Action: func(c *cli.Context) {
-				var source backends.Backend
-				var destination backends.Backend
-
-				if sourceURI := c.Args().Get(0); sourceURI != `` {
-					if destinationURI := c.Args().Get(1); destinationURI != `` {
-						if s, err := pivot.NewDatabase(sourceURI); err == nil {
-							if err := s.Initialize(); err != nil {
-								log.Fatalf("failed to initialize source: %v", err)
-							}
-
-							if d, err := pivot.NewDatabase(destinationURI); err == nil {
-								if err := d.Initialize(); err != nil {
-									log.Fatalf("failed to initialize destination: %v", err)
-								}
-
-								source = s
-								destination = d
-							} else {
-								log.Fatalf("failed to connect to destination: %v", err)
-							}
-						} else {
-							log.Fatalf("failed to connect to source: %v", err)
+				if err := restore(c); err != nil {
+					log.Fatalf("restore failed: %v", err)
+				}
+			},
+		},
+		{
+			Name:  `schema`,
+			Usage: `Compare and apply schema definitions to a datasource.`,
+			Subcommands: []cli.Command{
+				{
+					Name:      `diff`,
+					Usage:     `Show the differences between a schema file and a datasource.`,
+					ArgsUsage: `SCHEMA_FILE CONNECTION_STRING`,
+					Action: func(c *cli.Context) {
+						if err := schemaDiff(c); err != nil {
+							log.Fatalf("schema diff failed: %v", err)
 						}
-					} else {
-						log.Fatalf("Must specify a destination")
-					}
-				} else {
-					log.Fatalf("Must specify a source")
+					},
+				}, {
+					Name:      `apply`,
+					Usage:     `Create and migrate collections to match a schema file.`,
+					ArgsUsage: `SCHEMA_FILE CONNECTION_STRING`,
+					Action: func(c *cli.Context) {
+						if err := schemaApply(c); err != nil {
+							log.Fatalf("schema apply failed: %v", err)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:      `seed`,
+			Usage:     `Load fixture files (or directories of them) into a datasource.`,
+			ArgsUsage: `CONNECTION_STRING FIXTURES...`,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  `dry-run, n`,
+					Usage: `Validate the fixtures and show what would be loaded without writing anything.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := seed(c); err != nil {
+					log.Fatalf("seed failed: %v", err)
 				}
-
-				collections := c.StringSlice(`collection`)
-
-				if len(collections) == 0 {
-					if c, err := source.ListCollections(); err == nil {
-						collections = c
-					} else {
-						log.Fatalf("failed to list source collections: %v", err)
-					}
+			},
+		},
+		{
+			Name:      `query`,
+			Usage:     `Query a collection and write the results in a format suitable for scripts and pipelines.`,
+			ArgsUsage: `CONNECTION_STRING COLLECTION`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `query, q`,
+					Usage: `The filter to apply (e.g.: "status/active").`,
+					Value: `all`,
+				},
+				cli.StringFlag{
+					Name:  `output, o`,
+					Usage: `The output format: table, json, csv, ndjson, parquet, or avro.`,
+					Value: `table`,
+				},
+				cli.StringSliceFlag{
+					Name:  `fields, f`,
+					Usage: `Only output the given fields (comma-separated, can be specified multiple times).`,
+				},
+				cli.StringSliceFlag{
+					Name:  `sort, S`,
+					Usage: `Sort by the given fields; prefix a field with "-" for descending order.`,
+				},
+				cli.IntFlag{
+					Name:  `limit, l`,
+					Usage: `The maximum number of records to output (0 for no limit).`,
+				},
+				cli.IntFlag{
+					Name:  `offset`,
+					Usage: `The number of records to skip.`,
+				},
+				cli.IntFlag{
+					Name:  `page-size`,
+					Usage: `The number of records to request from the backend at a time.`,
+					Value: QueryDefaultPageSize,
+				},
+				cli.BoolFlag{
+					Name:  `no-header, H`,
+					Usage: `Omit the header row from table and CSV output.`,
+				},
+				cli.IntFlag{
+					Name:  `parallelism, P`,
+					Usage: `The maximum number of partitions of the collection to query concurrently (if supported by the backend).`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := query(c); err != nil {
+					log.Fatalf("query failed: %v", err)
 				}
-
-				log.Debugf("Copying %d collections", len(collections))
-
-				for _, name := range collections {
-					if collection, err := source.GetCollection(name); err == nil {
-						if indexer := source.WithSearch(collection); indexer != nil {
-							var destCollection *dal.Collection
-
-							if dc, err := destination.GetCollection(name); err == nil {
-								destCollection = dc
-
-							} else if dal.IsCollectionNotFoundErr(err) {
-								if err := destination.CreateCollection(collection); err == nil {
-									destCollection = collection
-								} else {
-									log.Errorf("Cannot create destination collection %q: %v", name, err)
-									continue
-								}
-							} else {
-								log.Errorf("Cannot import to destination collection %q: %v", name, err)
-								continue
-							}
-
-							if diffs := destCollection.Diff(collection); len(diffs) == 0 || c.Bool(`no-schema-check`) {
-								sourceItem := mapper.NewModel(source, collection)
-								var i int
-
-								if err := sourceItem.Each(&dal.Record{}, func(ptrToInstance interface{}, err error) {
-									if newRecord, ok := ptrToInstance.(*dal.Record); ok && err == nil {
-										if err := destination.Insert(name, dal.NewRecordSet(newRecord)); err == nil {
-											i += 1
-											log.Debugf("Copied record %v", newRecord.ID)
-										} else {
-											log.Warningf("failed to write record to destination: %v", err)
-										}
-									} else if !ok {
-										log.Warningf("failed to copy record: invalid return type %T", ptrToInstance)
-									} else {
-										log.Warningf("failed to copy record: %v", i, err)
-									}
-								}); err == nil {
-									log.Noticef("Successfully copied %d records from collection %q", i, name)
-								} else {
-									log.Errorf("Failed to copy collection %q: %v", name, err)
-								}
-							} else {
-								log.Errorf("Cannot import to destination collection %q: collections differ", name)
-
-								for _, diff := range diffs {
-									log.Errorf("  %v", diff)
-								}
-							}
-						} else {
-							log.Errorf("Cannot export source collection %q: collection is not enumerable", name)
+			},
+		},
+		{
+			Name:  `codegen`,
+			Usage: `Generate code and schema definitions from collections.`,
+			Subcommands: []cli.Command{
+				{
+					Name:      `structs`,
+					Usage:     `Generate Go types for the collections in the given --schema files or datasource.`,
+					ArgsUsage: `[CONNECTION_STRING]`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  `output, o`,
+							Usage: `The file to write to (default: standard output).`,
+						},
+						cli.StringFlag{
+							Name:  `package, p`,
+							Usage: `The name of the package the generated code belongs to.`,
+							Value: `models`,
+						},
+						cli.StringSliceFlag{
+							Name:  `collection, c`,
+							Usage: `A collection (or wildcard pattern) to generate types for (can be specified multiple times).`,
+						},
+						cli.BoolFlag{
+							Name:  `accessors`,
+							Usage: `Generate getter and setter methods for each field.`,
+						},
+						cli.BoolFlag{
+							Name:  `hooks`,
+							Usage: `Generate Validate() method stubs to be filled in by hand.`,
+						},
+					},
+					Action: func(c *cli.Context) {
+						if err := codegenStructs(c); err != nil {
+							log.Fatalf("codegen failed: %v", err)
 						}
-					} else {
-						log.Errorf("Cannot export source collection %q: %v", name, err)
-					}
+					},
+				}, {
+					Name:      `schema`,
+					Usage:     `Generate a schema file describing the collections in an existing datasource.`,
+					ArgsUsage: `CONNECTION_STRING`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  `output, o`,
+							Usage: `The file to write to (default: standard output).`,
+						},
+						cli.StringFlag{
+							Name:  `format, f`,
+							Usage: `The format of the schema file: "json" or "yaml".`,
+							Value: `json`,
+						},
+						cli.StringFlag{
+							Name:  `split`,
+							Usage: `Write each collection to its own file in the given directory.`,
+						},
+						cli.StringSliceFlag{
+							Name:  `collection, c`,
+							Usage: `A collection (or wildcard pattern) to include (can be specified multiple times).`,
+						},
+						cli.BoolFlag{
+							Name:  `include-internal`,
+							Usage: `Include collections used internally by pivot (e.g.: migration state).`,
+						},
+					},
+					Action: func(c *cli.Context) {
+						if err := codegenSchema(c); err != nil {
+							log.Fatalf("codegen failed: %v", err)
+						}
+					},
+				}, {
+					Name:      `proto`,
+					Usage:     `Generate protobuf messages for the collections in the given --schema files or datasource.`,
+					ArgsUsage: `[CONNECTION_STRING]`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  `output, o`,
+							Usage: `The file to write to (default: standard output).`,
+						},
+						cli.StringFlag{
+							Name:  `package, p`,
+							Usage: `The protobuf package the generated messages belong to.`,
+							Value: `pivot`,
+						},
+						cli.StringFlag{
+							Name:  `go-package`,
+							Usage: `The Go import path of the package Go bindings are generated into.`,
+						},
+						cli.StringFlag{
+							Name:  `go-out`,
+							Usage: `Also generate Go bindings into the given directory using protoc (requires --output).`,
+						},
+						cli.StringSliceFlag{
+							Name:  `collection, c`,
+							Usage: `A collection (or wildcard pattern) to generate messages for (can be specified multiple times).`,
+						},
+					},
+					Action: func(c *cli.Context) {
+						if err := codegenProto(c); err != nil {
+							log.Fatalf("codegen failed: %v", err)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:      `watch`,
+			Usage:     `Print changes to the records in a collection as newline-delimited JSON.`,
+			ArgsUsage: `CONNECTION_STRING COLLECTION`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `query, q`,
+					Usage: `Only report changes to records matching the given filter.`,
+					Value: `all`,
+				},
+				cli.DurationFlag{
+					Name:  `interval, i`,
+					Usage: `How often to check for changes if the backend does not provide a change feed.`,
+					Value: backends.DefaultWatchPollInterval,
+				},
+				cli.BoolFlag{
+					Name:  `initial`,
+					Usage: `Report all matching records as created when starting (polling only).`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := watch(c); err != nil {
+					log.Fatalf("watch failed: %v", err)
+				}
+			},
+		},
+		{
+			Name:  `migrate`,
+			Usage: `Apply or revert versioned migrations.`,
+			Subcommands: []cli.Command{
+				{
+					Name:      `up`,
+					Usage:     `Apply pending migrations.`,
+					ArgsUsage: `CONNECTION_STRING`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  `dir, d`,
+							Usage: `The directory containing migration files.`,
+							Value: `./migrations`,
+						},
+						cli.IntFlag{
+							Name:  `steps, n`,
+							Usage: `The maximum number of migrations to apply (0 for all).`,
+						},
+					},
+					Action: func(c *cli.Context) {
+						if err := migrateUp(c); err != nil {
+							log.Fatalf("migrate failed: %v", err)
+						}
+					},
+				}, {
+					Name:      `down`,
+					Usage:     `Revert the most recently applied migrations.`,
+					ArgsUsage: `CONNECTION_STRING`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  `dir, d`,
+							Usage: `The directory containing migration files.`,
+							Value: `./migrations`,
+						},
+						cli.IntFlag{
+							Name:  `steps, n`,
+							Usage: `The number of migrations to revert (0 for all).`,
+							Value: 1,
+						},
+					},
+					Action: func(c *cli.Context) {
+						if err := migrateDown(c); err != nil {
+							log.Fatalf("migrate failed: %v", err)
+						}
+					},
+				}, {
+					Name:      `status`,
+					Usage:     `Show which migrations have been applied.`,
+					ArgsUsage: `CONNECTION_STRING`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  `dir, d`,
+							Usage: `The directory containing migration files.`,
+							Value: `./migrations`,
+						},
+					},
+					Action: func(c *cli.Context) {
+						if err := migrateStatus(c); err != nil {
+							log.Fatalf("migrate failed: %v", err)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:      `reindex`,
+			Usage:     `Rebuild the search index of one or more collections from the records in the datasource.`,
+			ArgsUsage: `CONNECTION_STRING [COLLECTION...]`,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  `parallel, p`,
+					Usage: `The number of collections to reindex concurrently.`,
+					Value: 1,
+				},
+				cli.IntFlag{
+					Name:  `batch-size, b`,
+					Usage: `The number of records to submit to the indexer at a time.`,
+					Value: backends.DefaultIndexRebuildBatchSize,
+				},
+				cli.BoolFlag{
+					Name:  `clear`,
+					Usage: `Remove all existing documents from each index before rebuilding it.`,
+				},
+				cli.BoolFlag{
+					Name:  `quiet, q`,
+					Usage: `Do not report progress.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := reindex(c); err != nil {
+					log.Fatalf("reindex failed: %v", err)
+				}
+			},
+		},
+		{
+			Name:      `explain`,
+			Usage:     `Show the native query a filter is rendered into and how the database will execute it.`,
+			ArgsUsage: `CONNECTION_STRING COLLECTION`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `query, q`,
+					Usage: `The filter to explain (e.g.: "status/active").`,
+					Value: `all`,
+				},
+				cli.IntFlag{
+					Name:  `limit, l`,
+					Usage: `Explain the query with the given result limit applied.`,
+				},
+				cli.StringFlag{
+					Name:  `format, f`,
+					Usage: `The output format: "text" or "json".`,
+					Value: `text`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := explain(c); err != nil {
+					log.Fatalf("explain failed: %v", err)
+				}
+			},
+		},
+		{
+			Name:      `validate`,
+			Usage:     `Check that the records in a datasource satisfy their collection schemas.`,
+			ArgsUsage: `CONNECTION_STRING [COLLECTION...]`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `output, o`,
+					Usage: `The output format: "table" or "ndjson".`,
+					Value: `table`,
+				},
+				cli.IntFlag{
+					Name:  `max-errors, m`,
+					Usage: `Stop after finding this many invalid records (0 for no limit).`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := validate(c); err != nil {
+					log.Fatalf("validate failed: %v", err)
+				}
+			},
+		},
+		{
+			Name:      `bench`,
+			Usage:     `Measure the throughput and latency of common operations against a datasource.`,
+			ArgsUsage: `CONNECTION_STRING`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `collection, c`,
+					Usage: `The name of the (temporary) collection to create and run the workloads against.`,
+					Value: `pivot_bench`,
+				},
+				cli.StringFlag{
+					Name:  `workloads, w`,
+					Usage: `A comma-separated list of workloads to run in order (insert, retrieve, query, update, delete).`,
+					Value: `insert,retrieve,query`,
+				},
+				cli.IntFlag{
+					Name:  `records, n`,
+					Usage: `The number of records to insert (and retrieve, update, or delete).`,
+					Value: 1000,
+				},
+				cli.IntFlag{
+					Name:  `queries`,
+					Usage: `The number of queries to run in the query workload.`,
+					Value: 100,
+				},
+				cli.IntFlag{
+					Name:  `query-limit`,
+					Usage: `The maximum number of results returned by each query.`,
+					Value: 25,
+				},
+				cli.IntFlag{
+					Name:  `concurrency, C`,
+					Usage: `The number of operations to run in parallel.`,
+					Value: 1,
+				},
+				cli.BoolFlag{
+					Name:  `keep`,
+					Usage: `Do not remove the collection after the benchmark completes.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := bench(c); err != nil {
+					log.Fatalf("bench failed: %v", err)
+				}
+			},
+		},
+		{
+			Name:      `anonymize`,
+			Usage:     `Rewrite personally-identifiable fields in place according to a rules file.`,
+			ArgsUsage: `CONNECTION_STRING`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `rules, r`,
+					Usage: `A JSON or YAML file mapping collections and fields to anonymization rules.`,
+				},
+				cli.StringFlag{
+					Name:  `salt`,
+					Usage: `The salt used to derive anonymized values (default: random).`,
+				},
+				cli.IntFlag{
+					Name:  `batch-size, b`,
+					Usage: `The number of records to update at a time.`,
+					Value: pivot.DefaultRestoreBatchSize,
+				},
+				cli.BoolFlag{
+					Name:  `dry-run, n`,
+					Usage: `Print the anonymized records instead of writing them.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := anonymize(c); err != nil {
+					log.Fatalf("anonymize failed: %v", err)
+				}
+			},
+		},
+		{
+			Name:      `copy`,
+			Usage:     `Copies data from one datasource to another`,
+			ArgsUsage: `[SOURCE DESTINATION]`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `from`,
+					Usage: `The connection string of the source datasource.`,
+				},
+				cli.StringFlag{
+					Name:  `to`,
+					Usage: `The connection string of the destination datasource.`,
+				},
+				cli.StringSliceFlag{
+					Name:  `collections, collection, c`,
+					Usage: `Collections (or wildcard patterns, comma-separated) to copy (can be specified multiple times).`,
+				},
+				cli.BoolFlag{
+					Name:  `no-schema-check, S`,
+					Usage: `Skip verifying schema equality.`,
+				},
+				cli.BoolFlag{
+					Name:  `no-create`,
+					Usage: `Do not create collections that do not exist in the destination.`,
+				},
+				cli.IntFlag{
+					Name:  `batch-size, b`,
+					Usage: `The number of records to write to the destination at a time.`,
+					Value: pivot.DefaultRestoreBatchSize,
+				},
+				cli.BoolFlag{
+					Name:  `dry-run, n`,
+					Usage: `Show what would be copied without writing anything to the destination.`,
+				},
+				cli.StringFlag{
+					Name:  `anonymize, A`,
+					Usage: `Rewrite fields according to the given anonymization rules file while copying.`,
+				},
+				cli.StringFlag{
+					Name:  `salt`,
+					Usage: `The salt used to derive anonymized values (default: random).`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if err := copyCollections(c); err != nil {
+					log.Fatalf("copy failed: %v", err)
 				}
 			},
 		},
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot"
+)
+
+func newMigrator(c *cli.Context) (*pivot.Migrator, error) {
+	db, err := connect(c, c.Args().First())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if migrations, err := pivot.LoadMigrations(c.String(`dir`)); err == nil {
+		return pivot.NewMigrator(db, migrations), nil
+	} else {
+		return nil, err
+	}
+}
+
+// Acquires the migration lock, runs the given function, and releases the lock.
+func withMigrationLock(migrator *pivot.Migrator, fn func() ([]*pivot.Migration, error)) error {
+	if err := migrator.Lock(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := migrator.Unlock(); err != nil {
+			log.Warningf("failed to release migration lock: %v", err)
+		}
+	}()
+
+	migrations, err := fn()
+
+	for _, migration := range migrations {
+		fmt.Printf("%d\t%s\n", migration.Version, migration.Name)
+	}
+
+	return err
+}
+
+func migrateUp(c *cli.Context) error {
+	if migrator, err := newMigrator(c); err == nil {
+		return withMigrationLock(migrator, func() ([]*pivot.Migration, error) {
+			return migrator.Up(c.Int(`steps`))
+		})
+	} else {
+		return err
+	}
+}
+
+func migrateDown(c *cli.Context) error {
+	if migrator, err := newMigrator(c); err == nil {
+		return withMigrationLock(migrator, func() ([]*pivot.Migration, error) {
+			return migrator.Down(c.Int(`steps`))
+		})
+	} else {
+		return err
+	}
+}
+
+func migrateStatus(c *cli.Context) error {
+	migrator, err := newMigrator(c)
+
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrator.Status()
+
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+
+	for _, status := range statuses {
+		if status.Applied {
+			fmt.Fprintf(tw, "%d\t%s\tapplied\t%s\n", status.Migration.Version, status.Migration.Name, status.AppliedAt.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(tw, "%d\t%s\tpending\t-\n", status.Migration.Version, status.Migration.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/dal/encoding"
+	"github.com/ghetzel/pivot/filter"
+)
+
+var QueryDefaultPageSize = 1000
+
+// Writes query results to an output stream in a particular format.  Formats that can be streamed
+// write each record as it arrives; the others buffer records until Close is called.
+type recordWriter struct {
+	format     string
+	out        io.Writer
+	collection *dal.Collection
+	fields     []string
+	header     bool
+	records    []*dal.Record
+	csv        *csv.Writer
+	parquet    *encoding.ParquetWriter
+	avro       *encoding.AvroWriter
+	columns    []string
+	count      int
+}
+
+func newRecordWriter(out io.Writer, format string, collection *dal.Collection, fields []string, header bool) (*recordWriter, error) {
+	switch format {
+	case `table`, `json`, `ndjson`, `csv`, `parquet`, `avro`:
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+
+	return &recordWriter{
+		format:     format,
+		out:        out,
+		collection: collection,
+		fields:     fields,
+		header:     header,
+	}, nil
+}
+
+// Returns a copy of the given record containing only the selected fields.
+func (self *recordWriter) selectFields(record *dal.Record) *dal.Record {
+	if len(self.fields) == 0 {
+		return record
+	}
+
+	selected := dal.NewRecord(record.ID)
+
+	for _, field := range self.fields {
+		if field != `id` {
+			selected.Set(field, record.Get(field))
+		}
+	}
+
+	return selected
+}
+
+func (self *recordWriter) Write(record *dal.Record) error {
+	record = self.selectFields(record)
+	self.count += 1
+
+	switch self.format {
+	case `ndjson`:
+		if data, err := json.Marshal(record); err == nil {
+			_, err = self.out.Write(append(data, '\n'))
+			return err
+		} else {
+			return err
+		}
+
+	case `csv`:
+		if self.csv == nil {
+			self.csv = csv.NewWriter(self.out)
+			self.columns = self.fieldColumns([]*dal.Record{record})
+
+			if self.header {
+				if err := self.csv.Write(self.columns); err != nil {
+					return err
+				}
+			}
+		}
+
+		row := make([]string, len(self.columns))
+
+		for i, column := range self.columns {
+			if value := recordValue(record, column); value != nil {
+				row[i] = fmt.Sprintf("%v", value)
+			}
+		}
+
+		return self.csv.Write(row)
+
+	case `parquet`:
+		if self.parquet == nil {
+			if pw, err := encoding.NewParquetWriter(self.out, self.collection); err == nil {
+				self.parquet = pw
+			} else {
+				return err
+			}
+		}
+
+		return self.parquet.Write(record)
+
+	case `avro`:
+		if self.avro == nil {
+			if aw, err := encoding.NewAvroWriter(self.out, self.collection); err == nil {
+				self.avro = aw
+			} else {
+				return err
+			}
+		}
+
+		return self.avro.Write(record)
+
+	default:
+		self.records = append(self.records, record)
+		return nil
+	}
+}
+
+func (self *recordWriter) Close() error {
+	switch self.format {
+	case `csv`:
+		if self.csv != nil {
+			self.csv.Flush()
+			return self.csv.Error()
+		}
+
+	case `parquet`:
+		if self.parquet == nil {
+			// write an empty file, which still carries the schema
+			if pw, err := encoding.NewParquetWriter(self.out, self.collection); err == nil {
+				self.parquet = pw
+			} else {
+				return err
+			}
+		}
+
+		return self.parquet.Close()
+
+	case `avro`:
+		if self.avro == nil {
+			// write an empty file, which still carries the schema
+			if aw, err := encoding.NewAvroWriter(self.out, self.collection); err == nil {
+				self.avro = aw
+			} else {
+				return err
+			}
+		}
+
+		return self.avro.Close()
+
+	case `json`:
+		records := self.records
+
+		if records == nil {
+			records = make([]*dal.Record, 0)
+		}
+
+		encoder := json.NewEncoder(self.out)
+		encoder.SetIndent(``, `  `)
+		return encoder.Encode(records)
+
+	case `table`:
+		writeRecordTable(self.out, self.fieldColumns(self.records), self.records, self.header)
+	}
+
+	return nil
+}
+
+func (self *recordWriter) fieldColumns(records []*dal.Record) []string {
+	if len(self.fields) > 0 {
+		columns := []string{`id`}
+
+		for _, field := range self.fields {
+			if field != `id` {
+				columns = append(columns, field)
+			}
+		}
+
+		return columns
+	}
+
+	return recordColumns(self.collection, records)
+}
+
+func splitFields(values []string) []string {
+	fields := make([]string, 0)
+
+	for _, value := range values {
+		for _, field := range strings.Split(value, `,`) {
+			if field = strings.TrimSpace(field); field != `` {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	return fields
+}
+
+func query(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("Must specify a connection string and a collection")
+	}
+
+	db, err := connect(c, c.Args().Get(0))
+
+	if err != nil {
+		return err
+	}
+
+	collection, err := db.GetCollection(c.Args().Get(1))
+
+	if err != nil {
+		return err
+	}
+
+	spec := c.String(`query`)
+
+	if spec == `` {
+		spec = filter.AllValue
+	}
+
+	f, err := filter.Parse(spec)
+
+	if err != nil {
+		return err
+	}
+
+	f.IdentityField = collection.IdentityField
+	f.Parallelism = c.Int(`parallelism`)
+
+	if sort := splitFields(c.StringSlice(`sort`)); len(sort) > 0 {
+		f.Sort = sort
+	}
+
+	search := db.WithSearch(collection, f)
+
+	if search == nil {
+		return fmt.Errorf("backend %T does not support queries", db)
+	}
+
+	fields := splitFields(c.StringSlice(`fields`))
+	writer, err := newRecordWriter(os.Stdout, c.String(`output`), collection, fields, !c.Bool(`no-header`))
+
+	if err != nil {
+		return err
+	}
+
+	if err := writeQueryResults(search, collection, f, writer, c.Int(`limit`), c.Int(`offset`), c.Int(`page-size`)); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// Pages through the results of the given query, writing each record to the writer.  Paging stops
+// once the limit (if any) is reached or a short page is returned.
+func writeQueryResults(search backends.Indexer, collection *dal.Collection, f *filter.Filter, writer *recordWriter, limit int, offset int, pageSize int) error {
+	if pageSize <= 0 {
+		pageSize = QueryDefaultPageSize
+	}
+
+	for {
+		f.Offset = offset
+		f.Limit = pageSize
+
+		if limit > 0 {
+			if remaining := limit - writer.count; remaining <= 0 {
+				break
+			} else if remaining < f.Limit {
+				f.Limit = remaining
+			}
+		}
+
+		if recordset, err := search.Query(collection, f); err == nil {
+			for _, record := range recordset.Records {
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+			}
+
+			if len(recordset.Records) < f.Limit {
+				break
+			}
+
+			offset += len(recordset.Records)
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitFields(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal([]string{}, splitFields(nil))
+	assert.Equal([]string{`name`, `age`, `id`}, splitFields([]string{`name, age`, ``, `id,`}))
+}
+
+func TestRecordWriter(t *testing.T) {
+	assert := require.New(t)
+	var out bytes.Buffer
+
+	collection := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+	}, dal.Field{
+		Name: `age`,
+	})
+
+	records := []*dal.Record{
+		dal.NewRecord(1).Set(`name`, `alice`).Set(`age`, 31),
+		dal.NewRecord(2).Set(`name`, `bob`),
+	}
+
+	write := func(format string, fields []string, header bool) string {
+		out.Reset()
+
+		writer, err := newRecordWriter(&out, format, collection, fields, header)
+		assert.NoError(err)
+
+		for _, record := range records {
+			assert.NoError(writer.Write(record))
+		}
+
+		assert.NoError(writer.Close())
+		assert.Equal(len(records), writer.count)
+
+		return out.String()
+	}
+
+	assert.Equal("id,name,age\n1,alice,31\n2,bob,\n", write(`csv`, nil, true))
+	assert.Equal("1,alice\n2,bob\n", write(`csv`, []string{`id`, `name`}, false))
+
+	lines := strings.Split(strings.TrimSpace(write(`ndjson`, []string{`age`}, true)), "\n")
+	assert.Len(lines, 2)
+
+	var record dal.Record
+	assert.NoError(json.Unmarshal([]byte(lines[0]), &record))
+	assert.EqualValues(1, record.ID)
+	assert.EqualValues(31, record.Get(`age`))
+	assert.Nil(record.Get(`name`))
+
+	var all []*dal.Record
+	assert.NoError(json.Unmarshal([]byte(write(`json`, nil, true)), &all))
+	assert.Len(all, 2)
+	assert.Equal(`bob`, all[1].Get(`name`))
+
+	table := strings.Split(strings.TrimSpace(write(`table`, nil, true)), "\n")
+	assert.Len(table, 3)
+	assert.Equal([]string{`ID`, `NAME`, `AGE`}, strings.Fields(table[0]))
+	assert.Equal([]string{`2`, `bob`, `-`}, strings.Fields(table[2]))
+
+	// empty results are still valid output
+	records = nil
+	assert.Equal("[]\n", write(`json`, nil, true))
+	assert.Equal(``, write(`csv`, nil, true))
+
+	_, err := newRecordWriter(&out, `xml`, collection, nil, true)
+	assert.Error(err)
+}
+
+func TestWriteQueryResults(t *testing.T) {
+	assert := require.New(t)
+
+	mock, err := newTestBackend()
+	assert.NoError(err)
+
+	collection, err := mock.GetCollection(`users`)
+	assert.NoError(err)
+
+	run := func(limit int, offset int, pageSize int) []interface{} {
+		var out bytes.Buffer
+
+		mock, err = newTestBackend()
+		assert.NoError(err)
+
+		writer, err := newRecordWriter(&out, `ndjson`, collection, []string{`id`}, false)
+		assert.NoError(err)
+		assert.NoError(writeQueryResults(mock, collection, filter.All(), writer, limit, offset, pageSize))
+
+		ids := make([]interface{}, 0)
+
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line != `` {
+				var record dal.Record
+				assert.NoError(json.Unmarshal([]byte(line), &record))
+				ids = append(ids, record.ID)
+			}
+		}
+
+		return ids
+	}
+
+	// 3 records in pages of 2 takes two queries
+	assert.Equal([]interface{}{float64(1), float64(2), float64(3)}, run(0, 0, 2))
+	assert.Len(mock.CallsTo(`Query`), 2)
+
+	// full pages need one more query to find the end
+	assert.Len(run(0, 0, 3), 3)
+	assert.Len(mock.CallsTo(`Query`), 2)
+
+	// limits stop paging early
+	assert.Equal([]interface{}{float64(1), float64(2)}, run(2, 0, 1))
+	assert.Len(mock.CallsTo(`Query`), 2)
+
+	assert.Equal([]interface{}{float64(2), float64(3)}, run(0, 1, 0))
+	assert.Len(mock.CallsTo(`Query`), 1)
+
+	assert.Empty(run(0, 5, 10))
+}
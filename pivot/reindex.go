@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot"
+)
+
+func reindex(c *cli.Context) error {
+	db, err := connect(c, c.Args().First())
+
+	if err != nil {
+		return err
+	}
+
+	collections, err := selectCollections(db, c.Args().Tail())
+
+	if err != nil {
+		return err
+	}
+
+	err = pivot.Reindex(db, collections, reindexOptions(c))
+
+	if !c.Bool(`quiet`) {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return err
+}
+
+func reindexOptions(c *cli.Context) pivot.ReindexOptions {
+	options := pivot.ReindexOptions{
+		Parallel:  c.Int(`parallel`),
+		BatchSize: c.Int(`batch-size`),
+		Clear:     c.Bool(`clear`),
+	}
+
+	if !c.Bool(`quiet`) {
+		options.Progress = progressReporter(`reindexed`)
+	}
+
+	return options
+}
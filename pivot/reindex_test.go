@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"testing"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a context for the reindex command with the given arguments
+func newReindexContext(args ...string) (*cli.Context, error) {
+	set := flag.NewFlagSet(`reindex`, flag.ContinueOnError)
+	set.Int(`parallel`, 1, ``)
+	set.Int(`batch-size`, 100, ``)
+	set.Bool(`clear`, false, ``)
+	set.Bool(`quiet`, false, ``)
+
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return cli.NewContext(nil, set, nil), nil
+}
+
+func TestReindexOptions(t *testing.T) {
+	assert := require.New(t)
+
+	c, err := newReindexContext()
+	assert.NoError(err)
+
+	options := reindexOptions(c)
+	assert.Equal(1, options.Parallel)
+	assert.Equal(100, options.BatchSize)
+	assert.False(options.Clear)
+	assert.NotNil(options.Progress)
+
+	c, err = newReindexContext(`-parallel`, `4`, `-batch-size`, `10`, `-clear`, `-quiet`)
+	assert.NoError(err)
+
+	options = reindexOptions(c)
+	assert.Equal(4, options.Parallel)
+	assert.Equal(10, options.BatchSize)
+	assert.True(options.Clear)
+	assert.Nil(options.Progress)
+}
+
+func TestReindex(t *testing.T) {
+	assert := require.New(t)
+
+	c, err := newReindexContext(`-quiet`)
+	assert.NoError(err)
+	assert.Error(reindex(c))
+
+	// the mock backend has no index to rebuild
+	c, err = newReindexContext(`-quiet`, `mock://`)
+	assert.NoError(err)
+
+	err = reindex(c)
+	assert.Error(err)
+	assert.True(errors.Is(err, dal.ErrUnsupported))
+}
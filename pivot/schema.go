@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+)
+
+// A planned change to bring a collection in line with its schema definition.
+type schemaChange struct {
+	Definition *dal.Collection
+	Create     bool
+	Deltas     []dal.SchemaDelta
+}
+
+// Returns whether the given delta can be applied by the migration engine.  Only adding fields is
+// supported; all other changes must be made by hand.
+func isSupportedDelta(delta dal.SchemaDelta) bool {
+	return delta.Issue == dal.FieldMissingIssue
+}
+
+// Loads the schema definitions in the given file and compares them to the collections that exist
+// in the given backend.
+func planSchemaChanges(filename string, db backends.Backend) ([]schemaChange, error) {
+	definitions, err := pivot.LoadSchemataFromFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]schemaChange, 0)
+
+	for _, definition := range definitions {
+		if actual, err := db.GetCollection(definition.Name); err == nil {
+			if deltas := definition.Diff(actual); len(deltas) > 0 {
+				changes = append(changes, schemaChange{
+					Definition: definition,
+					Deltas:     deltas,
+				})
+			}
+		} else if dal.IsCollectionNotFoundErr(err) {
+			changes = append(changes, schemaChange{
+				Definition: definition,
+				Create:     true,
+			})
+		} else {
+			return nil, fmt.Errorf("collection %q: %v", definition.Name, err)
+		}
+	}
+
+	return changes, nil
+}
+
+func printSchemaChanges(changes []schemaChange) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "COLLECTION\tISSUE\tFIELD\tPARAMETER\tDESIRED\tACTUAL\tSUPPORTED")
+
+	for _, change := range changes {
+		if change.Create {
+			fmt.Fprintf(tw, "%s\tcreate-collection\t-\t-\t-\t-\tyes\n", change.Definition.Name)
+			continue
+		}
+
+		for _, delta := range change.Deltas {
+			supported := `yes`
+
+			if !isSupportedDelta(delta) {
+				supported = `NO`
+			}
+
+			fmt.Fprintf(
+				tw,
+				"%s\t%v\t%s\t%s\t%s\t%s\t%s\n",
+				change.Definition.Name,
+				delta.Issue,
+				orDash(delta.Name),
+				orDash(delta.Parameter),
+				orDash(valueString(delta.Desired)),
+				orDash(valueString(delta.Actual)),
+				supported,
+			)
+		}
+	}
+}
+
+func valueString(value interface{}) string {
+	if value == nil {
+		return ``
+	}
+
+	return truncate(fmt.Sprintf("%v", value), 32)
+}
+
+func orDash(in string) string {
+	if in == `` {
+		return `-`
+	}
+
+	return in
+}
+
+func schemaDiff(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: schema diff SCHEMA_FILE CONNECTION_STRING")
+	}
+
+	db, err := pivot.NewDatabase(c.Args().Get(1))
+
+	if err != nil {
+		return err
+	}
+
+	changes, err := planSchemaChanges(c.Args().Get(0), db)
+
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("Schema is up to date.")
+		return nil
+	}
+
+	printSchemaChanges(changes)
+	return nil
+}
+
+func schemaApply(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: schema apply SCHEMA_FILE CONNECTION_STRING")
+	}
+
+	db, err := pivot.NewDatabase(c.Args().Get(1))
+
+	if err != nil {
+		return err
+	}
+
+	changes, err := planSchemaChanges(c.Args().Get(0), db)
+
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("Schema is up to date.")
+		return nil
+	}
+
+	printSchemaChanges(changes)
+	return applySchemaChanges(db, changes)
+}
+
+// Creates and migrates collections according to the given changes.  Nothing is applied if any of
+// the changes are unsupported by the migration engine.
+func applySchemaChanges(db backends.Backend, changes []schemaChange) error {
+	var migrations int
+	var unsupported int
+
+	for _, change := range changes {
+		if change.Create {
+			continue
+		}
+
+		migrations += 1
+
+		for _, delta := range change.Deltas {
+			if !isSupportedDelta(delta) {
+				log.Errorf("  %v", delta)
+				unsupported += 1
+			}
+		}
+	}
+
+	if unsupported > 0 {
+		return dal.Unsupported("%d schema changes cannot be applied automatically and must be made by hand", unsupported)
+	}
+
+	migratable, ok := db.(backends.Migratable)
+
+	if migrations > 0 && !ok {
+		return dal.Unsupported("backend %T does not support schema changes", db)
+	}
+
+	for _, change := range changes {
+		if change.Create {
+			if err := db.CreateCollection(change.Definition); err == nil {
+				log.Noticef("Created collection %q", change.Definition.Name)
+			} else {
+				return fmt.Errorf("collection %q: %v", change.Definition.Name, err)
+			}
+
+			continue
+		}
+
+		db.RegisterCollection(change.Definition)
+
+		if err := migratable.Migrate(change.Deltas); err == nil {
+			log.Noticef("Migrated collection %q (%d changes)", change.Definition.Name, len(change.Deltas))
+		} else {
+			return fmt.Errorf("collection %q: %v", change.Definition.Name, err)
+		}
+	}
+
+	return nil
+}
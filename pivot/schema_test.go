@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// a mock backend that records the migrations applied to it
+type migratableBackend struct {
+	*backends.MockBackend
+	migrations [][]dal.SchemaDelta
+}
+
+func (self *migratableBackend) Migrate(diff []dal.SchemaDelta) error {
+	self.migrations = append(self.migrations, diff)
+	return nil
+}
+
+// writes the given collection definitions to a schema file in the given directory
+func writeSchemaFile(dir string, collections ...*dal.Collection) (string, error) {
+	filename := filepath.Join(dir, `schema.json`)
+
+	if data, err := json.Marshal(collections); err == nil {
+		return filename, ioutil.WriteFile(filename, data, 0644)
+	} else {
+		return ``, err
+	}
+}
+
+func TestSchemaChanges(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-schema-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename, err := writeSchemaFile(dir, dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `email`,
+		Type: dal.StringType,
+	}), dal.NewCollection(`groups`))
+
+	assert.NoError(err)
+
+	mock := backends.NewMockBackend()
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	changes, err := planSchemaChanges(filename, mock)
+	assert.NoError(err)
+	assert.Len(changes, 2)
+
+	assert.Equal(`users`, changes[0].Definition.Name)
+	assert.False(changes[0].Create)
+	assert.Len(changes[0].Deltas, 1)
+	assert.Equal(dal.FieldMissingIssue, changes[0].Deltas[0].Issue)
+	assert.Equal(`email`, changes[0].Deltas[0].Name)
+
+	assert.Equal(`groups`, changes[1].Definition.Name)
+	assert.True(changes[1].Create)
+
+	// backends that can't migrate collections are rejected before anything is created
+	err = applySchemaChanges(mock, changes)
+	assert.Error(err)
+	assert.True(errors.Is(err, dal.ErrUnsupported))
+	assert.Len(mock.CallsTo(`CreateCollection`), 1)
+
+	db := &migratableBackend{
+		MockBackend: mock,
+	}
+
+	assert.NoError(applySchemaChanges(db, changes))
+	assert.Len(mock.CallsTo(`CreateCollection`), 2)
+	assert.Equal([][]dal.SchemaDelta{changes[0].Deltas}, db.migrations)
+
+	_, err = mock.GetCollection(`groups`)
+	assert.NoError(err)
+
+	// nothing to do once the schema is up to date
+	changes, err = planSchemaChanges(filename, mock)
+	assert.NoError(err)
+	assert.Empty(changes)
+}
+
+func TestSchemaChangesUnsupported(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-schema-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename, err := writeSchemaFile(dir, dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.IntType,
+	}), dal.NewCollection(`groups`))
+
+	assert.NoError(err)
+
+	mock := backends.NewMockBackend()
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	changes, err := planSchemaChanges(filename, mock)
+	assert.NoError(err)
+	assert.Len(changes, 2)
+	assert.False(isSupportedDelta(changes[0].Deltas[0]))
+
+	// changing a field's type is reported up front, and nothing is applied
+	db := &migratableBackend{
+		MockBackend: mock,
+	}
+
+	err = applySchemaChanges(db, changes)
+	assert.Error(err)
+	assert.True(errors.Is(err, dal.ErrUnsupported))
+	assert.Empty(db.migrations)
+	assert.Len(mock.CallsTo(`CreateCollection`), 1)
+}
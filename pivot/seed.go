@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghodss/yaml"
+)
+
+// Fixture values of the form "@ref:COLLECTION.LABEL" are replaced with the ID of the fixture record
+// with the given label.
+var FixtureReferencePrefix = `@ref:`
+
+const fixtureLabelKey = `_ref`
+
+// A set of records to be loaded into a collection.  Records may be given a label (using the "_ref"
+// key) so that other fixtures can refer to their IDs.  Records that are referred to must specify
+// their ID explicitly.
+//
+//	collection: orders
+//	records:
+//	- _ref: first
+//	  id: 1
+//	  user_id: "@ref:users.alice"
+//	  total: 42.5
+type FixtureSet struct {
+	Collection string                   `json:"collection"`
+	Definition *dal.Collection          `json:"definition,omitempty"`
+	Records    []map[string]interface{} `json:"records"`
+	source     string
+}
+
+// Loads all fixture sets from the given files and directories.
+func loadFixtures(paths []string) ([]*FixtureSet, error) {
+	var files []string
+	var sets []*FixtureSet
+
+	for _, path := range paths {
+		if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+			if entries, err := ioutil.ReadDir(path); err == nil {
+				for _, entry := range entries {
+					switch filepath.Ext(entry.Name()) {
+					case `.yml`, `.yaml`, `.json`:
+						files = append(files, filepath.Join(path, entry.Name()))
+					}
+				}
+			} else {
+				return nil, err
+			}
+		} else if err == nil {
+			files = append(files, path)
+		} else {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+
+	for _, filename := range files {
+		data, err := ioutil.ReadFile(filename)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var fileSets []*FixtureSet
+
+		// files may contain a single fixture set or a list of them
+		if strings.HasPrefix(strings.TrimSpace(string(data)), `[`) || strings.HasPrefix(strings.TrimSpace(string(data)), `-`) {
+			if err := yaml.Unmarshal(data, &fileSets); err != nil {
+				return nil, fmt.Errorf("%s: %v", filename, err)
+			}
+		} else {
+			var set FixtureSet
+
+			if err := yaml.Unmarshal(data, &set); err != nil {
+				return nil, fmt.Errorf("%s: %v", filename, err)
+			}
+
+			fileSets = append(fileSets, &set)
+		}
+
+		for _, set := range fileSets {
+			if set.Collection == `` {
+				if set.Definition != nil {
+					set.Collection = set.Definition.Name
+				} else {
+					return nil, fmt.Errorf("%s: fixture set must specify a collection", filename)
+				}
+			}
+
+			set.source = filename
+			sets = append(sets, set)
+		}
+	}
+
+	return sets, nil
+}
+
+// Returns the references (as "COLLECTION.LABEL") that the given value depends on.
+func fixtureReferences(value interface{}) []string {
+	refs := make([]string, 0)
+
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, FixtureReferencePrefix) {
+			refs = append(refs, strings.TrimPrefix(v, FixtureReferencePrefix))
+		}
+	case []interface{}:
+		for _, item := range v {
+			refs = append(refs, fixtureReferences(item)...)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			refs = append(refs, fixtureReferences(item)...)
+		}
+	}
+
+	return refs
+}
+
+// Replaces all references in the given value with the IDs they refer to.
+func resolveFixtureReferences(value interface{}, ids map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, FixtureReferencePrefix) {
+			ref := strings.TrimPrefix(v, FixtureReferencePrefix)
+
+			if id, ok := ids[ref]; ok {
+				return id, nil
+			} else {
+				return nil, fmt.Errorf("unknown reference %q", ref)
+			}
+		}
+	case []interface{}:
+		out := make([]interface{}, len(v))
+
+		for i, item := range v {
+			if resolved, err := resolveFixtureReferences(item, ids); err == nil {
+				out[i] = resolved
+			} else {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{})
+
+		for k, item := range v {
+			if resolved, err := resolveFixtureReferences(item, ids); err == nil {
+				out[k] = resolved
+			} else {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	}
+
+	return value, nil
+}
+
+// Loads the given fixture sets into the backend.  Sets are loaded in an order that ensures the
+// records they refer to have already been loaded.
+func seedFixtures(db backends.Backend, sets []*FixtureSet, dryRun bool) error {
+	ids := make(map[string]interface{})
+	pending := sets
+
+	// register the IDs of all labeled records up front so references can be checked
+	for _, set := range sets {
+		for i, record := range set.Records {
+			if label, ok := record[fixtureLabelKey]; ok {
+				ref := fmt.Sprintf("%s.%v", set.Collection, label)
+
+				if _, exists := ids[ref]; exists {
+					return fmt.Errorf("%s: duplicate fixture %q", set.source, ref)
+				}
+
+				if id, ok := record[`id`]; ok {
+					ids[ref] = id
+				} else {
+					return fmt.Errorf("%s: record %d (%q) is referenced by label and must specify an id", set.source, i, ref)
+				}
+			}
+		}
+	}
+
+	done := make(map[*FixtureSet]bool)
+
+	// returns whether any fixtures for the given collection have yet to be loaded
+	unloaded := func(collection string) bool {
+		for _, set := range sets {
+			if set.Collection == collection && !done[set] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for len(pending) > 0 {
+		var remaining []*FixtureSet
+
+		for _, set := range pending {
+			ready := true
+
+			for _, record := range set.Records {
+				for _, ref := range fixtureReferences(map[string]interface{}(record)) {
+					if _, ok := ids[ref]; !ok {
+						return fmt.Errorf("%s: unknown reference %q", set.source, ref)
+					}
+
+					if collection := strings.SplitN(ref, `.`, 2)[0]; collection != set.Collection && unloaded(collection) {
+						ready = false
+					}
+				}
+			}
+
+			if !ready {
+				remaining = append(remaining, set)
+				continue
+			}
+
+			if err := seedFixtureSet(db, set, ids, dryRun); err != nil {
+				return fmt.Errorf("%s: %v", set.source, err)
+			}
+
+			done[set] = true
+		}
+
+		if len(remaining) == len(pending) {
+			return fmt.Errorf("fixtures contain circular references between collections")
+		}
+
+		pending = remaining
+	}
+
+	return nil
+}
+
+func seedFixtureSet(db backends.Backend, set *FixtureSet, ids map[string]interface{}, dryRun bool) error {
+	if dryRun {
+		fmt.Printf("would load %d records into %q from %s\n", len(set.Records), set.Collection, set.source)
+		return nil
+	}
+
+	if _, err := db.GetCollection(set.Collection); dal.IsCollectionNotFoundErr(err) {
+		if set.Definition != nil {
+			set.Definition.Name = set.Collection
+
+			if err := db.CreateCollection(set.Definition); err != nil {
+				return err
+			}
+		} else {
+			return fmt.Errorf("collection %q does not exist and no definition was given", set.Collection)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	recordset := dal.NewRecordSet()
+
+	for _, data := range set.Records {
+		var id interface{}
+		fields := make(map[string]interface{})
+
+		for k, v := range data {
+			if k == fixtureLabelKey {
+				continue
+			}
+
+			if resolved, err := resolveFixtureReferences(v, ids); err == nil {
+				if k == `id` {
+					id = resolved
+				} else {
+					fields[k] = resolved
+				}
+			} else {
+				return err
+			}
+		}
+
+		recordset.Push(dal.NewRecord(id).SetFields(fields))
+	}
+
+	if err := db.Insert(set.Collection, recordset); err != nil {
+		return err
+	}
+
+	log.Noticef("Loaded %d records into %q from %s", len(recordset.Records), set.Collection, set.source)
+	return nil
+}
+
+func seed(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: seed CONNECTION_STRING FIXTURES...")
+	}
+
+	db, err := connect(c, c.Args().First())
+
+	if err != nil {
+		return err
+	}
+
+	sets, err := loadFixtures(c.Args().Tail())
+
+	if err != nil {
+		return err
+	}
+
+	return seedFixtures(db, sets, c.Bool(`dry-run`))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// writes the given fixture files to a temporary directory
+func writeFixtures(files map[string]string) (string, error) {
+	dir, err := ioutil.TempDir(``, `pivot-fixtures-`)
+
+	if err != nil {
+		return ``, err
+	}
+
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			os.RemoveAll(dir)
+			return ``, err
+		}
+	}
+
+	return dir, nil
+}
+
+func TestLoadFixtures(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := writeFixtures(map[string]string{
+		`a-orders.yml`: "collection: orders\nrecords:\n- id: 1\n  user_id: \"@ref:users.alice\"\n",
+		`b-users.json`: `[{"collection":"users","records":[{"_ref":"alice","id":1},{"_ref":"bob","id":2}]}]`,
+		`c-tags.yaml`:  "definition:\n  name: tags\nrecords: []\n",
+		`README.md`:    `ignored`,
+	})
+
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sets, err := loadFixtures([]string{dir})
+	assert.NoError(err)
+	assert.Len(sets, 3)
+
+	assert.Equal(`orders`, sets[0].Collection)
+	assert.Equal([]string{`users.alice`}, fixtureReferences(sets[0].Records[0][`user_id`]))
+	assert.Equal(`users`, sets[1].Collection)
+	assert.Len(sets[1].Records, 2)
+
+	// the collection name defaults to that of the definition
+	assert.Equal(`tags`, sets[2].Collection)
+
+	// individual files can be given too
+	sets, err = loadFixtures([]string{filepath.Join(dir, `b-users.json`)})
+	assert.NoError(err)
+	assert.Len(sets, 1)
+
+	_, err = loadFixtures([]string{filepath.Join(dir, `missing.yml`)})
+	assert.Error(err)
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, `d-invalid.yml`), []byte("records: []\n"), 0644))
+	_, err = loadFixtures([]string{dir})
+	assert.Error(err)
+}
+
+func TestResolveFixtureReferences(t *testing.T) {
+	assert := require.New(t)
+	ids := map[string]interface{}{
+		`users.alice`: 1,
+		`users.bob`:   2,
+	}
+
+	value := map[string]interface{}{
+		`owner`:   `@ref:users.alice`,
+		`members`: []interface{}{`@ref:users.alice`, `@ref:users.bob`, `carol`},
+		`count`:   3,
+	}
+
+	assert.ElementsMatch([]string{`users.alice`, `users.alice`, `users.bob`}, fixtureReferences(value))
+
+	resolved, err := resolveFixtureReferences(value, ids)
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		`owner`:   1,
+		`members`: []interface{}{1, 2, `carol`},
+		`count`:   3,
+	}, resolved)
+
+	_, err = resolveFixtureReferences([]interface{}{`@ref:users.dave`}, ids)
+	assert.Error(err)
+}
+
+func TestSeedFixtures(t *testing.T) {
+	assert := require.New(t)
+
+	mock := backends.NewMockBackend()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`orders`).AddFields(dal.Field{
+		Name: `user_id`,
+		Type: dal.IntType,
+	})))
+
+	sets := []*FixtureSet{
+		{
+			Collection: `orders`,
+			Records: []map[string]interface{}{
+				{`id`: 10, `user_id`: `@ref:users.alice`},
+			},
+		}, {
+			Collection: `users`,
+			Records: []map[string]interface{}{
+				{`_ref`: `alice`, `id`: 1, `name`: `alice`},
+			},
+		}, {
+			Collection: `tags`,
+			Definition: dal.NewCollection(`tags`),
+			Records: []map[string]interface{}{
+				{`id`: 1, `name`: `new`},
+			},
+		},
+	}
+
+	// nothing is written on a dry run
+	assert.NoError(seedFixtures(mock, sets, true))
+	assert.Empty(mock.CallsTo(`Insert`))
+
+	assert.NoError(seedFixtures(mock, sets, false))
+
+	// users are loaded before the orders that refer to them
+	inserts := mock.CallsTo(`Insert`)
+	assert.Len(inserts, 3)
+	assert.Equal(`users`, inserts[0].Args[0])
+	assert.Equal(`orders`, inserts[2].Args[0])
+
+	order, err := mock.Retrieve(`orders`, 10)
+	assert.NoError(err)
+	assert.EqualValues(1, order.Get(`user_id`))
+
+	// labels aren't stored
+	user, err := mock.Retrieve(`users`, 1)
+	assert.NoError(err)
+	assert.Equal(`alice`, user.Get(`name`))
+	assert.NotContains(user.Fields, `_ref`)
+
+	// missing collections are created from their definitions
+	_, err = mock.GetCollection(`tags`)
+	assert.NoError(err)
+}
+
+func TestSeedFixturesInvalid(t *testing.T) {
+	assert := require.New(t)
+
+	for name, sets := range map[string][]*FixtureSet{
+		`unknown reference`: {{
+			Collection: `orders`,
+			Records:    []map[string]interface{}{{`id`: 1, `user_id`: `@ref:users.nobody`}},
+		}},
+		`duplicate label`: {{
+			Collection: `users`,
+			Records:    []map[string]interface{}{{`_ref`: `a`, `id`: 1}, {`_ref`: `a`, `id`: 2}},
+		}},
+		`label without id`: {{
+			Collection: `users`,
+			Records:    []map[string]interface{}{{`_ref`: `a`}},
+		}},
+		`circular references`: {{
+			Collection: `users`,
+			Records:    []map[string]interface{}{{`_ref`: `a`, `id`: 1, `group`: `@ref:groups.b`}},
+		}, {
+			Collection: `groups`,
+			Records:    []map[string]interface{}{{`_ref`: `b`, `id`: 1, `owner`: `@ref:users.a`}},
+		}},
+		`missing collection`: {{
+			Collection: `widgets`,
+			Records:    []map[string]interface{}{{`id`: 1}},
+		}},
+	} {
+		mock := backends.NewMockBackend()
+
+		for _, collection := range []string{`users`, `groups`, `orders`} {
+			assert.NoError(mock.CreateCollection(dal.NewCollection(collection)))
+		}
+
+		assert.Error(seedFixtures(mock, sets, false), name)
+		assert.Empty(mock.CallsTo(`Insert`), name)
+	}
+}
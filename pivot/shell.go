@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chzyer/readline"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+var ShellDefaultLimit = 25
+
+const shellHelp = `Commands:
+  \l                      List collections.
+  \d COLLECTION           Describe a collection's fields.
+  \use COLLECTION         Set the current collection.
+  \limit N                Set the maximum number of records returned by queries (0 = no limit).
+  \get [COLLECTION] ID    Retrieve a single record by ID.
+  \count [COLLECTION] [FILTER]
+                          Count the records matching a filter.
+  \q                      Quit.
+
+Anything else is treated as a filter (e.g.: "name/prefix:a/age/gt:30") against the current
+collection, or as "COLLECTION FILTER" if no collection has been selected.
+`
+
+// An interactive shell for querying a backend.
+type Shell struct {
+	db          backends.Backend
+	out         io.Writer
+	current     string
+	limit       int
+	collections []string
+	schemaCache map[string]*dal.Collection
+}
+
+func NewShell(db backends.Backend, out io.Writer) *Shell {
+	return &Shell{
+		db:          db,
+		out:         out,
+		limit:       ShellDefaultLimit,
+		schemaCache: make(map[string]*dal.Collection),
+	}
+}
+
+func (self *Shell) prompt() string {
+	if self.current != `` {
+		return fmt.Sprintf("pivot:%s> ", self.current)
+	}
+
+	return `pivot> `
+}
+
+func (self *Shell) refreshCollections() {
+	if names, err := self.db.ListCollections(); err == nil {
+		sort.Strings(names)
+		self.collections = names
+	}
+}
+
+func (self *Shell) collection(name string) (*dal.Collection, error) {
+	if collection, ok := self.schemaCache[name]; ok {
+		return collection, nil
+	}
+
+	if collection, err := self.db.GetCollection(name); err == nil {
+		self.schemaCache[name] = collection
+		return collection, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Implements readline.AutoCompleter, completing commands, collection names, and (when a collection
+// is selected) the field names of the current collection.
+func (self *Shell) Do(line []rune, pos int) ([][]rune, int) {
+	input := string(line[:pos])
+	word := input
+
+	if i := strings.LastIndexAny(input, ` /`); i >= 0 {
+		word = input[i+1:]
+	}
+
+	var candidates []string
+	trimmed := strings.TrimLeft(input, ` `)
+
+	switch {
+	case !strings.Contains(trimmed, ` `) && strings.HasPrefix(trimmed, `\`):
+		candidates = []string{`\l`, `\d`, `\use`, `\limit`, `\get`, `\count`, `\q`}
+	case strings.HasPrefix(trimmed, `\d `), strings.HasPrefix(trimmed, `\use `):
+		candidates = self.collections
+	default:
+		candidates = append(candidates, self.collections...)
+
+		if self.current != `` {
+			if collection, err := self.collection(self.current); err == nil {
+				candidates = append(candidates, collection.IdentityField)
+
+				for _, field := range collection.Fields {
+					candidates = append(candidates, field.Name)
+				}
+			}
+		}
+	}
+
+	var matches [][]rune
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, []rune(candidate[len(word):]))
+		}
+	}
+
+	return matches, len([]rune(word))
+}
+
+// Runs the shell until the user quits or input is exhausted.
+func (self *Shell) Run() error {
+	self.refreshCollections()
+
+	config := &readline.Config{
+		Prompt:       self.prompt(),
+		AutoComplete: self,
+		HistoryLimit: 1000,
+	}
+
+	if usr, err := user.Current(); err == nil {
+		config.HistoryFile = filepath.Join(usr.HomeDir, `.pivot_history`)
+	}
+
+	rl, err := readline.NewEx(config)
+
+	if err != nil {
+		return err
+	}
+
+	defer rl.Close()
+
+	fmt.Fprintf(self.out, "Connected to %v. Type \\? for help.\n", self.db.GetConnectionString())
+
+	for {
+		line, err := rl.Readline()
+
+		if err == readline.ErrInterrupt {
+			continue
+		} else if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+
+		if line == `` {
+			continue
+		} else if line == `\q` || line == `exit` || line == `quit` {
+			return nil
+		}
+
+		if err := self.Execute(line); err != nil {
+			fmt.Fprintf(self.out, "ERROR: %v\n", err)
+		}
+
+		rl.SetPrompt(self.prompt())
+	}
+}
+
+// Executes a single line of shell input.
+func (self *Shell) Execute(line string) error {
+	parts := strings.Fields(line)
+	args := parts[1:]
+
+	switch parts[0] {
+	case `\?`, `\h`, `help`:
+		fmt.Fprint(self.out, shellHelp)
+
+	case `\l`:
+		self.refreshCollections()
+
+		for _, name := range self.collections {
+			fmt.Fprintln(self.out, name)
+		}
+
+	case `\d`:
+		name := self.current
+
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		if name == `` {
+			return fmt.Errorf("usage: \\d COLLECTION")
+		}
+
+		if collection, err := self.collection(name); err == nil {
+			self.describe(collection)
+		} else {
+			return err
+		}
+
+	case `\use`:
+		if len(args) == 0 {
+			self.current = ``
+		} else if _, err := self.collection(args[0]); err == nil {
+			self.current = args[0]
+		} else {
+			return err
+		}
+
+	case `\limit`:
+		if len(args) == 0 {
+			fmt.Fprintf(self.out, "limit: %d\n", self.limit)
+		} else if v, err := strconv.Atoi(args[0]); err == nil && v >= 0 {
+			self.limit = v
+		} else {
+			return fmt.Errorf("invalid limit %q", args[0])
+		}
+
+	case `\get`:
+		name, rest := self.collectionArgs(args)
+
+		if name == `` || len(rest) == 0 {
+			return fmt.Errorf("usage: \\get [COLLECTION] ID")
+		}
+
+		if record, err := self.db.Retrieve(name, rest[0]); err == nil {
+			collection, _ := self.collection(name)
+			printRecordTable(self.out, collection, []*dal.Record{record})
+		} else {
+			return err
+		}
+
+	case `\count`:
+		name, rest := self.collectionArgs(args)
+
+		if name == `` {
+			return fmt.Errorf("usage: \\count [COLLECTION] [FILTER]")
+		}
+
+		return self.count(name, strings.Join(rest, ` `))
+
+	default:
+		if strings.HasPrefix(parts[0], `\`) {
+			return fmt.Errorf("unknown command %q", parts[0])
+		}
+
+		name, rest := self.collectionArgs(parts)
+
+		if name == `` {
+			return fmt.Errorf("no collection selected; use \\use COLLECTION or specify one before the filter")
+		}
+
+		return self.query(name, strings.Join(rest, ` `))
+	}
+
+	return nil
+}
+
+// Splits the collection name from the remaining arguments.  If the first argument names a known
+// collection it is used, otherwise the current collection is.
+func (self *Shell) collectionArgs(args []string) (string, []string) {
+	if len(args) > 0 {
+		for _, name := range self.collections {
+			if name == args[0] {
+				return args[0], args[1:]
+			}
+		}
+	}
+
+	return self.current, args
+}
+
+func (self *Shell) describe(collection *dal.Collection) {
+	records := make([]*dal.Record, 0)
+
+	for _, field := range collection.Fields {
+		records = append(records, dal.NewRecord(field.Name).SetFields(map[string]interface{}{
+			`type`:     field.Type,
+			`required`: field.Required,
+			`unique`:   field.Unique,
+			`key`:      field.Key,
+		}))
+	}
+
+	fmt.Fprintf(self.out, "Collection %q (identity: %s %s)\n", collection.Name, collection.IdentityField, collection.IdentityFieldType)
+	printRecordTable(self.out, dal.NewCollection(``).AddFields(
+		dal.Field{Name: `type`},
+		dal.Field{Name: `required`},
+		dal.Field{Name: `unique`},
+		dal.Field{Name: `key`},
+	), records)
+}
+
+func (self *Shell) parseFilter(spec string) (*filter.Filter, error) {
+	if spec == `` {
+		spec = filter.AllValue
+	}
+
+	return filter.Parse(spec)
+}
+
+func (self *Shell) query(name string, spec string) error {
+	collection, err := self.collection(name)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := self.parseFilter(spec)
+
+	if err != nil {
+		return err
+	}
+
+	f.Limit = self.limit
+
+	if search := self.db.WithSearch(collection); search != nil {
+		started := time.Now()
+
+		if recordset, err := search.Query(collection, f); err == nil {
+			printRecordTable(self.out, collection, recordset.Records)
+			fmt.Fprintf(self.out, "(%d records, %v)\n", len(recordset.Records), time.Since(started).Round(time.Millisecond))
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return fmt.Errorf("backend %T does not support queries", self.db)
+	}
+}
+
+func (self *Shell) count(name string, spec string) error {
+	collection, err := self.collection(name)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := self.parseFilter(spec)
+
+	if err != nil {
+		return err
+	}
+
+	if aggregator := self.db.WithAggregator(collection); aggregator != nil {
+		if count, err := aggregator.Count(collection, f); err == nil {
+			fmt.Fprintln(self.out, count)
+			return nil
+		} else {
+			return err
+		}
+	} else if search := self.db.WithSearch(collection); search != nil {
+		var count int
+
+		if err := search.QueryFunc(collection, f, func(*dal.Record, error, backends.IndexPage) error {
+			count += 1
+			return nil
+		}); err == nil {
+			fmt.Fprintln(self.out, count)
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return fmt.Errorf("backend %T does not support counting", self.db)
+	}
+}
+
+func runShell(db backends.Backend) error {
+	return NewShell(db, os.Stdout).Run()
+}
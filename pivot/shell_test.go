@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a mock backend containing a "users" collection with three records
+func newTestBackend() (*backends.MockBackend, error) {
+	mock := backends.NewMockBackend()
+
+	if err := mock.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	})); err != nil {
+		return nil, err
+	}
+
+	if err := mock.CreateCollection(dal.NewCollection(`groups`)); err != nil {
+		return nil, err
+	}
+
+	return mock, mock.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `alice`).Set(`age`, 31),
+		dal.NewRecord(2).Set(`name`, `bob`).Set(`age`, 25),
+		dal.NewRecord(3).Set(`name`, `carol`).Set(`age`, 40),
+	))
+}
+
+func TestShellExecute(t *testing.T) {
+	assert := require.New(t)
+	var out bytes.Buffer
+
+	mock, err := newTestBackend()
+	assert.NoError(err)
+
+	shell := NewShell(mock, &out)
+	shell.refreshCollections()
+
+	assert.NoError(shell.Execute(`\l`))
+	assert.Equal("groups\nusers\n", out.String())
+
+	out.Reset()
+	assert.NoError(shell.Execute(`\d users`))
+	assert.Contains(out.String(), `Collection "users"`)
+	assert.Contains(out.String(), `name`)
+	assert.Contains(out.String(), `age`)
+
+	// queries need a collection
+	assert.Error(shell.Execute(`name/alice`))
+
+	out.Reset()
+	assert.NoError(shell.Execute(`users name/alice`))
+	assert.Contains(out.String(), `alice`)
+	assert.NotContains(out.String(), `bob`)
+	assert.Contains(out.String(), `(1 records,`)
+
+	// which can be selected for subsequent commands
+	assert.Error(shell.Execute(`\use missing`))
+	assert.NoError(shell.Execute(`\use users`))
+	assert.Equal(`pivot:users> `, shell.prompt())
+
+	out.Reset()
+	assert.NoError(shell.Execute(`age/gt:30`))
+	assert.Contains(out.String(), `alice`)
+	assert.Contains(out.String(), `carol`)
+	assert.NotContains(out.String(), `bob`)
+
+	out.Reset()
+	assert.NoError(shell.Execute(`\get 2`))
+	assert.Contains(out.String(), `bob`)
+
+	out.Reset()
+	assert.NoError(shell.Execute(`\count age/lt:35`))
+	assert.Equal("2\n", out.String())
+
+	out.Reset()
+	assert.NoError(shell.Execute(`\count groups`))
+	assert.Equal("0\n", out.String())
+
+	// limits apply to queries
+	assert.Error(shell.Execute(`\limit -1`))
+	assert.NoError(shell.Execute(`\limit 1`))
+
+	out.Reset()
+	assert.NoError(shell.Execute(`all`))
+	assert.Contains(out.String(), `(1 records,`)
+
+	assert.Error(shell.Execute(`\bogus`))
+
+	assert.NoError(shell.Execute(`\use`))
+	assert.Equal(`pivot> `, shell.prompt())
+}
+
+func TestShellComplete(t *testing.T) {
+	assert := require.New(t)
+
+	mock, err := newTestBackend()
+	assert.NoError(err)
+
+	shell := NewShell(mock, &bytes.Buffer{})
+	shell.refreshCollections()
+
+	complete := func(line string) []string {
+		matches, _ := shell.Do([]rune(line), len(line))
+		out := make([]string, len(matches))
+
+		for i, match := range matches {
+			out[i] = string(match)
+		}
+
+		return out
+	}
+
+	assert.Equal([]string{`se`}, complete(`\u`))
+	assert.Equal([]string{`sers`}, complete(`\d u`))
+	assert.Equal([]string{`roups`}, complete(`g`))
+
+	// fields of the current collection are completed too
+	assert.NoError(shell.Execute(`\use users`))
+	assert.Equal([]string{`me`}, complete(`na`))
+	assert.Equal([]string{`e`}, complete(`name/alice/ag`))
+}
+
+func TestSelectCollections(t *testing.T) {
+	assert := require.New(t)
+
+	mock, err := newTestBackend()
+	assert.NoError(err)
+
+	names, err := selectCollections(mock, nil)
+	assert.NoError(err)
+	assert.Equal([]string{`groups`, `users`}, names)
+
+	names, err = selectCollections(mock, []string{`u*`})
+	assert.NoError(err)
+	assert.Equal([]string{`users`}, names)
+
+	names, err = selectCollections(mock, []string{`users, groups`, `nope`})
+	assert.NoError(err)
+	assert.Equal([]string{`groups`, `users`}, names)
+
+	_, err = selectCollections(mock, []string{`[`})
+	assert.Error(err)
+}
+
+func TestPrintRecordTable(t *testing.T) {
+	assert := require.New(t)
+	var out bytes.Buffer
+
+	collection := dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+	})
+
+	printRecordTable(&out, collection, []*dal.Record{
+		dal.NewRecord(1).Set(`name`, `alice`).Set(`notes`, strings.Repeat(`x`, 100)),
+		dal.NewRecord(2),
+	})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(lines, 3)
+	assert.Equal([]string{`ID`, `NAME`, `NOTES`}, strings.Fields(lines[0]))
+	assert.Equal([]string{`1`, `alice`, strings.Repeat(`x`, 45) + `...`}, strings.Fields(lines[1]))
+	assert.Equal([]string{`2`, `-`, `-`}, strings.Fields(lines[2]))
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+type recordViolation struct {
+	Collection string      `json:"collection"`
+	ID         interface{} `json:"id"`
+	dal.FieldViolation
+}
+
+// Checks every record in the given collections against their schema definitions, reporting each
+// field that does not satisfy it.  Returns an error if any invalid records were found.
+func validate(c *cli.Context) error {
+	db, err := connect(c, c.Args().First())
+
+	if err != nil {
+		return err
+	}
+
+	names, err := selectCollections(db, c.Args().Tail())
+
+	if err != nil {
+		return err
+	}
+
+	var report func(recordViolation)
+	var invalid, checked int
+
+	if c.String(`output`) == `ndjson` {
+		encoder := json.NewEncoder(os.Stdout)
+
+		report = func(violation recordViolation) {
+			encoder.Encode(violation)
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		defer tw.Flush()
+
+		fmt.Fprintln(tw, "COLLECTION\tID\tFIELD\tVALUE\tERROR")
+
+		report = func(violation recordViolation) {
+			field := violation.Field
+			value := `-`
+
+			if field == `` {
+				field = `-`
+			}
+
+			if violation.Value != nil {
+				value = truncate(fmt.Sprintf("%v", violation.Value), 32)
+			}
+
+			fmt.Fprintf(tw, "%s\t%v\t%s\t%s\t%s\n", violation.Collection, violation.ID, field, value, violation.Error)
+		}
+	}
+
+	maxInvalid := c.Int(`max-errors`)
+
+	for _, name := range names {
+		collection, err := db.GetCollection(name)
+
+		if err != nil {
+			return err
+		}
+
+		search := db.WithSearch(collection)
+
+		if search == nil {
+			return fmt.Errorf("backend %T does not support querying collection %q", db, name)
+		}
+
+		f := filter.All()
+		f.IdentityField = collection.IdentityField
+
+		if err := search.QueryFunc(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+			if err != nil {
+				return err
+			} else if record == nil {
+				return nil
+			}
+
+			checked += 1
+
+			if violations := collection.CheckRecord(record); len(violations) > 0 {
+				invalid += 1
+
+				for _, violation := range violations {
+					report(recordViolation{
+						Collection:     name,
+						ID:             record.ID,
+						FieldViolation: violation,
+					})
+				}
+
+				if maxInvalid > 0 && invalid >= maxInvalid {
+					return fmt.Errorf("stopped after %d invalid records", invalid)
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("collection %q: %v", name, err)
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d records are invalid", invalid, checked)
+	}
+
+	log.Infof("%d records are valid", checked)
+	return nil
+}
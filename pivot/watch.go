@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// Prints change events for the records in a collection matching a filter as newline-delimited JSON
+// until interrupted.
+func watch(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("Must specify a connection string and a collection")
+	}
+
+	db, err := connect(c, c.Args().Get(0))
+
+	if err != nil {
+		return err
+	}
+
+	collection, err := db.GetCollection(c.Args().Get(1))
+
+	if err != nil {
+		return err
+	}
+
+	spec := c.String(`query`)
+
+	if spec == `` {
+		spec = filter.AllValue
+	}
+
+	f, err := filter.Parse(spec)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	events, err := backends.Watch(ctx, db, collection, f, backends.WatchOptions{
+		PollInterval: c.Duration(`interval`),
+		Initial:      c.Bool(`initial`),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return writeChangeEvents(os.Stdout, events)
+}
+
+// Writes each event to the given writer as a line of JSON until the channel is closed.
+func writeChangeEvents(w io.Writer, events <-chan *backends.ChangeEvent) error {
+	encoder := json.NewEncoder(w)
+
+	for event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/cli"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteChangeEvents(t *testing.T) {
+	assert := require.New(t)
+	var out bytes.Buffer
+
+	events := make(chan *backends.ChangeEvent, 2)
+	events <- &backends.ChangeEvent{
+		Type:       backends.ChangeCreate,
+		Collection: `users`,
+		ID:         1,
+		Record:     dal.NewRecord(1).Set(`name`, `alice`),
+		Timestamp:  time.Now(),
+	}
+
+	events <- &backends.ChangeEvent{
+		Type:       backends.ChangeDelete,
+		Collection: `users`,
+		ID:         2,
+		Timestamp:  time.Now(),
+	}
+
+	close(events)
+
+	assert.NoError(writeChangeEvents(&out, events))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(lines, 2)
+
+	var event map[string]interface{}
+
+	assert.NoError(json.Unmarshal([]byte(lines[0]), &event))
+	assert.Equal(`create`, event[`type`])
+	assert.Equal(`users`, event[`collection`])
+	assert.EqualValues(1, event[`id`])
+	assert.Contains(event, `record`)
+
+	event = nil
+	assert.NoError(json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(`delete`, event[`type`])
+	assert.NotContains(event, `record`)
+}
+
+func TestWatchArguments(t *testing.T) {
+	assert := require.New(t)
+
+	for _, args := range [][]string{
+		{},
+		{`mock://`},
+		{`mock://`, `missing`},
+	} {
+		set := flag.NewFlagSet(`watch`, flag.ContinueOnError)
+		set.String(`query`, ``, ``)
+		set.Duration(`interval`, time.Second, ``)
+		set.Bool(`initial`, false, ``)
+		assert.NoError(set.Parse(args))
+
+		assert.Error(watch(cli.NewContext(nil, set, nil)), "%v", args)
+	}
+}
@@ -0,0 +1,40 @@
+package pivot
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/ghetzel/pivot/backends"
+)
+
+// Registers the net/http/pprof handlers under /debug/pprof/.  Like other administrative endpoints,
+// these require the admin token (if one is set).
+func (self *Server) setupProfiling(mux *http.ServeMux) {
+	mux.HandleFunc(`/debug/pprof/`, self.requireAdmin(pprof.Index))
+	mux.HandleFunc(`/debug/pprof/cmdline`, self.requireAdmin(pprof.Cmdline))
+	mux.HandleFunc(`/debug/pprof/profile`, self.requireAdmin(pprof.Profile))
+	mux.HandleFunc(`/debug/pprof/symbol`, self.requireAdmin(pprof.Symbol))
+	mux.HandleFunc(`/debug/pprof/trace`, self.requireAdmin(pprof.Trace))
+}
+
+// Responds with the operation timing histograms collected by the backends, along with the mean and
+// approximate percentiles of each.
+func (self *Server) handleTimings(w http.ResponseWriter, req *http.Request) {
+	timings := make([]map[string]interface{}, 0)
+
+	for _, histogram := range backends.Timings.Snapshot() {
+		timings = append(timings, map[string]interface{}{
+			`name`:    histogram.Name,
+			`count`:   histogram.Count,
+			`min`:     histogram.Min.String(),
+			`max`:     histogram.Max.String(),
+			`mean`:    histogram.Mean().String(),
+			`p50`:     histogram.Percentile(0.5).String(),
+			`p90`:     histogram.Percentile(0.9).String(),
+			`p99`:     histogram.Percentile(0.99).String(),
+			`buckets`: histogram.Buckets,
+		})
+	}
+
+	respond(w, req, timings)
+}
@@ -0,0 +1,336 @@
+package pivot
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var DefaultQuotaKeyHeader = `X-Pivot-Api-Key`
+
+// The most distinct keys that usage will be tracked for in a day.  Usage by any further keys is
+// attributed to the shared OverflowQuotaKey.
+var MaxQuotaKeys = 10000
+
+var OverflowQuotaKey = `*`
+
+// Limits on the number of requests made and records returned or written per day.  A value of
+// zero means unlimited.
+type QuotaLimit struct {
+	Requests int64 `json:"requests,omitempty"`
+	Records  int64 `json:"records,omitempty"`
+}
+
+// Configures the daily quotas applied to API requests.  Usage is attributed to the API key given in
+// KeyHeader if it is one of the configured Keys, and to the client's IP address otherwise.
+type QuotaConfig struct {
+	KeyHeader   string                `json:"key_header,omitempty"`
+	Keys        []string              `json:"keys,omitempty"`
+	Default     QuotaLimit            `json:"default"`
+	Collections map[string]QuotaLimit `json:"collections,omitempty"`
+}
+
+// Returns the limit that applies to the given collection.
+func (self *QuotaConfig) LimitFor(collection string) QuotaLimit {
+	if self == nil {
+		return QuotaLimit{}
+	}
+
+	if limit, ok := self.Collections[collection]; ok && collection != `` {
+		return limit
+	}
+
+	return self.Default
+}
+
+type Usage struct {
+	Requests int64 `json:"requests"`
+	Records  int64 `json:"records"`
+}
+
+// Tracks the number of requests and records consumed per key, per collection, for the current
+// (UTC) day.  Usage is reset when the day changes.  At most MaxKeys keys are tracked; usage by
+// keys beyond that is attributed to OverflowQuotaKey.
+type UsageTracker struct {
+	MaxKeys int
+	day     string
+	usage   map[string]map[string]*Usage
+	lock    sync.Mutex
+}
+
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{
+		MaxKeys: MaxQuotaKeys,
+		usage:   make(map[string]map[string]*Usage),
+	}
+}
+
+func (self *UsageTracker) rollover() {
+	if today := time.Now().UTC().Format(`2006-01-02`); today != self.day {
+		self.day = today
+		self.usage = make(map[string]map[string]*Usage)
+	}
+}
+
+// Returns the current usage for the given key and collection.
+func (self *UsageTracker) Get(key string, collection string) Usage {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.rollover()
+
+	if byCollection, ok := self.usage[key]; ok {
+		if usage, ok := byCollection[collection]; ok {
+			return *usage
+		}
+	}
+
+	return Usage{}
+}
+
+// returns the (possibly new) usage for the given key and collection; the lock must be held
+func (self *UsageTracker) entry(key string, collection string) *Usage {
+	self.rollover()
+
+	byCollection, ok := self.usage[key]
+
+	if !ok {
+		if self.MaxKeys > 0 && len(self.usage) >= self.MaxKeys && key != OverflowQuotaKey {
+			return self.entry(OverflowQuotaKey, collection)
+		}
+
+		byCollection = make(map[string]*Usage)
+		self.usage[key] = byCollection
+	}
+
+	usage, ok := byCollection[collection]
+
+	if !ok {
+		usage = new(Usage)
+		byCollection[collection] = usage
+	}
+
+	return usage
+}
+
+// Records a request against the given key and collection, provided doing so would not exceed the
+// given limit.  Returns the usage as of this request, and whether the request is permitted.
+func (self *UsageTracker) Reserve(key string, collection string, limit QuotaLimit) (Usage, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	usage := self.entry(key, collection)
+
+	if (limit.Requests > 0 && usage.Requests >= limit.Requests) || (limit.Records > 0 && usage.Records >= limit.Records) {
+		return *usage, false
+	}
+
+	usage.Requests += 1
+	return *usage, true
+}
+
+// Records a request against each of the given collections, provided doing so would not exceed any
+// of their limits.  Either every collection is charged or none are, and whether they were is
+// returned.
+func (self *UsageTracker) ReserveAll(key string, limits map[string]QuotaLimit) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for collection, limit := range limits {
+		usage := self.entry(key, collection)
+
+		if (limit.Requests > 0 && usage.Requests >= limit.Requests) || (limit.Records > 0 && usage.Records >= limit.Records) {
+			return false
+		}
+	}
+
+	for collection := range limits {
+		self.entry(key, collection).Requests += 1
+	}
+
+	return true
+}
+
+// Records the number of records consumed by a request previously reserved with Reserve.
+func (self *UsageTracker) AddRecords(key string, collection string, records int64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.entry(key, collection).Records += records
+}
+
+// Returns a copy of today's usage for the given key (or for all keys if key is empty), grouped
+// by key and collection.
+func (self *UsageTracker) Report(key string) map[string]map[string]Usage {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.rollover()
+
+	report := make(map[string]map[string]Usage)
+
+	for k, byCollection := range self.usage {
+		if key != `` && k != key {
+			continue
+		}
+
+		report[k] = make(map[string]Usage)
+
+		for collection, usage := range byCollection {
+			report[k][collection] = *usage
+		}
+	}
+
+	return report
+}
+
+// Returns the key that usage for the given request should be attributed to: the value of the
+// configured key header if it is a known key, otherwise the client's IP address.  Unknown keys are
+// ignored so that clients can't reset their usage by presenting a new key.
+func (self *Server) quotaKey(req *http.Request) string {
+	header := DefaultQuotaKeyHeader
+
+	if self.Quotas != nil && self.Quotas.KeyHeader != `` {
+		header = self.Quotas.KeyHeader
+	}
+
+	if key := req.Header.Get(header); key != `` && self.Quotas != nil {
+		for _, known := range self.Quotas.Keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(known)) == 1 {
+				return key
+			}
+		}
+	}
+
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+
+	return req.RemoteAddr
+}
+
+// A negroni middleware that tracks usage of the API and rejects requests with 429 Too Many
+// Requests once a key has exhausted its daily quota for a collection.  This must be installed
+// after the AccessLogger, which provides the record counts for each request.  Nothing is tracked
+// unless quotas are configured.
+func (self *Server) enforceQuotas(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if self.Quotas == nil || !strings.HasPrefix(req.URL.Path, `/api/`) || strings.HasPrefix(req.URL.Path, `/api/usage`) {
+		next(w, req)
+		return
+	}
+
+	key := self.quotaKey(req)
+
+	// batches are charged to each of the collections they write to
+	if req.Method == `POST` && req.URL.Path == `/api/batch` {
+		if batch, ok := peekBatchRequest(req); ok {
+			self.enforceBatchQuotas(w, req, next, key, batch)
+			return
+		}
+	}
+
+	collection := collectionFromPath(req.URL.Path)
+	limit := self.Quotas.LimitFor(collection)
+	usage, ok := self.usage.Reserve(key, collection, limit)
+
+	if limit.Requests > 0 {
+		w.Header().Set(`X-RateLimit-Limit`, strconv.FormatInt(limit.Requests, 10))
+		w.Header().Set(`X-RateLimit-Remaining`, strconv.FormatInt(maxInt64(limit.Requests-usage.Requests, 0), 10))
+	}
+
+	if !ok {
+		respondQuotaExceeded(w, req)
+		return
+	}
+
+	next(w, req)
+
+	self.usage.AddRecords(key, collection, int64(GetRequestDetails(req).RecordCount))
+}
+
+func (self *Server) enforceBatchQuotas(w http.ResponseWriter, req *http.Request, next http.HandlerFunc, key string, batch *BatchRequest) {
+	limits := make(map[string]QuotaLimit)
+
+	for _, op := range batch.Operations {
+		limits[op.Collection] = self.Quotas.LimitFor(op.Collection)
+	}
+
+	if !self.usage.ReserveAll(key, limits) {
+		respondQuotaExceeded(w, req)
+		return
+	}
+
+	next(w, req)
+
+	// records are only counted if the batch was applied
+	if GetRequestDetails(req).RecordCount > 0 {
+		for _, op := range batch.Operations {
+			self.usage.AddRecords(key, op.Collection, int64(op.Count()))
+		}
+	}
+}
+
+// Reads the operations of a batch request without consuming its body.
+func peekBatchRequest(req *http.Request) (*BatchRequest, bool) {
+	if req.Body == nil {
+		return nil, false
+	}
+
+	if data, err := ioutil.ReadAll(req.Body); err == nil {
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+		var batch BatchRequest
+		peek := *req
+		peek.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+		if err := parseRequest(&peek, &batch); err == nil && len(batch.Operations) > 0 {
+			return &batch, true
+		}
+	}
+
+	return nil, false
+}
+
+func respondQuotaExceeded(w http.ResponseWriter, req *http.Request) {
+	now := time.Now().UTC()
+	reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	w.Header().Set(`Retry-After`, strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+	respond(w, req, fmt.Errorf("Daily quota exceeded"), http.StatusTooManyRequests)
+}
+
+// Reports the calling key's usage for the current day.
+func (self *Server) handleUsage(w http.ResponseWriter, req *http.Request) {
+	key := self.quotaKey(req)
+	limits := make(map[string]QuotaLimit)
+
+	if self.Quotas != nil {
+		limits = self.Quotas.Collections
+	}
+
+	respond(w, req, map[string]interface{}{
+		`key`:     key,
+		`usage`:   self.usage.Report(key)[key],
+		`default`: self.Quotas.LimitFor(``),
+		`limits`:  limits,
+	})
+}
+
+// Reports usage for all keys for the current day.
+func (self *Server) handleUsageAll(w http.ResponseWriter, req *http.Request) {
+	respond(w, req, self.usage.Report(``))
+}
+
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
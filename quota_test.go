@@ -0,0 +1,222 @@
+package pivot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+func TestQuotaConfigLimitFor(t *testing.T) {
+	assert := require.New(t)
+
+	var config *QuotaConfig
+	assert.Equal(QuotaLimit{}, config.LimitFor(`users`))
+
+	config = &QuotaConfig{
+		Default: QuotaLimit{Requests: 10},
+		Collections: map[string]QuotaLimit{
+			`users`: {Records: 5},
+		},
+	}
+
+	assert.Equal(QuotaLimit{Records: 5}, config.LimitFor(`users`))
+	assert.Equal(QuotaLimit{Requests: 10}, config.LimitFor(`groups`))
+	assert.Equal(QuotaLimit{Requests: 10}, config.LimitFor(``))
+}
+
+func TestUsageTrackerReserve(t *testing.T) {
+	assert := require.New(t)
+	tracker := NewUsageTracker()
+	limit := QuotaLimit{Requests: 10}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var permitted int
+
+	// concurrent requests can't slip past the limit
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, ok := tracker.Reserve(`key`, `users`, limit); ok {
+				lock.Lock()
+				permitted += 1
+				lock.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(10, permitted)
+	assert.Equal(Usage{Requests: 10}, tracker.Get(`key`, `users`))
+
+	// record limits apply once the records have been consumed
+	limit = QuotaLimit{Records: 5}
+
+	_, ok := tracker.Reserve(`key`, `groups`, limit)
+	assert.True(ok)
+	tracker.AddRecords(`key`, `groups`, 5)
+
+	usage, ok := tracker.Reserve(`key`, `groups`, limit)
+	assert.False(ok)
+	assert.Equal(Usage{Requests: 1, Records: 5}, usage)
+}
+
+func TestUsageTrackerMaxKeys(t *testing.T) {
+	assert := require.New(t)
+	tracker := NewUsageTracker()
+	tracker.MaxKeys = 2
+
+	for _, key := range []string{`a`, `b`, `c`, `d`, `a`} {
+		_, ok := tracker.Reserve(key, `users`, QuotaLimit{})
+		assert.True(ok)
+	}
+
+	report := tracker.Report(``)
+	assert.Len(report, 3)
+	assert.Equal(int64(2), report[`a`][`users`].Requests)
+	assert.Equal(int64(1), report[`b`][`users`].Requests)
+	assert.Equal(int64(2), report[OverflowQuotaKey][`users`].Requests)
+}
+
+func TestQuotaKey(t *testing.T) {
+	assert := require.New(t)
+	server, _, _ := newTestServer()
+
+	req := httptest.NewRequest(`GET`, `/api/status`, nil)
+	req.RemoteAddr = `192.0.2.1:1234`
+	req.Header.Set(DefaultQuotaKeyHeader, `known`)
+
+	// without quotas, keys aren't honored
+	assert.Equal(`192.0.2.1`, server.quotaKey(req))
+
+	server.Quotas = &QuotaConfig{
+		Keys: []string{`known`},
+	}
+
+	assert.Equal(`known`, server.quotaKey(req))
+
+	// unknown keys are attributed to the client
+	req.Header.Set(DefaultQuotaKeyHeader, `made-up`)
+	assert.Equal(`192.0.2.1`, server.quotaKey(req))
+
+	server.Quotas.KeyHeader = `X-Api-Key`
+	req.Header.Set(`X-Api-Key`, `known`)
+	assert.Equal(`known`, server.quotaKey(req))
+}
+
+// returns the server's API handler behind the access logger and quota middleware
+func quotaHandler(server *Server, handler http.Handler) http.Handler {
+	n := negroni.New(NewAccessLogger(ioutil.Discard))
+	n.UseFunc(server.enforceQuotas)
+	n.UseHandler(handler)
+
+	return n
+}
+
+func TestEnforceQuotas(t *testing.T) {
+	assert := require.New(t)
+
+	server, mock, handler := newTestServer(func(server *Server) {
+		server.Quotas = &QuotaConfig{
+			Default: QuotaLimit{Requests: 2},
+		}
+	})
+
+	handler = quotaHandler(server, handler)
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+
+	w := testRequest(handler, `GET`, `/api/schema/users`, ``)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`2`, w.Header().Get(`X-RateLimit-Limit`))
+	assert.Equal(`1`, w.Header().Get(`X-RateLimit-Remaining`))
+
+	w = testRequest(handler, `GET`, `/api/schema/users`, ``)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`0`, w.Header().Get(`X-RateLimit-Remaining`))
+
+	w = testRequest(handler, `GET`, `/api/schema/users`, ``)
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(w.Header().Get(`Retry-After`))
+
+	// presenting a new key doesn't reset the quota
+	w = testRequest(handler, `GET`, `/api/schema/users`, ``, DefaultQuotaKeyHeader, `fresh`)
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+
+	// usage reports are exempt
+	assert.Equal(http.StatusOK, testRequest(handler, `GET`, `/api/usage`, ``).Code)
+}
+
+func TestEnforceQuotasDisabled(t *testing.T) {
+	assert := require.New(t)
+	server, mock, handler := newTestServer()
+
+	handler = quotaHandler(server, handler)
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+
+	for i := 0; i < 3; i++ {
+		w := testRequest(handler, `GET`, `/api/schema/users`, ``)
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Empty(w.Header().Get(`X-RateLimit-Limit`))
+	}
+
+	// nothing is tracked when quotas aren't configured
+	assert.Empty(server.usage.Report(``))
+}
+
+func TestEnforceQuotasBatch(t *testing.T) {
+	assert := require.New(t)
+	var batcher *testBatcher
+
+	server, _, handler := newTestServer(func(server *Server) {
+		batcher = &testBatcher{
+			MockBackend: server.backend.(*backends.MockBackend),
+		}
+
+		server.backend = batcher
+		server.Quotas = &QuotaConfig{
+			Collections: map[string]QuotaLimit{
+				`users`: {Requests: 1},
+			},
+		}
+	})
+
+	handler = quotaHandler(server, handler)
+
+	w := testRequest(handler, `POST`, `/api/batch`, `{"operations": [
+		{"type": "insert", "collection": "users", "records": [{"id": 1}, {"id": 2}]},
+		{"type": "delete", "collection": "groups", "ids": [3]}
+	]}`)
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	// each collection in the batch is charged, rather than the batch as a whole
+	usage := server.usage.Report(``)[`192.0.2.1`]
+	assert.Equal(Usage{Requests: 1, Records: 2}, usage[`users`])
+	assert.Equal(Usage{Requests: 1, Records: 1}, usage[`groups`])
+	assert.NotContains(usage, ``)
+
+	// batches touching an exhausted collection are refused without charging the others
+	w = testRequest(handler, `POST`, `/api/batch`, `{"operations": [
+		{"type": "delete", "collection": "groups", "ids": [3]},
+		{"type": "update", "collection": "users", "records": [{"id": 1}]}
+	]}`)
+
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.Len(batcher.batches, 1)
+	assert.Equal(Usage{Requests: 1, Records: 1}, server.usage.Get(`192.0.2.1`, `groups`))
+
+	w = testRequest(handler, `POST`, `/api/batch`, `{"operations": [{"type": "delete", "collection": "groups", "ids": [3]}]}`)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Len(batcher.batches, 2)
+}
@@ -0,0 +1,102 @@
+package pivot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+)
+
+type ReindexOptions struct {
+	// The number of collections to rebuild concurrently.
+	Parallel int
+
+	// The number of records to submit to the indexer at a time.
+	BatchSize int
+
+	// Whether to remove all existing documents from each index before rebuilding it.
+	Clear bool
+
+	// If set, this function will be called with the running total of indexed records per collection.
+	// Calls are serialized, even when rebuilding collections in parallel.
+	Progress ProgressFunc
+}
+
+// Repopulates the search index of the given collections from the records stored in the backend.
+// Every collection is attempted, and the errors for any that failed are returned together.
+func Reindex(db backends.Backend, collections []string, options ReindexOptions) error {
+	rebuilder, ok := db.(backends.IndexRebuilder)
+
+	if !ok {
+		return dal.Unsupported("Backend %T does not support rebuilding its index", db)
+	}
+
+	if options.Parallel < 1 {
+		options.Parallel = 1
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var errs []string
+
+	names := make(chan string)
+
+	for i := 0; i < options.Parallel; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for name := range names {
+				if err := reindexCollection(db, rebuilder, name, options, &lock); err != nil {
+					lock.Lock()
+					errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, name := range collections {
+		names <- name
+	}
+
+	close(names)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to reindex %d collection(s): %s", len(errs), strings.Join(errs, `; `))
+	}
+
+	return nil
+}
+
+func reindexCollection(db backends.Backend, rebuilder backends.IndexRebuilder, name string, options ReindexOptions, lock *sync.Mutex) error {
+	collection, err := db.GetCollection(name)
+
+	if err != nil {
+		return err
+	}
+
+	progress := func(indexed int) {
+		if options.Progress != nil {
+			lock.Lock()
+			defer lock.Unlock()
+
+			options.Progress(name, indexed)
+		}
+	}
+
+	if indexed, err := rebuilder.IndexRebuild(collection, backends.IndexRebuildOptions{
+		BatchSize: options.BatchSize,
+		Clear:     options.Clear,
+		Progress:  progress,
+	}); err == nil {
+		progress(indexed)
+		return nil
+	} else {
+		return err
+	}
+}
@@ -0,0 +1,75 @@
+package pivot
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// a mock backend that records the index rebuilds requested of it
+type rebuildingBackend struct {
+	*backends.MockBackend
+	lock     sync.Mutex
+	rebuilds map[string]backends.IndexRebuildOptions
+}
+
+func (self *rebuildingBackend) IndexRebuild(collection *dal.Collection, options backends.IndexRebuildOptions) (int, error) {
+	self.lock.Lock()
+	self.rebuilds[collection.Name] = options
+	self.lock.Unlock()
+
+	if options.Progress != nil {
+		options.Progress(1)
+	}
+
+	return 2, nil
+}
+
+func TestReindex(t *testing.T) {
+	assert := require.New(t)
+
+	source, err := newBackupSource(2)
+	assert.NoError(err)
+
+	// backends without rebuildable indexes are rejected
+	err = Reindex(source, []string{`users`}, ReindexOptions{})
+	assert.Error(err)
+	assert.True(errors.Is(err, dal.ErrUnsupported))
+
+	db := &rebuildingBackend{
+		MockBackend: source,
+		rebuilds:    make(map[string]backends.IndexRebuildOptions),
+	}
+
+	progress := make(map[string][]int)
+
+	assert.NoError(Reindex(db, []string{`users`, `groups`}, ReindexOptions{
+		Parallel:  2,
+		BatchSize: 10,
+		Clear:     true,
+		Progress: func(collection string, records int) {
+			progress[collection] = append(progress[collection], records)
+		},
+	}))
+
+	assert.Len(db.rebuilds, 2)
+	assert.Equal(10, db.rebuilds[`users`].BatchSize)
+	assert.True(db.rebuilds[`groups`].Clear)
+
+	// the running total is reported, followed by the final count
+	assert.Equal(map[string][]int{
+		`users`:  {1, 2},
+		`groups`: {1, 2},
+	}, progress)
+
+	// all collections are attempted, and failures are reported together
+	err = Reindex(db, []string{`missing`, `users`, `other`}, ReindexOptions{})
+	assert.Error(err)
+	assert.Contains(err.Error(), `2 collection(s)`)
+	assert.Contains(err.Error(), `missing`)
+	assert.Contains(err.Error(), `other`)
+}
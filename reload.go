@@ -0,0 +1,133 @@
+package pivot
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var DefaultReloadInterval = time.Duration(5) * time.Second
+
+func (self *Server) adminToken() string {
+	self.settingsLock.RLock()
+	defer self.settingsLock.RUnlock()
+
+	return self.AdminToken
+}
+
+func (self *Server) tenantTokenSecret() string {
+	self.settingsLock.RLock()
+	defer self.settingsLock.RUnlock()
+
+	return self.TenantTokenSecret
+}
+
+// Re-reads the configuration file (if one is set) and all schema definition files, applying any
+// changes to the running server.  Newly-defined collections are registered and existing
+// definitions are replaced; authentication settings take effect for all subsequent requests.
+// Authentication settings removed from the configuration are cleared, unless they were given
+// when the server was started (which always take precedence).  Settings that are bound at startup
+// (e.g.: the listen address, backend, and TLS configuration) are not affected.
+//
+// Every file is read before anything is applied, so if any of them is invalid the error is
+// returned and the server keeps running with its previous configuration.
+func (self *Server) Reload() error {
+	var config *Configuration
+
+	if self.ConfigFile != `` {
+		if cnf, err := LoadConfigFile(self.ConfigFile); err == nil {
+			forEnv := cnf.ForEnv(self.ConfigEnv)
+			config = &forEnv
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	definitions, err := self.readSchemaDefinitions()
+
+	if err != nil {
+		return err
+	}
+
+	if config != nil {
+		self.settingsLock.Lock()
+
+		if self.startupSettings.AdminToken != `` {
+			self.AdminToken = self.startupSettings.AdminToken
+		} else {
+			self.AdminToken = config.AdminToken
+		}
+
+		if self.startupSettings.TenantTokenSecret != `` {
+			self.TenantTokenSecret = self.startupSettings.TenantTokenSecret
+		} else {
+			self.TenantTokenSecret = config.TenantTokenSecret
+		}
+
+		self.settingsLock.Unlock()
+	}
+
+	self.registerSchemaDefinitions(definitions)
+	return nil
+}
+
+// Returns the most recent modification time among the configuration and schema files.
+func (self *Server) configModTime() time.Time {
+	var latest time.Time
+
+	for _, filename := range append(self.schemaFiles(), self.ConfigFile) {
+		if filename == `` {
+			continue
+		}
+
+		if stat, err := os.Stat(filename); err == nil && stat.ModTime().After(latest) {
+			latest = stat.ModTime()
+		}
+	}
+
+	// also consider the schema directories themselves, which change when files are added or removed
+	for _, filename := range self.schemaDefs {
+		if stat, err := os.Stat(filename); err == nil && stat.IsDir() && stat.ModTime().After(latest) {
+			latest = stat.ModTime()
+		}
+	}
+
+	return latest
+}
+
+// Starts a goroutine that reloads the server configuration upon receiving SIGHUP, or when any of
+// the configuration or schema files change (checked every ReloadInterval, if set).
+func (self *Server) watchForChanges() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	var ticks <-chan time.Time
+
+	if self.ReloadInterval > 0 {
+		ticks = time.NewTicker(self.ReloadInterval).C
+	}
+
+	go func() {
+		lastModified := self.configModTime()
+
+		for {
+			select {
+			case <-signals:
+				log.Infof("Received SIGHUP, reloading configuration")
+
+			case <-ticks:
+				if modTime := self.configModTime(); modTime.After(lastModified) {
+					lastModified = modTime
+					log.Infof("Configuration changed, reloading")
+				} else {
+					continue
+				}
+			}
+
+			if err := self.Reload(); err != nil {
+				log.Errorf("Failed to reload configuration: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,192 @@
+package pivot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadSettings(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-reload-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, `pivot.yml`)
+	server, _, _ := newTestServer()
+	server.ConfigFile = filename
+
+	// a missing configuration file is not an error
+	assert.NoError(server.Reload())
+	assert.Empty(server.adminToken())
+
+	assert.NoError(ioutil.WriteFile(filename, []byte("admin_token: first\ntenant_token_secret: shh\n"), 0644))
+	assert.NoError(server.Reload())
+	assert.Equal(`first`, server.adminToken())
+	assert.Equal(`shh`, server.tenantTokenSecret())
+
+	assert.NoError(ioutil.WriteFile(filename, []byte("admin_token: second\n"), 0644))
+	assert.NoError(server.Reload())
+	assert.Equal(`second`, server.adminToken())
+
+	// removing a value from the configuration revokes it
+	assert.NoError(ioutil.WriteFile(filename, []byte("backend: mock://\n"), 0644))
+	assert.NoError(server.Reload())
+	assert.Empty(server.adminToken())
+	assert.Empty(server.tenantTokenSecret())
+
+	// invalid configurations are reported
+	assert.NoError(ioutil.WriteFile(filename, []byte("admin_token: [\n"), 0644))
+	assert.Error(server.Reload())
+}
+
+func TestReloadKeepsStartupSettings(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-reload-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, `pivot.yml`)
+	assert.NoError(ioutil.WriteFile(filename, []byte("admin_token: from-config\ntenant_token_secret: from-config\n"), 0644))
+
+	server, _, _ := newTestServer()
+	server.ConfigFile = filename
+	server.startupSettings.AdminToken = `from-flag`
+
+	assert.NoError(server.Reload())
+	assert.Equal(`from-flag`, server.adminToken())
+	assert.Equal(`from-config`, server.tenantTokenSecret())
+
+	assert.NoError(ioutil.WriteFile(filename, []byte("backend: mock://\n"), 0644))
+	assert.NoError(server.Reload())
+	assert.Equal(`from-flag`, server.adminToken())
+	assert.Empty(server.tenantTokenSecret())
+}
+
+func TestReloadKeepsPreviousConfigOnError(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-reload-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, `pivot.yml`)
+	schemata := filepath.Join(dir, `schema`)
+	assert.NoError(os.Mkdir(schemata, 0755))
+
+	assert.NoError(ioutil.WriteFile(filename, []byte("admin_token: first\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(schemata, `users.json`), []byte(`[{"name": "users"}]`), 0644))
+
+	server, mock, _ := newTestServer()
+	server.ConfigFile = filename
+	server.AddSchemaDefinition(schemata)
+
+	assert.NoError(server.Reload())
+	assert.Equal(`first`, server.adminToken())
+
+	users, err := mock.GetCollection(`users`)
+	assert.NoError(err)
+	assert.Empty(users.Fields)
+
+	// a bad schema file means none of the changes made alongside it are applied
+	assert.NoError(ioutil.WriteFile(filename, []byte("admin_token: second\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(schemata, `users.json`), []byte(`[{"name": "users", "fields": [{"name": "email", "type": "str"}]}]`), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(schemata, `widgets.json`), []byte(`[{"name": `), 0644))
+
+	assert.Error(server.Reload())
+	assert.Equal(`first`, server.adminToken())
+
+	users, err = mock.GetCollection(`users`)
+	assert.NoError(err)
+	assert.Empty(users.Fields)
+
+	// ...and neither are those in a bad configuration file
+	assert.NoError(os.Remove(filepath.Join(schemata, `widgets.json`)))
+	assert.NoError(ioutil.WriteFile(filename, []byte("admin_token: [\n"), 0644))
+
+	assert.Error(server.Reload())
+	assert.Equal(`first`, server.adminToken())
+
+	users, err = mock.GetCollection(`users`)
+	assert.NoError(err)
+	assert.Empty(users.Fields)
+
+	// once fixed, everything is applied together
+	assert.NoError(ioutil.WriteFile(filename, []byte("admin_token: second\n"), 0644))
+	assert.NoError(server.Reload())
+	assert.Equal(`second`, server.adminToken())
+
+	users, err = mock.GetCollection(`users`)
+	assert.NoError(err)
+	assert.Len(users.Fields, 1)
+}
+
+// requests served while the configuration is being reloaded see either the old or the new settings,
+// never an empty value in between
+func TestReloadDuringRequests(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-reload-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, `first.yml`)
+	second := filepath.Join(dir, `second.yml`)
+
+	assert.NoError(ioutil.WriteFile(first, []byte("admin_token: shared\ntenant_token_secret: one\n"), 0644))
+	assert.NoError(ioutil.WriteFile(second, []byte("admin_token: shared\ntenant_token_secret: two\n"), 0644))
+
+	server, _, handler := newTestServer()
+	server.ConfigFile = first
+	assert.NoError(server.Reload())
+
+	done := make(chan struct{})
+	reloaded := make(chan error, 1)
+
+	go func() {
+		defer close(reloaded)
+
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			// the config file is only ever swapped between two valid versions
+			if i%2 == 0 {
+				server.ConfigFile = second
+			} else {
+				server.ConfigFile = first
+			}
+
+			if err := server.Reload(); err != nil {
+				reloaded <- err
+				return
+			}
+		}
+	}()
+
+	statuses := make(map[int]int)
+
+	for i := 0; i < 200; i++ {
+		w := testRequest(handler, `GET`, `/api/timings`, ``, `X-Pivot-Admin-Token`, `shared`)
+		statuses[w.Code]++
+
+		if secret := server.tenantTokenSecret(); secret != `one` && secret != `two` {
+			t.Fatalf("unexpected tenant token secret %q", secret)
+		}
+	}
+
+	close(done)
+	assert.NoError(<-reloaded)
+
+	// the admin token is the same in both versions, so it is never missing
+	assert.Equal(map[int]int{http.StatusOK: 200}, statuses)
+}
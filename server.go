@@ -3,12 +3,16 @@ package pivot
 //go:generate esc -o static.go -pkg pivot -modtime 1500000000 -prefix ui ui
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ghetzel/diecast"
@@ -18,6 +22,7 @@ import (
 	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/ghetzel/pivot/backends"
 	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/dal/encoding"
 	"github.com/ghetzel/pivot/filter"
 	"github.com/ghetzel/pivot/util"
 	"github.com/husobee/vestigo"
@@ -30,14 +35,33 @@ var DefaultResultLimit = 25
 var DefaultUiDirectory = `embedded`
 
 type Server struct {
-	Address          string
-	ConnectionString string
-	ConnectOptions   backends.ConnectOptions
-	UiDirectory      string
-	backend          backends.Backend
-	endpoints        []util.Endpoint
-	routeMap         map[string]util.EndpointResponseFunc
-	schemaDefs       []string
+	Address           string
+	ConnectionString  string
+	ConnectOptions    backends.ConnectOptions
+	UiDirectory       string
+	AdminToken        string
+	InsecureAdmin     bool
+	Cors              *CorsConfig
+	TLS               *TLSConfig
+	AccessLog         io.Writer
+	Compression       *Compressor
+	Quotas            *QuotaConfig
+	TenancyMode       TenancyMode
+	TenantTokenSecret string
+	ConfigFile        string
+	ConfigEnv         string
+	ReloadInterval    time.Duration
+	Profiling         bool
+	ValidatePayloads  bool
+	backend           backends.Backend
+	endpoints         []util.Endpoint
+	routeMap          map[string]util.EndpointResponseFunc
+	schemaDefs        []string
+	tenantDefinitions sync.Map
+	startedAt         time.Time
+	settingsLock      sync.RWMutex
+	startupSettings   Configuration
+	usage             *UsageTracker
 }
 
 func NewServer(connectionString ...string) *Server {
@@ -45,22 +69,71 @@ func NewServer(connectionString ...string) *Server {
 		Address:          fmt.Sprintf("%s:%d", DefaultAddress, DefaultPort),
 		ConnectionString: connectionString[0],
 		UiDirectory:      DefaultUiDirectory,
+		Cors:             DefaultCorsConfig(),
+		Compression:      NewCompressor(DefaultCompressionMinSize),
+		ReloadInterval:   DefaultReloadInterval,
+		usage:            NewUsageTracker(),
 		endpoints:        make([]util.Endpoint, 0),
 		routeMap:         make(map[string]util.EndpointResponseFunc),
+		startedAt:        time.Now(),
 	}
 }
 
+// Adds a schema definition file (or a directory of them) to be loaded when the server starts.
+// Directories are re-read whenever the server configuration is reloaded, so new files placed in
+// them will be picked up.
 func (self *Server) AddSchemaDefinition(filename string) {
-	if pathutil.DirExists(filename) {
-		if entries, err := ioutil.ReadDir(filename); err == nil {
-			for _, entry := range entries {
-				if entry.Mode().IsRegular() {
-					self.schemaDefs = append(self.schemaDefs, path.Join(filename, entry.Name()))
+	if pathutil.DirExists(filename) || pathutil.FileExists(filename) {
+		self.schemaDefs = append(self.schemaDefs, filename)
+	}
+}
+
+func (self *Server) schemaFiles() []string {
+	files := make([]string, 0)
+
+	for _, filename := range self.schemaDefs {
+		if pathutil.DirExists(filename) {
+			if entries, err := ioutil.ReadDir(filename); err == nil {
+				for _, entry := range entries {
+					if entry.Mode().IsRegular() {
+						files = append(files, path.Join(filename, entry.Name()))
+					}
 				}
 			}
+		} else if pathutil.FileExists(filename) {
+			files = append(files, filename)
+		}
+	}
+
+	return files
+}
+
+// Loads all schema definition files, returning the collections they define.  Nothing is
+// registered, so that a bad file can be reported without applying the others.
+func (self *Server) readSchemaDefinitions() ([]*dal.Collection, error) {
+	definitions := make([]*dal.Collection, 0)
+
+	for _, filename := range self.schemaFiles() {
+		if collections, err := LoadSchemataFromFile(filename); err == nil {
+			log.Infof("Loaded %d definitions from %v", len(collections), filename)
+			definitions = append(definitions, collections...)
+		} else {
+			return nil, err
+		}
+	}
+
+	return definitions, nil
+}
+
+// Registers the given collection definitions with the backend (or, if tenancy is enabled, as the
+// definitions each tenant's collections are created from).
+func (self *Server) registerSchemaDefinitions(collections []*dal.Collection) {
+	for _, collection := range collections {
+		if self.TenancyMode == NoTenancy {
+			self.backend.RegisterCollection(collection)
+		} else {
+			self.tenantDefinitions.Store(collection.Name, collection)
 		}
-	} else if pathutil.FileExists(filename) {
-		self.schemaDefs = append(self.schemaDefs, filename)
 	}
 }
 
@@ -77,19 +150,21 @@ func (self *Server) ListenAndServe() error {
 		return err
 	}
 
-	// if specified, pre-load schema definitions
-	for _, filename := range self.schemaDefs {
-		if collections, err := LoadSchemataFromFile(filename); err == nil {
-			log.Infof("Loaded %d definitions from %v", len(collections), filename)
+	// settings given at startup take precedence over the configuration file, now and on reload
+	self.settingsLock.Lock()
+	self.startupSettings = Configuration{
+		AdminToken:        self.AdminToken,
+		TenantTokenSecret: self.TenantTokenSecret,
+	}
+	self.settingsLock.Unlock()
 
-			for _, collection := range collections {
-				self.backend.RegisterCollection(collection)
-			}
-		} else {
-			return err
-		}
+	// apply the configuration file and pre-load schema definitions (if specified)
+	if err := self.Reload(); err != nil {
+		return err
 	}
 
+	self.watchForChanges()
+
 	server := negroni.New()
 	mux := http.NewServeMux()
 	router := vestigo.NewRouter()
@@ -114,47 +189,92 @@ func (self *Server) ListenAndServe() error {
 		return err
 	}
 
-	mux.Handle(`/api/`, router)
+	mux.Handle(`/api/`, self.tenantScoped(router))
+	mux.Handle(`/openapi.json`, self.tenantScoped(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if spec, err := GenerateOpenAPI(self.db(req)); err == nil {
+			respond(w, req, spec)
+		} else {
+			respond(w, req, err)
+		}
+	})))
+	mux.HandleFunc(`/healthz`, self.handleHealthz)
+	mux.HandleFunc(`/readyz`, self.handleReadyz)
+
+	if self.Profiling {
+		self.setupProfiling(mux)
+	}
+
 	mux.Handle(`/`, ui)
 
+	server.Use(NewAccessLogger(self.AccessLog))
+
+	if self.Quotas != nil {
+		server.UseFunc(self.enforceQuotas)
+	}
+
+	if self.Compression != nil {
+		server.Use(self.Compression)
+	}
+
 	server.UseHandler(mux)
-	// server.Use(httputil.NewRequestLogger())
-	server.Run(self.Address)
-	return nil
+
+	httpServer := &http.Server{
+		Addr:    self.Address,
+		Handler: server,
+	}
+
+	if self.TLS != nil {
+		if tlsConfig, err := self.TLS.ServerConfig(); err == nil {
+			httpServer.TLSConfig = tlsConfig
+		} else {
+			return err
+		}
+
+		log.Infof("Listening on %v (TLS)", self.Address)
+
+		// certificates are provided by the TLS config's GetCertificate function
+		return httpServer.ListenAndServeTLS(``, ``)
+	} else {
+		log.Infof("Listening on %v", self.Address)
+		return httpServer.ListenAndServe()
+	}
 }
 
 func (self *Server) setupRoutes(router *vestigo.Router) error {
-	router.SetGlobalCors(&vestigo.CorsAccessControl{
-		AllowOrigin:      []string{"*"},
-		AllowCredentials: true,
-		AllowMethods:     []string{`GET`, `POST`, `PUT`, `DELETE`},
-		MaxAge:           3600 * time.Second,
-		AllowHeaders:     []string{"*"},
-	})
+	if cors := self.Cors; cors != nil {
+		router.SetGlobalCors(&vestigo.CorsAccessControl{
+			AllowOrigin:      cors.AllowOrigins,
+			AllowCredentials: cors.AllowCredentials,
+			AllowMethods:     cors.AllowMethods,
+			AllowHeaders:     cors.AllowHeaders,
+			ExposeHeaders:    cors.ExposeHeaders,
+			MaxAge:           time.Duration(cors.MaxAge) * time.Second,
+		})
+	}
 
 	router.Get(`/api/status`,
 		func(w http.ResponseWriter, req *http.Request) {
 			status := map[string]interface{}{
-				`backend`: self.backend.GetConnectionString().String(),
+				`backend`: self.db(req).GetConnectionString().String(),
 			}
 
-			if indexer := self.backend.WithSearch(nil, nil); indexer != nil {
+			if indexer := self.db(req).WithSearch(nil, nil); indexer != nil {
 				status[`indexer`] = indexer.IndexConnectionString().String()
 			}
 
-			httputil.RespondJSON(w, status)
+			respond(w, req, status)
 		})
 
 	router.Get(`/api/collections/:collection`,
 		func(w http.ResponseWriter, req *http.Request) {
 			name := vestigo.Param(req, `collection`)
 
-			if collection, err := self.backend.GetCollection(name); err == nil {
+			if collection, err := self.db(req).GetCollection(name); err == nil {
 				collection = injectRequestParamsIntoCollection(req, collection)
 
-				httputil.RespondJSON(w, collection)
+				respond(w, req, collection)
 			} else {
-				httputil.RespondJSON(w, err, http.StatusNotFound)
+				respond(w, req, err, http.StatusNotFound)
 			}
 		})
 
@@ -172,37 +292,79 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 		case `POST`:
 			fMap := make(map[string]interface{})
 
-			if err := httputil.ParseRequest(req, &fMap); err == nil {
-				query = fMap
+			if err := parseRequest(req, &fMap); err == nil {
+				// structured filters are identified by the presence of a "criteria" or "cursor" key,
+				// otherwise the body is treated as a map of field names to values
+				if isStructuredFilter(fMap) {
+					if data, err := json.Marshal(fMap); err == nil {
+						if f, err := filter.FromJSON(data); err == nil {
+							query = f
+						} else {
+							respond(w, req, fmt.Errorf("filter parse error: %v", err), http.StatusBadRequest)
+							return
+						}
+					} else {
+						respond(w, req, err, http.StatusBadRequest)
+						return
+					}
+				} else {
+					query = fMap
+				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
 				return
 			}
 		}
 
-		if f, err := filterFromRequest(req, query, int64(DefaultResultLimit)); err == nil {
-			if collection, err := self.backend.GetCollection(name); err == nil {
+		defaultLimit := int64(DefaultResultLimit)
+		stream := wantsStream(req)
+		avro := wantsAvro(req)
+
+		// streamed results are not buffered, so there is no need to limit them by default
+		if stream || avro {
+			defaultLimit = 0
+		}
+
+		if f, err := filterFromRequest(req, query, defaultLimit); err == nil {
+			if collection, err := self.db(req).GetCollection(name); err == nil {
 				collection = injectRequestParamsIntoCollection(req, collection)
 
-				if search := self.backend.WithSearch(collection); search != nil {
-					if recordset, err := search.Query(collection, f); err == nil {
-						httputil.RespondJSON(w, recordset)
+				if search := self.db(req).WithSearch(collection, f); search != nil {
+					querylog.Debugf("[%s] query %s: %v", GetRequestId(req), collection.Name, f)
+
+					if avro {
+						avroQuery(w, req, search, collection, f)
+					} else if stream {
+						streamQuery(w, req, search, collection, f)
+					} else if recordset, err := search.Query(collection, f); err == nil {
+						setRequestRecordCount(req, recordset.Len())
+
+						if f.Limit > 0 && recordset.Len() >= f.Limit {
+							if recordset.Options == nil {
+								recordset.Options = make(map[string]interface{})
+							}
+
+							recordset.Options[`next_cursor`] = f.NextCursor()
+						}
+
+						respond(w, req, recordset)
 					} else {
-						httputil.RespondJSON(w, err)
+						respond(w, req, err)
 					}
 				} else {
-					httputil.RespondJSON(w, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
+					respond(w, req, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
 				}
 			} else if dal.IsCollectionNotFoundErr(err) {
-				httputil.RespondJSON(w, err, http.StatusNotFound)
+				respond(w, req, err, http.StatusNotFound)
 			} else {
-				httputil.RespondJSON(w, err)
+				respond(w, req, err)
 			}
 		} else {
-			httputil.RespondJSON(w, err, http.StatusBadRequest)
+			respond(w, req, err, http.StatusBadRequest)
 		}
 	}
 
+	router.Post(`/api/collections/:collection/query`, queryHandler)
 	router.Post(`/api/collections/:collection/query/`, queryHandler)
 	router.Get(`/api/collections/:collection/query/`, queryHandler)
 	router.Get(`/api/collections/:collection/where/*urlquery`, queryHandler)
@@ -214,10 +376,10 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			aggregations := strings.Split(httputil.Q(req, `fn`, `count`), `,`)
 
 			if f, err := filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
-				if collection, err := self.backend.GetCollection(name); err == nil {
+				if collection, err := self.db(req).GetCollection(name); err == nil {
 					collection = injectRequestParamsIntoCollection(req, collection)
 
-					if aggregator := self.backend.WithAggregator(collection); aggregator != nil {
+					if aggregator := self.db(req).WithAggregator(collection); aggregator != nil {
 						results := make(map[string]interface{})
 
 						for _, field := range fields {
@@ -239,12 +401,12 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 								case `avg`:
 									value, err = aggregator.Average(collection, field, f)
 								default:
-									httputil.RespondJSON(w, fmt.Errorf("Unsupported aggregator '%s'", aggregation), http.StatusBadRequest)
+									respond(w, req, fmt.Errorf("Unsupported aggregator '%s'", aggregation), http.StatusBadRequest)
 									return
 								}
 
 								if err != nil {
-									httputil.RespondJSON(w, err)
+									respond(w, req, err)
 									return
 								}
 
@@ -254,17 +416,17 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 							results[field] = fieldResults
 						}
 
-						httputil.RespondJSON(w, results)
+						respond(w, req, results)
 					} else {
-						httputil.RespondJSON(w, fmt.Errorf("Backend %T does not support aggregations.", self.backend), http.StatusBadRequest)
+						respond(w, req, fmt.Errorf("Backend %T does not support aggregations.", self.backend), http.StatusBadRequest)
 					}
 				} else if dal.IsCollectionNotFoundErr(err) {
-					httputil.RespondJSON(w, err, http.StatusNotFound)
+					respond(w, req, err, http.StatusNotFound)
 				} else {
-					httputil.RespondJSON(w, err)
+					respond(w, req, err)
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -274,27 +436,137 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			fieldNames := vestigo.Param(req, `_name`)
 
 			if f, err := filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
-				if collection, err := self.backend.GetCollection(name); err == nil {
+				if collection, err := self.db(req).GetCollection(name); err == nil {
 					collection = injectRequestParamsIntoCollection(req, collection)
 
-					if search := self.backend.WithSearch(collection); search != nil {
-						fields := strings.TrimPrefix(fieldNames, `/`)
+					if search := self.db(req).WithSearch(collection, f); search != nil {
+						fields := strings.Split(strings.TrimPrefix(fieldNames, `/`), `/`)
 
-						if recordset, err := search.ListValues(collection, strings.Split(fields, `/`), f); err == nil {
-							httputil.RespondJSON(w, recordset)
+						for _, field := range fields {
+							if def, ok := collection.GetField(field); ok && (def.Hidden || def.Redacted) {
+								respond(w, req, fmt.Errorf("Cannot list values for field %q", field), http.StatusForbidden)
+								return
+							}
+						}
+
+						if recordset, err := search.ListValues(collection, fields, f); err == nil {
+							respond(w, req, recordset)
 						} else {
-							httputil.RespondJSON(w, err)
+							respond(w, req, err)
 						}
 					} else {
-						httputil.RespondJSON(w, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
+						respond(w, req, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
 					}
 				} else if dal.IsCollectionNotFoundErr(err) {
-					httputil.RespondJSON(w, err, http.StatusNotFound)
+					respond(w, req, err, http.StatusNotFound)
 				} else {
-					httputil.RespondJSON(w, err)
+					respond(w, req, err)
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
+			}
+		})
+
+	router.Get(`/api/collections/:collection/facets/*fields`,
+		func(w http.ResponseWriter, req *http.Request) {
+			name := vestigo.Param(req, `collection`)
+			fieldNames := vestigo.Param(req, `_name`)
+
+			if f, err := filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
+				if collection, err := self.db(req).GetCollection(name); err == nil {
+					collection = injectRequestParamsIntoCollection(req, collection)
+
+					if search := self.db(req).WithSearch(collection, f); search != nil {
+						fields := strings.Split(strings.TrimPrefix(fieldNames, `/`), `/`)
+
+						for _, field := range fields {
+							if def, ok := collection.GetField(field); ok && (def.Hidden || def.Redacted) {
+								respond(w, req, fmt.Errorf("Cannot count values for field %q", field), http.StatusForbidden)
+								return
+							}
+						}
+
+						if facets, err := search.Facets(collection, fields, f); err == nil {
+							respond(w, req, facets)
+						} else {
+							respond(w, req, err)
+						}
+					} else {
+						respond(w, req, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
+					}
+				} else if dal.IsCollectionNotFoundErr(err) {
+					respond(w, req, err, http.StatusNotFound)
+				} else {
+					respond(w, req, err)
+				}
+			} else {
+				respond(w, req, err, http.StatusBadRequest)
+			}
+		})
+
+	router.Get(`/api/collections/:collection/timeseries/:field`,
+		func(w http.ResponseWriter, req *http.Request) {
+			name := vestigo.Param(req, `collection`)
+			field := vestigo.Param(req, `field`)
+			fn := filter.Aggregate{
+				Field: httputil.Q(req, `value`),
+			}
+
+			switch aggregation := httputil.Q(req, `fn`, `count`); aggregation {
+			case `count`:
+				fn.Aggregation = filter.Count
+			case `sum`:
+				fn.Aggregation = filter.Sum
+			case `min`:
+				fn.Aggregation = filter.Minimum
+			case `max`:
+				fn.Aggregation = filter.Maximum
+			case `avg`:
+				fn.Aggregation = filter.Average
+			case `first`:
+				fn.Aggregation = filter.First
+			case `last`:
+				fn.Aggregation = filter.Last
+			default:
+				respond(w, req, fmt.Errorf("Unsupported aggregator '%s'", aggregation), http.StatusBadRequest)
+				return
+			}
+
+			if fn.Aggregation != filter.Count && fn.Field == `` {
+				respond(w, req, fmt.Errorf("A value field is required for this aggregator"), http.StatusBadRequest)
+				return
+			}
+
+			interval, err := backends.ParseTimeInterval(httputil.Q(req, `interval`, `day`))
+
+			if err != nil {
+				respond(w, req, err, http.StatusBadRequest)
+				return
+			}
+
+			if f, err := filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
+				if collection, err := self.db(req).GetCollection(name); err == nil {
+					collection = injectRequestParamsIntoCollection(req, collection)
+
+					if def, ok := collection.GetField(fn.Field); ok && (def.Hidden || def.Redacted) {
+						respond(w, req, fmt.Errorf("Cannot aggregate values for field %q", fn.Field), http.StatusForbidden)
+						return
+					}
+
+					if buckets, err := backends.AggregateByTime(self.db(req), collection, field, interval, fn, f); err == nil {
+						respond(w, req, buckets)
+					} else if errors.Is(err, dal.ErrUnsupported) {
+						respond(w, req, err, http.StatusBadRequest)
+					} else {
+						respond(w, req, err)
+					}
+				} else if dal.IsCollectionNotFoundErr(err) {
+					respond(w, req, err, http.StatusNotFound)
+				} else {
+					respond(w, req, err)
+				}
+			} else {
+				respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -304,13 +576,13 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			query := vestigo.Param(req, `_name`)
 
 			if f, err := filter.Parse(query); err == nil {
-				if err := self.backend.Delete(name, f); err == nil {
-					httputil.RespondJSON(w, nil)
+				if err := self.db(req).Delete(name, f); err == nil {
+					respond(w, req, nil)
 				} else {
-					httputil.RespondJSON(w, err, http.StatusBadRequest)
+					respond(w, req, err, http.StatusBadRequest)
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -330,12 +602,25 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 				fields = strings.Split(v, `,`)
 			}
 
-			if record, err := self.backend.Retrieve(name, id, fields...); err == nil {
-				httputil.RespondJSON(w, record)
-			} else if strings.HasSuffix(err.Error(), `does not exist`) {
-				httputil.RespondJSON(w, err, http.StatusNotFound)
+			if record, err := self.db(req).Retrieve(name, id, fields...); err == nil {
+				setRequestRecordCount(req, 1)
+
+				if collection, err := self.db(req).GetCollection(name); err == nil && len(fields) == 0 {
+					if etag := etagForRecord(collection, record); etag != `` {
+						w.Header().Set(`ETag`, etag)
+
+						if etagMatches(req.Header.Get(`If-None-Match`), etag, false) {
+							w.WriteHeader(http.StatusNotModified)
+							return
+						}
+					}
+				}
+
+				respond(w, req, record)
+			} else if dal.IsNotExistError(err) {
+				respond(w, req, err, http.StatusNotFound)
 			} else {
-				httputil.RespondJSON(w, err)
+				respond(w, req, err)
 			}
 		})
 
@@ -343,24 +628,65 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 		func(w http.ResponseWriter, req *http.Request) {
 			var record dal.Record
 
-			if err := httputil.ParseRequest(req, &record); err == nil {
+			if err := parseRequest(req, &record); err == nil {
 				recordset := dal.NewRecordSet(&record)
 				name := vestigo.Param(req, `collection`)
 				var err error
 
-				if self.backend.Exists(name, record.ID) {
-					err = self.backend.Update(name, recordset)
+				if record.ID == nil {
+					record.ID = vestigo.Param(req, `id`)
+				}
+
+				if !self.checkIfMatch(w, req, name, record.ID) {
+					return
+				}
+
+				exists := self.db(req).Exists(name, record.ID)
+
+				if !self.validatePayload(w, req, name, recordset, exists) {
+					return
+				}
+
+				if exists {
+					err = self.db(req).Update(name, recordset)
 				} else {
-					err = self.backend.Insert(name, recordset)
+					err = self.db(req).Insert(name, recordset)
 				}
 
 				if err == nil {
-					httputil.RespondJSON(w, &record)
+					respond(w, req, &record)
 				} else {
-					httputil.RespondJSON(w, err)
+					respond(w, req, err)
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
+			}
+		})
+
+	router.Put(`/api/collections/:collection/records/:id`,
+		func(w http.ResponseWriter, req *http.Request) {
+			var record dal.Record
+
+			if err := parseRequest(req, &record); err == nil {
+				name := vestigo.Param(req, `collection`)
+				record.ID = vestigo.Param(req, `id`)
+
+				if !self.checkIfMatch(w, req, name, record.ID) {
+					return
+				}
+
+				if !self.validatePayload(w, req, name, dal.NewRecordSet(&record), true) {
+					return
+				}
+
+				if err := self.db(req).Update(name, dal.NewRecordSet(&record)); err == nil {
+					setRequestRecordCount(req, 1)
+					respond(w, req, &record)
+				} else {
+					respond(w, req, err)
+				}
+			} else {
+				respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -368,16 +694,21 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 		func(w http.ResponseWriter, req *http.Request) {
 			var recordset dal.RecordSet
 
-			if err := httputil.ParseRequest(req, &recordset); err == nil {
+			if err := parseRequest(req, &recordset); err == nil {
 				name := vestigo.Param(req, `collection`)
 
-				if err := self.backend.Insert(name, &recordset); err == nil {
-					httputil.RespondJSON(w, &recordset)
+				if !self.validatePayload(w, req, name, &recordset, false) {
+					return
+				}
+
+				if err := self.db(req).Insert(name, &recordset); err == nil {
+					setRequestRecordCount(req, recordset.Len())
+					respond(w, req, &recordset)
 				} else {
-					httputil.RespondJSON(w, err)
+					respond(w, req, err)
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -385,16 +716,21 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 		func(w http.ResponseWriter, req *http.Request) {
 			var recordset dal.RecordSet
 
-			if err := httputil.ParseRequest(req, &recordset); err == nil {
+			if err := parseRequest(req, &recordset); err == nil {
 				name := vestigo.Param(req, `collection`)
 
-				if err := self.backend.Update(name, &recordset); err == nil {
-					httputil.RespondJSON(w, nil)
+				if !self.validatePayload(w, req, name, &recordset, true) {
+					return
+				}
+
+				if err := self.db(req).Update(name, &recordset); err == nil {
+					setRequestRecordCount(req, recordset.Len())
+					respond(w, req, nil)
 				} else {
-					httputil.RespondJSON(w, err)
+					respond(w, req, err)
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -409,10 +745,14 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 				id = ids
 			}
 
-			if err := self.backend.Delete(name, id); err == nil {
-				httputil.RespondJSON(w, nil)
+			if !self.checkIfMatch(w, req, name, id) {
+				return
+			}
+
+			if err := self.db(req).Delete(name, id); err == nil {
+				respond(w, req, nil)
 			} else {
-				httputil.RespondJSON(w, err)
+				respond(w, req, err)
 			}
 		})
 
@@ -421,14 +761,18 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			var recordset dal.RecordSet
 			name := vestigo.Param(req, `collection`)
 
-			if err := json.NewDecoder(req.Body).Decode(&recordset); err == nil {
-				if err := self.backend.Insert(name, &recordset); err == nil {
-					httputil.RespondJSON(w, nil)
+			if err := parseRequest(req, &recordset); err == nil {
+				if !self.validatePayload(w, req, name, &recordset, false) {
+					return
+				}
+
+				if err := self.db(req).Insert(name, &recordset); err == nil {
+					respond(w, req, nil)
 				} else {
-					httputil.RespondJSON(w, err)
+					respond(w, req, err)
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -437,59 +781,74 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			var recordset dal.RecordSet
 			name := vestigo.Param(req, `collection`)
 
-			if err := json.NewDecoder(req.Body).Decode(&recordset); err == nil {
-				if err := self.backend.Update(name, &recordset); err == nil {
-					httputil.RespondJSON(w, nil)
+			if err := parseRequest(req, &recordset); err == nil {
+				if !self.validatePayload(w, req, name, &recordset, true) {
+					return
+				}
+
+				if err := self.db(req).Update(name, &recordset); err == nil {
+					setRequestRecordCount(req, recordset.Len())
+					respond(w, req, nil)
 				} else {
-					httputil.RespondJSON(w, err)
+					respond(w, req, err)
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
+	router.Post(`/api/batch`, self.handleBatch)
+	router.Get(`/api/usage`, self.handleUsage)
+	router.Get(`/api/usage/all`, self.requireAdmin(self.handleUsageAll))
+	router.Get(`/api/timings`, self.requireAdmin(self.handleTimings))
+
+	router.Get(`/api/collections/:collection/records/:id/blobs/:field`, self.handleBlobDownload)
+	router.Put(`/api/collections/:collection/records/:id/blobs/:field`, self.handleBlobUpload)
+	router.Post(`/api/collections/:collection/records/:id/blobs/:field`, self.handleBlobUpload)
+
 	router.Get(`/api/schema`,
 		func(w http.ResponseWriter, req *http.Request) {
-			if names, err := self.backend.ListCollections(); err == nil {
-				httputil.RespondJSON(w, names)
+			if names, err := self.db(req).ListCollections(); err == nil {
+				respond(w, req, names)
 			} else {
-				httputil.RespondJSON(w, err)
+				respond(w, req, err)
 			}
 		})
 
 	router.Post(`/api/schema`,
-		func(w http.ResponseWriter, req *http.Request) {
-			var collections []dal.Collection
+		self.requireAdmin(func(w http.ResponseWriter, req *http.Request) {
+			var collections []*dal.Collection
 
 			if body, err := ioutil.ReadAll(req.Body); err == nil {
 				var collection dal.Collection
 
 				if err := json.Unmarshal(body, &collection); err == nil {
-					collections = append(collections, collection)
+					collections = append(collections, &collection)
 				} else if strings.Contains(err.Error(), `cannot unmarshal array `) {
 					if err := json.Unmarshal(body, &collections); err != nil {
-						httputil.RespondJSON(w, err, http.StatusBadRequest)
+						respond(w, req, err, http.StatusBadRequest)
 						return
 					}
 				} else {
-					httputil.RespondJSON(w, err, http.StatusBadRequest)
+					respond(w, req, err, http.StatusBadRequest)
+					return
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
 				return
 			}
 
 			var errors []error
 
 			for _, collection := range collections {
-				if err := self.backend.CreateCollection(&collection); err == nil {
-					httputil.RespondJSON(w, collection, http.StatusCreated)
+				if err := self.db(req).CreateCollection(collection); err == nil {
+					respond(w, req, collection, http.StatusCreated)
 
 				} else if len(collections) == 1 {
 					if dal.IsExistError(err) {
-						httputil.RespondJSON(w, err, http.StatusConflict)
+						respond(w, req, err, http.StatusConflict)
 					} else {
-						httputil.RespondJSON(w, err)
+						respond(w, req, err)
 					}
 
 					return
@@ -499,41 +858,196 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			}
 
 			if len(errors) > 0 {
-				httputil.RespondJSON(w, errors, http.StatusBadRequest)
+				respond(w, req, errors, http.StatusBadRequest)
 			}
-		})
+		}))
 
 	router.Get(`/api/schema/:collection`,
 		func(w http.ResponseWriter, req *http.Request) {
 			name := vestigo.Param(req, `collection`)
 
-			if collection, err := self.backend.GetCollection(name); err == nil {
+			if collection, err := self.db(req).GetCollection(name); err == nil {
 				collection = injectRequestParamsIntoCollection(req, collection)
 
-				httputil.RespondJSON(w, collection)
+				respond(w, req, collection)
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
-	router.Delete(`/api/schema/:collection`,
+	router.Get(`/api/schema/:collection/json-schema`,
 		func(w http.ResponseWriter, req *http.Request) {
 			name := vestigo.Param(req, `collection`)
 
-			if err := self.backend.DeleteCollection(name); err == nil {
-				httputil.RespondJSON(w, nil)
+			if collection, err := self.db(req).GetCollection(name); err == nil {
+				respond(w, req, encoding.JSONSchema(collection, httputil.Q(req, `partial`) == `true`))
+			} else if dal.IsCollectionNotFoundErr(err) {
+				respond(w, req, err, http.StatusNotFound)
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				respond(w, req, err)
 			}
 		})
 
+	router.Put(`/api/schema/:collection`,
+		self.requireAdmin(func(w http.ResponseWriter, req *http.Request) {
+			var definition dal.Collection
+			name := vestigo.Param(req, `collection`)
+
+			if err := json.NewDecoder(req.Body).Decode(&definition); err != nil {
+				respond(w, req, err, http.StatusBadRequest)
+				return
+			}
+
+			if definition.Name == `` {
+				definition.Name = name
+			} else if definition.Name != name {
+				respond(w, req, fmt.Errorf("Collection name %q does not match %q", definition.Name, name), http.StatusBadRequest)
+				return
+			}
+
+			if actual, err := self.db(req).GetCollection(name); err == nil {
+				// the backend may know this collection by a different (e.g.: tenant-scoped) name
+				current := actual.Copy()
+				current.Name = definition.Name
+				diff := definition.Diff(current)
+
+				if len(diff) == 0 {
+					respond(w, req, actual)
+					return
+				}
+
+				if migratable, ok := self.db(req).(backends.Migratable); ok {
+					self.db(req).RegisterCollection(&definition)
+
+					if err := migratable.Migrate(diff); err == nil {
+						respond(w, req, &definition)
+					} else {
+						// put the previous definition back, keeping any fields that were added before
+						// the migration failed so the registration still describes the collection
+						var migrationErr *backends.MigrationError
+
+						if errors.As(err, &migrationErr) {
+							for _, name := range migrationErr.Added {
+								if field, ok := definition.GetField(name); ok {
+									current.AddFields(field)
+								}
+							}
+						}
+
+						self.db(req).RegisterCollection(current)
+						respond(w, req, err, http.StatusBadRequest)
+					}
+				} else {
+					respond(w, req, fmt.Errorf("Backend %T does not support schema changes.", self.backend), http.StatusNotImplemented)
+				}
+			} else if dal.IsCollectionNotFoundErr(err) {
+				respond(w, req, err, http.StatusNotFound)
+			} else {
+				respond(w, req, err)
+			}
+		}))
+
+	router.Delete(`/api/schema/:collection`,
+		self.requireAdmin(func(w http.ResponseWriter, req *http.Request) {
+			name := vestigo.Param(req, `collection`)
+
+			if err := self.db(req).DeleteCollection(name); err == nil {
+				respond(w, req, nil)
+			} else {
+				respond(w, req, err, http.StatusBadRequest)
+			}
+		}))
+
 	return nil
 }
 
+// If payload validation is enabled, checks the given records against their collection's JSON Schema
+// before they are written.  If any don't match, a response describing every problem is written and
+// false is returned.  When updating, partial records are allowed.
+func (self *Server) validatePayload(w http.ResponseWriter, req *http.Request, name string, recordset *dal.RecordSet, partial bool) bool {
+	if problems, err := self.payloadProblems(req, name, recordset.Records, partial); err != nil {
+		respond(w, req, err)
+		return false
+	} else if len(problems) > 0 {
+		respondSchemaErrors(w, req, problems)
+		return false
+	}
+
+	return true
+}
+
+// Returns every way in which the given records fail to match their collection's JSON Schema, or
+// nothing if payload validation is disabled.
+func (self *Server) payloadProblems(req *http.Request, name string, records []*dal.Record, partial bool) (encoding.SchemaErrors, error) {
+	if !self.ValidatePayloads {
+		return nil, nil
+	}
+
+	collection, err := self.db(req).GetCollection(name)
+
+	if err != nil {
+		// let the write itself report the problem
+		return nil, nil
+	}
+
+	problems := make(encoding.SchemaErrors, 0)
+
+	for i, record := range records {
+		if err := encoding.ValidateJSONSchema(collection, record, partial); err == nil {
+			continue
+		} else if errs, ok := err.(encoding.SchemaErrors); ok {
+			for _, problem := range errs {
+				if len(records) > 1 {
+					problem.Path = fmt.Sprintf("/records/%d%s", i, problem.Path)
+				}
+
+				problems = append(problems, problem)
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return problems, nil
+}
+
+func respondSchemaErrors(w http.ResponseWriter, req *http.Request, problems encoding.SchemaErrors) {
+	respond(w, req, map[string]interface{}{
+		`error`:  problems.Error(),
+		`errors`: problems,
+	}, http.StatusBadRequest)
+}
+
+// Wraps the given handler such that requests are only permitted if they present the
+// configured AdminToken, either as a bearer token or in the X-Pivot-Admin-Token header.
+// If no AdminToken is set, all requests are denied unless InsecureAdmin is set.
+func (self *Server) requireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if adminToken := self.adminToken(); adminToken != `` {
+			token := req.Header.Get(`X-Pivot-Admin-Token`)
+
+			if token == `` {
+				if auth := req.Header.Get(`Authorization`); strings.HasPrefix(auth, `Bearer `) {
+					token = strings.TrimPrefix(auth, `Bearer `)
+				}
+			}
+
+			if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+				respond(w, req, fmt.Errorf("This operation requires administrative access"), http.StatusForbidden)
+				return
+			}
+		} else if !self.InsecureAdmin {
+			respond(w, req, fmt.Errorf("This operation requires an admin token to be configured"), http.StatusForbidden)
+			return
+		}
+
+		handler(w, req)
+	}
+}
+
 func injectRequestParamsIntoCollection(req *http.Request, collection *dal.Collection) *dal.Collection {
-	// shallow copy the collection so we can screw with it
-	c := *collection
-	collection = &c
+	// copy the collection so we can screw with it
+	collection = collection.Copy()
 
 	if v := httputil.Q(req, `index`); v != `` {
 		collection.IndexName = v
@@ -550,6 +1064,16 @@ func injectRequestParamsIntoCollection(req *http.Request, collection *dal.Collec
 	return collection
 }
 
+func isStructuredFilter(in map[string]interface{}) bool {
+	for _, key := range []string{`criteria`, `cursor`} {
+		if _, ok := in[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
 func filterFromRequest(req *http.Request, filterIn interface{}, defaultLimit int64) (*filter.Filter, error) {
 	limit := int(httputil.QInt(req, `limit`, defaultLimit))
 	offset := int(httputil.QInt(req, `offset`))
@@ -565,6 +1089,20 @@ func filterFromRequest(req *http.Request, filterIn interface{}, defaultLimit int
 	case *filter.Filter:
 		f = filterIn.(*filter.Filter)
 
+		// filters given as structured input carry their own pagination and projection, which
+		// query string parameters may override
+		if f.Limit <= 0 {
+			f.Limit = int(defaultLimit)
+		}
+
+		if v := httputil.Q(req, `limit`); v != `` {
+			f.Limit = limit
+		}
+
+		if v := httputil.Q(req, `offset`); v != `` {
+			f.Offset = offset
+		}
+
 	default:
 		if typeutil.IsMap(filterIn) {
 			if fMap, err := maputil.Compact(maputil.Autotype(filterIn)); err == nil {
@@ -581,8 +1119,10 @@ func filterFromRequest(req *http.Request, filterIn interface{}, defaultLimit int
 		}
 	}
 
-	f.Limit = limit
-	f.Offset = offset
+	if _, ok := filterIn.(*filter.Filter); !ok {
+		f.Limit = limit
+		f.Offset = offset
+	}
 
 	if v := httputil.Q(req, `sort`); v != `` {
 		f.Sort = strings.Split(v, `,`)
@@ -592,5 +1132,9 @@ func filterFromRequest(req *http.Request, filterIn interface{}, defaultLimit int
 		f.Fields = strings.Split(v, `,`)
 	}
 
+	if v := httputil.Q(req, `parallelism`); v != `` {
+		f.Parallelism = int(httputil.QInt(req, `parallelism`))
+	}
+
 	return f, nil
 }
@@ -0,0 +1,206 @@
+package pivot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a server backed by a MockBackend, along with a handler that serves its API routes
+func newTestServer(configure ...func(*Server)) (*Server, *backends.MockBackend, http.Handler) {
+	connection, err := dal.ParseConnectionString(`mock://`)
+
+	if err != nil {
+		panic(err.Error())
+	}
+
+	mock := backends.NewMockBackendFromConnectionString(connection).(*backends.MockBackend)
+	server := NewServer(`mock://`)
+	server.backend = mock
+
+	for _, fn := range configure {
+		fn(server)
+	}
+
+	router := vestigo.NewRouter()
+
+	if err := server.setupRoutes(router); err != nil {
+		panic(err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(`/api/`, server.tenantScoped(router))
+
+	return server, mock, mux
+}
+
+// makes a request against the given handler, with headers given as alternating names and values
+func testRequest(handler http.Handler, method string, url string, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	if body != `` {
+		req.Header.Set(`Content-Type`, `application/json`)
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestRequireAdmin(t *testing.T) {
+	assert := require.New(t)
+
+	for _, tc := range []struct {
+		AdminToken    string
+		InsecureAdmin bool
+		Headers       []string
+		Permitted     bool
+	}{
+		{Permitted: false},
+		{InsecureAdmin: true, Permitted: true},
+		{AdminToken: `s3cr3t`, Permitted: false},
+		{AdminToken: `s3cr3t`, InsecureAdmin: true, Permitted: false},
+		{AdminToken: `s3cr3t`, Headers: []string{`X-Pivot-Admin-Token`, `wrong`}, Permitted: false},
+		{AdminToken: `s3cr3t`, Headers: []string{`Authorization`, `Bearer wrong`}, Permitted: false},
+		{AdminToken: `s3cr3t`, Headers: []string{`Authorization`, `s3cr3t`}, Permitted: false},
+		{AdminToken: `s3cr3t`, Headers: []string{`X-Pivot-Admin-Token`, `s3cr3t`}, Permitted: true},
+		{AdminToken: `s3cr3t`, Headers: []string{`Authorization`, `Bearer s3cr3t`}, Permitted: true},
+	} {
+		_, mock, handler := newTestServer(func(server *Server) {
+			server.AdminToken = tc.AdminToken
+			server.InsecureAdmin = tc.InsecureAdmin
+		})
+
+		assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+
+		w := testRequest(handler, `DELETE`, `/api/schema/users`, ``, tc.Headers...)
+
+		if tc.Permitted {
+			assert.NotEqual(http.StatusForbidden, w.Code, "%+v", tc)
+			assert.Len(mock.CallsTo(`DeleteCollection`), 1, "%+v", tc)
+		} else {
+			assert.Equal(http.StatusForbidden, w.Code, "%+v", tc)
+			assert.Empty(mock.CallsTo(`DeleteCollection`), "%+v", tc)
+		}
+	}
+}
+
+func TestRequireAdminSchemaRoutes(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`)))
+
+	// every schema modification is denied when no admin token is configured
+	assert.Equal(http.StatusForbidden, testRequest(handler, `POST`, `/api/schema`, `{"name":"other"}`).Code)
+	assert.Equal(http.StatusForbidden, testRequest(handler, `PUT`, `/api/schema/users`, `{"name":"users"}`).Code)
+	assert.Equal(http.StatusForbidden, testRequest(handler, `DELETE`, `/api/schema/users`, ``).Code)
+	assert.Equal(http.StatusForbidden, testRequest(handler, `GET`, `/api/usage/all`, ``).Code)
+	assert.Equal(http.StatusForbidden, testRequest(handler, `GET`, `/api/timings`, ``).Code)
+
+	assert.Len(mock.CallsTo(`CreateCollection`), 1)
+	assert.Empty(mock.CallsTo(`DeleteCollection`))
+
+	// reading schemas does not require administrative access
+	assert.Equal(http.StatusOK, testRequest(handler, `GET`, `/api/schema/users`, ``).Code)
+}
+
+func TestResponsesAreMasked(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name:   `password`,
+		Type:   dal.StringType,
+		Hidden: true,
+	}, dal.Field{
+		Name:     `email`,
+		Type:     dal.StringType,
+		Redacted: true,
+	})))
+
+	assert.NoError(mock.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(`1`).Set(`name`, `tester`).Set(`password`, `hunter2`).Set(`email`, `tester@example.com`),
+	)))
+
+	for _, url := range []string{
+		`/api/collections/users/records/1`,
+		`/api/collections/users/where/name/tester`,
+	} {
+		w := testRequest(handler, `GET`, url, ``)
+		assert.Equal(http.StatusOK, w.Code, url)
+
+		body := w.Body.String()
+		assert.Contains(body, `tester`, url)
+		assert.Contains(body, dal.RedactedValue, url)
+		assert.NotContains(body, `hunter2`, url)
+		assert.NotContains(body, `tester@example.com`, url)
+	}
+
+	// records written by the API are masked in the response too
+	w := testRequest(handler, `PUT`, `/api/collections/users/records/1`, `{"fields":{"password":"hunter3"}}`)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.NotContains(w.Body.String(), `hunter3`)
+
+	// the stored record is left alone
+	record, err := mock.Retrieve(`users`, `1`)
+	assert.NoError(err)
+	assert.Equal(`hunter3`, record.Get(`password`))
+	assert.Equal(`tester@example.com`, record.Get(`email`))
+}
+
+func TestPayloadValidation(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer(func(server *Server) {
+		server.ValidatePayloads = true
+	})
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name:     `name`,
+		Type:     dal.StringType,
+		Required: true,
+	})))
+
+	// both the current and the legacy routes reject records that don't match the schema, without
+	// writing anything
+	for _, url := range []string{
+		`/api/collections/users/records`,
+		`/api/collections/users`,
+	} {
+		w := testRequest(handler, `POST`, url, `{"records": [{"id": 1, "fields": {"name": 5}}]}`)
+		assert.Equal(http.StatusBadRequest, w.Code, url)
+		assert.Contains(w.Body.String(), `/fields/name`, url)
+
+		w = testRequest(handler, `POST`, url, `{"records": [{"id": 1}]}`)
+		assert.Equal(http.StatusBadRequest, w.Code, url)
+
+		w = testRequest(handler, `PUT`, url, `{"records": [{"id": 1, "fields": {"name": 5}}]}`)
+		assert.Equal(http.StatusBadRequest, w.Code, url)
+
+		assert.Empty(mock.CallsTo(`Insert`), url)
+		assert.Empty(mock.CallsTo(`Update`), url)
+	}
+
+	// valid records are written, and partial records are allowed when updating
+	w := testRequest(handler, `POST`, `/api/collections/users`, `{"records": [{"id": 1, "fields": {"name": "tester"}}]}`)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.True(mock.Exists(`users`, 1))
+
+	w = testRequest(handler, `PUT`, `/api/collections/users`, `{"records": [{"id": 1}]}`)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Len(mock.CallsTo(`Update`), 1)
+}
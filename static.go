@@ -192,44 +192,69 @@ var _escData = map[string]*_escFile{
 
 	"/_layouts/default.html": {
 		local:   "ui/_layouts/default.html",
-		size:    2036,
+		size:    2377,
 		modtime: 1500000000,
 		compressed: `
-H4sIAAAAAAAC/6xVMW/jOgze8ytUvVkWULwpsD28dzfcdB263EhbjKNUlnwS7SYw8t8PipLGTZweisaL
-TerjR30kZQkhFpW2StsmLBeCWWhxyeITCKgPC8Y8Btf7GpdsKaHT8rgQI/OHbz//f/719J2tqTXlIo8v
-ZsA2BUfLy0UkytcIKn0ezFB73RGjXYcFJ9yS3MAAyctZ8HXB5SbIze8e/U48Zo/Zv1mrbbYJvMxlwn2e
-rnKOAnnosqq3yuAdKAlpjf4ORIGgw3AHolSzbBOcFYPGV/RfJISuu2A4UxhtX5hHU/BAO4NhjUicrT2u
-Ci7rMC15VFaHwKf5ol1+gm3lLAl4xeBavAvhTLG+ShnLNc9xJiFNBssnPTjKZTLSMZHpnCSjcmo3yWxh
-YLWBEApuYajAs5XeohLkOpYcQoF/YVWT3kcfbjuwairhQYg3IyXqiZx954vPu2SCXNMY9PwKlnQmjutV
-BQTH2ILXzhjoAt6CgW+QCv5Pi7a/xoDXIGpnyTsTCv4BKGlGVfAVmLl0B5SBKvbx+bC5WC7dAOlLEeVV
-cB46sPPVEbp29nBUOrDvI3OZSnT2CjGZiQNE6YsWi7eKMa2Okmc2BBdRlY8tP00lP00aXOS7KQa3xFoj
-oCc3ky7NTPkf1C9o1TKXVcnGkWWnOyRLt0NWJQDb72cpxpHpFbO4vR15taCtwi36W5RpZ57J8oPl8kci
-OWz8Jm5O0F+yjyO7IXd+HpQeJqdSWhim/4g4C7HnceDREj/1KNqgLXqxMr1WvBxHRth2BgjZGZ2x/X6S
-Ipfpb5LLdEv/CQAA//9Rj2LU9AcAAA==
+H4sIAAAAAAAC/61WTW/bMAy951do2lk2UOwU2D6022GXrQN62ZG2GUepLHmSnA8U/e+jrHRxE7tD0fji
+SCLfI59IOkKIRSl1LXXjlgvBNLS4ZOFxHnzvFoxZdKa3FW0vU+hkejwQ5Jl9+vrz7uH3/Te29q0qFll4
+MQW6yTlqXiwCULZGqOPPYekqKzvP/KHDnHvc+3QDW4i7nDlb5TzduHTzp0d7EDfJTfIlaaVONo4XWRrt
+3g9XGuOdt9AlZa9rhVeA9OjXaK8ARIp26K4AFDUjEKPFVuKOovsYIHTdGcIJQkn9SMWhcu78QaFbI5Lr
+2uKKfCs3ljxkRjt8zBfWxTvQVkZ7ATsqxhavAjgh1kchg1zTGCcQL73C4l5ujc/SuIhtksY+iYvS1IcR
+s4YtqxQ4l3P6WYJlK7nHWnjTsbgharCPrGzi+7iH+w50PU7hE3UtGz1Z2Xtv9Ku98LwiI5qmUWj5hVnM
+M2Jcntbg4eib88ooBZ3DOTOwDfqcf25R95c2YCWIikrAGkVxvWEUc8Y65ytQU3SDlYIy3OPDEFyQSzbg
+5XkSxYVz5gh9Wh0hKb6hVcjktWeWRolOu0KMamIwqeXZFYt/ijFZH1OeCAjOvEobrvylKvlLpcEZ3+Db
+qzNnek1wHIt/ZCukx5ZyHZOL0B4j4ltrdg4Dc5YqeR1UUtgZhbz4FXr3utiuWmMLvPiBO3ZH0mMVCmKe
+I0t7NSXqVIXQIGCtEtB7M6dvWdxC9Yi6XlK1FOzpiSUvH+YkfnKTMhqw5+dJCHKRK6ZxP+95cUBL3KOd
+g4yRWZYWbxwX3yPIEPis3VRC/2Enl5l0p5uMWmg06lISfzx4Q4OFRgpTBDUN7uMdhTVITQ28Ur2kaUm0
+VCudAo/sZJ1QHCMKynUY0TS2h78+fwECnM/6SQkAAA==
 `,
 	},
 
 	"/_query.html": {
 		local:   "ui/_query.html",
-		size:    3663,
+		size:    3958,
 		modtime: 1500000000,
 		compressed: `
-H4sIAAAAAAAC/+xWzY7jNgy+5ykINdjJAONoD+0evHYKFEWBFmh7KNDrjmzRY+0qkiPJyQZG3r2QbOfH
-sTOZor0U9SGxROoj+ZEmFUXRLBOKC/Vi41kEiq0xBv8YtLV0dhbedG1yjCGmrBI011Ji7oRWljYNbCw8
-C/4MhwPdlWjQ72kDC7+/eX6EZyall84AKmbY2sYzjy/FWrgY4KGDCGuv9xDEuigsuvgobtcnudXGnz7K
-/fokLQRKbk+n23Ur9yEnXGwhl8zalORaOSYUmqiQteBkFRASxY4a/jUzemfRRCUyjqZTCopnUIptM2ai
-zDDFyeqCm4Re6vVKsxNQoc2611jLiNVOwy767j0BFthOCT2zG44IVdUO3L7ClJSCc1QkpNBH1SeJwJbJ
-GlNy4c8Q6cy7ABq9GF1XA60zm52u9znyDBotSeeIw6+ud2MztL7pjF/jjnsQsapCxUccCYey2jmtRmX+
-aR2ydbYWjkxqdVYzpyBzKrKYa8WZ2Y+fWE3iJOJIC4OCRcXO/1pkJi/JKqFiIgjaRjHCtS+aQaIutxLq
-M9CVLFVs2zF7zmZfuluBu/PCLT8cqy2Lvg316kpdW6a4KPaw7PvCsmsF3f+nXNfKweHQt4iElh/O69ix
-TGIPHRbDYnP+KxoJ15kJhly5+vnHhLpyWv5T+MRv67xTma0+juskdGjd6135mbhM8/3lXtOAYeoFYS4U
-x69PMDeYa8MhTkdZ9DLbdsS7CeA+PR3uUvDQURyf1Abr9hJTUjHurcfwntyo2+uMQfiN7Lp7qSQTqns3
-aCutrNjiDcwprobPibsvTzDfesr6KNumPcbTgLPec5SCv+ZTXwqezS8ti+U9JzgInpLNJ38uZBkOh089
-yB02mwZEAcL+yiof5itBHe3a3IjKnTVW+pltWbt7h9X+mS+KWoVpsHhs7j7lny0zwJljkMIvf/z+27Ji
-xuKiaeCz1cq3iRDM48fZm1Dni4dvJrh8eFx66D8F7tAsvOkneJvLoaVrKVllkcfgTI1PbwLw4fzTuglt
-k3ZXpaC06MvF1sboWnHkPwSiSUOAHMh/oHr+r5p/qWruLY1cc1z1iUhoWN5lRPF7bEwPp+l5+/esJfSV
-KZPQMLam7l43hugNTAalwSIlFLlw2nx/um6nF9fsd+0sS4fD+7XJObhJVqhyIW/cIdtY2FuCvE7AOOFX
-BF8Q2t1Hu7+/AgAA//9SGR81Tw4AAA==
+H4sIAAAAAAAC/+1XTW/bOBC951cQ3KBxgMjsoduDK3uBxaJAC7QFWmCvCSVSERuaVEjKrhHkv3dIibFk
+S46yH5fFCrAtcoZvZt4MOXSSJGeZUEyoW7s4S5Cia75A/jHc1tLZs/Cma5PD/ILQSpBcS8lzJ7Sy5OEB
+3Vt0I9gNenwk25Ib7ue0QTM/f39ziW6olF4KSBU1dA12PL4Ua+HA1EULEcZe7yKIdVFYDvIobsZ7udXG
+r36S+/FeWggumd2vbsaNPIGQUyY2KJfU2iXOtXJUKG6SQtaC4VVASBV90vCvmdFbCzolp4ybVikodqBA
+MaMmyQxVgNPjJiV9vai0x6FdqRTqDjn+w8HbbekwKg0vlpjYvORr+ts+BcuelQ5ewBQRs6CooEmx9d+O
+ZpLjVUrECn0LeHsnCH2pR5wJp83f86iStW0d+sy36CvPtWF9p/ajQpt1BFnLhNZOo23y62uMaGMfkyOj
+qqodcruKL3EpGOMKh0r3yY9uY7Shsoapk+53khhAk1uj6+pAq2MzBgs+J77QjJa4dcRzGd24P7R+3xo/
+xh32IKFVxXv11FuU1c5pNSjzT+OQrTPYgnhUq7WaOYXgk1jIkmLU7IZXrEZxhmvAcmrysqmC4SBIE8UA
+135vHSSqP5USn4F2ZxMo55bZLptxh28E33b3Zfn2qdqy5E3Y1q7UtYXtK4odmsfjc96emO3vda5r5SCL
+8SRNSfm2W8dhG0bodk/2Y3D+sBkI15kRhly5+vBHSuBnVP4+nISndV6pzFbvhnVg1hxSPeBn6jLNdv05
+IA7OvFuOzoEx/uMKnZuw0dFiOciil9mmcUwmgPn0tLhzwcLB69ioNrJuJ6H0K8q89QV6jU/U7XHGUPhO
+7Lp9qSR0kvYdAqmgRYoNP4E5xtXhs+fuDnjbeMpilE1vG+LpgLPoOZeCPedTLAXP5l3DYjllBUOCwXF2
+7deFLMPa6wgywSaoigIJ+4lWPsxngnqya3MjKtc5WMl3uqHN7ASr8TmfFbUK3WB2+TB5lX821CBGHUVL
+9PHbl89zuOlYPoNovlut/DERgrl8d/Yi1PPZxS8jXF5czj30n3BYcTPzpq/Qy1wORzq0P1pZzhbImZpf
+vQjAh/NP66akSdqkSuHScl8utjbQA4Eh9nsgGj9ghB/xf6B6/q+af6lqppZGrhlfxUSkJAwnGVFsio3x
+5jTeb/+aNcA53WVAwbetsbvXiSZ6ApNO/Jfwqully8Pm/VznPLhJwhU4F/LEHfLoP87zQR4nYJjwI4J7
+hLb30fbnJxEmjkV2DwAA
+`,
+	},
+
+	"/console.html": {
+		local:   "ui/console.html",
+		size:    2565,
+		modtime: 1500000000,
+		compressed: `
+H4sIAAAAAAAC/8VWXW/TMBR9z6+wwqSkYkmgjD2UrC/wxAOID/GCEHOTm8Xg2KnttJu6/XeunaTNkoI2
+gUSkNo19z/G5x9c3jaLIWzGRM3GlF15EBK1gQeyVSc4hM0wK7RGiQMtGZTi3SGjNEp2VUFEvQniasw3J
+ONX6ws+kMJQJUFHBG5b7S89SpeW8D6hMdOYvPzSgbshrpJYc0qScL702sJCqIix3RHbO73F2IlJy2zG6
+4Hvr8qjKo/lg2oVosDncI7ESleRk+BDpynepO6Yu7RGXvXY7oqi4AhL3nsUDm8jd3QSRytrOkQ3lDbIj
+QYxh9s4KAutrfAzXmlweaC5nGEBa4ZBjIIgcR5YdNE1axqPi2tD7FiQt1cC3BI37o41nYxuZqJsHumhu
+aszTwLXpHV37pOY0g1LyHBTiGTegSAjxVbxwMUmtoGDXCzrzB0ZJ1VqzRkcuKeeX1rjHpDH/p2kUDHiu
+J7m4wf+nSktlRprc0GMUPf9rRaKpVqB6TZxVzOx3cv7SJxUTF/6zvxO1aozBg9QuqJuVW6PDrIwg+EFF
+7lYrVlFsMPb3isvs55GjnLJ9gpQUNCq29huNvPGXacKW5GMjRgepVXA0iTSxzvRdrDwf9rBIN5WV4w96
+4Au7SHnegW32QwA224YbPQTMLcAt1928VGeK1WZQE8kPuqHtaJfwSVg0wnWVcLbb6z4JgyfdUsEsxrmg
+tTM4JftwGMTbC2I8oxsQ5g0UFMWFs1fevYANVa46yAXym5JpDBjPH5ocRtnguMA2GgZfJ633GwrD+gmP
+kKyPYtcHCLm9JQH2i2CK1YYqbKrI8IYaiIXcTvI4ia/AvP30/l0YuBfdoL8nAXk6zOEpCZJtCQrcxPqU
+7CZV1vaHxRHBXefYqz6dYO05tq/iKdad8D8g3QFcHEO2R/M30LtZnEsBh5rJqaGzaVKD+ulrGwltBYaT
+WHtZmrit6e+ZbISxzpGuyAkTxNoXHnaERP1GzWxkpYMJ7XjXxrI6cpT1Q0vxhcEWlEvn2Cb1/3ZorSHH
+vaJcw9TUu1EtolsFZfzg1nWpHmdWEIwoH5QFLmPNrDEGbJ3acrdjaJlp9Gckngg9PPe/7R3/GbhesfR+
+AXDBj7kFCgAA
 `,
 	},
 
@@ -15783,6 +15808,35 @@ DjN9tCCmVhCLGvtUShdMkEEw4fT7iXjPCQ8jCXw2nRP9z+HAZ5O5e9HAISefTcZ/xkiuLStJcm30sc43
 `,
 	},
 
+	"/schema.html": {
+		local:   "ui/schema.html",
+		size:    3584,
+		modtime: 1500000000,
+		compressed: `
+H4sIAAAAAAAC/61XW2/bNhR+96840IJKxiwZCPrkOQ6GJA8ZhrZoXGDAMKC0RFlMaFIlKTuB6/++Q1Gy
+JEsOsnYCkoiH5/KduxKG4WjFRMLEWs9GIQiyoTOwj44zuiEjAEW1LFSMZH9KcjZ1F9P9Hr5p+BpLzmls
+mBRf4XDwkV/m9kT4DIwqKBJSwvmKxE8z2B9GIRqcJ2wLMSdaX3mxFIYwQZW3GFmz8+yyvtqY8H1FtQ8a
+ZOmAzSPDQwlsBoPI2moo17RN+kB3cHPk7nCKpGacT7PLxci9WvwsufJcKEJtiCm0V+MmnCoDSSikoB4o
+yWlF8xbzKYq2tVQiqVSbcK1kkbccnnOyohzwDuWTDROhkU9UeIvf7QGW9jCflkwtISbywnT02hAjCmgf
+Qr3xwLzkCC1Hzp1UiVe61DYEOScxzSRPKEIIFP1WMEUTKASnWoPJKGiqtlTBjmjAKCiDtztmMghDJjSN
+C0XDUuO4Tu+PBqAKdUJTJphNk7e4Pb73o2DoM8Kh5Eixz1BQLGO4kULqHH31OvytFLfsdliU3KHGy/dd
+qs4p5ygYP6E1gtXWXC/OlTHSH7UULH2BqO7IyFl3t1XV7ktVnm1Uz3aq500cJWWUJxppf/8zOhxrdz6t
+Q/EjCVgVxkjRqXWypcdKXxkB+BPmim2IemlJulI8Kic4BMJ0Z3+XCrAR2AIe8LXq2sbk1NmsIL6p8Qdg
+JpRT0weaELHGck25JCZUbJ2ZN2E2iuisAn1bah6aFw30cxOkDH2dgbmOFctN1YU2S9NHsiWOWsG6CNJC
+lFaC8f6odksUNKGAK/CHh/FvTQwvAv+XVmv742hLeLDDOpO7iMuY8AcjFVnTaE3NvaGbwM/ZVpqoFFo6
+Gfj+HXx/3FJrkdixQLVBGEesG2oymUygUHwCK5m8jGHfCbOVK4Gg1DA0tNIWGEKqzyKdOOVtpGWzUlMo
+ARcReSTPQReRfRzsWfsw6TGhT7P2oc+REEOOLNb7PoudPlSYJaYe1yrJc85iYkM3tTPA7wtklOAQ1k5t
+4EJ3DXvw/wo/We/DcieEzv1ZFdsD2JU77mo7tCJ76KUyl6qTSfk0wUhojfE+zaHNW2cB+uMe7EjRjdzS
+G9tSge9WIpS7MNRFHNst4k6uMYc0kCRx4vIJPfY7wj7Masp5BbazgtqH876nhHHcXi3fnzN16rMLUFDO
+9AlYDvRQ5xJ33R8PHz/Au3dwSouoUlKVvWOvXKiWiOkMlnZYcT5iOyAUP8YCecK6HhoHtQfNjjqpe6Ne
+oF/tDTs6XSLNidI0aAFoWOquPGnLA2DZxlnQK45+sPx7gRpYUprCtMGvQE+0NT16YqXrDi6DoJl0w5bL
+kRT4n74sMWid71ZruJGeOM+1UbhvcfcGjcvjcZRguQbnQn7ipv3YRVNunUGR4xSgSeQPuHgYR7bYAldx
+vYiWe/41nz4+nDrl/w9utCasZYsyRVO7WioT103MrsrciVgm9Mvn+xu5wVrHYdayGdmvk//s+ag9oob6
+wS31N3aErZLKJ5y2KVO4KKrdbT9cW/vT65YEHnwPCO5t/L8FZArM6HKmX/vjV4vt9u7Pu+Xd6/X2s8nw
+fyqo9d/51H1lLEb/AtAQUyoADgAA
+`,
+	},
+
 	"/views/backend.html": {
 		local:   "ui/views/backend.html",
 		size:    4555,
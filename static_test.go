@@ -0,0 +1,30 @@
+package pivot
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedUiIsCurrent(t *testing.T) {
+	assert := require.New(t)
+
+	for _, name := range []string{
+		`/_layouts/default.html`,
+		`/_query.html`,
+		`/console.html`,
+		`/index.html`,
+		`/schema.html`,
+	} {
+		local, err := ioutil.ReadFile(filepath.Join(`ui`, name))
+		assert.NoError(err, name)
+
+		embedded, err := FSByte(false, name)
+		assert.NoError(err, name)
+
+		// if this fails, run "go generate" to rebuild static.go
+		assert.Equal(string(local), string(embedded), name)
+	}
+}
@@ -0,0 +1,162 @@
+package pivot
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/httputil"
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/dal/encoding"
+	"github.com/ghetzel/pivot/filter"
+)
+
+const ContentTypeNDJSON = `application/x-ndjson`
+const ContentTypeAvro = `application/avro`
+
+var StreamHeartbeatInterval = time.Duration(15) * time.Second
+
+// Returns whether the client has requested that results be streamed as newline-delimited JSON,
+// either by accepting application/x-ndjson or by specifying ?stream=true.
+func wantsStream(req *http.Request) bool {
+	if strings.Contains(req.Header.Get(`Accept`), ContentTypeNDJSON) {
+		return true
+	}
+
+	switch strings.ToLower(httputil.Q(req, `stream`)) {
+	case `true`, `1`, `yes`:
+		return true
+	}
+
+	return false
+}
+
+// Returns whether the client has requested query results as an Avro object container file, either by
+// accepting application/avro or by specifying ?format=avro.
+func wantsAvro(req *http.Request) bool {
+	if strings.Contains(req.Header.Get(`Accept`), ContentTypeAvro) {
+		return true
+	}
+
+	return strings.ToLower(httputil.Q(req, `format`)) == `avro`
+}
+
+// Writes query results to the response as an Avro object container file, using a schema generated from
+// the collection.  Like streamed results, records are written as they are produced.
+func avroQuery(w http.ResponseWriter, req *http.Request, search backends.Indexer, collection *dal.Collection, f *filter.Filter) {
+	var count int
+
+	writer, err := encoding.NewAvroWriter(w, collection)
+
+	if err != nil {
+		respond(w, req, err)
+		return
+	}
+
+	w.Header().Set(`Content-Type`, ContentTypeAvro)
+
+	if _, err := search.Query(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+		select {
+		case <-req.Context().Done():
+			return req.Context().Err()
+		default:
+		}
+
+		if record == nil {
+			return err
+		}
+
+		count += 1
+		return writer.Write(collection.MaskRecord(record))
+	}); err == nil {
+		err = writer.Close()
+	}
+
+	if err != nil {
+		log.Warningf("[%s] avro export of %s failed: %v", GetRequestId(req), collection.Name, err)
+	}
+
+	setRequestRecordCount(req, count)
+}
+
+// Writes query results to the response as they are produced, one JSON-encoded record per line.
+// While waiting on results, an empty line is periodically written so that intermediate proxies
+// don't consider the connection idle.  If an error occurs after the response has started, it is
+// written as a final {"error": "..."} line.
+func streamQuery(w http.ResponseWriter, req *http.Request, search backends.Indexer, collection *dal.Collection, f *filter.Filter) {
+	var lock sync.Mutex
+	var count int
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	done := make(chan bool)
+
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	w.Header().Set(`Content-Type`, ContentTypeNDJSON)
+	w.Header().Set(`X-Content-Type-Options`, `nosniff`)
+	w.WriteHeader(http.StatusOK)
+	flush()
+
+	go func() {
+		ticker := time.NewTicker(StreamHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				lock.Lock()
+				w.Write([]byte("\n"))
+				flush()
+				lock.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	_, err := search.Query(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+		// stop the query if the client has gone away
+		select {
+		case <-req.Context().Done():
+			return req.Context().Err()
+		default:
+		}
+
+		if record == nil {
+			return err
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err := encoder.Encode(collection.MaskRecord(record)); err != nil {
+			return err
+		}
+
+		count += 1
+		flush()
+		return nil
+	})
+
+	close(done)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err != nil && req.Context().Err() == nil {
+		encoder.Encode(map[string]interface{}{
+			`error`: err.Error(),
+		})
+	}
+
+	setRequestRecordCount(req, count)
+	flush()
+}
@@ -0,0 +1,140 @@
+package pivot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWantsStream(t *testing.T) {
+	assert := require.New(t)
+
+	req := httptest.NewRequest(`GET`, `/`, nil)
+	assert.False(wantsStream(req))
+
+	req.Header.Set(`Accept`, ContentTypeNDJSON)
+	assert.True(wantsStream(req))
+
+	for _, q := range []string{`true`, `1`, `yes`, `TRUE`} {
+		assert.True(wantsStream(httptest.NewRequest(`GET`, `/?stream=`+q, nil)), q)
+	}
+
+	assert.False(wantsStream(httptest.NewRequest(`GET`, `/?stream=false`, nil)))
+}
+
+// decodes each non-empty line of an NDJSON response
+func ndjsonLines(body string) ([]map[string]interface{}, error) {
+	lines := make([]map[string]interface{}, 0)
+	scanner := bufio.NewScanner(strings.NewReader(body))
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != `` {
+			var out map[string]interface{}
+
+			if err := json.Unmarshal([]byte(line), &out); err == nil {
+				lines = append(lines, out)
+			} else {
+				return nil, err
+			}
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+func TestStreamQuery(t *testing.T) {
+	assert := require.New(t)
+	_, mock, handler := newTestServer()
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`users`).AddFields(dal.Field{
+		Name:   `password`,
+		Type:   dal.StringType,
+		Hidden: true,
+	})))
+
+	assert.NoError(mock.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(`1`).Set(`group`, `a`).Set(`password`, `hunter2`),
+		dal.NewRecord(`2`).Set(`group`, `b`),
+		dal.NewRecord(`3`).Set(`group`, `a`),
+	)))
+
+	w := testRequest(handler, `GET`, `/api/collections/users/where/group/a`, ``, `Accept`, ContentTypeNDJSON)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(ContentTypeNDJSON, w.Header().Get(`Content-Type`))
+	assert.NotContains(w.Body.String(), `hunter2`)
+
+	lines, err := ndjsonLines(w.Body.String())
+	assert.NoError(err)
+	assert.Len(lines, 2)
+	assert.EqualValues(1, lines[0][`id`])
+	assert.EqualValues(3, lines[1][`id`])
+
+	// streaming can also be requested in the query string
+	w = testRequest(handler, `GET`, `/api/collections/users/where/group/b?stream=true`, ``)
+	lines, err = ndjsonLines(w.Body.String())
+	assert.NoError(err)
+	assert.Len(lines, 1)
+	assert.EqualValues(2, lines[0][`id`])
+
+	// errors after the response has started are written as a final line
+	mock.FailWith(`QueryFunc`, fmt.Errorf("backend went away"))
+
+	w = testRequest(handler, `GET`, `/api/collections/users/where/group/a?stream=true`, ``)
+	assert.Equal(http.StatusOK, w.Code)
+
+	lines, err = ndjsonLines(w.Body.String())
+	assert.NoError(err)
+	assert.Len(lines, 1)
+	assert.Equal(`backend went away`, lines[0][`error`])
+}
+
+// an indexer that yields the same record instances on every query, as caching backends do
+type sharedRecordIndexer struct {
+	*backends.MockBackend
+	records []*dal.Record
+}
+
+func (self *sharedRecordIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...backends.IndexResultFunc) (*dal.RecordSet, error) {
+	for _, record := range self.records {
+		if err := resultFns[0](record, nil, backends.IndexPage{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return dal.NewRecordSet(self.records...), nil
+}
+
+func TestStreamQueryLeavesRecordsIntact(t *testing.T) {
+	assert := require.New(t)
+
+	search := &sharedRecordIndexer{
+		MockBackend: backends.NewMockBackend(),
+		records: []*dal.Record{
+			dal.NewRecord(1).Set(`name`, `alice`),
+			dal.NewRecord(2).Set(`name`, `bob`),
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		streamQuery(w, httptest.NewRequest(`GET`, `/`, nil), search, dal.NewCollection(`users`), filter.All())
+
+		lines, err := ndjsonLines(w.Body.String())
+		assert.NoError(err)
+		assert.Len(lines, 2)
+		assert.EqualValues(1, lines[0][`id`])
+		assert.Equal(`bob`, lines[1][`fields`].(map[string]interface{})[`name`])
+	}
+
+	assert.EqualValues(1, search.records[0].ID)
+	assert.Equal(`alice`, search.records[0].Get(`name`))
+}
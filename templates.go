@@ -0,0 +1,128 @@
+package pivot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/ghetzel/pivot/filter"
+)
+
+// The maximum number of records the "query" template function returns when no limit is given.
+var TemplateQueryLimit = 100
+
+// Returns functions that read data from the given backend, for use in text/template and
+// html/template templates (e.g.: template.New(`page`).Funcs(pivot.TemplateFuncs(db))).  Filters are
+// given in their string form (e.g.: "status/active/-created_at").
+//
+//	query COLLECTION FILTER [LIMIT]       the records matching the filter
+//	retrieve COLLECTION ID                a single record
+//	count COLLECTION [FILTER]             the number of records matching the filter (or all records)
+//	facets COLLECTION FIELDS [FILTER]     value counts for the given (comma-separated) fields
+func TemplateFuncs(db backends.Backend) map[string]interface{} {
+	return map[string]interface{}{
+		`query`: func(name string, spec string, limit ...int) ([]*dal.Record, error) {
+			f, err := filter.Parse(spec)
+
+			if err != nil {
+				return nil, err
+			}
+
+			collection, search, err := templateSearch(db, name, f)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if len(limit) > 0 {
+				f.Limit = limit[0]
+			} else if f.Limit == 0 {
+				f.Limit = TemplateQueryLimit
+			}
+
+			records := make([]*dal.Record, 0)
+
+			if recordset, err := search.Query(collection, f); err == nil {
+				err := recordset.Each(func(record *dal.Record) error {
+					records = append(records, record)
+					return nil
+				})
+
+				return records, err
+			} else {
+				return nil, err
+			}
+		},
+		`retrieve`: func(name string, id interface{}) (*dal.Record, error) {
+			return db.Retrieve(name, id)
+		},
+		`count`: func(name string, spec ...string) (uint64, error) {
+			collection, err := db.GetCollection(name)
+
+			if err != nil {
+				return 0, err
+			}
+
+			f, err := templateFilter(spec...)
+
+			if err != nil {
+				return 0, err
+			}
+
+			if aggregator := db.WithAggregator(collection); aggregator != nil {
+				return aggregator.Count(collection, f)
+			} else if search := db.WithSearch(collection, f); search != nil {
+				var count uint64
+
+				f.Fields = []string{collection.IdentityField}
+
+				err := search.QueryFunc(collection, f, func(_ *dal.Record, err error, _ backends.IndexPage) error {
+					if err == nil {
+						count += 1
+					}
+
+					return nil
+				})
+
+				return count, err
+			}
+
+			return 0, fmt.Errorf("Backend %T does not support counting records", db)
+		},
+		`facets`: func(name string, fields string, spec ...string) (map[string][]backends.FacetBucket, error) {
+			f, err := templateFilter(spec...)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if collection, search, err := templateSearch(db, name, f); err == nil {
+				return search.Facets(collection, strings.Split(fields, `,`), f)
+			} else {
+				return nil, err
+			}
+		},
+	}
+}
+
+func templateSearch(db backends.Backend, name string, f *filter.Filter) (*dal.Collection, backends.Indexer, error) {
+	if collection, err := db.GetCollection(name); err == nil {
+		if search := db.WithSearch(collection, f); search != nil {
+			return collection, search, nil
+		}
+
+		return nil, nil, fmt.Errorf("Backend %T does not support complex queries", db)
+	} else {
+		return nil, nil, err
+	}
+}
+
+// parses the optional filter given to a template function, matching all records if none was given
+func templateFilter(spec ...string) (*filter.Filter, error) {
+	if len(spec) == 0 || spec[0] == `` {
+		return filter.All(), nil
+	}
+
+	return filter.Parse(spec[0])
+}
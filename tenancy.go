@@ -0,0 +1,174 @@
+package pivot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/pivot/backends"
+)
+
+type TenancyMode string
+
+const (
+	NoTenancy           TenancyMode = ``
+	TenantFromHeader    TenancyMode = `header`
+	TenantFromSubdomain TenancyMode = `subdomain`
+	TenantFromClaim     TenancyMode = `claim`
+)
+
+var TenantHeader = `X-Pivot-Tenant`
+var TenantClaim = `tenant`
+var rxValidTenant = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9\-]*$`)
+
+const (
+	requestBackendKey requestContextKey = iota + 100
+)
+
+// Retrieve the backend that should be used to service the given request.  If tenancy is enabled,
+// this will be a backend scoped to the tenant the request was made on behalf of.
+func (self *Server) db(req *http.Request) backends.Backend {
+	if backend := requestBackend(req); backend != nil {
+		return backend
+	}
+
+	return self.backend
+}
+
+// Returns the backend the given request is being serviced by, if it has been wrapped by tenantScoped.
+func requestBackend(req *http.Request) backends.Backend {
+	if v := req.Context().Value(requestBackendKey); v != nil {
+		if backend, ok := v.(backends.Backend); ok {
+			return backend
+		}
+	}
+
+	return nil
+}
+
+// Wraps the given handler such that requests know which backend is servicing them.  When tenancy is
+// enabled, every request is required to identify a tenant and is serviced by a backend scoped to that
+// tenant.
+func (self *Server) tenantScoped(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		backend := self.backend
+
+		if self.TenancyMode != NoTenancy {
+			if tenant, err := self.tenantFromRequest(req); err == nil {
+				backend = backends.NewTenantBackend(self.backend, tenant, &self.tenantDefinitions)
+			} else {
+				respond(w, req, err, http.StatusUnauthorized)
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, req.WithContext(
+			context.WithValue(req.Context(), requestBackendKey, backend),
+		))
+	})
+}
+
+func (self *Server) tenantFromRequest(req *http.Request) (string, error) {
+	var tenant string
+
+	switch self.TenancyMode {
+	case TenantFromHeader:
+		tenant = req.Header.Get(TenantHeader)
+
+	case TenantFromSubdomain:
+		host := req.Host
+
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if parts := strings.SplitN(host, `.`, 2); len(parts) == 2 {
+			tenant = parts[0]
+		}
+
+	case TenantFromClaim:
+		if auth := req.Header.Get(`Authorization`); strings.HasPrefix(auth, `Bearer `) {
+			if claims, err := verifyHS256Token(strings.TrimPrefix(auth, `Bearer `), self.tenantTokenSecret()); err == nil {
+				if v, ok := claims[TenantClaim].(string); ok {
+					tenant = v
+				}
+			} else {
+				return ``, err
+			}
+		}
+
+	default:
+		return ``, fmt.Errorf("Unsupported tenancy mode %q", self.TenancyMode)
+	}
+
+	if tenant == `` {
+		return ``, fmt.Errorf("No tenant specified")
+	} else if !rxValidTenant.MatchString(tenant) {
+		return ``, fmt.Errorf("Invalid tenant %q", tenant)
+	}
+
+	return tenant, nil
+}
+
+// Verifies a JSON Web Token signed with HMAC-SHA256 and returns its claims.
+func verifyHS256Token(token string, secret string) (map[string]interface{}, error) {
+	if secret == `` {
+		return nil, fmt.Errorf("No token secret configured")
+	}
+
+	parts := strings.Split(token, `.`)
+
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("Malformed token")
+	}
+
+	var header map[string]interface{}
+	var claims map[string]interface{}
+
+	if data, err := base64.RawURLEncoding.DecodeString(parts[0]); err == nil {
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, fmt.Errorf("Malformed token header: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("Malformed token header: %v", err)
+	}
+
+	if alg, _ := header[`alg`].(string); alg != `HS256` {
+		return nil, fmt.Errorf("Unsupported token algorithm %q", alg)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + `.` + parts[1]))
+
+	if signature, err := base64.RawURLEncoding.DecodeString(parts[2]); err == nil {
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, fmt.Errorf("Invalid token signature")
+		}
+	} else {
+		return nil, fmt.Errorf("Malformed token signature: %v", err)
+	}
+
+	if data, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+		if err := json.Unmarshal(data, &claims); err != nil {
+			return nil, fmt.Errorf("Malformed token claims: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("Malformed token claims: %v", err)
+	}
+
+	if exp, ok := claims[`exp`].(float64); ok {
+		if time.Now().After(time.Unix(int64(exp), 0)) {
+			return nil, fmt.Errorf("Token has expired")
+		}
+	}
+
+	return claims, nil
+}
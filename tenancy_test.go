@@ -0,0 +1,72 @@
+package pivot
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ghetzel/pivot/backends"
+	"github.com/ghetzel/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantBackendIsolation(t *testing.T) {
+	assert := require.New(t)
+	mock := backends.NewMockBackend()
+
+	a := backends.NewTenantBackend(mock, `a`, nil)
+	ab := backends.NewTenantBackend(mock, `ab`, nil)
+
+	assert.NoError(a.CreateCollection(dal.NewCollection(`users`)))
+	assert.NoError(ab.CreateCollection(dal.NewCollection(`users`)))
+	assert.NoError(a.Insert(`users`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `a`))))
+
+	// names that could reach into another tenant's collections are refused
+	for _, name := range []string{`_users`, `b__users`, `__users`, ``} {
+		assert.Error(a.CreateCollection(dal.NewCollection(name)), name)
+
+		_, err := a.GetCollection(name)
+		assert.Error(err, name)
+
+		_, err = a.Retrieve(name, 1)
+		assert.Error(err, name)
+
+		assert.False(a.Exists(name, 1), name)
+	}
+
+	// as are tenants whose names could collide with one another
+	for _, tenant := range []string{`a_`, `a__b`, `_a`, ``} {
+		_, err := backends.NewTenantBackend(mock, tenant, nil).GetCollection(`users`)
+		assert.Error(err, tenant)
+	}
+
+	names, err := a.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`users`}, names)
+
+	assert.False(ab.Exists(`users`, 1))
+	assert.True(a.Exists(`users`, 1))
+}
+
+func TestTenantFromRequest(t *testing.T) {
+	assert := require.New(t)
+
+	_, mock, handler := newTestServer(func(server *Server) {
+		server.TenancyMode = TenantFromHeader
+	})
+
+	assert.NoError(mock.CreateCollection(dal.NewCollection(`a__users`)))
+
+	w := testRequest(handler, `GET`, `/api/schema/users`, ``)
+	assert.Equal(http.StatusUnauthorized, w.Code)
+
+	for _, tenant := range []string{`a_`, `a__b`, `-a`} {
+		w = testRequest(handler, `GET`, `/api/schema/users`, ``, TenantHeader, tenant)
+		assert.Equal(http.StatusUnauthorized, w.Code, tenant)
+	}
+
+	w = testRequest(handler, `GET`, `/api/schema/users`, ``, TenantHeader, `a`)
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = testRequest(handler, `GET`, `/api/schema/_users`, ``, TenantHeader, `a`)
+	assert.NotEqual(http.StatusOK, w.Code)
+}
@@ -0,0 +1,128 @@
+package pivot
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+var TLSReloadCheckInterval = time.Duration(10) * time.Second
+
+// Holds a certificate/key pair loaded from disk, reloading it whenever either file changes.  This
+// allows certificates to be rotated without restarting the server.
+type certificateReloader struct {
+	certFile    string
+	keyFile     string
+	certificate *tls.Certificate
+	modTime     time.Time
+	lastCheck   time.Time
+	lock        sync.Mutex
+}
+
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	reloader := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+func (self *certificateReloader) latestModTime() time.Time {
+	var latest time.Time
+
+	for _, filename := range []string{self.certFile, self.keyFile} {
+		if stat, err := os.Stat(filename); err == nil && stat.ModTime().After(latest) {
+			latest = stat.ModTime()
+		}
+	}
+
+	return latest
+}
+
+func (self *certificateReloader) reload() error {
+	modTime := self.latestModTime()
+
+	if cert, err := tls.LoadX509KeyPair(self.certFile, self.keyFile); err == nil {
+		self.certificate = &cert
+		self.modTime = modTime
+		return nil
+	} else {
+		return fmt.Errorf("Cannot load TLS certificate: %v", err)
+	}
+}
+
+// Implements tls.Config.GetCertificate.  If the certificate fails to reload (e.g.: because the
+// files are mid-rotation), the previously-loaded certificate continues to be used.
+func (self *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if time.Since(self.lastCheck) >= TLSReloadCheckInterval {
+		self.lastCheck = time.Now()
+
+		if self.latestModTime().After(self.modTime) {
+			if err := self.reload(); err == nil {
+				log.Infof("Reloaded TLS certificate from %v", self.certFile)
+			} else {
+				log.Warningf("%v", err)
+			}
+		}
+	}
+
+	return self.certificate, nil
+}
+
+// Builds a tls.Config from the given configuration.  If a client CA file is specified, client
+// certificates are verified against it; the ClientAuth setting determines whether presenting one
+// is optional ("request") or mandatory ("require", the default).
+func (self *TLSConfig) ServerConfig() (*tls.Config, error) {
+	if self.CertFile == `` || self.KeyFile == `` {
+		return nil, fmt.Errorf("TLS requires both a certificate and a key file")
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if reloader, err := newCertificateReloader(self.CertFile, self.KeyFile); err == nil {
+		config.GetCertificate = reloader.GetCertificate
+	} else {
+		return nil, err
+	}
+
+	if self.ClientCAFile != `` {
+		if data, err := ioutil.ReadFile(self.ClientCAFile); err == nil {
+			pool := x509.NewCertPool()
+
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("No valid certificates found in %v", self.ClientCAFile)
+			}
+
+			config.ClientCAs = pool
+		} else {
+			return nil, fmt.Errorf("Cannot load client CA file: %v", err)
+		}
+
+		switch self.ClientAuth {
+		case `request`:
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		case ``, `require`:
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return nil, fmt.Errorf("Unknown client authentication mode %q", self.ClientAuth)
+		}
+	} else if self.ClientAuth != `` {
+		return nil, fmt.Errorf("Client authentication requires a client CA file")
+	}
+
+	return config, nil
+}
@@ -0,0 +1,282 @@
+package pivot
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// generates a certificate for localhost, signed by the given parent (or self-signed if nil)
+func newTestCertificate(name string, parent *testCertificate) (*testCertificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{`localhost`},
+		IPAddresses:  []net.IP{net.ParseIP(`127.0.0.1`)},
+	}
+
+	signer, signerKey := template, key
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &testCertificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: `CERTIFICATE`, Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: `EC PRIVATE KEY`, Bytes: keyDER}),
+	}, nil
+}
+
+func (self *testCertificate) write(dir string, name string) (string, string, error) {
+	certFile := filepath.Join(dir, name+`.crt`)
+	keyFile := filepath.Join(dir, name+`.key`)
+
+	if err := ioutil.WriteFile(certFile, self.certPEM, 0600); err != nil {
+		return ``, ``, err
+	}
+
+	return certFile, keyFile, ioutil.WriteFile(keyFile, self.keyPEM, 0600)
+}
+
+func (self *testCertificate) keypair() (tls.Certificate, error) {
+	return tls.X509KeyPair(self.certPEM, self.keyPEM)
+}
+
+func TestTLSServerConfig(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-tls-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	ca, err := newTestCertificate(`ca`, nil)
+	assert.NoError(err)
+
+	serverCert, err := newTestCertificate(`server`, ca)
+	assert.NoError(err)
+
+	certFile, keyFile, err := serverCert.write(dir, `server`)
+	assert.NoError(err)
+
+	caFile, _, err := ca.write(dir, `ca`)
+	assert.NoError(err)
+
+	// incomplete or invalid configurations are rejected
+	for _, config := range []TLSConfig{
+		{CertFile: certFile},
+		{KeyFile: keyFile},
+		{CertFile: keyFile, KeyFile: certFile},
+		{CertFile: certFile, KeyFile: keyFile, ClientAuth: `require`},
+		{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile},
+		{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: `sometimes`},
+	} {
+		_, err := config.ServerConfig()
+		assert.Error(err, "%+v", config)
+	}
+
+	config, err := (&TLSConfig{CertFile: certFile, KeyFile: keyFile}).ServerConfig()
+	assert.NoError(err)
+	assert.Equal(uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Equal(tls.NoClientCert, config.ClientAuth)
+
+	config, err = (&TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}).ServerConfig()
+	assert.NoError(err)
+	assert.Equal(tls.RequireAndVerifyClientCert, config.ClientAuth)
+
+	config, err = (&TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: `request`}).ServerConfig()
+	assert.NoError(err)
+	assert.Equal(tls.VerifyClientCertIfGiven, config.ClientAuth)
+}
+
+func TestMutualTLS(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-tls-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	ca, err := newTestCertificate(`ca`, nil)
+	assert.NoError(err)
+
+	serverCert, err := newTestCertificate(`server`, ca)
+	assert.NoError(err)
+
+	clientCert, err := newTestCertificate(`client`, ca)
+	assert.NoError(err)
+
+	strangerCA, err := newTestCertificate(`stranger-ca`, nil)
+	assert.NoError(err)
+
+	strangerCert, err := newTestCertificate(`stranger`, strangerCA)
+	assert.NoError(err)
+
+	certFile, keyFile, err := serverCert.write(dir, `server`)
+	assert.NoError(err)
+
+	caFile, _, err := ca.write(dir, `ca`)
+	assert.NoError(err)
+
+	config, err := (&TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}).ServerConfig()
+	assert.NoError(err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+
+	server.TLS = config
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	get := func(cert *testCertificate) (string, error) {
+		clientConfig := &tls.Config{
+			RootCAs: roots,
+		}
+
+		if cert != nil {
+			if pair, err := cert.keypair(); err == nil {
+				clientConfig.Certificates = []tls.Certificate{pair}
+			} else {
+				return ``, err
+			}
+		}
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: clientConfig,
+			},
+		}
+
+		if res, err := client.Get(server.URL); err == nil {
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			return string(body), err
+		} else {
+			return ``, err
+		}
+	}
+
+	// clients presenting a certificate signed by the CA are permitted
+	body, err := get(clientCert)
+	assert.NoError(err)
+	assert.Equal(`client`, body)
+
+	// others are refused
+	_, err = get(nil)
+	assert.Error(err)
+
+	_, err = get(strangerCert)
+	assert.Error(err)
+}
+
+func TestCertificateReloader(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-tls-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	interval := TLSReloadCheckInterval
+	TLSReloadCheckInterval = 0
+	defer func() {
+		TLSReloadCheckInterval = interval
+	}()
+
+	first, err := newTestCertificate(`first`, nil)
+	assert.NoError(err)
+
+	certFile, keyFile, err := first.write(dir, `server`)
+	assert.NoError(err)
+
+	_, err = newCertificateReloader(filepath.Join(dir, `missing.crt`), keyFile)
+	assert.Error(err)
+
+	reloader, err := newCertificateReloader(certFile, keyFile)
+	assert.NoError(err)
+
+	cert, err := reloader.GetCertificate(nil)
+	assert.NoError(err)
+	assert.Equal(first.cert.Raw, cert.Certificate[0])
+
+	// rotated certificates are picked up
+	second, err := newTestCertificate(`second`, nil)
+	assert.NoError(err)
+
+	_, _, err = second.write(dir, `server`)
+	assert.NoError(err)
+
+	later := time.Now().Add(time.Minute)
+	assert.NoError(os.Chtimes(certFile, later, later))
+	assert.NoError(os.Chtimes(keyFile, later, later))
+
+	cert, err = reloader.GetCertificate(nil)
+	assert.NoError(err)
+	assert.Equal(second.cert.Raw, cert.Certificate[0])
+
+	// a rotation that leaves the files unusable keeps the previous certificate
+	assert.NoError(ioutil.WriteFile(keyFile, []byte(`garbage`), 0600))
+
+	later = later.Add(time.Minute)
+	assert.NoError(os.Chtimes(keyFile, later, later))
+
+	cert, err = reloader.GetCertificate(nil)
+	assert.NoError(err)
+	assert.Equal(second.cert.Raw, cert.Certificate[0])
+}